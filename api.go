@@ -2,10 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"math"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,11 +28,30 @@ type APIServer struct {
 	db          *Database
 	s3Client    *S3Client
 	config      *Config
-	jobQueue    chan *TileJob
+	jobQueue    chan *TileJob // Jobs to run when there is no database
+	jobWake     chan struct{} // Signalled when a job is created in the database
+	workerID    string        // Recorded as "claimedBy" on jobs claimed from the database
 	activeJobs  map[string]*JobStatus
 	jobsMutex   sync.RWMutex
 	subscribers map[string][]chan JobStatusUpdate
+	history     map[string][]JobStatusUpdate // Recent updates per unfinished or streamed job, for Last-Event-ID resume
+	eventSeq    int64                        // Last SSE event ID issued, guarded by subsMutex
 	subsMutex   sync.RWMutex
+
+	sseKeepalive time.Duration // Interval between SSE ping events
+
+	statsCache   *StatsResponse
+	statsMutex   sync.Mutex
+	statsFetcher func(ctx context.Context) (*JobStats, error) // Database aggregates; nil without a database
+
+	// Shutdown state, guarded by jobsMutex
+	httpServer *http.Server   // Set by Start
+	stopping   bool           // Set by Stop; new jobs are refused
+	shutdown   chan struct{}  // Closed by Stop
+	processing sync.WaitGroup // Held by the job processor while it runs
+
+	// runJob runs a job's pipeline; a field so tests can stand in for it
+	runJob func(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error)
 }
 
 // JobStatus tracks the current status of a job
@@ -31,16 +61,27 @@ type JobStatus struct {
 	Error      error
 	UpdatedAt  time.Time
 	CancelFunc context.CancelFunc // Function to cancel the job
+
+	// Set once the job completes
+	TilesDir string     // Absolute path of the region's tiles directory
+	TilesURL string     // Public {z}/{x}/{y} tile URL template, when uploaded
+	Result   *JobResult // What the pipeline produced
 }
 
 // JobStatusUpdate represents a status update for streaming
 type JobStatusUpdate struct {
-	JobID      string    `json:"jobId"`
-	Status     string    `json:"status"`
-	Progress   int       `json:"progress"`
-	Message    string    `json:"message"`
-	Error      string    `json:"error,omitempty"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID        int64     `json:"id,omitempty"` // SSE event ID, increasing across all jobs
+	JobID     string    `json:"jobId"`
+	Status    string    `json:"status"`
+	Progress  int       `json:"progress"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Full job state, set on the snapshot sent when a client connects
+	CurrentStep    *string `json:"currentStep,omitempty"`
+	RoadsExtracted *int    `json:"roadsExtracted,omitempty"`
+	TilesGenerated *int    `json:"tilesGenerated,omitempty"`
 }
 
 // GenerateRequest represents a tile generation request
@@ -69,6 +110,9 @@ type JobStatusResponse struct {
 	CurrentStep           *string `json:"currentStep,omitempty"`
 	RoadsExtracted        *int    `json:"roadsExtracted,omitempty"`
 	TilesGenerated        *int    `json:"tilesGenerated,omitempty"`
+	TotalSizeBytes        *int64  `json:"totalSizeBytes,omitempty"`
+	TilesDir              string  `json:"tilesDir,omitempty"` // Absolute path, once completed
+	TilesURL              string  `json:"tilesUrl,omitempty"` // MapLibre tile URL template, once completed and uploaded
 	UploadProgress        int     `json:"uploadProgress"`
 	ErrorMessage          *string `json:"errorMessage,omitempty"`
 	UpdatedAt             string  `json:"updatedAt"`
@@ -79,37 +123,186 @@ type JobStatusResponse struct {
 	ExtractGeometry       bool    `json:"extractGeometry"`
 	SkipGeometryInsertion bool    `json:"skipGeometryInsertion"`
 	MergeAll              bool    `json:"mergeAll"`
+
+	// Result is what the pipeline produced, including the extraction
+	// summary, once the job has completed on this server
+	Result *JobResult `json:"result,omitempty"`
 }
 
 // NewAPIServer creates a new API server
 func NewAPIServer(db *Database, s3Client *S3Client, config *Config) *APIServer {
-	return &APIServer{
+	s := &APIServer{
 		db:          db,
 		s3Client:    s3Client,
 		config:      config,
 		jobQueue:    make(chan *TileJob, 100),
+		jobWake:     make(chan struct{}, 1),
+		workerID:    newWorkerID(),
 		activeJobs:  make(map[string]*JobStatus),
 		subscribers: make(map[string][]chan JobStatusUpdate),
+		history:     make(map[string][]JobStatusUpdate),
+		shutdown:    make(chan struct{}),
+
+		sseKeepalive: sseKeepaliveInterval(config),
+	}
+	if db != nil {
+		s.statsFetcher = db.GetJobStats
+	}
+	s.runJob = s.runPipeline
+	return s
+}
+
+// newWorkerID identifies this server process among the workers sharing the
+// job table
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// jobPollInterval is how often the job processor checks the database for
+// pending jobs it was not woken for, such as jobs re-queued by the reaper or
+// created by another server; a variable so tests can shorten it
+var jobPollInterval = 5 * time.Second
+
+// sseKeepaliveInterval returns the configured SSE ping interval, defaulting
+// to 30 seconds
+func sseKeepaliveInterval(config *Config) time.Duration {
+	if config != nil && config.Service.SSEKeepalive > 0 {
+		return time.Duration(config.Service.SSEKeepalive) * time.Second
 	}
+	return 30 * time.Second
 }
 
-// Start starts the API server
+// maxSSEHistory bounds how many past updates are kept per job for replay
+const maxSSEHistory = 100
+
+// Start starts the API server and blocks until it fails or Stop is called
 func (s *APIServer) Start(port int) error {
+	// Setup routes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/generate", s.handleGenerate)
+	mux.HandleFunc("/api/jobs/", s.handleJob)
+	mux.HandleFunc("/api/jobs", s.handleListJobs)
+	mux.HandleFunc("/api/stream/", s.handleJobStream)
+	mux.HandleFunc("/api/cancel/", s.handleCancelJob)
+	mux.HandleFunc("/api/regions", s.handleGetRegions)
+	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/roads/nearby", s.handleNearbyRoads)
+	mux.HandleFunc("/health", s.handleHealth)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+
+	s.jobsMutex.Lock()
+	if s.stopping {
+		s.jobsMutex.Unlock()
+		return nil
+	}
+	s.httpServer = server
+	s.processing.Add(1)
+	s.jobsMutex.Unlock()
+
 	// Start job processor
 	go s.processJobs()
 
-	// Setup routes
-	http.HandleFunc("/api/generate", s.handleGenerate)
-	http.HandleFunc("/api/jobs/", s.handleJobStatus)
-	http.HandleFunc("/api/jobs", s.handleListJobs)
-	http.HandleFunc("/api/stream/", s.handleJobStream)
-	http.HandleFunc("/api/cancel/", s.handleCancelJob)
-	http.HandleFunc("/api/regions", s.handleGetRegions)
-	http.HandleFunc("/health", s.handleHealth)
-
-	addr := fmt.Sprintf(":%d", port)
+	// Re-queue jobs whose worker stopped sending heartbeats
+	if s.db != nil && s.config.Service.HeartbeatInterval > 0 && s.config.Service.JobLeaseTimeout > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-s.shutdown
+			cancel()
+		}()
+		go runReaper(ctx, s.db,
+			time.Duration(s.config.Service.HeartbeatInterval)*time.Second,
+			time.Duration(s.config.Service.JobLeaseTimeout)*time.Second)
+	}
+
 	slog.Info("starting API server", "port", port)
-	return http.ListenAndServe(addr, nil)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Stop shuts the server down gracefully. It stops accepting requests and new
+// jobs, ends job streams, and waits for the running job to finish until ctx
+// is done. Jobs still queued or running after that are cancelled and marked
+// cancelled in the database.
+func (s *APIServer) Stop(ctx context.Context) error {
+	s.jobsMutex.Lock()
+	if s.stopping {
+		s.jobsMutex.Unlock()
+		return nil
+	}
+	s.stopping = true
+	close(s.shutdown)
+	server := s.httpServer
+	s.jobsMutex.Unlock()
+
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down http server: %w", err))
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.processing.Wait()
+		close(drained)
+	}()
+	timedOut := false
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		slog.Warn("running job did not finish before the shutdown timeout, cancelling it")
+		errs = append(errs, fmt.Errorf("timed out waiting for the running job: %w", ctx.Err()))
+		timedOut = true
+	}
+
+	s.cancelUnfinishedJobs("Job was cancelled because the server shut down")
+
+	// Give the cancelled job a moment to stop its subprocesses and clean up
+	// before the process exits
+	if timedOut {
+		select {
+		case <-drained:
+		case <-time.After(stopCancelGrace):
+			slog.Warn("cancelled job still running after the grace period", "grace", stopCancelGrace)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopCancelGrace is how long Stop waits for a job it cancelled at the
+// shutdown timeout to return; a variable so tests can shorten it
+var stopCancelGrace = 10 * time.Second
+
+// isStopping reports whether Stop has been called
+func (s *APIServer) isStopping() bool {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+	return s.stopping
+}
+
+// cancelUnfinishedJobs cancels every job that is still queued or running
+func (s *APIServer) cancelUnfinishedJobs(reason string) {
+	s.jobsMutex.RLock()
+	var jobIDs []string
+	for id, status := range s.activeJobs {
+		if !isTerminalJobStatus(status.Job.Status) {
+			jobIDs = append(jobIDs, id)
+		}
+	}
+	s.jobsMutex.RUnlock()
+
+	for _, id := range jobIDs {
+		// A job may finish between the scan and here; that is fine
+		if err := s.cancelJob(id, reason); err == nil {
+			slog.Warn("cancelled unfinished job at shutdown", "job_id", id)
+		}
+	}
 }
 
 // handleGenerate handles POST /api/generate
@@ -118,6 +311,10 @@ func (s *APIServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if s.isStopping() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 
 	var req GenerateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -126,10 +323,12 @@ func (s *APIServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if req.Region == "" {
-		http.Error(w, "Region is required", http.StatusBadRequest)
+	region, err := normalizeRegion(req.Region)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Region = region
 	if req.MaxZoom == 0 {
 		req.MaxZoom = 16
 	}
@@ -173,13 +372,22 @@ func (s *APIServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 	s.jobsMutex.Unlock()
 
-	// Queue job for processing
-	select {
-	case s.jobQueue <- job:
+	// Queue job for processing. With a database the pending row is the
+	// queue, so the processor is only woken to claim it.
+	if s.db != nil {
+		select {
+		case s.jobWake <- struct{}{}:
+		default:
+		}
 		slog.Info("job queued", "job_id", jobID, "region", req.Region)
-	default:
-		http.Error(w, "Job queue is full", http.StatusServiceUnavailable)
-		return
+	} else {
+		select {
+		case s.jobQueue <- job:
+			slog.Info("job queued", "job_id", jobID, "region", req.Region)
+		default:
+			http.Error(w, "Job queue is full", http.StatusServiceUnavailable)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -189,6 +397,25 @@ func (s *APIServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleJob routes requests for a single job: GET /api/jobs/{jobId} returns
+// its status, DELETE /api/jobs/{jobId} and POST /api/jobs/{jobId}/cancel
+// cancel it
+func (s *APIServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	if jobID, ok := strings.CutSuffix(r.URL.Path[len("/api/jobs/"):], "/cancel"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.cancelJobRequest(w, jobID)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		s.cancelJobRequest(w, r.URL.Path[len("/api/jobs/"):])
+		return
+	}
+	s.handleJobStatus(w, r)
+}
+
 // handleJobStatus handles GET /api/jobs/{jobId}
 func (s *APIServer) handleJobStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -226,66 +453,216 @@ func (s *APIServer) handleJobStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Build response
-	resp := JobStatusResponse{
-		JobID:                 status.Job.ID,
-		Region:                status.Job.Region,
-		Status:                status.Job.Status,
-		CurrentStep:           status.Job.CurrentStep,
-		RoadsExtracted:        status.Job.RoadsExtracted,
-		TilesGenerated:        status.Job.TilesGenerated,
-		UploadProgress:        status.Job.UploadProgress,
-		ErrorMessage:          status.Job.ErrorMessage,
-		UpdatedAt:             status.UpdatedAt.Format(time.RFC3339),
-		MaxZoom:               status.Job.MaxZoom,
-		MinZoom:               status.Job.MinZoom,
-		SkipUpload:            status.Job.SkipUpload,
-		SkipGeneration:        status.Job.SkipGeneration,
-		ExtractGeometry:       status.Job.ExtractGeometry,
-		SkipGeometryInsertion: status.Job.SkipGeometryInsertion,
-		MergeAll:              status.Job.MergeAll,
+	resp := newJobStatusResponse(status)
+
+	etag := jobETag(status)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// handleListJobs handles GET /api/jobs
+// jobETag derives a strong ETag from a job's status and last update time
+func jobETag(status *JobStatus) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", status.Job.ID, status.Job.Status, status.UpdatedAt.UnixNano())))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleListJobs handles GET /api/jobs. With ?since=<RFC3339> only jobs
+// updated at or after that time are returned, including historical jobs from
+// the database. The X-Server-Time header carries the cursor for the next poll.
+// ?status=pending lists the job queue instead (see handleListPendingJobs).
 func (s *APIServer) handleListJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	switch r.URL.Query().Get("status") {
+	case "":
+	case "pending":
+		s.handleListPendingJobs(w, r)
+		return
+	default:
+		http.Error(w, "status must be pending", http.StatusBadRequest)
+		return
+	}
 
-	s.jobsMutex.RLock()
-	defer s.jobsMutex.RUnlock()
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
 
-	var jobs []JobStatusResponse
+	// Taken before reading so updates made while we build the response are
+	// picked up by the next poll
+	serverTime := time.Now()
+
+	s.jobsMutex.RLock()
+	jobs := []JobStatusResponse{}
+	seen := make(map[string]bool, len(s.activeJobs))
 	for _, status := range s.activeJobs {
-		jobs = append(jobs, JobStatusResponse{
-			JobID:                 status.Job.ID,
-			Region:                status.Job.Region,
-			Status:                status.Job.Status,
-			CurrentStep:           status.Job.CurrentStep,
-			RoadsExtracted:        status.Job.RoadsExtracted,
-			TilesGenerated:        status.Job.TilesGenerated,
-			UploadProgress:        status.Job.UploadProgress,
-			ErrorMessage:          status.Job.ErrorMessage,
-			UpdatedAt:             status.UpdatedAt.Format(time.RFC3339),
-			MaxZoom:               status.Job.MaxZoom,
-			MinZoom:               status.Job.MinZoom,
-			SkipUpload:            status.Job.SkipUpload,
-			SkipGeneration:        status.Job.SkipGeneration,
-			ExtractGeometry:       status.Job.ExtractGeometry,
-			SkipGeometryInsertion: status.Job.SkipGeometryInsertion,
-			MergeAll:              status.Job.MergeAll,
-		})
+		seen[status.Job.ID] = true
+		if status.UpdatedAt.Before(since) {
+			continue
+		}
+		jobs = append(jobs, newJobStatusResponse(status))
+	}
+	s.jobsMutex.RUnlock()
+
+	if !since.IsZero() && s.db != nil {
+		historical, err := s.getJobsUpdatedSinceFromDB(r.Context(), since, maxJobsSince)
+		if err != nil {
+			slog.Error("failed to query jobs updated since", "since", since, "error", err)
+			http.Error(w, "Failed to list jobs", http.StatusInternalServerError)
+			return
+		}
+		// A full page may have left later updates behind; the next poll
+		// resumes from the last one returned instead of skipping them
+		if len(historical) == maxJobsSince {
+			serverTime = historical[len(historical)-1].UpdatedAt
+		}
+		for _, job := range historical {
+			if seen[job.ID] {
+				continue
+			}
+			jobs = append(jobs, newJobStatusResponse(&JobStatus{Job: job, UpdatedAt: job.UpdatedAt}))
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Server-Time", serverTime.UTC().Format(time.RFC3339Nano))
 	json.NewEncoder(w).Encode(jobs)
 }
 
+// Page sizes for GET /api/jobs?status=pending
+const (
+	defaultPendingJobsLimit = 100
+	maxPendingJobsLimit     = 1000
+)
+
+// handleListPendingJobs handles GET /api/jobs?status=pending&limit=&after=,
+// listing pending jobs oldest first, the order they are claimed in. With a
+// database this is the queue every server claims from, paged with the
+// X-Next-Cursor header passed back as ?after=; without one it is this
+// server's in-memory queue.
+func (s *APIServer) handleListPendingJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultPendingJobsLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPendingJobsLimit {
+			http.Error(w, fmt.Sprintf("limit must be a whole number between 1 and %d", maxPendingJobsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var after *JobCursor
+	if v := query.Get("after"); v != "" {
+		cursor, err := ParseJobCursor(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		after = cursor
+	}
+
+	var pending []*TileJob
+	if s.db != nil {
+		var err error
+		pending, err = s.db.GetPendingJobs(r.Context(), limit, after)
+		if err != nil {
+			slog.Error("failed to list pending jobs", "error", err)
+			http.Error(w, "Failed to list jobs", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		pending = s.pendingJobsInMemory(limit, after)
+	}
+
+	s.jobsMutex.RLock()
+	jobs := make([]JobStatusResponse, 0, len(pending))
+	for _, job := range pending {
+		status := &JobStatus{Job: job, UpdatedAt: job.UpdatedAt}
+		if active, exists := s.activeJobs[job.ID]; exists {
+			status = active
+		}
+		jobs = append(jobs, newJobStatusResponse(status))
+	}
+	s.jobsMutex.RUnlock()
+
+	if len(pending) == limit {
+		w.Header().Set("X-Next-Cursor", CursorAfter(pending[len(pending)-1]).String())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// pendingJobsInMemory pages through this server's pending jobs in the same
+// order as GetPendingJobs
+func (s *APIServer) pendingJobsInMemory(limit int, after *JobCursor) []*TileJob {
+	s.jobsMutex.RLock()
+	var pending []*TileJob
+	for _, status := range s.activeJobs {
+		if status.Job.Status == "pending" && (after == nil || after.Before(status.Job)) {
+			pending = append(pending, status.Job)
+		}
+	}
+	s.jobsMutex.RUnlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return CursorAfter(pending[i]).Before(pending[j])
+	})
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending
+}
+
+// newJobStatusResponse builds the API representation of a job
+func newJobStatusResponse(status *JobStatus) JobStatusResponse {
+	return JobStatusResponse{
+		JobID:                 status.Job.ID,
+		Region:                status.Job.Region,
+		Status:                status.Job.Status,
+		CurrentStep:           status.Job.CurrentStep,
+		RoadsExtracted:        status.Job.RoadsExtracted,
+		TilesGenerated:        status.Job.TilesGenerated,
+		TotalSizeBytes:        status.Job.TotalSizeBytes,
+		TilesDir:              status.TilesDir,
+		TilesURL:              status.TilesURL,
+		UploadProgress:        status.Job.UploadProgress,
+		ErrorMessage:          status.Job.ErrorMessage,
+		UpdatedAt:             status.UpdatedAt.Format(time.RFC3339),
+		MaxZoom:               status.Job.MaxZoom,
+		MinZoom:               status.Job.MinZoom,
+		SkipUpload:            status.Job.SkipUpload,
+		SkipGeneration:        status.Job.SkipGeneration,
+		ExtractGeometry:       status.Job.ExtractGeometry,
+		SkipGeometryInsertion: status.Job.SkipGeometryInsertion,
+		MergeAll:              status.Job.MergeAll,
+		Result:                status.Result,
+	}
+}
+
 // handleJobStream handles GET /api/stream/{jobId} for Server-Sent Events
 func (s *APIServer) handleJobStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -305,8 +682,21 @@ func (s *APIServer) handleJobStream(w http.ResponseWriter, r *http.Request) {
 	_, exists := s.activeJobs[jobID]
 	s.jobsMutex.RUnlock()
 
+	// Jobs this process isn't running get a one-off snapshot from the database
 	if !exists {
-		http.Error(w, "Job not found", http.StatusNotFound)
+		if s.db == nil {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		job, err := s.getJobFromDB(r.Context(), jobID)
+		if err != nil {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeSSEUpdate(w, jobSnapshotUpdate(&JobStatus{Job: job, UpdatedAt: job.UpdatedAt}))
 		return
 	}
 
@@ -319,10 +709,17 @@ func (s *APIServer) handleJobStream(w http.ResponseWriter, r *http.Request) {
 	// Create channel for updates
 	updateChan := make(chan JobStatusUpdate, 10)
 
-	// Subscribe to job updates
+	// Subscribe to job updates, collecting any updates a reconnecting client
+	// missed. Both happen under the same lock so nothing falls in between.
+	lastEventID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
 	s.subsMutex.Lock()
-	if s.subscribers[jobID] == nil {
-		s.subscribers[jobID] = []chan JobStatusUpdate{}
+	var missed []JobStatusUpdate
+	if lastEventID > 0 {
+		for _, update := range s.history[jobID] {
+			if update.ID > lastEventID {
+				missed = append(missed, update)
+			}
+		}
 	}
 	s.subscribers[jobID] = append(s.subscribers[jobID], updateChan)
 	s.subsMutex.Unlock()
@@ -338,6 +735,7 @@ func (s *APIServer) handleJobStream(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		close(updateChan)
+		s.pruneHistory(jobID)
 		s.subsMutex.Unlock()
 	}()
 
@@ -348,142 +746,341 @@ func (s *APIServer) handleJobStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send initial status
-	s.jobsMutex.RLock()
-	status := s.activeJobs[jobID]
-	s.jobsMutex.RUnlock()
+	// Replay missed updates, or send the full current status to new clients
+	if len(missed) > 0 {
+		for _, update := range missed {
+			writeSSEUpdate(w, update)
+		}
+		flusher.Flush()
 
-	if status != nil {
-		update := JobStatusUpdate{
-			JobID:     jobID,
-			Status:    status.Job.Status,
-			Message:   "Connected to job stream",
-			UpdatedAt: time.Now(),
+		if isTerminalJobStatus(missed[len(missed)-1].Status) {
+			return
 		}
-		data, _ := json.Marshal(update)
-		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		// Snapshot after subscribing so no update falls between the two
+		s.jobsMutex.RLock()
+		snapshot := jobSnapshotUpdate(s.activeJobs[jobID])
+		s.jobsMutex.RUnlock()
+
+		writeSSEUpdate(w, snapshot)
 		flusher.Flush()
+
+		if isTerminalJobStatus(snapshot.Status) {
+			return
+		}
 	}
 
+	keepalive := time.NewTicker(s.sseKeepalive)
+	defer keepalive.Stop()
+
 	// Stream updates until job completes or client disconnects
 	for {
 		select {
 		case update := <-updateChan:
-			data, err := json.Marshal(update)
-			if err != nil {
-				continue
-			}
-			fmt.Fprintf(w, "data: %s\n\n", data)
+			writeSSEUpdate(w, update)
 			flusher.Flush()
 
 			// Close stream when job completes
-			if update.Status == "completed" || update.Status == "failed" {
+			if isTerminalJobStatus(update.Status) {
 				return
 			}
 		case <-r.Context().Done():
 			return
-		case <-time.After(30 * time.Second):
-			// Send keepalive
-			fmt.Fprintf(w, ": keepalive\n\n")
+		case <-s.shutdown:
+			// Let the server shut down instead of waiting for the job
+			return
+		case now := <-keepalive.C:
+			// Named ping event; some clients and proxies ignore comment lines
+			fmt.Fprintf(w, "event: ping\ndata: %s\n\n", now.UTC().Format(time.RFC3339))
 			flusher.Flush()
 		}
 	}
 }
 
-// handleGetRegions handles GET /api/regions
+// jobSnapshotUpdate builds the full-state update sent when a client connects.
+// Callers must hold jobsMutex for active jobs.
+func jobSnapshotUpdate(status *JobStatus) JobStatusUpdate {
+	update := JobStatusUpdate{
+		JobID:          status.Job.ID,
+		Status:         status.Job.Status,
+		Progress:       status.Job.UploadProgress,
+		Message:        "Connected to job stream",
+		UpdatedAt:      status.UpdatedAt,
+		CurrentStep:    status.Job.CurrentStep,
+		RoadsExtracted: status.Job.RoadsExtracted,
+		TilesGenerated: status.Job.TilesGenerated,
+	}
+	if status.Job.ErrorMessage != nil {
+		update.Error = *status.Job.ErrorMessage
+	} else if status.Error != nil {
+		update.Error = status.Error.Error()
+	}
+	return update
+}
+
+// writeSSEUpdate writes update as an SSE message, with its ID when it has one
+func writeSSEUpdate(w http.ResponseWriter, update JobStatusUpdate) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+	if update.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", update.ID)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+// isTerminalJobStatus reports whether a job in status will get no more updates
+func isTerminalJobStatus(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// handleGetRegions handles GET /api/regions, listing the regions with a KMZ
+// file in the curvature data directory
 func (s *APIServer) handleGetRegions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Hardcoded list of available regions from curvature-data
-	regions := []string{
-		"test-region", // For testing with sample tiles
-		"alabama", "alaska", "arizona", "arkansas", "california", "colorado",
-		"connecticut", "delaware", "district of columbia", "florida", "georgia",
-		"hawaii", "idaho", "illinois", "indiana", "iowa", "kansas", "kentucky",
-		"louisiana", "maine", "maryland", "massachusetts", "michigan", "minnesota",
-		"mississippi", "missouri", "montana", "nebraska", "nevada", "new hampshire",
-		"new jersey", "new mexico", "new york", "north carolina", "north dakota",
-		"ohio", "oklahoma", "oregon", "pennsylvania", "rhode island", "south carolina",
-		"south dakota", "tennessee", "texas", "utah", "vermont", "virginia",
-		"washington", "west virginia", "wisconsin", "wyoming",
-		"alberta", "british columbia", "manitoba", "new brunswick", "newfoundland and labrador",
-		"northwest territories", "nova scotia", "nunavut", "ontario", "prince edward island",
-		"quebec", "saskatchewan", "yukon",
-		"andorra", "austria", "azerbaijan", "belgium", "bosnia herzegovina", "bulgaria",
-		"croatia", "czech", "denmark", "estonia", "finland", "france", "germany",
-		"greece", "hungary", "iceland", "ireland", "isle of man", "italy", "latvia",
-		"liechtenstein", "lithuania", "luxembourg", "macedonia", "moldova", "monaco",
-		"montenegro", "netherlands", "norway", "poland", "portugal", "romania",
-		"serbia", "slovakia", "slovenia", "spain", "sweden", "switzerland",
-		"turkey", "ukraine", "united kingdom",
-		"argentina", "bolivia", "brazil", "chile", "colombia", "ecuador",
-		"guyana", "paraguay", "peru", "suriname", "uruguay", "venezuela",
-		"japan",
-		"mexico",
+	// Regions are whatever has a KMZ file in the curvature data directory; a
+	// missing directory just means nothing can be generated yet
+	regions, err := ListKMZRegions(s.config.Paths.CurvatureData)
+	if errors.Is(err, fs.ErrNotExist) {
+		regions = []KMZRegion{}
+	} else if err != nil {
+		slog.Error("failed to list regions", "dir", s.config.Paths.CurvatureData, "error", err)
+		http.Error(w, "Failed to list regions", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(regions)
 }
 
-// handleCancelJob handles POST /api/cancel/{jobId}
+// Radius limits for GET /api/roads/nearby, in meters
+const (
+	defaultNearbyRadius = 10000.0
+	maxNearbyRadius     = 100000.0
+)
+
+// Limits on the number of roads GET /api/roads/nearby returns
+const (
+	defaultNearbyLimit = 100
+	maxNearbyLimit     = 1000
+)
+
+// handleNearbyRoads handles GET /api/roads/nearby?lat=&lng=&radius=&limit=,
+// listing up to limit roads within radius meters of the point, nearest first
+func (s *APIServer) handleNearbyRoads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lat, lng, radius, limit, err := parseNearbyQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if s.db == nil {
+		http.Error(w, "Database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	roads, err := s.db.FindNearbyRoads(r.Context(), lat, lng, radius, limit)
+	if err != nil {
+		slog.Error("failed to find nearby roads", "error", err, "lat", lat, "lng", lng, "radius", radius)
+		http.Error(w, "Failed to find nearby roads", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roads)
+}
+
+// parseNearbyQuery validates the lat, lng, radius and limit query
+// parameters. radius defaults to defaultNearbyRadius and limit to
+// defaultNearbyLimit.
+func parseNearbyQuery(r *http.Request) (lat, lng, radius float64, limit int, err error) {
+	query := r.URL.Query()
+
+	lat, err = parseCoordinate(query.Get("lat"), "lat", 90)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	lng, err = parseCoordinate(query.Get("lng"), "lng", 180)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	radius = defaultNearbyRadius
+	if value := query.Get("radius"); value != "" {
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(radius) || radius <= 0 || radius > maxNearbyRadius {
+			return 0, 0, 0, 0, fmt.Errorf("radius must be a number of meters between 0 and %.0f", maxNearbyRadius)
+		}
+	}
+
+	limit = defaultNearbyLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxNearbyLimit {
+			return 0, 0, 0, 0, fmt.Errorf("limit must be a whole number between 1 and %d", maxNearbyLimit)
+		}
+	}
+	return lat, lng, radius, limit, nil
+}
+
+// parseCoordinate parses a required lat or lng parameter within ±limit degrees
+func parseCoordinate(value, name string, limit float64) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || v < -limit || v > limit {
+		return 0, fmt.Errorf("%s must be a number between -%.0f and %.0f", name, limit, limit)
+	}
+	return v, nil
+}
+
+// handleCancelJob handles POST /api/cancel/{jobId}, kept for older clients
+// of DELETE /api/jobs/{jobId}
 func (s *APIServer) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	s.cancelJobRequest(w, r.URL.Path[len("/api/cancel/"):])
+}
 
-	// Extract job ID from path
-	jobID := r.URL.Path[len("/api/cancel/"):]
+// Errors returned by cancelJob
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job already finished")
+)
+
+// cancelJobRequest cancels jobID and writes the response: 404 for an unknown
+// job, 409 for one that already completed or failed
+func (s *APIServer) cancelJobRequest(w http.ResponseWriter, jobID string) {
 	if jobID == "" {
 		http.Error(w, "Job ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Get job status
+	err := s.cancelJob(jobID, "Job was cancelled by user")
+	switch {
+	case errors.Is(err, errJobNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errJobFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Job cancelled successfully",
+		"jobId":   jobID,
+	})
+}
+
+// cancelJob stops a queued or running job and marks it cancelled, recording
+// reason as its error message. A running job's context is cancelled; a queued
+// one is skipped when it is dequeued. Stream subscribers get a final
+// "cancelled" update.
+func (s *APIServer) cancelJob(jobID, reason string) error {
 	s.jobsMutex.Lock()
 	status, exists := s.activeJobs[jobID]
 	if !exists {
 		s.jobsMutex.Unlock()
-		http.Error(w, "Job not found or already completed", http.StatusNotFound)
-		return
+		return errJobNotFound
+	}
+	if current := status.Job.Status; isTerminalJobStatus(current) {
+		s.jobsMutex.Unlock()
+		return fmt.Errorf("%w: %s", errJobFinished, current)
 	}
 
-	// Call cancel function if it exists
 	if status.CancelFunc != nil {
 		status.CancelFunc()
-		slog.Info("job cancelled", "job_id", jobID, "region", status.Job.Region)
 	}
+	status.Job.Status = "cancelled"
+	region := status.Job.Region
 	s.jobsMutex.Unlock()
 
+	slog.Info("job cancelled", "job_id", jobID, "region", region)
+
 	// Update job status in database
 	if s.db != nil {
 		ctx := context.Background()
-		errMsg := "Job was cancelled by user"
 		query := `
 			UPDATE "TileJob"
-			SET status = 'failed',
+			SET status = 'cancelled',
 			    "errorMessage" = $1,
 			    "updatedAt" = $2
 			WHERE id = $3
 		`
-		_, err := s.db.conn.ExecContext(ctx, query, errMsg, time.Now(), jobID)
+		_, err := s.db.conn.ExecContext(ctx, query, reason, time.Now(), jobID)
 		if err != nil {
 			slog.Error("failed to update cancelled job in database", "error", err, "job_id", jobID)
 		}
 	}
 
-	// Send cancellation update to subscribers
-	s.updateJobStatus(jobID, "failed", "Job was cancelled by user")
+	// Send cancellation update to subscribers, which ends their streams
+	s.updateJobStatus(jobID, "cancelled", reason, 0)
+	return nil
+}
+
+// handleStats handles GET /api/stats. Job and road aggregates come from the
+// database (or the in-memory jobs when there is none); responses are cached
+// for statsCacheTTL.
+func (s *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+
+	if s.statsCache == nil || time.Since(s.statsCache.GeneratedAt) > statsCacheTTL {
+		resp, err := s.buildStats(r.Context())
+		if err != nil {
+			slog.Error("failed to build stats", "error", err)
+			http.Error(w, "Failed to compute stats", http.StatusInternalServerError)
+			return
+		}
+		s.statsCache = resp
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Job cancelled successfully",
-		"jobId":   jobID,
-	})
+	json.NewEncoder(w).Encode(s.statsCache)
+}
+
+// buildStats combines the aggregate job statistics with tallies of the jobs
+// this server holds in memory
+func (s *APIServer) buildStats(ctx context.Context) (*StatsResponse, error) {
+	s.jobsMutex.RLock()
+	jobs := make([]*TileJob, 0, len(s.activeJobs))
+	active := make(map[string]int)
+	for _, status := range s.activeJobs {
+		jobs = append(jobs, status.Job)
+		active[status.Job.Status]++
+	}
+	s.jobsMutex.RUnlock()
+
+	resp := &StatsResponse{ActiveJobs: active, GeneratedAt: time.Now()}
+	if s.statsFetcher != nil {
+		stats, err := s.statsFetcher(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp.JobStats = *stats
+	} else {
+		resp.JobStats = aggregateJobStats(jobs)
+	}
+	return resp, nil
 }
 
 // handleHealth handles GET /health
@@ -495,34 +1092,99 @@ func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// processJobs processes jobs from the queue
+// processJobs processes jobs from the queue until the server stops
 func (s *APIServer) processJobs() {
-	for job := range s.jobQueue {
-		s.processJob(job)
+	defer s.processing.Done()
+	if s.db != nil {
+		s.processClaimedJobs()
+		return
+	}
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case job := <-s.jobQueue:
+			s.processJob(job)
+		}
 	}
 }
 
+// processClaimedJobs claims pending jobs from the database one at a time
+// until the server stops. Claiming with FOR UPDATE SKIP LOCKED lets several
+// servers share the job table, and picks up jobs the reaper re-queued.
+func (s *APIServer) processClaimedJobs() {
+	for !s.isStopping() {
+		job, err := s.db.ClaimPendingJob(context.Background(), s.workerID)
+		if err != nil {
+			slog.Error("failed to claim pending job", "error", err)
+		}
+		if job != nil {
+			s.processJob(s.trackClaimedJob(job))
+			continue
+		}
+
+		select {
+		case <-s.shutdown:
+			return
+		case <-s.jobWake:
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// trackClaimedJob returns the in-memory job for a claimed row, adding it to
+// the active jobs when it was created by another server or re-queued after
+// a restart, so it can be polled, streamed and cancelled
+func (s *APIServer) trackClaimedJob(job *TileJob) *TileJob {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if status, exists := s.activeJobs[job.ID]; exists {
+		status.Job.StartedAt = job.StartedAt
+		return status.Job
+	}
+	s.activeJobs[job.ID] = &JobStatus{
+		Job:       job,
+		Progress:  &JobProgress{},
+		UpdatedAt: time.Now(),
+	}
+	return job
+}
+
 // processJob processes a single job
 func (s *APIServer) processJob(job *TileJob) {
 	// Create cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Ensure cleanup
 
-	// Store cancel function in job status
+	// Store cancel function in job status, unless the job was cancelled
+	// while it waited in the queue or the server is stopping
 	s.jobsMutex.Lock()
+	if s.stopping {
+		s.jobsMutex.Unlock()
+		slog.Info("not starting job, server is stopping", "job_id", job.ID)
+		return
+	}
 	if status, exists := s.activeJobs[job.ID]; exists {
+		if status.Job.Status == "cancelled" {
+			s.jobsMutex.Unlock()
+			slog.Info("skipping job cancelled while queued", "job_id", job.ID)
+			return
+		}
 		status.CancelFunc = cancel
 	}
+	job.Status = "processing"
 	s.jobsMutex.Unlock()
 
 	slog.Info("processing job", "job_id", job.ID, "region", job.Region)
 
-	// Update status to processing
-	job.Status = "processing"
-	s.updateJobStatus(job.ID, "processing", "Starting tile generation")
+	// Keep the job's lease alive so the reaper leaves it alone
+	if interval := s.config.Service.HeartbeatInterval; s.db != nil && interval > 0 {
+		stopHeartbeat := startHeartbeat(ctx, s.db, job.ID, time.Duration(interval)*time.Second)
+		defer stopHeartbeat()
+	}
 
-	// Create service
-	service := NewTileService(s.db, s.s3Client, s.config)
+	// Update status to processing
+	s.updateJobStatus(job.ID, "processing", "Starting tile generation", 0)
 
 	// Create job options from TileJob fields
 	opts := &JobOptions{
@@ -536,17 +1198,24 @@ func (s *APIServer) processJob(job *TileJob) {
 		MergeAll:              job.MergeAll, // Default false = merge only overlapping neighbors
 	}
 
-	err := service.ProcessJobWithOptions(ctx, job, opts)
+	result, err := s.runJob(ctx, job, opts)
 
-	if err != nil {
+	if ctx.Err() != nil {
+		// cancelJob already recorded the cancellation and notified subscribers
+		job.Status = "cancelled"
+		slog.Warn("job stopped after cancellation", "job_id", job.ID, "error", err)
+	} else if err != nil {
 		job.Status = "failed"
 		errMsg := err.Error()
 		job.ErrorMessage = &errMsg
-		s.updateJobStatus(job.ID, "failed", fmt.Sprintf("Job failed: %v", err))
+		s.updateJobStatus(job.ID, "failed", fmt.Sprintf("Job failed: %v", err), 0)
 		slog.Error("job failed", "job_id", job.ID, "error", err)
 	} else {
+		// Recorded before "completed" is announced, so a client reacting to
+		// it finds the tiles
+		s.setJobOutput(job, result)
 		job.Status = "completed"
-		s.updateJobStatus(job.ID, "completed", "Job completed successfully")
+		s.updateJobStatus(job.ID, "completed", "Job completed successfully", 100)
 		slog.Info("job completed", "job_id", job.ID)
 	}
 
@@ -558,29 +1227,128 @@ func (s *APIServer) processJob(job *TileJob) {
 		if err != nil {
 			status.Error = err
 		}
+		status.Result = result
 	}
 	s.jobsMutex.Unlock()
 }
 
-// updateJobStatus updates job status and notifies subscribers
-func (s *APIServer) updateJobStatus(jobID, status, message string) {
+// runPipeline runs a job's pipeline with a TileService, forwarding its step
+// progress and phase changes to the job's status
+func (s *APIServer) runPipeline(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error) {
+	service := NewTileService(s.db, s.s3Client, s.config)
+	service.StepProgress = func(step string, percent int) {
+		s.updateJobStatus(job.ID, step, fmt.Sprintf("%s: %d%%", step, percent), percent)
+	}
+	service.StepChange = func(step string) {
+		s.setJobStep(job.ID, step)
+	}
+	return service.ProcessJobWithOptions(ctx, job, opts)
+}
+
+// setJobOutput records what a completed job produced: the pipeline's
+// result, the absolute tiles directory, its tile count and size, and the
+// public tile URL template when the tiles were uploaded
+func (s *APIServer) setJobOutput(job *TileJob, result *JobResult) {
+	dir := filepath.Join(s.config.Paths.OutputDir, job.Region)
+	if result != nil && result.TilesDir != "" {
+		dir = result.TilesDir
+	}
+	tilesDir, err := filepath.Abs(dir)
+	if err != nil {
+		slog.Warn("failed to resolve tiles directory", "job_id", job.ID, "error", err)
+		return
+	}
+	var tilesURL string
+	if !job.SkipUpload && s.s3Client != nil {
+		tilesURL = s.s3Client.GetPublicURL(path.Join(s.config.S3.BucketPath, "{z}/{x}/{y}.pbf"))
+	}
+
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if result != nil {
+		tilesCount, totalSize := result.TilesCount, result.TotalSizeBytes
+		job.TilesGenerated, job.TotalSizeBytes = &tilesCount, &totalSize
+	}
+	if active, exists := s.activeJobs[job.ID]; exists {
+		active.TilesDir, active.TilesURL = tilesDir, tilesURL
+		active.Result = result
+	}
+}
+
+// setJobStep records an active job's current pipeline phase, so polling the
+// job reflects it without the SSE stream
+func (s *APIServer) setJobStep(jobID, step string) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if active, exists := s.activeJobs[jobID]; exists {
+		active.Job.CurrentStep = &step
+		active.UpdatedAt = time.Now()
+	}
+}
+
+// updateJobStatus updates job status and notifies subscribers. progress is
+// the percentage through the current status (0-100).
+func (s *APIServer) updateJobStatus(jobID, status, message string, progress int) {
 	update := JobStatusUpdate{
 		JobID:     jobID,
 		Status:    status,
+		Progress:  progress,
 		Message:   message,
 		UpdatedAt: time.Now(),
 	}
 
-	// Notify subscribers
-	s.subsMutex.RLock()
-	subscribers := s.subscribers[jobID]
-	s.subsMutex.RUnlock()
+	s.jobsMutex.Lock()
+	if active, exists := s.activeJobs[jobID]; exists {
+		active.UpdatedAt = update.UpdatedAt
+	}
+	s.jobsMutex.Unlock()
+
+	// Record and notify subscribers. Sending under the lock keeps a
+	// disconnecting subscriber from closing its channel mid-send.
+	s.subsMutex.Lock()
+	defer s.subsMutex.Unlock()
+
+	s.eventSeq++
+	update.ID = s.eventSeq
+	history := append(s.history[jobID], update)
+	if len(history) > maxSSEHistory {
+		history = history[len(history)-maxSSEHistory:]
+	}
+	s.history[jobID] = history
+
+	for _, ch := range s.subscribers[jobID] {
+		sendLatest(ch, update)
+	}
+	s.pruneHistory(jobID)
+}
+
+// pruneHistory drops a finished job's update history once no stream is left
+// to resume; a client reconnecting later gets the final snapshot instead.
+// Callers must hold subsMutex.
+func (s *APIServer) pruneHistory(jobID string) {
+	if len(s.subscribers[jobID]) > 0 {
+		return
+	}
+	delete(s.subscribers, jobID)
+	if history := s.history[jobID]; len(history) > 0 && isTerminalJobStatus(history[len(history)-1].Status) {
+		delete(s.history, jobID)
+	}
+}
 
-	for _, ch := range subscribers {
+// sendLatest delivers update without blocking. When a slow subscriber's
+// channel is full the oldest pending update is dropped, so the subscriber
+// always ends up with the most recent state rather than a stale one.
+func sendLatest(ch chan JobStatusUpdate, update JobStatusUpdate) {
+	for {
 		select {
 		case ch <- update:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
 		default:
-			// Channel full, skip
 		}
 	}
 }
@@ -631,3 +1399,40 @@ func (s *APIServer) getJobFromDB(ctx context.Context, jobID string) (*TileJob, e
 	}
 	return job, nil
 }
+
+// maxJobsSince caps how many database jobs one ?since= poll returns
+const maxJobsSince = 1000
+
+// jobsUpdatedSinceQuery builds the query for up to limit jobs updated at or
+// after since, oldest update first
+func jobsUpdatedSinceQuery(since time.Time, limit int) (string, []interface{}) {
+	return `
+		SELECT ` + jobColumns + `
+		FROM "TileJob"
+		WHERE "updatedAt" >= $1
+		ORDER BY "updatedAt", id
+		LIMIT $2
+	`, []interface{}{since, limit}
+}
+
+// getJobsUpdatedSinceFromDB retrieves up to limit jobs updated at or after
+// since
+func (s *APIServer) getJobsUpdatedSinceFromDB(ctx context.Context, since time.Time, limit int) ([]*TileJob, error) {
+	query, args := jobsUpdatedSinceQuery(since, limit)
+
+	rows, err := s.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*TileJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,1119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestAPIServer builds an API server with no database or S3 client and
+// the given jobs already active
+func newTestAPIServer(jobs ...*JobStatus) *APIServer {
+	s := NewAPIServer(nil, nil, &Config{})
+	for _, status := range jobs {
+		s.activeJobs[status.Job.ID] = status
+	}
+	return s
+}
+
+func TestHandleListJobs_Since(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestAPIServer(
+		&JobStatus{Job: &TileJob{ID: "old", Status: "completed"}, UpdatedAt: cutoff.Add(-time.Minute)},
+		&JobStatus{Job: &TileJob{ID: "at", Status: "generating"}, UpdatedAt: cutoff},
+		&JobStatus{Job: &TileJob{ID: "new", Status: "uploading"}, UpdatedAt: cutoff.Add(time.Minute)},
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"no since returns all", "", []string{"at", "new", "old"}},
+		{"since cutoff", "?since=" + cutoff.Format(time.RFC3339), []string{"at", "new"}},
+		{"since after all", "?since=" + cutoff.Add(time.Hour).Format(time.RFC3339), []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			rec := httptest.NewRecorder()
+			s.handleListJobs(rec, httptest.NewRequest(http.MethodGet, "/api/jobs"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rec.Code)
+			}
+
+			var jobs []JobStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&jobs); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, job := range jobs {
+				got = append(got, job.JobID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			serverTime, err := time.Parse(time.RFC3339, rec.Header().Get("X-Server-Time"))
+			if err != nil {
+				t.Fatalf("expected RFC3339 X-Server-Time header: %v", err)
+			}
+			if serverTime.Before(before.Add(-time.Second)) {
+				t.Errorf("server time %v is older than the request", serverTime)
+			}
+		})
+	}
+}
+
+func TestHandleListJobs_InvalidSince(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestAPIServer().handleListJobs(rec, httptest.NewRequest(http.MethodGet, "/api/jobs?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestJobsUpdatedSinceQuery(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	query, args := jobsUpdatedSinceQuery(since, 50)
+	if !strings.Contains(query, `WHERE "updatedAt" >= $1`) {
+		t.Errorf("expected updatedAt filter, got %s", query)
+	}
+	if !strings.Contains(query, "LIMIT $2") {
+		t.Errorf("expected a LIMIT, got %s", query)
+	}
+	if len(args) != 2 || args[0] != since || args[1] != 50 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestGetJobsUpdatedSinceFromDB(t *testing.T) {
+	db := newJobsTestDB(t)
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	insertTestJob(t, db, "old", "completed", since.Add(-time.Hour))
+	insertTestJob(t, db, "new", "pending", since.Add(time.Hour))
+	insertTestJob(t, db, "at", "pending", since)
+	if _, err := db.conn.Exec(`UPDATE "TileJob" SET "mergeAll" = 1 WHERE id = 'new'`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewAPIServer(db, nil, &Config{})
+	jobs, err := s.getJobsUpdatedSinceFromDB(context.Background(), since, maxJobsSince)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "at" || jobs[1].ID != "new" {
+		t.Fatalf("expected jobs at and new in update order, got %+v", jobs)
+	}
+	if !jobs[1].MergeAll || !jobs[1].UpdatedAt.Equal(since.Add(time.Hour)) {
+		t.Errorf("expected the job's columns to be scanned, got %+v", jobs[1])
+	}
+
+	jobs, err = s.getJobsUpdatedSinceFromDB(context.Background(), since, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "at" {
+		t.Errorf("expected only the oldest update, got %+v", jobs)
+	}
+}
+
+// recordJobRuns stands in for s's pipeline, sending each job it runs on the
+// returned channel
+func recordJobRuns(s *APIServer) chan *TileJob {
+	ran := make(chan *TileJob, 10)
+	s.runJob = func(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error) {
+		ran <- job
+		return &JobResult{Region: job.Region}, nil
+	}
+	return ran
+}
+
+// waitForJobRun returns the next job run on ran, failing after a timeout
+func waitForJobRun(t *testing.T, ran chan *TileJob) *TileJob {
+	t.Helper()
+	select {
+	case job := <-ran:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a job to run")
+		return nil
+	}
+}
+
+func TestHandleGenerate_JobIsClaimedFromDatabase(t *testing.T) {
+	db := newJobsTestDB(t)
+	s := NewAPIServer(db, nil, &Config{})
+	ran := recordJobRuns(s)
+	s.processing.Add(1)
+	go s.processJobs()
+	defer s.Stop(context.Background())
+
+	rec := httptest.NewRecorder()
+	s.handleGenerate(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(`{"region":"oregon"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp GenerateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	job := waitForJobRun(t, ran)
+	if job.ID != resp.JobID || job.Region != "oregon" {
+		t.Errorf("expected job %s for oregon to run, got %+v", resp.JobID, job)
+	}
+	if len(s.jobQueue) != 0 {
+		t.Errorf("expected the database to be the queue, got %d in memory", len(s.jobQueue))
+	}
+	var claimedBy string
+	if err := db.conn.QueryRow(`SELECT "claimedBy" FROM "TileJob" WHERE id = ?`, resp.JobID).Scan(&claimedBy); err != nil {
+		t.Fatal(err)
+	}
+	if claimedBy != s.workerID {
+		t.Errorf("expected the job claimed by %s, got %q", s.workerID, claimedBy)
+	}
+}
+
+// listPendingJobIDs pages through GET /api/jobs?status=pending, returning
+// the job IDs of each page
+func listPendingJobIDs(t *testing.T, s *APIServer, limit int) [][]string {
+	t.Helper()
+	var pages [][]string
+	after := ""
+	for {
+		target := fmt.Sprintf("/api/jobs?status=pending&limit=%d", limit)
+		if after != "" {
+			target += "&after=" + url.QueryEscape(after)
+		}
+		rec := httptest.NewRecorder()
+		s.handleListJobs(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var jobs []JobStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&jobs); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.JobID)
+		}
+		pages = append(pages, ids)
+		if after = rec.Header().Get("X-Next-Cursor"); after == "" {
+			return pages
+		}
+		if len(pages) > 10 {
+			t.Fatal("expected paging to end")
+		}
+	}
+}
+
+func TestHandleListJobs_PendingFromDatabase(t *testing.T) {
+	db := newJobsTestDB(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"job-a", "job-c", "job-b", "job-d", "job-e"} {
+		// job-b and job-c share a createdAt and page by id
+		created := base.Add(time.Duration(i) * time.Second)
+		if id == "job-b" {
+			created = base.Add(time.Second)
+		}
+		insertTestJob(t, db, id, "pending", created)
+	}
+	insertTestJob(t, db, "done", "completed", base)
+	s := NewAPIServer(db, nil, &Config{})
+
+	pages := listPendingJobIDs(t, s, 2)
+	if want := "[[job-a job-b] [job-c job-d] [job-e]]"; fmt.Sprint(pages) != want {
+		t.Errorf("expected pages %s, got %v", want, pages)
+	}
+}
+
+func TestHandleListJobs_PendingInMemory(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestAPIServer(
+		&JobStatus{Job: &TileJob{ID: "second", Status: "pending", CreatedAt: base.Add(time.Second)}},
+		&JobStatus{Job: &TileJob{ID: "first", Status: "pending", CreatedAt: base}},
+		&JobStatus{Job: &TileJob{ID: "third", Status: "pending", CreatedAt: base.Add(2 * time.Second)}},
+		&JobStatus{Job: &TileJob{ID: "running", Status: "generating", CreatedAt: base}},
+	)
+
+	pages := listPendingJobIDs(t, s, 2)
+	if want := "[[first second] [third]]"; fmt.Sprint(pages) != want {
+		t.Errorf("expected pages %s, got %v", want, pages)
+	}
+}
+
+func TestHandleListJobs_PendingBadQuery(t *testing.T) {
+	s := newTestAPIServer()
+	for _, query := range []string{"status=failed", "status=pending&limit=0", "status=pending&limit=1001", "status=pending&after=job-1"} {
+		rec := httptest.NewRecorder()
+		s.handleListJobs(rec, httptest.NewRequest(http.MethodGet, "/api/jobs?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestSetJobStep(t *testing.T) {
+	job := &TileJob{ID: "job-1", Region: "oregon", Status: "processing"}
+	s := newTestAPIServer(&JobStatus{Job: job})
+
+	s.setJobStep("job-1", "converting")
+	s.setJobStep("unknown", "generating")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/job-1", nil)
+	rec := httptest.NewRecorder()
+	s.handleJobStatus(rec, req)
+
+	var resp JobStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CurrentStep == nil || *resp.CurrentStep != "converting" {
+		t.Errorf("expected current step converting, got %v", resp.CurrentStep)
+	}
+}
+
+func TestSetJobOutput(t *testing.T) {
+	outputDir := t.TempDir()
+	result := &JobResult{
+		Region:         "oregon",
+		TilesDir:       filepath.Join(outputDir, "oregon"),
+		TilesCount:     2,
+		TotalSizeBytes: 1234,
+		Extraction:     &ExtractionSummary{Region: "oregon", TilesProcessed: 2, RoadsExtracted: 7},
+	}
+
+	tests := []struct {
+		name       string
+		skipUpload bool
+		result     *JobResult
+		wantURL    string
+	}{
+		{"uploaded", false, result, "https://tiles.drivefinder.com/{z}/{x}/{y}.pbf"},
+		{"skip upload", true, result, ""},
+		{"no result", false, nil, "https://tiles.drivefinder.com/{z}/{x}/{y}.pbf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &TileJob{ID: "job-1", Region: "oregon", Status: "processing", SkipUpload: tt.skipUpload}
+			s := newTestAPIServer(&JobStatus{Job: job})
+			s.config.Paths.OutputDir = outputDir
+			s.config.S3.BucketPath = "tiles"
+			s.s3Client = &S3Client{bucket: "bucket", bucketPath: "tiles"}
+
+			s.setJobOutput(job, tt.result)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/jobs/job-1", nil)
+			rec := httptest.NewRecorder()
+			s.handleJobStatus(rec, req)
+
+			var resp JobStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			wantDir := filepath.Join(outputDir, "oregon")
+			if resp.TilesDir != wantDir || !filepath.IsAbs(resp.TilesDir) {
+				t.Errorf("expected tiles dir %s, got %q", wantDir, resp.TilesDir)
+			}
+			if resp.TilesURL != tt.wantURL {
+				t.Errorf("expected tiles URL %q, got %q", tt.wantURL, resp.TilesURL)
+			}
+
+			if tt.result == nil {
+				if resp.Result != nil || resp.TilesGenerated != nil {
+					t.Errorf("expected no result or counts, got %+v and %v", resp.Result, resp.TilesGenerated)
+				}
+				return
+			}
+			if resp.TilesGenerated == nil || *resp.TilesGenerated != 2 {
+				t.Errorf("expected the result's 2 tiles, got %v", resp.TilesGenerated)
+			}
+			if resp.TotalSizeBytes == nil || *resp.TotalSizeBytes != 1234 {
+				t.Errorf("expected the result's total size, got %v", resp.TotalSizeBytes)
+			}
+			if resp.Result == nil || resp.Result.TilesCount != 2 || resp.Result.Extraction == nil || resp.Result.Extraction.RoadsExtracted != 7 {
+				t.Errorf("expected the result and its extraction summary, got %+v", resp.Result)
+			}
+		})
+	}
+}
+
+func TestHandleJobStatus_ETag(t *testing.T) {
+	job := &TileJob{ID: "job-1", Region: "oregon", Status: "generating"}
+	s := newTestAPIServer(&JobStatus{Job: job, UpdatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/jobs/job-1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		s.handleJobStatus(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+
+	unchanged := get(etag)
+	if unchanged.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for unchanged job, got %d", unchanged.Code)
+	}
+	if unchanged.Body.Len() != 0 {
+		t.Errorf("expected empty body on 304, got %q", unchanged.Body.String())
+	}
+
+	job.Status = "uploading"
+	s.updateJobStatus("job-1", "uploading", "Uploading tiles", 0)
+
+	changed := get(etag)
+	if changed.Code != http.StatusOK {
+		t.Fatalf("expected 200 after status change, got %d", changed.Code)
+	}
+	newETag := changed.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("expected a new ETag after status change, got %q", newETag)
+	}
+	var resp JobStatusResponse
+	if err := json.NewDecoder(changed.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "uploading" {
+		t.Errorf("expected updated status in body, got %q", resp.Status)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// readSSEEvents reads events from an SSE stream until n have arrived
+func readSSEEvents(t *testing.T, body *bufio.Reader, n int) []map[string]string {
+	t.Helper()
+	var events []map[string]string
+	event := map[string]string{}
+	for len(events) < n {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %d events: %v", len(events), err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			events = append(events, event)
+			event = map[string]string{}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		event[field] = value
+	}
+	return events
+}
+
+func TestHandleJobStream_PingCadence(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "generating"}})
+	s.sseKeepalive = 50 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(s.handleJobStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/stream/job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	events := readSSEEvents(t, bufio.NewReader(resp.Body), 4)
+	elapsed := time.Since(start)
+
+	if _, ok := events[0]["data"]; !ok || events[0]["event"] != "" {
+		t.Errorf("expected initial status message, got %v", events[0])
+	}
+	for _, event := range events[1:] {
+		if event["event"] != "ping" {
+			t.Errorf("expected ping event, got %v", event)
+		}
+		if _, err := time.Parse(time.RFC3339, event["data"]); err != nil {
+			t.Errorf("expected timestamp data on ping, got %q", event["data"])
+		}
+	}
+	if elapsed < 3*s.sseKeepalive-10*time.Millisecond || elapsed > 3*s.sseKeepalive+time.Second {
+		t.Errorf("3 pings took %v with a %v interval", elapsed, s.sseKeepalive)
+	}
+}
+
+func TestHandleJobStream_ResumeFromLastEventID(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "generating"}})
+	s.sseKeepalive = time.Hour
+	s.updateJobStatus("job-1", "extracting", "one", 0)
+	s.updateJobStatus("job-1", "generating", "two", 0)
+	s.updateJobStatus("job-1", "uploading", "three", 0)
+
+	server := httptest.NewServer(http.HandlerFunc(s.handleJobStream))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/stream/job-1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+
+	events := readSSEEvents(t, body, 2)
+	for i, want := range []struct{ id, message string }{{"2", "two"}, {"3", "three"}} {
+		if events[i]["id"] != want.id {
+			t.Errorf("event %d: expected id %s, got %v", i, want.id, events[i])
+		}
+		var update JobStatusUpdate
+		if err := json.Unmarshal([]byte(events[i]["data"]), &update); err != nil {
+			t.Fatal(err)
+		}
+		if update.Message != want.message {
+			t.Errorf("event %d: expected message %q, got %q", i, want.message, update.Message)
+		}
+	}
+
+	// Live updates continue after the replay, and a terminal one ends the stream
+	s.updateJobStatus("job-1", "completed", "done", 0)
+	events = readSSEEvents(t, body, 1)
+	if events[0]["id"] != "4" {
+		t.Errorf("expected live event id 4, got %v", events[0])
+	}
+	if _, err := body.ReadString('\n'); err == nil {
+		t.Error("expected stream to close after completed")
+	}
+
+	// The finished job's history goes once its last stream is gone
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.subsMutex.Lock()
+		_, kept := s.history["job-1"]
+		s.subsMutex.Unlock()
+		if !kept {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the history to be pruned after the stream closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUpdateJobStatus_PrunesFinishedHistory(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "generating"}})
+
+	s.updateJobStatus("job-1", "generating", "working", 10)
+	if len(s.history["job-1"]) != 1 {
+		t.Fatalf("expected a running job's history to be kept, got %+v", s.history["job-1"])
+	}
+	s.updateJobStatus("job-1", "completed", "done", 100)
+	if _, kept := s.history["job-1"]; kept {
+		t.Errorf("expected a finished job without streams to drop its history, got %+v", s.history["job-1"])
+	}
+	if _, kept := s.subscribers["job-1"]; kept {
+		t.Error("expected no subscriber entry to be left behind")
+	}
+}
+
+func TestUpdateJobStatus_CoalescesUnderBackpressure(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "uploading"}})
+
+	// A subscriber that isn't reading
+	ch := make(chan JobStatusUpdate, 10)
+	s.subscribers["job-1"] = []chan JobStatusUpdate{ch}
+
+	for i := 1; i <= 50; i++ {
+		s.updateJobStatus("job-1", "uploading", fmt.Sprintf("progress %d", i), 0)
+	}
+
+	if len(ch) != cap(ch) {
+		t.Fatalf("expected a full channel, got %d of %d", len(ch), cap(ch))
+	}
+
+	var last JobStatusUpdate
+	prevID := int64(0)
+	for len(ch) > 0 {
+		last = <-ch
+		if last.ID <= prevID {
+			t.Errorf("expected updates in order, got id %d after %d", last.ID, prevID)
+		}
+		prevID = last.ID
+	}
+	if last.Message != "progress 50" {
+		t.Errorf("expected latest update to be delivered, got %q", last.Message)
+	}
+}
+
+func TestHandleJobStream_InitialSnapshot(t *testing.T) {
+	step := "generating"
+	roads, tiles := 1200, 340
+	errMsg := "tippecanoe exited with status 1"
+
+	tests := []struct {
+		name       string
+		job        *TileJob
+		wantClosed bool
+	}{
+		{
+			name: "mid-job",
+			job: &TileJob{ID: "job-1", Status: "generating", CurrentStep: &step,
+				RoadsExtracted: &roads, TilesGenerated: &tiles, UploadProgress: 0},
+		},
+		{
+			name:       "completed",
+			job:        &TileJob{ID: "job-1", Status: "completed", RoadsExtracted: &roads, TilesGenerated: &tiles, UploadProgress: 100},
+			wantClosed: true,
+		},
+		{
+			name:       "failed",
+			job:        &TileJob{ID: "job-1", Status: "failed", ErrorMessage: &errMsg},
+			wantClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAPIServer(&JobStatus{Job: tt.job, UpdatedAt: time.Now()})
+			s.sseKeepalive = time.Hour
+			server := httptest.NewServer(http.HandlerFunc(s.handleJobStream))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/stream/job-1", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body := bufio.NewReader(resp.Body)
+
+			events := readSSEEvents(t, body, 1)
+			var update JobStatusUpdate
+			if err := json.Unmarshal([]byte(events[0]["data"]), &update); err != nil {
+				t.Fatal(err)
+			}
+			if update.Status != tt.job.Status || update.Progress != tt.job.UploadProgress {
+				t.Errorf("expected status %s progress %d, got %+v", tt.job.Status, tt.job.UploadProgress, update)
+			}
+			if tt.job.CurrentStep != nil && (update.CurrentStep == nil || *update.CurrentStep != step) {
+				t.Errorf("expected current step %q, got %v", step, update.CurrentStep)
+			}
+			if tt.job.RoadsExtracted != nil && (update.RoadsExtracted == nil || *update.RoadsExtracted != roads) {
+				t.Errorf("expected roads %d, got %v", roads, update.RoadsExtracted)
+			}
+			if tt.job.TilesGenerated != nil && (update.TilesGenerated == nil || *update.TilesGenerated != tiles) {
+				t.Errorf("expected tiles %d, got %v", tiles, update.TilesGenerated)
+			}
+			if tt.job.ErrorMessage != nil && update.Error != errMsg {
+				t.Errorf("expected error %q, got %q", errMsg, update.Error)
+			}
+
+			_, err = body.ReadString('\n')
+			if tt.wantClosed && err == nil {
+				t.Error("expected stream to close after terminal status")
+			}
+			if !tt.wantClosed {
+				if err == nil {
+					t.Error("expected no further events for an idle job")
+				} else if ctx.Err() == nil {
+					t.Errorf("expected stream to stay open, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelJob_Responses(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		status     string
+		wantCode   int
+		wantStatus string
+	}{
+		{"delete running", http.MethodDelete, "/api/jobs/job-1", "generating", http.StatusOK, "cancelled"},
+		{"post cancel queued", http.MethodPost, "/api/jobs/job-1/cancel", "pending", http.StatusOK, "cancelled"},
+		{"legacy cancel", http.MethodPost, "/api/cancel/job-1", "processing", http.StatusOK, "cancelled"},
+		{"completed", http.MethodDelete, "/api/jobs/job-1", "completed", http.StatusConflict, "completed"},
+		{"failed", http.MethodPost, "/api/jobs/job-1/cancel", "failed", http.StatusConflict, "failed"},
+		{"unknown", http.MethodDelete, "/api/jobs/nope", "generating", http.StatusNotFound, "generating"},
+		{"get on cancel", http.MethodGet, "/api/jobs/job-1/cancel", "generating", http.StatusMethodNotAllowed, "generating"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := false
+			status := &JobStatus{Job: &TileJob{ID: "job-1", Status: tt.status}, CancelFunc: func() { cancelled = true }}
+			s := newTestAPIServer(status)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/jobs/", s.handleJob)
+			mux.HandleFunc("/api/cancel/", s.handleCancelJob)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if status.Job.Status != tt.wantStatus {
+				t.Errorf("expected status %q, got %q", tt.wantStatus, status.Job.Status)
+			}
+			if cancelled != (tt.wantCode == http.StatusOK) {
+				t.Errorf("expected the job context to be cancelled only on success, cancelled=%v", cancelled)
+			}
+		})
+	}
+}
+
+func TestCancelJob_QueuedJobIsSkipped(t *testing.T) {
+	job := &TileJob{ID: "job-1", Region: "never-run", Status: "pending"}
+	s := newTestAPIServer(&JobStatus{Job: job})
+	updates := make(chan JobStatusUpdate, 10)
+	s.subscribers["job-1"] = []chan JobStatusUpdate{updates}
+
+	if err := s.cancelJob("job-1", "Job was cancelled by user"); err != nil {
+		t.Fatal(err)
+	}
+	s.processJob(job)
+
+	if job.Status != "cancelled" {
+		t.Errorf("expected the dequeued job to stay cancelled, got %q", job.Status)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected only the cancellation update, got %d updates", len(updates))
+	}
+	if update := <-updates; update.Status != "cancelled" {
+		t.Errorf("expected a cancelled update, got %+v", update)
+	}
+}
+
+func TestCancelJob_EndsStream(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "generating"}, UpdatedAt: time.Now()})
+	s.sseKeepalive = time.Hour
+	server := httptest.NewServer(http.HandlerFunc(s.handleJobStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/stream/job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+	readSSEEvents(t, body, 1) // snapshot, sent after subscribing
+
+	if err := s.cancelJob("job-1", "Job was cancelled by user"); err != nil {
+		t.Fatal(err)
+	}
+
+	events := readSSEEvents(t, body, 1)
+	var update JobStatusUpdate
+	if err := json.Unmarshal([]byte(events[0]["data"]), &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.Status != "cancelled" {
+		t.Errorf("expected a final cancelled update, got %+v", update)
+	}
+	if _, err := body.ReadString('\n'); err == nil {
+		t.Error("expected the stream to close after cancellation")
+	}
+}
+
+func TestStop_WaitsForRunningJob(t *testing.T) {
+	cancelled := false
+	running := &JobStatus{Job: &TileJob{ID: "running", Status: "generating"}, CancelFunc: func() { cancelled = true }}
+	queued := &JobStatus{Job: &TileJob{ID: "queued", Status: "pending"}}
+	s := newTestAPIServer(running, queued)
+	updates := make(chan JobStatusUpdate, 10)
+	s.subscribers["queued"] = []chan JobStatusUpdate{updates}
+
+	// Stand in for the job processor finishing its job
+	s.processing.Add(1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.jobsMutex.Lock()
+		running.Job.Status = "completed"
+		s.jobsMutex.Unlock()
+		s.processing.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if running.Job.Status != "completed" || cancelled {
+		t.Errorf("expected the running job to finish uncancelled, got %q (cancelled=%v)", running.Job.Status, cancelled)
+	}
+	if queued.Job.Status != "cancelled" {
+		t.Errorf("expected the queued job to be cancelled, got %q", queued.Job.Status)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected one update for the queued job, got %d", len(updates))
+	}
+	if update := <-updates; !strings.Contains(update.Message, "server shut down") {
+		t.Errorf("expected a shutdown cancellation update, got %+v", update)
+	}
+}
+
+func TestStop_CancelsJobPastTimeout(t *testing.T) {
+	prev := stopCancelGrace
+	stopCancelGrace = 10 * time.Millisecond
+	t.Cleanup(func() { stopCancelGrace = prev })
+
+	cancelled := false
+	running := &JobStatus{Job: &TileJob{ID: "running", Status: "generating"}, CancelFunc: func() { cancelled = true }}
+	s := newTestAPIServer(running)
+
+	// A job processor that never finishes
+	s.processing.Add(1)
+	defer s.processing.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if running.Job.Status != "cancelled" || !cancelled {
+		t.Errorf("expected the running job to be cancelled, got %q (cancelled=%v)", running.Job.Status, cancelled)
+	}
+}
+
+func TestStop_WaitsForCancelledJob(t *testing.T) {
+	var finished atomic.Bool
+	s := newTestAPIServer()
+	// A job processor that only returns once cancelled, after cleaning up
+	s.processing.Add(1)
+	running := &JobStatus{Job: &TileJob{ID: "running", Status: "generating"}, CancelFunc: func() {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+			s.processing.Done()
+		}()
+	}}
+	s.activeJobs["running"] = running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !finished.Load() {
+		t.Error("expected Stop to wait for the cancelled job to return")
+	}
+}
+
+func TestStop_RefusesNewJobs(t *testing.T) {
+	s := newTestAPIServer()
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleGenerate(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(`{"region":"oregon"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(s.jobQueue) != 0 || len(s.activeJobs) != 0 {
+		t.Errorf("expected no job to be queued, got %d queued and %d active", len(s.jobQueue), len(s.activeJobs))
+	}
+
+	// A job dequeued after Stop is not started
+	job := &TileJob{ID: "late", Region: "oregon", Status: "pending"}
+	s.processJob(job)
+	if job.Status != "pending" {
+		t.Errorf("expected the job not to start, got %q", job.Status)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	s := newTestAPIServer()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.Start(0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("expected Start to return nil after Stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+}
+
+func TestStartStop_WithoutDatabaseSkipsLeases(t *testing.T) {
+	// Leases are on by default; without a database there is nothing to
+	// heartbeat or reap
+	s := NewAPIServer(nil, nil, &Config{Service: ServiceConfig{HeartbeatInterval: 1, JobLeaseTimeout: 1}})
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.Start(0)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.jobsMutex.RLock()
+		started := s.httpServer != nil
+		s.jobsMutex.RUnlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Give a reaper time to run its first pass
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("expected Start to return nil after Stop, got %v", err)
+	}
+}
+
+func TestHandleNearbyRoads(t *testing.T) {
+	db := newRoadsTestDB(t)
+	// Around (45, -122) with a 2km radius
+	roads := []struct {
+		id                             string
+		minLat, maxLat, minLng, maxLng float64
+		curvature                      any
+	}{
+		{"containing", 44.99, 45.01, -122.01, -121.99, "1200"},
+		{"north", 45.01, 45.02, -122.001, -121.999, nil},
+		{"corner", 45.015, 45.03, -121.98, -121.97, "800"}, // inside the degree window, ~2.3km away
+		{"far", 45.5, 45.6, -122.1, -122.0, "3000"},
+	}
+	for _, road := range roads {
+		_, err := db.conn.Exec(`INSERT INTO "RoadGeometry" ("roadId", region, "minLat", "maxLat", "minLng", "maxLng", curvature)
+			VALUES ($1, 'oregon', $2, $3, $4, $5, $6)`, road.id, road.minLat, road.maxLat, road.minLng, road.maxLng, road.curvature)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewAPIServer(db, nil, &Config{})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{"nearest first", "?lat=45&lng=-122&radius=2000", http.StatusOK, []string{"containing", "north"}},
+		{"larger radius", "?lat=45&lng=-122&radius=3000", http.StatusOK, []string{"containing", "north", "corner"}},
+		{"limit keeps the nearest", "?lat=45&lng=-122&radius=3000&limit=2", http.StatusOK, []string{"containing", "north"}},
+		{"default radius", "?lat=45.55&lng=-122.05", http.StatusOK, []string{"far"}},
+		{"nothing nearby", "?lat=10&lng=10&radius=500", http.StatusOK, []string{}},
+		{"missing lat", "?lng=-122", http.StatusBadRequest, nil},
+		{"missing lng", "?lat=45", http.StatusBadRequest, nil},
+		{"invalid lat", "?lat=north&lng=-122", http.StatusBadRequest, nil},
+		{"lat out of range", "?lat=91&lng=-122", http.StatusBadRequest, nil},
+		{"lng out of range", "?lat=45&lng=-181", http.StatusBadRequest, nil},
+		{"zero radius", "?lat=45&lng=-122&radius=0", http.StatusBadRequest, nil},
+		{"radius too large", "?lat=45&lng=-122&radius=1e9", http.StatusBadRequest, nil},
+		{"zero limit", "?lat=45&lng=-122&limit=0", http.StatusBadRequest, nil},
+		{"limit too large", "?lat=45&lng=-122&limit=1001", http.StatusBadRequest, nil},
+		{"invalid limit", "?lat=45&lng=-122&limit=ten", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleNearbyRoads(rec, httptest.NewRequest(http.MethodGet, "/api/roads/nearby"+tt.query, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got []NearbyRoad
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, road := range got {
+				ids = append(ids, road.RoadID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+			for i, road := range got {
+				if i > 0 && road.DistanceMeters < got[i-1].DistanceMeters {
+					t.Errorf("roads not sorted by distance: %+v", got)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleNearbyRoads(rec, httptest.NewRequest(http.MethodGet, "/api/roads/nearby?lat=45&lng=-122&radius=2000", nil))
+	var got []NearbyRoad
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Region != "oregon" || got[0].Curvature == nil || *got[0].Curvature != "1200" || got[0].DistanceMeters != 0 ||
+		got[0].Bounds != (RoadBounds{MinLat: 44.99, MaxLat: 45.01, MinLng: -122.01, MaxLng: -121.99}) {
+		t.Errorf("unexpected containing road %+v", got[0])
+	}
+	if got[1].Curvature != nil {
+		t.Errorf("expected no curvature for a road without one, got %q", *got[1].Curvature)
+	}
+}
+
+func TestHandleNearbyRoads_NoDatabase(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestAPIServer().handleNearbyRoads(rec, httptest.NewRequest(http.MethodGet, "/api/roads/nearby?lat=45&lng=-122", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestHandleGetRegions(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"us-oregon.c_1000.curves.kmz":    "oregon",
+		"us-new-york.c_1000.curves.kmz":  "new york kmz",
+		"asia-japan.c_1000.curves.kmz":   "japan",
+		"oregon.c_1000.curves.kmz":       "shadowed by us-oregon",
+		"us-washington.c_300.curves.kmz": "other curvature threshold",
+		"notes.txt":                      "not a kmz",
+		".c_1000.curves.kmz":             "no region name",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "us-idaho.c_1000.curves.kmz"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestAPIServer()
+	s.config.Paths.CurvatureData = dir
+	rec := httptest.NewRecorder()
+	s.handleGetRegions(rec, httptest.NewRequest(http.MethodGet, "/api/regions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var got []KMZRegion
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []KMZRegion{
+		{Name: "asia-japan", File: "asia-japan.c_1000.curves.kmz", SizeBytes: 5, ModifiedAt: modTime},
+		{Name: "new-york", File: "us-new-york.c_1000.curves.kmz", SizeBytes: 12, ModifiedAt: modTime},
+		{Name: "oregon", File: "us-oregon.c_1000.curves.kmz", SizeBytes: 6, ModifiedAt: modTime},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d regions, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].File != want[i].File || got[i].SizeBytes != want[i].SizeBytes || !got[i].ModifiedAt.Equal(want[i].ModifiedAt) {
+			t.Errorf("region %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestHandleGetRegions_MissingDirectory(t *testing.T) {
+	s := newTestAPIServer()
+	s.config.Paths.CurvatureData = filepath.Join(t.TempDir(), "missing")
+	rec := httptest.NewRecorder()
+	s.handleGetRegions(rec, httptest.NewRequest(http.MethodGet, "/api/regions", nil))
+
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("expected 200 with an empty array, got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestUpdateJobStatus_Progress(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "job-1", Status: "processing"}})
+	ch := make(chan JobStatusUpdate, 10)
+	s.subscribers["job-1"] = []chan JobStatusUpdate{ch}
+
+	s.updateJobStatus("job-1", "uploading", "uploading: 42%", 42)
+
+	update := <-ch
+	if update.Status != "uploading" || update.Progress != 42 {
+		t.Errorf("expected uploading at 42%%, got %+v", update)
+	}
+	if history := s.history["job-1"]; len(history) != 1 || history[0].Progress != 42 {
+		t.Errorf("expected the progress kept for resuming clients, got %+v", history)
+	}
+}
+
+func TestHandleGenerate_InvalidRegion(t *testing.T) {
+	for _, region := range []string{"", "../../etc", "tiles/oregon", "oregon.tiles", ".."} {
+		t.Run(region, func(t *testing.T) {
+			s := newTestAPIServer()
+			body := fmt.Sprintf(`{"region": %q}`, region)
+			rec := httptest.NewRecorder()
+			s.handleGenerate(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if len(s.activeJobs) != 0 {
+				t.Errorf("expected no job to be queued, got %d", len(s.activeJobs))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// RegionError records why a single region in a batch failed
+type RegionError struct {
+	Region string
+	Err    error
+}
+
+// BatchResult summarizes a multi-region run
+type BatchResult struct {
+	Succeeded []string
+	Failed    []RegionError
+	Cancelled []string // interrupted or never started because the context was cancelled
+	Skipped   []string // never started because an earlier region failed (-keep-going=false)
+}
+
+// FailedRegions returns the names of the regions that failed
+func (r *BatchResult) FailedRegions() []string {
+	regions := make([]string, len(r.Failed))
+	for i, f := range r.Failed {
+		regions[i] = f.Region
+	}
+	return regions
+}
+
+// OK reports whether every region in the batch succeeded
+func (r *BatchResult) OK() bool {
+	return len(r.Failed) == 0 && len(r.Cancelled) == 0 && len(r.Skipped) == 0
+}
+
+// LogSummary logs the outcome of the batch, one line per failed region
+func (r *BatchResult) LogSummary(name string) {
+	for _, f := range r.Failed {
+		slog.Error("region failed", "region", f.Region, "error", f.Err)
+	}
+	slog.Info(name+" summary",
+		"succeeded", len(r.Succeeded),
+		"failed", len(r.Failed),
+		"cancelled", len(r.Cancelled),
+		"skipped", len(r.Skipped),
+	)
+	if len(r.Failed) > 0 {
+		slog.Error("failed regions", "regions", r.FailedRegions())
+	}
+	if len(r.Skipped) > 0 {
+		slog.Warn("skipped regions (rerun without -keep-going=false to process them)", "regions", r.Skipped)
+	}
+}
+
+// runRegionBatch processes regions with a pool of workers. Without keepGoing
+// the first failure stops any new region from starting; regions already in
+// flight are allowed to finish. With keepGoing every region is attempted and
+// errors are collected for the summary.
+func runRegionBatch(ctx context.Context, regions []string, workers int, keepGoing bool, process func(ctx context.Context, workerID int, region string) error) *BatchResult {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(regions) {
+		workers = len(regions)
+	}
+
+	workChan := make(chan string, len(regions))
+	for _, region := range regions {
+		workChan <- region
+	}
+	close(workChan)
+
+	result := &BatchResult{}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stopped := false
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for region := range workChan {
+				if ctx.Err() != nil {
+					mu.Lock()
+					result.Cancelled = append(result.Cancelled, region)
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				if stopped {
+					result.Skipped = append(result.Skipped, region)
+					mu.Unlock()
+					continue
+				}
+				mu.Unlock()
+
+				err := process(ctx, workerID, region)
+
+				mu.Lock()
+				if errors.Is(err, context.Canceled) {
+					result.Cancelled = append(result.Cancelled, region)
+				} else if err != nil {
+					result.Failed = append(result.Failed, RegionError{Region: region, Err: err})
+					if !keepGoing {
+						stopped = true
+					}
+				} else {
+					result.Succeeded = append(result.Succeeded, region)
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return result
+}
+
+// jobProcessor runs the full pipeline for a single job (implemented by TileService)
+type jobProcessor interface {
+	ProcessJobWithOptions(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error)
+}
+
+// generateRegions runs the generate pipeline for each region with shared
+// options. With a single worker regions are processed in the order given.
+func generateRegions(ctx context.Context, p jobProcessor, regions []string, opts *JobOptions, workers int, keepGoing bool) *BatchResult {
+	return runRegionBatch(ctx, regions, workers, keepGoing, func(ctx context.Context, workerID int, region string) error {
+		logger := slog.With("worker", workerID, "region", region)
+		logger.Info("starting region")
+
+		job := &TileJob{
+			ID:     newCLIJobID("batch-" + region),
+			Region: region,
+			Status: "pending",
+		}
+
+		result, err := p.ProcessJobWithOptions(ctx, job, opts)
+		if err != nil {
+			logger.Error("region failed", "error", err)
+			return err
+		}
+		logger.Info("region completed", "tiles_count", result.TilesCount, "roads_extracted", result.RoadsExtracted)
+		return nil
+	})
+}
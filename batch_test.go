@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// processFailing returns a batch process func that fails for the named regions
+func processFailing(failing ...string) func(ctx context.Context, workerID int, region string) error {
+	fail := make(map[string]bool)
+	for _, r := range failing {
+		fail[r] = true
+	}
+	return func(ctx context.Context, workerID int, region string) error {
+		if fail[region] {
+			return errors.New("boom")
+		}
+		return nil
+	}
+}
+
+func TestRunRegionBatch_KeepGoing(t *testing.T) {
+	regions := []string{"washington", "oregon", "idaho", "montana"}
+
+	for _, workers := range []int{1, 4} {
+		result := runRegionBatch(context.Background(), regions, workers, true, processFailing("oregon"))
+
+		if len(result.Failed) != 1 || result.Failed[0].Region != "oregon" {
+			t.Errorf("workers=%d: expected only oregon to fail, got %v", workers, result.FailedRegions())
+		}
+		sort.Strings(result.Succeeded)
+		want := []string{"idaho", "montana", "washington"}
+		if len(result.Succeeded) != len(want) {
+			t.Fatalf("workers=%d: expected %v to succeed, got %v", workers, want, result.Succeeded)
+		}
+		for i := range want {
+			if result.Succeeded[i] != want[i] {
+				t.Errorf("workers=%d: expected %v to succeed, got %v", workers, want, result.Succeeded)
+				break
+			}
+		}
+		if len(result.Skipped) != 0 {
+			t.Errorf("workers=%d: expected no skipped regions, got %v", workers, result.Skipped)
+		}
+		if result.OK() {
+			t.Errorf("workers=%d: expected batch with a failure not to be OK", workers)
+		}
+	}
+}
+
+func TestRunRegionBatch_StopsOnFirstFailure(t *testing.T) {
+	regions := []string{"washington", "oregon", "idaho", "montana"}
+
+	result := runRegionBatch(context.Background(), regions, 1, false, processFailing("oregon"))
+
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != "washington" {
+		t.Errorf("expected only washington to succeed, got %v", result.Succeeded)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Region != "oregon" {
+		t.Errorf("expected oregon to fail, got %v", result.FailedRegions())
+	}
+	if len(result.Skipped) != 2 {
+		t.Errorf("expected idaho and montana to be skipped, got %v", result.Skipped)
+	}
+}
+
+func TestRunRegionBatch_AllSucceed(t *testing.T) {
+	result := runRegionBatch(context.Background(), []string{"washington", "oregon"}, 2, false, processFailing())
+	if !result.OK() {
+		t.Errorf("expected batch to be OK, got %+v", result)
+	}
+	if len(result.Succeeded) != 2 {
+		t.Errorf("expected 2 successes, got %v", result.Succeeded)
+	}
+}
+
+func TestRunRegionBatch_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	process := func(ctx context.Context, workerID int, region string) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	result := runRegionBatch(ctx, []string{"washington", "oregon", "idaho"}, 1, true, process)
+
+	if len(result.Cancelled) != 3 {
+		t.Errorf("expected all regions to be cancelled, got %v", result.Cancelled)
+	}
+	if len(result.Failed) != 0 {
+		t.Errorf("expected cancellation not to count as failure, got %v", result.FailedRegions())
+	}
+}
+
+// recordingProcessor records the jobs it is asked to run
+type recordingProcessor struct {
+	mu   sync.Mutex
+	jobs []*TileJob
+	opts []*JobOptions
+	fail map[string]bool
+}
+
+func (p *recordingProcessor) ProcessJobWithOptions(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs = append(p.jobs, job)
+	p.opts = append(p.opts, opts)
+	if p.fail[job.Region] {
+		return nil, errors.New("boom")
+	}
+	return &JobResult{Region: job.Region}, nil
+}
+
+func TestGenerateRegions_RunsEachRegionInOrder(t *testing.T) {
+	p := &recordingProcessor{}
+	opts := &JobOptions{MinZoom: 5, MaxZoom: 10, SkipUpload: true}
+
+	result := generateRegions(context.Background(), p, []string{"washington", "oregon"}, opts, 1, false)
+
+	if !result.OK() {
+		t.Fatalf("expected batch to succeed, got %+v", result)
+	}
+	if len(p.jobs) != 2 {
+		t.Fatalf("expected 2 pipelines to run, got %d", len(p.jobs))
+	}
+	for i, want := range []string{"washington", "oregon"} {
+		if p.jobs[i].Region != want {
+			t.Errorf("job %d: expected region %s, got %s", i, want, p.jobs[i].Region)
+		}
+		if p.opts[i] != opts {
+			t.Errorf("job %d: expected shared job options", i)
+		}
+	}
+	if p.jobs[0].ID == p.jobs[1].ID {
+		t.Errorf("expected distinct job IDs, both were %s", p.jobs[0].ID)
+	}
+}
+
+func TestGenerateRegions_KeepGoingAggregates(t *testing.T) {
+	p := &recordingProcessor{fail: map[string]bool{"washington": true}}
+
+	result := generateRegions(context.Background(), p, []string{"washington", "oregon"}, &JobOptions{}, 1, true)
+
+	if len(p.jobs) != 2 {
+		t.Fatalf("expected both pipelines to run, got %d", len(p.jobs))
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Region != "washington" {
+		t.Errorf("expected washington to fail, got %v", result.FailedRegions())
+	}
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != "oregon" {
+		t.Errorf("expected oregon to succeed, got %v", result.Succeeded)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+// parseRegionBounds parses REGION_BOUNDS, a semicolon-separated list of
+// region=minLng,minLat,maxLng,maxLat entries, e.g.
+// "oregon=-124.7,41.9,-116.4,46.3;washington=-124.9,45.5,-116.9,49.1".
+func parseRegionBounds(value string) (map[string]orb.Bound, error) {
+	bounds := make(map[string]orb.Bound)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		region, coords, ok := strings.Cut(entry, "=")
+		region = strings.TrimSpace(region)
+		if !ok || region == "" {
+			return nil, fmt.Errorf("%q is not region=minLng,minLat,maxLng,maxLat", entry)
+		}
+
+		parts := strings.Split(coords, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("bounds for %s need 4 values, got %d", region, len(parts))
+		}
+		var v [4]float64
+		for i, part := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bounds for %s: %w", region, err)
+			}
+			v[i] = f
+		}
+
+		bound := orb.Bound{Min: orb.Point{v[0], v[1]}, Max: orb.Point{v[2], v[3]}}
+		if bound.Min.Lon() >= bound.Max.Lon() || bound.Min.Lat() >= bound.Max.Lat() {
+			return nil, fmt.Errorf("bounds for %s are empty (min must be below max)", region)
+		}
+		bounds[region] = bound
+	}
+	return bounds, nil
+}
+
+// pruneTilesOutsideBounds removes tiles in the zoom range that do not touch
+// bound, along with any x directories left empty. Returns how many tiles
+// were removed.
+func pruneTilesOutsideBounds(tilesDir string, bound orb.Bound, minZoom, maxZoom int) (int, error) {
+	coords, err := GetTileCoords(tilesDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tiles: %w", err)
+	}
+
+	removed := 0
+	for c := range coords {
+		if c.Z < minZoom || c.Z > maxZoom {
+			continue
+		}
+		tile := maptile.New(uint32(c.X), uint32(c.Y), maptile.Zoom(c.Z))
+		if tile.Bound().Intersects(bound) {
+			continue
+		}
+
+		xDir := filepath.Join(tilesDir, strconv.Itoa(c.Z), strconv.Itoa(c.X))
+		if err := os.Remove(filepath.Join(xDir, fmt.Sprintf("%d.pbf", c.Y))); err != nil {
+			return removed, fmt.Errorf("failed to remove out-of-bounds tile: %w", err)
+		}
+		removed++
+		// Fails harmlessly while the directory still holds tiles
+		os.Remove(xDir)
+	}
+	return removed, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestParseRegionBounds(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]orb.Bound
+		wantErr bool
+	}{
+		{"", map[string]orb.Bound{}, false},
+		{"oregon=-124.7,41.9,-116.4,46.3", map[string]orb.Bound{
+			"oregon": {Min: orb.Point{-124.7, 41.9}, Max: orb.Point{-116.4, 46.3}},
+		}, false},
+		{" oregon = -124.7, 41.9, -116.4, 46.3 ; washington=-124.9,45.5,-116.9,49.1;", map[string]orb.Bound{
+			"oregon":     {Min: orb.Point{-124.7, 41.9}, Max: orb.Point{-116.4, 46.3}},
+			"washington": {Min: orb.Point{-124.9, 45.5}, Max: orb.Point{-116.9, 49.1}},
+		}, false},
+		{"oregon", nil, true},
+		{"oregon=-124.7,41.9,-116.4", nil, true},
+		{"oregon=-124.7,41.9,-116.4,north", nil, true},
+		{"oregon=-116.4,41.9,-124.7,46.3", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRegionBounds(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRegionBounds(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseRegionBounds(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for region, bound := range tt.want {
+			if got[region] != bound {
+				t.Errorf("parseRegionBounds(%q)[%s] = %v, want %v", tt.value, region, got[region], bound)
+			}
+		}
+	}
+}
+
+// writeStrayGeoJSON writes a Seattle road plus a stray road in Florida
+func writeStrayGeoJSON(t *testing.T) string {
+	t.Helper()
+	fc := geojson.NewFeatureCollection()
+	road := geojson.NewFeature(orb.LineString{{-122.40, 47.55}, {-122.30, 47.60}})
+	road.Properties = geojson.Properties{"id": "seattle", "Name": "In Region"}
+	stray := geojson.NewFeature(orb.LineString{{-81.40, 28.50}, {-81.30, 28.55}})
+	stray.Properties = geojson.Properties{"id": "stray", "Name": "Stray"}
+	fc.Append(road)
+	fc.Append(stray)
+
+	data, err := fc.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "roads.geojson")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerateTiles_RegionBoundsDropsStrayTiles(t *testing.T) {
+	geoJSONPath := writeStrayGeoJSON(t)
+	washington := orb.Bound{Min: orb.Point{-124.9, 45.5}, Max: orb.Point{-116.9, 49.1}}
+
+	countOutside := func(dir string) (inside, outside int) {
+		coords, err := GetTileCoords(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for c := range coords {
+			if maptile.New(uint32(c.X), uint32(c.Y), maptile.Zoom(c.Z)).Bound().Intersects(washington) {
+				inside++
+			} else {
+				outside++
+			}
+		}
+		return inside, outside
+	}
+
+	// Without bounds the stray road produces tiles in Florida
+	unbounded, _, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "washington", t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 6, MaxZoom: 9, Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, outside := countOutside(unbounded); outside == 0 {
+		t.Fatal("expected out-of-region tiles without bounds")
+	}
+
+	tilesDir, count, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "washington", t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 6, MaxZoom: 9, Encoder: EncoderGo, Bounds: &washington})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside, outside := countOutside(tilesDir)
+	if outside != 0 {
+		t.Errorf("expected no tiles outside the region bounds, found %d", outside)
+	}
+	if inside == 0 || count != inside {
+		t.Errorf("expected the %d in-region tiles to remain, count reported %d", inside, count)
+	}
+}
+
+func TestGenerateTiles_RegionBoundsPrunesExtendedZooms(t *testing.T) {
+	// A Tippecanoe stand-in that adds zoom 8 past -max-zoom 6, as
+	// --extend-zooms-if-still-dropping (on by default) does
+	bin := filepath.Join(t.TempDir(), "tippecanoe")
+	script := `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+for tile in 6/10/22 6/0/0 8/42/89 8/0/0; do
+	mkdir -p "$out/${tile%/*}"
+	echo tile > "$out/$tile.pbf"
+done
+`
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	washington := orb.Bound{Min: orb.Point{-124.9, 45.5}, Max: orb.Point{-116.9, 49.1}}
+
+	tilesDir, count, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "washington", t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 6, TippecanoeBin: bin, Bounds: &washington})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coords, err := GetTileCoords(tilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || !coords[TileCoord{Z: 6, X: 10, Y: 22}] || !coords[TileCoord{Z: 8, X: 42, Y: 89}] {
+		t.Errorf("expected only the in-region tiles at zooms 6 and 8, got %d: %v", count, coords)
+	}
+	if IsIncompleteTileDir(tilesDir) {
+		t.Error("expected the incomplete marker to be removed")
+	}
+}
+
+func TestPruneTilesOutsideBounds_ZoomRangeAndEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	bound := orb.Bound{Min: orb.Point{-122.5, 47.5}, Max: orb.Point{-122.2, 47.7}}
+	in := maptile.At(orb.Point{-122.3, 47.6}, 10)
+	out := maptile.At(orb.Point{-81.3, 28.5}, 10)
+	outOtherZoom := maptile.At(orb.Point{-81.3, 28.5}, 14)
+
+	for _, tile := range []maptile.Tile{in, out, outOtherZoom} {
+		path := filepath.Join(dir, tileRelPath(tile))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("tile"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := pruneTilesOutsideBounds(dir, bound, 5, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d tiles, want 1", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, tileRelPath(in))); err != nil {
+		t.Errorf("in-bounds tile removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(filepath.Join(dir, tileRelPath(out)))); !os.IsNotExist(err) {
+		t.Errorf("expected empty x directory to be removed, got %v", err)
+	}
+	// Outside the generated zoom range, so left alone
+	if _, err := os.Stat(filepath.Join(dir, tileRelPath(outOtherZoom))); err != nil {
+		t.Errorf("tile outside zoom range removed: %v", err)
+	}
+}
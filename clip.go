@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+)
+
+// ClipStats reports how much of the input a clip boundary removed
+type ClipStats struct {
+	RoadsDropped  int     // Roads entirely outside the boundary
+	RoadsTrimmed  int     // Roads partly outside the boundary
+	MetersRemoved float64 // Total road length cut away
+}
+
+// LoadClipPolygon reads the Polygon and MultiPolygon geometries of a GeoJSON
+// file (FeatureCollection, Feature or bare geometry) into one MultiPolygon
+func LoadClipPolygon(path string) (orb.MultiPolygon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clip boundary: %w", err)
+	}
+
+	var geometries []orb.Geometry
+	if fc, err := geojson.UnmarshalFeatureCollection(data); err == nil && len(fc.Features) > 0 {
+		for _, f := range fc.Features {
+			geometries = append(geometries, f.Geometry)
+		}
+	} else if f, err := geojson.UnmarshalFeature(data); err == nil && f.Geometry != nil {
+		geometries = append(geometries, f.Geometry)
+	} else if g, err := geojson.UnmarshalGeometry(data); err == nil && g.Coordinates != nil {
+		geometries = append(geometries, g.Geometry())
+	}
+
+	var poly orb.MultiPolygon
+	for _, g := range geometries {
+		switch g := g.(type) {
+		case orb.Polygon:
+			poly = append(poly, g)
+		case orb.MultiPolygon:
+			poly = append(poly, g...)
+		}
+	}
+	if len(poly) == 0 {
+		return nil, fmt.Errorf("no Polygon or MultiPolygon found in %s", path)
+	}
+	return poly, nil
+}
+
+// loadClipBoundary loads the -clip boundary; an empty path means no clipping
+func loadClipBoundary(path string) (orb.MultiPolygon, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return LoadClipPolygon(path)
+}
+
+// clipRoadLines clips a road's segments to poly, returning the parts inside
+// and how many meters were cut away
+func clipRoadLines(lines [][][]float64, poly orb.MultiPolygon) ([][][]float64, float64) {
+	var kept [][][]float64
+	removed := 0.0
+	for _, line := range lines {
+		pieces := clipLineToPolygon(line, poly)
+		keptLength := 0.0
+		for _, piece := range pieces {
+			keptLength += calculateLineStringLength(piece)
+		}
+		removed += math.Max(0, calculateLineStringLength(line)-keptLength)
+		kept = append(kept, pieces...)
+	}
+	return kept, removed
+}
+
+// clipLineToPolygon splits line where it crosses the boundary of poly and
+// keeps the pieces inside. Coordinates are [lng, lat] and the test is planar.
+func clipLineToPolygon(line [][]float64, poly orb.MultiPolygon) [][][]float64 {
+	var pieces [][][]float64
+	var current [][]float64
+
+	for i := 0; i < len(line)-1; i++ {
+		a := orb.Point{line[i][0], line[i][1]}
+		b := orb.Point{line[i+1][0], line[i+1][1]}
+
+		// Split the segment at every boundary crossing; each sub-segment is
+		// then wholly inside or outside, so its midpoint decides
+		cuts := append([]float64{0}, boundaryCrossings(a, b, poly)...)
+		cuts = append(cuts, 1)
+
+		for j := 0; j < len(cuts)-1; j++ {
+			t0, t1 := cuts[j], cuts[j+1]
+			if t1-t0 < 1e-12 {
+				continue
+			}
+			if planar.MultiPolygonContains(poly, lerpPoint(a, b, (t0+t1)/2)) {
+				if current == nil {
+					current = [][]float64{lerpCoord(line[i], line[i+1], t0)}
+				}
+				current = append(current, lerpCoord(line[i], line[i+1], t1))
+			} else if current != nil {
+				pieces = append(pieces, current)
+				current = nil
+			}
+		}
+	}
+	if current != nil {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// boundaryCrossings returns the sorted positions (0 < t < 1) along a->b where
+// the segment crosses an edge of any ring of poly
+func boundaryCrossings(a, b orb.Point, poly orb.MultiPolygon) []float64 {
+	var ts []float64
+	r := orb.Point{b[0] - a[0], b[1] - a[1]}
+	for _, p := range poly {
+		for _, ring := range p {
+			for k := 0; k < len(ring)-1; k++ {
+				c, d := ring[k], ring[k+1]
+				s := orb.Point{d[0] - c[0], d[1] - c[1]}
+				denom := cross(r, s)
+				if denom == 0 {
+					continue // parallel; the midpoint test handles overlap
+				}
+				ac := orb.Point{c[0] - a[0], c[1] - a[1]}
+				t := cross(ac, s) / denom
+				u := cross(ac, r) / denom
+				if t > 0 && t < 1 && u >= 0 && u <= 1 {
+					ts = append(ts, t)
+				}
+			}
+		}
+	}
+	sort.Float64s(ts)
+	return ts
+}
+
+func cross(p, q orb.Point) float64 {
+	return p[0]*q[1] - p[1]*q[0]
+}
+
+// lerpCoord interpolates every dimension of a coordinate, so an altitude is
+// carried through a cut
+func lerpCoord(a, b []float64, t float64) []float64 {
+	p := make([]float64, len(a))
+	for i := range p {
+		p[i] = a[i]
+		if i < len(b) {
+			p[i] += (b[i] - a[i]) * t
+		}
+	}
+	return p
+}
+
+func lerpPoint(a, b orb.Point, t float64) orb.Point {
+	return orb.Point{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+}
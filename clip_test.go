@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// square is the polygon (0,0)-(10,10), with an optional hole (4,4)-(6,6)
+func square(withHole bool) orb.MultiPolygon {
+	poly := orb.Polygon{{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}}
+	if withHole {
+		poly = append(poly, orb.Ring{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}})
+	}
+	return orb.MultiPolygon{poly}
+}
+
+func TestClipLineToPolygon(t *testing.T) {
+	tests := []struct {
+		name string
+		line [][]float64
+		hole bool
+		want [][][]float64
+	}{
+		{"inside", [][]float64{{1, 1}, {2, 2}, {3, 1}}, false, [][][]float64{{{1, 1}, {2, 2}, {3, 1}}}},
+		{"outside", [][]float64{{-5, -5}, {-1, -1}}, false, nil},
+		{"crosses both edges", [][]float64{{-5, 5}, {15, 5}}, false, [][][]float64{{{0, 5}, {10, 5}}}},
+		{"leaves at a vertex bend", [][]float64{{5, 5}, {5, 15}, {8, 15}}, false, [][][]float64{{{5, 5}, {5, 10}}}},
+		{"exits and re-enters", [][]float64{{5, 5}, {12, 5}, {12, 8}, {5, 8}}, false, [][][]float64{{{5, 5}, {10, 5}}, {{10, 8}, {5, 8}}}},
+		{"through a hole", [][]float64{{1, 5}, {9, 5}}, true, [][][]float64{{{1, 5}, {4, 5}}, {{6, 5}, {9, 5}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clipLineToPolygon(tt.line, square(tt.hole))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d pieces %v, want %d %v", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if len(got[i]) != len(tt.want[i]) {
+					t.Fatalf("piece %d = %v, want %v", i, got[i], tt.want[i])
+				}
+				for j := range got[i] {
+					if math.Abs(got[i][j][0]-tt.want[i][j][0]) > 1e-9 || math.Abs(got[i][j][1]-tt.want[i][j][1]) > 1e-9 {
+						t.Errorf("piece %d point %d = %v, want %v", i, j, got[i][j], tt.want[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestClipRoadLines_ReportsRemovedLength(t *testing.T) {
+	lines := [][][]float64{{{-5, 5}, {15, 5}}, {{20, 20}, {21, 21}}}
+	kept, removed := clipRoadLines(lines, square(false))
+	if len(kept) != 1 {
+		t.Fatalf("expected one kept piece, got %v", kept)
+	}
+
+	want := calculateLineStringLength(lines[0]) - calculateLineStringLength(kept[0]) + calculateLineStringLength(lines[1])
+	if math.Abs(removed-want) > 1 {
+		t.Errorf("removed %.0f m, want %.0f m", removed, want)
+	}
+}
+
+func TestLoadClipPolygon(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		polygons int
+		wantErr  bool
+	}{
+		{"collection.geojson", `{"type":"FeatureCollection","features":[
+			{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}},
+			{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[[[[2,2],[3,2],[3,3],[2,2]]],[[[4,4],[5,4],[5,5],[4,4]]]]}},
+			{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[9,9]}}]}`, 3, false},
+		{"feature.geojson", `{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`, 1, false},
+		{"geometry.geojson", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, 1, false},
+		{"lines.geojson", `{"type":"LineString","coordinates":[[0,0],[1,1]]}`, 0, true},
+		{"broken.geojson", `not json`, 0, true},
+	}
+
+	for _, tt := range tests {
+		poly, err := LoadClipPolygon(write(tt.name, tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(poly) != tt.polygons {
+			t.Errorf("%s: got %d polygons, want %d", tt.name, len(poly), tt.polygons)
+		}
+	}
+}
+
+func TestConvertKMLToGeoJSON_Clip(t *testing.T) {
+	kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Inside Road</name><Placemark><LineString><coordinates>1,1,0 2,2,0</coordinates></LineString></Placemark></Folder>
+<Folder><name>Border Road</name><Placemark><LineString><coordinates>5,5,0 15,5,0</coordinates></LineString></Placemark></Folder>
+<Folder><name>Outside Road</name><Placemark><LineString><coordinates>20,20,0 21,21,0</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(kml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	region := "cliptest-" + filepath.Base(t.TempDir())
+	unclippedPath, count, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 roads without clipping, got %d", count)
+	}
+	unclipped, err := os.ReadFile(unclippedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := geojson.UnmarshalFeatureCollection(unclipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, count, err := ConvertKMLToGeoJSONWithOptions(context.Background(), kmlPath, region, &ConvertOptions{Clip: square(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	if count != 2 {
+		t.Fatalf("expected the outside road to be dropped, got %d roads", count)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range fc.Features {
+		if f.Properties["Name"] != "Border Road" {
+			continue
+		}
+		ls, ok := f.Geometry.(orb.LineString)
+		if !ok || len(ls) != 2 || ls[1] != (orb.Point{10, 5}) {
+			t.Errorf("expected border road trimmed at x=10, got %v", f.Geometry)
+		}
+		if l, _ := f.Properties["length"].(float64); l >= before.Features[1].Properties["length"].(float64) {
+			t.Errorf("expected trimmed length below the original, got %v", l)
+		}
+		// The id is kept from the unclipped road
+		if f.Properties["id"] != before.Features[1].Properties["id"] {
+			t.Errorf("trimmed road id changed: %v vs %v", f.Properties["id"], before.Features[1].Properties["id"])
+		}
+	}
+}
+
+func TestClipLineToPolygon_KeepsAltitude(t *testing.T) {
+	got := clipLineToPolygon([][]float64{{5, 5, 100}, {15, 5, 200}}, square(false))
+	if len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("expected one two-point piece, got %v", got)
+	}
+	end := got[0][1]
+	if len(end) != 3 || math.Abs(end[0]-10) > 1e-9 || math.Abs(end[2]-150) > 1e-9 {
+		t.Errorf("expected the cut at x=10 to interpolate altitude 150, got %v", end)
+	}
+}
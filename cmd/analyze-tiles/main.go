@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mumuon/drivefinder/tile-service/internal/geomcount"
 	"github.com/paulmach/orb/encoding/mvt"
 )
 
@@ -18,8 +22,41 @@ type TileStats struct {
 	TotalTiles    int
 	TotalFeatures int
 	FeaturesByZoom map[int]int
+	// Coordinate points summed over every feature's geometry, comparable
+	// with analyze-kml's total coordinate points
+	TotalCoordinates  int
+	CoordinatesByZoom map[int]int
 	UniqueRoadIDs map[string]bool
 	LayersFound   map[string]int
+	ByLayer       map[string]*LayerStats
+
+	// Layer restricts feature counts and road IDs to one layer ("" = all
+	// layers, with road IDs from the "roads" layer)
+	Layer string
+}
+
+// LayerStats counts the features and road IDs of one layer
+type LayerStats struct {
+	Tiles          int
+	Features       int
+	FeaturesByZoom map[int]int
+	Coordinates    int
+	UniqueRoadIDs  map[string]bool
+}
+
+// defaultRoadLayer is the layer road IDs come from when no -layer is given
+const defaultRoadLayer = "roads"
+
+// newTileStats creates empty stats, restricted to layer when it is set
+func newTileStats(layer string) *TileStats {
+	return &TileStats{
+		FeaturesByZoom: make(map[int]int),
+		CoordinatesByZoom: make(map[int]int),
+		UniqueRoadIDs:  make(map[string]bool),
+		LayersFound:    make(map[string]int),
+		ByLayer:        make(map[string]*LayerStats),
+		Layer:          layer,
+	}
 }
 
 // TileInfo represents detailed information about a single tile
@@ -50,23 +87,35 @@ func main() {
 	tilePath := flag.String("tile", "", "Path to a single tile file to inspect")
 	verbose := flag.Bool("verbose", false, "Show all features (not just first 10)")
 	jsonOutput := flag.Bool("json", false, "Output in JSON format")
+	layer := flag.String("layer", "", "Only count features and road IDs in this layer")
+	allLayers := flag.Bool("all-layers", false, "Report feature counts and road IDs for each layer")
+	mbtilesPath := flag.String("mbtiles", "", "Analyze the tiles of an .mbtiles file")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: analyze-tiles [options] [tile-directory]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: analyze-tiles [options] [tile-directory | file.mbtiles]\n\n")
 		fmt.Fprintf(os.Stderr, "Modes:\n")
 		fmt.Fprintf(os.Stderr, "  1. Single tile inspection: analyze-tiles --tile <path>\n")
-		fmt.Fprintf(os.Stderr, "  2. Directory analysis:     analyze-tiles <directory>\n\n")
+		fmt.Fprintf(os.Stderr, "  2. Directory analysis:     analyze-tiles <directory>\n")
+		fmt.Fprintf(os.Stderr, "  3. MBTiles analysis:       analyze-tiles --mbtiles <file.mbtiles>\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  analyze-tiles --tile ~/data/df/tiles/colorado/10/200/400.pbf\n")
 		fmt.Fprintf(os.Stderr, "  analyze-tiles --tile ~/data/df/tiles/colorado/10/200/400.pbf --verbose\n")
 		fmt.Fprintf(os.Stderr, "  analyze-tiles --tile ~/data/df/tiles/colorado/10/200/400.pbf --json\n")
-		fmt.Fprintf(os.Stderr, "  analyze-tiles ~/data/df/tiles/colorado\n\n")
+		fmt.Fprintf(os.Stderr, "  analyze-tiles ~/data/df/tiles/colorado\n")
+		fmt.Fprintf(os.Stderr, "  analyze-tiles --mbtiles ~/data/df/colorado.mbtiles\n")
+		fmt.Fprintf(os.Stderr, "  analyze-tiles --layer roads ~/data/df/tiles/colorado\n")
+		fmt.Fprintf(os.Stderr, "  analyze-tiles --all-layers ~/data/df/tiles/colorado\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
 
+	if *layer != "" && *allLayers {
+		fmt.Println("Error: --layer and --all-layers cannot be combined")
+		os.Exit(1)
+	}
+
 	// Single tile inspection mode
 	if *tilePath != "" {
 		info, err := inspectSingleTile(*tilePath)
@@ -74,6 +123,9 @@ func main() {
 			fmt.Printf("Error inspecting tile: %v\n", err)
 			os.Exit(1)
 		}
+		if *layer != "" {
+			info.Layers = filterLayers(info.Layers, *layer)
+		}
 
 		if *jsonOutput {
 			printTileJSON(info)
@@ -85,25 +137,28 @@ func main() {
 
 	// Directory analysis mode (existing behavior)
 	args := flag.Args()
-	if len(args) < 1 {
+	if *mbtilesPath == "" && len(args) < 1 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	tileDir := args[0]
-
-	stats := &TileStats{
-		FeaturesByZoom: make(map[int]int),
-		UniqueRoadIDs: make(map[string]bool),
-		LayersFound:   make(map[string]int),
+	tileDir, analyze, err := tileSource(*mbtilesPath, args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	if err := analyzeTileDirectory(tileDir, stats); err != nil {
+	stats := newTileStats(*layer)
+
+	if err := analyze(tileDir, stats); err != nil {
 		fmt.Printf("Error analyzing tiles: %v\n", err)
 		os.Exit(1)
 	}
 
 	printStats(stats, tileDir)
+	if *allLayers {
+		printLayerStats(stats)
+	}
 }
 
 // inspectSingleTile inspects a single tile file and returns detailed information
@@ -292,13 +347,80 @@ func analyzeTileDirectory(dir string, stats *TileStats) error {
 	})
 }
 
+// isMBTiles reports whether path names an MBTiles file rather than a tile
+// directory
+func isMBTiles(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mbtiles")
+}
+
+// tileSource picks the tiles to analyze: the -mbtiles file when it is set,
+// otherwise the first argument, read as MBTiles when it has that extension
+func tileSource(mbtiles string, args []string) (string, func(string, *TileStats) error, error) {
+	if mbtiles != "" {
+		if len(args) > 0 {
+			return "", nil, fmt.Errorf("--mbtiles cannot be combined with a tile directory")
+		}
+		return mbtiles, analyzeMBTiles, nil
+	}
+	if len(args) < 1 {
+		return "", nil, fmt.Errorf("no tile directory or --mbtiles file given")
+	}
+	if isMBTiles(args[0]) {
+		return args[0], analyzeMBTiles, nil
+	}
+	return args[0], analyzeTileDirectory, nil
+}
+
+// analyzeMBTiles analyzes every row of an MBTiles file's tiles table.
+// MBTiles stores rows in the TMS scheme, so tile_row is flipped to the XYZ
+// row used by tile directories.
+func analyzeMBTiles(path string, stats *TileStats) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT zoom_level, tile_column, tile_row, tile_data FROM tiles ORDER BY zoom_level, tile_column, tile_row`)
+	if err != nil {
+		return fmt.Errorf("failed to read tiles table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var z, x, row int
+		var data []byte
+		if err := rows.Scan(&z, &x, &row, &data); err != nil {
+			return err
+		}
+		y := (1 << z) - 1 - row
+
+		if err := analyzeTileData(data, z, stats); err != nil {
+			fmt.Printf("Warning: failed to analyze %d/%d/%d: %v\n", z, x, y, err)
+		}
+	}
+	return rows.Err()
+}
+
 func analyzeTile(path string, z int, stats *TileStats) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
+	return analyzeTileData(data, z, stats)
+}
 
-	layers, err := mvt.Unmarshal(data)
+// analyzeTileData counts the features of one tile at zoom z into stats.
+// MBTiles tile data is usually gzipped, so gzipped tiles are decompressed.
+func analyzeTileData(data []byte, z int, stats *TileStats) error {
+	unmarshal := mvt.Unmarshal
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		unmarshal = mvt.UnmarshalGzipped
+	}
+	layers, err := unmarshal(data)
 	if err != nil {
 		return err
 	}
@@ -309,25 +431,65 @@ func analyzeTile(path string, z int, stats *TileStats) error {
 		layerName := layer.Name
 		stats.LayersFound[layerName]++
 
-		numFeatures := len(layer.Features)
-		stats.TotalFeatures += numFeatures
-		stats.FeaturesByZoom[z] += numFeatures
-
-		// Extract road IDs from features (if roads layer)
-		if layerName == "roads" {
-			for _, feature := range layer.Features {
-				if roadID := getPropertyString(feature.Properties, "Name"); roadID != "" {
-					stats.UniqueRoadIDs[roadID] = true
-				} else if id := getPropertyString(feature.Properties, "id"); id != "" {
+		ls := stats.ByLayer[layerName]
+		if ls == nil {
+			ls = &LayerStats{FeaturesByZoom: make(map[int]int), UniqueRoadIDs: make(map[string]bool)}
+			stats.ByLayer[layerName] = ls
+		}
+		ls.Tiles++
+		ls.Features += len(layer.Features)
+		ls.FeaturesByZoom[z] += len(layer.Features)
+
+		// Road IDs come from the selected layer, or the roads layer by default
+		roadLayer := stats.Layer
+		if roadLayer == "" {
+			roadLayer = defaultRoadLayer
+		}
+		coords := 0
+		for _, feature := range layer.Features {
+			coords += geomcount.Coordinates(feature.Geometry)
+			if id := featureRoadID(feature.Properties); id != "" {
+				ls.UniqueRoadIDs[id] = true
+				if layerName == roadLayer {
 					stats.UniqueRoadIDs[id] = true
 				}
 			}
 		}
+		ls.Coordinates += coords
+
+		if stats.Layer != "" && layerName != stats.Layer {
+			continue
+		}
+
+		numFeatures := len(layer.Features)
+		stats.TotalFeatures += numFeatures
+		stats.FeaturesByZoom[z] += numFeatures
+		stats.TotalCoordinates += coords
+		stats.CoordinatesByZoom[z] += coords
 	}
 
 	return nil
 }
 
+// featureRoadID returns a feature's road ID: its Name property, or its id
+func featureRoadID(props map[string]interface{}) string {
+	if roadID := getPropertyString(props, "Name"); roadID != "" {
+		return roadID
+	}
+	return getPropertyString(props, "id")
+}
+
+// filterLayers keeps only the layer with the given name
+func filterLayers(layers []LayerInfo, name string) []LayerInfo {
+	var kept []LayerInfo
+	for _, layer := range layers {
+		if layer.Name == name {
+			kept = append(kept, layer)
+		}
+	}
+	return kept
+}
+
 func getPropertyString(props map[string]interface{}, key string) string {
 	if val, ok := props[key]; ok {
 		if str, ok := val.(string); ok {
@@ -343,9 +505,18 @@ func printStats(stats *TileStats, dir string) {
 	fmt.Println("=" + strings.Repeat("=", 70))
 	fmt.Println()
 
+	if stats.Layer != "" {
+		fmt.Printf("Layer: %s\n", stats.Layer)
+		if stats.LayersFound[stats.Layer] == 0 {
+			fmt.Printf("⚠️  Layer %q not found in any tile\n", stats.Layer)
+		}
+		fmt.Println()
+	}
+
 	fmt.Println("📊 Tile Counts:")
 	fmt.Printf("  Total tiles:     %d\n", stats.TotalTiles)
 	fmt.Printf("  Total features:  %d\n", stats.TotalFeatures)
+	fmt.Printf("  Total coords:    %d\n", stats.TotalCoordinates)
 	fmt.Printf("  Unique road IDs: %d\n", len(stats.UniqueRoadIDs))
 	fmt.Println()
 
@@ -365,7 +536,38 @@ func printStats(stats *TileStats, dir string) {
 	for _, z := range zooms {
 		count := stats.FeaturesByZoom[z]
 		bar := strings.Repeat("█", min(count/10, 50))
-		fmt.Printf("  Z%2d: %6d features %s\n", z, count, bar)
+		fmt.Printf("  Z%2d: %6d features %8d coords %s\n", z, count, stats.CoordinatesByZoom[z], bar)
+	}
+	fmt.Println()
+
+	fmt.Println("=" + strings.Repeat("=", 70))
+}
+
+// printLayerStats prints the per-layer breakdown in layer name order
+func printLayerStats(stats *TileStats) {
+	names := make([]string, 0, len(stats.ByLayer))
+	for name := range stats.ByLayer {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("📚 Per-Layer Breakdown:")
+	for _, name := range names {
+		ls := stats.ByLayer[name]
+		fmt.Printf("  %s\n", name)
+		fmt.Printf("    Tiles:           %d\n", ls.Tiles)
+		fmt.Printf("    Features:        %d\n", ls.Features)
+		fmt.Printf("    Coordinates:     %d\n", ls.Coordinates)
+		fmt.Printf("    Unique road IDs: %d\n", len(ls.UniqueRoadIDs))
+
+		zooms := make([]int, 0, len(ls.FeaturesByZoom))
+		for z := range ls.FeaturesByZoom {
+			zooms = append(zooms, z)
+		}
+		sort.Ints(zooms)
+		for _, z := range zooms {
+			fmt.Printf("    Z%2d: %6d features\n", z, ls.FeaturesByZoom[z])
+		}
 	}
 	fmt.Println()
 
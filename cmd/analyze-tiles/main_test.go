@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+)
+
+// testTile is a fixture tile: a "roads" layer with one line per road name
+// (omitted when there are none) plus any extra layers, each feature named by
+// its ID ("" = no ID)
+type testTile struct {
+	z, x, y int
+	roads   []string
+	extra   map[string][]string
+}
+
+// encodeTile builds an MVT tile from a fixture
+func encodeTile(t *testing.T, tile testTile) []byte {
+	t.Helper()
+	layers := map[string][]string{}
+	if len(tile.roads) > 0 {
+		layers["roads"] = tile.roads
+	}
+	for name, ids := range tile.extra {
+		layers[name] = ids
+	}
+
+	collections := make(map[string]*geojson.FeatureCollection)
+	for name, ids := range layers {
+		fc := geojson.NewFeatureCollection()
+		for i, id := range ids {
+			f := geojson.NewFeature(orb.LineString{{float64(i), 0}, {float64(i), 100}})
+			if id != "" {
+				if name == "roads" {
+					f.Properties["Name"] = id
+				} else {
+					f.Properties["id"] = id
+				}
+			}
+			fc.Append(f)
+		}
+		collections[name] = fc
+	}
+
+	data, err := mvt.Marshal(mvt.NewLayers(collections))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeTileDir writes tiles as a z/x/y.pbf directory
+func writeTileDir(t *testing.T, tiles []testTile) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, tile := range tiles {
+		path := filepath.Join(dir, fmt.Sprint(tile.z), fmt.Sprint(tile.x), fmt.Sprintf("%d.pbf", tile.y))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, encodeTile(t, tile), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// writeMBTiles writes tiles gzipped into an .mbtiles file at their TMS rows,
+// as Tippecanoe does
+func writeMBTiles(t *testing.T, tiles []testTile) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.mbtiles")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, tile := range tiles {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(encodeTile(t, tile))
+		zw.Close()
+
+		row := (1 << tile.z) - 1 - tile.y
+		if _, err := db.Exec(`INSERT INTO tiles VALUES (?, ?, ?, ?)`, tile.z, tile.x, row, buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestAnalyzeMBTilesMatchesDirectory(t *testing.T) {
+	tiles := []testTile{
+		{10, 163, 357, []string{"road-1", "road-2"}, map[string][]string{"labels": {""}}},
+		{10, 164, 357, []string{"road-2"}, map[string][]string{"labels": {""}}},
+		{11, 327, 714, []string{"road-1", "road-3", "road-4"}, map[string][]string{"labels": {""}}},
+	}
+
+	dirStats := newTileStats("")
+	if err := analyzeTileDirectory(writeTileDir(t, tiles), dirStats); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeMBTiles(t, tiles)
+	if !isMBTiles(path) {
+		t.Fatalf("expected %s to be recognised as MBTiles", path)
+	}
+	mbStats := newTileStats("")
+	if err := analyzeMBTiles(path, mbStats); err != nil {
+		t.Fatal(err)
+	}
+
+	roadIDs := map[string]bool{"road-1": true, "road-2": true, "road-3": true, "road-4": true}
+	want := &TileStats{
+		TotalTiles:     3,
+		TotalFeatures:  9, // 6 roads and a label per tile
+		FeaturesByZoom: map[int]int{10: 5, 11: 4},
+		// Every fixture feature is a two-point line
+		TotalCoordinates:  18,
+		CoordinatesByZoom: map[int]int{10: 10, 11: 8},
+		UniqueRoadIDs:     roadIDs,
+		LayersFound:       map[string]int{"roads": 3, "labels": 3},
+		ByLayer: map[string]*LayerStats{
+			"roads":  {Tiles: 3, Features: 6, FeaturesByZoom: map[int]int{10: 3, 11: 3}, Coordinates: 12, UniqueRoadIDs: roadIDs},
+			"labels": {Tiles: 3, Features: 3, FeaturesByZoom: map[int]int{10: 2, 11: 1}, Coordinates: 6, UniqueRoadIDs: map[string]bool{}},
+		},
+	}
+	if !reflect.DeepEqual(dirStats, want) {
+		t.Errorf("directory stats %+v, want %+v", dirStats, want)
+	}
+	if !reflect.DeepEqual(mbStats, want) {
+		t.Errorf("mbtiles stats %+v, want %+v", mbStats, want)
+	}
+}
+
+func TestTileSource(t *testing.T) {
+	tiles := []testTile{{10, 163, 357, []string{"road-1"}, nil}}
+	path := writeMBTiles(t, tiles)
+	// A -mbtiles file is read as MBTiles whatever its extension
+	renamed := filepath.Join(t.TempDir(), "colorado.db")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	src, analyze, err := tileSource(renamed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != renamed {
+		t.Errorf("source %q, want %q", src, renamed)
+	}
+	stats := newTileStats("")
+	if err := analyze(src, stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalTiles != 1 || len(stats.UniqueRoadIDs) != 1 {
+		t.Errorf("got %d tiles and %d road IDs, want 1 and 1", stats.TotalTiles, len(stats.UniqueRoadIDs))
+	}
+
+	if _, _, err := tileSource(renamed, []string{"tiles/colorado"}); err == nil {
+		t.Error("expected an error combining -mbtiles with a directory")
+	}
+	if _, _, err := tileSource("", nil); err == nil {
+		t.Error("expected an error without a source")
+	}
+	dir := t.TempDir()
+	src, analyze, err = tileSource("", []string{dir})
+	if err != nil || src != dir {
+		t.Fatalf("tileSource(dir) = %q, %v", src, err)
+	}
+	if err := analyze(dir, newTileStats("")); err != nil {
+		t.Errorf("expected a directory to be walked, got %v", err)
+	}
+}
+
+func TestAnalyzeMBTiles_Errors(t *testing.T) {
+	if err := analyzeMBTiles(filepath.Join(t.TempDir(), "missing.mbtiles"), newTileStats("")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	// A SQLite file without a tiles table is not an MBTiles file
+	path := filepath.Join(t.TempDir(), "empty.mbtiles")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE metadata (name text, value text)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := analyzeMBTiles(path, newTileStats("")); err == nil {
+		t.Error("expected an error for a file without a tiles table")
+	}
+}
+
+func TestAnalyzeTileDirectory_Layers(t *testing.T) {
+	dir := writeTileDir(t, []testTile{
+		{12, 1, 1, []string{"road-1", "road-2"}, map[string][]string{"trails": {"trail-1"}, "water": {"", ""}}},
+		{12, 1, 2, []string{"road-2"}, map[string][]string{"trails": {"trail-1", "trail-2"}}},
+		{13, 2, 2, nil, map[string][]string{"trails": {"trail-3"}, "water": {""}}},
+	})
+
+	tests := []struct {
+		name         string
+		layer        string
+		wantFeatures int
+		wantByZoom   map[int]int
+		wantRoadIDs  []string
+	}{
+		{"all layers", "", 10, map[int]int{12: 8, 13: 2}, []string{"road-1", "road-2"}},
+		{"roads only", "roads", 3, map[int]int{12: 3}, []string{"road-1", "road-2"}},
+		{"trails only", "trails", 4, map[int]int{12: 3, 13: 1}, []string{"trail-1", "trail-2", "trail-3"}},
+		{"layer without IDs", "water", 3, map[int]int{12: 2, 13: 1}, nil},
+		{"missing layer", "buildings", 0, map[int]int{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := newTileStats(tt.layer)
+			if err := analyzeTileDirectory(dir, stats); err != nil {
+				t.Fatal(err)
+			}
+
+			if stats.TotalTiles != 3 {
+				t.Errorf("expected every tile to be read, got %d", stats.TotalTiles)
+			}
+			if stats.TotalFeatures != tt.wantFeatures || !reflect.DeepEqual(stats.FeaturesByZoom, tt.wantByZoom) {
+				t.Errorf("expected %d features %v, got %d %v", tt.wantFeatures, tt.wantByZoom, stats.TotalFeatures, stats.FeaturesByZoom)
+			}
+			want := map[string]bool{}
+			for _, id := range tt.wantRoadIDs {
+				want[id] = true
+			}
+			if !reflect.DeepEqual(stats.UniqueRoadIDs, want) {
+				t.Errorf("expected road IDs %v, got %v", tt.wantRoadIDs, stats.UniqueRoadIDs)
+			}
+
+			// Layers and the per-layer breakdown always cover every layer
+			if !reflect.DeepEqual(stats.LayersFound, map[string]int{"roads": 2, "trails": 3, "water": 2}) {
+				t.Errorf("unexpected layers found %v", stats.LayersFound)
+			}
+			trails := stats.ByLayer["trails"]
+			if trails == nil || trails.Tiles != 3 || trails.Features != 4 || len(trails.UniqueRoadIDs) != 3 {
+				t.Errorf("unexpected trails breakdown %+v", trails)
+			}
+		})
+	}
+}
+
+func TestFilterLayers(t *testing.T) {
+	layers := []LayerInfo{{Name: "roads", FeatureCount: 2}, {Name: "trails", FeatureCount: 1}}
+	if got := filterLayers(layers, "trails"); len(got) != 1 || got[0].Name != "trails" {
+		t.Errorf("expected only the trails layer, got %+v", got)
+	}
+	if got := filterLayers(layers, "water"); len(got) != 0 {
+		t.Errorf("expected no layers, got %+v", got)
+	}
+}
+
+func TestAnalyzeTileData_Coordinates(t *testing.T) {
+	collections := map[string]*geojson.FeatureCollection{
+		"roads": geojson.NewFeatureCollection().
+			Append(geojson.NewFeature(orb.LineString{{0, 0}, {10, 10}, {20, 0}, {30, 10}})).
+			Append(geojson.NewFeature(orb.MultiLineString{{{0, 50}, {50, 50}}, {{60, 60}, {70, 70}, {80, 60}}})),
+		"pois": geojson.NewFeatureCollection().
+			Append(geojson.NewFeature(orb.Point{5, 5})),
+	}
+	data, err := mvt.Marshal(mvt.NewLayers(collections))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layer      string
+		wantCoords int
+	}{
+		{"", 10},
+		{"roads", 9},
+		{"pois", 1},
+		{"water", 0},
+	}
+
+	for _, tt := range tests {
+		stats := newTileStats(tt.layer)
+		for _, z := range []int{12, 12, 13} {
+			if err := analyzeTileData(data, z, stats); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		wantByZoom := map[int]int{12: 2 * tt.wantCoords, 13: tt.wantCoords}
+		if tt.wantCoords == 0 {
+			wantByZoom = map[int]int{}
+		}
+		if stats.TotalCoordinates != 3*tt.wantCoords || !reflect.DeepEqual(stats.CoordinatesByZoom, wantByZoom) {
+			t.Errorf("layer %q: expected %d coordinates %v, got %d %v", tt.layer, 3*tt.wantCoords, wantByZoom,
+				stats.TotalCoordinates, stats.CoordinatesByZoom)
+		}
+		if got := stats.ByLayer["roads"].Coordinates; got != 27 {
+			t.Errorf("layer %q: expected 27 coordinates in the roads breakdown, got %d", tt.layer, got)
+		}
+	}
+}
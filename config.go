@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/paulmach/orb"
 )
 
 // Config represents the service configuration
@@ -14,16 +16,20 @@ type Config struct {
 	S3       S3Config
 	Paths    PathsConfig
 	Service  ServiceConfig
+
+	Tippecanoe *TippecanoeConfig // Tile compression and feature dropping (nil = DefaultTippecanoeConfig)
 }
 
 // DatabaseConfig represents database connection settings
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host           string
+	Port           int
+	User           string
+	Password       string
+	DBName         string
+	SSLMode        string
+	LogSQL         bool // Log each SQL statement at debug level
+	StreamPageSize int  // Rows per page when streaming roads (0 = DefaultRoadStreamPageSize)
 }
 
 // S3Config represents S3/R2 connection settings
@@ -34,19 +40,62 @@ type S3Config struct {
 	Region          string
 	Bucket          string
 	BucketPath      string // e.g., "tiles"
+	UserAgent       string // Prepended to the SDK User-Agent on every request
+
+	MetadataACL          string // Canned ACL of each region's metadata.json and manifest
+	MetadataCacheControl string // Cache-Control of each region's metadata.json and manifest
+
+	MaxAttempts int // Attempts per object upload/download, including the first (0 = DefaultRetryPolicy)
+
+	UploadConcurrency int // Files uploaded at once (0 = DefaultUploadConcurrency)
+
+	ObjectHeaders map[string]ObjectHeaders // Content-Type and Cache-Control of uploaded files by extension
 }
 
 // PathsConfig represents file system paths
 type PathsConfig struct {
 	CurvatureData string // Where KMZ files are located
+	KMZPattern    string // Glob of a region's KMZ file in CurvatureData, with {region} (empty = default names)
 	TempDir       string // Temporary working directory
 	OutputDir     string // Where generated tiles are stored
+	TippecanoeBin string // Tippecanoe command, a name on PATH or a path to the binary
+
+	TileFileMode os.FileMode // Mode for generated tile files (0 = Tippecanoe default)
+	TileDirMode  os.FileMode // Mode for generated tile directories (0 = as created)
+
+	TileScheme TileScheme // Row convention of existing tile directories read by extract
+
+	GeoJSONArchiveDir string // Where converted GeoJSON is kept, as {region}/{timestamp}.geojson
+	GeoJSONRetention  int    // Converted GeoJSON files kept per region (0 = none, deleted after the job)
 }
 
 // ServiceConfig represents service-level settings
 type ServiceConfig struct {
 	Workers     int
 	PollInterval int // seconds
+
+	MaxRoadBBoxKm int // Flag extracted roads with a larger bbox diagonal (0 or less = off)
+
+	MaxInvalidRoads  int     // Abort extraction past this many invalid roads (0 = default, <0 = never)
+	InvalidWarnRatio float64 // Warn when this share of decoded roads is invalid (0 = default, <0 = off)
+	LogCoordDecimals int     // Round coordinates in extraction diagnostics (0 or less = full precision)
+	ExtractWorkers   int     // Tiles read and decoded at once during extraction (0 = one per CPU)
+	DedupGlobal      bool    // Key roads by name and rounded end points so overlapping regions share a row
+
+	ExtractMetadataCheck MetadataCheck // On a tileset metadata mismatch: error (default), warn or off
+
+	HeartbeatInterval int // seconds between heartbeats from a processing worker
+	JobLeaseTimeout   int // seconds without a heartbeat before a job is re-queued
+
+	SSEKeepalive int // seconds between SSE ping events on job streams
+
+	ShutdownTimeout int // seconds serve waits for the running job on shutdown before cancelling it
+
+	PhaseConcurrency int // Post-generation phases (extraction, upload) run at once (0 or less = default, 1 = sequential)
+
+	DiskSpaceFactor float64 // Free space needed before generating, as a multiple of the GeoJSON size (0 = default, <0 = no check)
+
+	RegionBounds map[string]orb.Bound // Per-region box; tiles outside it are dropped after generation
 }
 
 // LoadConfig loads configuration from environment variables and .env file
@@ -73,12 +122,14 @@ func LoadConfig(envPath string) (*Config, error) {
 
 	cfg := &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvInt("DB_PORT", 5432),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			DBName:   getEnv("DB_NAME", "drivefinder"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:           getEnv("DB_HOST", "localhost"),
+			Port:           getEnvInt("DB_PORT", 5432),
+			User:           getEnv("DB_USER", "postgres"),
+			Password:       getEnv("DB_PASSWORD", ""),
+			DBName:         getEnv("DB_NAME", "drivefinder"),
+			SSLMode:        getEnv("DB_SSLMODE", "disable"),
+			LogSQL:         getEnvBool("DB_LOG_SQL", false),
+			StreamPageSize: getEnvInt("DB_STREAM_PAGE_SIZE", 0),
 		},
 		S3: S3Config{
 			Endpoint:        getEnv("S3_ENDPOINT", "https://s3.us-west-1.wasabisys.com"),
@@ -87,18 +138,81 @@ func LoadConfig(envPath string) (*Config, error) {
 			Region:          getEnv("S3_REGION", "us-west-1"),
 			Bucket:          getEnv("S3_BUCKET", "drivefinder-tiles"),
 			BucketPath:      getEnv("S3_BUCKET_PATH", "tiles"),
+			UserAgent:       getEnv("S3_USER_AGENT", "drivefinder-tile-service"),
+
+			MetadataACL:          getEnv("S3_METADATA_ACL", DefaultMetadataACL),
+			MetadataCacheControl: getEnv("S3_METADATA_CACHE_CONTROL", DefaultMetadataCacheControl),
+
+			MaxAttempts:       getEnvInt("S3_MAX_ATTEMPTS", 0),
+			UploadConcurrency: getEnvInt("S3_UPLOAD_CONCURRENCY", 0),
 		},
 		Paths: PathsConfig{
 			CurvatureData: getEnv("CURVATURE_DATA_DIR", "./curvature-data"),
 			TempDir:       getEnv("TEMP_DIR", "/tmp"),
 			OutputDir:     getEnv("OUTPUT_DIR", defaultOutputDir),
+			TippecanoeBin: getEnv("TIPPECANOE_BIN", DefaultTippecanoeBin),
+
+			GeoJSONRetention: getEnvInt("GEOJSON_RETENTION", 0),
 		},
 		Service: ServiceConfig{
-			Workers:     getEnvInt("WORKERS", 3),
-			PollInterval: getEnvInt("POLL_INTERVAL_SECONDS", 10),
+			Workers:           getEnvInt("WORKERS", 3),
+			PollInterval:      getEnvInt("POLL_INTERVAL_SECONDS", 10),
+			MaxRoadBBoxKm:     getEnvInt("MAX_ROAD_BBOX_KM", int(DefaultMaxBBoxMeters/1000)),
+			HeartbeatInterval: getEnvInt("HEARTBEAT_INTERVAL_SECONDS", 30),
+			JobLeaseTimeout:   getEnvInt("JOB_LEASE_TIMEOUT_SECONDS", 300),
+			SSEKeepalive:      getEnvInt("SSE_KEEPALIVE_SECONDS", 30),
+			ShutdownTimeout:   getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 300),
+			PhaseConcurrency:  getEnvInt("PHASE_CONCURRENCY", 0),
+			DiskSpaceFactor:   getEnvFloat("DISK_SPACE_FACTOR", 0),
+			MaxInvalidRoads:   getEnvInt("EXTRACT_MAX_INVALID_ROADS", 0),
+			InvalidWarnRatio:  getEnvFloat("EXTRACT_INVALID_WARN_RATIO", 0),
+			LogCoordDecimals:  getEnvInt("EXTRACT_LOG_COORD_DECIMALS", 0),
+			ExtractWorkers:    getEnvInt("EXTRACT_WORKERS", 0),
+			DedupGlobal:       getEnvBool("EXTRACT_DEDUP_GLOBAL", false),
 		},
 	}
 
+	var err error
+	if cfg.Paths.TileFileMode, err = parseFileMode(os.Getenv("TILE_FILE_MODE")); err != nil {
+		return nil, fmt.Errorf("invalid TILE_FILE_MODE: %w", err)
+	}
+	if cfg.Paths.TileDirMode, err = parseDirMode(os.Getenv("TILE_DIR_MODE")); err != nil {
+		return nil, fmt.Errorf("invalid TILE_DIR_MODE: %w", err)
+	}
+	cfg.Paths.KMZPattern = os.Getenv("KMZ_PATTERN")
+	if err := validateKMZPattern(cfg.Paths.KMZPattern); err != nil {
+		return nil, fmt.Errorf("invalid KMZ_PATTERN: %w", err)
+	}
+	if cfg.Paths.TileScheme, err = ParseTileScheme(os.Getenv("TILE_SCHEME")); err != nil {
+		return nil, fmt.Errorf("invalid TILE_SCHEME: %w", err)
+	}
+	if cfg.Service.ExtractMetadataCheck, err = ParseMetadataCheck(os.Getenv("EXTRACT_METADATA_CHECK")); err != nil {
+		return nil, fmt.Errorf("invalid EXTRACT_METADATA_CHECK: %w", err)
+	}
+	if cfg.S3.ObjectHeaders, err = parseObjectHeaders(os.Getenv("S3_CONTENT_TYPES"), os.Getenv("S3_CACHE_CONTROL")); err != nil {
+		return nil, fmt.Errorf("invalid S3_CONTENT_TYPES or S3_CACHE_CONTROL: %w", err)
+	}
+	if cfg.Service.RegionBounds, err = parseRegionBounds(os.Getenv("REGION_BOUNDS")); err != nil {
+		return nil, fmt.Errorf("invalid REGION_BOUNDS: %w", err)
+	}
+	if err := validateCannedACL(cfg.S3.MetadataACL); err != nil {
+		return nil, fmt.Errorf("invalid S3_METADATA_ACL: %w", err)
+	}
+	if cfg.S3.MaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid S3_MAX_ATTEMPTS: %d (must be at least 1, or 0 for the default)", cfg.S3.MaxAttempts)
+	}
+	if cfg.S3.UploadConcurrency < 0 {
+		return nil, fmt.Errorf("invalid S3_UPLOAD_CONCURRENCY: %d (must be at least 1, or 0 for the default)", cfg.S3.UploadConcurrency)
+	}
+	if cfg.Paths.GeoJSONRetention < 0 {
+		return nil, fmt.Errorf("invalid GEOJSON_RETENTION: %d (must be at least 1, or 0 to keep none)", cfg.Paths.GeoJSONRetention)
+	}
+	if cfg.Tippecanoe, err = loadTippecanoeConfig(); err != nil {
+		return nil, err
+	}
+	// Kept next to the tiles directory by default (~/data/df/geojson)
+	cfg.Paths.GeoJSONArchiveDir = getEnv("GEOJSON_ARCHIVE_DIR", filepath.Join(filepath.Dir(cfg.Paths.OutputDir), "geojson"))
+
 	// Validate required config
 	if cfg.Database.Password == "" {
 		return nil, fmt.Errorf("DB_PASSWORD environment variable is required")
@@ -109,6 +223,61 @@ func LoadConfig(envPath string) (*Config, error) {
 	return cfg, nil
 }
 
+// loadTippecanoeConfig reads the Tippecanoe settings, defaulting each one
+// to DefaultTippecanoeConfig:
+//
+//	TIPPECANOE_COMPRESS                      gzip tiles (default false)
+//	TIPPECANOE_DROP_DENSEST_AS_NEEDED        drop features from tiles over a limit (default true)
+//	TIPPECANOE_EXTEND_ZOOMS                  add zooms while still dropping (default true)
+//	TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH   truncate strings (default 1000, 0 = Tippecanoe's default)
+//	TIPPECANOE_EXTRA_ARGS                    space-separated extra arguments
+func loadTippecanoeConfig() (*TippecanoeConfig, error) {
+	def := DefaultTippecanoeConfig
+	tc := &TippecanoeConfig{
+		Compress:                 getEnvBool("TIPPECANOE_COMPRESS", def.Compress),
+		DropDensest:              getEnvBool("TIPPECANOE_DROP_DENSEST_AS_NEEDED", def.DropDensest),
+		ExtendZooms:              getEnvBool("TIPPECANOE_EXTEND_ZOOMS", def.ExtendZooms),
+		MaxStringAttributeLength: getEnvInt("TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH", def.MaxStringAttributeLength),
+	}
+	if extra := os.Getenv("TIPPECANOE_EXTRA_ARGS"); extra != "" {
+		tc.ExtraArgs = strings.Fields(extra)
+	}
+	if tc.MaxStringAttributeLength < 0 {
+		return nil, fmt.Errorf("invalid TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH: %d (must be positive, or 0 for Tippecanoe's default)", tc.MaxStringAttributeLength)
+	}
+	return tc, nil
+}
+
+// parseFileMode parses an octal permission string such as "0644". An empty
+// string yields 0, meaning "leave permissions alone".
+func parseFileMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an octal file mode", value)
+	}
+	if mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("%q must be a permission mode between 0001 and 0777", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseDirMode parses a directory permission string like parseFileMode. The
+// mode must let the owner search the directory (u+x); without it the
+// service could not walk or clean up the tiles it wrote.
+func parseDirMode(value string) (os.FileMode, error) {
+	mode, err := parseFileMode(value)
+	if err != nil {
+		return 0, err
+	}
+	if mode != 0 && mode&0100 == 0 {
+		return 0, fmt.Errorf("%q must let the owner search directories (u+x, e.g. 0750)", value)
+	}
+	return mode, nil
+}
+
 // loadEnvFile loads environment variables from a .env file
 func loadEnvFile(path string) error {
 	content, err := os.ReadFile(path)
@@ -153,3 +322,23 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// getEnvFloat gets an environment variable as a float with a default value
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultVal
+}
+
+// getEnvBool gets an environment variable as a boolean with a default value
+func getEnvBool(key string, defaultVal bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultVal
+}
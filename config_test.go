@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0644", 0644, false},
+		{"640", 0640, false},
+		{"0750", 0750, false},
+		{"0999", 0, true},
+		{"rw-r--r--", 0, true},
+		{"0", 0, true},
+		{"01777", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFileMode(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFileMode(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseFileMode(%q) = %o, want %o", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDirMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0750", 0750, false},
+		{"0711", 0711, false},
+		{"0644", 0, true},
+		{"0650", 0, true},
+		{"0999", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDirMode(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDirMode(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDirMode(%q) = %o, want %o", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTippecanoeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    TippecanoeConfig
+		wantErr bool
+	}{
+		{"defaults", nil, DefaultTippecanoeConfig, false},
+		{
+			"all set",
+			map[string]string{
+				"TIPPECANOE_COMPRESS":                    "true",
+				"TIPPECANOE_DROP_DENSEST_AS_NEEDED":      "false",
+				"TIPPECANOE_EXTEND_ZOOMS":                "false",
+				"TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH": "0",
+				"TIPPECANOE_EXTRA_ARGS":                  " --coalesce  --simplification=4 ",
+			},
+			TippecanoeConfig{Compress: true, ExtraArgs: []string{"--coalesce", "--simplification=4"}},
+			false,
+		},
+		{"negative string length", map[string]string{"TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH": "-1"}, TippecanoeConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"TIPPECANOE_COMPRESS", "TIPPECANOE_DROP_DENSEST_AS_NEEDED", "TIPPECANOE_EXTEND_ZOOMS", "TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH", "TIPPECANOE_EXTRA_ARGS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := loadTippecanoeConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
@@ -12,12 +12,68 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/paulmach/orb"
 )
 
+// ConvertOptions configures KML to GeoJSON conversion
+type ConvertOptions struct {
+	Clip             orb.MultiPolygon // Keep only the parts of roads inside this boundary (nil = no clipping)
+	PreserveAltitude bool             // Keep KML altitude as a third coordinate ([lng, lat, alt])
+}
+
+// kmlDocument is the part of a curvature KML file the pipeline reads. The
+// structure is: kml > Document > Folder > Placemark > LineString, where each
+// Folder represents one road with multiple Placemarks (road segments). Some
+// exports have no folders and put Placemarks directly under Document.
+// Note: KML files use the namespace "http://www.opengis.net/kml/2.2" - must be specified on ALL elements
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document struct {
+		Folders    []kmlFolder    `xml:"http://www.opengis.net/kml/2.2 Folder"`
+		Placemarks []kmlPlacemark `xml:"http://www.opengis.net/kml/2.2 Placemark"`
+	} `xml:"http://www.opengis.net/kml/2.2 Document"`
+}
+
+// kmlFolder is one road made of one or more Placemark segments
+type kmlFolder struct {
+	Name        string         `xml:"http://www.opengis.net/kml/2.2 name"`
+	Description string         `xml:"http://www.opengis.net/kml/2.2 description"`
+	Placemarks  []kmlPlacemark `xml:"http://www.opengis.net/kml/2.2 Placemark"`
+}
+
+// kmlPlacemark is one road segment
+type kmlPlacemark struct {
+	Name        string `xml:"http://www.opengis.net/kml/2.2 name"`
+	Description string `xml:"http://www.opengis.net/kml/2.2 description"`
+	LineString  struct {
+		Coordinates string `xml:"http://www.opengis.net/kml/2.2 coordinates"`
+	} `xml:"http://www.opengis.net/kml/2.2 LineString"`
+}
+
+// roads returns the document's roads: each Folder, then each Placemark
+// directly under Document as a single-segment road with its own name
+func (d *kmlDocument) roads() []kmlFolder {
+	roads := make([]kmlFolder, 0, len(d.Document.Folders)+len(d.Document.Placemarks))
+	roads = append(roads, d.Document.Folders...)
+	for _, pm := range d.Document.Placemarks {
+		roads = append(roads, kmlFolder{Name: pm.Name, Description: pm.Description, Placemarks: []kmlPlacemark{pm}})
+	}
+	return roads
+}
+
 // ConvertKMLToGeoJSON converts a KML file to GeoJSON format
 func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, int, error) {
+	return ConvertKMLToGeoJSONWithOptions(ctx, kmlPath, region, nil)
+}
+
+// ConvertKMLToGeoJSONWithOptions converts a KML file to GeoJSON, optionally
+// clipping roads to a boundary polygon
+func ConvertKMLToGeoJSONWithOptions(ctx context.Context, kmlPath, region string, opts *ConvertOptions) (string, int, error) {
 	logger := slog.With("kml_path", kmlPath, "region", region)
 	logger.Info("converting KML to GeoJSON")
 
@@ -27,37 +83,21 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 		return "", 0, fmt.Errorf("failed to read KML file: %w", err)
 	}
 
-	// Parse KML - structure is: kml > Document > Folder > Placemark > LineString
-	// Each Folder represents one road with multiple Placemarks (road segments)
-	// Note: KML files use the namespace "http://www.opengis.net/kml/2.2" - must be specified on ALL elements
-	var doc struct {
-		XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
-		Document struct {
-			Folders []struct {
-				Name        string `xml:"http://www.opengis.net/kml/2.2 name"`
-				Description string `xml:"http://www.opengis.net/kml/2.2 description"`
-				Placemarks  []struct {
-					Name       string `xml:"http://www.opengis.net/kml/2.2 name"`
-					LineString struct {
-						Coordinates string `xml:"http://www.opengis.net/kml/2.2 coordinates"`
-					} `xml:"http://www.opengis.net/kml/2.2 LineString"`
-				} `xml:"http://www.opengis.net/kml/2.2 Placemark"`
-			} `xml:"http://www.opengis.net/kml/2.2 Folder"`
-		} `xml:"http://www.opengis.net/kml/2.2 Document"`
-	}
-
+	var doc kmlDocument
 	if err := xml.Unmarshal(kmlContent, &doc); err != nil {
 		return "", 0, fmt.Errorf("failed to parse KML: %w", err)
 	}
 
-	logger.Debug("KML parsed", "folders", len(doc.Document.Folders))
+	logger.Debug("KML parsed", "folders", len(doc.Document.Folders), "top_level_placemarks", len(doc.Document.Placemarks))
 
 	// Build GeoJSON FeatureCollection
 	features := make([]map[string]interface{}, 0)
 	roadCount := 0
+	var clipStats ClipStats
+	unparsedBlocks := 0
 
 	// Process each Folder (each folder = one road with multiple segments)
-	for _, folder := range doc.Document.Folders {
+	for _, folder := range doc.roads() {
 		// Get folder name with fallback
 		folderName := folder.Name
 		if folderName == "" {
@@ -70,11 +110,19 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 
 		for _, pm := range folder.Placemarks {
 			// Extract LineString coordinates
-			if pm.LineString.Coordinates == "" {
+			if strings.TrimSpace(pm.LineString.Coordinates) == "" {
 				continue
 			}
 
-			coords := parseKMLCoordinates(pm.LineString.Coordinates)
+			coords := parseKMLCoordinatesWithOptions(pm.LineString.Coordinates, opts != nil && opts.PreserveAltitude)
+			if len(coords) == 0 {
+				unparsedBlocks++
+				logger.Warn("KML coordinate block yielded no points",
+					"road", folderName,
+					"sample", truncateForLog(pm.LineString.Coordinates, 80),
+				)
+				continue
+			}
 			if len(coords) < 2 {
 				continue
 			}
@@ -87,6 +135,22 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 			continue
 		}
 
+		// The UUID is anchored to the unclipped start so a trimmed road keeps its id
+		idLng, idLat := lineStrings[0][0][0], lineStrings[0][0][1]
+
+		if opts != nil && opts.Clip != nil {
+			clipped, removed := clipRoadLines(lineStrings, opts.Clip)
+			clipStats.MetersRemoved += removed
+			if len(clipped) == 0 {
+				clipStats.RoadsDropped++
+				continue
+			}
+			if removed > 0 {
+				clipStats.RoadsTrimmed++
+			}
+			lineStrings = clipped
+		}
+
 		// Create ONE feature per road (folder) with MultiLineString geometry
 		var geometry map[string]interface{}
 		if len(lineStrings) == 1 {
@@ -106,6 +170,8 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 		// Calculate road metrics
 		roadLength := calculateRoadLength(geometry)
 		startLat, startLng, endLat, endLng, hasPoints := extractStartEndPoints(geometry)
+		// Only the curvature is kept from the description, which is mostly
+		// HTML and would bloat the tiles
 		curvature := parseCurvature(folder.Description)
 
 		// Generate deterministic UUID based on region + start coordinates
@@ -114,7 +180,7 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 		if hasPoints {
 			// Create deterministic UUID v5 using region + start coords
 			namespace := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8") // DNS namespace
-			name := fmt.Sprintf("%s:%.6f,%.6f", region, startLat, startLng)
+			name := fmt.Sprintf("%s:%.6f,%.6f", region, idLat, idLng)
 			roadUUID = uuid.NewSHA1(namespace, []byte(name)).String()
 		} else {
 			// Fallback to random UUID if no coordinates available
@@ -147,6 +213,19 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 	}
 
 	logger.Info("features extracted from KML", "count", len(features))
+	if len(features) == 0 {
+		logger.Warn("no roads with coordinates found in KML")
+	}
+	if unparsedBlocks > 0 {
+		logger.Warn("KML coordinate blocks skipped", "count", unparsedBlocks)
+	}
+	if opts != nil && opts.Clip != nil {
+		logger.Info("roads clipped to boundary",
+			"roads_dropped", clipStats.RoadsDropped,
+			"roads_trimmed", clipStats.RoadsTrimmed,
+			"km_removed", math.Round(clipStats.MetersRemoved/100)/10,
+		)
+	}
 
 	// Create GeoJSON FeatureCollection
 	featureCollection := map[string]interface{}{
@@ -155,14 +234,14 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 	}
 
 	// Write to file
-	geoJSONPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s_roads.geojson", region))
 	geoJSONBytes, err := json.Marshal(featureCollection)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to marshal GeoJSON: %w", err)
 	}
 
-	if err := os.WriteFile(geoJSONPath, geoJSONBytes, 0644); err != nil {
-		return "", 0, fmt.Errorf("failed to write GeoJSON file: %w", err)
+	geoJSONPath, err := writeIntermediateGeoJSON(region, geoJSONBytes)
+	if err != nil {
+		return "", 0, err
 	}
 
 	logger.Info("GeoJSON created", "path", geoJSONPath, "size_bytes", len(geoJSONBytes))
@@ -170,13 +249,115 @@ func ConvertKMLToGeoJSON(ctx context.Context, kmlPath, region string) (string, i
 	return geoJSONPath, len(features), nil
 }
 
+// ConvertFileToGeoJSON converts a KML file, or a KMZ after extracting it, to
+// GeoJSON at outputPath and returns the number of features written
+func ConvertFileToGeoJSON(ctx context.Context, inputPath, outputPath string) (int, error) {
+	region := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+
+	kmlPath := inputPath
+	if strings.EqualFold(filepath.Ext(inputPath), ".kmz") {
+		extracted, err := ExtractKMZFile(ctx, inputPath, region)
+		if err != nil {
+			return 0, err
+		}
+		defer CleanupTemporaryFiles(ctx, extracted, "", "")
+		kmlPath = extracted
+	}
+
+	geoJSONPath, count, err := ConvertKMLToGeoJSON(ctx, kmlPath, region)
+	if err != nil {
+		return 0, err
+	}
+	if err := moveFile(geoJSONPath, outputPath); err != nil {
+		os.Remove(geoJSONPath)
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return count, nil
+}
+
+// moveFile moves src to dst, copying when a rename is not possible (e.g.
+// across filesystems)
+func moveFile(src, dst string) error {
+	if absSrc, err := filepath.Abs(src); err == nil {
+		if absDst, err := filepath.Abs(dst); err == nil && absSrc == absDst {
+			return nil
+		}
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// intermediateGeoJSONPattern names the files conversion writes a region's
+// GeoJSON to, {region}_roads-{random}.geojson in the system temp directory.
+// They are removed after generation unless -no-cleanup is set.
+func intermediateGeoJSONPattern(region string) string {
+	return fmt.Sprintf("%s_roads-*.geojson", region)
+}
+
+// writeIntermediateGeoJSON writes a region's converted GeoJSON to a new
+// intermediate file and returns its path. Every conversion gets its own file,
+// so a generate and a report of the same region never overwrite each other.
+func writeIntermediateGeoJSON(region string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", intermediateGeoJSONPattern(region))
+	if err != nil {
+		return "", fmt.Errorf("failed to create GeoJSON file: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp makes the file private; keep the GeoJSON readable like before
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write GeoJSON file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// latestIntermediateGeoJSON returns the newest intermediate GeoJSON of a
+// region left in the system temp directory, such as one kept by
+// generate -no-cleanup, or "" if there is none
+func latestIntermediateGeoJSON(region string) string {
+	matches, err := filepath.Glob(filepath.Join(os.TempDir(), intermediateGeoJSONPattern(region)))
+	if err != nil {
+		return ""
+	}
+	latest, latestTime := "", time.Time{}
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = path, info.ModTime()
+		}
+	}
+	return latest
+}
+
 // parseKMLCoordinates parses KML coordinate string into [[lng, lat], ...] format
 // KML format: "lng,lat,elev lng,lat,elev ..." (space-separated, comma-separated inner)
 func parseKMLCoordinates(coordString string) [][]float64 {
+	return parseKMLCoordinatesWithOptions(coordString, false)
+}
+
+// parseKMLCoordinatesWithOptions parses a KML coordinate string, keeping the
+// altitude as [lng, lat, alt] when preserveAltitude is set. Tuples without an
+// altitude (or with an unparseable one) get 0, so every point has the same
+// dimension.
+func parseKMLCoordinatesWithOptions(coordString string, preserveAltitude bool) [][]float64 {
 	var coordinates [][]float64
 
-	// Split by space to get individual coordinate triples
-	parts := strings.Fields(strings.TrimSpace(coordString))
+	// Split by whitespace to get individual coordinate triples
+	parts := strings.Fields(normalizeKMLCoordinates(coordString))
 
 	for _, part := range parts {
 		if part == "" {
@@ -196,13 +377,59 @@ func parseKMLCoordinates(coordString string) [][]float64 {
 			continue
 		}
 
-		// GeoJSON format is [lng, lat]
+		// Skip values that are not a position on Earth (NaN, Inf, out of range)
+		if !(lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90) {
+			continue
+		}
+
+		// GeoJSON format is [lng, lat] or [lng, lat, alt]
+		if preserveAltitude {
+			alt := 0.0
+			if len(values) > 2 {
+				if v, err := strconv.ParseFloat(values[2], 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+					alt = v
+				}
+			}
+			coordinates = append(coordinates, []float64{lng, lat, alt})
+			continue
+		}
 		coordinates = append(coordinates, []float64{lng, lat})
 	}
 
 	return coordinates
 }
 
+// kmlCommaSpace matches a tuple comma with whitespace around it ("lng, lat")
+var kmlCommaSpace = regexp.MustCompile(` *, *`)
+
+// normalizeKMLCoordinates strips CDATA markers left in a coordinate string and
+// turns every whitespace variant (non-breaking, zero-width, BOM) into a plain
+// space, so tuples split on whitespace and never inside "lng, lat"
+func normalizeKMLCoordinates(coordString string) string {
+	s := strings.ReplaceAll(coordString, "<![CDATA[", " ")
+	s = strings.ReplaceAll(s, "]]>", " ")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\u200b' || r == '\ufeff' {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(kmlCommaSpace.ReplaceAllString(s, ","))
+}
+
+// truncateForLog shortens s to at most n bytes for a log field, without
+// splitting a UTF-8 character
+func truncateForLog(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
 // haversineDistance calculates distance between two points in meters
 func haversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	const earthRadius = 6371000.0 // meters
@@ -299,8 +526,14 @@ func extractStartEndPoints(geometry map[string]interface{}) (startLat, startLng,
 	return 0, 0, 0, 0, false
 }
 
+// curvatureLabelPattern matches "curvature: 1000", "<b>Curvature:</b> 1,234.5"
+// and similar, as written in the folder descriptions of curvature KML exports
+var curvatureLabelPattern = regexp.MustCompile(`(?i)curvature:\s*(?:<[^>]*>\s*)*(\d[\d,]*(?:\.\d+)?)`)
+
 // parseCurvature extracts curvature value from KML description
-// Looks for patterns like "c_1000" or "curvature: 1000"
+// Looks for patterns like "c_1000" or "curvature: 1000". Thousands separators
+// are dropped and fractional values rounded, so the result is always a whole
+// number.
 func parseCurvature(description string) *string {
 	if description == "" {
 		return nil
@@ -313,9 +546,13 @@ func parseCurvature(description string) *string {
 	}
 
 	// Look for "curvature: XXX" pattern
-	re2 := regexp.MustCompile(`curvature:\s*(\d+)`)
-	if matches := re2.FindStringSubmatch(description); len(matches) > 1 {
-		return &matches[1]
+	if matches := curvatureLabelPattern.FindStringSubmatch(description); len(matches) > 1 {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(matches[1], ",", ""), 64)
+		if err != nil {
+			return nil
+		}
+		curvature := strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+		return &curvature
 	}
 
 	return nil
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/paulmach/orb/geojson"
 )
 
 func TestHaversineDistance(t *testing.T) {
@@ -303,6 +313,16 @@ func TestParseCurvature(t *testing.T) {
 			description: "",
 			expected:    nil,
 		},
+		{
+			name:        "capitalised label with separators and decimals",
+			description: "<b>Curvature:</b> 1,234.6<br/>Length: 5.2 km",
+			expected:    stringPtr("1235"),
+		},
+		{
+			name:        "label without a number",
+			description: "Curvature: unknown",
+			expected:    nil,
+		},
 		{
 			name:        "c_ pattern takes precedence",
 			description: "c_2000 and curvature: 1000",
@@ -328,3 +348,427 @@ func TestParseCurvature(t *testing.T) {
 		})
 	}
 }
+
+func TestParseKMLCoordinates(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]float64
+	}{
+		{"with elevation", "-122.5,45.5,0 -122.4,45.6,12.5", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+		{"newlines and tabs", "\n\t\t-122.5,45.5\n\t\t-122.4,45.6\n", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+		{"malformed entries skipped", "-122.5 abc,45 -122.4,45.6", [][]float64{{-122.4, 45.6}}},
+		{"out of range skipped", "200,45 -122,95 -122.4,45.6", [][]float64{{-122.4, 45.6}}},
+		{"non-finite skipped", "NaN,45 -122,Inf -122.4,45.6", [][]float64{{-122.4, 45.6}}},
+		{"empty", "   ", nil},
+		{"CDATA wrapped", "<![CDATA[-122.5,45.5,0 -122.4,45.6,0]]>", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+		{"CDATA with padding", "\n  <![CDATA[\n-122.5,45.5\n]]>\n", [][]float64{{-122.5, 45.5}}},
+		{"non-breaking spaces", "-122.5,45.5,0\u00a0-122.4,45.6,0\u202f-122.3,45.7,0", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}, {-122.3, 45.7}}},
+		{"zero-width and BOM separators", "\ufeff-122.5,45.5\u200b-122.4,45.6", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+		{"spaces after commas", "-122.5, 45.5, 0  -122.4 ,45.6", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+		{"CRLF line endings", "-122.5,45.5,0\r\n-122.4,45.6,0\r\n", [][]float64{{-122.5, 45.5}, {-122.4, 45.6}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseKMLCoordinates(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseKMLCoordinates(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for i := range got {
+				if got[i][0] != tt.want[i][0] || got[i][1] != tt.want[i][1] {
+					t.Errorf("point %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseKMLCoordinates_PreserveAltitude(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]float64
+	}{
+		{"altitudes kept", "-122.5,45.5,120.5 -122.4,45.6,-3", [][]float64{{-122.5, 45.5, 120.5}, {-122.4, 45.6, -3}}},
+		{"missing altitude is zero", "-122.5,45.5 -122.4,45.6,80", [][]float64{{-122.5, 45.5, 0}, {-122.4, 45.6, 80}}},
+		{"unparseable altitude is zero", "-122.5,45.5,high -122.4,45.6,NaN", [][]float64{{-122.5, 45.5, 0}, {-122.4, 45.6, 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseKMLCoordinatesWithOptions(tt.input, true)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if len(got[i]) != 3 || got[i][0] != tt.want[i][0] || got[i][1] != tt.want[i][1] || got[i][2] != tt.want[i][2] {
+					t.Errorf("point %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// Without the option the altitude is dropped as before
+	if got := parseKMLCoordinates("-122.5,45.5,120.5"); len(got) != 1 || len(got[0]) != 2 {
+		t.Errorf("expected a 2D point, got %v", got)
+	}
+}
+
+func TestConvertKMLToGeoJSON_PreserveAltitude(t *testing.T) {
+	kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Mountain Road</name><Placemark><LineString><coordinates>-122.40,47.55,100 -122.30,47.60,250 -122.20,47.65,400</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(kml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	region := "altitude-" + filepath.Base(t.TempDir())
+
+	flatPath, _, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat := readFeatureCollection(t, flatPath)
+
+	path, _, err := ConvertKMLToGeoJSONWithOptions(context.Background(), kmlPath, region, &ConvertOptions{PreserveAltitude: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	fc := readFeatureCollection(t, path)
+
+	var raw struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates [][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	coords := raw.Features[0].Geometry.Coordinates
+	if len(coords) != 3 || len(coords[1]) != 3 || coords[1][2] != 250 {
+		t.Fatalf("expected altitude in the GeoJSON coordinates, got %v", coords)
+	}
+
+	// 2D properties are unaffected by the altitude
+	for _, key := range []string{"length", "startLat", "startLng", "endLat", "endLng"} {
+		if fc.Features[0].Properties[key] != flat.Features[0].Properties[key] {
+			t.Errorf("%s = %v, want %v as without altitude", key, fc.Features[0].Properties[key], flat.Features[0].Properties[key])
+		}
+	}
+
+	// The 2D tile pipeline still accepts the 3D GeoJSON
+	_, tilesCount, _, err := GenerateTilesWithOptions(context.Background(), path, region, t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tilesCount == 0 {
+		t.Error("expected tiles from the 3D GeoJSON")
+	}
+}
+
+// readFeatureCollection parses a GeoJSON file written by the converter
+func readFeatureCollection(t *testing.T, path string) *geojson.FeatureCollection {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+func FuzzParseKMLCoordinates(f *testing.F) {
+	for _, seed := range []string{
+		// As exported by roadcurvature.com
+		"-122.6765,45.5231,0 -122.6770,45.5235,0 -122.6781,45.5240,0",
+		"\n\t\t\t\t-121.0834891,44.0582017,0\n\t\t\t\t-121.0836321,44.0583402,0\n\t\t\t",
+		"-122.5,45.5",
+		"-122.5,45.5,0,extra",
+		"1e308,1e308",
+		"NaN,NaN Inf,-Inf",
+		",,, , ,",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		coords := parseKMLCoordinates(input)
+		if len(coords) > len(strings.Fields(input)) {
+			t.Fatalf("parseKMLCoordinates(%q) returned %d points from %d fields", input, len(coords), len(strings.Fields(input)))
+		}
+		for _, c := range coords {
+			if len(c) != 2 {
+				t.Fatalf("parseKMLCoordinates(%q) returned point %v", input, c)
+			}
+			lng, lat := c[0], c[1]
+			if !(lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90) {
+				t.Fatalf("parseKMLCoordinates(%q) returned out-of-range point %v", input, c)
+			}
+		}
+	})
+}
+
+func TestConvertKMLToGeoJSON_CoordinateVariants(t *testing.T) {
+	kml := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+		`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>CDATA Road</name><Placemark><LineString><coordinates><![CDATA[-122.5,45.5,0 -122.4,45.6,0]]></coordinates></LineString></Placemark></Folder>
+<Folder><name>NBSP Road</name><Placemark><LineString><coordinates>-122.5,45.5,0` + "\u00a0" + `-122.4,45.6,0</coordinates></LineString></Placemark></Folder>
+<Folder><name>Broken Road</name><Placemark><LineString><coordinates>n/a</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(kml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	path, count, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, "variants-"+filepath.Base(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	if count != 2 {
+		t.Errorf("expected the CDATA and NBSP roads to convert, got %d roads", count)
+	}
+	if !strings.Contains(logs.String(), `msg="KML coordinate block yielded no points" kml_path=`) || !strings.Contains(logs.String(), `road="Broken Road" sample=n/a`) {
+		t.Errorf("expected a warning for the unparseable block, got:\n%s", logs.String())
+	}
+}
+
+func TestConvertKMLToGeoJSON_Curvature(t *testing.T) {
+	t.Chdir(t.TempDir())
+	kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Twisty Road</name><description><![CDATA[<b>Curvature:</b> 1,234.6<br/>Length: 5.2 km]]></description><Placemark><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0 -122.20,47.65,0</coordinates></LineString></Placemark></Folder>
+<Folder><name>Plain Road</name><Placemark><LineString><coordinates>-122.40,47.45,0 -122.30,47.50,0</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(kml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	region := "curvature-" + filepath.Base(t.TempDir())
+
+	path, _, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	// Only the parsed number is kept, not the description
+	fc := readFeatureCollection(t, path)
+	if len(fc.Features) != 2 {
+		t.Fatalf("expected 2 roads, got %d", len(fc.Features))
+	}
+	twisty, plain := fc.Features[0].Properties, fc.Features[1].Properties
+	if twisty["curvature"] != "1235" {
+		t.Errorf("expected curvature 1235, got %v", twisty["curvature"])
+	}
+	if _, ok := plain["curvature"]; ok {
+		t.Errorf("expected no curvature without a description, got %v", plain["curvature"])
+	}
+	for _, props := range []geojson.Properties{twisty, plain} {
+		if _, ok := props["description"]; ok {
+			t.Error("expected the description to be left out")
+		}
+	}
+
+	// The curvature survives into the tiles and back out of extraction
+	tilesDir, _, _, err := GenerateTilesWithOptions(context.Background(), path, region, t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 8, MaxZoom: 10, Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roads, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(tilesDir), region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, road := range roads {
+		if road.Name == "Twisty Road" {
+			found = road.Curvature != nil && *road.Curvature == "1235"
+		}
+	}
+	if !found {
+		t.Errorf("expected Twisty Road to be extracted with curvature 1235, got %+v", roads)
+	}
+}
+
+func TestConvertFileToGeoJSON(t *testing.T) {
+	kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Test Road</name><Placemark><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0 -122.20,47.65,0</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+
+	tests := []struct {
+		name  string
+		input func(dir string) string
+	}{
+		{"kml", func(dir string) string {
+			path := filepath.Join(dir, "oregon.kml")
+			if err := os.WriteFile(path, []byte(kml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}},
+		{"kmz", func(dir string) string {
+			writeKMZ(t, dir, "us-oregon", kml)
+			return filepath.Join(dir, "us-oregon.c_1000.curves.kmz")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			input := tt.input(t.TempDir())
+			output := filepath.Join(t.TempDir(), "roads.geojson")
+
+			count, err := ConvertFileToGeoJSON(context.Background(), input, output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("expected 1 feature, got %d", count)
+			}
+
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fc, err := geojson.UnmarshalFeatureCollection(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fc.Features) != 1 || fc.Features[0].Properties["Name"] != "Test Road" {
+				t.Errorf("unexpected features %+v", fc.Features)
+			}
+
+			// The intermediate GeoJSON and any KMZ extraction are cleaned up
+			if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+				t.Errorf("expected no temporary files left, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestConvertFileToGeoJSON_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notKMZ := filepath.Join(dir, "broken.kmz")
+	if err := os.WriteFile(notKMZ, []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{filepath.Join(dir, "missing.kml"), notKMZ} {
+		if _, err := ConvertFileToGeoJSON(context.Background(), input, filepath.Join(dir, "out.geojson")); err == nil {
+			t.Errorf("expected an error converting %s", input)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.geojson")); !os.IsNotExist(err) {
+		t.Error("expected no output file after a failed conversion")
+	}
+}
+
+func TestConvertKMLToGeoJSON_TopLevelPlacemarks(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantNames []string
+		wantTypes []string
+	}{
+		{
+			name: "placemarks only",
+			body: `<Placemark><name>Bare Road</name><description>Curvature: 800</description><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0</coordinates></LineString></Placemark>
+<Placemark><LineString><coordinates>-122.20,47.55,0 -122.10,47.60,0</coordinates></LineString></Placemark>
+<Placemark><name>No Geometry</name></Placemark>`,
+			wantNames: []string{"Bare Road", "Road_0"},
+			wantTypes: []string{"LineString", "LineString"},
+		},
+		{
+			// Folders still merge their segments; bare placemarks stay single roads
+			name: "folders and placemarks",
+			body: `<Folder><name>Folder Road</name><Placemark><LineString><coordinates>-122.40,47.45,0 -122.30,47.50,0</coordinates></LineString></Placemark><Placemark><LineString><coordinates>-122.30,47.50,0 -122.20,47.52,0</coordinates></LineString></Placemark></Folder>
+<Placemark><name>Bare Road</name><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0</coordinates></LineString></Placemark>`,
+			wantNames: []string{"Folder Road", "Bare Road"},
+			wantTypes: []string{"MultiLineString", "LineString"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+` + tt.body + `
+</Document></kml>`
+			kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+			if err := os.WriteFile(kmlPath, []byte(kml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			path, count, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, "placemarks-"+filepath.Base(t.TempDir()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+
+			if count != len(tt.wantNames) {
+				t.Fatalf("expected %d roads, got %d", len(tt.wantNames), count)
+			}
+			fc := readFeatureCollection(t, path)
+			for i, f := range fc.Features {
+				if f.Properties["Name"] != tt.wantNames[i] || f.Geometry.GeoJSONType() != tt.wantTypes[i] {
+					t.Errorf("feature %d: expected %s %q, got %s %v", i, tt.wantTypes[i], tt.wantNames[i], f.Geometry.GeoJSONType(), f.Properties["Name"])
+				}
+			}
+			if tt.name == "placemarks only" && fc.Features[0].Properties["curvature"] != "800" {
+				t.Errorf("expected the placemark's own description to give the curvature, got %v", fc.Features[0].Properties["curvature"])
+			}
+
+			// The report counts the same roads the converter produced
+			counts, err := countKML(kmlPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counts.Folders < count {
+				t.Errorf("expected the KML count to include bare placemarks, got %d roads for %d features", counts.Folders, count)
+			}
+		})
+	}
+}
+
+func TestTruncateForLog(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"  short  ", 10, "short"},
+		{"abcdef", 3, "abc..."},
+		{"Route du Col de l'Iséran", 21, "Route du Col de l'Is..."},
+		{"Route du Col de l'Iséran", 22, "Route du Col de l'Isé..."},
+		{"日本", 2, "..."},
+	}
+	for _, tt := range tests {
+		got := truncateForLog(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncateForLog(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -3,18 +3,37 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"math"
+	"net"
+	"reflect"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Database wraps database operations
 type Database struct {
-	conn *sql.DB
+	conn           *sql.DB
+	logSQL         bool        // Log each statement and its args at debug level
+	reconnect      RetryPolicy // Retries of writes while the database is unreachable (zero value = DefaultReconnectPolicy)
+	streamPageSize int         // Rows per StreamRoadGeometries page (<= 0 = DefaultRoadStreamPageSize)
+}
+
+// DefaultReconnectPolicy retries a write for roughly 15 seconds of database
+// outage before giving up
+var DefaultReconnectPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    8 * time.Second,
 }
 
 // NewDatabase creates a new database connection
@@ -43,7 +62,7 @@ func NewDatabase(cfg DatabaseConfig) (*Database, error) {
 
 	slog.Info("database connected successfully")
 
-	return &Database{conn: db}, nil
+	return &Database{conn: db, logSQL: cfg.LogSQL, streamPageSize: cfg.StreamPageSize}, nil
 }
 
 // Close closes the database connection
@@ -51,20 +70,219 @@ func (d *Database) Close() error {
 	return d.conn.Close()
 }
 
-// GetPendingJobs retrieves pending jobs from the database
-func (d *Database) GetPendingJobs(ctx context.Context, limit int) ([]*TileJob, error) {
-	query := `
-		SELECT id, region, status, "maxZoom", "minZoom", "skipUpload", "skipGeneration",
-		       "noCleanup", "extractGeometry", "skipGeometryInsertion",
+// withReconnect calls fn, retrying while it fails because the database is
+// unreachable. Before each retry it waits and pings the database so the pool
+// dials a fresh connection. Other errors are returned at once.
+func (d *Database) withReconnect(ctx context.Context, op string, fn func() error) error {
+	policy := d.reconnect
+	if policy.MaxAttempts <= 0 {
+		policy = DefaultReconnectPolicy
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !isDatabaseUnavailable(err) {
+			return err
+		}
+
+		delay := jitter(policy.delay(attempt))
+		slog.Warn("database unavailable, reconnecting",
+			"operation", op,
+			"attempt", attempt,
+			"max_attempts", policy.MaxAttempts,
+			"delay", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if err := d.conn.PingContext(ctx); err == nil {
+			slog.Info("database reconnected", "operation", op)
+		}
+	}
+}
+
+// isDatabaseUnavailable reports whether err means the database could not be
+// reached or dropped the connection, rather than rejecting the statement
+func isDatabaseUnavailable(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// Class 08 is a connection exception; 57P0x is the server shutting down
+	// or still starting up
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || strings.HasPrefix(string(pqErr.Code), "57P0")
+	}
+	return false
+}
+
+// maxLoggedSQLArgs and maxLoggedSQLArgLen bound how much of a statement's
+// parameters logQuery prints
+const (
+	maxLoggedSQLArgs   = 20
+	maxLoggedSQLArgLen = 200
+)
+
+// logQuery logs a statement and its (truncated) args at debug level when SQL
+// logging is enabled
+func (d *Database) logQuery(ctx context.Context, query string, args ...interface{}) {
+	if !d.logSQL || !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	slog.DebugContext(ctx, "sql query", "query", compactSQL(query), "args", formatSQLArgs(args))
+}
+
+// logBatch logs a multi-row statement by row and parameter count rather than
+// printing every parameter
+func (d *Database) logBatch(ctx context.Context, statement string, rows, params int) {
+	if !d.logSQL {
+		return
+	}
+	slog.DebugContext(ctx, "sql batch", "statement", statement, "rows", rows, "params", params)
+}
+
+// compactSQL collapses whitespace so multi-line queries log on one line
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// formatSQLArgs renders args for logging, dereferencing pointers and
+// truncating long values and long argument lists
+func formatSQLArgs(args []interface{}) string {
+	parts := make([]string, 0, min(len(args), maxLoggedSQLArgs)+1)
+	for i, arg := range args {
+		if i == maxLoggedSQLArgs {
+			parts = append(parts, fmt.Sprintf("... (%d more)", len(args)-maxLoggedSQLArgs))
+			break
+		}
+		v := reflect.ValueOf(arg)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				arg = nil
+			} else {
+				arg = v.Elem().Interface()
+			}
+		}
+		str := fmt.Sprintf("%v", arg)
+		if len(str) > maxLoggedSQLArgLen {
+			str = str[:maxLoggedSQLArgLen] + "..."
+		}
+		parts = append(parts, fmt.Sprintf("$%d=%s", i+1, str))
+	}
+	return strings.Join(parts, " ")
+}
+
+// jobColumns is the column list scanned by scanJob
+const jobColumns = `id, region, status, "maxZoom", "minZoom", "skipUpload", "skipGeneration",
+		       "noCleanup", "extractGeometry", "skipGeometryInsertion", "mergeAll",
 		       "currentStep", "roadsExtracted", "tilesGenerated",
 		       "totalSizeBytes", "uploadProgress", "uploadedBytes", "errorMessage", "errorLog",
-		       "createdAt", "updatedAt", "startedAt", "completedAt"
+		       "createdAt", "updatedAt", "startedAt", "completedAt"`
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row interface{ Scan(dest ...any) error }) (*TileJob, error) {
+	job := &TileJob{}
+	err := row.Scan(
+		&job.ID, &job.Region, &job.Status, &job.MaxZoom, &job.MinZoom,
+		&job.SkipUpload, &job.SkipGeneration, &job.NoCleanup,
+		&job.ExtractGeometry, &job.SkipGeometryInsertion, &job.MergeAll,
+		&job.CurrentStep, &job.RoadsExtracted, &job.TilesGenerated,
+		&job.TotalSizeBytes, &job.UploadProgress, &job.UploadedBytes,
+		&job.ErrorMessage, &job.ErrorLog,
+		&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// JobCursor marks a position in the pending job queue. Jobs are ordered by
+// ("createdAt", id) so jobs created in the same instant still page stably.
+type JobCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// CursorAfter returns the cursor that resumes paging after job
+func CursorAfter(job *TileJob) *JobCursor {
+	return &JobCursor{CreatedAt: job.CreatedAt, ID: job.ID}
+}
+
+// Before reports whether job comes after the cursor in queue order
+func (c *JobCursor) Before(job *TileJob) bool {
+	if !job.CreatedAt.Equal(c.CreatedAt) {
+		return job.CreatedAt.After(c.CreatedAt)
+	}
+	return job.ID > c.ID
+}
+
+// String encodes the cursor as "<createdAt RFC3339Nano>,<id>"
+func (c *JobCursor) String() string {
+	return c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID
+}
+
+// ParseJobCursor parses a cursor encoded by JobCursor.String
+func ParseJobCursor(s string) (*JobCursor, error) {
+	createdAt, id, ok := strings.Cut(s, ",")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("invalid job cursor %q", s)
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job cursor %q: %w", s, err)
+	}
+	return &JobCursor{CreatedAt: t, ID: id}, nil
+}
+
+// pendingJobsQuery builds the keyset-paginated pending jobs query
+func pendingJobsQuery(limit int, since *JobCursor) (string, []interface{}) {
+	if since == nil {
+		return `
+		SELECT ` + jobColumns + `
 		FROM "TileJob"
 		WHERE status = 'pending'
+		ORDER BY "createdAt", id
 		LIMIT $1
-	`
+	`, []interface{}{limit}
+	}
+	return `
+		SELECT ` + jobColumns + `
+		FROM "TileJob"
+		WHERE status = 'pending' AND ("createdAt", id) > ($1, $2)
+		ORDER BY "createdAt", id
+		LIMIT $3
+	`, []interface{}{since.CreatedAt, since.ID, limit}
+}
 
-	rows, err := d.conn.QueryContext(ctx, query, limit)
+// GetPendingJobs retrieves up to limit pending jobs, oldest first. Pass nil
+// for the first page and CursorAfter(lastJob) for the following ones.
+func (d *Database) GetPendingJobs(ctx context.Context, limit int, since *JobCursor) ([]*TileJob, error) {
+	query, args := pendingJobsQuery(limit, since)
+
+	d.logQuery(ctx, query, args...)
+	rows, err := d.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query pending jobs: %w", err)
 	}
@@ -72,16 +290,7 @@ func (d *Database) GetPendingJobs(ctx context.Context, limit int) ([]*TileJob, e
 
 	var jobs []*TileJob
 	for rows.Next() {
-		job := &TileJob{}
-		err := rows.Scan(
-			&job.ID, &job.Region, &job.Status, &job.MaxZoom, &job.MinZoom,
-			&job.SkipUpload, &job.SkipGeneration, &job.NoCleanup,
-			&job.ExtractGeometry, &job.SkipGeometryInsertion,
-			&job.CurrentStep, &job.RoadsExtracted, &job.TilesGenerated,
-			&job.TotalSizeBytes, &job.UploadProgress, &job.UploadedBytes,
-			&job.ErrorMessage, &job.ErrorLog,
-			&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.CompletedAt,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			slog.Error("failed to scan job row", "error", err)
 			continue
@@ -96,6 +305,105 @@ func (d *Database) GetPendingJobs(ctx context.Context, limit int) ([]*TileJob, e
 	return jobs, nil
 }
 
+// claimPendingJobQuery builds the query that claims the oldest pending job.
+// FOR UPDATE SKIP LOCKED makes concurrent claimers pass over rows another
+// transaction has already locked, so each job is handed to exactly one worker.
+// The claim starts the job's lease, so the reaper judges it by its heartbeat.
+func claimPendingJobQuery(workerID string) (string, []interface{}) {
+	return `
+		UPDATE "TileJob"
+		SET status = 'processing', "claimedBy" = $1, "lastHeartbeatAt" = NOW(),
+		    "startedAt" = COALESCE("startedAt", NOW()), "updatedAt" = NOW()
+		WHERE id = (
+			SELECT id
+			FROM "TileJob"
+			WHERE status = 'pending'
+			ORDER BY "createdAt", id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns + `
+	`, []interface{}{workerID}
+}
+
+// ClaimPendingJob atomically claims the oldest pending job for workerID and
+// marks it processing. Returns nil, nil when no pending job is available.
+func (d *Database) ClaimPendingJob(ctx context.Context, workerID string) (*TileJob, error) {
+	query, args := claimPendingJobQuery(workerID)
+
+	d.logQuery(ctx, query, args...)
+	job, err := scanJob(d.conn.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending job: %w", err)
+	}
+
+	slog.Info("claimed pending job", "job_id", job.ID, "region", job.Region, "worker_id", workerID)
+	return job, nil
+}
+
+// activeJobStatuses are the statuses a job holds while a worker owns it
+const activeJobStatuses = `'processing', 'extracting', 'generating', 'merging', 'uploading'`
+
+// HeartbeatJob refreshes the lease on a job the caller is processing
+func (d *Database) HeartbeatJob(ctx context.Context, jobID string) error {
+	query := `
+		UPDATE "TileJob"
+		SET "lastHeartbeatAt" = NOW()
+		WHERE id = $1
+	`
+
+	d.logQuery(ctx, query, jobID)
+	if _, err := d.conn.ExecContext(ctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to update job heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// requeueStaleJobsQuery builds the query that returns active jobs whose
+// heartbeat is older than the lease timeout to the pending queue. Jobs that
+// never sent a heartbeat are judged by when they started.
+func requeueStaleJobsQuery(timeout time.Duration) (string, []interface{}) {
+	return `
+		UPDATE "TileJob"
+		SET status = 'pending', "claimedBy" = NULL, "lastHeartbeatAt" = NULL, "updatedAt" = NOW()
+		WHERE status IN (` + activeJobStatuses + `)
+		  AND COALESCE("lastHeartbeatAt", "startedAt", "updatedAt") < NOW() - make_interval(secs => $1)
+		RETURNING id
+	`, []interface{}{timeout.Seconds()}
+}
+
+// RequeueStaleJobs re-queues active jobs whose heartbeat is older than
+// timeout and returns their IDs
+func (d *Database) RequeueStaleJobs(ctx context.Context, timeout time.Duration) ([]string, error) {
+	query, args := requeueStaleJobsQuery(timeout)
+
+	d.logQuery(ctx, query, args...)
+	rows, err := d.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue stale jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan requeued job id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requeued jobs: %w", err)
+	}
+
+	return ids, nil
+}
+
 // UpdateJobStatus updates the status of a job
 func (d *Database) UpdateJobStatus(ctx context.Context, jobID, status string) error {
 	query := `
@@ -104,6 +412,7 @@ func (d *Database) UpdateJobStatus(ctx context.Context, jobID, status string) er
 		WHERE id = $2
 	`
 
+	d.logQuery(ctx, query, status, jobID)
 	result, err := d.conn.ExecContext(ctx, query, status, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to update job status: %w", err)
@@ -129,6 +438,7 @@ func (d *Database) UpdateJobProgress(ctx context.Context, jobID string, roadsExt
 		WHERE id = $3
 	`
 
+	d.logQuery(ctx, query, roadsExtracted, tilesGenerated, jobID)
 	_, err := d.conn.ExecContext(ctx, query, roadsExtracted, tilesGenerated, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to update job progress: %w", err)
@@ -137,6 +447,23 @@ func (d *Database) UpdateJobProgress(ctx context.Context, jobID string, roadsExt
 	return nil
 }
 
+// UpdateJobStep records the pipeline phase a job is in
+func (d *Database) UpdateJobStep(ctx context.Context, jobID, step string) error {
+	query := `
+		UPDATE "TileJob"
+		SET "currentStep" = $1, "updatedAt" = NOW()
+		WHERE id = $2
+	`
+
+	d.logQuery(ctx, query, step, jobID)
+	_, err := d.conn.ExecContext(ctx, query, step, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to update job step: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateJobError updates the error information for a failed job
 func (d *Database) UpdateJobError(ctx context.Context, jobID, errorMsg string) error {
 	query := `
@@ -145,6 +472,7 @@ func (d *Database) UpdateJobError(ctx context.Context, jobID, errorMsg string) e
 		WHERE id = $2
 	`
 
+	d.logQuery(ctx, query, errorMsg, jobID)
 	_, err := d.conn.ExecContext(ctx, query, errorMsg, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to update job error: %w", err)
@@ -169,6 +497,7 @@ func (d *Database) CompleteJob(ctx context.Context, jobID string, roadsExtracted
 		WHERE id = $4
 	`
 
+	d.logQuery(ctx, query, roadsExtracted, tilesGenerated, totalSizeBytes, jobID)
 	result, err := d.conn.ExecContext(ctx, query, roadsExtracted, tilesGenerated, totalSizeBytes, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to complete job: %w", err)
@@ -189,25 +518,13 @@ func (d *Database) CompleteJob(ctx context.Context, jobID string, roadsExtracted
 // GetJobByID retrieves a specific job by ID
 func (d *Database) GetJobByID(ctx context.Context, jobID string) (*TileJob, error) {
 	query := `
-		SELECT id, region, status, "maxZoom", "minZoom", "skipUpload", "skipGeneration",
-		       "noCleanup", "extractGeometry", "skipGeometryInsertion",
-		       "currentStep", "roadsExtracted", "tilesGenerated",
-		       "totalSizeBytes", "uploadProgress", "uploadedBytes", "errorMessage", "errorLog",
-		       "createdAt", "updatedAt", "startedAt", "completedAt"
+		SELECT ` + jobColumns + `
 		FROM "TileJob"
 		WHERE id = $1
 	`
 
-	job := &TileJob{}
-	err := d.conn.QueryRowContext(ctx, query, jobID).Scan(
-		&job.ID, &job.Region, &job.Status, &job.MaxZoom, &job.MinZoom,
-		&job.SkipUpload, &job.SkipGeneration, &job.NoCleanup,
-		&job.ExtractGeometry, &job.SkipGeometryInsertion,
-		&job.CurrentStep, &job.RoadsExtracted, &job.TilesGenerated,
-		&job.TotalSizeBytes, &job.UploadProgress, &job.UploadedBytes,
-		&job.ErrorMessage, &job.ErrorLog,
-		&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.CompletedAt,
-	)
+	d.logQuery(ctx, query, jobID)
+	job, err := scanJob(d.conn.QueryRowContext(ctx, query, jobID))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("job not found: %s", jobID)
@@ -219,9 +536,35 @@ func (d *Database) GetJobByID(ctx context.Context, jobID string) (*TileJob, erro
 	return job, nil
 }
 
+// lngUnionSQL returns upsert expressions for the "minLng" and "maxLng" of the
+// union of a row's longitude interval with the EXCLUDED one, as
+// unionLngRange computes it: the narrowest interval covering both, running
+// past 180 when it crosses the antimeridian. LEAST/GREATEST alone would
+// stretch such a box around the globe.
+func lngUnionSQL() (minLng, maxLng string) {
+	const rowMin, rowMax = `"RoadGeometry"."minLng"`, `"RoadGeometry"."maxLng"`
+	const newMin, newMax = `EXCLUDED."minLng"`, `EXCLUDED."maxLng"`
+	width := func(shift string) string {
+		return fmt.Sprintf("(GREATEST(%s, %s%s) - LEAST(%s, %s%s))", rowMax, newMax, shift, rowMin, newMin, shift)
+	}
+
+	// Shift the new interval by 0, -360 or +360, whichever is narrowest
+	shift := fmt.Sprintf("(CASE WHEN %[3]s < LEAST(%[1]s, %[2]s) THEN 360 WHEN %[2]s < %[1]s THEN -360 ELSE 0 END)",
+		width(""), width(" - 360"), width(" + 360"))
+	lo := fmt.Sprintf("LEAST(%s, %s + %s)", rowMin, newMin, shift)
+	hi := fmt.Sprintf("GREATEST(%s, %s + %s)", rowMax, newMax, shift)
+
+	// Then move both so minLng is back in [-180, 180)
+	wrap := fmt.Sprintf("(CASE WHEN %[1]s < -180 THEN 360 WHEN %[1]s >= 180 THEN -360 ELSE 0 END)", lo)
+	return lo + " + " + wrap, hi + " + " + wrap
+}
+
+// minLngUnionSQL and maxLngUnionSQL merge longitudes on upsert (lngUnionSQL)
+var minLngUnionSQL, maxLngUnionSQL = lngUnionSQL()
+
 // UpsertRoadGeometry inserts or updates a road geometry record
 func (d *Database) UpsertRoadGeometry(ctx context.Context, road *RoadGeometry) error {
-	query := `
+	query := fmt.Sprintf(`
 		INSERT INTO "RoadGeometry" (
 			id, "roadId", name, region,
 			"minLat", "maxLat", "minLng", "maxLng",
@@ -235,8 +578,8 @@ func (d *Database) UpsertRoadGeometry(ctx context.Context, road *RoadGeometry) e
 			name = COALESCE(EXCLUDED.name, "RoadGeometry".name),
 			"minLat" = LEAST("RoadGeometry"."minLat", EXCLUDED."minLat"),
 			"maxLat" = GREATEST("RoadGeometry"."maxLat", EXCLUDED."maxLat"),
-			"minLng" = LEAST("RoadGeometry"."minLng", EXCLUDED."minLng"),
-			"maxLng" = GREATEST("RoadGeometry"."maxLng", EXCLUDED."maxLng"),
+			"minLng" = %s,
+			"maxLng" = %s,
 			curvature = COALESCE(EXCLUDED.curvature, "RoadGeometry".curvature),
 			length = COALESCE(EXCLUDED.length, "RoadGeometry".length),
 			"startLat" = COALESCE(EXCLUDED."startLat", "RoadGeometry"."startLat"),
@@ -244,7 +587,7 @@ func (d *Database) UpsertRoadGeometry(ctx context.Context, road *RoadGeometry) e
 			"endLat" = COALESCE(EXCLUDED."endLat", "RoadGeometry"."endLat"),
 			"endLng" = COALESCE(EXCLUDED."endLng", "RoadGeometry"."endLng"),
 			"updatedAt" = NOW()
-	`
+	`, minLngUnionSQL, maxLngUnionSQL)
 
 	// Debug: log what we're about to insert
 	if road.MinLat == 0 || road.MaxLng == 0 {
@@ -252,12 +595,15 @@ func (d *Database) UpsertRoadGeometry(ctx context.Context, road *RoadGeometry) e
 			road.RoadID, road.Region, road.MinLat, road.MaxLat, road.MinLng, road.MaxLng)
 	}
 
-	_, err := d.conn.ExecContext(ctx, query,
+	args := []interface{}{
 		road.RoadID, road.Name, road.Region,
 		road.MinLat, road.MaxLat, road.MinLng, road.MaxLng,
 		road.Curvature, road.Length,
 		road.StartLat, road.StartLng, road.EndLat, road.EndLng,
-	)
+	}
+
+	d.logQuery(ctx, query, args...)
+	_, err := d.conn.ExecContext(ctx, query, args...)
 
 	if err != nil {
 		return fmt.Errorf("failed to upsert road geometry: %w", err)
@@ -266,9 +612,18 @@ func (d *Database) UpsertRoadGeometry(ctx context.Context, road *RoadGeometry) e
 	return nil
 }
 
+// rowsPerTransaction is how many rows BatchUpsertRoadGeometries commits at
+// once: a good balance of speed and resilience (a var so tests can commit
+// more often)
+var rowsPerTransaction = 500000
+
 // BatchUpsertRoadGeometries inserts or updates multiple road geometry records in a batch
-// Uses optimized chunked-transaction approach: fast + resilient to failures
-func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGeometry, batchSize int) (int, error) {
+// Uses optimized chunked-transaction approach: fast + resilient to failures.
+// progress, if not nil, is called after each commit with the number of roads
+// committed so far; roads[:inserted] are then durable and need not be sent again.
+// keys picks the conflict target: ("roadId", region), or "roadId" alone for
+// RoadKeyGlobal, which needs the unique index of docs/SCHEMA_SYNC.md.
+func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGeometry, keys RoadKeyStrategy, batchSize int, progress func(inserted int)) (int, error) {
 	logger := slog.With("total_roads", len(roads), "batch_size", batchSize)
 	logger.Info("starting optimized batch upsert of road geometries")
 
@@ -277,16 +632,10 @@ func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGe
 	// Max batch size = 65535 / 13 = 5041
 	// Use 5000 for safety margin
 	const maxBatchSize = 5000
-	if batchSize < 5000 {
-		batchSize = 5000
-	}
-	if batchSize > maxBatchSize {
+	if batchSize <= 0 || batchSize > maxBatchSize {
 		batchSize = maxBatchSize
 	}
 
-	// Commit every 500k rows (good balance of speed and resilience)
-	const rowsPerTransaction = 500000
-
 	inserted := 0
 	var tx *sql.Tx
 	var err error
@@ -337,13 +686,13 @@ func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGe
 				"createdAt", "updatedAt"
 			)
 			VALUES %s
-			ON CONFLICT ("roadId", region)
+			ON CONFLICT (%s)
 			DO UPDATE SET
 				name = COALESCE(EXCLUDED.name, "RoadGeometry".name),
 				"minLat" = LEAST("RoadGeometry"."minLat", EXCLUDED."minLat"),
 				"maxLat" = GREATEST("RoadGeometry"."maxLat", EXCLUDED."maxLat"),
-				"minLng" = LEAST("RoadGeometry"."minLng", EXCLUDED."minLng"),
-				"maxLng" = GREATEST("RoadGeometry"."maxLng", EXCLUDED."maxLng"),
+				"minLng" = %s,
+				"maxLng" = %s,
 				curvature = COALESCE(EXCLUDED.curvature, "RoadGeometry".curvature),
 				length = COALESCE(EXCLUDED.length, "RoadGeometry".length),
 				"startLat" = COALESCE(EXCLUDED."startLat", "RoadGeometry"."startLat"),
@@ -351,12 +700,17 @@ func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGe
 				"endLat" = COALESCE(EXCLUDED."endLat", "RoadGeometry"."endLat"),
 				"endLng" = COALESCE(EXCLUDED."endLng", "RoadGeometry"."endLng"),
 				"updatedAt" = NOW()
-		`, strings.Join(valuesStrings, ", "))
+		`, strings.Join(valuesStrings, ", "), keys.conflictTarget(), minLngUnionSQL, maxLngUnionSQL)
+
+		d.logBatch(ctx, "upsert road geometries", len(batch), len(valueArgs))
 
 		// Execute within transaction
 		_, err = tx.ExecContext(ctx, query, valueArgs...)
 		if err != nil {
 			tx.Rollback()
+			if keys == RoadKeyGlobal {
+				return inserted, fmt.Errorf("failed to insert batch at row %d (global road keys need the %q index, see docs/SCHEMA_SYNC.md): %w", i, roadIDIndexName, err)
+			}
 			return inserted, fmt.Errorf("failed to insert batch at row %d: %w", i, err)
 		}
 
@@ -370,6 +724,9 @@ func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGe
 			}
 			logger.Info("transaction committed", "inserted", inserted, "total", len(roads))
 			tx = nil // Will start new transaction on next iteration
+			if progress != nil {
+				progress(inserted)
+			}
 		} else if inserted%50000 == 0 {
 			// Log progress within transaction
 			logger.Info("batch progress", "inserted", inserted, "total", len(roads), "uncommitted", rowsInCurrentTx)
@@ -381,16 +738,45 @@ func (d *Database) BatchUpsertRoadGeometries(ctx context.Context, roads []RoadGe
 		if err := tx.Commit(); err != nil {
 			return inserted - rowsInCurrentTx, fmt.Errorf("failed to commit final transaction: %w", err)
 		}
+		if progress != nil {
+			progress(inserted)
+		}
 	}
 
 	logger.Info("batch upsert complete", "total_inserted", inserted)
 	return inserted, nil
 }
 
-// DeleteRoadGeometriesByRegion deletes all road geometries for a specific region
-func (d *Database) DeleteRoadGeometriesByRegion(ctx context.Context, region string) (int64, error) {
+// upsertRoadGeometriesWithReconnect runs BatchUpsertRoadGeometries through
+// withReconnect. Each retry resumes after the roads the earlier attempts
+// committed rather than sending them all again. It returns the number of
+// roads committed across all attempts.
+func (d *Database) upsertRoadGeometriesWithReconnect(ctx context.Context, roads []RoadGeometry, keys RoadKeyStrategy, batchSize int) (int, error) {
+	committed := 0
+	err := d.withReconnect(ctx, "insert road geometries", func() error {
+		start := committed
+		if start > 0 {
+			slog.Info("resuming road geometry insert", "already_inserted", start, "total", len(roads))
+		}
+		_, err := d.BatchUpsertRoadGeometries(ctx, roads[start:], keys, batchSize, func(inserted int) {
+			committed = start + inserted
+		})
+		return err
+	})
+	return committed, err
+}
+
+// DeleteRoadGeometriesByRegion deletes all road geometries for a specific region.
+// Under RoadKeyGlobal a row shared by overlapping regions keeps only the
+// region that inserted it first, so deleting by region is refused.
+func (d *Database) DeleteRoadGeometriesByRegion(ctx context.Context, region string, keys RoadKeyStrategy) (int64, error) {
+	if keys == RoadKeyGlobal {
+		return 0, fmt.Errorf("cannot delete road geometries of region %s: global road keys share rows across regions", region)
+	}
+
 	query := `DELETE FROM "RoadGeometry" WHERE region = $1`
 
+	d.logQuery(ctx, query, region)
 	result, err := d.conn.ExecContext(ctx, query, region)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete road geometries: %w", err)
@@ -409,6 +795,8 @@ func (d *Database) GetRoadGeometryCount(ctx context.Context, region string) (int
 	query := `SELECT COUNT(*) FROM "RoadGeometry" WHERE region = $1`
 
 	var count int
+
+	d.logQuery(ctx, query, region)
 	err := d.conn.QueryRowContext(ctx, query, region).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count road geometries: %w", err)
@@ -416,3 +804,261 @@ func (d *Database) GetRoadGeometryCount(ctx context.Context, region string) (int
 
 	return count, nil
 }
+
+// ExistingRoadIDs returns which of the given road IDs exist for a region
+func (d *Database) ExistingRoadIDs(ctx context.Context, region string, roadIDs []string) (map[string]bool, error) {
+	query := `SELECT "roadId" FROM "RoadGeometry" WHERE region = $1 AND "roadId" = ANY($2)`
+
+	d.logQuery(ctx, query, region, roadIDs)
+	rows, err := d.conn.QueryContext(ctx, query, region, pq.Array(roadIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query road IDs: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool, len(roadIDs))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan road ID: %w", err)
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
+
+// SampleRoadIDs returns up to limit random road IDs for a region
+func (d *Database) SampleRoadIDs(ctx context.Context, region string, limit int) ([]string, error) {
+	query := `SELECT "roadId" FROM "RoadGeometry" WHERE region = $1 ORDER BY random() LIMIT $2`
+
+	d.logQuery(ctx, query, region, limit)
+	rows, err := d.conn.QueryContext(ctx, query, region, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample road IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan road ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// NearbyRoad is a road whose bounding box lies within a search radius
+type NearbyRoad struct {
+	RoadID         string     `json:"roadId"`
+	Region         string     `json:"region"`
+	Bounds         RoadBounds `json:"bounds"`
+	Curvature      *string    `json:"curvature,omitempty"`
+	DistanceMeters float64    `json:"distanceMeters"` // From the point to the nearest edge of the bounding box, 0 inside it
+}
+
+// RoadBounds is a road's bounding box
+type RoadBounds struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// metersPerDegreeLat is the length of one degree of latitude
+const metersPerDegreeLat = 111320.0
+
+// nearbyRoadsQuery selects the roads whose bounding box passes the degree
+// window ($5-$12), nearest first by the equirectangular distance to the box
+// in degrees ($1 = lat, $2-$4 = lng and lng ± 360, $13 = cos² of the
+// latitude, $14 = squared radius in degrees), up to $15 rows. The window and
+// distance are both taken at the three longitude shifts, so boxes crossing
+// the antimeridian match from either side.
+const nearbyRoadsQuery = `
+	SELECT "roadId", region, "minLat", "maxLat", "minLng", "maxLng", curvature
+	FROM (
+		SELECT "roadId", region, "minLat", "maxLat", "minLng", "maxLng", curvature,
+			GREATEST(GREATEST("minLat" - $1, $1 - "maxLat"), 0.0) AS dlat,
+			LEAST(LEAST(
+				GREATEST(GREATEST("minLng" - $2, $2 - "maxLng"), 0.0),
+				GREATEST(GREATEST("minLng" - $3, $3 - "maxLng"), 0.0)),
+				GREATEST(GREATEST("minLng" - $4, $4 - "maxLng"), 0.0)) AS dlng
+		FROM "RoadGeometry"
+		WHERE "minLat" <= $5 AND "maxLat" >= $6
+			AND (("minLng" <= $7 AND "maxLng" >= $8)
+				OR ("minLng" <= $9 AND "maxLng" >= $10)
+				OR ("minLng" <= $11 AND "maxLng" >= $12))
+	) AS windowed
+	WHERE dlat * dlat + dlng * dlng * $13 <= $14
+	ORDER BY dlat * dlat + dlng * dlng * $13, "roadId", region
+	LIMIT $15
+`
+
+// FindNearbyRoads returns up to limit roads whose bounding box comes within
+// radiusMeters of the point, nearest first. The database pre-filters on the
+// bounding box columns with a degree window around the point and returns the
+// limit nearest boxes by an equirectangular distance; the exact distance to
+// each box is then computed with haversine. Boxes crossing the antimeridian
+// run past 180 (see lngRange), so the window is also compared shifted by
+// ±360°, which matches them from either side and matches boxes beyond 180°
+// from a window crossing it.
+func (d *Database) FindNearbyRoads(ctx context.Context, lat, lng, radiusMeters float64, limit int) ([]NearbyRoad, error) {
+	latDelta := radiusMeters / metersPerDegreeLat
+	// Longitude degrees shrink toward the poles; cap the scale so the window
+	// stays finite there
+	lngScale := math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	lngDelta := latDelta / lngScale
+
+	query := nearbyRoadsQuery
+	args := []interface{}{
+		lat, lng, lng + 360, lng - 360,
+		lat + latDelta, lat - latDelta,
+		lng + lngDelta, lng - lngDelta,
+		lng + 360 + lngDelta, lng + 360 - lngDelta,
+		lng - 360 + lngDelta, lng - 360 - lngDelta,
+		lngScale * lngScale, latDelta * latDelta,
+		limit,
+	}
+
+	d.logQuery(ctx, query, args...)
+	rows, err := d.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearby roads: %w", err)
+	}
+	defer rows.Close()
+
+	roads := []NearbyRoad{}
+	for rows.Next() {
+		var road NearbyRoad
+		var curvature sql.NullString
+		if err := rows.Scan(
+			&road.RoadID, &road.Region,
+			&road.Bounds.MinLat, &road.Bounds.MaxLat, &road.Bounds.MinLng, &road.Bounds.MaxLng,
+			&curvature,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan nearby road: %w", err)
+		}
+		road.DistanceMeters = distanceToBounds(lat, lng, road.Bounds)
+		if road.DistanceMeters > radiusMeters {
+			continue
+		}
+		if curvature.Valid {
+			road.Curvature = &curvature.String
+		}
+		roads = append(roads, road)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nearby roads: %w", err)
+	}
+
+	// The database ordered by an approximate distance
+	sort.SliceStable(roads, func(i, j int) bool { return roads[i].DistanceMeters < roads[j].DistanceMeters })
+	return roads, nil
+}
+
+// distanceToBounds returns the haversine distance in meters from a point to
+// the nearest point of a bounding box, 0 when the point is inside it. The
+// point is also tried a turn east and west, for boxes running past 180.
+func distanceToBounds(lat, lng float64, b RoadBounds) float64 {
+	nearestLat := math.Min(math.Max(lat, b.MinLat), b.MaxLat)
+	distance := math.Inf(1)
+	for _, l := range []float64{lng, lng + 360, lng - 360} {
+		nearestLng := math.Min(math.Max(l, b.MinLng), b.MaxLng)
+		distance = math.Min(distance, haversineDistance(lat, l, nearestLat, nearestLng))
+	}
+	return distance
+}
+
+// DefaultRoadStreamPageSize is the number of rows fetched per page by
+// StreamRoadGeometries unless DB_STREAM_PAGE_SIZE says otherwise
+const DefaultRoadStreamPageSize = 5000
+
+// StreamRoadGeometries calls fn for every road in a region, ordered by roadId.
+// Rows are fetched in pages with keyset pagination so memory stays bounded
+// regardless of region size. Returning an error from fn stops the stream.
+func (d *Database) StreamRoadGeometries(ctx context.Context, region string, fn func(RoadGeometry) error) error {
+	pageSize := d.streamPageSize
+	if pageSize <= 0 {
+		pageSize = DefaultRoadStreamPageSize
+	}
+	return streamRoadPages(ctx, d.fetchRoadPage, region, pageSize, fn)
+}
+
+// fetchRoadPage returns up to limit roads with roadId > after, ordered by roadId
+func (d *Database) fetchRoadPage(ctx context.Context, region, after string, limit int) ([]RoadGeometry, error) {
+	query := `
+		SELECT "roadId", COALESCE(name, ''), region,
+			"minLat", "maxLat", "minLng", "maxLng",
+			curvature, length,
+			"startLat", "startLng", "endLat", "endLng"
+		FROM "RoadGeometry"
+		WHERE region = $1 AND "roadId" > $2
+		ORDER BY "roadId"
+		LIMIT $3
+	`
+
+	d.logQuery(ctx, query, region, after, limit)
+	rows, err := d.conn.QueryContext(ctx, query, region, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query road geometries: %w", err)
+	}
+	defer rows.Close()
+
+	var roads []RoadGeometry
+	for rows.Next() {
+		var road RoadGeometry
+		var curvature sql.NullString
+		var length, startLat, startLng, endLat, endLng sql.NullFloat64
+		if err := rows.Scan(
+			&road.RoadID, &road.Name, &road.Region,
+			&road.MinLat, &road.MaxLat, &road.MinLng, &road.MaxLng,
+			&curvature, &length,
+			&startLat, &startLng, &endLat, &endLng,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan road geometry: %w", err)
+		}
+		if curvature.Valid {
+			road.Curvature = &curvature.String
+		}
+		road.Length = nullFloatPtr(length)
+		road.StartLat = nullFloatPtr(startLat)
+		road.StartLng = nullFloatPtr(startLng)
+		road.EndLat = nullFloatPtr(endLat)
+		road.EndLng = nullFloatPtr(endLng)
+		roads = append(roads, road)
+	}
+	return roads, rows.Err()
+}
+
+// streamRoadPages drives keyset pagination over fetch until a short page
+func streamRoadPages(ctx context.Context, fetch func(ctx context.Context, region, after string, limit int) ([]RoadGeometry, error), region string, pageSize int, fn func(RoadGeometry) error) error {
+	after := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := fetch(ctx, region, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, road := range page {
+			if err := fn(road); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		after = page[len(page)-1].RoadID
+	}
+}
+
+// nullFloatPtr converts a nullable column to the pointer form used by RoadGeometry
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPendingJobsQuery(t *testing.T) {
+	query, args := pendingJobsQuery(10, nil)
+	if !strings.Contains(query, `ORDER BY "createdAt", id`) {
+		t.Errorf("expected stable ordering, got %s", query)
+	}
+	if strings.Contains(query, "$2") || len(args) != 1 || args[0] != 10 {
+		t.Errorf("expected first page to take only the limit, got %v", args)
+	}
+
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	query, args = pendingJobsQuery(10, &JobCursor{CreatedAt: created, ID: "job-b"})
+	if !strings.Contains(query, `("createdAt", id) > ($1, $2)`) {
+		t.Errorf("expected keyset predicate, got %s", query)
+	}
+	if !strings.Contains(query, `ORDER BY "createdAt", id`) {
+		t.Errorf("expected stable ordering, got %s", query)
+	}
+	if len(args) != 3 || args[0] != created || args[1] != "job-b" || args[2] != 10 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+// newJobsTestDB returns a Database on an SQLite "TileJob" table
+func newJobsTestDB(t *testing.T) *Database {
+	t.Helper()
+	conn := openTestSQLite(t)
+	_, err := conn.Exec(`CREATE TABLE "TileJob" (
+		id TEXT PRIMARY KEY, region TEXT, status TEXT,
+		"maxZoom" INTEGER DEFAULT 16, "minZoom" INTEGER DEFAULT 5,
+		"skipUpload" BOOLEAN DEFAULT 0, "skipGeneration" BOOLEAN DEFAULT 0,
+		"noCleanup" BOOLEAN DEFAULT 0, "extractGeometry" BOOLEAN DEFAULT 1,
+		"skipGeometryInsertion" BOOLEAN DEFAULT 0, "mergeAll" BOOLEAN DEFAULT 0,
+		"currentStep" TEXT, "roadsExtracted" INTEGER, "tilesGenerated" INTEGER,
+		"totalSizeBytes" INTEGER, "uploadProgress" INTEGER DEFAULT 0, "uploadedBytes" INTEGER DEFAULT 0,
+		"errorMessage" TEXT, "errorLog" TEXT,
+		"createdAt" DATETIME, "updatedAt" DATETIME, "startedAt" DATETIME, "completedAt" DATETIME,
+		"claimedBy" TEXT, "lastHeartbeatAt" DATETIME
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Database{conn: conn}
+}
+
+// insertTestJob adds a job with the given id, status and creation time
+func insertTestJob(t *testing.T, db *Database, id, status string, createdAt time.Time) {
+	t.Helper()
+	_, err := db.conn.Exec(`INSERT INTO "TileJob" (id, region, status, "createdAt", "updatedAt") VALUES (?, 'oregon', ?, ?, ?)`,
+		id, status, createdAt, createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPendingJobsPagination_StableAndNonOverlapping(t *testing.T) {
+	db := newJobsTestDB(t)
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		// Pairs of jobs share a createdAt to exercise the id tie-break
+		insertTestJob(t, db, fmt.Sprintf("job-%02d", 9-i), "pending", base.Add(time.Duration(i/2)*time.Second))
+	}
+	insertTestJob(t, db, "done", "completed", base)
+
+	seen := map[string]bool{}
+	var order []string
+	var since *JobCursor
+	pages := 0
+	for {
+		page, err := db.GetPendingJobs(ctx, 3, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		pages++
+		for _, job := range page {
+			if seen[job.ID] {
+				t.Fatalf("job %s returned on more than one page", job.ID)
+			}
+			seen[job.ID] = true
+			order = append(order, job.ID)
+		}
+		since = CursorAfter(page[len(page)-1])
+	}
+
+	if len(seen) != 10 {
+		t.Errorf("expected all 10 pending jobs, got %d", len(seen))
+	}
+	if pages != 4 {
+		t.Errorf("expected 4 pages of 3, got %d", pages)
+	}
+	if len(order) > 1 && (order[0] != "job-08" || order[1] != "job-09") {
+		t.Errorf("expected jobs sharing a createdAt ordered by id, got %v", order)
+	}
+
+	// Re-running from the start yields the same order
+	var again []string
+	since = nil
+	for {
+		page, err := db.GetPendingJobs(ctx, 3, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, job := range page {
+			again = append(again, job.ID)
+		}
+		since = CursorAfter(page[len(page)-1])
+	}
+	if fmt.Sprint(order) != fmt.Sprint(again) {
+		t.Errorf("expected stable order, got %v then %v", order, again)
+	}
+}
+
+func TestJobCursor_RoundTrip(t *testing.T) {
+	cursor := &JobCursor{CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 123456789, time.UTC), ID: "job-1"}
+	parsed, err := ParseJobCursor(cursor.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.CreatedAt.Equal(cursor.CreatedAt) || parsed.ID != cursor.ID {
+		t.Errorf("expected %+v, got %+v", cursor, parsed)
+	}
+	for _, bad := range []string{"", "job-1", "2025-01-01T00:00:00Z,", "yesterday,job-1"} {
+		if _, err := ParseJobCursor(bad); err == nil {
+			t.Errorf("expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestClaimPendingJobQuery(t *testing.T) {
+	query, args := claimPendingJobQuery("worker-1")
+	for _, want := range []string{
+		"FOR UPDATE SKIP LOCKED",
+		"status = 'pending'",
+		`ORDER BY "createdAt", id`,
+		"LIMIT 1",
+		"SET status = 'processing'",
+		`"claimedBy" = $1`,
+		`"lastHeartbeatAt" = NOW()`,
+		"RETURNING " + jobColumns,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("expected claim query to contain %q, got %s", want, query)
+		}
+	}
+	if len(args) != 1 || args[0] != "worker-1" {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestClaimPendingJob_ConcurrentClaimersGetDistinctJobs(t *testing.T) {
+	db := newJobsTestDB(t)
+	// SQLite allows one writer at a time; a single connection keeps the
+	// claimers from failing with SQLITE_BUSY instead of waiting their turn
+	db.conn.SetMaxOpenConns(1)
+	const numJobs = 20
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < numJobs; i++ {
+		insertTestJob(t, db, fmt.Sprintf("job-%02d", i), "pending", base.Add(time.Duration(i)*time.Second))
+	}
+	insertTestJob(t, db, "done", "completed", base)
+
+	var mu sync.Mutex
+	claimed := map[string]string{}
+	var wg sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		workerID := fmt.Sprintf("worker-%d", w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := db.ClaimPendingJob(context.Background(), workerID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				if job.Status != "processing" || job.StartedAt == nil {
+					t.Errorf("expected claimed job %s to be processing and started, got %q", job.ID, job.Status)
+				}
+				mu.Lock()
+				if prev, ok := claimed[job.ID]; ok {
+					t.Errorf("job %s claimed by both %s and %s", job.ID, prev, workerID)
+				}
+				claimed[job.ID] = workerID
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != numJobs {
+		t.Errorf("expected all %d pending jobs claimed, got %d", numJobs, len(claimed))
+	}
+	rows, err := db.conn.Query(`SELECT id, status, "claimedBy" FROM "TileJob" WHERE "lastHeartbeatAt" IS NOT NULL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, status, claimedBy string
+		if err := rows.Scan(&id, &status, &claimedBy); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if status != "processing" || claimedBy != claimed[id] {
+			t.Errorf("job %s: expected processing by %s, got %s by %s", id, claimed[id], status, claimedBy)
+		}
+	}
+	if n != numJobs {
+		t.Errorf("expected %d leased jobs, got %d", numJobs, n)
+	}
+}
+
+func TestClaimPendingJob_OldestFirst(t *testing.T) {
+	db := newJobsTestDB(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	insertTestJob(t, db, "newer", "pending", base.Add(time.Minute))
+	insertTestJob(t, db, "older", "pending", base)
+
+	for _, want := range []string{"older", "newer"} {
+		job, err := db.ClaimPendingJob(context.Background(), "worker-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil || job.ID != want {
+			t.Fatalf("expected to claim %s, got %+v", want, job)
+		}
+	}
+	job, err := db.ClaimPendingJob(context.Background(), "worker-1")
+	if err != nil || job != nil {
+		t.Errorf("expected nothing left to claim, got %+v, %v", job, err)
+	}
+}
+
+func TestLogQuery_DebugLevel(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	conn, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=x dbname=x sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	db := &Database{conn: conn, logSQL: true}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	// The connection fails, but the statement is logged before execution
+	db.UpdateJobStatus(context.Background(), "job-1", "processing")
+
+	out := buf.String()
+	if !strings.Contains(out, "level=DEBUG") || !strings.Contains(out, `UPDATE \"TileJob\" SET status = $1`) {
+		t.Errorf("expected debug log with compacted query, got %q", out)
+	}
+	if !strings.Contains(out, "$1=processing $2=job-1") {
+		t.Errorf("expected args in log, got %q", out)
+	}
+
+	// Nothing is logged above debug level or with SQL logging disabled
+	for _, tc := range []struct {
+		level  slog.Level
+		logSQL bool
+	}{
+		{slog.LevelInfo, true},
+		{slog.LevelDebug, false},
+	} {
+		buf.Reset()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tc.level})))
+		db.logSQL = tc.logSQL
+		db.logQuery(context.Background(), "SELECT 1")
+		db.logBatch(context.Background(), "upsert road geometries", 10, 130)
+		if buf.Len() != 0 {
+			t.Errorf("level=%v logSQL=%v: expected no SQL log, got %q", tc.level, tc.logSQL, buf.String())
+		}
+	}
+}
+
+func TestLogBatch_LogsRowCount(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	db := &Database{logSQL: true}
+	db.logBatch(context.Background(), "upsert road geometries", 5000, 65000)
+
+	out := buf.String()
+	if !strings.Contains(out, "rows=5000") || !strings.Contains(out, "params=65000") {
+		t.Errorf("expected row and param counts, got %q", out)
+	}
+}
+
+func TestFormatSQLArgs(t *testing.T) {
+	name := "Mulholland"
+	var nilName *string
+	long := strings.Repeat("x", maxLoggedSQLArgLen+50)
+
+	got := formatSQLArgs([]interface{}{1, &name, nilName, long})
+	want := "$1=1 $2=Mulholland $3=<nil> $4=" + strings.Repeat("x", maxLoggedSQLArgLen) + "..."
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	many := make([]interface{}, maxLoggedSQLArgs+5)
+	for i := range many {
+		many[i] = i
+	}
+	got = formatSQLArgs(many)
+	if !strings.HasSuffix(got, "... (5 more)") || strings.Contains(got, fmt.Sprintf("$%d=", maxLoggedSQLArgs+1)) {
+		t.Errorf("expected truncated arg list, got %q", got)
+	}
+}
+
+func TestBatchUpsertRoadGeometries_Antimeridian(t *testing.T) {
+	tests := []struct {
+		name             string
+		rowMin, rowMax   float64
+		newMin, newMax   float64
+		wantMin, wantMax float64
+	}{
+		{"overlapping", -122, -121, -121.5, -120, -122, -120},
+		{"apart", 10, 11, 20, 21, 10, 21},
+		{"crossing row grows west", 179.8, 180.03, -179.95, -179.8, 179.8, 180.2},
+		{"east row meets west", 179.8, 179.95, -179.95, -179.8, 179.8, 180.2},
+		{"west row meets east", -179.95, -179.8, 179.8, 179.95, 179.8, 180.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newRoadsTestDB(t)
+			for _, lngs := range [][2]float64{{tt.rowMin, tt.rowMax}, {tt.newMin, tt.newMax}} {
+				road := RoadGeometry{RoadID: "road", Region: "aleutians", MinLat: 51, MaxLat: 52, MinLng: lngs[0], MaxLng: lngs[1]}
+				if _, err := db.BatchUpsertRoadGeometries(context.Background(), []RoadGeometry{road}, RoadKeyRegional, 10, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var minLng, maxLng float64
+			if err := db.conn.QueryRow(`SELECT "minLng", "maxLng" FROM "RoadGeometry"`).Scan(&minLng, &maxLng); err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(minLng-tt.wantMin) > 1e-9 || math.Abs(maxLng-tt.wantMax) > 1e-9 {
+				t.Errorf("expected %v..%v, got %v..%v", tt.wantMin, tt.wantMax, minLng, maxLng)
+			}
+			// The same union extraction computes
+			goMin, goMax := unionLngRange(tt.rowMin, tt.rowMax, tt.newMin, tt.newMax)
+			if math.Abs(minLng-goMin) > 1e-9 || math.Abs(maxLng-goMax) > 1e-9 {
+				t.Errorf("expected unionLngRange's %v..%v, got %v..%v", goMin, goMax, minLng, maxLng)
+			}
+		})
+	}
+}
+
+func TestFindNearbyRoads_NearestInDatabase(t *testing.T) {
+	if !strings.Contains(nearbyRoadsQuery, "ORDER BY dlat * dlat + dlng * dlng * $13") || !strings.Contains(nearbyRoadsQuery, "LIMIT $15") {
+		t.Fatalf("expected the database to order and limit by distance, got %s", nearbyRoadsQuery)
+	}
+
+	db := newRoadsTestDB(t)
+	// Inserted farthest first, so insertion order cannot pass for distance order
+	var roads []RoadGeometry
+	for i := 5; i >= 1; i-- {
+		lng := -122 + float64(i)*0.01
+		roads = append(roads, RoadGeometry{RoadID: fmt.Sprintf("road-%d", i), Region: "oregon", MinLat: 45, MaxLat: 45.001, MinLng: lng, MaxLng: lng + 0.001})
+	}
+	if _, err := db.BatchUpsertRoadGeometries(context.Background(), roads, RoadKeyRegional, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.FindNearbyRoads(context.Background(), 45, -122, 10000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].RoadID != "road-1" || got[1].RoadID != "road-2" {
+		t.Errorf("expected the 2 nearest roads, got %+v", got)
+	}
+}
+
+func TestFindNearbyRoads_Antimeridian(t *testing.T) {
+	db := newRoadsTestDB(t)
+	for _, road := range []RoadGeometry{
+		{RoadID: "crossing", Region: "aleutians", MinLat: 51, MaxLat: 51.1, MinLng: 179.9, MaxLng: 180.1},
+		{RoadID: "west", Region: "aleutians", MinLat: 51, MaxLat: 51.1, MinLng: -179.9, MaxLng: -179.8},
+		{RoadID: "east", Region: "aleutians", MinLat: 51, MaxLat: 51.1, MinLng: 179.8, MaxLng: 179.9},
+	} {
+		if _, err := db.BatchUpsertRoadGeometries(context.Background(), []RoadGeometry{road}, RoadKeyRegional, 10, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A degree of longitude is ~70km at 51°N
+	tests := []struct {
+		name   string
+		lng    float64
+		radius float64
+		want   []string
+	}{
+		{"west of the antimeridian", -179.95, 5000, []string{"crossing", "west"}},
+		{"east of the antimeridian", 179.95, 5000, []string{"crossing", "east"}},
+		{"window crossing the antimeridian", 179.99, 10000, []string{"crossing", "east", "west"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roads, err := db.FindNearbyRoads(context.Background(), 51.05, tt.lng, tt.radius, defaultNearbyLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, road := range roads {
+				ids = append(ids, road.RoadID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, ids)
+			}
+			if len(roads) > 0 && roads[0].DistanceMeters != 0 {
+				t.Errorf("expected the crossing road to contain the point, got %vm", roads[0].DistanceMeters)
+			}
+		})
+	}
+}
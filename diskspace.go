@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// DefaultDiskSpaceFactor is how many times the GeoJSON size generation is
+// assumed to need free, on both the output and the temp partition
+const DefaultDiskSpaceFactor = 3.0
+
+// freeSpaceFunc reports the bytes available to this process on the
+// filesystem holding path
+type freeSpaceFunc func(path string) (uint64, error)
+
+// checkDiskSpace fails when the output or temp partition has less free space
+// than factor times the size of the GeoJSON file. Free space that cannot be
+// determined on this platform is logged and not treated as a failure.
+func checkDiskSpace(geoJSONPath string, factor float64, freeSpace freeSpaceFunc, dirs ...string) error {
+	info, err := os.Stat(geoJSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat GeoJSON: %w", err)
+	}
+	required := uint64(float64(info.Size()) * factor)
+
+	for _, dir := range dirs {
+		existing := existingAncestor(dir)
+		available, err := freeSpace(existing)
+		if errors.Is(err, errors.ErrUnsupported) {
+			slog.Warn("cannot determine free disk space on this platform, skipping check", "dir", dir)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check free space on %s: %w", dir, err)
+		}
+		if available < required {
+			return fmt.Errorf("not enough free disk space on %s: %.1f MB available, about %.1f MB needed (%.1f MB GeoJSON x %.1f, see DISK_SPACE_FACTOR)",
+				dir, megabytes(available), megabytes(required), megabytes(uint64(info.Size())), factor)
+		}
+		slog.Debug("disk space check passed", "dir", dir, "available_bytes", available, "required_bytes", required)
+	}
+	return nil
+}
+
+// existingAncestor returns dir, or its closest parent that exists, so free
+// space can be checked before the directory is created
+func existingAncestor(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// megabytes converts a byte count for messages
+func megabytes(n uint64) float64 {
+	return float64(n) / (1024 * 1024)
+}
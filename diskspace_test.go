@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	geoJSONPath := filepath.Join(dir, "roads.geojson")
+	if err := os.WriteFile(geoJSONPath, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(dir, "tiles")
+	tempDir := filepath.Join(dir, "scratch")
+	for _, d := range []string{outputDir, tempDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		free    map[string]uint64
+		err     error
+		wantErr string
+	}{
+		{"enough everywhere", map[string]uint64{outputDir: 3000, tempDir: 5000}, nil, ""},
+		{"output partition short", map[string]uint64{outputDir: 2999, tempDir: 5000}, nil, "not enough free disk space on " + outputDir},
+		{"temp partition short", map[string]uint64{outputDir: 5000, tempDir: 100}, nil, "not enough free disk space on " + tempDir},
+		{"reporter fails", nil, errors.New("statfs broke"), "statfs broke"},
+		{"unsupported platform", nil, errors.ErrUnsupported, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			free := func(path string) (uint64, error) {
+				return tt.free[path], tt.err
+			}
+			err := checkDiskSpace(geoJSONPath, 3, free, outputDir, tempDir)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckDiskSpace_MissingDirUsesParent(t *testing.T) {
+	dir := t.TempDir()
+	geoJSONPath := filepath.Join(dir, "roads.geojson")
+	if err := os.WriteFile(geoJSONPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var checked []string
+	free := func(path string) (uint64, error) {
+		checked = append(checked, path)
+		return 1 << 30, nil
+	}
+	if err := checkDiskSpace(geoJSONPath, 3, free, filepath.Join(dir, "not", "yet", "created")); err != nil {
+		t.Fatal(err)
+	}
+	if len(checked) != 1 || checked[0] != dir {
+		t.Errorf("expected the closest existing parent %s to be checked, got %v", dir, checked)
+	}
+}
+
+func TestGenerateTiles_InsufficientDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	geoJSONPath := writeTestGeoJSON(t)
+	outDir := filepath.Join(dir, "out")
+
+	// No disk is a trillion times the size of a small GeoJSON file
+	_, _, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "diskregion", outDir, &GenerateTilesOptions{Encoder: EncoderGo, DiskSpaceFactor: 1e12})
+	if err == nil || !strings.Contains(err.Error(), "not enough free disk space") {
+		t.Fatalf("expected a disk space error, got %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written when the precheck fails")
+	}
+}
+
+func TestTileService_DiskSpaceFactor(t *testing.T) {
+	tests := []struct {
+		configured float64
+		want       float64
+	}{
+		{0, DefaultDiskSpaceFactor},
+		{5, 5},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		svc := NewTileService(nil, nil, &Config{Service: ServiceConfig{DiskSpaceFactor: tt.configured}})
+		if got := svc.diskSpaceFactor(); got != tt.want {
+			t.Errorf("DiskSpaceFactor %v: got %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+}
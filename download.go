@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultDownloadConcurrency is how many objects download at once unless
+// DownloadOptions says otherwise
+const DefaultDownloadConcurrency = 32
+
+// DownloadOptions controls a region download. Transient failures are
+// retried according to the S3 client's Retry policy.
+type DownloadOptions struct {
+	Concurrency int          // Objects downloaded at once (0 = DefaultDownloadConcurrency)
+	Progress    ProgressFunc // Receives files downloaded so far in place of periodic progress logs (nil = log)
+	All         bool         // Without a region manifest, download every tile under the shared prefix instead of failing
+}
+
+// DownloadSummary reports what DownloadRegion wrote
+type DownloadSummary struct {
+	Files      int
+	TotalBytes int64
+	Filtered   bool // Tiles were limited to the region's manifest
+}
+
+// Print logs the summary
+func (d *DownloadSummary) Print() {
+	slog.Info("download summary", "files", d.Files, "total_bytes", d.TotalBytes, "region_manifest", d.Filtered)
+}
+
+// DownloadRegion downloads a region's tiles from R2 into destDir as
+// {z}/{x}/{y}.pbf, the reverse of UploadToR2, along with the region's
+// metadata.json. Tiles of every region share one prefix, so the listing is
+// narrowed to the files in the region's manifest. A region uploaded without
+// -manifest is an error unless opts.All asks for every tile under the prefix,
+// which includes other regions' tiles.
+func (s *TileService) DownloadRegion(ctx context.Context, region, destDir string, opts DownloadOptions) (*DownloadSummary, error) {
+	prefix := s.config.S3.BucketPath + "/"
+	logger := slog.With("region", region, "dest_dir", destDir, "prefix", prefix)
+	logger.Info("starting R2 download")
+
+	manifest, found, err := s.FetchManifest(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	var wanted map[string]bool
+	if found {
+		wanted = make(map[string]bool, len(manifest))
+		for _, entry := range manifest {
+			wanted[entry.Path] = true
+		}
+	} else if opts.All {
+		logger.Warn("no manifest for region, downloading every tile under the prefix")
+	} else {
+		return nil, fmt.Errorf("no manifest for region %s on R2, so its tiles cannot be told from other regions' (pass -all to download every tile under the prefix)", region)
+	}
+
+	keys, err := s.s3.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Object key -> local path
+	files := make(map[string]string)
+	metadataKey := MetadataKey(s.config.S3.BucketPath, region)
+	for _, key := range keys {
+		if key == metadataKey {
+			files[key] = filepath.Join(destDir, MetadataFileName)
+			continue
+		}
+		relPath := strings.TrimPrefix(key, prefix)
+		if _, ok := tileCoordFromKey(relPath); !ok {
+			continue
+		}
+		if wanted != nil && !wanted[relPath] {
+			continue
+		}
+		files[key] = filepath.Join(destDir, filepath.FromSlash(relPath))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no tiles found on R2 for region %s", region)
+	}
+	logger.Info("found objects to download", "count", len(files))
+
+	summary := &DownloadSummary{Filtered: found}
+	if err := s.downloadFiles(ctx, files, opts, summary); err != nil {
+		return summary, err
+	}
+
+	logger.Info("R2 download completed", "files", summary.Files, "total_bytes", summary.TotalBytes)
+	return summary, nil
+}
+
+// downloadFiles downloads each key to its local path with a worker pool of
+// opts.Concurrency workers, counting into summary
+func (s *TileService) downloadFiles(ctx context.Context, files map[string]string, opts DownloadOptions, summary *DownloadSummary) error {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	work := make(chan string)
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultDownloadConcurrency
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range work {
+				size, err := s.s3.DownloadFile(ctx, key, files[key])
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					summary.Files++
+					summary.TotalBytes += size
+					if opts.Progress != nil {
+						opts.Progress(summary.Files, len(keys))
+					} else if summary.Files%1000 == 0 {
+						slog.Info("download progress", "files_downloaded", summary.Files, "total", len(keys))
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- key
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to download %d files: %w", len(keys)-summary.Files, errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadRegion(t *testing.T) {
+	fixtures := map[string]string{
+		"tiles/5/1/1.pbf":                 "oregon-a",
+		"tiles/6/2/3.pbf":                 "oregon-b",
+		"tiles/6/9/9.pbf":                 "washington",
+		"tiles/oregon/metadata.json":      `{"name":"oregon"}`,
+		"tiles/washington/metadata.json":  `{"name":"washington"}`,
+		"tiles/manifests/other/notes.txt": "not a tile",
+	}
+	manifest, err := json.Marshal([]ManifestEntry{{Path: "5/1/1.pbf"}, {Path: "6/2/3.pbf"}, {Path: MetadataFileName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		withManifest bool
+		all          bool
+		want         map[string]string // local path -> content
+		wantMissing  []string
+		wantErr      bool
+	}{
+		{
+			name:         "region manifest",
+			withManifest: true,
+			want: map[string]string{
+				"5/1/1.pbf":      "oregon-a",
+				"6/2/3.pbf":      "oregon-b",
+				MetadataFileName: `{"name":"oregon"}`,
+			},
+			wantMissing: []string{"6/9/9.pbf"},
+		},
+		{
+			name:    "no manifest",
+			wantErr: true,
+		},
+		{
+			name: "no manifest with all",
+			all:  true,
+			want: map[string]string{
+				"5/1/1.pbf":      "oregon-a",
+				"6/2/3.pbf":      "oregon-b",
+				"6/9/9.pbf":      "washington",
+				MetadataFileName: `{"name":"oregon"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			fake.seedObjects(fixtures)
+			if tt.withManifest {
+				fake.seedObjects(map[string]string{"tiles/manifests/oregon/manifest.json": string(manifest)})
+			}
+			service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+			dest := filepath.Join(t.TempDir(), "oregon")
+
+			summary, err := service.DownloadRegion(context.Background(), "oregon", dest, DownloadOptions{All: tt.all})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error without a manifest")
+				}
+				if _, statErr := os.Stat(dest); !os.IsNotExist(statErr) {
+					t.Error("expected nothing to be downloaded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Files != len(tt.want) || summary.Filtered != tt.withManifest {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			var wantBytes int64
+			for rel, content := range tt.want {
+				wantBytes += int64(len(content))
+				data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(rel)))
+				if err != nil || string(data) != content {
+					t.Errorf("%s: expected %q, got %q (%v)", rel, content, data, err)
+				}
+			}
+			if summary.TotalBytes != wantBytes {
+				t.Errorf("expected %d bytes, got %d", wantBytes, summary.TotalBytes)
+			}
+			for _, rel := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+					t.Errorf("expected %s not to be downloaded", rel)
+				}
+			}
+
+			// The downloaded tree reads like a generated one
+			coords, err := GetTileCoords(dest)
+			if err != nil || len(coords) != len(tt.want)-1 {
+				t.Errorf("expected %d tiles in the download, got %d (%v)", len(tt.want)-1, len(coords), err)
+			}
+		})
+	}
+}
+
+func TestDownloadRegion_NothingFound(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.seedObjects(map[string]string{"tiles/washington/metadata.json": "{}"})
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+
+	if _, err := service.DownloadRegion(context.Background(), "oregon", t.TempDir(), DownloadOptions{All: true}); err == nil {
+		t.Error("expected an error when R2 has nothing for the region")
+	}
+}
+
+func TestDownloadRegion_RetriesTransientFailures(t *testing.T) {
+	fixtures := map[string]string{}
+	for i := 0; i < 20; i++ {
+		fixtures[fmt.Sprintf("tiles/8/%d/1.pbf", i)] = fmt.Sprintf("tile-content-%02d", i)
+	}
+	fixtures["tiles/oregon/metadata.json"] = `{"name":"oregon"}`
+
+	tests := []struct {
+		name     string
+		failures int // Cut-off GETs of each flaky tile
+		attempts int
+		wantErr  bool
+	}{
+		{"recovers after one failure", 1, 3, false},
+		{"recovers on the last attempt", 2, 3, false},
+		{"gives up after max attempts", 3, 3, true},
+		{"no retries", 1, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			fake.seedObjects(fixtures)
+			flaky := []string{"tiles/8/3/1.pbf", "tiles/8/11/1.pbf", "tiles/oregon/metadata.json"}
+			for _, key := range flaky {
+				fake.failGets[key] = tt.failures
+			}
+			client.Retry = RetryPolicy{MaxAttempts: tt.attempts, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+			service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+			dest := t.TempDir()
+
+			var (
+				mu    sync.Mutex
+				calls []int
+			)
+			opts := DownloadOptions{Concurrency: 4, All: true, Progress: func(done, total int) {
+				mu.Lock()
+				defer mu.Unlock()
+				if total != len(fixtures) {
+					t.Errorf("expected progress total %d, got %d", len(fixtures), total)
+				}
+				calls = append(calls, done)
+			}}
+
+			summary, err := service.DownloadRegion(context.Background(), "oregon", dest, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			wantFiles := len(fixtures)
+			if tt.wantErr {
+				wantFiles -= len(flaky)
+			}
+			if summary.Files != wantFiles || len(calls) != wantFiles {
+				t.Errorf("expected %d files and progress calls, got %d and %d", wantFiles, summary.Files, len(calls))
+			}
+			for i, done := range calls {
+				if done != i+1 {
+					t.Errorf("expected progress to count up, got %v", calls)
+					break
+				}
+			}
+			for _, key := range flaky {
+				if want := min(tt.failures+1, tt.attempts); fake.gets[key] != want {
+					t.Errorf("%s: expected %d GETs, got %d", key, want, fake.gets[key])
+				}
+			}
+
+			// A failed attempt never leaves a partial file behind
+			data, err := os.ReadFile(filepath.Join(dest, "8", "3", "1.pbf"))
+			if tt.wantErr {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no file for a failed download, got %q (%v)", data, err)
+				}
+			} else if string(data) != "tile-content-03" {
+				t.Errorf("expected the full tile after retrying, got %q (%v)", data, err)
+			}
+			entries, _ := os.ReadDir(filepath.Join(dest, "8", "3"))
+			for _, entry := range entries {
+				if entry.Name() != "1.pbf" {
+					t.Errorf("unexpected leftover file %s", entry.Name())
+				}
+			}
+		})
+	}
+}
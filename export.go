@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// roadStreamer is the subset of Database used to export road geometries
+type roadStreamer interface {
+	StreamRoadGeometries(ctx context.Context, region string, fn func(RoadGeometry) error) error
+}
+
+// ExportRegionGeoJSON writes a region's roads from the database to w as a
+// GeoJSON FeatureCollection. Features are written as they are streamed from
+// the database, so the whole region is never held in memory. Each road is a
+// LineString from its start to its end point (or a Point at the bounding box
+// center when those are missing) with the same properties the tiles carry.
+// Returns the number of features written.
+func ExportRegionGeoJSON(ctx context.Context, db roadStreamer, region string, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+
+	if _, err := io.WriteString(bw, `{"type":"FeatureCollection","features":[`); err != nil {
+		return 0, fmt.Errorf("failed to write GeoJSON header: %w", err)
+	}
+
+	count := 0
+	err := db.StreamRoadGeometries(ctx, region, func(road RoadGeometry) error {
+		data, err := roadToFeature(road).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to marshal road %s: %w", road.RoadID, err)
+		}
+		if count > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+		count++
+		if count%100000 == 0 {
+			slog.Info("export progress", "region", region, "features", count)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to export road geometries: %w", err)
+	}
+
+	if _, err := io.WriteString(bw, "]}\n"); err != nil {
+		return count, fmt.Errorf("failed to write GeoJSON footer: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return count, fmt.Errorf("failed to flush GeoJSON: %w", err)
+	}
+
+	return count, nil
+}
+
+// ToGeoJSONFeature returns the road's bounding box as a rectangular Polygon
+// feature, for checking extracted boxes in a GIS tool. A box crossing the
+// antimeridian (MaxLng past 180) is split there into a MultiPolygon, as
+// RFC 7946 recommends, so no coordinate is out of range.
+func (r *RoadGeometry) ToGeoJSONFeature() *geojson.Feature {
+	var geometry orb.Geometry = orb.Polygon{bboxRing(r.MinLng, r.MinLat, r.MaxLng, r.MaxLat)}
+	if r.MaxLng > 180 {
+		geometry = orb.MultiPolygon{
+			{bboxRing(r.MinLng, r.MinLat, 180, r.MaxLat)},
+			{bboxRing(-180, r.MinLat, r.MaxLng-360, r.MaxLat)},
+		}
+	}
+	f := geojson.NewFeature(geometry)
+	f.Properties["roadId"] = r.RoadID
+	f.Properties["region"] = r.Region
+	if r.Curvature != nil {
+		f.Properties["curvature"] = *r.Curvature
+	}
+	return f
+}
+
+// bboxRing returns a closed counterclockwise ring around a bounding box
+func bboxRing(minLng, minLat, maxLng, maxLat float64) orb.Ring {
+	return orb.Ring{
+		{minLng, minLat},
+		{maxLng, minLat},
+		{maxLng, maxLat},
+		{minLng, maxLat},
+		{minLng, minLat},
+	}
+}
+
+// WriteRoadBoundsGeoJSON writes roads' bounding boxes to path as a GeoJSON
+// FeatureCollection, ordered by road ID
+func WriteRoadBoundsGeoJSON(path string, roads []RoadGeometry) error {
+	sorted := append([]RoadGeometry(nil), roads...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RoadID < sorted[j].RoadID })
+
+	fc := geojson.NewFeatureCollection()
+	for i := range sorted {
+		fc.Append(sorted[i].ToGeoJSONFeature())
+	}
+	data, err := fc.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal road bounds: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write road bounds GeoJSON: %w", err)
+	}
+	return nil
+}
+
+// roadToFeature converts a stored road back into a GeoJSON feature. A box
+// crossing the antimeridian (MaxLng past 180) gets its center wrapped into
+// range and, as RFC 7946 specifies, a bbox whose west edge is east of its
+// east edge.
+func roadToFeature(road RoadGeometry) *geojson.Feature {
+	var geom orb.Geometry
+	if road.StartLat != nil && road.StartLng != nil && road.EndLat != nil && road.EndLng != nil {
+		geom = orb.LineString{{*road.StartLng, *road.StartLat}, {*road.EndLng, *road.EndLat}}
+	} else {
+		geom = orb.Point{wrapLng((road.MinLng + road.MaxLng) / 2), (road.MinLat + road.MaxLat) / 2}
+	}
+
+	east := road.MaxLng
+	if east > 180 {
+		east = wrapLng(east)
+	}
+	f := geojson.NewFeature(geom)
+	f.BBox = geojson.BBox{road.MinLng, road.MinLat, east, road.MaxLat}
+	f.Properties["id"] = road.RoadID
+	f.Properties["Name"] = road.Name
+	if road.Curvature != nil {
+		f.Properties["curvature"] = *road.Curvature
+	}
+	if road.Length != nil {
+		f.Properties["length"] = *road.Length
+	}
+	if road.StartLat != nil && road.StartLng != nil {
+		f.Properties["startLat"] = *road.StartLat
+		f.Properties["startLng"] = *road.StartLng
+	}
+	if road.EndLat != nil && road.EndLng != nil {
+		f.Properties["endLat"] = *road.EndLat
+		f.Properties["endLng"] = *road.EndLng
+	}
+	return f
+}
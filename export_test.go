@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// pagedRoads serves keyset pages from an in-memory table and counts fetches
+type pagedRoads struct {
+	roads    []RoadGeometry
+	pageSize int
+	fetches  int
+}
+
+func newPagedRoads(n, pageSize int) *pagedRoads {
+	p := &pagedRoads{pageSize: pageSize}
+	for i := n - 1; i >= 0; i-- { // insert out of order; fetch must sort
+		start, end := float64(i), float64(i)+0.5
+		p.roads = append(p.roads, RoadGeometry{
+			RoadID: fmt.Sprintf("road-%03d", i),
+			Name:   fmt.Sprintf("Road %d", i),
+			Region: "oregon",
+			MinLat: 44, MaxLat: 45, MinLng: -123, MaxLng: -122,
+			StartLat: &start, StartLng: &start, EndLat: &end, EndLng: &end,
+		})
+	}
+	return p
+}
+
+func (p *pagedRoads) fetch(ctx context.Context, region, after string, limit int) ([]RoadGeometry, error) {
+	p.fetches++
+	sorted := append([]RoadGeometry(nil), p.roads...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RoadID < sorted[j].RoadID })
+
+	var page []RoadGeometry
+	for _, road := range sorted {
+		if road.Region == region && road.RoadID > after && len(page) < limit {
+			page = append(page, road)
+		}
+	}
+	return page, nil
+}
+
+func (p *pagedRoads) StreamRoadGeometries(ctx context.Context, region string, fn func(RoadGeometry) error) error {
+	return streamRoadPages(ctx, p.fetch, region, p.pageSize, fn)
+}
+
+func TestStreamRoadPages(t *testing.T) {
+	tests := []struct {
+		name        string
+		roads       int
+		pageSize    int
+		wantFetches int
+	}{
+		{"several pages", 7, 3, 3},
+		{"exact multiple", 6, 3, 3}, // final empty page confirms the end
+		{"single page", 2, 10, 1},
+		{"empty", 0, 5, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPagedRoads(tt.roads, tt.pageSize)
+
+			var got []string
+			err := p.StreamRoadGeometries(context.Background(), "oregon", func(road RoadGeometry) error {
+				got = append(got, road.RoadID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != tt.roads {
+				t.Errorf("expected %d roads, got %d", tt.roads, len(got))
+			}
+			if !sort.StringsAreSorted(got) {
+				t.Errorf("expected roads in roadId order, got %v", got)
+			}
+			if p.fetches != tt.wantFetches {
+				t.Errorf("expected %d fetches, got %d", tt.wantFetches, p.fetches)
+			}
+		})
+	}
+}
+
+func TestStreamRoadPages_CallbackErrorStops(t *testing.T) {
+	p := newPagedRoads(10, 3)
+	stop := errors.New("stop")
+
+	seen := 0
+	err := p.StreamRoadGeometries(context.Background(), "oregon", func(road RoadGeometry) error {
+		seen++
+		if seen == 4 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if p.fetches != 2 {
+		t.Errorf("expected streaming to stop after 2 fetches, got %d", p.fetches)
+	}
+}
+
+func TestStreamRoadGeometries_SQLite(t *testing.T) {
+	db := newRoadsTestDB(t)
+	db.streamPageSize = 3
+
+	var roads []RoadGeometry
+	for i := 9; i >= 0; i-- {
+		roads = append(roads, RoadGeometry{RoadID: fmt.Sprintf("road-%02d", i), Region: "oregon", MinLat: 44, MaxLat: 45, MinLng: -124, MaxLng: -123})
+	}
+	roads = append(roads, RoadGeometry{RoadID: "road-05", Region: "washington", MinLat: 46, MaxLat: 47, MinLng: -124, MaxLng: -123})
+	if _, err := db.BatchUpsertRoadGeometries(context.Background(), roads, RoadKeyRegional, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err := db.StreamRoadGeometries(context.Background(), "oregon", func(road RoadGeometry) error {
+		if road.Region != "oregon" {
+			t.Errorf("streamed road %s of region %s", road.RoadID, road.Region)
+		}
+		got = append(got, road.RoadID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 10 || !sort.StringsAreSorted(got) || got[0] != "road-00" {
+		t.Errorf("expected the 10 oregon roads in roadId order across pages, got %v", got)
+	}
+}
+
+func TestExportRegionGeoJSON(t *testing.T) {
+	p := newPagedRoads(5, 2)
+
+	var buf bytes.Buffer
+	count, err := ExportRegionGeoJSON(context.Background(), p, "oregon", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Errorf("expected 5 features, got %d", count)
+	}
+
+	fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	if err != nil {
+		t.Fatalf("export is not a valid FeatureCollection: %v", err)
+	}
+	if len(fc.Features) != 5 {
+		t.Fatalf("expected 5 features in output, got %d", len(fc.Features))
+	}
+
+	first := fc.Features[0]
+	if first.Properties.MustString("id") != "road-000" || first.Properties.MustString("Name") != "Road 0" {
+		t.Errorf("unexpected first feature properties: %v", first.Properties)
+	}
+	ls, ok := first.Geometry.(orb.LineString)
+	if !ok || len(ls) != 2 || ls[1] != (orb.Point{0.5, 0.5}) {
+		t.Errorf("expected start->end LineString, got %v", first.Geometry)
+	}
+}
+
+func TestExportRegionGeoJSON_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	count, err := ExportRegionGeoJSON(context.Background(), newPagedRoads(0, 5), "oregon", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	if err != nil {
+		t.Fatalf("empty export is not valid GeoJSON: %v", err)
+	}
+	if count != 0 || len(fc.Features) != 0 {
+		t.Errorf("expected no features, got %d", len(fc.Features))
+	}
+}
+
+func TestRoadToFeature_FallsBackToCenterPoint(t *testing.T) {
+	f := roadToFeature(RoadGeometry{RoadID: "a", MinLat: 10, MaxLat: 12, MinLng: 20, MaxLng: 24})
+	if p, ok := f.Geometry.(orb.Point); !ok || p != (orb.Point{22, 11}) {
+		t.Errorf("expected center point, got %v", f.Geometry)
+	}
+	if _, ok := f.Properties["startLat"]; ok {
+		t.Error("expected no start coordinates when they are missing")
+	}
+}
+
+func TestRoadToFeature_Antimeridian(t *testing.T) {
+	f := roadToFeature(RoadGeometry{RoadID: "a", MinLat: 51, MaxLat: 52, MinLng: 179, MaxLng: 183})
+	if p, ok := f.Geometry.(orb.Point); !ok || p != (orb.Point{-179, 51.5}) {
+		t.Errorf("expected the center wrapped to -179, got %v", f.Geometry)
+	}
+	if want := (geojson.BBox{179, 51, -177, 52}); fmt.Sprint(f.BBox) != fmt.Sprint(want) {
+		t.Errorf("expected bbox %v with west east of east, got %v", want, f.BBox)
+	}
+}
+
+func TestRoadGeometry_ToGeoJSONFeature(t *testing.T) {
+	curvature := "850"
+	road := RoadGeometry{RoadID: "road-1", Name: "Test Rd", Region: "oregon", MinLat: 44, MaxLat: 45, MinLng: -123, MaxLng: -122, Curvature: &curvature}
+
+	f := road.ToGeoJSONFeature()
+	poly, ok := f.Geometry.(orb.Polygon)
+	if !ok || len(poly) != 1 {
+		t.Fatalf("expected a single-ring polygon, got %#v", f.Geometry)
+	}
+	if got := poly.Bound(); got != (orb.Bound{Min: orb.Point{-123, 44}, Max: orb.Point{-122, 45}}) {
+		t.Errorf("expected the ring to span the bounding box, got %v", got)
+	}
+	if ring := poly[0]; len(ring) != 5 || ring[0] != ring[4] {
+		t.Errorf("expected a closed rectangle, got %v", ring)
+	}
+	want := geojson.Properties{"roadId": "road-1", "region": "oregon", "curvature": "850"}
+	if fmt.Sprint(f.Properties) != fmt.Sprint(want) {
+		t.Errorf("expected properties %v, got %v", want, f.Properties)
+	}
+
+	road.Curvature = nil
+	if _, ok := road.ToGeoJSONFeature().Properties["curvature"]; ok {
+		t.Error("expected no curvature property when the road has none")
+	}
+
+	// A box crossing the antimeridian is split at 180°
+	road = RoadGeometry{RoadID: "road-2", MinLat: 51, MaxLat: 52, MinLng: 179.5, MaxLng: 180.5}
+	multi, ok := road.ToGeoJSONFeature().Geometry.(orb.MultiPolygon)
+	if !ok || len(multi) != 2 {
+		t.Fatalf("expected two polygons, got %#v", road.ToGeoJSONFeature().Geometry)
+	}
+	if got := multi[0].Bound(); got != (orb.Bound{Min: orb.Point{179.5, 51}, Max: orb.Point{180, 52}}) {
+		t.Errorf("expected the east half up to 180, got %v", got)
+	}
+	if got := multi[1].Bound(); got != (orb.Bound{Min: orb.Point{-180, 51}, Max: orb.Point{-179.5, 52}}) {
+		t.Errorf("expected the west half from -180, got %v", got)
+	}
+}
+
+func TestRoadGeometry_ToGeoJSONFeature_Rectangles(t *testing.T) {
+	tests := []struct {
+		name  string
+		road  RoadGeometry
+		rings []orb.Bound // One per polygon
+	}{
+		{
+			"box",
+			RoadGeometry{MinLat: 47.60, MaxLat: 47.61, MinLng: -122.34, MaxLng: -122.32},
+			[]orb.Bound{{Min: orb.Point{-122.34, 47.60}, Max: orb.Point{-122.32, 47.61}}},
+		},
+		{
+			"degenerate box of a point",
+			RoadGeometry{MinLat: 10, MaxLat: 10, MinLng: 20, MaxLng: 20},
+			[]orb.Bound{{Min: orb.Point{20, 10}, Max: orb.Point{20, 10}}},
+		},
+		{
+			"crossing the antimeridian",
+			RoadGeometry{MinLat: 51, MaxLat: 52, MinLng: 179.5, MaxLng: 180.5},
+			[]orb.Bound{
+				{Min: orb.Point{179.5, 51}, Max: orb.Point{180, 52}},
+				{Min: orb.Point{-180, 51}, Max: orb.Point{-179.5, 52}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polygons []orb.Polygon
+			switch g := tt.road.ToGeoJSONFeature().Geometry.(type) {
+			case orb.Polygon:
+				polygons = []orb.Polygon{g}
+			case orb.MultiPolygon:
+				polygons = g
+			default:
+				t.Fatalf("unexpected geometry %#v", g)
+			}
+			if len(polygons) != len(tt.rings) {
+				t.Fatalf("expected %d polygons, got %d", len(tt.rings), len(polygons))
+			}
+			for i, poly := range polygons {
+				if len(poly) != 1 {
+					t.Fatalf("expected a single ring, got %d", len(poly))
+				}
+				ring := poly[0]
+				if len(ring) != 5 || ring[0] != ring[4] {
+					t.Errorf("expected a closed rectangle, got %v", ring)
+				}
+				if ring.Bound() != tt.rings[i] {
+					t.Errorf("polygon %d: expected %v, got %v", i, tt.rings[i], ring.Bound())
+				}
+				// RFC 7946 exterior rings are counterclockwise
+				if ring.Orientation() == orb.CW {
+					t.Errorf("polygon %d: expected a counterclockwise ring, got %v", i, ring)
+				}
+				for _, p := range ring {
+					if p.Lon() < -180 || p.Lon() > 180 {
+						t.Errorf("polygon %d: longitude %v out of range", i, p.Lon())
+					}
+					// Every corner is on the box's edge
+					if (p.Lon() != ring.Bound().Min.Lon() && p.Lon() != ring.Bound().Max.Lon()) ||
+						(p.Lat() != ring.Bound().Min.Lat() && p.Lat() != ring.Bound().Max.Lat()) {
+						t.Errorf("polygon %d: corner %v is not on the box", i, p)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExtractRoadGeometriesFromExistingTiles_BoundsGeoJSON(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeFixtureTile(t, dir)
+
+	service := NewTileService(nil, nil, &Config{})
+	service.BoundsGeoJSON = filepath.Join(t.TempDir(), "bounds.geojson")
+	if _, err := service.ExtractRoadGeometriesFromExistingTiles(context.Background(), dir, "seattle"); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := readFeatureCollection(t, service.BoundsGeoJSON)
+	if len(fc.Features) != 1 {
+		t.Fatalf("expected one box, got %d features", len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.Properties["roadId"] != "road-7" || f.Properties["region"] != "seattle" {
+		t.Errorf("unexpected properties %v", f.Properties)
+	}
+	// The fixture road runs from (47.60, -122.34) to (47.61, -122.32)
+	b := f.Geometry.Bound()
+	if b.Min.Lat() < 47.59 || b.Max.Lat() > 47.62 || b.Min.Lon() < -122.35 || b.Max.Lon() > -122.31 {
+		t.Errorf("expected the box around the fixture road, got %v", b)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+)
+
+// DefaultMaxInvalidRatio is the share of invalid roads above which an
+// extraction run is reported as failed
+const DefaultMaxInvalidRatio = 0.05
+
+// ExtractionSummary is the machine-readable result of an extraction run
+type ExtractionSummary struct {
+	Region         string `json:"region"`
+	TilesTotal     int    `json:"tilesTotal"`
+	TilesProcessed int    `json:"tilesProcessed"`
+	TilesFailed    int    `json:"tilesFailed"`
+	RoadsExtracted int    `json:"roadsExtracted"`
+	InvalidSkipped int    `json:"invalidSkipped"`
+	RoadsInserted  int    `json:"roadsInserted"`
+	TilesLimit     int    `json:"tilesLimit,omitempty"` // Set when only the first tiles were read
+	Scope          string `json:"scope,omitempty"`      // Set when only a tile or z/x column was read
+
+	// DurationSeconds is how long reading and decoding the tiles took
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// InvalidRatio is the share of decoded roads that were skipped as invalid
+func (s *ExtractionSummary) InvalidRatio() float64 {
+	total := s.RoadsExtracted + s.InvalidSkipped
+	if total == 0 {
+		return 0
+	}
+	return float64(s.InvalidSkipped) / float64(total)
+}
+
+// Check reports a run that most likely failed silently: no roads at all, or
+// more invalid roads than maxInvalidRatio allows (0 disables the ratio check)
+func (s *ExtractionSummary) Check(maxInvalidRatio float64) error {
+	if s.RoadsExtracted == 0 {
+		return fmt.Errorf("no roads extracted from %d tiles", s.TilesProcessed)
+	}
+	if maxInvalidRatio > 0 && s.InvalidRatio() > maxInvalidRatio {
+		return fmt.Errorf("invalid road ratio %.3f exceeds %.3f (%d invalid, %d extracted)",
+			s.InvalidRatio(), maxInvalidRatio, s.InvalidSkipped, s.RoadsExtracted)
+	}
+	return nil
+}
+
+// Print logs the summary as a single structured line
+func (s *ExtractionSummary) Print() {
+	slog.Info("extraction summary",
+		"region", s.Region,
+		"tiles_total", s.TilesTotal,
+		"tiles_processed", s.TilesProcessed,
+		"tiles_failed", s.TilesFailed,
+		"roads_extracted", s.RoadsExtracted,
+		"invalid_skipped", s.InvalidSkipped,
+		"roads_inserted", s.RoadsInserted,
+		"duration_seconds", math.Round(s.DurationSeconds*10)/10,
+	)
+	if s.TilesLimit > 0 {
+		slog.Warn("extraction was limited to the first tiles, roads outside them are missing",
+			"tiles_limit", s.TilesLimit,
+			"tiles_total", s.TilesTotal,
+		)
+	}
+	if s.Scope != "" {
+		slog.Warn("extraction was limited to a tile scope, roads outside it are missing", "scope", s.Scope)
+	}
+}
+
+// WriteJSON writes the summary as indented JSON
+func (s *ExtractionSummary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("failed to encode extraction summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestExtractionSummary_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		summary  ExtractionSummary
+		maxRatio float64
+		wantErr  bool
+	}{
+		{"healthy", ExtractionSummary{TilesProcessed: 10, RoadsExtracted: 100, InvalidSkipped: 2}, 0.05, false},
+		{"zero roads", ExtractionSummary{TilesProcessed: 10}, 0.05, true},
+		{"zero roads with ratio check off", ExtractionSummary{TilesProcessed: 10}, 0, true},
+		{"high invalid ratio", ExtractionSummary{TilesProcessed: 10, RoadsExtracted: 10, InvalidSkipped: 5}, 0.05, true},
+		{"high invalid ratio, check off", ExtractionSummary{TilesProcessed: 10, RoadsExtracted: 10, InvalidSkipped: 5}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.summary.Check(tt.maxRatio)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%v) error = %v, wantErr %v", tt.maxRatio, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeEquatorTile writes n roads lying exactly on the equator (lat 0), which
+// the extractor rejects as invalid zero coordinates
+func writeEquatorTile(t *testing.T, dir string, n int) {
+	t.Helper()
+	tile := maptile.New(0, 0, 1)
+	var features []*geojson.Feature
+	for i := 0; i < n; i++ {
+		f := geojson.NewFeature(orb.LineString{{100, 4096}, {200 + float64(i), 4096}})
+		f.Properties = geojson.Properties{"id": fmt.Sprintf("equator-%d", i)}
+		features = append(features, f)
+	}
+	data, err := EncodeTile(mvt.Layers{{Name: "roads", Version: 2, Extent: mvt.DefaultExtent, Features: features}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, tileRelPath(tile))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_Summary(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	t.Run("zero roads", func(t *testing.T) {
+		_, summary, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(ctx, NewDirTileSource(t.TempDir()), "empty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.RoadsExtracted != 0 || summary.TilesProcessed != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if err := summary.Check(DefaultMaxInvalidRatio); err == nil {
+			t.Error("expected zero roads to fail the check")
+		}
+	})
+
+	t.Run("high invalid ratio", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFixtureTile(t, dir)
+		writeEquatorTile(t, dir, 3)
+
+		roads, summary, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(ctx, NewDirTileSource(dir), "mixed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roads) != 1 || summary.RoadsExtracted != 1 || summary.InvalidSkipped != 3 || summary.TilesProcessed != 2 {
+			t.Fatalf("unexpected summary %+v", summary)
+		}
+		if err := summary.Check(DefaultMaxInvalidRatio); err == nil {
+			t.Error("expected a 75% invalid ratio to fail the check")
+		}
+		if err := summary.Check(0.8); err != nil {
+			t.Errorf("expected ratio under a 0.8 threshold to pass: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := summary.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded["invalidSkipped"] != 3.0 || decoded["roadsExtracted"] != 1.0 {
+			t.Errorf("unexpected JSON summary %s", buf.String())
+		}
+	})
+}
+
+func TestExtractRoadGeometriesFromTiles_Summary(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeFixtureTile(t, dir)
+	writeEquatorTile(t, dir, 2)
+	// An unreadable tile is counted as failed, not processed
+	bad := filepath.Join(dir, "3", "1", "1.pbf")
+	if err := os.MkdirAll(filepath.Dir(bad), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("not a tile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	roads, summary, err := NewGeometryExtractor().ExtractRoadGeometriesFromTiles(context.Background(), dir, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ExtractionSummary{
+		Region:         "stats",
+		TilesTotal:     3,
+		TilesProcessed: 2,
+		TilesFailed:    1,
+		RoadsExtracted: len(roads),
+		InvalidSkipped: 2,
+	}
+	got := *summary
+	if got.DurationSeconds <= 0 {
+		t.Errorf("expected the run duration to be recorded, got %v", got.DurationSeconds)
+	}
+	got.DurationSeconds = 0
+	if got != want || len(roads) != 1 {
+		t.Errorf("expected summary %+v with 1 road, got %+v with %d", want, got, len(roads))
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+// writeRoadGrid writes n zoom 12 tiles around Seattle. Each has a road of its
+// own plus a "shared" road that crosses every tile, so merging depends on
+// every tile's result.
+func writeRoadGrid(t *testing.T, dir string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		tile := maptile.New(uint32(650+i%8), uint32(1425+i/8), 12)
+		own := geojson.NewFeature(orb.LineString{{100, 100}, {float64(200 + i*10), 3000}})
+		own.Properties = geojson.Properties{"id": fmt.Sprintf("road-%d", i)}
+		shared := geojson.NewFeature(orb.LineString{{0, 2048}, {4096, 2048}})
+		shared.Properties = geojson.Properties{"id": "shared"}
+
+		data, err := EncodeTile(mvt.Layers{{Name: "roads", Version: 2, Extent: mvt.DefaultExtent, Features: []*geojson.Feature{own, shared}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, tileRelPath(tile))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// flakySource fails reads of some tiles and can cancel a context once a
+// number of tiles have been read
+type flakySource struct {
+	TileSource
+	fail        map[maptile.Tile]bool
+	cancelAfter int64
+	cancel      context.CancelFunc
+	reads       atomic.Int64
+}
+
+func (s *flakySource) ReadTile(ctx context.Context, tile maptile.Tile) ([]byte, error) {
+	if n := s.reads.Add(1); s.cancel != nil && n == s.cancelAfter {
+		s.cancel()
+	}
+	if s.fail[tile] {
+		return nil, errors.New("disk error")
+	}
+	return s.TileSource.ReadTile(ctx, tile)
+}
+
+func sortedRoads(roads []RoadGeometry) []RoadGeometry {
+	sort.Slice(roads, func(i, j int) bool { return roads[i].RoadID < roads[j].RoadID })
+	return roads
+}
+
+func TestExtractRoadGeometriesFromSource_Workers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeRoadGrid(t, dir, 40)
+
+	tiles, err := NewDirTileSource(dir).Tiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := map[maptile.Tile]bool{tiles[3]: true, tiles[17]: true}
+
+	extract := func(workers int) ([]RoadGeometry, *ExtractionSummary) {
+		e := NewGeometryExtractor()
+		e.Workers = workers
+		src := &flakySource{TileSource: NewDirTileSource(dir), fail: fail}
+		roads, summary, err := e.ExtractRoadGeometriesFromSource(context.Background(), src, fmt.Sprintf("workers-%d", workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sortedRoads(roads), summary
+	}
+
+	wantRoads, wantSummary := extract(1)
+	if wantSummary.TilesFailed != 2 || wantSummary.TilesProcessed != 38 || len(wantRoads) != 39 {
+		t.Fatalf("unexpected sequential result: %+v, %d roads", wantSummary, len(wantRoads))
+	}
+
+	for _, workers := range []int{2, 8, 64} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			roads, summary := extract(workers)
+			// Only the run time may differ from a single worker's
+			summary.Region = wantSummary.Region
+			summary.DurationSeconds = wantSummary.DurationSeconds
+			if *summary != *wantSummary {
+				t.Errorf("summary %+v, want %+v", summary, wantSummary)
+			}
+			for i := range roads {
+				roads[i].Region = wantRoads[i].Region
+			}
+			if !reflect.DeepEqual(roads, wantRoads) {
+				t.Errorf("expected the same roads as a single worker")
+			}
+		})
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_WorkersCancel(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeRoadGrid(t, dir, 40)
+
+	tiles, err := NewDirTileSource(dir).Tiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewGeometryExtractor()
+	e.Workers = 4
+	src := &flakySource{TileSource: NewDirTileSource(dir), cancelAfter: 10, cancel: cancel}
+
+	if _, _, err := e.ExtractRoadGeometriesFromSource(ctx, src, "cancelled"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The checkpoint covers a prefix of the tiles that were all merged
+	progress := e.loadProgress("cancelled")
+	if progress == nil {
+		t.Fatal("expected progress to be saved on cancellation")
+	}
+	if progress.ProcessedTiles >= 10 {
+		t.Errorf("expected fewer than 10 tiles merged, got %d", progress.ProcessedTiles)
+	}
+	if progress.ProcessedTiles > 0 {
+		if want := tileKey(tiles[progress.ProcessedTiles-1]); progress.LastProcessedTile == nil || *progress.LastProcessedTile != want {
+			t.Errorf("expected last processed tile %s, got %v", want, progress.LastProcessedTile)
+		}
+	}
+
+	// Workers stop once cancelled instead of reading the rest of the tiles
+	if reads := src.reads.Load(); reads >= int64(len(tiles)) {
+		t.Errorf("expected reading to stop early, read %d of %d tiles", reads, len(tiles))
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_WorkersFailFast(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeEquatorTile(t, dir, 60)
+	writeRoadGrid(t, dir, 40)
+
+	for _, workers := range []int{1, 8} {
+		e := NewGeometryExtractor()
+		e.Workers = workers
+		e.MaxInvalidRoads = 59
+		src := &flakySource{TileSource: NewDirTileSource(dir)}
+		_, _, err := e.ExtractRoadGeometriesFromSource(context.Background(), src, fmt.Sprintf("failfast-%d", workers))
+		if err == nil {
+			t.Errorf("%d workers: expected the invalid road limit to abort extraction", workers)
+		}
+	}
+}
+
+func TestGeometryExtractorWorkers(t *testing.T) {
+	if got := NewGeometryExtractor().workers(); got < 1 {
+		t.Errorf("expected at least one default worker, got %d", got)
+	}
+
+	cfg := &Config{Service: ServiceConfig{ExtractWorkers: 3}}
+	if got := NewTileService(nil, nil, cfg).newGeometryExtractor().workers(); got != 3 {
+		t.Errorf("expected EXTRACT_WORKERS to set 3 workers, got %d", got)
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_MaxTiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeRoadGrid(t, dir, 40)
+
+	e := NewGeometryExtractor()
+	e.MaxTiles = 5
+	roads, summary, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TilesProcessed != 5 || summary.TilesLimit != 5 || summary.TilesTotal != 40 {
+		t.Errorf("expected 5 of 40 tiles processed, got %+v", summary)
+	}
+
+	// The first tiles in z/x/y order are column 650, each road-i's own tile
+	// plus the shared road
+	var got []string
+	for _, road := range sortedRoads(roads) {
+		got = append(got, road.RoadID)
+	}
+	want := []string{"road-0", "road-16", "road-24", "road-32", "road-8", "shared"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected roads %v, got %v", want, got)
+	}
+
+	if _, err := os.Stat(e.getProgressFile("limited")); !os.IsNotExist(err) {
+		t.Errorf("expected a limited run to save no progress, got %v", err)
+	}
+
+	// A limit above the tile count reads everything and is not reported
+	e.MaxTiles = 100
+	_, summary, err = e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TilesProcessed != 40 || summary.TilesLimit != 0 {
+		t.Errorf("expected all 40 tiles processed, got %+v", summary)
+	}
+}
+
+func TestExtractRoadGeometries_PartialRunSkipsDatabase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeRoadGrid(t, dir, 40)
+	db := newRoadsTestDB(t)
+	countRows := func() int {
+		t.Helper()
+		var n int
+		if err := db.conn.QueryRow(`SELECT COUNT(*) FROM "RoadGeometry"`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	service := NewTileService(db, nil, nil)
+	service.MaxTiles = 5
+	summary, err := service.ExtractRoadGeometriesFromExistingTiles(context.Background(), dir, "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.RoadsInserted != 0 || countRows() != 0 {
+		t.Errorf("expected a limited run to insert nothing, got %d reported and %d rows", summary.RoadsInserted, countRows())
+	}
+	if _, err := os.Stat(".extracted-roads-limited.partial.json"); err != nil {
+		t.Errorf("expected the roads in the partial extraction file: %v", err)
+	}
+	if _, err := os.Stat(".extracted-roads-limited.json"); !os.IsNotExist(err) {
+		t.Errorf("expected the full extraction file untouched, got %v", err)
+	}
+
+	// A full run inserts every road
+	service.MaxTiles = 0
+	summary, err = service.ExtractRoadGeometriesFromExistingTiles(context.Background(), dir, "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.RoadsInserted == 0 || countRows() != summary.RoadsInserted {
+		t.Errorf("expected a full run to insert its roads, got %d reported and %d rows", summary.RoadsInserted, countRows())
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_Scope(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeRoadGrid(t, dir, 40)
+
+	tests := []struct {
+		scope     string
+		wantTiles int
+		wantRoads []string
+	}{
+		{"12/651/1425", 1, []string{"road-1", "shared"}},
+		{"12/650", 5, []string{"road-0", "road-16", "road-24", "road-32", "road-8", "shared"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			scope, err := ParseTileScope(tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := NewGeometryExtractor()
+			e.Scope = scope
+			roads, summary, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "scoped")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.TilesProcessed != tt.wantTiles || summary.TilesTotal != tt.wantTiles || summary.Scope != tt.scope {
+				t.Errorf("expected %d tiles processed in scope %s, got %+v", tt.wantTiles, tt.scope, summary)
+			}
+
+			var got []string
+			for _, road := range sortedRoads(roads) {
+				got = append(got, road.RoadID)
+			}
+			if !reflect.DeepEqual(got, tt.wantRoads) {
+				t.Errorf("expected roads %v, got %v", tt.wantRoads, got)
+			}
+			if _, err := os.Stat(e.getProgressFile("scoped")); !os.IsNotExist(err) {
+				t.Errorf("expected a scoped run to save no progress, got %v", err)
+			}
+		})
+	}
+
+	// A scope with no tiles fails rather than extracting nothing
+	e := NewGeometryExtractor()
+	e.Scope = &TileScope{Tile: maptile.New(0, 0, 5), Column: true}
+	if _, _, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "scoped"); err == nil {
+		t.Error("expected an empty scope to fail")
+	}
+}
@@ -3,14 +3,102 @@ package main
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
+// KMZ file naming in the curvature data directory: {prefix}{region}{suffix},
+// where the us- prefix marks US states
+const (
+	kmzUSPrefix = "us-"
+	kmzSuffix   = ".c_1000.curves.kmz"
+)
+
+// KMZRegion is a region with a KMZ file in the curvature data directory
+type KMZRegion struct {
+	Name       string    `json:"name"`
+	File       string    `json:"file"`
+	SizeBytes  int64     `json:"sizeBytes"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+}
+
+// ListKMZRegions lists the regions that have a KMZ file in curvatureDataDir,
+// sorted by name. A region with both a us- and a plain file is listed once,
+// with the us- file ExtractKMZFromDir would pick.
+func ListKMZRegions(curvatureDataDir string) ([]KMZRegion, error) {
+	entries, err := os.ReadDir(curvatureDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]KMZRegion)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, kmzSuffix) {
+			continue
+		}
+		name := strings.TrimSuffix(fileName, kmzSuffix)
+		isUS := strings.HasPrefix(name, kmzUSPrefix)
+		name = strings.ToLower(strings.TrimPrefix(name, kmzUSPrefix))
+		if name == "" {
+			continue
+		}
+		if existing, ok := byName[name]; ok && strings.HasPrefix(existing.File, kmzUSPrefix) && !isUS {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", fileName, err)
+		}
+		byName[name] = KMZRegion{Name: name, File: fileName, SizeBytes: info.Size(), ModifiedAt: info.ModTime().UTC()}
+	}
+
+	regions := make([]KMZRegion, 0, len(byName))
+	for _, region := range byName {
+		regions = append(regions, region)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Name < regions[j].Name })
+	return regions, nil
+}
+
+// validateRegion rejects region names that are unsafe to join into a path:
+// regions name KMZ files, tile directories and extraction files, so only
+// lowercase letters, digits, '-' and '_' are allowed
+func validateRegion(region string) error {
+	switch {
+	case region == "":
+		return errors.New("region is required")
+	case strings.ContainsAny(region, `/\`):
+		return fmt.Errorf("invalid region %q: must not contain path separators", region)
+	case strings.Contains(region, ".."):
+		return fmt.Errorf("invalid region %q: must not contain \"..\"", region)
+	}
+	for _, r := range region {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return fmt.Errorf("invalid region %q: only lowercase letters, digits, '-' and '_' are allowed", region)
+		}
+	}
+	return nil
+}
+
+// normalizeRegion lowercases and trims a user-supplied region name, as KMZ
+// lookup always has, and validates the result
+func normalizeRegion(region string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(region))
+	if err := validateRegion(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
 // ExtractKMZ extracts KMZ file to find doc.kml
 func ExtractKMZ(ctx context.Context, region string) (string, error) {
 	return ExtractKMZFromDir(ctx, region, "./curvature-data")
@@ -18,31 +106,117 @@ func ExtractKMZ(ctx context.Context, region string) (string, error) {
 
 // ExtractKMZFromDir extracts KMZ file from a specific directory to find doc.kml
 func ExtractKMZFromDir(ctx context.Context, region, curvatureDataDir string) (string, error) {
+	return ExtractKMZFromDirWithPattern(ctx, region, curvatureDataDir, "")
+}
+
+// ExtractKMZFromDirWithPattern extracts the region's KMZ file from
+// curvatureDataDir, found with FindKMZFile, and returns its doc.kml
+func ExtractKMZFromDirWithPattern(ctx context.Context, region, curvatureDataDir, pattern string) (string, error) {
+	kmzPath, err := FindKMZFile(region, curvatureDataDir, pattern)
+	if err != nil {
+		return "", err
+	}
+	return ExtractKMZFile(ctx, kmzPath, region)
+}
+
+// kmzRegionPlaceholder is replaced by the region in a KMZ file pattern
+const kmzRegionPlaceholder = "{region}"
+
+// FindKMZFile returns the path of region's KMZ file in curvatureDataDir.
+//
+// With a pattern (KMZ_PATTERN), {region} is replaced by the region and the
+// result is a glob within the directory, e.g. "{region}.c_300.curves.kmz" or
+// "{region}.c_*.curves.kmz"; of several matches the most recently modified is
+// used. A wildcard before {region} would also match other regions
+// ("*virginia*" matches west-virginia), so validateKMZPattern rejects one.
+// Without one, us-{region}.c_1000.curves.kmz (US states) and then
+// {region}.c_1000.curves.kmz (other regions like asia-japan) are tried.
+func FindKMZFile(region, curvatureDataDir, pattern string) (string, error) {
 	logger := slog.With("region", region, "data_dir", curvatureDataDir)
-	logger.Debug("extracting KMZ")
 
-	// Build KMZ file path - try multiple naming patterns
-	// First, try: us-{region}.c_1000.curves.kmz (for US states)
-	// Second, try: {region}.c_1000.curves.kmz (for other regions like asia-japan, canada-ontario)
 	regionLower := strings.ToLower(region)
+	if err := validateRegion(regionLower); err != nil {
+		return "", err
+	}
 
-	var kmzPath string
-	potentialNames := []string{
-		fmt.Sprintf("us-%s.c_1000.curves.kmz", regionLower),
-		fmt.Sprintf("%s.c_1000.curves.kmz", regionLower),
+	if pattern != "" {
+		glob := filepath.Join(curvatureDataDir, strings.ReplaceAll(pattern, kmzRegionPlaceholder, regionLower))
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return "", fmt.Errorf("invalid KMZ pattern %q: %w", pattern, err)
+		}
+		kmzPath, err := newestFile(matches)
+		if err != nil {
+			return "", err
+		}
+		if kmzPath == "" {
+			return "", fmt.Errorf("KMZ file not found for region '%s' in %s (pattern %s)", region, curvatureDataDir, pattern)
+		}
+		if len(matches) > 1 {
+			logger.Info("several KMZ files match, using the most recently modified", "pattern", pattern, "matches", len(matches), "kmz_path", kmzPath)
+		}
+		return kmzPath, nil
 	}
 
+	potentialNames := []string{
+		kmzUSPrefix + regionLower + kmzSuffix,
+		regionLower + kmzSuffix,
+	}
 	for _, name := range potentialNames {
 		path := filepath.Join(curvatureDataDir, name)
 		if _, err := os.Stat(path); err == nil {
-			kmzPath = path
-			break
+			return path, nil
 		}
 	}
+	return "", fmt.Errorf("KMZ file not found for region '%s' in %s", region, curvatureDataDir)
+}
 
-	if kmzPath == "" {
-		return "", fmt.Errorf("KMZ file not found for region '%s' in %s", region, curvatureDataDir)
+// newestFile returns the most recently modified regular file of paths ("" if
+// there is none). Ties go to the path that sorts first.
+func newestFile(paths []string) (string, error) {
+	var newest string
+	var newestTime time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
 	}
+	return newest, nil
+}
+
+// validateKMZPattern checks a KMZ_PATTERN: a valid glob that names the
+// region, so different regions do not share a file. A wildcard before the
+// region in its file name is rejected too, as "*{region}.kmz" for virginia
+// also matches west-virginia.kmz.
+func validateKMZPattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	i := strings.Index(pattern, kmzRegionPlaceholder)
+	if i < 0 {
+		return fmt.Errorf("pattern %q must contain %s", pattern, kmzRegionPlaceholder)
+	}
+	namePrefix := pattern[strings.LastIndex(pattern[:i], "/")+1 : i]
+	if strings.ContainsAny(namePrefix, `*?[\`) {
+		return fmt.Errorf("pattern %q has a wildcard before %s, which would also match other regions' files", pattern, kmzRegionPlaceholder)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// ExtractKMZFile extracts the KMZ file at kmzPath into a temporary directory
+// named after region and returns the path of its doc.kml
+func ExtractKMZFile(ctx context.Context, kmzPath, region string) (string, error) {
+	logger := slog.With("region", region, "kmz_path", kmzPath)
 
 	// Create temporary extraction directory
 	extractDir := filepath.Join(os.TempDir(), fmt.Sprintf("kmz-extract-%s-%d", region, os.Getpid()))
@@ -50,7 +224,7 @@ func ExtractKMZFromDir(ctx context.Context, region, curvatureDataDir string) (st
 		return "", fmt.Errorf("failed to create extraction directory: %w", err)
 	}
 
-	logger.Debug("KMZ file found", "path", kmzPath, "extract_dir", extractDir)
+	logger.Debug("extracting KMZ file", "extract_dir", extractDir)
 
 	// Open KMZ file
 	reader, err := zip.OpenReader(kmzPath)
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRegion(t *testing.T) {
+	tests := []struct {
+		region  string
+		wantErr string // "" = valid
+	}{
+		{"oregon", ""},
+		{"new-jersey", ""},
+		{"asia_japan-2", ""},
+		{"", "region is required"},
+		{"../../etc", "path separators"},
+		{"..", `".."`},
+		{"oregon..", `".."`},
+		{"tiles/oregon", "path separators"},
+		{`..\windows`, "path separators"},
+		{"/etc/passwd", "path separators"},
+		{"Oregon", "only lowercase"},
+		{"oregon.tiles", "only lowercase"},
+		{"oregon\x00", "only lowercase"},
+		{"ore gon", "only lowercase"},
+		{"~oregon", "only lowercase"},
+		{"oregón", "only lowercase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			err := validateRegion(tt.region)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected %q to be valid, got %v", tt.region, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q for %q, got %v", tt.wantErr, tt.region, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeRegion(t *testing.T) {
+	tests := []struct {
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{"oregon", "oregon", false},
+		{" Oregon\n", "oregon", false},
+		{"NEW-JERSEY", "new-jersey", false},
+		{"   ", "", true},
+		{"../Oregon", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeRegion(tt.region)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeRegion(%q) = %q, %v; want %q, error %v", tt.region, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExtractKMZFromDir_RejectsUnsafeRegion(t *testing.T) {
+	// A KMZ outside the data directory that a traversal could reach
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestKMZ(t, root, "secret")
+
+	for _, region := range []string{"../secret", "../us-secret", ""} {
+		if _, err := ExtractKMZFromDir(context.Background(), region, dataDir); err == nil || !strings.Contains(err.Error(), "region") {
+			t.Errorf("expected region %q to be rejected, got %v", region, err)
+		}
+	}
+}
+
+func TestFindKMZFile(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// File name -> age in hours (older files have larger ages)
+	files := map[string]int{
+		"us-oregon.c_1000.curves.kmz":   0,
+		"oregon.c_1000.curves.kmz":      0,
+		"oregon.c_300.curves.kmz":       2,
+		"oregon.c_300.v2.curves.kmz":    1,
+		"idaho.c_1000.curves.kmz":       0,
+		"washington.c_300.curves.kmz":   0,
+		"oregon-coast.c_300.curves.kmz": 0,
+	}
+	for name, age := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("kmz"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := base.Add(-time.Duration(age) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		region  string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"default prefers us-", "oregon", "", "us-oregon.c_1000.curves.kmz", false},
+		{"default without us-", "idaho", "", "idaho.c_1000.curves.kmz", false},
+		{"default ignores other thresholds", "washington", "", "", true},
+		{"template", "washington", "{region}.c_300.curves.kmz", "washington.c_300.curves.kmz", false},
+		{"template is case-insensitive on the region", "WASHINGTON", "{region}.c_300.curves.kmz", "washington.c_300.curves.kmz", false},
+		{"glob picks the newest", "oregon", "{region}.c_300*.kmz", "oregon.c_300.v2.curves.kmz", false},
+		{"glob does not cross regions", "oregon", "{region}.c_300.curves.kmz", "oregon.c_300.curves.kmz", false},
+		{"no match", "idaho", "{region}.c_300.curves.kmz", "", true},
+		{"bad glob", "oregon", "{region}[.kmz", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindKMZFile(tt.region, dir, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && got != filepath.Join(dir, tt.want) {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateKMZPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"", false},
+		{"{region}.c_300.curves.kmz", false},
+		{"{region}.c_*.curves.kmz", false},
+		{"us-{region}.c_*.curves.kmz", false},
+		{"*/{region}.kmz", false},
+		{"oregon.kmz", true},
+		{"{region}[.kmz", true},
+		{"*{region}*.kmz", true},
+		{"us-?{region}.kmz", true},
+		{"curves/[uw]s-{region}.kmz", true},
+	}
+
+	for _, tt := range tests {
+		if err := validateKMZPattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("validateKMZPattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExtractKMZFromDirWithPattern(t *testing.T) {
+	dataDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "oregon")
+	if err := os.Rename(filepath.Join(dataDir, "oregon.c_1000.curves.kmz"), filepath.Join(dataDir, "oregon.c_300.curves.kmz")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExtractKMZFromDir(context.Background(), "oregon", dataDir); err == nil {
+		t.Error("expected the default names not to find a c_300 file")
+	}
+	kmlPath, err := ExtractKMZFromDirWithPattern(context.Background(), "oregon", dataDir, "{region}.c_300.curves.kmz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(kmlPath))
+	if filepath.Base(kmlPath) != "doc.kml" {
+		t.Errorf("expected the extracted doc.kml, got %s", kmlPath)
+	}
+}
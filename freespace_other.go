@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// diskFreeSpace is not implemented on this platform
+func diskFreeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
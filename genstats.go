@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// GenerationStats describes the tiles a region would produce
+type GenerationStats struct {
+	Region   string
+	Roads    int
+	Metadata *TileMetadata
+	Report   *TileIntegrityReport
+}
+
+// Print logs the stats followed by the per-zoom generation report
+func (s *GenerationStats) Print() {
+	slog.Info("generation stats",
+		"region", s.Region,
+		"roads", s.Roads,
+		"tiles_count", s.Metadata.TilesCount,
+		"total_size_bytes", s.Metadata.TotalSize,
+		"min_zoom", s.Metadata.MinZoom,
+		"max_zoom", s.Metadata.MaxZoom,
+	)
+	s.Report.Print()
+}
+
+// GenerateStats converts a region and generates its tiles into a temporary
+// directory, measures them and deletes them again. Nothing is uploaded or
+// recorded in the database.
+func (s *TileService) GenerateStats(ctx context.Context, region string, opts *JobOptions) (*GenerationStats, error) {
+	logger := slog.With("region", region)
+	logger.Info("generating tiles for stats only")
+
+	kmlPath, err := ExtractKMZFromDirWithPattern(ctx, region, s.config.Paths.CurvatureData, s.config.Paths.KMZPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract KMZ: %w", err)
+	}
+
+	geoJSONPath, roadsCount, err := ConvertKMLToGeoJSONWithOptions(ctx, kmlPath, region, &ConvertOptions{Clip: opts.Clip, PreserveAltitude: opts.PreserveAltitude})
+	defer func() {
+		if opts.NoCleanup {
+			logger.Info("skipping cleanup (--no-cleanup flag set)", "kml_path", kmlPath, "geojson_path", geoJSONPath)
+			return
+		}
+		if err := CleanupTemporaryFiles(ctx, kmlPath, geoJSONPath, ""); err != nil {
+			logger.Warn("failed to cleanup temporary files", "error", err)
+		}
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert KML: %w", err)
+	}
+
+	outputDir, err := os.MkdirTemp(s.config.Paths.TempDir, "tile-stats-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary tiles directory: %w", err)
+	}
+	// The tiles are only measured, never kept
+	defer os.RemoveAll(outputDir)
+
+	genOpts := s.generateTilesOptions(region, opts)
+	tilesDir, _, _, err := GenerateTilesWithOptions(ctx, geoJSONPath, region, outputDir, genOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tiles: %w", err)
+	}
+
+	minZoom, maxZoom, _ := genOpts.resolve()
+	metadata, err := GetTileMetadata(tilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile metadata: %w", err)
+	}
+	metadata.MinZoom, metadata.MaxZoom = minZoom, maxZoom
+
+	report, err := VerifyTileDirectory(tilesDir, minZoom, maxZoom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build generation report: %w", err)
+	}
+
+	return &GenerationStats{Region: region, Roads: roadsCount, Metadata: metadata, Report: report}, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestKMZ writes a curvature KMZ for region with a single road into dir
+func writeTestKMZ(t *testing.T, dir, region string) {
+	t.Helper()
+	writeKMZ(t, dir, region, `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Test Road</name><Placemark><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0 -122.20,47.65,0</coordinates></LineString></Placemark></Folder>
+</Document></kml>`)
+}
+
+// writeKMZ writes kml as region's curvature KMZ into dir
+func writeKMZ(t *testing.T, dir, region, kml string) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, region+".c_1000.curves.kmz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("doc.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(kml))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateStats(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	dataDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "statsregion")
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	cfg := &Config{Paths: PathsConfig{CurvatureData: dataDir, OutputDir: outputDir}}
+	svc := NewTileService(nil, nil, cfg)
+	stats, err := svc.GenerateStats(context.Background(), "statsregion", &JobOptions{MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Roads != 1 || stats.Metadata.TilesCount == 0 || stats.Metadata.TotalSize == 0 {
+		t.Errorf("unexpected stats %+v / %+v", stats, stats.Metadata)
+	}
+	if stats.Metadata.MinZoom != 5 || stats.Metadata.MaxZoom != 8 || !stats.Report.OK {
+		t.Errorf("expected a passing report for zooms 5-8, got %+v", stats.Report)
+	}
+
+	stats.Print()
+	out := logs.String()
+	if !strings.Contains(out, `msg="generation stats" region=statsregion roads=1 tiles_count=`) || !strings.Contains(out, `msg="zoom level stats" zoom=8`) {
+		t.Errorf("expected stats and per-zoom report in the output, got:\n%s", out)
+	}
+
+	// Nothing is left behind: no temp tiles, KML or GeoJSON, and nothing in the output dir
+	for _, dir := range []string{tmp, outputDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected %s to be empty, found %v", dir, entries)
+		}
+	}
+
+	// The measured tiles go under TEMP_DIR
+	cfg.Paths.TempDir = filepath.Join(tmp, "missing")
+	if _, err := svc.GenerateStats(context.Background(), "statsregion", &JobOptions{MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo}); err == nil || !strings.Contains(err.Error(), cfg.Paths.TempDir) {
+		t.Errorf("expected the tiles directory to be created in TEMP_DIR, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// geoJSONArchiveTimeFormat names archived GeoJSON files. It sorts in time
+// order and has no characters that are awkward in file names.
+const geoJSONArchiveTimeFormat = "20060102T150405.000Z"
+
+// archiveGeoJSON copies a region's converted GeoJSON into
+// archiveDir/{region}/{timestamp}.geojson and prunes the region's archive to
+// the newest keep files. It returns the archived path.
+func archiveGeoJSON(geoJSONPath, archiveDir, region string, keep int, now time.Time) (string, error) {
+	if err := validateRegion(region); err != nil {
+		return "", err
+	}
+	regionDir := filepath.Join(archiveDir, region)
+	dst := filepath.Join(regionDir, now.UTC().Format(geoJSONArchiveTimeFormat)+".geojson")
+	if err := copyFile(geoJSONPath, dst); err != nil {
+		return "", fmt.Errorf("failed to archive GeoJSON: %w", err)
+	}
+	if _, err := pruneGeoJSONArchive(regionDir, keep); err != nil {
+		return dst, err
+	}
+	return dst, nil
+}
+
+// ListGeoJSONArchive returns the archived GeoJSON files in a region's archive
+// directory, oldest first. Files not named by archiveGeoJSON are ignored.
+func ListGeoJSONArchive(regionDir string) ([]string, error) {
+	entries, err := os.ReadDir(regionDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read GeoJSON archive: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutSuffix(name, ".geojson")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		if _, err := time.Parse(geoJSONArchiveTimeFormat, stamp); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(regionDir, name)
+	}
+	return paths, nil
+}
+
+// pruneGeoJSONArchive deletes all but the newest keep archived files in
+// regionDir and returns the paths removed
+func pruneGeoJSONArchive(regionDir string, keep int) ([]string, error) {
+	paths, err := ListGeoJSONArchive(regionDir)
+	if err != nil || len(paths) <= keep {
+		return nil, err
+	}
+
+	old := paths[:len(paths)-keep]
+	for _, path := range old {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to prune GeoJSON archive: %w", err)
+		}
+	}
+	return old, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveGeoJSON_Retention(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "oregon_roads.geojson")
+	if err := os.WriteFile(src, []byte(`{"type":"FeatureCollection","features":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		runs int
+		keep int
+	}{
+		{"under the limit", 2, 3},
+		{"at the limit", 3, 3},
+		{"over the limit", 7, 3},
+		{"keep one", 4, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archiveDir := t.TempDir()
+			regionDir := filepath.Join(archiveDir, "oregon")
+			// Other files in the archive are never pruned
+			if err := os.MkdirAll(regionDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			notes := filepath.Join(regionDir, "notes.geojson")
+			if err := os.WriteFile(notes, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var archived []string
+			for i := 0; i < tt.runs; i++ {
+				path, err := archiveGeoJSON(src, archiveDir, "oregon", tt.keep, start.Add(time.Duration(i)*time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+				archived = append(archived, path)
+			}
+
+			got, err := ListGeoJSONArchive(regionDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := archived[max(0, tt.runs-tt.keep):]
+			if len(got) != len(want) {
+				t.Fatalf("expected %d archived files, got %v", len(want), got)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("expected the newest files %v, got %v", want, got)
+					break
+				}
+			}
+			if _, err := os.Stat(notes); err != nil {
+				t.Errorf("expected unrelated files to be kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestArchiveGeoJSON_Naming(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "roads.geojson")
+	if err := os.WriteFile(src, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 30, 5, 250e6, time.FixedZone("PST", -8*3600))
+
+	path, err := archiveGeoJSON(src, archiveDir, "oregon", 5, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(archiveDir, "oregon", "20260301T203005.250Z.geojson"); path != want {
+		t.Errorf("expected %s, got %s", want, path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "{}" {
+		t.Errorf("expected a copy of the GeoJSON, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("expected the source to be left for the pipeline: %v", err)
+	}
+
+	if _, err := archiveGeoJSON(src, archiveDir, "../oregon", 5, now); err == nil {
+		t.Error("expected an unsafe region to be rejected")
+	}
+}
+
+func TestProcessJobWithOptions_ArchivesGeoJSON(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("TMPDIR", t.TempDir())
+	dataDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "archived")
+	archiveDir := t.TempDir()
+
+	// An earlier run's file is pruned once the new one takes its place
+	regionDir := filepath.Join(archiveDir, "archived")
+	if err := os.MkdirAll(regionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(regionDir, "20250101T000000.000Z.geojson")
+	if err := os.WriteFile(old, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewTileService(nil, nil, &Config{Paths: PathsConfig{
+		CurvatureData: dataDir, OutputDir: t.TempDir(),
+		GeoJSONArchiveDir: archiveDir, GeoJSONRetention: 1,
+	}})
+	_, err := svc.ProcessJobWithOptions(context.Background(), &TileJob{ID: "job-1", Region: "archived"}, &JobOptions{
+		MinZoom: 5, MaxZoom: 6, Encoder: EncoderGo, SkipUpload: true, SkipMerge: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListGeoJSONArchive(regionDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] == old {
+		t.Fatalf("expected only the new GeoJSON to be kept, got %v", got)
+	}
+	if info, err := os.Stat(got[0]); err != nil || info.Size() == 0 {
+		t.Errorf("expected the converted GeoJSON in the archive: %v", err)
+	}
+	// The intermediate file is still cleaned up as usual
+	if path := latestIntermediateGeoJSON("archived"); path != "" {
+		t.Errorf("expected the intermediate GeoJSON to be removed, found %s", path)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+// accuracyFixture is a road with known real-world coordinates used to check
+// calculateBounds against ground truth rather than mere plausibility.
+type accuracyFixture struct {
+	name   string
+	zoom   maptile.Zoom
+	points []orb.Point // lng, lat
+}
+
+// accuracyFixtures covers several latitudes (both hemispheres) and zoom levels.
+var accuracyFixtures = []accuracyFixture{
+	{
+		name: "Tail of the Dragon, Tennessee",
+		zoom: 12,
+		points: []orb.Point{
+			{-83.9365, 35.4651},
+			{-83.9402, 35.4688},
+			{-83.9447, 35.4712},
+			{-83.9481, 35.4745},
+		},
+	},
+	{
+		name: "Highway 1 near Bixby Bridge, California",
+		zoom: 14,
+		points: []orb.Point{
+			{-121.9018, 36.3715},
+			{-121.9012, 36.3698},
+			{-121.9003, 36.3682},
+		},
+	},
+	{
+		name: "Stelvio Pass, Italy",
+		zoom: 10,
+		points: []orb.Point{
+			{10.4530, 46.5287},
+			{10.4571, 46.5301},
+			{10.4612, 46.5279},
+			{10.4655, 46.5263},
+		},
+	},
+	{
+		name: "Trollstigen, Norway",
+		zoom: 9,
+		points: []orb.Point{
+			{7.6622, 62.4565},
+			{7.6655, 62.4540},
+			{7.6690, 62.4512},
+		},
+	},
+	{
+		name: "Great Ocean Road, Australia",
+		zoom: 11,
+		points: []orb.Point{
+			{143.3920, -38.6801},
+			{143.3985, -38.6842},
+			{143.4051, -38.6870},
+		},
+	},
+	{
+		name: "Dalton Highway, Alaska",
+		zoom: 8,
+		points: []orb.Point{
+			{-149.7790, 68.6210},
+			{-149.7450, 68.6480},
+			{-149.7120, 68.6750},
+		},
+	},
+}
+
+// lngLatToTileSpace projects a lng/lat point into unquantized tile-space
+// coordinates (0..extent) for the given tile. It is the exact inverse of the
+// Web Mercator math in calculateBounds.
+func lngLatToTileSpace(p orb.Point, tile maptile.Tile, extent float64) orb.Point {
+	n := math.Pow(2.0, float64(tile.Z))
+	latRad := p.Lat() * math.Pi / 180.0
+
+	worldX := (p.Lon() + 180.0) / 360.0 * n
+	worldY := (1.0 - math.Log(math.Tan(latRad)+1.0/math.Cos(latRad))/math.Pi) / 2.0 * n
+
+	return orb.Point{
+		(worldX - float64(tile.X)) * extent,
+		(worldY - float64(tile.Y)) * extent,
+	}
+}
+
+// trueBound returns the lng/lat bounding box of the fixture points
+func (f accuracyFixture) trueBound() orb.Bound {
+	return orb.LineString(f.points).Bound()
+}
+
+// tile returns the tile containing the first point of the fixture
+func (f accuracyFixture) tile() maptile.Tile {
+	return maptile.At(f.points[0], f.zoom)
+}
+
+// boundErrorMeters returns the largest distance between corresponding corners
+// of the expected and actual bounds.
+func boundErrorMeters(expected, actual orb.Bound) float64 {
+	sw := haversineDistance(expected.Min.Lat(), expected.Min.Lon(), actual.Min.Lat(), actual.Min.Lon())
+	ne := haversineDistance(expected.Max.Lat(), expected.Max.Lon(), actual.Max.Lat(), actual.Max.Lon())
+	return math.Max(sw, ne)
+}
+
+// TestCalculateBoundsAccuracy checks calculateBounds against the known
+// coordinates of real roads using unquantized tile-space input. Any error here
+// comes from the projection math itself, so the tolerance is tight (1 meter).
+func TestCalculateBoundsAccuracy(t *testing.T) {
+	extractor := NewGeometryExtractor()
+	const toleranceMeters = 1.0
+
+	for _, fx := range accuracyFixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			tile := fx.tile()
+
+			line := make(orb.LineString, 0, len(fx.points))
+			for _, p := range fx.points {
+				line = append(line, lngLatToTileSpace(p, tile, 4096))
+			}
+
+			bounds := extractor.calculateBounds(line, tile)
+			if bounds == nil {
+				t.Fatal("calculateBounds returned nil")
+			}
+
+			expected := fx.trueBound()
+			if errM := boundErrorMeters(expected, *bounds); errM > toleranceMeters {
+				t.Errorf("bounds off by %.3fm (tolerance %.1fm)\n  expected: %v\n  actual:   %v",
+					errM, toleranceMeters, expected, *bounds)
+			}
+		})
+	}
+}
+
+// TestTileRoundTripAccuracy encodes each fixture into a real MVT tile, decodes
+// it, and runs the extractor over the file. MVT quantizes coordinates to
+// integer tile-space units (truncating, not rounding), so each corner may be
+// off by up to one unit on both axes at the fixture's zoom and latitude.
+func TestTileRoundTripAccuracy(t *testing.T) {
+	extractor := NewGeometryExtractor()
+
+	for _, fx := range accuracyFixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			tile := fx.tile()
+
+			// ProjectToTile rewrites coordinates in place, so encode a copy
+			line := orb.LineString(fx.points).Clone()
+
+			fc := geojson.NewFeatureCollection()
+			feature := geojson.NewFeature(line)
+			feature.Properties["id"] = "fixture-road"
+			feature.Properties["Name"] = fx.name
+			fc.Append(feature)
+
+			layers := mvt.NewLayers(map[string]*geojson.FeatureCollection{"roads": fc})
+			layers.ProjectToTile(tile)
+
+			data, err := EncodeTile(layers)
+			if err != nil {
+				t.Fatalf("failed to marshal tile: %v", err)
+			}
+
+			dir := filepath.Join(t.TempDir(), "fixture",
+				strconv.Itoa(int(tile.Z)), strconv.Itoa(int(tile.X)))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			tilePath := filepath.Join(dir, strconv.Itoa(int(tile.Y))+".pbf")
+			if err := os.WriteFile(tilePath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			roads, invalid, err := extractor.extractRoadsFromTile(tilePath, "fixture", tile)
+			if err != nil {
+				t.Fatalf("extractRoadsFromTile failed: %v", err)
+			}
+			if invalid != 0 || len(roads) != 1 {
+				t.Fatalf("expected 1 valid road, got %d (invalid %d)", len(roads), invalid)
+			}
+
+			road := roads[0]
+			actual := orb.Bound{
+				Min: orb.Point{road.MinLng, road.MinLat},
+				Max: orb.Point{road.MaxLng, road.MaxLat},
+			}
+
+			// One tile-space unit in meters at this latitude and zoom, allowed on
+			// both axes
+			tileWidthMeters := haversineDistance(
+				fx.points[0].Lat(), tile.Bound().Min.Lon(),
+				fx.points[0].Lat(), tile.Bound().Max.Lon())
+			tolerance := math.Sqrt2 * tileWidthMeters / 4096.0
+
+			if errM := boundErrorMeters(fx.trueBound(), actual); errM > tolerance {
+				t.Errorf("round-trip bounds off by %.3fm (tolerance %.3fm)", errM, tolerance)
+			}
+		})
+	}
+}
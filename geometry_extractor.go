@@ -8,11 +8,14 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/paulmach/orb"
-	"github.com/paulmach/orb/encoding/mvt"
 	"github.com/paulmach/orb/maptile"
 )
 
@@ -33,6 +36,39 @@ type RoadGeometry struct {
 	EndLng    *float64 `json:"endLng,omitempty"`
 }
 
+// BBoxDiagonalMeters returns the length of the bounding box diagonal in meters
+func (r *RoadGeometry) BBoxDiagonalMeters() float64 {
+	return haversineDistance(r.MinLat, r.MinLng, r.MaxLat, r.MaxLng)
+}
+
+// BBoxAreaSqMeters returns the approximate area of the bounding box in square meters
+func (r *RoadGeometry) BBoxAreaSqMeters() float64 {
+	width := haversineDistance(r.MinLat, r.MinLng, r.MinLat, r.MaxLng)
+	height := haversineDistance(r.MinLat, r.MinLng, r.MaxLat, r.MinLng)
+	return width * height
+}
+
+// DefaultMaxBBoxMeters is the bounding box diagonal above which an extracted
+// road is flagged as suspicious. Real curvy road segments are a few tens of
+// kilometers at most; boxes spanning hundreds usually mean unrelated roads
+// with the same ID were merged.
+const DefaultMaxBBoxMeters = 200000.0
+
+// FindOversizedRoads returns roads whose bounding box diagonal exceeds
+// maxMeters, largest first
+func FindOversizedRoads(roads []RoadGeometry, maxMeters float64) []RoadGeometry {
+	var oversized []RoadGeometry
+	for _, road := range roads {
+		if road.BBoxDiagonalMeters() > maxMeters {
+			oversized = append(oversized, road)
+		}
+	}
+	sort.Slice(oversized, func(i, j int) bool {
+		return oversized[i].BBoxDiagonalMeters() > oversized[j].BBoxDiagonalMeters()
+	})
+	return oversized
+}
+
 // ExtractionProgress tracks the extraction process
 type ExtractionProgress struct {
 	Region           string  `json:"region"`
@@ -44,37 +80,186 @@ type ExtractionProgress struct {
 	Status           string  `json:"status"` // "extracting", "inserting", "complete"
 }
 
+// DefaultMaxInvalidRoads is how many invalid roads abort an extraction run
+const DefaultMaxInvalidRoads = 100
+
+// DefaultInvalidWarnRatio is the invalid-to-decoded road ratio that triggers
+// the first warning during extraction
+const DefaultInvalidWarnRatio = 0.01
+
+// minInvalidRatioSample is how many decoded roads are needed before the
+// invalid ratio is considered meaningful
+const minInvalidRatioSample = 50
+
 // GeometryExtractor handles road geometry extraction from vector tiles
 type GeometryExtractor struct {
 	logger *slog.Logger
+
+	// MaxBBoxMeters flags extracted roads with a larger bounding box diagonal (0 = off)
+	MaxBBoxMeters float64
+
+	// MaxInvalidRoads aborts extraction once more roads than this are invalid (0 = off)
+	MaxInvalidRoads int
+
+	// InvalidWarnRatio warns when the invalid ratio reaches it, escalating
+	// each time the ratio doubles (0 = off)
+	InvalidWarnRatio float64
+
+	// LogCoordDecimals rounds coordinates in diagnostic logs to this many
+	// decimals (0 = full precision)
+	LogCoordDecimals int
+
+	// Progress receives the tiles done so far in place of the periodic
+	// checkpoint logs (nil = log)
+	Progress ProgressFunc
+
+	// Workers is how many tiles are read and decoded at once (0 = one per CPU)
+	Workers int
+
+	// MaxTiles limits extraction to the first tiles in z/x/y order, for quick
+	// debugging runs (0 = all). A limited run starts fresh, saves no progress
+	// and writes its roads to a separate partial extraction file, so a later
+	// full run neither resumes from it nor loses its own file.
+	MaxTiles int
+
+	// Scope limits extraction to one tile or one z/x column, for debugging a
+	// specific road (nil = all). Like MaxTiles, a scoped run saves no
+	// progress and writes the partial extraction file.
+	Scope *TileScope
+
+	// MetadataCheck is what happens when the tileset's metadata declares a
+	// format, scheme or projection extraction would read wrongly (empty = off)
+	MetadataCheck MetadataCheck
+
+	// KeyStrategy is how extracted roads are keyed: by road ID and region
+	// (the default), or by RoadKeyGlobal so overlapping regions share a row
+	KeyStrategy RoadKeyStrategy
 }
 
 // NewGeometryExtractor creates a new geometry extractor
 func NewGeometryExtractor() *GeometryExtractor {
 	return &GeometryExtractor{
-		logger: slog.Default(),
+		logger:           slog.Default(),
+		MaxBBoxMeters:    DefaultMaxBBoxMeters,
+		MaxInvalidRoads:  DefaultMaxInvalidRoads,
+		InvalidWarnRatio: DefaultInvalidWarnRatio,
+		MetadataCheck:    MetadataCheckError,
 	}
 }
 
-// ExtractRoadGeometriesFromTiles extracts road bounding boxes from all tiles in a directory
-func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context, tilesDir, region string) ([]RoadGeometry, error) {
-	logger := e.logger.With("region", region, "tiles_dir", tilesDir)
+// invalidRatioLevel returns how many times the invalid ratio has doubled past
+// warnRatio: 0 below it, 1 at warnRatio, 2 at twice it, 3 at four times it...
+func invalidRatioLevel(invalid, decoded int, warnRatio float64) int {
+	if warnRatio <= 0 || decoded < minInvalidRatioSample || invalid == 0 {
+		return 0
+	}
+	ratio := float64(invalid) / float64(decoded)
+	level := 0
+	for threshold := warnRatio; ratio >= threshold && threshold <= 1; threshold *= 2 {
+		level++
+	}
+	return level
+}
+
+// logCoord rounds a coordinate for diagnostic logging
+func (e *GeometryExtractor) logCoord(v float64) float64 {
+	if e.LogCoordDecimals <= 0 {
+		return v
+	}
+	scale := math.Pow(10, float64(e.LogCoordDecimals))
+	return math.Round(v*scale) / scale
+}
+
+// boundsAttr logs a geographic bound as a "bounds" group
+func (e *GeometryExtractor) boundsAttr(b orb.Bound) slog.Attr {
+	return slog.Group("bounds",
+		"min_lat", e.logCoord(b.Min.Lat()),
+		"max_lat", e.logCoord(b.Max.Lat()),
+		"min_lng", e.logCoord(b.Min.Lon()),
+		"max_lng", e.logCoord(b.Max.Lon()),
+	)
+}
+
+// tileSpaceAttr logs the raw tile-space extent of a geometry (0..extent, y
+// pointing down) as a "tile_space" group, before any projection
+func tileSpaceAttr(geom orb.Geometry, extent float64) slog.Attr {
+	b := geom.Bound()
+	return slog.Group("tile_space",
+		"min_x", b.Min[0],
+		"min_y", b.Min[1],
+		"max_x", b.Max[0],
+		"max_y", b.Max[1],
+		"extent", extent,
+	)
+}
+
+// ExtractRoadGeometriesFromTiles extracts road bounding boxes from all tiles
+// in a directory, or in an MBTiles file when tilesDir ends in .mbtiles, and
+// summarizes the run
+func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context, tilesDir, region string) ([]RoadGeometry, *ExtractionSummary, error) {
+	src, err := OpenTileSource(tilesDir, SchemeXYZ)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	return e.ExtractRoadGeometriesFromSource(ctx, src, region)
+}
+
+// ExtractRoadGeometriesFromSource extracts road bounding boxes from every
+// tile in a tile source and summarizes the run
+func (e *GeometryExtractor) ExtractRoadGeometriesFromSource(ctx context.Context, src TileSource, region string) ([]RoadGeometry, *ExtractionSummary, error) {
+	logger := e.logger.With("region", region)
 	logger.Info("starting road geometry extraction from tiles")
+	start := time.Now()
+
+	if e.MetadataCheck != "" {
+		if err := checkTilesetMetadata(ctx, src, e.MetadataCheck, logger); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	// Find all .pbf files
-	pbfFiles, err := e.findPBFFiles(tilesDir)
+	tiles, err := src.Tiles(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find PBF files: %w", err)
+		return nil, nil, fmt.Errorf("failed to list tiles: %w", err)
 	}
 
-	logger.Info("found tiles", "count", len(pbfFiles))
+	logger.Info("found tiles", "count", len(tiles))
+
+	if e.Scope != nil {
+		tiles = e.Scope.Filter(tiles)
+		if len(tiles) == 0 {
+			return nil, nil, fmt.Errorf("no tiles found in scope %s", e.Scope)
+		}
+		logger.Warn("extraction limited to a scope", "scope", e.Scope.String(), "tiles", len(tiles))
+	}
+
+	found := len(tiles)
+	limited := e.MaxTiles > 0
+	if limited {
+		sortTiles(tiles)
+		if len(tiles) > e.MaxTiles {
+			tiles = tiles[:e.MaxTiles]
+			logger.Warn("extraction limited to the first tiles", "max_tiles", e.MaxTiles, "found", found)
+		}
+	}
+	// A run over only some of the tiles neither resumes nor saves progress
+	partial := e.partial()
+	saveProgress := func(progress *ExtractionProgress) {
+		if !partial {
+			e.saveProgress(progress)
+		}
+	}
 
 	// Load or initialize progress
-	progress := e.loadProgress(region)
+	var progress *ExtractionProgress
+	if !partial {
+		progress = e.loadProgress(region)
+	}
 	if progress == nil {
 		progress = &ExtractionProgress{
 			Region:         region,
-			TotalTiles:     len(pbfFiles),
+			TotalTiles:     len(tiles),
 			ProcessedTiles: 0,
 			ExtractedRoads: 0,
 			StartedAt:      int64(os.Getpid()),
@@ -85,11 +270,14 @@ func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context,
 		logger.Info("resuming extraction", "processed", progress.ProcessedTiles, "total", progress.TotalTiles)
 	}
 
-	// Map to deduplicate roads across tiles
-	roadsMap := make(map[string]*RoadGeometry)
+	// Deduplicate roads across tiles; workers merge into it as they decode
+	roadsMap := newRoadShards()
 
 	// Track invalid roads with zero coordinates
 	invalidRoadCount := 0
+	decodedRoadCount := 0
+	invalidLevel := 0
+	failedTiles := 0
 
 	// Load existing roads from extraction file if resuming
 	extractionFile := e.getExtractionFile(region)
@@ -98,9 +286,9 @@ func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context,
 		if err != nil {
 			logger.Warn("failed to load existing roads", "error", err)
 		} else {
+			// Roads from earlier runs come before any tile of this one
 			for _, road := range existingRoads {
-				key := fmt.Sprintf("%s_%s", road.RoadID, road.Region)
-				roadsMap[key] = &road
+				roadsMap.merge(road, -1)
 			}
 			logger.Info("loaded existing roads", "count", len(existingRoads))
 		}
@@ -109,69 +297,97 @@ func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context,
 	// Find starting index
 	startIndex := 0
 	if progress.LastProcessedTile != nil {
-		for i, file := range pbfFiles {
-			if file == *progress.LastProcessedTile {
+		for i, tile := range tiles {
+			if tileKey(tile) == *progress.LastProcessedTile {
 				startIndex = i + 1
 				break
 			}
 		}
+		if startIndex == 0 {
+			logger.Warn("last processed tile not found, reading all tiles again", "last_processed_tile", *progress.LastProcessedTile)
+		}
+	}
+
+	// Tiles are read and decoded by a worker pool, which merges roads into
+	// the shards concurrently. Results are accounted for here in tile order
+	// so LastProcessedTile always ends a fully processed prefix.
+	workCtx, stopWorkers := context.WithCancel(ctx)
+	results, waitWorkers := e.decodeTiles(workCtx, src, region, tiles, startIndex, roadsMap)
+	defer func() {
+		stopWorkers()
+		waitWorkers()
+	}()
+
+	cancelled := func() ([]RoadGeometry, *ExtractionSummary, error) {
+		logger.Info("extraction cancelled")
+		progress.ExtractedRoads = roadsMap.len()
+		saveProgress(progress)
+		return nil, nil, ctx.Err()
 	}
 
 	// Process tiles
-	for i := startIndex; i < len(pbfFiles); i++ {
-		select {
-		case <-ctx.Done():
-			logger.Info("extraction cancelled")
-			e.saveProgress(progress)
-			return nil, ctx.Err()
-		default:
+	for i := startIndex; i < len(tiles); i++ {
+		if ctx.Err() != nil {
+			return cancelled()
 		}
 
-		pbfFile := pbfFiles[i]
+		if e.Progress != nil {
+			e.Progress(i, len(tiles))
+		}
 
-		// Extract tile coordinates from path
-		tileCoords, err := e.parseTilePath(pbfFile)
-		if err != nil {
-			logger.Warn("failed to parse tile path", "file", pbfFile, "error", err)
-			continue
+		tileCoords := tiles[i]
+		key := tileKey(tileCoords)
+
+		var result tileResult
+		select {
+		case <-ctx.Done():
+			return cancelled()
+		case slot := <-results:
+			select {
+			case <-ctx.Done():
+				return cancelled()
+			case result = <-slot:
+			}
 		}
 
-		// Process tile
-		roads, invalidCountFromTile, err := e.extractRoadsFromTile(pbfFile, region, tileCoords)
-		if err != nil {
-			logger.Warn("failed to extract from tile", "file", pbfFile, "error", err)
+		if result.err != nil {
+			logger.Warn(result.failure, "tile", key, "error", result.err)
+			failedTiles++
 			continue
 		}
+		roads, invalidCountFromTile := result.roads, result.invalid
 
 		// Track invalid roads
 		invalidRoadCount += invalidCountFromTile
+		decodedRoadCount += len(roads) + invalidCountFromTile
 
 		// Fail fast if we're seeing too many invalid roads
-		if invalidRoadCount > 100 {
-			return nil, fmt.Errorf("ABORTING: found %d roads with zero coordinates - this indicates a bug in calculateBounds()", invalidRoadCount)
-		}
-
-		// Merge roads into map
-		for _, road := range roads {
-			key := fmt.Sprintf("%s_%s", road.RoadID, road.Region)
-			existing, exists := roadsMap[key]
-			if exists {
-				// Expand bounding box
-				existing.MinLat = math.Min(existing.MinLat, road.MinLat)
-				existing.MaxLat = math.Max(existing.MaxLat, road.MaxLat)
-				existing.MinLng = math.Min(existing.MinLng, road.MinLng)
-				existing.MaxLng = math.Max(existing.MaxLng, road.MaxLng)
-			} else {
-				roadsMap[key] = &road
-				progress.ExtractedRoads++
+		if e.MaxInvalidRoads > 0 && invalidRoadCount > e.MaxInvalidRoads {
+			return nil, nil, fmt.Errorf("ABORTING: found %d roads with zero coordinates - this indicates a bug in calculateBounds()", invalidRoadCount)
+		}
+
+		// Warn as the invalid share grows, louder each time it doubles
+		if level := invalidRatioLevel(invalidRoadCount, decodedRoadCount, e.InvalidWarnRatio); level > invalidLevel {
+			invalidLevel = level
+			logLevel := slog.LevelWarn
+			if level >= 3 {
+				logLevel = slog.LevelError
 			}
+			logger.Log(ctx, logLevel, "invalid road ratio rising",
+				"escalation", level,
+				"invalid", invalidRoadCount,
+				"decoded", decodedRoadCount,
+				"ratio", math.Round(float64(invalidRoadCount)/float64(decodedRoadCount)*1000)/1000,
+				"warn_ratio", e.InvalidWarnRatio,
+			)
 		}
 
 		progress.ProcessedTiles++
-		progress.LastProcessedTile = &pbfFile
+		progress.LastProcessedTile = &key
 
 		// Log progress every 500 tiles (no file I/O during extraction for speed)
-		if progress.ProcessedTiles%500 == 0 {
+		if e.Progress == nil && progress.ProcessedTiles%500 == 0 {
+			progress.ExtractedRoads = roadsMap.len()
 			logger.Info("progress checkpoint",
 				"processed", progress.ProcessedTiles,
 				"total", progress.TotalTiles,
@@ -179,19 +395,157 @@ func (e *GeometryExtractor) ExtractRoadGeometriesFromTiles(ctx context.Context,
 		}
 	}
 
-	// Convert map to slice
-	result := make([]RoadGeometry, 0, len(roadsMap))
-	for _, road := range roadsMap {
+	if e.Progress != nil {
+		e.Progress(len(tiles), len(tiles))
+	}
+
+	// Combine the shards and convert to a slice
+	merged := roadsMap.combined()
+	if e.KeyStrategy == RoadKeyGlobal {
+		regional := len(merged)
+		merged = dedupRoadsGlobally(merged)
+		logger.Info("keyed roads globally", "roads", regional, "unique", len(merged))
+	}
+	result := make([]RoadGeometry, 0, len(merged))
+	for _, road := range merged {
 		result = append(result, *road)
 	}
 
 	// Save final results
 	progress.Status = "complete"
-	e.saveProgress(progress)
-	e.saveRoadsToFile(extractionFile, roadsMap)
+	progress.ExtractedRoads = len(merged)
+	saveProgress(progress)
+	e.saveRoadsToFile(extractionFile, merged)
 
 	logger.Info("extraction complete", "roads_extracted", len(result))
-	return result, nil
+
+	if e.MaxBBoxMeters > 0 {
+		if oversized := FindOversizedRoads(result, e.MaxBBoxMeters); len(oversized) > 0 {
+			for _, road := range oversized[:min(len(oversized), 10)] {
+				logger.Warn("oversized road bounding box",
+					"road_id", road.RoadID,
+					"name", road.Name,
+					"bbox_diagonal_m", math.Round(road.BBoxDiagonalMeters()),
+				)
+			}
+			logger.Warn("roads with oversized bounding boxes (possible same-name merge)",
+				"count", len(oversized),
+				"max_bbox_m", e.MaxBBoxMeters,
+			)
+		}
+	}
+
+	summary := &ExtractionSummary{
+		Region:          region,
+		TilesTotal:      found,
+		TilesProcessed:  progress.ProcessedTiles,
+		TilesFailed:     failedTiles,
+		RoadsExtracted:  len(result),
+		InvalidSkipped:  invalidRoadCount,
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if found > len(tiles) {
+		summary.TilesLimit = e.MaxTiles
+	}
+	if e.Scope != nil {
+		summary.Scope = e.Scope.String()
+	}
+	return result, summary, nil
+}
+
+// sortTiles orders tiles by zoom, column and row
+func sortTiles(tiles []maptile.Tile) {
+	sort.Slice(tiles, func(i, j int) bool {
+		return tileLess(tiles[i], tiles[j])
+	})
+}
+
+// tileLess reports whether a sorts before b by zoom, column and row
+func tileLess(a, b maptile.Tile) bool {
+	if a.Z != b.Z {
+		return a.Z < b.Z
+	}
+	if a.X != b.X {
+		return a.X < b.X
+	}
+	return a.Y < b.Y
+}
+
+// tileResult is the outcome of reading and decoding one tile
+type tileResult struct {
+	roads   []RoadGeometry
+	invalid int    // Roads skipped with zero coordinates
+	failure string // What failed when err is set
+	err     error
+}
+
+// workers returns the decode pool size, defaulting to one worker per CPU
+func (e *GeometryExtractor) workers() int {
+	if e.Workers > 0 {
+		return e.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// decodeTiles reads and decodes tiles[start:] with a pool of e.workers()
+// goroutines, each merging the roads it decodes into shards with the tile's
+// index as their order. It returns a channel that yields one result slot per
+// tile, in tile order, and a function that waits for every goroutine to exit,
+// which they do once all tiles are decoded or ctx is cancelled.
+func (e *GeometryExtractor) decodeTiles(ctx context.Context, src TileSource, region string, tiles []maptile.Tile, start int, shards *roadShards) (<-chan chan tileResult, func()) {
+	type tileJob struct {
+		index  int
+		tile   maptile.Tile
+		result chan tileResult
+	}
+
+	workers := e.workers()
+	jobs := make(chan tileJob)
+	// Bounds how far decoding runs ahead of the in-order accounting
+	slots := make(chan chan tileResult, workers*2)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := start; i < len(tiles); i++ {
+			result := make(chan tileResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- result:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- tileJob{index: i, tile: tiles[i], result: result}:
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				data, err := src.ReadTile(ctx, job.tile)
+				if err != nil {
+					job.result <- tileResult{failure: "failed to read tile", err: err}
+					continue
+				}
+				roads, invalid, err := e.extractRoadsFromTileData(data, region, job.tile)
+				if err == nil {
+					for _, road := range roads {
+						shards.merge(road, job.index)
+					}
+				}
+				job.result <- tileResult{roads: roads, invalid: invalid, failure: "failed to extract from tile", err: err}
+			}
+		}()
+	}
+
+	return slots, wg.Wait
 }
 
 // extractRoadsFromTile extracts roads from a single tile file
@@ -202,9 +556,13 @@ func (e *GeometryExtractor) extractRoadsFromTile(pbfFile, region string, tileCoo
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to read tile: %w", err)
 	}
+	return e.extractRoadsFromTileData(data, region, tileCoords)
+}
 
+// extractRoadsFromTileData extracts roads from raw (optionally gzipped) tile data
+func (e *GeometryExtractor) extractRoadsFromTileData(data []byte, region string, tileCoords maptile.Tile) ([]RoadGeometry, int, error) {
 	// Decode MVT
-	layers, err := mvt.Unmarshal(data)
+	layers, err := decodeTile(data)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to unmarshal MVT: %w", err)
 	}
@@ -226,6 +584,12 @@ func (e *GeometryExtractor) extractRoadsFromTile(pbfFile, region string, tileCoo
 				// Use the UUID from the GeoJSON (generated during conversion)
 				roadID = id
 			}
+			if roadID == "" {
+				// Fallback: the MVT feature ID, set by --use-attribute-for-id
+				if id, ok := feature.ID.(float64); ok {
+					roadID = strconv.FormatFloat(id, 'f', -1, 64)
+				}
+			}
 			if roadID == "" {
 				// Fallback: use name with region prefix
 				if name, ok := feature.Properties["Name"].(string); ok && name != "" {
@@ -243,13 +607,14 @@ func (e *GeometryExtractor) extractRoadsFromTile(pbfFile, region string, tileCoo
 				roadName = name
 			}
 
-			// Get curvature if available
-			var curvature *string
-			if curv, ok := feature.Properties["curvature"].(string); ok {
-				curvature = &curv
-			} else if curv, ok := feature.Properties["curvature"].(float64); ok {
-				curvStr := fmt.Sprintf("%.2f", curv)
-				curvature = &curvStr
+			// Get curvature if available, leaving out malformed values
+			curvature, err := parseCurvatureProperty(feature.Properties["curvature"])
+			if err != nil {
+				e.logger.Warn("ignoring invalid curvature",
+					"road_id", roadID,
+					"tile", tileKey(tileCoords),
+					"error", err,
+				)
 			}
 
 			// Get length if available
@@ -291,15 +656,13 @@ func (e *GeometryExtractor) extractRoadsFromTile(pbfFile, region string, tileCoo
 			// Validate: skip and log if any coordinate is zero
 			if minLat == 0 || maxLat == 0 || minLng == 0 || maxLng == 0 {
 				invalidCount++
-				e.logger.Error("INVALID ROAD: zero coordinates detected",
-					"roadId", roadID,
-					"tile", fmt.Sprintf("%d/%d/%d", tileCoords.Z, tileCoords.X, tileCoords.Y),
-					"minLat", minLat, "maxLat", maxLat,
-					"minLng", minLng, "maxLng", maxLng,
-					"bounds.Min.Lat()", bounds.Min.Lat(),
-					"bounds.Max.Lat()", bounds.Max.Lat(),
-					"bounds.Min.Lon()", bounds.Min.Lon(),
-					"bounds.Max.Lon()", bounds.Max.Lon())
+				e.logger.Error("invalid road: zero coordinates detected",
+					"road_id", roadID,
+					"tile", tileKey(tileCoords),
+					"geometry_type", feature.Geometry.GeoJSONType(),
+					e.boundsAttr(*bounds),
+					tileSpaceAttr(feature.Geometry, float64(layer.Extent)),
+				)
 				continue
 			}
 
@@ -324,33 +687,92 @@ func (e *GeometryExtractor) extractRoadsFromTile(pbfFile, region string, tileCoo
 	return roads, invalidCount, nil
 }
 
-// calculateBounds calculates the geographic bounding box for a geometry
-func (e *GeometryExtractor) calculateBounds(geom orb.Geometry, tile maptile.Tile) *orb.Bound {
-	if geom == nil {
-		return nil
+// Limits on the curvature property read from tiles. The converter writes
+// whole numbers, and even the twistiest long roads score far below
+// MaxCurvature, so anything beyond these is malformed data.
+const (
+	MaxCurvatureLength = 32
+	MaxCurvature       = 10_000_000
+)
+
+// parseCurvatureProperty validates a feature's curvature property, a numeric
+// string or a number. Returns nil for a missing property, and an error for
+// an overlong, non-numeric, negative or absurdly large value.
+func parseCurvatureProperty(value any) (*string, error) {
+	var curvature string
+	var number float64
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if len(v) > MaxCurvatureLength {
+			return nil, fmt.Errorf("curvature is %d characters long (max %d)", len(v), MaxCurvatureLength)
+		}
+		curvature = strings.TrimSpace(v)
+		var err error
+		if number, err = strconv.ParseFloat(curvature, 64); err != nil {
+			return nil, fmt.Errorf("curvature %q is not a number", curvature)
+		}
+	case float64:
+		number = v
+		curvature = fmt.Sprintf("%.2f", v)
+	default:
+		return nil, fmt.Errorf("curvature has unexpected type %T", value)
 	}
 
-	// Helper function to convert tile-space coords (0-4096) to lat/lng
-	// Uses Web Mercator projection (same as Mapbox tiles)
-	tileCoordToLatLng := func(x, y float64) orb.Point {
-		// Get tile bounds in Web Mercator space
-		n := math.Pow(2.0, float64(tile.Z))
+	if math.IsNaN(number) || number < 0 || number > MaxCurvature {
+		return nil, fmt.Errorf("curvature %s is out of range (0 to %d)", curvature, MaxCurvature)
+	}
+	return &curvature, nil
+}
+
+// MaxMercatorLatitude is the latitude limit of the Web Mercator projection.
+// Tiles cannot represent anything north or south of it, so roads closer to the
+// poles are pinned to this latitude.
+const MaxMercatorLatitude = 85.05112877980659
+
+// TileCoordToLatLng converts tile-space coordinates (0..extent, y pointing
+// down) within tile to a lng/lat point using the Web Mercator projection (same
+// as Mapbox tiles). (0,0) is the tile's NW corner and (extent,extent) its SE
+// corner. Latitudes are clamped to ±MaxMercatorLatitude.
+func TileCoordToLatLng(tile maptile.Tile, x, y, extent float64) orb.Point {
+	// Get tile bounds in Web Mercator space
+	n := math.Pow(2.0, float64(tile.Z))
+
+	// Calculate tile-space fraction (0-1)
+	xFrac := x / extent
+	yFrac := y / extent
+
+	// Tile indices with fractional part
+	tileX := float64(tile.X) + xFrac
+	tileY := float64(tile.Y) + yFrac
+
+	// Web Mercator is only defined for |lat| <= MaxMercatorLatitude, which
+	// corresponds to world Y in [0, n]. Tile buffers let geometry spill past
+	// the edge of the top/bottom row, so clamp to keep latitudes in range.
+	tileY = math.Max(0, math.Min(n, tileY))
 
-		// Calculate tile-space fraction (0-1)
-		xFrac := x / 4096.0
-		yFrac := y / 4096.0
+	// Convert to longitude (simple linear)
+	lng := (tileX/n)*360.0 - 180.0
 
-		// Tile indices with fractional part
-		tileX := float64(tile.X) + xFrac
-		tileY := float64(tile.Y) + yFrac
+	// Convert to latitude (inverse Mercator projection)
+	lat := math.Atan(math.Sinh(math.Pi*(1.0-2.0*tileY/n))) * (180.0 / math.Pi)
 
-		// Convert to longitude (simple linear)
-		lng := (tileX/n)*360.0 - 180.0
+	return orb.Point{lng, lat}
+}
 
-		// Convert to latitude (inverse Mercator projection)
-		lat := math.Atan(math.Sinh(math.Pi*(1.0-2.0*tileY/n))) * (180.0 / math.Pi)
+// tileExtent is the tile-space extent Tippecanoe and the go encoder write
+const tileExtent = 4096.0
 
-		return orb.Point{lng, lat}
+// calculateBounds calculates the geographic bounding box for a geometry.
+// Latitudes are clamped to ±MaxMercatorLatitude.
+func (e *GeometryExtractor) calculateBounds(geom orb.Geometry, tile maptile.Tile) *orb.Bound {
+	if geom == nil {
+		return nil
+	}
+
+	tileCoordToLatLng := func(x, y float64) orb.Point {
+		return TileCoordToLatLng(tile, x, y, tileExtent)
 	}
 
 	// Collect all points from geometry
@@ -406,19 +828,18 @@ func (e *GeometryExtractor) calculateBounds(geom orb.Geometry, tile maptile.Tile
 		return nil
 	}
 
-	// Manually calculate min/max from all points
-	minLng := points[0].Lon()
-	maxLng := points[0].Lon()
+	// Manually calculate min/max from all points. Longitude wraps, so a road
+	// crossing the antimeridian gets the narrow box across it rather than one
+	// spanning the globe.
+	lngs := make([]float64, len(points))
+	for i, p := range points {
+		lngs[i] = p.Lon()
+	}
+	minLng, maxLng := lngRange(lngs)
 	minLat := points[0].Lat()
 	maxLat := points[0].Lat()
 
 	for _, p := range points[1:] {
-		if p.Lon() < minLng {
-			minLng = p.Lon()
-		}
-		if p.Lon() > maxLng {
-			maxLng = p.Lon()
-		}
 		if p.Lat() < minLat {
 			minLat = p.Lat()
 		}
@@ -432,21 +853,60 @@ func (e *GeometryExtractor) calculateBounds(geom orb.Geometry, tile maptile.Tile
 		Max: orb.Point{maxLng, maxLat},
 	}
 
-	// Debug: validate the bound - CHECK ALL 4 COORDINATES
-	if bound.Min.Lat() == 0 || bound.Max.Lat() == 0 || bound.Min.Lon() == 0 || bound.Max.Lon() == 0 {
-		e.logger.Warn("Invalid bound detected",
-			"minLng", minLng, "minLat", minLat,
-			"maxLng", maxLng, "maxLat", maxLat,
-			"bound.Min.Lat()", bound.Min.Lat(),
-			"bound.Max.Lat()", bound.Max.Lat(),
-			"bound.Min.Lon()", bound.Min.Lon(),
-			"bound.Max.Lon()", bound.Max.Lon())
+	return &bound
+}
+
+// wrapLng normalizes a longitude to [-180, 180)
+func wrapLng(lng float64) float64 {
+	return lng - 360*math.Floor((lng+180)/360)
+}
+
+// lngRange returns the narrowest longitude interval covering lngs, which may
+// spill past ±180° (tile buffers do). An interval crossing the antimeridian
+// keeps min < max by running past 180°: a road from 179.5°E to 179.5°W is
+// 179.5 to 180.5, not -179.5 to 179.5.
+func lngRange(lngs []float64) (float64, float64) {
+	sorted := make([]float64, len(lngs))
+	for i, lng := range lngs {
+		sorted[i] = wrapLng(lng)
+	}
+	sort.Float64s(sorted)
+
+	// The interval is everything but the widest gap between neighbouring
+	// longitudes, going around the globe
+	last := len(sorted) - 1
+	gapAfter, widest := last, sorted[0]+360-sorted[last]
+	for i := 0; i < last; i++ {
+		if gap := sorted[i+1] - sorted[i]; gap > widest {
+			gapAfter, widest = i, gap
+		}
 	}
+	if gapAfter == last {
+		return sorted[0], sorted[last]
+	}
+	return sorted[gapAfter+1], sorted[gapAfter] + 360
+}
 
-	return &bound
+// unionLngRange returns the narrowest longitude interval covering two
+// intervals from lngRange, crossing the antimeridian when that is narrower
+func unionLngRange(aMin, aMax, bMin, bMax float64) (float64, float64) {
+	minLng, maxLng := math.Min(aMin, bMin), math.Max(aMax, bMax)
+	for _, shift := range []float64{-360, 360} {
+		lo, hi := math.Min(aMin, bMin+shift), math.Max(aMax, bMax+shift)
+		if hi-lo < maxLng-minLng {
+			minLng, maxLng = lo, hi
+		}
+	}
+	if wrapped := wrapLng(minLng); wrapped != minLng {
+		maxLng += wrapped - minLng
+		minLng = wrapped
+	}
+	return minLng, maxLng
 }
 
-// findPBFFiles finds all .pbf files in a directory tree
+// findPBFFiles finds all .pbf files in a directory tree, ordered by numeric
+// zoom, column and row (so 5/... comes before 10/...). Paths that are not
+// z/x/y come last, in walk order.
 func (e *GeometryExtractor) findPBFFiles(dir string) ([]string, error) {
 	var files []string
 
@@ -464,11 +924,40 @@ func (e *GeometryExtractor) findPBFFiles(dir string) ([]string, error) {
 		return nil, err
 	}
 
+	sortPBFFiles(files)
 	return files, nil
 }
 
+// sortPBFFiles orders tile files by their parsed z/x/y, keeping files whose
+// path does not parse after the rest in their original order
+func sortPBFFiles(files []string) {
+	tiles := make(map[string]maptile.Tile, len(files))
+	for _, file := range files {
+		if tile, err := ParseTilePath(file); err == nil {
+			tiles[file] = tile
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, aOK := tiles[files[i]]
+		b, bOK := tiles[files[j]]
+		if aOK != bOK {
+			return aOK
+		}
+		return aOK && tileLess(a, b)
+	})
+}
+
 // parseTilePath extracts tile coordinates (z/x/y) from file path
 func (e *GeometryExtractor) parseTilePath(path string) (maptile.Tile, error) {
+	return ParseTilePath(path)
+}
+
+// maxTileZoom is the deepest zoom a uint32 tile column/row can address
+const maxTileZoom = 32
+
+// ParseTilePath extracts tile coordinates from a ".../z/x/y.pbf" path or a
+// bare "z/x/y" string
+func ParseTilePath(path string) (maptile.Tile, error) {
 	// Expected format: .../z/x/y.pbf
 	parts := strings.Split(filepath.ToSlash(path), "/")
 	if len(parts) < 3 {
@@ -499,19 +988,91 @@ func (e *GeometryExtractor) parseTilePath(path string) (maptile.Tile, error) {
 		return maptile.Tile{}, fmt.Errorf("invalid y coordinate: %w", err)
 	}
 
+	if zInt > maxTileZoom {
+		return maptile.Tile{}, fmt.Errorf("zoom %d exceeds maximum %d", zInt, maxTileZoom)
+	}
+	if n := uint64(1) << zInt; xInt >= n || yInt >= n {
+		return maptile.Tile{}, fmt.Errorf("tile %d/%d out of range for zoom %d", xInt, yInt, zInt)
+	}
+
 	return maptile.New(uint32(xInt), uint32(yInt), maptile.Zoom(zInt)), nil
 }
 
+// TileScope is a single tile (z/x/y) or every tile in one column of a zoom
+// (z/x, the z/x directory of a tiles tree)
+type TileScope struct {
+	Tile   maptile.Tile
+	Column bool // Match any row of Tile's column, not just Tile.Y
+}
+
+// ParseTileScope parses a "z/x/y" tile or a "z/x" column
+func ParseTileScope(value string) (*TileScope, error) {
+	if strings.Count(value, "/") == 1 {
+		// Parse the column as row 0 of it, which is always in range
+		tile, err := ParseTilePath(value + "/0")
+		if err != nil {
+			return nil, fmt.Errorf("invalid tile column %q: %w", value, err)
+		}
+		return &TileScope{Tile: tile, Column: true}, nil
+	}
+	if strings.Count(value, "/") != 2 {
+		return nil, fmt.Errorf("invalid tile %q (want z/x/y or z/x)", value)
+	}
+	tile, err := ParseTilePath(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tile %q: %w", value, err)
+	}
+	return &TileScope{Tile: tile}, nil
+}
+
+// Contains reports whether tile is in the scope
+func (s *TileScope) Contains(tile maptile.Tile) bool {
+	if tile.Z != s.Tile.Z || tile.X != s.Tile.X {
+		return false
+	}
+	return s.Column || tile.Y == s.Tile.Y
+}
+
+// Filter returns the tiles in the scope
+func (s *TileScope) Filter(tiles []maptile.Tile) []maptile.Tile {
+	var scoped []maptile.Tile
+	for _, tile := range tiles {
+		if s.Contains(tile) {
+			scoped = append(scoped, tile)
+		}
+	}
+	return scoped
+}
+
+// String formats the scope as it is parsed, "z/x/y" or "z/x"
+func (s *TileScope) String() string {
+	if s.Column {
+		return fmt.Sprintf("%d/%d", s.Tile.Z, s.Tile.X)
+	}
+	return tileKey(s.Tile)
+}
+
 // Progress and file management functions
 
 func (e *GeometryExtractor) getProgressFile(region string) string {
 	return filepath.Join(".", fmt.Sprintf(".extract-progress-%s.json", region))
 }
 
+// getExtractionFile returns the file extracted roads are saved to. Limited
+// and scoped runs use their own file so they never replace a full run's.
 func (e *GeometryExtractor) getExtractionFile(region string) string {
+	if e.partial() {
+		return filepath.Join(".", fmt.Sprintf(".extracted-roads-%s.partial.json", region))
+	}
 	return filepath.Join(".", fmt.Sprintf(".extracted-roads-%s.json", region))
 }
 
+// partial reports whether the extractor reads only some of the tiles, with
+// MaxTiles or Scope
+func (e *GeometryExtractor) partial() bool {
+	return e.MaxTiles > 0 || e.Scope != nil
+}
+
 func (e *GeometryExtractor) loadProgress(region string) *ExtractionProgress {
 	progressFile := e.getProgressFile(region)
 	data, err := os.ReadFile(progressFile)
@@ -524,10 +1085,25 @@ func (e *GeometryExtractor) loadProgress(region string) *ExtractionProgress {
 		e.logger.Error("failed to unmarshal progress", "error", err)
 		return nil
 	}
+	if progress.LastProcessedTile != nil {
+		key := progressTileKey(*progress.LastProcessedTile)
+		progress.LastProcessedTile = &key
+	}
 
 	return &progress
 }
 
+// progressTileKey returns the z/x/y tile key a saved LastProcessedTile
+// refers to. Progress files written before tiles were read through a
+// TileSource hold the tile's file path ({tiles dir}/z/x/y.pbf) instead.
+func progressTileKey(last string) string {
+	tile, err := ParseTilePath(last)
+	if err != nil {
+		return last
+	}
+	return tileKey(tile)
+}
+
 func (e *GeometryExtractor) saveProgress(progress *ExtractionProgress) error {
 	progressFile := e.getProgressFile(progress.Region)
 	data, err := json.MarshalIndent(progress, "", "  ")
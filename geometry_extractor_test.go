@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
 	"github.com/paulmach/orb/maptile"
 )
 
@@ -56,6 +65,16 @@ func TestTilePathParsing(t *testing.T) {
 			path:        "public/tiles/foo/bar/baz.pbf",
 			expectError: true,
 		},
+		{
+			name:        "Invalid path - column out of range for zoom",
+			path:        "tiles/3/8/1.pbf",
+			expectError: true,
+		},
+		{
+			name:        "Invalid path - zoom too deep",
+			path:        "tiles/33/0/0.pbf",
+			expectError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -132,6 +151,30 @@ func TestProgressSaveLoad(t *testing.T) {
 	os.Remove(progressFile)
 }
 
+func TestLoadProgress_FilePathLastTile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	extractor := NewGeometryExtractor()
+
+	tests := []struct {
+		last string
+		want string
+	}{
+		{"/data/tiles/oregon/10/163/357.pbf", "10/163/357"},
+		{"unknown", "unknown"},
+		{"10/163/357", "10/163/357"},
+	}
+	for _, tt := range tests {
+		data := fmt.Sprintf(`{"region": "oregon", "processedTiles": 5, "lastProcessedTile": %q}`, tt.last)
+		if err := os.WriteFile(extractor.getProgressFile("oregon"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		progress := extractor.loadProgress("oregon")
+		if progress == nil || progress.LastProcessedTile == nil || *progress.LastProcessedTile != tt.want {
+			t.Errorf("lastProcessedTile %q: expected %q, got %+v", tt.last, tt.want, progress)
+		}
+	}
+}
+
 // TestRoadsSaveLoad tests road data persistence
 func TestRoadsSaveLoad(t *testing.T) {
 	extractor := NewGeometryExtractor()
@@ -301,6 +344,35 @@ func TestFindPBFFiles(t *testing.T) {
 	}
 }
 
+func TestFindPBFFiles_NumericOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Lexicographically, 10 < 5 and 12 < 2
+	for _, rel := range []string{"10/0/0.pbf", "5/20/1.pbf", "5/3/12.pbf", "5/3/2.pbf", "5/4/0.pbf", "stray.pbf", "9/511/511.pbf"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("tile"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := NewGeometryExtractor().findPBFFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to find PBF files: %v", err)
+	}
+
+	var got []string
+	for _, f := range found {
+		rel, _ := filepath.Rel(tmpDir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"5/3/2.pbf", "5/3/12.pbf", "5/4/0.pbf", "5/20/1.pbf", "9/511/511.pbf", "10/0/0.pbf", "stray.pbf"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
 // TestCleanupExtractionFiles tests file cleanup
 func TestCleanupExtractionFiles(t *testing.T) {
 	extractor := NewGeometryExtractor()
@@ -894,3 +966,572 @@ func TestExtractRoadWithNewProperties(t *testing.T) {
 		t.Log("NOTE: No roads have end point - test tiles may need regeneration")
 	}
 }
+
+// TestCalculateBoundsMercatorClamp tests that tiles in the top row stay within
+// the Web Mercator latitude limit, even when buffered geometry spills past the
+// tile edge
+func TestCalculateBoundsMercatorClamp(t *testing.T) {
+	extractor := NewGeometryExtractor()
+
+	testCases := []struct {
+		name     string
+		tile     maptile.Tile
+		geometry orb.Geometry
+	}{
+		{
+			name:     "Top row tile at zoom 0",
+			tile:     maptile.New(0, 0, 0),
+			geometry: orb.LineString{{0, 0}, {4096, 4096}},
+		},
+		{
+			name:     "Northern Canada top row at zoom 5",
+			tile:     maptile.New(8, 0, 5),
+			geometry: orb.LineString{{100, 0}, {200, 50}},
+		},
+		{
+			name:     "Buffered geometry above top row at zoom 8",
+			tile:     maptile.New(40, 0, 8),
+			geometry: orb.LineString{{100, -256}, {200, 64}},
+		},
+		{
+			name:     "Buffered geometry below bottom row at zoom 6",
+			tile:     maptile.New(10, 63, 6),
+			geometry: orb.LineString{{100, 4000}, {200, 4352}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bounds := extractor.calculateBounds(tc.geometry, tc.tile)
+			if bounds == nil {
+				t.Fatal("calculateBounds returned nil")
+			}
+
+			for _, lat := range []float64{bounds.Min.Lat(), bounds.Max.Lat()} {
+				if math.IsNaN(lat) {
+					t.Fatalf("latitude is NaN")
+				}
+				if math.Abs(lat) > MaxMercatorLatitude+1e-9 {
+					t.Errorf("latitude %.8f exceeds Mercator limit %.8f", lat, MaxMercatorLatitude)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateBoundsAntimeridian(t *testing.T) {
+	extractor := NewGeometryExtractor()
+
+	// line projects lng/lat points into tile space; points past ±180° land in
+	// the tile buffer beyond its east or west edge
+	line := func(tile maptile.Tile, points ...orb.Point) orb.LineString {
+		var ls orb.LineString
+		for _, p := range points {
+			ls = append(ls, lngLatToTileSpace(p, tile, tileExtent))
+		}
+		return ls
+	}
+	aleutians := maptile.At(orb.Point{179.9, 52}, 8)
+	fiji := maptile.At(orb.Point{-179.9, -17}, 8)
+	world := maptile.New(0, 0, 0)
+	seattle := maptile.At(orb.Point{-122.33, 47.6}, 12)
+
+	testCases := []struct {
+		name           string
+		tile           maptile.Tile
+		geometry       orb.Geometry
+		minLng, maxLng float64
+	}{
+		{"Aleutians, buffer past the east edge", aleutians, line(aleutians, orb.Point{179.8, 52}, orb.Point{180.03, 52.1}), 179.8, 180.03},
+		{"Fiji, buffer past the west edge", fiji, line(fiji, orb.Point{-180.04, -17}, orb.Point{-179.8, -16.9}), 179.96, 180.2},
+		// At zoom 0 the road's ends are at opposite edges of the tile
+		{"Fiji, crossing at zoom 0", world, line(world, orb.Point{179.5, -17}, orb.Point{-179.5, -16.8}), 179.5, 180.5},
+		{"Seattle, far from the antimeridian", seattle, line(seattle, orb.Point{-122.34, 47.60}, orb.Point{-122.32, 47.61}), -122.34, -122.32},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bounds := extractor.calculateBounds(tc.geometry, tc.tile)
+			if bounds == nil {
+				t.Fatal("calculateBounds returned nil")
+			}
+			if math.Abs(bounds.Min.Lon()-tc.minLng) > 1e-6 || math.Abs(bounds.Max.Lon()-tc.maxLng) > 1e-6 {
+				t.Errorf("expected longitudes %v to %v, got %v to %v", tc.minLng, tc.maxLng, bounds.Min.Lon(), bounds.Max.Lon())
+			}
+			if span := bounds.Max.Lon() - bounds.Min.Lon(); span > 1 {
+				t.Errorf("expected a narrow box, got one %.2f° wide", span)
+			}
+		})
+	}
+}
+
+func TestUnionLngRange(t *testing.T) {
+	tests := []struct {
+		name                   string
+		aMin, aMax, bMin, bMax float64
+		wantMin, wantMax       float64
+	}{
+		{"overlapping", -122.4, -122.3, -122.35, -122.2, -122.4, -122.2},
+		{"disjoint", -122.4, -122.3, -121.0, -120.9, -122.4, -120.9},
+		{"east piece then west piece", 179.8, 180.03, -179.95, -179.8, 179.8, 180.2},
+		{"west piece then east piece", -179.95, -179.8, 179.8, 180.03, 179.8, 180.2},
+		{"crossing box and west piece", 179.5, 180.5, -179.2, -179.1, 179.5, 180.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMin, gotMax := unionLngRange(tt.aMin, tt.aMax, tt.bMin, tt.bMax)
+			if math.Abs(gotMin-tt.wantMin) > 1e-9 || math.Abs(gotMax-tt.wantMax) > 1e-9 {
+				t.Errorf("unionLngRange = %v, %v; want %v, %v", gotMin, gotMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestTileCoordToLatLng(t *testing.T) {
+	const eps = 1e-9
+
+	tiles := []maptile.Tile{
+		{X: 0, Y: 0, Z: 0},
+		{X: 1, Y: 1, Z: 1},
+		{X: 1060, Y: 1631, Z: 12},  // Tail of the Dragon
+		{X: 5241, Y: 12666, Z: 15}, // Seattle
+		{X: 3, Y: 28, Z: 5},        // southern hemisphere
+	}
+
+	for _, tile := range tiles {
+		for _, extent := range []float64{4096, 512} {
+			b := tile.Bound()
+
+			nw := TileCoordToLatLng(tile, 0, 0, extent)
+			if math.Abs(nw.Lon()-b.Left()) > eps || math.Abs(nw.Lat()-b.Top()) > eps {
+				t.Errorf("tile %v extent %v: NW = %v, want (%v, %v)", tile, extent, nw, b.Left(), b.Top())
+			}
+
+			se := TileCoordToLatLng(tile, extent, extent, extent)
+			if math.Abs(se.Lon()-b.Right()) > eps || math.Abs(se.Lat()-b.Bottom()) > eps {
+				t.Errorf("tile %v extent %v: SE = %v, want (%v, %v)", tile, extent, se, b.Right(), b.Bottom())
+			}
+
+			// The tile's center should land inside the tile's bound
+			center := TileCoordToLatLng(tile, extent/2, extent/2, extent)
+			if !b.Contains(center) {
+				t.Errorf("tile %v extent %v: center %v outside bound %v", tile, extent, center, b)
+			}
+		}
+	}
+}
+
+func TestTileCoordToLatLngClampsBuffer(t *testing.T) {
+	top := maptile.Tile{X: 0, Y: 0, Z: 2}
+	p := TileCoordToLatLng(top, 0, -256, 4096)
+	if p.Lat() > MaxMercatorLatitude {
+		t.Errorf("expected latitude clamped to %v, got %v", MaxMercatorLatitude, p.Lat())
+	}
+
+	bottom := maptile.Tile{X: 0, Y: 3, Z: 2}
+	p = TileCoordToLatLng(bottom, 0, 4096+256, 4096)
+	if p.Lat() < -MaxMercatorLatitude {
+		t.Errorf("expected latitude clamped to %v, got %v", -MaxMercatorLatitude, p.Lat())
+	}
+}
+
+func TestRoadGeometryBBoxSize(t *testing.T) {
+	// 0.1 degree of latitude is ~11.1 km
+	road := RoadGeometry{MinLat: 45.0, MaxLat: 45.1, MinLng: -122.0, MaxLng: -122.0}
+	if d := road.BBoxDiagonalMeters(); math.Abs(d-11119) > 50 {
+		t.Errorf("expected ~11119m diagonal, got %.0f", d)
+	}
+	if a := road.BBoxAreaSqMeters(); a != 0 {
+		t.Errorf("expected zero area for a zero-width box, got %f", a)
+	}
+
+	square := RoadGeometry{MinLat: 0, MaxLat: 0.01, MinLng: 0, MaxLng: 0.01}
+	if a := square.BBoxAreaSqMeters(); math.Abs(a-1112*1112) > 5000 {
+		t.Errorf("expected ~1.24 km² area, got %.0f m²", a)
+	}
+}
+
+func TestFindOversizedRoads(t *testing.T) {
+	roads := []RoadGeometry{
+		{RoadID: "normal", MinLat: 35.4, MaxLat: 35.5, MinLng: -84.0, MaxLng: -83.9},
+		// Same-name merge: two roads on opposite sides of the state
+		{RoadID: "merged", MinLat: 35.0, MaxLat: 36.5, MinLng: -90.0, MaxLng: -82.0},
+		{RoadID: "large", MinLat: 35.0, MaxLat: 37.0, MinLng: -84.0, MaxLng: -84.0},
+		{RoadID: "point", MinLat: 35.0, MaxLat: 35.0, MinLng: -84.0, MaxLng: -84.0},
+	}
+
+	oversized := FindOversizedRoads(roads, DefaultMaxBBoxMeters)
+	if len(oversized) != 2 {
+		t.Fatalf("expected 2 oversized roads, got %d", len(oversized))
+	}
+	if oversized[0].RoadID != "merged" || oversized[1].RoadID != "large" {
+		t.Errorf("expected largest first [merged large], got [%s %s]", oversized[0].RoadID, oversized[1].RoadID)
+	}
+
+	if got := FindOversizedRoads(roads, 1e9); len(got) != 0 {
+		t.Errorf("expected no roads above a huge threshold, got %d", len(got))
+	}
+}
+
+func TestInvalidRatioLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		invalid   int
+		decoded   int
+		warnRatio float64
+		want      int
+	}{
+		{"too few roads to judge", 10, 20, 0.01, 0},
+		{"no invalid roads", 0, 1000, 0.01, 0},
+		{"below threshold", 5, 1000, 0.01, 0},
+		{"at threshold", 10, 1000, 0.01, 1},
+		{"doubled", 20, 1000, 0.01, 2},
+		{"between doublings", 50, 1000, 0.01, 3},
+		{"all invalid", 1000, 1000, 0.01, 7},
+		{"disabled", 1000, 1000, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invalidRatioLevel(tt.invalid, tt.decoded, tt.warnRatio); got != tt.want {
+				t.Errorf("invalidRatioLevel(%d, %d, %v) = %d, want %d", tt.invalid, tt.decoded, tt.warnRatio, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRoadGeometries_InvalidThresholds(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeFixtureTile(t, dir)
+	writeEquatorTile(t, dir, 60)
+
+	// Each run gets its own region so it doesn't resume the previous run's progress
+	runs := 0
+	extract := func(maxInvalid int, warnRatio float64) (string, error) {
+		runs++
+		var logs bytes.Buffer
+		e := NewGeometryExtractor()
+		e.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+		e.MaxInvalidRoads = maxInvalid
+		e.InvalidWarnRatio = warnRatio
+		_, _, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), fmt.Sprintf("thresholds-%d", runs))
+		return logs.String(), err
+	}
+
+	t.Run("absolute abort", func(t *testing.T) {
+		if _, err := extract(59, 0); err == nil || !strings.Contains(err.Error(), "ABORTING") {
+			t.Errorf("expected abort past 59 invalid roads, got %v", err)
+		}
+		if _, err := extract(60, 0); err != nil {
+			t.Errorf("expected 60 invalid roads to be within a limit of 60: %v", err)
+		}
+		if _, err := extract(0, 0); err != nil {
+			t.Errorf("expected no abort with the limit off: %v", err)
+		}
+	})
+
+	t.Run("ratio warning", func(t *testing.T) {
+		logs, err := extract(0, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The equator tile sorts first, so all 60 decoded roads are invalid:
+		// 0.5 and 1.0 are crossed at once, still only a warning
+		if !strings.Contains(logs, `"level":"WARN","msg":"invalid road ratio rising"`) || !strings.Contains(logs, `"escalation":2`) {
+			t.Errorf("expected a ratio warning, got %s", logs)
+		}
+
+		logs, err = extract(0, 0.1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 0.1, 0.2, 0.4, 0.8 are all crossed at once: escalates straight to an error
+		if !strings.Contains(logs, `"level":"ERROR","msg":"invalid road ratio rising"`) || !strings.Contains(logs, `"escalation":4`) {
+			t.Errorf("expected an escalated ratio error, got %s", logs)
+		}
+
+		logs, err = extract(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(logs, "invalid road ratio rising") {
+			t.Errorf("expected no ratio warnings when disabled, got %s", logs)
+		}
+	})
+}
+
+func TestNewGeometryExtractor_InvalidThresholdConfig(t *testing.T) {
+	tests := []struct {
+		maxInvalid     int
+		warnRatio      float64
+		wantMaxInvalid int
+		wantWarnRatio  float64
+	}{
+		{0, 0, DefaultMaxInvalidRoads, DefaultInvalidWarnRatio},
+		{500, 0.2, 500, 0.2},
+		{-1, -1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Service: ServiceConfig{MaxInvalidRoads: tt.maxInvalid, InvalidWarnRatio: tt.warnRatio}}
+		e := NewTileService(nil, nil, cfg).newGeometryExtractor()
+		if e.MaxInvalidRoads != tt.wantMaxInvalid || e.InvalidWarnRatio != tt.wantWarnRatio {
+			t.Errorf("config (%d, %v): got (%d, %v), want (%d, %v)", tt.maxInvalid, tt.warnRatio,
+				e.MaxInvalidRoads, e.InvalidWarnRatio, tt.wantMaxInvalid, tt.wantWarnRatio)
+		}
+	}
+}
+
+func TestNewGeometryExtractor_MaxRoadBBoxConfig(t *testing.T) {
+	tests := []struct {
+		km   int
+		want float64
+	}{
+		{0, 0},
+		{50, 50000},
+		{-1, 0},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Service: ServiceConfig{MaxRoadBBoxKm: tt.km}}
+		if got := NewTileService(nil, nil, cfg).newGeometryExtractor().MaxBBoxMeters; got != tt.want {
+			t.Errorf("MaxRoadBBoxKm %d: got %v, want %v", tt.km, got, tt.want)
+		}
+	}
+}
+
+func TestExtractRoadsFromTileData_InvalidRoadLogFields(t *testing.T) {
+	dir := t.TempDir()
+	writeEquatorTile(t, dir, 1)
+	tile := maptile.New(0, 0, 1)
+	data, err := os.ReadFile(filepath.Join(dir, tileRelPath(tile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		decimals int
+		wantLng  float64
+	}{
+		{0, -175.60546875},
+		{2, -175.61},
+	}
+
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		e := NewGeometryExtractor()
+		e.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+		e.LogCoordDecimals = tt.decimals
+
+		roads, invalid, err := e.extractRoadsFromTileData(data, "equator", tile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roads) != 0 || invalid != 1 {
+			t.Fatalf("expected one invalid road, got %d roads and %d invalid", len(roads), invalid)
+		}
+
+		var entry map[string]any
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("expected one JSON log line, got %q: %v", logs.String(), err)
+		}
+		if entry["road_id"] != "equator-0" || entry["tile"] != "1/0/0" || entry["geometry_type"] != "LineString" {
+			t.Errorf("missing road fields in %v", entry)
+		}
+
+		bounds, _ := entry["bounds"].(map[string]any)
+		if bounds["min_lat"] != 0.0 || bounds["max_lat"] != 0.0 || bounds["min_lng"] != tt.wantLng {
+			t.Errorf("decimals %d: unexpected bounds %v, want min_lng %v", tt.decimals, bounds, tt.wantLng)
+		}
+
+		raw, _ := entry["tile_space"].(map[string]any)
+		if raw["min_x"] != 100.0 || raw["max_x"] != 200.0 || raw["min_y"] != 4096.0 || raw["max_y"] != 4096.0 || raw["extent"] != 4096.0 {
+			t.Errorf("unexpected tile-space fields %v", raw)
+		}
+	}
+}
+
+func TestExtractRoadsFromTileData_InvalidRoadLogsLayerExtent(t *testing.T) {
+	// A road on the prime meridian, the left edge of tile 1/1/0, in a layer
+	// with a non-default extent
+	layer := &mvt.Layer{Name: "roads", Version: 2, Extent: 8192}
+	f := geojson.NewFeature(orb.LineString{{0, 100}, {0, 200}})
+	f.Properties["id"] = "meridian"
+	layer.Features = append(layer.Features, f)
+	data, err := EncodeTile(mvt.Layers{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	e := NewGeometryExtractor()
+	e.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	if _, invalid, err := e.extractRoadsFromTileData(data, "meridian", maptile.New(1, 0, 1)); err != nil || invalid != 1 {
+		t.Fatalf("expected one invalid road, got %d (%v)", invalid, err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON log line, got %q: %v", logs.String(), err)
+	}
+	if raw, _ := entry["tile_space"].(map[string]any); raw["extent"] != 8192.0 {
+		t.Errorf("expected the layer's extent of 8192, got %v", raw)
+	}
+}
+
+func TestParseTileScope(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TileScope
+		wantErr bool
+	}{
+		{"12/650/1425", TileScope{Tile: maptile.New(650, 1425, 12)}, false},
+		{"12/650", TileScope{Tile: maptile.New(650, 0, 12), Column: true}, false},
+		{"0/0/0", TileScope{Tile: maptile.New(0, 0, 0)}, false},
+		{"12", TileScope{}, true},
+		{"12/650/1425/1", TileScope{}, true},
+		{"2/4", TileScope{}, true},
+		{"2/1/4", TileScope{}, true},
+		{"z/x/y", TileScope{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTileScope(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTileScope(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseTileScope(%q) = %+v, want %+v", tt.value, *got, tt.want)
+		}
+		if got.String() != tt.value {
+			t.Errorf("ParseTileScope(%q).String() = %q", tt.value, got.String())
+		}
+	}
+}
+
+func FuzzParseTilePath(f *testing.F) {
+	for _, seed := range []string{
+		"public/tiles/oregon/8/42/95.pbf",
+		"/home/user/data/df/tiles/washington/12/655/1430.pbf",
+		"~/data/df/tiles/merged/0/0/0.pbf",
+		"16/10485/22870",
+		"tiles\\5\\5\\11.pbf",
+		"5/10.pbf",
+		"tiles/3/8/1.pbf",
+		"tiles/-1/0/0.pbf",
+		"tiles/4/4294967296/0.pbf",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		tile, err := ParseTilePath(path)
+		if err != nil {
+			return
+		}
+		if tile.Z > maxTileZoom {
+			t.Fatalf("ParseTilePath(%q) returned zoom %d", path, tile.Z)
+		}
+		if n := uint64(1) << tile.Z; uint64(tile.X) >= n || uint64(tile.Y) >= n {
+			t.Fatalf("ParseTilePath(%q) returned out-of-range tile %v", path, tile)
+		}
+		// A parsed tile must round-trip through its own key
+		again, err := ParseTilePath(tileKey(tile))
+		if err != nil || again != tile {
+			t.Fatalf("ParseTilePath(%q) = %v, but its key %q parses to %v, %v", path, tile, tileKey(tile), again, err)
+		}
+	})
+}
+
+func TestParseCurvatureProperty(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string // "" = nil
+		wantErr bool
+	}{
+		{"missing", nil, "", false},
+		{"whole number string", "1250", "1250", false},
+		{"padded string", " 1250 ", "1250", false},
+		{"number", 850.0, "850.00", false},
+		{"zero", "0", "0", false},
+		{"oversized string", strings.Repeat("9", 5000), "", true},
+		{"oversized description", "curvature: " + strings.Repeat("x", 2000), "", true},
+		{"not a number", "very twisty", "", true},
+		{"negative", "-5", "", true},
+		{"absurdly large", 1e300, "", true},
+		{"absurdly large string", "99999999999", "", true},
+		{"NaN", "NaN", "", true},
+		{"infinity", math.Inf(1), "", true},
+		{"unexpected type", true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCurvatureProperty(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no curvature, got %q", *got)
+				}
+			} else if got == nil || *got != tt.want {
+				t.Errorf("expected curvature %q, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExtractRoadsFromTileData_OversizedCurvature(t *testing.T) {
+	road := orb.LineString{{-122.34, 47.60}, {-122.33, 47.61}}
+	tile := maptile.At(road[1], 12)
+	var features []*geojson.Feature
+	for id, curvature := range map[string]any{"good": "1250", "oversized": strings.Repeat("7", 1000)} {
+		f := geojson.NewFeature(ProjectLineString(tile, road, mvt.DefaultExtent))
+		f.Properties = geojson.Properties{"id": id, "curvature": curvature}
+		features = append(features, f)
+	}
+	data, err := EncodeTile(mvt.Layers{{Name: "roads", Version: 2, Extent: mvt.DefaultExtent, Features: features}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	e := NewGeometryExtractor()
+	e.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	roads, invalid, err := e.extractRoadsFromTileData(data, "oversized", tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The road is kept, only its curvature is dropped
+	if len(roads) != 2 || invalid != 0 {
+		t.Fatalf("expected 2 valid roads, got %d roads and %d invalid", len(roads), invalid)
+	}
+	for _, r := range roads {
+		switch r.RoadID {
+		case "good":
+			if r.Curvature == nil || *r.Curvature != "1250" {
+				t.Errorf("expected curvature 1250, got %v", r.Curvature)
+			}
+		case "oversized":
+			if r.Curvature != nil {
+				t.Errorf("expected the oversized curvature to be ignored, got %d characters", len(*r.Curvature))
+			}
+		}
+	}
+	if !strings.Contains(logs.String(), "ignoring invalid curvature") || !strings.Contains(logs.String(), `"road_id":"oversized"`) {
+		t.Errorf("expected a warning naming the road, got %s", logs.String())
+	}
+	if strings.Contains(logs.String(), strings.Repeat("7", 100)) {
+		t.Error("expected the warning not to repeat the oversized value")
+	}
+}
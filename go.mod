@@ -11,6 +11,7 @@ require (
 	github.com/aws/smithy-go v1.23.2
 	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.32
 	github.com/paulmach/orb v0.11.1
 	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+	"github.com/paulmach/orb/maptile/tilecover"
+)
+
+// Tile encoders selectable with GenerateTilesOptions.Encoder
+const (
+	EncoderTippecanoe = "tippecanoe"
+	EncoderGo         = "go"
+)
+
+// goEncoderLayer is the layer name written by the Go encoder, matching the
+// --layer passed to Tippecanoe
+const goEncoderLayer = "roads"
+
+// goEncoderBuffer is how far, in tile extent units, features are kept past
+// the tile edge so lines don't show seams between neighbouring tiles
+const goEncoderBuffer = 64
+
+// goEncoderProperties are the feature properties kept in tiles, matching the
+// --include list passed to Tippecanoe
+var goEncoderProperties = []string{"id", "Name", "curvature", "length", "startLat", "startLng", "endLat", "endLng"}
+
+// encodeTilesGo is a pure-Go fallback for Tippecanoe. It assigns every
+// feature in the GeoJSON file to the tiles it covers at each zoom, clips and
+// projects it into tile space, and writes uncompressed {z}/{x}/{y}.pbf files.
+// There is no feature dropping or simplification, so it is only suitable
+// for small datasets. A non-empty idAttribute is promoted to the feature ID
+// like Tippecanoe's --use-attribute-for-id. progress, when set, receives
+// the zoom levels done.
+func encodeTilesGo(ctx context.Context, geoJSONPath, tilesDir string, minZoom, maxZoom int, idAttribute string, progress ProgressFunc) error {
+	data, err := os.ReadFile(geoJSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoJSON: %w", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	withoutID := 0
+	for _, f := range fc.Features {
+		if idAttribute != "" {
+			if id, ok := attributeFeatureID(f.Properties, idAttribute); ok {
+				f.ID = id
+			} else {
+				f.ID = nil
+				withoutID++
+			}
+		}
+		f.Properties = filterProperties(f.Properties)
+	}
+	if withoutID > 0 {
+		slog.Warn("features without a usable ID attribute get no feature ID",
+			"attribute", idAttribute, "features", withoutID)
+	}
+
+	for z := minZoom; z <= maxZoom; z++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		byTile := make(map[maptile.Tile][]*geojson.Feature)
+		for _, f := range fc.Features {
+			tiles, err := tilecover.Geometry(f.Geometry, maptile.Zoom(z))
+			if err != nil {
+				return fmt.Errorf("failed to compute tile cover: %w", err)
+			}
+			for tile := range tiles {
+				byTile[tile] = append(byTile[tile], f)
+			}
+		}
+
+		written := 0
+		for tile, features := range byTile {
+			ok, err := writeGoTile(tilesDir, tile, features)
+			if err != nil {
+				return err
+			}
+			if ok {
+				written++
+			}
+		}
+		slog.Debug("encoded zoom level", "zoom", z, "tiles", written)
+		if progress != nil {
+			progress(z-minZoom+1, maxZoom-minZoom+1)
+		}
+	}
+
+	return nil
+}
+
+// writeGoTile encodes features into tile and writes it under tilesDir. It
+// returns false when every feature clipped away.
+func writeGoTile(tilesDir string, tile maptile.Tile, features []*geojson.Feature) (bool, error) {
+	layer := &mvt.Layer{Name: goEncoderLayer, Version: 2, Extent: mvt.DefaultExtent}
+	for _, f := range features {
+		g := projectAndClip(tile, f.Geometry)
+		if g == nil {
+			continue
+		}
+		clone := geojson.NewFeature(g)
+		clone.ID = f.ID
+		clone.Properties = f.Properties
+		layer.Features = append(layer.Features, clone)
+	}
+	if len(layer.Features) == 0 {
+		return false, nil
+	}
+
+	data, err := EncodeTile(mvt.Layers{layer})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode tile %d/%d/%d: %w", tile.Z, tile.X, tile.Y, err)
+	}
+
+	dir := filepath.Join(tilesDir, fmt.Sprint(tile.Z), fmt.Sprint(tile.X))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create tile directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.pbf", tile.Y)), data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write tile: %w", err)
+	}
+	return true, nil
+}
+
+// projectAndClip projects g into tile space and clips it to the tile plus
+// goEncoderBuffer, snapping to the integer grid. It returns nil when nothing
+// is left inside the tile.
+func projectAndClip(tile maptile.Tile, g orb.Geometry) orb.Geometry {
+	const extent = mvt.DefaultExtent
+	bound := TileClipBound(extent, goEncoderBuffer)
+
+	switch g := g.(type) {
+	case orb.Point:
+		p := LatLngToTileCoord(tile, g, extent)
+		if !bound.Contains(p) {
+			return nil
+		}
+		return snapPoint(p)
+	case orb.LineString:
+		return snapLines(ClipLineString(ProjectLineString(tile, g, extent), bound))
+	case orb.MultiLineString:
+		var parts orb.MultiLineString
+		for _, ls := range g {
+			parts = append(parts, ClipLineString(ProjectLineString(tile, ls, extent), bound)...)
+		}
+		return snapLines(parts)
+	default:
+		// Other geometry types go through orb's projection and clipping
+		layer := &mvt.Layer{Extent: extent, Features: []*geojson.Feature{geojson.NewFeature(orb.Clone(g))}}
+		layer.ProjectToTile(tile)
+		layer.Clip(bound)
+		if len(layer.Features) == 0 {
+			return nil
+		}
+		return layer.Features[0].Geometry
+	}
+}
+
+// snapPoint floors p to the tile grid, as orb's projection does
+func snapPoint(p orb.Point) orb.Point {
+	return orb.Point{math.Floor(p[0]), math.Floor(p[1])}
+}
+
+// snapLines snaps parts to the tile grid, dropping repeated points and parts
+// that collapse to a single point
+func snapLines(parts orb.MultiLineString) orb.Geometry {
+	var snapped orb.MultiLineString
+	for _, ls := range parts {
+		var line orb.LineString
+		for _, p := range ls {
+			p = snapPoint(p)
+			if len(line) > 0 && line[len(line)-1] == p {
+				continue
+			}
+			line = append(line, p)
+		}
+		if len(line) > 1 {
+			snapped = append(snapped, line)
+		}
+	}
+
+	switch len(snapped) {
+	case 0:
+		return nil
+	case 1:
+		return snapped[0]
+	default:
+		return snapped
+	}
+}
+
+// attributeFeatureID returns the value of a feature's attr property as a
+// feature ID. As with Tippecanoe, only non-negative integers qualify.
+func attributeFeatureID(props geojson.Properties, attr string) (uint64, bool) {
+	v, ok := props[attr].(float64)
+	if !ok || v < 0 || v != math.Trunc(v) || v > math.MaxInt64 {
+		return 0, false
+	}
+	return uint64(v), true
+}
+
+// filterProperties keeps only goEncoderProperties
+func filterProperties(props geojson.Properties) geojson.Properties {
+	kept := make(geojson.Properties, len(goEncoderProperties))
+	for _, key := range goEncoderProperties {
+		if v, ok := props[key]; ok {
+			kept[key] = v
+		}
+	}
+	return kept
+}
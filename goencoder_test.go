@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+// writeTestGeoJSON writes a small road dataset around Seattle
+func writeTestGeoJSON(t *testing.T) string {
+	t.Helper()
+	road := geojson.NewFeature(orb.LineString{{-122.40, 47.55}, {-122.30, 47.60}, {-122.20, 47.65}})
+	road.ID = 1
+	road.Properties = geojson.Properties{"id": "road-1", "Name": "Test Road", "curvature": 1200.0, "length": 15000.0, "internal": "dropped"}
+
+	fc := geojson.NewFeatureCollection()
+	fc.Append(road)
+	data, err := fc.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "roads.geojson")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerateTiles_GoEncoder(t *testing.T) {
+	geoJSONPath := writeTestGeoJSON(t)
+	outDir := t.TempDir()
+
+	tilesDir, count, size, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "testregion", outDir,
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 10, Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 6 || size == 0 {
+		t.Fatalf("expected at least one tile per zoom, got %d tiles (%d bytes)", count, size)
+	}
+	if IsIncompleteTileDir(tilesDir) {
+		t.Error("expected incomplete marker to be removed")
+	}
+
+	for z := 5; z <= 10; z++ {
+		tile := maptile.At(orb.Point{-122.30, 47.60}, maptile.Zoom(z))
+		path := filepath.Join(tilesDir, tileRelPath(tile))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("zoom %d: expected tile covering the road: %v", z, err)
+		}
+
+		layers, err := mvt.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("zoom %d: failed to decode tile: %v", z, err)
+		}
+		if len(layers) != 1 || layers[0].Name != goEncoderLayer || len(layers[0].Features) != 1 {
+			t.Fatalf("zoom %d: expected one roads feature, got %+v", z, layers)
+		}
+
+		f := layers[0].Features[0]
+		if f.Properties["Name"] != "Test Road" || f.Properties["id"] != "road-1" {
+			t.Errorf("zoom %d: unexpected properties %v", z, f.Properties)
+		}
+		if _, ok := f.Properties["internal"]; ok {
+			t.Errorf("zoom %d: expected non-included property to be dropped", z)
+		}
+		if _, ok := f.Geometry.(orb.LineString); !ok {
+			t.Errorf("zoom %d: expected LineString geometry, got %T", z, f.Geometry)
+		}
+
+		// Decoded back to WGS84 the line still passes near its midpoint
+		layers.ProjectToWGS84(tile)
+		if !layers[0].Features[0].Geometry.Bound().Pad(0.01).Contains(orb.Point{-122.30, 47.60}) {
+			t.Errorf("zoom %d: decoded geometry %v does not cover the road", z, layers[0].Features[0].Geometry.Bound())
+		}
+	}
+
+	// No tiles far away from the road
+	far := maptile.At(orb.Point{-100, 40}, 8)
+	if _, err := os.Stat(filepath.Join(tilesDir, tileRelPath(far))); !os.IsNotExist(err) {
+		t.Error("expected no tile away from the data")
+	}
+}
+
+func TestGenerateTiles_GoEncoderIDAttribute(t *testing.T) {
+	t.Chdir(t.TempDir())
+	fc := geojson.NewFeatureCollection()
+	for i, osmID := range []interface{}{42.0, "way/7", -3.0, nil} {
+		road := geojson.NewFeature(orb.LineString{{-122.40, 47.55 + float64(i)*0.01}, {-122.20, 47.55 + float64(i)*0.01}})
+		road.Properties = geojson.Properties{"Name": fmt.Sprintf("Road %d", i)}
+		if osmID != nil {
+			road.Properties["osm_id"] = osmID
+		}
+		fc.Append(road)
+	}
+	data, err := fc.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	geoJSONPath := filepath.Join(t.TempDir(), "roads.geojson")
+	if err := os.WriteFile(geoJSONPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tilesDir, _, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "ids", t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 10, MaxZoom: 10, Encoder: EncoderGo, IDAttribute: "osm_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the non-negative integer becomes a feature ID
+	tile := maptile.At(orb.Point{-122.30, 47.56}, 10)
+	tileData, err := os.ReadFile(filepath.Join(tilesDir, tileRelPath(tile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := mvt.Unmarshal(tileData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]interface{}{}
+	for _, f := range layers[0].Features {
+		ids[f.Properties["Name"].(string)] = f.ID
+	}
+	want := map[string]interface{}{"Road 0": 42.0, "Road 1": nil, "Road 2": nil, "Road 3": nil}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("expected feature IDs %v, got %v", want, ids)
+	}
+
+	// Without an id property, extraction falls back to the feature ID
+	roads, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(tilesDir), "ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, road := range roads {
+		if road.Name == "Road 0" {
+			found = true
+			if road.RoadID != "42" {
+				t.Errorf("expected road ID 42 from the feature ID, got %q", road.RoadID)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected Road 0 to be extracted")
+	}
+}
+
+func TestGenerateTiles_UnknownEncoder(t *testing.T) {
+	_, _, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "testregion", t.TempDir(),
+		&GenerateTilesOptions{Encoder: "mapnik"})
+	if err == nil || !strings.Contains(err.Error(), "unknown tile encoder") {
+		t.Errorf("expected unknown encoder error, got %v", err)
+	}
+}
+
+// tileRelPath returns the {z}/{x}/{y}.pbf path of tile
+func tileRelPath(tile maptile.Tile) string {
+	return filepath.Join(fmt.Sprint(tile.Z), fmt.Sprint(tile.X), fmt.Sprintf("%d.pbf", tile.Y))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/paulmach/orb/encoding/mvt"
+)
+
+// mergeTilesGo is a pure-Go fallback for tile-join. Tiles found in a single
+// input are copied as they are; tiles found in several are decoded and their
+// layers combined by name, with every input's features kept. Inputs must be
+// {z}/{x}/{y}.pbf directories. Output tiles are uncompressed, like tile-join
+// run with --no-tile-compression.
+func mergeTilesGo(ctx context.Context, inputDirs []string, outputDir string, opts *MergeTilesOptions) error {
+	sources := make(map[TileCoord][]string)
+	for _, dir := range inputDirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read merge input: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("merge input %s is not a tile directory (the Go merge does not read MBTiles)", dir)
+		}
+
+		coords, err := GetTileCoords(dir)
+		if err != nil {
+			return fmt.Errorf("failed to list tiles in %s: %w", dir, err)
+		}
+		for coord := range coords {
+			if opts != nil && ((opts.MinZoom >= 0 && coord.Z < opts.MinZoom) || (opts.MaxZoom >= 0 && coord.Z > opts.MaxZoom)) {
+				continue
+			}
+			sources[coord] = append(sources[coord], filepath.Join(dir, coord.relPath()))
+		}
+	}
+
+	coords := make([]TileCoord, 0, len(sources))
+	for coord := range sources {
+		coords = append(coords, coord)
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		a, b := coords[i], coords[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+
+	progress := opts.progress()
+	combined := 0
+	for i, coord := range coords {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := mergeTileData(sources[coord])
+		if err != nil {
+			return fmt.Errorf("failed to merge tile %d/%d/%d: %w", coord.Z, coord.X, coord.Y, err)
+		}
+		if len(sources[coord]) > 1 {
+			combined++
+		}
+
+		path := filepath.Join(outputDir, coord.relPath())
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create tile directory: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write tile: %w", err)
+		}
+		if progress != nil {
+			progress(i+1, len(coords))
+		}
+	}
+
+	slog.Debug("merged tiles with the Go merge", "tiles", len(coords), "combined", combined)
+	return nil
+}
+
+// mergeTileData returns the merged contents of the tile files in paths
+func mergeTileData(paths []string) ([]byte, error) {
+	var layers mvt.Layers
+	byName := make(map[string]*mvt.Layer)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tile: %w", err)
+		}
+		gzipped := bytes.HasPrefix(data, []byte{0x1f, 0x8b})
+		if len(paths) == 1 && !gzipped {
+			return data, nil
+		}
+
+		decoded, err := decodeTile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		for _, layer := range decoded {
+			if existing, ok := byName[layer.Name]; ok {
+				existing.Features = append(existing.Features, layer.Features...)
+				continue
+			}
+			byName[layer.Name] = layer
+			layers = append(layers, layer)
+		}
+	}
+
+	return EncodeTile(layers)
+}
+
+// relPath returns the tile's {z}/{x}/{y}.pbf path relative to a tiles directory
+func (c TileCoord) relPath() string {
+	return filepath.Join(fmt.Sprint(c.Z), fmt.Sprint(c.X), fmt.Sprintf("%d.pbf", c.Y))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+)
+
+// writeMergeTile writes a tile at coord under dir with one "roads" feature per id
+func writeMergeTile(t *testing.T, dir string, coord TileCoord, ids ...int) {
+	t.Helper()
+	layer := &mvt.Layer{Name: goEncoderLayer, Version: 2, Extent: mvt.DefaultExtent}
+	for _, id := range ids {
+		f := geojson.NewFeature(orb.LineString{{10, 10}, {float64(100 + id), 200}})
+		f.ID = id
+		layer.Features = append(layer.Features, f)
+	}
+	data, err := EncodeTile(mvt.Layers{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, coord.relPath())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeStubTileJoin writes a tile-join into binDir that records its
+// arguments and writes one tile into the output directory
+func writeStubTileJoin(t *testing.T) (binDir, argsFile string) {
+	t.Helper()
+	binDir = t.TempDir()
+	argsFile = filepath.Join(binDir, "args.txt")
+	script := `#!/bin/sh
+echo "$@" > "` + argsFile + `"
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+mkdir -p "$out/3/1"
+echo joined > "$out/3/1/2.pbf"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "tile-join"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return binDir, argsFile
+}
+
+// installStubTileJoin puts the stub tile-join on PATH
+func installStubTileJoin(t *testing.T) (argsFile string) {
+	t.Helper()
+	binDir, argsFile := writeStubTileJoin(t)
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+// hideTileJoin leaves only a PATH without tile-join, forcing the Go merge
+func hideTileJoin(t *testing.T) {
+	t.Helper()
+	t.Setenv("PATH", t.TempDir())
+	if CheckTileJoin(TileJoinBin(DefaultTippecanoeBin)) == nil {
+		t.Fatal("expected tile-join to be unavailable")
+	}
+}
+
+func TestTileJoinBin(t *testing.T) {
+	tests := []struct {
+		tippecanoeBin string
+		want          string
+	}{
+		{"tippecanoe", "tile-join"},
+		{"/opt/tippecanoe/bin/tippecanoe", "/opt/tippecanoe/bin/tile-join"},
+		{"./tippecanoe", "./tile-join"},
+		{"bin/tippecanoe", "bin/tile-join"},
+	}
+	for _, tt := range tests {
+		if got := TileJoinBin(tt.tippecanoeBin); got != tt.want {
+			t.Errorf("TileJoinBin(%q) = %q, want %q", tt.tippecanoeBin, got, tt.want)
+		}
+	}
+}
+
+func TestMergeWithTileJoin_UsesTileJoin(t *testing.T) {
+	argsFile := installStubTileJoin(t)
+	a, b, out := t.TempDir(), t.TempDir(), t.TempDir()
+
+	if err := MergeWithTileJoin(context.Background(), []string{a, b}, out); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "3", "1", "2.pbf")); err != nil || string(data) != "joined\n" {
+		t.Errorf("expected the stub's tile in the output, got %q (%v)", data, err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), "--output-to-directory="+out) || !strings.HasSuffix(strings.TrimSpace(string(args)), a+" "+b) {
+		t.Errorf("unexpected tile-join arguments %q", args)
+	}
+}
+
+func TestMergeTilesWithOptions_TileJoinBesideTippecanoe(t *testing.T) {
+	binDir, argsFile := writeStubTileJoin(t)
+	a := t.TempDir()
+	writeMergeTile(t, a, TileCoord{4, 1, 1}, 1)
+	out := filepath.Join(t.TempDir(), "merged")
+
+	opts := &MergeTilesOptions{MinZoom: -1, MaxZoom: -1, TippecanoeBin: filepath.Join(binDir, "tippecanoe")}
+	if _, err := MergeTilesWithOptions(context.Background(), []string{a}, out, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(argsFile); err != nil {
+		t.Errorf("expected the tile-join beside TIPPECANOE_BIN to run: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "3", "1", "2.pbf")); err != nil || string(data) != "joined\n" {
+		t.Errorf("expected the stub's tile in the output, got %q (%v)", data, err)
+	}
+}
+
+func TestMergeTileDirs_GoFallback(t *testing.T) {
+	hideTileJoin(t)
+	a, b, out := t.TempDir(), t.TempDir(), t.TempDir()
+	shared := TileCoord{5, 3, 4}
+	writeMergeTile(t, a, shared, 1, 2)
+	writeMergeTile(t, b, shared, 3)
+	writeMergeTile(t, a, TileCoord{6, 7, 8}, 4)
+
+	if err := mergeTileDirs(context.Background(), []string{a, b}, out, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overlapping tiles combine every input's features into one layer
+	data, err := os.ReadFile(filepath.Join(out, shared.relPath()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := decodeTile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].Name != goEncoderLayer || len(layers[0].Features) != 3 {
+		t.Fatalf("expected one roads layer with 3 features, got %+v", layers)
+	}
+
+	// Tiles from a single input are copied byte for byte
+	want, _ := os.ReadFile(filepath.Join(a, "6", "7", "8.pbf"))
+	got, err := os.ReadFile(filepath.Join(out, "6", "7", "8.pbf"))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("expected the single-source tile to be copied unchanged (%v)", err)
+	}
+}
+
+func TestMergeTilesWithOptions_GoFallbackZoomFilter(t *testing.T) {
+	hideTileJoin(t)
+	a, b := t.TempDir(), t.TempDir()
+	writeMergeTile(t, a, TileCoord{4, 1, 1}, 1)
+	writeMergeTile(t, b, TileCoord{5, 2, 2}, 2)
+	writeMergeTile(t, b, TileCoord{6, 4, 4}, 3)
+	out := filepath.Join(t.TempDir(), "merged")
+
+	metadata, err := MergeTilesWithOptions(context.Background(), []string{a, b}, out, &MergeTilesOptions{MinZoom: 5, MaxZoom: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.TilesCount != 2 {
+		t.Errorf("expected zooms 5 and 6 only, got %d tiles", metadata.TilesCount)
+	}
+	if _, err := os.Stat(filepath.Join(out, "4")); !os.IsNotExist(err) {
+		t.Error("expected zoom 4 to be filtered out")
+	}
+}
+
+func TestMergeTileDirs_GoFallbackRejectsMBTiles(t *testing.T) {
+	hideTileJoin(t)
+	mbtiles := filepath.Join(t.TempDir(), "region.mbtiles")
+	if err := os.WriteFile(mbtiles, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mergeTileDirs(context.Background(), []string{mbtiles}, t.TempDir(), nil); err == nil {
+		t.Error("expected an error for an MBTiles input without tile-join")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// InsertProgress is the checkpoint insert-geometries keeps while inserting an
+// extraction file, so an interrupted run resumes where it stopped
+type InsertProgress struct {
+	Region     string `json:"region"`
+	File       string `json:"file"`
+	FileSize   int64  `json:"fileSize"`
+	FileMtime  int64  `json:"fileMtime"` // Unix nanoseconds
+	TotalRoads int    `json:"totalRoads"`
+	Inserted   int    `json:"inserted"` // Roads committed, always a prefix of the file
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// sameFile reports whether the checkpoint was written for this version of
+// the extraction file, so a re-extraction with the same road count is not
+// mistaken for the file the checkpoint counted
+func (p *InsertProgress) sameFile(other *InsertProgress) bool {
+	return p.File == other.File && p.FileSize == other.FileSize && p.FileMtime == other.FileMtime
+}
+
+// insertProgressFile returns the checkpoint path for a region, next to the
+// extraction progress file
+func insertProgressFile(region string) string {
+	return filepath.Join(".", fmt.Sprintf(".insert-progress-%s.json", region))
+}
+
+// loadInsertProgress reads a region's checkpoint, or returns nil if there is
+// none
+func loadInsertProgress(region string) *InsertProgress {
+	data, err := os.ReadFile(insertProgressFile(region))
+	if err != nil {
+		return nil
+	}
+
+	var progress InsertProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		slog.Warn("ignoring unreadable insert progress", "region", region, "error", err)
+		return nil
+	}
+	return &progress
+}
+
+// saveInsertProgress writes the checkpoint through a temp file so a crash
+// mid-write never leaves a truncated one
+func saveInsertProgress(progress *InsertProgress) error {
+	progress.UpdatedAt = time.Now().Unix()
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := insertProgressFile(progress.Region)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// insertRoadGeometriesResumable upserts roads from extractionFile, skipping
+// the roads a previous interrupted run already committed and checkpointing
+// after every commit. The checkpoint is removed once every road is in. It
+// returns the number of roads inserted by this run.
+func insertRoadGeometriesResumable(ctx context.Context, db *Database, extractionFile, region string, roads []RoadGeometry, keys RoadKeyStrategy, batchSize int) (int, error) {
+	logger := slog.With("region", region, "file", extractionFile)
+
+	info, err := os.Stat(extractionFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat extraction file: %w", err)
+	}
+	progress := &InsertProgress{
+		Region:     region,
+		File:       extractionFile,
+		FileSize:   info.Size(),
+		FileMtime:  info.ModTime().UnixNano(),
+		TotalRoads: len(roads),
+	}
+
+	offset := 0
+	if prev := loadInsertProgress(region); prev != nil {
+		if prev.sameFile(progress) && prev.TotalRoads == len(roads) && prev.Inserted <= len(roads) {
+			offset = prev.Inserted
+			logger.Info("resuming insertion", "already_inserted", offset, "total", len(roads))
+		} else {
+			logger.Warn("insert progress is for a different extraction file, starting over",
+				"progress_file", prev.File, "progress_total", prev.TotalRoads)
+		}
+	}
+
+	progress.Inserted = offset
+	inserted, err := db.BatchUpsertRoadGeometries(ctx, roads[offset:], keys, batchSize, func(n int) {
+		progress.Inserted = offset + n
+		if err := saveInsertProgress(progress); err != nil {
+			logger.Warn("failed to save insert progress", "error", err)
+		}
+	})
+	if err != nil {
+		return inserted, err
+	}
+
+	os.Remove(insertProgressFile(region))
+	return inserted, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	registerRoadsDriver sync.Once
+	roadRowsSent        atomic.Int64 // Rows sent to the test database, counted via gen_random_uuid()
+)
+
+// openTestSQLite opens an empty SQLite database with the Postgres functions
+// the queries under test use registered
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	requireSQLite(t)
+	registerRoadsDriver.Do(func() {
+		sql.Register("sqlite3_roads", postgresLockingDriver{&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				funcs := map[string]any{
+					"gen_random_uuid": func() string { roadRowsSent.Add(1); return uuid.NewString() },
+					"now":             func() string { return time.Now().UTC().Format(time.RFC3339) },
+					"least":           math.Min,
+					"greatest":        math.Max,
+				}
+				for name, fn := range funcs {
+					if err := conn.RegisterFunc(name, fn, name == "least" || name == "greatest"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}})
+	})
+
+	conn, err := sql.Open("sqlite3_roads", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// postgresLockingDriver drops the row locking clauses SQLite does not parse.
+// SQLite runs one writer at a time, so a statement already holds the only
+// write lock.
+type postgresLockingDriver struct{ driver.Driver }
+
+func (d postgresLockingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return postgresLockingConn{conn}, nil
+}
+
+type postgresLockingConn struct{ driver.Conn }
+
+func stripRowLocks(query string) string {
+	return strings.ReplaceAll(query, "FOR UPDATE SKIP LOCKED", "")
+}
+
+func (c postgresLockingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(stripRowLocks(query))
+}
+
+func (c postgresLockingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, stripRowLocks(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c postgresLockingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, stripRowLocks(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c postgresLockingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// newRoadsTestDB returns a Database on an SQLite "RoadGeometry" table
+func newRoadsTestDB(t *testing.T) *Database {
+	t.Helper()
+	conn := openTestSQLite(t)
+	_, err := conn.Exec(`CREATE TABLE "RoadGeometry" (
+		id TEXT, "roadId" TEXT, name TEXT, region TEXT,
+		"minLat" REAL, "maxLat" REAL, "minLng" REAL, "maxLng" REAL,
+		curvature TEXT, length REAL,
+		"startLat" REAL, "startLng" REAL, "endLat" REAL, "endLng" REAL,
+		"createdAt" TEXT, "updatedAt" TEXT,
+		UNIQUE ("roadId", region)
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Database{conn: conn}
+}
+
+func TestInsertRoadGeometriesResumable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	prev := rowsPerTransaction
+	rowsPerTransaction = 10
+	t.Cleanup(func() { rowsPerTransaction = prev })
+
+	db := newRoadsTestDB(t)
+	roads := make([]RoadGeometry, 25)
+	for i := range roads {
+		roads[i] = RoadGeometry{RoadID: fmt.Sprintf("road-%02d", i), Name: "Road", Region: "resume", MinLat: 1, MaxLat: 2, MinLng: 3, MaxLng: 4}
+	}
+	const file = ".extracted-roads-resume.json"
+	if err := os.WriteFile(file, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kill the first run in its second transaction, as a crash would
+	_, err := db.conn.Exec(`CREATE TRIGGER crash BEFORE INSERT ON "RoadGeometry"
+		WHEN NEW."roadId" = 'road-12' BEGIN SELECT RAISE(ABORT, 'crash'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := insertRoadGeometriesResumable(context.Background(), db, file, "resume", roads, RoadKeyRegional, 5); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	if _, err := db.conn.Exec(`DROP TRIGGER crash`); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := loadInsertProgress("resume")
+	if checkpoint == nil || checkpoint.Inserted != 10 || checkpoint.TotalRoads != 25 {
+		t.Fatalf("expected a checkpoint after the first transaction, got %+v", checkpoint)
+	}
+	var committed int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM "RoadGeometry"`).Scan(&committed); err != nil {
+		t.Fatal(err)
+	}
+	if committed != checkpoint.Inserted {
+		t.Fatalf("checkpoint says %d roads, table has %d", checkpoint.Inserted, committed)
+	}
+
+	// The second run sends only the rest
+	roadRowsSent.Store(0)
+	inserted, err := insertRoadGeometriesResumable(context.Background(), db, file, "resume", roads, RoadKeyRegional, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 25 - checkpoint.Inserted; inserted != want || roadRowsSent.Load() != int64(want) {
+		t.Errorf("expected the resumed run to insert %d roads, inserted %d and sent %d rows", want, inserted, roadRowsSent.Load())
+	}
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM "RoadGeometry"`).Scan(&committed); err != nil {
+		t.Fatal(err)
+	}
+	if committed != 25 {
+		t.Errorf("expected all 25 roads in the table, got %d", committed)
+	}
+	if _, err := os.Stat(insertProgressFile("resume")); !os.IsNotExist(err) {
+		t.Error("expected the checkpoint to be removed after a complete run")
+	}
+}
+
+func TestInsertRoadGeometriesResumable_OtherFileStartsOver(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db := newRoadsTestDB(t)
+	roads := []RoadGeometry{{RoadID: "a", Region: "other"}, {RoadID: "b", Region: "other"}}
+	if err := os.WriteFile("new.json", []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat("new.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, mtime := info.Size(), info.ModTime().UnixNano()
+
+	tests := []struct {
+		name       string
+		checkpoint InsertProgress
+	}{
+		{"other file name", InsertProgress{File: "old.json", FileSize: size, FileMtime: mtime}},
+		{"file rewritten", InsertProgress{File: "new.json", FileSize: size, FileMtime: mtime - int64(time.Second)}},
+		{"file resized", InsertProgress{File: "new.json", FileSize: size + 1, FileMtime: mtime}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint := tt.checkpoint
+			checkpoint.Region, checkpoint.TotalRoads, checkpoint.Inserted = "other", 2, 2
+			if err := saveInsertProgress(&checkpoint); err != nil {
+				t.Fatal(err)
+			}
+			inserted, err := insertRoadGeometriesResumable(context.Background(), db, "new.json", "other", roads, RoadKeyRegional, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inserted != 2 {
+				t.Errorf("expected a checkpoint for another file to be ignored, inserted %d", inserted)
+			}
+		})
+	}
+}
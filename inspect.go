@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+// InspectedLayer is the tile-space dump of one MVT layer
+type InspectedLayer struct {
+	Name     string             `json:"name"`
+	Version  uint32             `json:"version"`
+	Extent   uint32             `json:"extent"`
+	Features []InspectedFeature `json:"features"`
+}
+
+// InspectedFeature is one feature of an InspectedLayer. Coordinates are in
+// tile space (0..extent, y pointing down).
+type InspectedFeature struct {
+	ID         interface{}       `json:"id,omitempty"`
+	Properties map[string]any    `json:"properties"`
+	Geometry   *geojson.Geometry `json:"geometry"`
+}
+
+// decodeTile decodes an MVT tile, gzipped or not
+func decodeTile(data []byte) (mvt.Layers, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return mvt.UnmarshalGzipped(data)
+	}
+	return mvt.Unmarshal(data)
+}
+
+// InspectTile decodes a tile into its layers and features in tile space
+func InspectTile(data []byte) ([]InspectedLayer, error) {
+	layers, err := decodeTile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile: %w", err)
+	}
+
+	inspected := make([]InspectedLayer, 0, len(layers))
+	for _, layer := range layers {
+		il := InspectedLayer{
+			Name:     layer.Name,
+			Version:  layer.Version,
+			Extent:   layer.Extent,
+			Features: make([]InspectedFeature, 0, len(layer.Features)),
+		}
+		for _, f := range layer.Features {
+			il.Features = append(il.Features, InspectedFeature{
+				ID:         f.ID,
+				Properties: f.Properties,
+				Geometry:   geojson.NewGeometry(f.Geometry),
+			})
+		}
+		inspected = append(inspected, il)
+	}
+	return inspected, nil
+}
+
+// TileToGeoJSON decodes a tile and converts its geometries back to lng/lat
+// with TileCoordToLatLng. Each feature gets a "layer" property naming the
+// layer it came from.
+func TileToGeoJSON(data []byte, tile maptile.Tile) (*geojson.FeatureCollection, error) {
+	layers, err := decodeTile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile: %w", err)
+	}
+
+	fc := geojson.NewFeatureCollection()
+	for _, layer := range layers {
+		extent := float64(layer.Extent)
+		if extent == 0 {
+			extent = mvt.DefaultExtent
+		}
+		toLatLng := func(p orb.Point) orb.Point {
+			return TileCoordToLatLng(tile, p[0], p[1], extent)
+		}
+
+		for _, f := range layer.Features {
+			out := geojson.NewFeature(mapGeometryPoints(f.Geometry, toLatLng))
+			out.ID = f.ID
+			for k, v := range f.Properties {
+				out.Properties[k] = v
+			}
+			out.Properties["layer"] = layer.Name
+			fc.Append(out)
+		}
+	}
+	return fc, nil
+}
+
+// mapGeometryPoints returns a copy of g with fn applied to every point
+func mapGeometryPoints(g orb.Geometry, fn func(orb.Point) orb.Point) orb.Geometry {
+	mapLine := func(ls []orb.Point) []orb.Point {
+		out := make([]orb.Point, len(ls))
+		for i, p := range ls {
+			out[i] = fn(p)
+		}
+		return out
+	}
+
+	switch g := g.(type) {
+	case orb.Point:
+		return fn(g)
+	case orb.MultiPoint:
+		return orb.MultiPoint(mapLine(g))
+	case orb.LineString:
+		return orb.LineString(mapLine(g))
+	case orb.MultiLineString:
+		out := make(orb.MultiLineString, len(g))
+		for i, ls := range g {
+			out[i] = mapLine(ls)
+		}
+		return out
+	case orb.Polygon:
+		out := make(orb.Polygon, len(g))
+		for i, r := range g {
+			out[i] = mapLine(r)
+		}
+		return out
+	case orb.MultiPolygon:
+		out := make(orb.MultiPolygon, len(g))
+		for i, poly := range g {
+			out[i] = mapGeometryPoints(poly, fn).(orb.Polygon)
+		}
+		return out
+	case orb.Collection:
+		out := make(orb.Collection, len(g))
+		for i, sub := range g {
+			out[i] = mapGeometryPoints(sub, fn)
+		}
+		return out
+	default:
+		return g
+	}
+}
+
+// inspectTileOutput builds the inspect-tile output for a tile read from
+// tilePath. The tile address comes from geo ("z/x/y") or, failing that, the
+// path, and is read in the given scheme; without one (or with raw set) the
+// tile-space dump is returned.
+func inspectTileOutput(data []byte, tilePath, geo string, raw bool, scheme TileScheme) (interface{}, error) {
+	if raw {
+		return InspectTile(data)
+	}
+
+	if geo != "" {
+		tile, err := ParseTilePath(geo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tile address %q: %w", geo, err)
+		}
+		return TileToGeoJSON(data, scheme.ToXYZ(tile))
+	}
+
+	tile, err := ParseTilePath(tilePath)
+	if err != nil {
+		return InspectTile(data)
+	}
+	return TileToGeoJSON(data, scheme.ToXYZ(tile))
+}
+
+// readInspectTile reads the tile to inspect: the file at tilePath, or the
+// z/x/y tile tilePath names (in the given scheme) inside mbtilesPath when
+// one is given
+func readInspectTile(ctx context.Context, tilePath, mbtilesPath string, scheme TileScheme) ([]byte, error) {
+	if mbtilesPath == "" {
+		return os.ReadFile(tilePath)
+	}
+
+	tile, err := ParseTilePath(tilePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tile address %q: %w", tilePath, err)
+	}
+
+	src, err := OpenMBTiles(mbtilesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return src.ReadTile(ctx, scheme.ToXYZ(tile))
+}
+
+// writeIndentedJSON writes v to w as indented JSON
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
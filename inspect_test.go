@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+// writeFixtureTile encodes one road through Seattle into a roads layer and
+// writes it at {dir}/{z}/{x}/{y}.pbf
+func writeFixtureTile(t *testing.T, dir string) (string, maptile.Tile, orb.LineString) {
+	t.Helper()
+	road := orb.LineString{{-122.34, 47.60}, {-122.33, 47.61}, {-122.32, 47.605}}
+	tile := maptile.At(road[1], 12)
+
+	f := geojson.NewFeature(ProjectLineString(tile, road, mvt.DefaultExtent))
+	f.ID = 7
+	f.Properties = geojson.Properties{"id": "road-7", "Name": "Fixture Way", "curvature": 850.0}
+	data, err := EncodeTile(mvt.Layers{{Name: "roads", Version: 2, Extent: mvt.DefaultExtent, Features: []*geojson.Feature{f}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, tileRelPath(tile))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, tile, road
+}
+
+func TestInspectTileOutput_GeoJSONFromPath(t *testing.T) {
+	path, _, road := writeFixtureTile(t, t.TempDir())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := inspectTileOutput(data, path, "", false, SchemeXYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, ok := out.(*geojson.FeatureCollection)
+	if !ok || len(fc.Features) != 1 {
+		t.Fatalf("expected a one-feature FeatureCollection, got %T %v", out, out)
+	}
+
+	f := fc.Features[0]
+	if f.Properties["Name"] != "Fixture Way" || f.Properties["layer"] != "roads" {
+		t.Errorf("unexpected properties %v", f.Properties)
+	}
+	ls, ok := f.Geometry.(orb.LineString)
+	if !ok || len(ls) != len(road) {
+		t.Fatalf("expected %d-point LineString, got %v", len(road), f.Geometry)
+	}
+	// One tile unit at z12 is well under 1e-4 degrees
+	for i := range road {
+		if math.Abs(ls[i].Lon()-road[i].Lon()) > 1e-4 || math.Abs(ls[i].Lat()-road[i].Lat()) > 1e-4 {
+			t.Errorf("point %d: got %v, want %v", i, ls[i], road[i])
+		}
+	}
+
+	// The result is valid GeoJSON
+	var buf bytes.Buffer
+	if err := writeIndentedJSON(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := geojson.UnmarshalFeatureCollection(buf.Bytes()); err != nil {
+		t.Errorf("output is not a FeatureCollection: %v", err)
+	}
+}
+
+func TestInspectTileOutput_GeoFlagAndGzip(t *testing.T) {
+	path, tile, road := writeFixtureTile(t, t.TempDir())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(data)
+	w.Close()
+
+	// A path without z/x/y needs -geo
+	geo := tileRelPath(tile)
+	out, err := inspectTileOutput(gz.Bytes(), "tile.pbf", geo[:len(geo)-len(".pbf")], false, SchemeXYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := out.(*geojson.FeatureCollection).Features[0].Geometry.(orb.LineString)
+	if math.Abs(ls[0].Lon()-road[0].Lon()) > 1e-4 {
+		t.Errorf("expected lat/lng output, got %v", ls)
+	}
+
+	if _, err := inspectTileOutput(data, "tile.pbf", "not/a/tile", false, SchemeXYZ); err == nil {
+		t.Error("expected error for invalid -geo")
+	}
+}
+
+func TestInspectTileOutput_RawWithoutAddress(t *testing.T) {
+	path, _, _ := writeFixtureTile(t, t.TempDir())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := inspectTileOutput(data, "tile.pbf", "", false, SchemeXYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, ok := out.([]InspectedLayer)
+	if !ok || len(layers) != 1 || layers[0].Name != "roads" || layers[0].Extent != mvt.DefaultExtent {
+		t.Fatalf("expected raw roads layer, got %T %v", out, out)
+	}
+
+	raw, err := json.Marshal(layers[0].Features[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		ID       float64 `json:"id"`
+		Geometry struct {
+			Type        string      `json:"type"`
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != 7 || decoded.Geometry.Type != "LineString" {
+		t.Errorf("unexpected feature %s", raw)
+	}
+	for _, c := range decoded.Geometry.Coordinates {
+		if c[0] < 0 || c[0] > mvt.DefaultExtent || c[1] < 0 || c[1] > mvt.DefaultExtent {
+			t.Errorf("expected tile-space coordinates, got %v", c)
+		}
+	}
+}
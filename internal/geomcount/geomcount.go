@@ -0,0 +1,45 @@
+// Package geomcount counts the coordinate points of geometries, so the
+// pipeline report and the analysis tools compare the same numbers
+package geomcount
+
+import "github.com/paulmach/orb"
+
+// Coordinates returns the number of points in a geometry, recursing into
+// multi-geometries and collections (0 for nil or unknown types)
+func Coordinates(geom orb.Geometry) int {
+	switch g := geom.(type) {
+	case orb.Point:
+		return 1
+	case orb.MultiPoint:
+		return len(g)
+	case orb.LineString:
+		return len(g)
+	case orb.Ring:
+		return len(g)
+	case orb.Polygon:
+		n := 0
+		for _, ring := range g {
+			n += len(ring)
+		}
+		return n
+	case orb.MultiLineString:
+		n := 0
+		for _, line := range g {
+			n += len(line)
+		}
+		return n
+	case orb.MultiPolygon:
+		n := 0
+		for _, poly := range g {
+			n += Coordinates(poly)
+		}
+		return n
+	case orb.Collection:
+		n := 0
+		for _, child := range g {
+			n += Coordinates(child)
+		}
+		return n
+	}
+	return 0
+}
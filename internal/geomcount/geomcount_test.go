@@ -0,0 +1,36 @@
+package geomcount
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestCoordinates(t *testing.T) {
+	line := orb.LineString{{0, 0}, {1, 1}, {2, 0}}
+	ring := orb.Ring{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}
+
+	tests := []struct {
+		name string
+		geom orb.Geometry
+		want int
+	}{
+		{"nil", nil, 0},
+		{"point", orb.Point{1, 2}, 1},
+		{"multi point", orb.MultiPoint{{1, 2}, {3, 4}}, 2},
+		{"line", line, 3},
+		{"ring", ring, 5},
+		{"multi line", orb.MultiLineString{line, line[:2]}, 5},
+		{"polygon with hole", orb.Polygon{ring, ring[:4]}, 9},
+		{"multi polygon", orb.MultiPolygon{{ring}, {ring, ring}}, 15},
+		{"collection", orb.Collection{line, orb.Point{0, 0}}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Coordinates(tt.geom); got != tt.want {
+				t.Errorf("Coordinates(%v) = %d, want %d", tt.geom, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobDefinition records the fully resolved settings of a generation run, so
+// the run can be reproduced later with `generate -from-job`
+type JobDefinition struct {
+	JobID          string            `json:"jobId"`
+	Region         string            `json:"region"`
+	MinZoom        int               `json:"minZoom"`
+	MaxZoom        int               `json:"maxZoom"`
+	Encoder        string            `json:"encoder"`
+	Options        JobOptions        `json:"options"`
+	TippecanoeArgs []string          `json:"tippecanoeArgs,omitempty"`
+	Tools          map[string]string `json:"tools"`
+	Env            map[string]string `json:"env"` // TIPPECANOE_* variables of the run (nil in older definitions)
+	CreatedAt      time.Time         `json:"createdAt"`
+}
+
+// tippecanoeEnvPrefix marks the environment variables that change the
+// generated tiles and are recorded in a JobDefinition
+const tippecanoeEnvPrefix = "TIPPECANOE_"
+
+// newCLIJobID returns a job ID for a command-line run, unique so one run's
+// job definition does not overwrite another's
+func newCLIJobID(prefix string) string {
+	return fmt.Sprintf("%s-%s-%s", prefix, time.Now().UTC().Format("20060102T150405"), uuid.New().String()[:8])
+}
+
+// jobDefinitionPath returns where the definition of jobID is stored in tilesDir
+func jobDefinitionPath(tilesDir, jobID string) string {
+	return filepath.Join(tilesDir, fmt.Sprintf(".job-%s.json", jobID))
+}
+
+// isJobDefinitionFile reports whether name is a stored job definition
+func isJobDefinitionFile(name string) bool {
+	return strings.HasPrefix(name, ".job-") && strings.HasSuffix(name, ".json")
+}
+
+// WriteJobDefinition stores def alongside the tiles in tilesDir
+func WriteJobDefinition(tilesDir string, def *JobDefinition) (string, error) {
+	data, err := json.MarshalIndent(def, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job definition: %w", err)
+	}
+
+	path := jobDefinitionPath(tilesDir, def.JobID)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write job definition: %w", err)
+	}
+	return path, nil
+}
+
+// ReadJobDefinition loads a definition written by WriteJobDefinition
+func ReadJobDefinition(path string) (*JobDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job definition: %w", err)
+	}
+
+	var def JobDefinition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse job definition %s: %w", path, err)
+	}
+	if def.Region == "" {
+		return nil, fmt.Errorf("job definition %s has no region", path)
+	}
+	return &def, nil
+}
+
+// newJobDefinition captures the resolved settings of job as generated with genOpts
+func newJobDefinition(ctx context.Context, job *TileJob, opts JobOptions, genOpts *GenerateTilesOptions, geoJSONPath, tilesDir string) *JobDefinition {
+	minZoom, maxZoom, encoder := genOpts.resolve()
+
+	// Record the effective values, so replaying does not depend on defaults
+	opts.MinZoom = minZoom
+	opts.MaxZoom = maxZoom
+	opts.Encoder = encoder
+	opts.Bounds = genOpts.Bounds
+
+	def := &JobDefinition{
+		JobID:     job.ID,
+		Region:    job.Region,
+		MinZoom:   minZoom,
+		MaxZoom:   maxZoom,
+		Encoder:   encoder,
+		Options:   opts,
+		Tools:     toolVersions(ctx, encoder, genOpts.tippecanoeBin()),
+		Env:       tippecanoeEnv(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if encoder == EncoderTippecanoe {
+		def.TippecanoeArgs = tippecanoeArgs(geoJSONPath, job.Region, tilesDir, genOpts)
+	}
+	return def
+}
+
+// tippecanoeEnv returns the TIPPECANOE_* environment variables, which
+// include those loaded from the .env file
+func tippecanoeEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(key, tippecanoeEnvPrefix) {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// RestoreEnv sets the TIPPECANOE_* environment to the one the definition
+// was generated with, unsetting variables it did not have, and reloads the
+// Tippecanoe settings of cfg from it. Definitions written before the
+// environment was recorded leave it unchanged.
+func (def *JobDefinition) RestoreEnv(cfg *Config) error {
+	if def.Env == nil {
+		return nil
+	}
+	for key := range tippecanoeEnv() {
+		if _, ok := def.Env[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+	for key, value := range def.Env {
+		os.Setenv(key, value)
+	}
+
+	tc, err := loadTippecanoeConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Tippecanoe = tc
+	cfg.Paths.TippecanoeBin = getEnv("TIPPECANOE_BIN", DefaultTippecanoeBin)
+	return nil
+}
+
+// ReplayOptions returns the definition's options with the generate flags
+// given explicitly on the command line (set in fs, parsed into flags)
+// taking their place
+func (def *JobDefinition) ReplayOptions(fs *flag.FlagSet, flags *JobOptions) JobOptions {
+	opts := def.Options
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "max-zoom":
+			opts.MaxZoom = flags.MaxZoom
+		case "min-zoom":
+			opts.MinZoom = flags.MinZoom
+		case "skip-upload":
+			opts.SkipUpload = flags.SkipUpload
+		case "skip-merge":
+			opts.SkipMerge = flags.SkipMerge
+		case "no-cleanup":
+			opts.NoCleanup = flags.NoCleanup
+		case "extract-geometry":
+			opts.ExtractGeometry = flags.ExtractGeometry
+		case "skip-geometry-insertion":
+			opts.SkipGeometryInsertion = flags.SkipGeometryInsertion
+		case "merge-all":
+			opts.MergeAll = flags.MergeAll
+		case "encoder":
+			opts.Encoder = flags.Encoder
+		case "clip":
+			opts.Clip = flags.Clip
+		case "preserve-altitude":
+			opts.PreserveAltitude = flags.PreserveAltitude
+		case "max-tile-features":
+			opts.MaxTileFeatures = flags.MaxTileFeatures
+		case "max-tile-bytes":
+			opts.MaxTileBytes = flags.MaxTileBytes
+		case "id-attribute":
+			opts.IDAttribute = flags.IDAttribute
+		case "atomic-swap":
+			opts.AtomicSwap = flags.AtomicSwap
+		}
+	})
+	return opts
+}
+
+// toolVersions reports the versions of this binary and of the external tools
+// a run depends on. Tools that are not installed are left out.
+func toolVersions(ctx context.Context, encoder, tippecanoeBin string) map[string]string {
+	tools := map[string]string{"go": runtime.Version()}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version := info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				version = setting.Value
+			}
+		}
+		if version != "" {
+			tools["tile-service"] = version
+		}
+	}
+
+	commands := map[string]string{"tile-join": TileJoinBin(tippecanoeBin)}
+	if encoder == EncoderTippecanoe {
+		commands["tippecanoe"] = tippecanoeBin
+	}
+	for name, command := range commands {
+		if version := commandVersion(ctx, command); version != "" {
+			tools[name] = version
+		}
+	}
+	return tools
+}
+
+// commandVersion returns the first line of `name --version`, or "" if the
+// command is not available
+func commandVersion(ctx context.Context, name string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return ""
+	}
+	output, err := exec.CommandContext(ctx, name, "--version").CombinedOutput()
+	if err != nil && len(output) == 0 {
+		return ""
+	}
+	line, _, _ := bufio.NewReader(bytes.NewReader(output)).ReadLine()
+	return strings.TrimSpace(string(line))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestJobDefinition_RoundTrip(t *testing.T) {
+	t.Setenv("TIPPECANOE_EXTEND_ZOOMS", "false")
+	dir := t.TempDir()
+	bound := orb.Bound{Min: orb.Point{-124.8, 45.5}, Max: orb.Point{-116.9, 49.1}}
+	job := &TileJob{ID: "42", Region: "washington"}
+	opts := JobOptions{
+		SkipUpload:      true,
+		SkipMerge:       true,
+		ExtractGeometry: true,
+		Clip:            square(true),
+	}
+	genOpts := &GenerateTilesOptions{MinZoom: 4, Bounds: &bound}
+
+	def := newJobDefinition(context.Background(), job, opts, genOpts, "/tmp/washington.geojson", dir)
+
+	// Unset values are recorded as the defaults generation actually used
+	if def.MinZoom != 4 || def.MaxZoom != 16 || def.Encoder != EncoderTippecanoe {
+		t.Errorf("unexpected resolved settings: zoom %d-%d, encoder %q", def.MinZoom, def.MaxZoom, def.Encoder)
+	}
+	if def.Options.MaxZoom != 16 || def.Options.Encoder != EncoderTippecanoe || def.Options.Bounds == nil {
+		t.Errorf("expected options to carry the resolved values, got %+v", def.Options)
+	}
+	if len(def.TippecanoeArgs) == 0 || def.TippecanoeArgs[len(def.TippecanoeArgs)-1] != "/tmp/washington.geojson" {
+		t.Errorf("unexpected Tippecanoe args %v", def.TippecanoeArgs)
+	}
+	if def.Tools["go"] == "" {
+		t.Errorf("expected the Go version to be recorded, got %v", def.Tools)
+	}
+	if def.Env["TIPPECANOE_EXTEND_ZOOMS"] != "false" {
+		t.Errorf("expected the Tippecanoe environment to be recorded, got %v", def.Env)
+	}
+
+	path, err := WriteJobDefinition(dir, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != ".job-42.json" || !isJobDefinitionFile(filepath.Base(path)) {
+		t.Errorf("unexpected definition path %s", path)
+	}
+
+	got, err := ReadJobDefinition(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(def.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, def.CreatedAt)
+	}
+	got.CreatedAt = def.CreatedAt
+	if !reflect.DeepEqual(got, def) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, def)
+	}
+}
+
+func TestNewJobDefinition_GoEncoderHasNoTippecanoeArgs(t *testing.T) {
+	def := newJobDefinition(context.Background(), &TileJob{ID: "1", Region: "test"}, JobOptions{}, &GenerateTilesOptions{Encoder: EncoderGo, MaxZoom: 10}, "in.geojson", t.TempDir())
+	if def.TippecanoeArgs != nil || def.Encoder != EncoderGo || def.MaxZoom != 10 {
+		t.Errorf("unexpected definition %+v", def)
+	}
+	if _, ok := def.Tools["tippecanoe"]; ok {
+		t.Errorf("did not expect a Tippecanoe version for the go encoder: %v", def.Tools)
+	}
+}
+
+func TestJobDefinition_ReplayOptions(t *testing.T) {
+	def := &JobDefinition{Options: JobOptions{MinZoom: 4, MaxZoom: 12, SkipUpload: false, ExtractGeometry: true, Encoder: EncoderTippecanoe}}
+
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	maxZoom := fs.Int("max-zoom", 16, "")
+	skipUpload := fs.Bool("skip-upload", false, "")
+	extractGeometry := fs.Bool("extract-geometry", true, "")
+	if err := fs.Parse([]string{"-skip-upload", "-max-zoom", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	flags := &JobOptions{MaxZoom: *maxZoom, SkipUpload: *skipUpload, ExtractGeometry: *extractGeometry}
+
+	got := def.ReplayOptions(fs, flags)
+	want := JobOptions{MinZoom: 4, MaxZoom: 10, SkipUpload: true, ExtractGeometry: true, Encoder: EncoderTippecanoe}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReplayOptions = %+v, want %+v", got, want)
+	}
+}
+
+func TestJobDefinition_RestoreEnv(t *testing.T) {
+	t.Setenv("TIPPECANOE_COMPRESS", "true")
+	t.Setenv("TIPPECANOE_EXTRA_ARGS", "--detect-shared-borders")
+	t.Setenv("TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH", "")
+
+	cfg := &Config{Tippecanoe: &TippecanoeConfig{Compress: true}}
+	def := &JobDefinition{Env: map[string]string{"TIPPECANOE_MAX_STRING_ATTRIBUTE_LENGTH": "200"}}
+	if err := def.RestoreEnv(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, set := os.LookupEnv("TIPPECANOE_COMPRESS"); set {
+		t.Error("expected a variable the run did not have to be unset")
+	}
+	if cfg.Tippecanoe.Compress || cfg.Tippecanoe.ExtraArgs != nil || cfg.Tippecanoe.MaxStringAttributeLength != 200 {
+		t.Errorf("expected the recorded settings, got %+v", cfg.Tippecanoe)
+	}
+	if cfg.Paths.TippecanoeBin != DefaultTippecanoeBin {
+		t.Errorf("TippecanoeBin = %q, want the default", cfg.Paths.TippecanoeBin)
+	}
+
+	// Older definitions did not record the environment
+	t.Setenv("TIPPECANOE_COMPRESS", "true")
+	if err := (&JobDefinition{}).RestoreEnv(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("TIPPECANOE_COMPRESS") != "true" {
+		t.Error("expected a definition without an environment to leave it unchanged")
+	}
+}
+
+func TestNewCLIJobID_Unique(t *testing.T) {
+	a, b := newCLIJobID("cli"), newCLIJobID("cli")
+	if a == b || !strings.HasPrefix(a, "cli-") {
+		t.Errorf("expected distinct cli- IDs, got %q and %q", a, b)
+	}
+}
+
+func TestReadJobDefinition_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"broken.json":   "not json",
+		"noregion.json": `{"jobId":"1","options":{}}`,
+		"missing.json":  "",
+	}
+	for name, body := range tests {
+		path := filepath.Join(dir, name)
+		if body != "" {
+			if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := ReadJobDefinition(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestUploadDirectory_SkipsJobDefinitions(t *testing.T) {
+	fake, client := newFakeS3(t)
+	dir := writeDedupFixture(t)
+	if _, err := WriteJobDefinition(dir, &JobDefinition{JobID: "1", Region: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := client.uploadDirectory(context.Background(), dir, "tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 6 || fake.objects["tiles/.job-1.json"] {
+		t.Errorf("expected only the 6 tiles to be uploaded, got %+v", stats)
+	}
+}
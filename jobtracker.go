@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// jobTracker records a job's status and progress in the database. Writes are
+// retried through a database outage (Database.withReconnect). Tracking is
+// best effort: once a write gives up the job carries on untracked, and later
+// writes are tried once each until the database answers again.
+//
+// Each status is also reported to onStep as a step at 0%, followed by the
+// step's percentage as extraction and upload make progress. Pipeline phases
+// are recorded as the job's currentStep and reported to onStepChange.
+type jobTracker struct {
+	db     *Database // nil = no job tracking
+	jobID  string
+	logger *slog.Logger
+	lost   bool // A write gave up and the database has not answered since
+
+	onStep       StepProgressFunc  // nil = no step progress
+	onStepChange func(step string) // nil = not reported
+	mu           sync.Mutex        // Guards percent; steps can report from concurrent phases
+	percent      map[string]int    // Last percentage reported per step since the last status
+}
+
+// newJobTracker returns a tracker for a job; with a nil db every write is a
+// no-op
+func newJobTracker(db *Database, jobID string, logger *slog.Logger) *jobTracker {
+	return &jobTracker{db: db, jobID: jobID, logger: logger}
+}
+
+// status records the job's status and reports it as a step starting at 0%
+func (t *jobTracker) status(ctx context.Context, status string) {
+	t.write(ctx, "update job status", func(ctx context.Context) error {
+		return t.db.UpdateJobStatus(ctx, t.jobID, status)
+	})
+
+	if t.onStep != nil {
+		t.mu.Lock()
+		t.percent = map[string]int{status: 0}
+		t.onStep(status, 0)
+		t.mu.Unlock()
+	}
+}
+
+// step records the pipeline phase the job has entered as its currentStep
+func (t *jobTracker) step(ctx context.Context, step string) {
+	t.write(ctx, "update job step", func(ctx context.Context) error {
+		return t.db.UpdateJobStep(ctx, t.jobID, step)
+	})
+	if t.onStepChange != nil {
+		t.onStepChange(step)
+	}
+}
+
+// cancelled records that the job was cancelled. The job's context is done by
+// then, so the write gets a fresh one.
+func (t *jobTracker) cancelled() {
+	t.write(context.Background(), "update job status", func(ctx context.Context) error {
+		return t.db.UpdateJobStatus(ctx, t.jobID, "cancelled")
+	})
+}
+
+// stepProgress returns a ProgressFunc that reports done/total as step's
+// percentage, each whole percent once, then calls next if it is set
+func (t *jobTracker) stepProgress(step string, next ProgressFunc) ProgressFunc {
+	return func(done, total int) {
+		if next != nil {
+			next(done, total)
+		}
+		if t.onStep == nil || total <= 0 {
+			return
+		}
+
+		percent := min(done*100/total, 100)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if last, ok := t.percent[step]; ok && percent <= last {
+			return
+		}
+		if t.percent == nil {
+			t.percent = make(map[string]int)
+		}
+		t.percent[step] = percent
+		t.onStep(step, percent)
+	}
+}
+
+// progress records the roads extracted and tiles generated so far
+func (t *jobTracker) progress(ctx context.Context, roadsExtracted, tilesGenerated int) {
+	t.write(ctx, "update job progress", func(ctx context.Context) error {
+		return t.db.UpdateJobProgress(ctx, t.jobID, roadsExtracted, tilesGenerated)
+	})
+}
+
+// fail marks the job failed with errorMsg
+func (t *jobTracker) fail(ctx context.Context, errorMsg string) {
+	t.write(ctx, "update job error", func(ctx context.Context) error {
+		return t.db.UpdateJobError(ctx, t.jobID, errorMsg)
+	})
+}
+
+// complete marks the job completed with its final counts
+func (t *jobTracker) complete(ctx context.Context, roadsExtracted, tilesGenerated int, totalSizeBytes int64) {
+	t.write(ctx, "complete job", func(ctx context.Context) error {
+		return t.db.CompleteJob(ctx, t.jobID, roadsExtracted, tilesGenerated, totalSizeBytes)
+	})
+}
+
+func (t *jobTracker) write(ctx context.Context, op string, fn func(ctx context.Context) error) {
+	if t.db == nil {
+		return
+	}
+
+	var err error
+	if t.lost {
+		err = fn(ctx)
+	} else {
+		err = t.db.withReconnect(ctx, op, func() error { return fn(ctx) })
+	}
+
+	switch {
+	case err == nil && t.lost:
+		t.lost = false
+		t.logger.Info("job tracking restored", "job_id", t.jobID, "operation", op)
+	case err != nil && isDatabaseUnavailable(err):
+		if !t.lost {
+			t.logger.Error("database unavailable, job tracking lost until it recovers",
+				"job_id", t.jobID, "operation", op, "error", err)
+		}
+		t.lost = true
+	case err != nil:
+		t.logger.Warn("failed to record job state", "job_id", t.jobID, "operation", op, "error", err)
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// flakyDB is a database/sql driver that accepts every statement until it goes
+// down, after which connections are refused as if the server had gone away
+type flakyDB struct {
+	mu        sync.Mutex
+	down      bool
+	downAfter int      // Go down once this many statements have run (0 = never)
+	outage    int      // Come back up after refusing this many statements (0 = never)
+	refused   int      // Statements refused in the current outage
+	ran       []string // Statements run, compacted (compactSQL)
+	attempts  int      // Statements tried, including refused ones
+}
+
+func (f *flakyDB) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// statements returns the statements run so far
+func (f *flakyDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.ran...)
+}
+
+func (f *flakyDB) Connect(ctx context.Context) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+	return &flakyConn{db: f}, nil
+}
+
+func (f *flakyDB) Driver() driver.Driver { return nil }
+
+type flakyConn struct{ db *flakyDB }
+
+func (c *flakyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f := c.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.down {
+		f.refused++
+		if f.outage > 0 && f.refused >= f.outage {
+			f.down, f.refused = false, 0
+		}
+		return nil, driver.ErrBadConn
+	}
+	f.ran = append(f.ran, compactSQL(query))
+	if f.downAfter > 0 && len(f.ran) == f.downAfter {
+		f.down = true
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *flakyConn) Ping(ctx context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.db.down {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *flakyConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *flakyConn) Commit() error             { return nil }
+func (c *flakyConn) Rollback() error           { return nil }
+func (c *flakyConn) Close() error              { return nil }
+func (c *flakyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+
+// newFlakyDB returns a Database on a flakyDB that retries with short delays
+func newFlakyDB(t *testing.T) (*flakyDB, *Database) {
+	t.Helper()
+	fake := &flakyDB{}
+	conn := sql.OpenDB(fake)
+	t.Cleanup(func() { conn.Close() })
+	return fake, &Database{conn: conn, reconnect: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+}
+
+func TestIsDatabaseUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"cancelled", fmt.Errorf("failed to update job status: %w", context.Canceled), false},
+		{"bad connection", fmt.Errorf("failed to update job status: %w", driver.ErrBadConn), true},
+		{"connection closed", io.EOF, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"server shutting down", &pq.Error{Code: "57P01"}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"job not found", errors.New("job not found: job-1"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDatabaseUnavailable(tt.err); got != tt.want {
+				t.Errorf("isDatabaseUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithReconnect(t *testing.T) {
+	_, db := newFlakyDB(t)
+	permanent := &pq.Error{Code: "23505"}
+
+	tests := []struct {
+		name      string
+		errs      []error // Returned by successive calls, then nil
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds first time", nil, 1, nil},
+		{"reconnects after an outage", []error{driver.ErrBadConn, io.EOF}, 3, nil},
+		{"gives up at max attempts", []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, 3, driver.ErrBadConn},
+		{"statement errors are not retried", []error{permanent}, 1, permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := db.withReconnect(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestJobTracker_Outage(t *testing.T) {
+	fake, db := newFlakyDB(t)
+	tracker := newJobTracker(db, "job-1", slog.Default())
+	ctx := context.Background()
+
+	tracker.status(ctx, "extracting")
+	if tracker.lost || len(fake.statements()) != 1 {
+		t.Fatalf("expected the status to be recorded, got %v", fake.statements())
+	}
+
+	// While the database is down writes give up and the job carries on
+	fake.setDown(true)
+	tracker.status(ctx, "generating")
+	if !tracker.lost {
+		t.Fatal("expected tracking to be marked lost")
+	}
+	before := fake.attempts
+	tracker.progress(ctx, 10, 0)
+	// A lost tracker tries once rather than waiting out another outage; the
+	// sql package itself retries a bad connection a couple of times
+	if tries := fake.attempts - before; tries > 3 {
+		t.Errorf("expected a single write while lost, the driver saw %d attempts", tries)
+	}
+
+	// Once the database is back, tracking resumes
+	fake.setDown(false)
+	tracker.progress(ctx, 10, 20)
+	if tracker.lost {
+		t.Error("expected tracking to be restored")
+	}
+	if got := fake.statements(); len(got) != 2 || !strings.Contains(got[1], `"roadsExtracted"`) {
+		t.Errorf("expected the progress update after recovery, got %v", got)
+	}
+}
+
+func TestJobTracker_NilDatabase(t *testing.T) {
+	tracker := newJobTracker(nil, "job-1", slog.Default())
+	tracker.status(context.Background(), "extracting")
+	tracker.complete(context.Background(), 1, 2, 3)
+	if tracker.lost {
+		t.Error("expected no tracking without a database")
+	}
+}
+
+func TestProcessJobWithOptions_DatabaseLostMidJob(t *testing.T) {
+	dataDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "outage")
+
+	tests := []struct {
+		name                   string
+		skipInsertion          bool
+		wantErr                string
+		statementsBeforeOutage int // Tracking statements recorded before the outage
+	}{
+		// Status, step and progress writes before generation succeed; the
+		// database then goes away for good
+		{"insertion fails clearly", false, "database unavailable, road geometries not inserted", 6},
+		{"tracking only degrades", true, "", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			fake, db := newFlakyDB(t)
+			fake.downAfter = tt.statementsBeforeOutage
+			svc := NewTileService(db, nil, &Config{Paths: PathsConfig{CurvatureData: dataDir, OutputDir: t.TempDir()}})
+
+			job := &TileJob{ID: "job-1", Region: "outage"}
+			_, err := svc.ProcessJobWithOptions(context.Background(), job, &JobOptions{
+				MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo,
+				SkipUpload: true, SkipMerge: true,
+				ExtractGeometry: true, SkipGeometryInsertion: tt.skipInsertion,
+			})
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected the job to finish without tracking, got %v", err)
+				}
+			} else {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected %q, got %v", tt.wantErr, err)
+				}
+				// The error points at the file the roads are still saved in
+				file := NewGeometryExtractor().getExtractionFile("outage")
+				if !strings.Contains(err.Error(), file) {
+					t.Errorf("expected the error to name %s, got %v", file, err)
+				}
+				if _, statErr := os.Stat(file); statErr != nil {
+					t.Errorf("expected the extraction file to be kept: %v", statErr)
+				}
+			}
+			if got := fake.statements(); len(got) != tt.statementsBeforeOutage {
+				t.Errorf("expected %d statements before the outage, got %v", tt.statementsBeforeOutage, got)
+			}
+		})
+	}
+}
+
+func TestUpsertRoadGeometriesWithReconnect_ResumesAfterCommit(t *testing.T) {
+	prev := rowsPerTransaction
+	rowsPerTransaction = 10
+	t.Cleanup(func() { rowsPerTransaction = prev })
+
+	// The connection drops after the first statement of the second
+	// transaction and is back for the retry
+	fake, db := newFlakyDB(t)
+	fake.downAfter, fake.outage = 3, 1
+	roads := make([]RoadGeometry, 25)
+	for i := range roads {
+		roads[i] = RoadGeometry{RoadID: fmt.Sprintf("road-%02d", i), Region: "outage"}
+	}
+
+	inserted, err := db.upsertRoadGeometriesWithReconnect(context.Background(), roads, RoadKeyRegional, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 25 {
+		t.Errorf("expected all 25 roads inserted, got %d", inserted)
+	}
+	// 3 batches before the outage, then the 3 after the committed 10 roads
+	if got := fake.statements(); len(got) != 6 {
+		t.Errorf("expected the retry to resume after the first commit, ran %d batches", len(got))
+	}
+}
+
+func TestJobTracker_StepProgress(t *testing.T) {
+	var got []string
+	tracker := newJobTracker(nil, "job-1", slog.Default())
+	tracker.onStep = func(step string, percent int) { got = append(got, fmt.Sprintf("%s %d", step, percent)) }
+
+	tracker.status(context.Background(), "uploading")
+	var forwarded int
+	progress := tracker.stepProgress("uploading", func(done, total int) { forwarded++ })
+	for _, done := range []int{0, 1, 2, 100, 50, 199, 200} {
+		progress(done, 200)
+	}
+	// A new status starts its steps over
+	tracker.status(context.Background(), "merging")
+	progress(10, 200)
+
+	want := []string{"uploading 0", "uploading 1", "uploading 50", "uploading 99", "uploading 100", "merging 0", "uploading 5"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if forwarded != 8 {
+		t.Errorf("expected every update forwarded to the next ProgressFunc, got %d", forwarded)
+	}
+}
+
+func TestProcessJobWithOptions_StepProgress(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dataDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "steps")
+	fake, client := newFakeS3(t)
+
+	svc := NewTileService(nil, client, &Config{
+		S3:    S3Config{BucketPath: "tiles"},
+		Paths: PathsConfig{CurvatureData: dataDir, OutputDir: t.TempDir()},
+	})
+	var mu sync.Mutex
+	var statuses []string
+	percents := map[string][]int{}
+	svc.StepProgress = func(step string, percent int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if percent == 0 {
+			statuses = append(statuses, step)
+		}
+		percents[step] = append(percents[step], percent)
+	}
+
+	_, err := svc.ProcessJobWithOptions(context.Background(), &TileJob{ID: "job-1", Region: "steps"}, &JobOptions{
+		MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo,
+		ExtractGeometry: true, SkipGeometryInsertion: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.puts) == 0 {
+		t.Fatal("expected tiles to be uploaded")
+	}
+
+	// Phase transitions keep their status strings
+	if want := []string{"extracting", "generating", "merging", "uploading/extracting"}; fmt.Sprint(statuses[:4]) != fmt.Sprint(want) {
+		t.Errorf("expected phase transitions %v, got %v", want, statuses)
+	}
+
+	// Extraction and upload each climb to 100
+	for _, step := range []string{"extracting", "uploading"} {
+		p := percents[step]
+		if len(p) < 2 || p[len(p)-1] != 100 {
+			t.Errorf("%s: expected progress ending at 100, got %v", step, p)
+			continue
+		}
+		for i := 1; i < len(p); i++ {
+			// "extracting" is also the first phase's status, at 0
+			if p[i] < p[i-1] && !(step == "extracting" && p[i] == 0) {
+				t.Errorf("%s: expected progress to climb, got %v", step, p)
+				break
+			}
+		}
+	}
+}
+
+func TestProcessJobWithOptions_Result(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dataDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "result")
+	_, client := newFakeS3(t)
+
+	svc := NewTileService(nil, client, &Config{
+		S3:    S3Config{BucketPath: "tiles"},
+		Paths: PathsConfig{CurvatureData: dataDir, OutputDir: outputDir},
+	})
+	result, err := svc.ProcessJobWithOptions(context.Background(), &TileJob{ID: "job-1", Region: "result"}, &JobOptions{
+		MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo, NoCleanup: true,
+		ExtractGeometry: true, SkipGeometryInsertion: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Region != "result" || result.TilesDir != filepath.Join(outputDir, "result") || result.MergedDir != filepath.Join(outputDir, "merged") {
+		t.Errorf("unexpected region or paths: %+v", result)
+	}
+	tiles, err := countTiles(result.TilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TilesCount == 0 || result.TilesCount != tiles {
+		t.Errorf("expected %d tiles, got %d", tiles, result.TilesCount)
+	}
+	if result.TotalSizeBytes <= 0 || result.UploadedBytes <= 0 {
+		t.Errorf("expected tile and upload sizes, got %d and %d", result.TotalSizeBytes, result.UploadedBytes)
+	}
+	if result.RoadsCount == 0 || result.RoadsExtracted == 0 {
+		t.Errorf("expected converted and extracted roads, got %d and %d", result.RoadsCount, result.RoadsExtracted)
+	}
+	if result.Report == nil || !result.Report.OK {
+		t.Errorf("expected a passing generation report, got %+v", result.Report)
+	}
+	if result.Extraction == nil || result.Extraction.RoadsExtracted != result.RoadsExtracted {
+		t.Errorf("expected the extraction summary to match %d roads, got %+v", result.RoadsExtracted, result.Extraction)
+	}
+	if _, err := os.Stat(result.GeoJSONPath); err != nil {
+		t.Errorf("expected the kept GeoJSON at %q: %v", result.GeoJSONPath, err)
+	}
+}
+
+func TestProcessJobWithOptions_CurrentStep(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dataDir := t.TempDir()
+	writeTestKMZ(t, dataDir, "phases")
+	fake, db := newFlakyDB(t)
+
+	svc := NewTileService(db, nil, &Config{Paths: PathsConfig{CurvatureData: dataDir, OutputDir: t.TempDir()}})
+	var steps []string
+	svc.StepChange = func(step string) { steps = append(steps, step) }
+
+	_, err := svc.ProcessJobWithOptions(context.Background(), &TileJob{ID: "job-1", Region: "phases"}, &JobOptions{
+		MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo,
+		SkipUpload: true, SkipMerge: true,
+		ExtractGeometry: true, SkipGeometryInsertion: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"extracting", "converting", "generating", "extracting"}
+	if fmt.Sprint(steps) != fmt.Sprint(want) {
+		t.Errorf("expected steps %v, got %v", want, steps)
+	}
+	var written int
+	for _, stmt := range fake.statements() {
+		if strings.Contains(stmt, `SET "currentStep" = $1`) {
+			written++
+		}
+	}
+	if written != len(want) {
+		t.Errorf("expected %d currentStep writes, got %d in %v", len(want), written, fake.statements())
+	}
+}
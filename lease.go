@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// jobLeaser is the subset of Database used to keep and expire job leases
+type jobLeaser interface {
+	HeartbeatJob(ctx context.Context, jobID string) error
+	RequeueStaleJobs(ctx context.Context, timeout time.Duration) ([]string, error)
+}
+
+// startHeartbeat refreshes jobID's lease every interval until the returned
+// stop function is called or ctx is done. Heartbeat failures are logged and
+// retried on the next tick.
+func startHeartbeat(ctx context.Context, leaser jobLeaser, jobID string, interval time.Duration) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := leaser.HeartbeatJob(ctx, jobID); err != nil && ctx.Err() == nil {
+					slog.Warn("job heartbeat failed", "job_id", jobID, "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// reapStaleJobs re-queues jobs whose heartbeat is older than timeout
+func reapStaleJobs(ctx context.Context, leaser jobLeaser, timeout time.Duration) []string {
+	ids, err := leaser.RequeueStaleJobs(ctx, timeout)
+	if err != nil {
+		slog.Error("failed to reap stale jobs", "error", err)
+		return nil
+	}
+	for _, id := range ids {
+		slog.Warn("re-queued job with stale heartbeat", "job_id", id, "lease_timeout", timeout)
+	}
+	return ids
+}
+
+// runReaper calls reapStaleJobs every interval until ctx is done
+func runReaper(ctx context.Context, leaser jobLeaser, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reapStaleJobs(ctx, leaser, timeout)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingConnector is a database/sql connector that records each query
+// and answers it with ids as a single "id" column, or with err
+type recordingConnector struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]driver.NamedValue
+	ids     []string
+	err     error
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver { return nil }
+
+type recordingConn struct{ c *recordingConnector }
+
+func (recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (recordingConn) Close() error                        { return nil }
+func (recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (r recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	r.c.queries = append(r.c.queries, query)
+	r.c.args = append(r.c.args, args)
+	if r.c.err != nil {
+		return nil, r.c.err
+	}
+	return &idRows{ids: append([]string(nil), r.c.ids...)}, nil
+}
+
+type idRows struct{ ids []string }
+
+func (*idRows) Columns() []string { return []string{"id"} }
+func (*idRows) Close() error      { return nil }
+func (r *idRows) Next(dest []driver.Value) error {
+	if len(r.ids) == 0 {
+		return io.EOF
+	}
+	dest[0], r.ids = r.ids[0], r.ids[1:]
+	return nil
+}
+
+// countingLeaser counts heartbeats
+type countingLeaser struct {
+	mu         sync.Mutex
+	heartbeats int
+}
+
+func (l *countingLeaser) HeartbeatJob(ctx context.Context, jobID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.heartbeats++
+	return nil
+}
+
+func (l *countingLeaser) RequeueStaleJobs(ctx context.Context, timeout time.Duration) ([]string, error) {
+	return nil, nil
+}
+
+func (l *countingLeaser) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.heartbeats
+}
+
+func TestRequeueStaleJobsQuery(t *testing.T) {
+	query, args := requeueStaleJobsQuery(5 * time.Minute)
+	for _, want := range []string{
+		"SET status = 'pending'",
+		`"lastHeartbeatAt" = NULL`,
+		"status IN (" + activeJobStatuses + ")",
+		`COALESCE("lastHeartbeatAt", "startedAt", "updatedAt")`,
+		"make_interval(secs => $1)",
+		"RETURNING id",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("expected requeue query to contain %q, got %s", want, query)
+		}
+	}
+	if len(args) != 1 || args[0] != 300.0 {
+		t.Errorf("expected timeout in seconds, got %v", args)
+	}
+}
+
+func TestReapStaleJobs(t *testing.T) {
+	wantQuery, _ := requeueStaleJobsQuery(5 * time.Minute)
+
+	tests := []struct {
+		name    string
+		ids     []string
+		err     error
+		wantIDs []string
+	}{
+		{"stale jobs", []string{"stale", "no-heartbeat"}, nil, []string{"stale", "no-heartbeat"}},
+		{"nothing stale", nil, nil, nil},
+		{"query fails", nil, errors.New("connection refused"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingConnector{ids: tt.ids, err: tt.err}
+			conn := sql.OpenDB(rec)
+			defer conn.Close()
+
+			ids := reapStaleJobs(context.Background(), &Database{conn: conn}, 5*time.Minute)
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("expected re-queued %v, got %v", tt.wantIDs, ids)
+			}
+			if len(rec.queries) != 1 || rec.queries[0] != wantQuery {
+				t.Fatalf("expected the requeue query once, got %q", rec.queries)
+			}
+			if args := rec.args[0]; len(args) != 1 || args[0].Value != 300.0 {
+				t.Errorf("expected the timeout in seconds, got %v", args)
+			}
+		})
+	}
+}
+
+// sqliteRequeuer re-queues every active job. It stands in for
+// RequeueStaleJobs, whose lease arithmetic SQLite cannot run.
+type sqliteRequeuer struct{ *Database }
+
+func (r sqliteRequeuer) RequeueStaleJobs(ctx context.Context, timeout time.Duration) ([]string, error) {
+	rows, err := r.conn.QueryContext(ctx, `
+		UPDATE "TileJob"
+		SET status = 'pending', "claimedBy" = NULL, "lastHeartbeatAt" = NULL
+		WHERE status IN (`+activeJobStatuses+`)
+		RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func TestReapStaleJobs_ReapedJobRunsAgain(t *testing.T) {
+	prev := jobPollInterval
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = prev })
+
+	// A worker claimed the job and died
+	db := newJobsTestDB(t)
+	insertTestJob(t, db, "orphan", "pending", time.Now())
+	if job, err := db.ClaimPendingJob(context.Background(), "dead-worker"); err != nil || job == nil {
+		t.Fatalf("expected to claim the job, got %+v, %v", job, err)
+	}
+
+	s := NewAPIServer(db, nil, &Config{})
+	ran := recordJobRuns(s)
+	s.processing.Add(1)
+	go s.processJobs()
+	defer s.Stop(context.Background())
+
+	select {
+	case job := <-ran:
+		t.Fatalf("expected the leased job to be left alone, got %s run", job.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if ids := reapStaleJobs(context.Background(), sqliteRequeuer{db}, time.Minute); len(ids) != 1 || ids[0] != "orphan" {
+		t.Fatalf("expected the orphan to be re-queued, got %v", ids)
+	}
+	if job := waitForJobRun(t, ran); job.ID != "orphan" {
+		t.Fatalf("expected the orphan to run again, got %s", job.ID)
+	}
+
+	var claimedBy string
+	if err := db.conn.QueryRow(`SELECT "claimedBy" FROM "TileJob" WHERE id = 'orphan'`).Scan(&claimedBy); err != nil {
+		t.Fatal(err)
+	}
+	if claimedBy != s.workerID {
+		t.Errorf("expected the orphan reclaimed by %s, got %q", s.workerID, claimedBy)
+	}
+	s.jobsMutex.RLock()
+	_, tracked := s.activeJobs["orphan"]
+	s.jobsMutex.RUnlock()
+	if !tracked {
+		t.Error("expected the reclaimed job to be tracked for polling and cancellation")
+	}
+}
+
+func TestHeartbeatJob(t *testing.T) {
+	db := newJobsTestDB(t)
+	insertTestJob(t, db, "job-1", "generating", time.Now())
+	insertTestJob(t, db, "job-2", "generating", time.Now())
+
+	if err := db.HeartbeatJob(context.Background(), "job-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.conn.Query(`SELECT id FROM "TileJob" WHERE "lastHeartbeatAt" IS NOT NULL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var beaten []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		beaten = append(beaten, id)
+	}
+	if len(beaten) != 1 || beaten[0] != "job-1" {
+		t.Errorf("expected only job-1 to have a heartbeat, got %v", beaten)
+	}
+}
+
+func TestStartHeartbeat_BeatsUntilStopped(t *testing.T) {
+	leaser := &countingLeaser{}
+
+	stop := startHeartbeat(context.Background(), leaser, "job-1", 5*time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for leaser.count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least 3 heartbeats, got %d", leaser.count())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+
+	after := leaser.count()
+	time.Sleep(20 * time.Millisecond)
+	if beats := leaser.count(); beats != after {
+		t.Errorf("expected no heartbeats after stop, got %d more", beats-after)
+	}
+}
@@ -2,15 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -18,6 +20,8 @@ func main() {
 	configPath := flag.String("config", ".env", "Path to config file")
 	debug := flag.Bool("debug", false, "Enable debug logging")
 	help := flag.Bool("help", false, "Show help message")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile to this file")
+	memProfile := flag.String("memprofile", "", "Write a heap profile to this file when the command finishes")
 	flag.Parse()
 
 	// Show help if requested or no arguments provided
@@ -30,14 +34,16 @@ func main() {
 	command := args[0]
 
 	// Setup logging
-	logLevel := slog.LevelInfo
-	if *debug {
-		logLevel = slog.LevelDebug
+	slog.SetDefault(newLogger(os.Stdout, *debug))
+
+	// Start profiling if requested. Profiles are finalized when the command
+	// returns, or when it fails through exit.
+	stopProfiling, err := startProfiling(*cpuProfile, *memProfile)
+	if err != nil {
+		slog.Error("failed to start profiling", "error", err)
+		os.Exit(1)
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	}))
-	slog.SetDefault(logger)
+	stopProfiles = stopProfiling
 
 	// Handle different commands
 	if command == "generate" {
@@ -46,19 +52,52 @@ func main() {
 		cmdUpload(args[1:], configPath, debug)
 	} else if command == "extract" {
 		cmdExtract(args[1:], configPath, debug)
+	} else if command == "convert" {
+		cmdConvert(args[1:], configPath, debug)
 	} else if command == "insert-geometries" {
 		cmdInsertGeometries(args[1:], configPath, debug)
+	} else if command == "qa-geometries" {
+		cmdQAGeometries(args[1:], configPath, debug)
+	} else if command == "export-geojson" {
+		cmdExportGeoJSON(args[1:], configPath, debug)
+	} else if command == "reconcile" {
+		cmdReconcile(args[1:], configPath, debug)
+	} else if command == "inspect-tile" {
+		cmdInspectTile(args[1:], configPath, debug)
 	} else if command == "merge" {
 		cmdMerge(args[1:], configPath, debug)
+	} else if command == "download" {
+		cmdDownload(args[1:], configPath, debug)
+	} else if command == "prune" {
+		cmdPrune(args[1:], configPath, debug)
+	} else if command == "repair-zooms" {
+		cmdRepairZooms(args[1:], configPath, debug)
 	} else if command == "serve" {
 		cmdServe(args[1:], configPath, debug)
 	} else if command == "verify" {
 		cmdVerify(args[1:], configPath, debug)
+	} else if command == "report" {
+		cmdReport(args[1:], configPath, debug)
+	} else if command == "stats" {
+		cmdStats(args[1:], configPath, debug)
 	} else {
 		slog.Error("unknown command", "command", command)
 		showHelp()
-		os.Exit(1)
+		exit(1)
+	}
+
+	stopProfiling()
+}
+
+// newLogger creates the CLI's text logger writing to w
+func newLogger(w io.Writer, debug bool) *slog.Logger {
+	logLevel := slog.LevelInfo
+	if debug {
+		logLevel = slog.LevelDebug
 	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: logLevel,
+	}))
 }
 
 // cmdGenerate handles tile generation for one or more regions
@@ -72,20 +111,133 @@ func cmdGenerate(args []string, configPath *string, debug *bool) {
 	extractGeometry := fs.Bool("extract-geometry", true, "Extract road geometries into database")
 	skipGeometryInsertion := fs.Bool("skip-geometry-insertion", false, "Extract geometries to file but don't insert to database")
 	mergeAll := fs.Bool("merge-all", false, "Merge all regions instead of just overlapping neighbors")
-	workers := fs.Int("workers", 1, "Number of parallel workers for multi-region generation")
+	workers := workersFlag(1)
+	fs.Var(&workers, "workers", "Number of parallel workers for multi-region generation")
+	extractWorkers := new(workersFlag)
+	fs.Var(extractWorkers, "extract-workers", "Tiles decoded at once during geometry extraction (default EXTRACT_WORKERS, or one per CPU)")
+	keepGoing := fs.Bool("keep-going", true, "Continue with remaining regions when one fails (-keep-going=false stops at the first failure)")
+	skipEmpty := fs.Bool("skip-empty", false, "Skip uploading near-empty tiles smaller than -skip-empty-bytes")
+	skipEmptyBytes := fs.Int64("skip-empty-bytes", 50, "Size threshold in bytes for -skip-empty")
+	skipEmptyStrict := fs.Bool("skip-empty-strict", false, "With -skip-empty, only skip tiles that decode to zero features")
+	encoder := fs.String("encoder", EncoderTippecanoe, "Tile encoder: tippecanoe, or go for a pure-Go fallback (small datasets)")
+	clipPath := fs.String("clip", "", "Clip roads to the polygon(s) in this GeoJSON file before tiling")
+	preserveAltitude := fs.Bool("preserve-altitude", false, "Keep KML altitude as a third GeoJSON coordinate")
+	maxTileFeatures := fs.Int("max-tile-features", 0, "Tippecanoe --maximum-tile-features; densest features are dropped past it (0 = Tippecanoe default)")
+	maxTileBytes := fs.Int("max-tile-bytes", 0, "Tippecanoe --maximum-tile-bytes; densest features are dropped past it (0 = Tippecanoe default)")
+	idAttribute := fs.String("id-attribute", "", "Property to use as the tile feature ID (Tippecanoe --use-attribute-for-id)")
+	atomicSwap := fs.Bool("atomic-swap", false, "Generate into a temp directory and swap it in on success, keeping old tiles servable meanwhile")
+	statsOnly := fs.Bool("stats-only", false, "Generate tiles to a temp dir, print their size and zoom stats, then delete them (no upload or DB)")
+	fromJob := fs.String("from-job", "", "Replay a job definition (.job-{id}.json) written by a previous run")
+	dedupGlobal := fs.Bool("dedup-global", false, "Key roads by name and rounded start and end points so overlapping regions share one row (default EXTRACT_DEDUP_GLOBAL)")
 	fs.Parse(args)
 
 	regions := fs.Args()
+	var replay *JobDefinition
+	if *fromJob != "" {
+		if len(regions) > 0 {
+			slog.Error("-from-job cannot be combined with region arguments")
+			exit(1)
+		}
+		var err error
+		replay, err = ReadJobDefinition(*fromJob)
+		if err != nil {
+			slog.Error("failed to load job definition", "path", *fromJob, "error", err)
+			exit(1)
+		}
+		regions = []string{replay.Region}
+	}
 	if len(regions) == 0 {
 		slog.Error("at least one region required")
-		os.Exit(1)
+		exit(1)
+	}
+	for i, region := range regions {
+		normalized, err := normalizeRegion(region)
+		if err != nil {
+			slog.Error("invalid region", "region", region, "error", err)
+			exit(1)
+		}
+		regions[i] = normalized
+	}
+
+	if *encoder != EncoderTippecanoe && *encoder != EncoderGo {
+		slog.Error("invalid encoder", "encoder", *encoder, "valid", []string{EncoderTippecanoe, EncoderGo})
+		exit(1)
+	}
+
+	if *maxTileFeatures < 0 || *maxTileBytes < 0 {
+		slog.Error("-max-tile-features and -max-tile-bytes must be positive", "max_tile_features", *maxTileFeatures, "max_tile_bytes", *maxTileBytes)
+		exit(1)
+	}
+
+	clip, err := loadClipBoundary(*clipPath)
+	if err != nil {
+		slog.Error("failed to load clip boundary", "path", *clipPath, "error", err)
+		exit(1)
 	}
 
 	// Load configuration
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+	cfg.Service.ExtractWorkers = extractWorkers.Or(cfg.Service.ExtractWorkers)
+	applyDedupGlobalFlag(fs, *dedupGlobal, cfg)
+	if replay != nil {
+		if err := replay.RestoreEnv(cfg); err != nil {
+			slog.Error("failed to restore the job definition's Tippecanoe settings", "path", *fromJob, "error", err)
+			exit(1)
+		}
+	}
+
+	// Build job options (shared across all regions)
+	opts := &JobOptions{
+		MaxZoom:               *maxZoom,
+		MinZoom:               *minZoom,
+		SkipUpload:            *skipUpload,
+		SkipMerge:             *skipMerge,
+		NoCleanup:             *noCleanup,
+		ExtractGeometry:       *extractGeometry,
+		SkipGeometryInsertion: *skipGeometryInsertion,
+		MergeAll:              *mergeAll,
+		Encoder:               *encoder,
+		Clip:                  clip,
+		PreserveAltitude:      *preserveAltitude,
+		MaxTileFeatures:       *maxTileFeatures,
+		MaxTileBytes:          *maxTileBytes,
+		IDAttribute:           *idAttribute,
+		AtomicSwap:            *atomicSwap,
+	}
+	if replay != nil {
+		// The definition holds the fully resolved options; flags given
+		// explicitly override them
+		replayed := replay.ReplayOptions(fs, opts)
+		opts = &replayed
+		slog.Info("replaying job definition", "path", *fromJob, "job_id", replay.JobID, "created_at", replay.CreatedAt)
+	}
+
+	// Fail before any work if the configured Tippecanoe cannot be run
+	if opts.Encoder != EncoderGo {
+		if err := CheckTippecanoe(cfg.Paths.TippecanoeBin); err != nil {
+			slog.Error("tippecanoe not available (set TIPPECANOE_BIN or use -encoder go)", "error", err)
+			exit(1)
+		}
+	}
+
+	if *statsOnly {
+		// Measure only: no database, no upload, tiles are deleted afterwards
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		service := NewTileService(nil, nil, cfg)
+		for _, region := range regions {
+			stats, err := service.GenerateStats(ctx, region, opts)
+			if err != nil {
+				slog.Error("stats generation failed", "region", region, "error", err)
+				exit(1)
+			}
+			stats.Print()
+		}
+		return
 	}
 
 	// Initialize database connection (optional)
@@ -101,7 +253,12 @@ func cmdGenerate(args []string, configPath *string, debug *bool) {
 	s3Client, err := NewS3Client(cfg.S3)
 	if err != nil {
 		slog.Error("failed to initialize S3 client", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	if *skipEmpty {
+		s3Client.SkipEmptyBelow = *skipEmptyBytes
+		s3Client.SkipEmptyStrict = *skipEmptyStrict
 	}
 
 	// Create service
@@ -114,136 +271,70 @@ func cmdGenerate(args []string, configPath *string, debug *bool) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Build job options (shared across all regions)
-	opts := &JobOptions{
-		MaxZoom:               *maxZoom,
-		MinZoom:               *minZoom,
-		SkipUpload:            *skipUpload,
-		SkipMerge:             *skipMerge,
-		NoCleanup:             *noCleanup,
-		ExtractGeometry:       *extractGeometry,
-		SkipGeometryInsertion: *skipGeometryInsertion,
-		MergeAll:              *mergeAll,
-	}
-
 	// Single region - simple path
 	if len(regions) == 1 {
 		region := regions[0]
-		slog.Info("starting tile generation", "region", region, "max_zoom", *maxZoom, "min_zoom", *minZoom, "skip_upload", *skipUpload)
+		slog.Info("starting tile generation", "region", region, "max_zoom", opts.MaxZoom, "min_zoom", opts.MinZoom, "skip_upload", opts.SkipUpload)
 
+		var result *JobResult
 		done := make(chan error, 1)
 		go func() {
 			job := &TileJob{
-				ID:     "1",
+				ID:     newCLIJobID("cli"),
 				Region: region,
 				Status: "pending",
 			}
-			done <- service.ProcessJobWithOptions(ctx, job, opts)
+			var err error
+			result, err = service.ProcessJobWithOptions(ctx, job, opts)
+			done <- err
 		}()
 
 		select {
 		case err := <-done:
 			if err != nil {
 				slog.Error("tile generation failed", "error", err)
-				os.Exit(1)
+				exit(1)
 			}
+			result.Print()
 			slog.Info("tile generation completed successfully")
 		case sig := <-sigChan:
 			slog.Info("received shutdown signal", "signal", sig)
 			cancel()
-			<-done
-			os.Exit(1)
+			if err := <-done; errors.Is(err, context.Canceled) {
+				slog.Warn("tile generation cancelled", "region", region)
+			}
+			exit(1)
 		}
 		return
 	}
 
 	// Multiple regions - parallel processing with worker pool
-	numWorkers := *workers
-	if numWorkers < 1 {
-		numWorkers = 1
-	}
-	if numWorkers > len(regions) {
-		numWorkers = len(regions)
-	}
-
 	slog.Info("starting batch tile generation",
 		"regions", len(regions),
-		"workers", numWorkers,
+		"workers", workers,
+		"keep_going", *keepGoing,
 		"skip_upload", *skipUpload,
 		"skip_merge", *skipMerge,
 	)
 
-	// Create work channel and results tracking
-	workChan := make(chan string, len(regions))
-	for _, region := range regions {
-		workChan <- region
-	}
-	close(workChan)
-
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
-	var succeeded []string
-
-	// Start workers
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for region := range workChan {
-				// Check if context cancelled
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-
-				logger := slog.With("worker", workerID, "region", region)
-				logger.Info("starting region")
-
-				job := &TileJob{
-					ID:     fmt.Sprintf("batch-%d-%s", workerID, region),
-					Region: region,
-					Status: "pending",
-				}
-
-				err := service.ProcessJobWithOptions(ctx, job, opts)
-
-				mu.Lock()
-				if err != nil {
-					logger.Error("region failed", "error", err)
-					failed = append(failed, region)
-				} else {
-					logger.Info("region completed")
-					succeeded = append(succeeded, region)
-				}
-				mu.Unlock()
-			}
-		}(i)
-	}
-
-	// Wait for completion or signal
-	done := make(chan struct{})
+	done := make(chan *BatchResult, 1)
 	go func() {
-		wg.Wait()
-		close(done)
+		done <- generateRegions(ctx, service, regions, opts, int(workers), *keepGoing)
 	}()
 
+	// Wait for completion or signal
+	var result *BatchResult
 	select {
-	case <-done:
-		slog.Info("batch generation completed",
-			"succeeded", len(succeeded),
-			"failed", len(failed),
-		)
-		if len(failed) > 0 {
-			slog.Error("failed regions", "regions", failed)
-			os.Exit(1)
-		}
+	case result = <-done:
 	case sig := <-sigChan:
 		slog.Info("received shutdown signal", "signal", sig)
 		cancel()
-		<-done
-		os.Exit(1)
+		result = <-done
+	}
+
+	result.LogSummary("batch generation")
+	if !result.OK() {
+		exit(1)
 	}
 }
 
@@ -252,20 +343,42 @@ func cmdUpload(args []string, configPath *string, debug *bool) {
 	fs := flag.NewFlagSet("upload", flag.ExitOnError)
 	maxZoom := fs.Int("max-zoom", -1, "Maximum zoom level to upload (-1 = all)")
 	minZoom := fs.Int("min-zoom", -1, "Minimum zoom level to upload (-1 = all)")
+	manifest := fs.Bool("manifest", false, "Also upload a manifest of every file with its size and SHA-256")
+	dedup := fs.Bool("dedup", false, "Upload identical files once and create the rest with server-side copies")
+	skipEmpty := fs.Bool("skip-empty", false, "Skip near-empty tiles smaller than -skip-empty-bytes")
+	skipEmptyBytes := fs.Int64("skip-empty-bytes", 50, "Size threshold in bytes for -skip-empty")
+	skipEmptyStrict := fs.Bool("skip-empty-strict", false, "With -skip-empty, only skip tiles that decode to zero features")
+	syncMode := fs.Bool("sync", false, "Upload only files changed since the last manifest and delete files removed locally")
+	syncVerify := fs.Bool("verify", false, "With -sync, list R2 to re-upload files the last manifest lists but R2 is missing")
+	workers := addWorkersFlag(fs, "Number of files to upload at once (default S3_UPLOAD_CONCURRENCY, or 8)", "concurrency")
 	fs.Parse(args)
 
 	parsedArgs := fs.Args()
 	if len(parsedArgs) == 0 {
 		slog.Error("tiles directory required")
-		os.Exit(1)
+		exit(1)
 	}
 	tilesDir := parsedArgs[0]
 
+	if IsIncompleteTileDir(tilesDir) {
+		slog.Error("tiles directory is from an interrupted generation run, regenerate before uploading", "tiles_dir", tilesDir)
+		exit(1)
+	}
+
+	if *syncMode && (*minZoom != -1 || *maxZoom != -1 || *dedup || *skipEmpty) {
+		slog.Error("-sync cannot be combined with -min-zoom, -max-zoom, -dedup or -skip-empty")
+		exit(1)
+	}
+	if *syncVerify && !*syncMode {
+		slog.Error("-verify requires -sync")
+		exit(1)
+	}
+
 	// Load configuration
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	slog.Info("starting tile upload", "tiles_dir", tilesDir, "min_zoom", *minZoom, "max_zoom", *maxZoom)
@@ -274,7 +387,20 @@ func cmdUpload(args []string, configPath *string, debug *bool) {
 	s3Client, err := NewS3Client(cfg.S3)
 	if err != nil {
 		slog.Error("failed to initialize S3 client", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	s3Client.Dedup = *dedup
+	s3Client.UploadConcurrency = workers.Or(s3Client.UploadConcurrency)
+	if *skipEmpty {
+		s3Client.SkipEmptyBelow = *skipEmptyBytes
+		s3Client.SkipEmptyStrict = *skipEmptyStrict
+	}
+
+	// On a terminal, show a progress bar instead of periodic progress logs
+	bar := newCLIProgress("upload", *debug)
+	if bar != nil {
+		s3Client.Progress = bar.Update
 	}
 
 	// Create service
@@ -292,13 +418,35 @@ func cmdUpload(args []string, configPath *string, debug *bool) {
 	go func() {
 		// Extract region from directory name (e.g., "~/data/df/tiles/oregon" -> "oregon")
 		region := filepath.Base(tilesDir)
-		uploadedBytes, err := service.UploadToR2WithZoomFilter(ctx, tilesDir, region, *minZoom, *maxZoom)
+		if *syncMode {
+			summary, err := service.SyncToR2(ctx, tilesDir, region, SyncOptions{Verify: *syncVerify})
+			if bar != nil {
+				bar.Finish()
+			}
+			if summary != nil {
+				summary.Print()
+			}
+			done <- err
+			return
+		}
+
+		summary, err := service.UploadToR2WithZoomFilter(ctx, tilesDir, region, *minZoom, *maxZoom)
+		if bar != nil {
+			bar.Finish()
+		}
 		if err != nil {
 			done <- err
-		} else {
-			slog.Info("upload completed successfully", "uploaded_bytes", uploadedBytes)
-			done <- nil
+			return
 		}
+		if *manifest {
+			if err := service.UploadManifest(ctx, tilesDir, region); err != nil {
+				done <- err
+				return
+			}
+		}
+		summary.Print()
+		slog.Info("upload completed successfully", "uploaded_bytes", summary.TotalBytes)
+		done <- nil
 	}()
 
 	// Wait for completion or signal
@@ -306,50 +454,127 @@ func cmdUpload(args []string, configPath *string, debug *bool) {
 	case err := <-done:
 		if err != nil {
 			slog.Error("upload failed", "error", err)
-			os.Exit(1)
+			exit(1)
 		}
 	case sig := <-sigChan:
 		slog.Info("received shutdown signal", "signal", sig)
 		cancel()
 		<-done
-		os.Exit(1)
+		exit(1)
 	}
 }
 
 // cmdExtract handles extracting road geometries from existing tiles
 func cmdExtract(args []string, configPath *string, debug *bool) {
 	fs := flag.NewFlagSet("extract", flag.ExitOnError)
-	fs.Parse(args)
+	mbtiles := fs.String("mbtiles", "", "Read tiles from an .mbtiles file instead of a tiles directory")
+	scheme := fs.String("scheme", "", "Row convention of the tiles directory: xyz or tms (default TILE_SCHEME or xyz)")
+	jsonOut := fs.Bool("json", false, "Print the extraction summary as JSON")
+	maxInvalidRatio := fs.Float64("max-invalid-ratio", DefaultMaxInvalidRatio, "Exit non-zero when more than this share of roads is invalid (0 = off)")
+	workers := addWorkersFlag(fs, "Tiles decoded at once (default EXTRACT_WORKERS, or one per CPU)")
+	geoJSONPath := fs.String("geojson", "", "Also write the extracted bounding boxes to this file as GeoJSON")
+	maxTiles := fs.Int("max-tiles", 0, "Only extract from the first N tiles in z/x/y order, for debugging (0 = all)")
+	tileFlag := fs.String("tile", "", "Only extract from this tile (z/x/y), for debugging one road")
+	subtree := fs.String("subtree", "", "Only extract from the tiles of this z/x column, for debugging one road")
+	metadataCheck := fs.String("metadata-check", "", "On a tileset metadata mismatch: error, warn or off (default EXTRACT_METADATA_CHECK or error)")
+	dedupGlobal := fs.Bool("dedup-global", false, "Key roads by name and rounded start and end points so overlapping regions share one row (default EXTRACT_DEDUP_GLOBAL)")
+	fs.Parse(reorderFlagsFirst(args))
+
+	if *tileFlag != "" && *subtree != "" {
+		slog.Error("-tile and -subtree cannot be used together")
+		exit(1)
+	}
+	var scope *TileScope
+	if value := *tileFlag + *subtree; value != "" {
+		var err error
+		if scope, err = ParseTileScope(value); err != nil {
+			slog.Error("invalid tile scope", "error", err)
+			exit(1)
+		}
+		if scope.Column != (*subtree != "") {
+			slog.Error("-tile takes z/x/y and -subtree takes z/x", "value", value)
+			exit(1)
+		}
+	}
 
 	parsedArgs := fs.Args()
-	if len(parsedArgs) == 0 {
+	tilesDir := ""
+	if len(parsedArgs) > 0 {
+		tilesDir = parsedArgs[0]
+	}
+	if tilesDir == "" && *mbtiles == "" {
 		slog.Error("tiles directory required")
-		os.Exit(1)
+		slog.Info("Usage: tile-service extract <tiles_dir | file.mbtiles> | extract -mbtiles <file>")
+		exit(1)
+	}
+
+	// A positional .mbtiles path is the same as -mbtiles
+	if *mbtiles == "" && IsMBTilesPath(tilesDir) {
+		*mbtiles, tilesDir = tilesDir, ""
 	}
-	tilesDir := parsedArgs[0]
 
 	// Extract region from directory path (e.g., "~/data/df/tiles/oregon" -> "oregon")
+	// or the mbtiles file name ("oregon.mbtiles" -> "oregon")
 	region := filepath.Base(tilesDir)
+	if *mbtiles != "" {
+		region = strings.TrimSuffix(filepath.Base(*mbtiles), filepath.Ext(*mbtiles))
+	}
+	region, err := normalizeRegion(region)
+	if err != nil {
+		slog.Error("cannot take a region name from the tiles path (rename it to the region, e.g. tiles/oregon)", "error", err)
+		exit(1)
+	}
 
 	// Load configuration
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+	if *scheme != "" {
+		if cfg.Paths.TileScheme, err = ParseTileScheme(*scheme); err != nil {
+			slog.Error("invalid -scheme", "error", err)
+			exit(1)
+		}
+	}
+	cfg.Service.ExtractWorkers = workers.Or(cfg.Service.ExtractWorkers)
+	applyDedupGlobalFlag(fs, *dedupGlobal, cfg)
+	if *metadataCheck != "" {
+		if cfg.Service.ExtractMetadataCheck, err = ParseMetadataCheck(*metadataCheck); err != nil {
+			slog.Error("invalid -metadata-check", "error", err)
+			exit(1)
+		}
+	}
+	// -tile is addressed like the directory's paths; rows are compared in XYZ
+	if scope != nil && *mbtiles == "" {
+		scope.Tile = cfg.Paths.TileScheme.ToXYZ(scope.Tile)
 	}
 
-	slog.Info("starting road geometry extraction", "tiles_dir", tilesDir, "region", region)
+	slog.Info("starting road geometry extraction", "tiles_dir", tilesDir, "mbtiles", *mbtiles, "region", region, "scheme", cfg.Paths.TileScheme, "workers", cfg.Service.ExtractWorkers)
 
-	// Initialize database connection (required for extraction)
-	db, err := NewDatabase(cfg.Database)
-	if err != nil {
-		slog.Error("failed to connect to database (required for extraction)", "error", err)
-		os.Exit(1)
+	// Initialize database connection (required for extraction). A limited or
+	// scoped run only writes its partial extraction file, so it needs none.
+	var db *Database
+	if *maxTiles == 0 && scope == nil {
+		db, err = NewDatabase(cfg.Database)
+		if err != nil {
+			slog.Error("failed to connect to database (required for extraction)", "error", err)
+			exit(1)
+		}
+		defer db.Close()
 	}
-	defer db.Close()
 
 	// Create service
 	service := NewTileService(db, nil, cfg)
+	service.BoundsGeoJSON = *geoJSONPath
+	service.MaxTiles = *maxTiles
+	service.TileScope = scope
+
+	// On a terminal, show a progress bar instead of periodic progress logs
+	bar := newCLIProgress("extract", *debug)
+	if bar != nil {
+		service.Progress = bar.Update
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -360,14 +585,18 @@ func cmdExtract(args []string, configPath *string, debug *bool) {
 
 	// Run extraction
 	done := make(chan error, 1)
+	var summary *ExtractionSummary
 	go func() {
-		count, err := service.ExtractRoadGeometriesFromExistingTiles(ctx, tilesDir, region)
-		if err != nil {
-			done <- err
+		var err error
+		if *mbtiles != "" {
+			summary, err = service.ExtractRoadGeometriesFromMBTiles(ctx, *mbtiles, region)
 		} else {
-			slog.Info("extraction completed successfully", "roads_inserted", count)
-			done <- nil
+			summary, err = service.ExtractRoadGeometriesFromExistingTiles(ctx, tilesDir, region)
+		}
+		if bar != nil {
+			bar.Finish()
 		}
+		done <- err
 	}()
 
 	// Wait for completion or signal
@@ -375,26 +604,41 @@ func cmdExtract(args []string, configPath *string, debug *bool) {
 	case err := <-done:
 		if err != nil {
 			slog.Error("extraction failed", "error", err)
-			os.Exit(1)
+			exit(1)
+		}
+		if *jsonOut {
+			if err := summary.WriteJSON(os.Stdout); err != nil {
+				slog.Error("failed to write summary", "error", err)
+				exit(1)
+			}
+		} else {
+			summary.Print()
+		}
+		// Zero roads or a high invalid ratio usually means a silent failure
+		if err := summary.Check(*maxInvalidRatio); err != nil {
+			slog.Error("extraction looks wrong", "error", err)
+			exit(1)
 		}
 	case sig := <-sigChan:
 		slog.Info("received shutdown signal", "signal", sig)
 		cancel()
 		<-done
-		os.Exit(1)
+		exit(1)
 	}
 }
 
 // cmdInsertGeometries handles batch insertion of extracted road geometries
 func cmdInsertGeometries(args []string, configPath *string, debug *bool) {
 	fs := flag.NewFlagSet("insert-geometries", flag.ExitOnError)
+	keepFile := fs.Bool("keep-file", false, "Keep the extraction file after insertion (e.g. to run reconcile)")
+	dedupGlobal := fs.Bool("dedup-global", false, "Upsert on \"roadId\" alone, for a file extracted with -dedup-global (default EXTRACT_DEDUP_GLOBAL)")
 	fs.Parse(args)
 
 	parsedArgs := fs.Args()
 	if len(parsedArgs) == 0 {
 		slog.Error("extraction file or region required")
 		slog.Info("Usage: tile-service insert-geometries <extraction_file_or_region>")
-		os.Exit(1)
+		exit(1)
 	}
 	fileOrRegion := parsedArgs[0]
 
@@ -402,39 +646,34 @@ func cmdInsertGeometries(args []string, configPath *string, debug *bool) {
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+	applyDedupGlobalFlag(fs, *dedupGlobal, cfg)
+	keys := RoadKeyRegional
+	if cfg.Service.DedupGlobal {
+		keys = RoadKeyGlobal
 	}
 
 	// Initialize database connection (required)
 	db, err := NewDatabase(cfg.Database)
 	if err != nil {
 		slog.Error("failed to connect to database (required for insertion)", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 	defer db.Close()
 
 	extractor := NewGeometryExtractor()
 
 	// Determine if input is a file or region name
-	var extractionFile string
-	var region string
-
-	if _, err := os.Stat(fileOrRegion); err == nil {
-		// It's a file
-		extractionFile = fileOrRegion
-		// Try to extract region from filename: .extracted-roads-{region}.json
-		base := filepath.Base(fileOrRegion)
-		region = strings.TrimPrefix(base, ".extracted-roads-")
-		region = strings.TrimSuffix(region, ".json")
+	extractionFile, region := resolveExtractionFile(extractor, fileOrRegion)
+
+	if extractionFile == fileOrRegion {
 		slog.Info("inserting from file", "file", extractionFile, "region", region)
 	} else {
-		// It's a region name
-		region = fileOrRegion
-		extractionFile = extractor.getExtractionFile(region)
 		if _, err := os.Stat(extractionFile); os.IsNotExist(err) {
 			slog.Error("extraction file not found", "file", extractionFile, "region", region)
 			slog.Info("Run extraction first: tile-service generate -skip-geometry-insertion " + region)
-			os.Exit(1)
+			exit(1)
 		}
 		slog.Info("inserting from region", "region", region, "file", extractionFile)
 	}
@@ -458,17 +697,20 @@ func cmdInsertGeometries(args []string, configPath *string, debug *bool) {
 
 		slog.Info("loaded roads from file", "count", len(roads))
 
-		// Insert into database with large batch size (multi-row INSERT is efficient)
-		inserted, err := db.BatchUpsertRoadGeometries(ctx, roads, 9000)
+		// Insert into database with large batch size (multi-row INSERT is efficient),
+		// resuming after the roads an interrupted run already committed
+		inserted, err := insertRoadGeometriesResumable(ctx, db, extractionFile, region, roads, keys, 9000)
 		if err != nil {
-			done <- fmt.Errorf("failed to insert road geometries: %w", err)
+			done <- fmt.Errorf("failed to insert road geometries (rerun to resume): %w", err)
 			return
 		}
 
 		slog.Info("insertion completed successfully", "roads_inserted", inserted)
 
 		// Cleanup extraction files after successful insertion
-		if err := extractor.CleanupExtractionFiles(region); err != nil {
+		if *keepFile {
+			slog.Info("keeping extraction file", "file", extractionFile)
+		} else if err := extractor.CleanupExtractionFiles(region); err != nil {
 			slog.Warn("failed to cleanup extraction files", "error", err)
 		}
 
@@ -480,13 +722,231 @@ func cmdInsertGeometries(args []string, configPath *string, debug *bool) {
 	case err := <-done:
 		if err != nil {
 			slog.Error("insertion failed", "error", err)
-			os.Exit(1)
+			exit(1)
 		}
 	case sig := <-sigChan:
 		slog.Info("received shutdown signal", "signal", sig)
 		cancel()
 		<-done
-		os.Exit(1)
+		exit(1)
+	}
+}
+
+// cmdQAGeometries reports bounding box health for an extraction file
+func cmdQAGeometries(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("qa-geometries", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	top := fs.Int("top", 10, "Number of largest bounding boxes to list")
+	maxBBoxKm := fs.Float64("max-bbox-km", DefaultMaxBBoxMeters/1000, "Bounding box diagonal above which a road is counted as oversized")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 {
+		slog.Error("extraction file or region required")
+		slog.Info("Usage: tile-service qa-geometries [-json] [-top n] <extraction_file_or_region>")
+		exit(1)
+	}
+	if *top < 0 {
+		slog.Error("-top must not be negative", "top", *top)
+		exit(1)
+	}
+
+	extractor := NewGeometryExtractor()
+	extractionFile, _ := resolveExtractionFile(extractor, parsedArgs[0])
+
+	roads, err := extractor.loadRoadsFromFile(extractionFile)
+	if err != nil {
+		slog.Error("failed to load extraction file", "file", extractionFile, "error", err)
+		exit(1)
+	}
+
+	report := BuildGeometryQAReport(extractionFile, roads, *top, *maxBBoxKm*1000)
+	if *jsonOut {
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			slog.Error("failed to write report", "error", err)
+			exit(1)
+		}
+		return
+	}
+	report.Print()
+}
+
+// cmdInspectTile dumps a single tile's layers and features as JSON
+func cmdInspectTile(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("inspect-tile", flag.ExitOnError)
+	geo := fs.String("geo", "", "Tile address z/x/y for lat/lng output (default: parsed from the path)")
+	raw := fs.Bool("raw", false, "Print layers in tile coordinates instead of GeoJSON")
+	mbtiles := fs.String("mbtiles", "", "Read the tile from an .mbtiles file (the argument is then z/x/y)")
+	scheme := fs.String("scheme", "", "Row convention of the tile path or -geo address: xyz or tms (default TILE_SCHEME or xyz)")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 {
+		slog.Error("tile path required")
+		slog.Info("Usage: tile-service inspect-tile [-geo z/x/y] [-raw] [-mbtiles file] <path.pbf | z/x/y>")
+		exit(1)
+	}
+	tilePath := parsedArgs[0]
+
+	// Like extract, the row convention defaults to TILE_SCHEME
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+	tileScheme := cfg.Paths.TileScheme
+	if *scheme != "" {
+		if tileScheme, err = ParseTileScheme(*scheme); err != nil {
+			slog.Error("invalid -scheme", "error", err)
+			exit(1)
+		}
+	}
+
+	data, err := readInspectTile(context.Background(), tilePath, *mbtiles, tileScheme)
+	if err != nil {
+		slog.Error("failed to read tile", "path", tilePath, "error", err)
+		exit(1)
+	}
+
+	out, err := inspectTileOutput(data, tilePath, *geo, *raw, tileScheme)
+	if err != nil {
+		slog.Error("failed to inspect tile", "path", tilePath, "error", err)
+		exit(1)
+	}
+
+	if err := writeIndentedJSON(os.Stdout, out); err != nil {
+		slog.Error("failed to write output", "error", err)
+		exit(1)
+	}
+}
+
+// cmdExportGeoJSON writes a region's road geometries from the database as GeoJSON
+func cmdExportGeoJSON(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("export-geojson", flag.ExitOnError)
+	output := fs.String("o", "", "Output file (default {region}.geojson)")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 {
+		slog.Error("region required")
+		slog.Info("Usage: tile-service export-geojson [-o file] <region>")
+		exit(1)
+	}
+	region := parsedArgs[0]
+
+	// Load configuration
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	// Initialize database connection (required)
+	db, err := NewDatabase(cfg.Database)
+	if err != nil {
+		slog.Error("failed to connect to database (required for export)", "error", err)
+		exit(1)
+	}
+	defer db.Close()
+
+	// Logs go to stdout, so the export always goes to a file
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = region + ".geojson"
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		slog.Error("failed to create output file", "error", err)
+		exit(1)
+	}
+	defer f.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	count, err := ExportRegionGeoJSON(ctx, db, region, f)
+	if err != nil {
+		slog.Error("export failed", "error", err)
+		exit(1)
+	}
+	slog.Info("export completed", "region", region, "features", count, "output", outputPath)
+}
+
+// cmdConvert converts a KML or KMZ file to GeoJSON
+func cmdConvert(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.Parse(args)
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) != 2 {
+		slog.Error("input and output files required")
+		slog.Info("Usage: tile-service convert <kml-or-kmz-file> <output-geojson>")
+		exit(1)
+	}
+	inputPath, outputPath := parsedArgs[0], parsedArgs[1]
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	count, err := ConvertFileToGeoJSON(ctx, inputPath, outputPath)
+	if err != nil {
+		slog.Error("conversion failed", "input", inputPath, "error", err)
+		exit(1)
+	}
+	slog.Info("converted to GeoJSON", "features", count, "output", outputPath)
+}
+
+// cmdReconcile compares a region's extraction file against the database
+func cmdReconcile(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	file := fs.String("file", "", "Extraction file (default .extracted-roads-{region}.json)")
+	sample := fs.Int("sample", 1000, "Number of road IDs to check in each direction (0 = all)")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 {
+		slog.Error("region required")
+		slog.Info("Usage: tile-service reconcile [-file path] [-sample n] <region>")
+		exit(1)
+	}
+	region := parsedArgs[0]
+
+	extractor := NewGeometryExtractor()
+	extractionFile := *file
+	if extractionFile == "" {
+		extractionFile = extractor.getExtractionFile(region)
+	}
+
+	roads, err := extractor.loadRoadsFromFile(extractionFile)
+	if err != nil {
+		slog.Error("failed to load extraction file", "file", extractionFile, "error", err)
+		exit(1)
+	}
+
+	// Load configuration
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	// Initialize database connection (required)
+	db, err := NewDatabase(cfg.Database)
+	if err != nil {
+		slog.Error("failed to connect to database (required for reconcile)", "error", err)
+		exit(1)
+	}
+	defer db.Close()
+
+	report, err := Reconcile(context.Background(), db, region, roads, *sample)
+	if err != nil {
+		slog.Error("reconcile failed", "error", err)
+		exit(1)
+	}
+
+	report.Print()
+	if !report.OK {
+		exit(1)
 	}
 }
 
@@ -503,7 +963,7 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	// Get list of regions to merge
@@ -515,11 +975,11 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 		inputDirs, err = FindOverlappingRegions(cfg.Paths.OutputDir, *forRegion)
 		if err != nil {
 			slog.Error("failed to find overlapping regions", "error", err, "for_region", *forRegion)
-			os.Exit(1)
+			exit(1)
 		}
 		if len(inputDirs) == 0 {
 			slog.Error("no overlapping regions found", "for_region", *forRegion)
-			os.Exit(1)
+			exit(1)
 		}
 		slog.Info("merging overlapping regions", "for_region", *forRegion, "count", len(inputDirs), "dirs", inputDirs)
 	} else if len(parsedArgs) > 0 {
@@ -528,7 +988,7 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 			regionDir := filepath.Join(cfg.Paths.OutputDir, region)
 			if _, err := os.Stat(regionDir); os.IsNotExist(err) {
 				slog.Error("region tiles not found", "region", region, "dir", regionDir)
-				os.Exit(1)
+				exit(1)
 			}
 			inputDirs = append(inputDirs, regionDir)
 		}
@@ -538,11 +998,11 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 		inputDirs, err = FindRegionalTileDirs(cfg.Paths.OutputDir)
 		if err != nil {
 			slog.Error("failed to find regional tile directories", "error", err)
-			os.Exit(1)
+			exit(1)
 		}
 		if len(inputDirs) == 0 {
 			slog.Error("no regional tile directories found", "base_dir", cfg.Paths.OutputDir)
-			os.Exit(1)
+			exit(1)
 		}
 		slog.Info("merging all regions", "count", len(inputDirs), "dirs", inputDirs)
 	}
@@ -560,12 +1020,10 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 		mergedDir := filepath.Join(cfg.Paths.OutputDir, "merged")
 
 		// Setup merge options with zoom filtering
-		var mergeOpts *MergeTilesOptions
-		if *minZoom >= 0 || *maxZoom >= 0 {
-			mergeOpts = &MergeTilesOptions{
-				MinZoom: *minZoom,
-				MaxZoom: *maxZoom,
-			}
+		mergeOpts := &MergeTilesOptions{
+			MinZoom:       *minZoom,
+			MaxZoom:       *maxZoom,
+			TippecanoeBin: cfg.Paths.TippecanoeBin,
 		}
 
 		metadata, err := MergeTilesWithOptions(ctx, inputDirs, mergedDir, mergeOpts)
@@ -604,14 +1062,14 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 	case err := <-done:
 		if err != nil {
 			slog.Error("merge failed", "error", err)
-			os.Exit(1)
+			exit(1)
 		}
 		slog.Info("merge operation completed successfully")
 	case sig := <-sigChan:
 		slog.Info("received shutdown signal", "signal", sig)
 		cancel()
 		<-done
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -619,17 +1077,28 @@ func cmdMerge(args []string, configPath *string, debug *bool) {
 func cmdServe(args []string, configPath *string, debug *bool) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	port := fs.Int("port", 8080, "Port to listen on")
+	pprofAddr := fs.String("pprof-addr", "", "Expose net/http/pprof on this address (e.g., localhost:6060)")
 	fs.Parse(args)
 
 	// Load configuration
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	// Workers generate tiles with Tippecanoe, so refuse to start without it
+	if err := CheckTippecanoe(cfg.Paths.TippecanoeBin); err != nil {
+		slog.Error("tippecanoe not available (set TIPPECANOE_BIN)", "error", err)
+		exit(1)
 	}
 
 	slog.Info("starting tile service API server", "port", *port)
 
+	if *pprofAddr != "" {
+		startPprofServer(*pprofAddr)
+	}
+
 	// Initialize database connection (optional)
 	db, err := NewDatabase(cfg.Database)
 	if err != nil {
@@ -643,7 +1112,7 @@ func cmdServe(args []string, configPath *string, debug *bool) {
 	s3Client, err := NewS3Client(cfg.S3)
 	if err != nil {
 		slog.Error("failed to initialize S3 client", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	// Create API server
@@ -665,13 +1134,38 @@ func cmdServe(args []string, configPath *string, debug *bool) {
 	select {
 	case err := <-errChan:
 		slog.Error("server failed to start", "error", err)
-		os.Exit(1)
+		exit(1)
 	case sig := <-sigChan:
-		slog.Info("received shutdown signal, stopping server", "signal", sig)
-		os.Exit(0)
+		timeout := time.Duration(cfg.Service.ShutdownTimeout) * time.Second
+		slog.Info("received shutdown signal, waiting for the running job", "signal", sig, "timeout", timeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		// A second signal stops waiting and cancels the running job
+		go func() {
+			sig := <-sigChan
+			slog.Warn("received second signal, cancelling running job", "signal", sig)
+			cancel()
+		}()
+
+		if err := apiServer.Stop(ctx); err != nil {
+			slog.Error("server did not shut down cleanly", "error", err)
+			exit(1)
+		}
+		slog.Info("server stopped")
 	}
 }
 
+// applyDedupGlobalFlag overrides EXTRACT_DEDUP_GLOBAL with -dedup-global when
+// it was given, so -dedup-global=false turns it off
+func applyDedupGlobalFlag(fs *flag.FlagSet, dedupGlobal bool, cfg *Config) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "dedup-global" {
+			cfg.Service.DedupGlobal = dedupGlobal
+		}
+	})
+}
+
 // reorderFlagsFirst moves flag arguments before positional arguments so Go's
 // flag package parses them correctly. Go's flag stops at the first non-flag arg.
 // This allows "verify tiles <dir> --min-zoom 0" to work like "--min-zoom 0 <dir>".
@@ -692,11 +1186,186 @@ func reorderFlagsFirst(args []string) []string {
 	return append(flags, positional...)
 }
 
+// cmdDownload downloads a region's tiles from R2 into a local directory
+func cmdDownload(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	workers := addWorkersFlag(fs, "Number of files to download at once (default 32)", "concurrency")
+	retries := fs.Int("retries", DefaultRetryPolicy.MaxAttempts-1, "Times to retry a file after a transient failure")
+	all := fs.Bool("all", false, "Without a region manifest, download every tile under the shared prefix")
+	fs.Parse(reorderFlagsFirst(args))
+
+	retriesSet := false
+	fs.Visit(func(f *flag.Flag) {
+		retriesSet = retriesSet || f.Name == "retries"
+	})
+
+	if *retries < 0 {
+		slog.Error("-retries cannot be negative", "retries", *retries)
+		exit(1)
+	}
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) < 2 {
+		slog.Error("region and destination directory required")
+		slog.Info("Usage: tile-service download <region> <dest_dir>")
+		exit(1)
+	}
+	region, destDir := parsedArgs[0], parsedArgs[1]
+
+	// Load configuration
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	// Initialize S3 client
+	s3Client, err := NewS3Client(cfg.S3)
+	if err != nil {
+		slog.Error("failed to initialize S3 client", "error", err)
+		exit(1)
+	}
+
+	// -retries overrides S3_MAX_ATTEMPTS, which NewS3Client already applied
+	if retriesSet || cfg.S3.MaxAttempts == 0 {
+		s3Client.Retry = DefaultRetryPolicy
+		s3Client.Retry.MaxAttempts = *retries + 1
+	}
+
+	// On a terminal, show a progress bar instead of periodic progress logs
+	opts := DownloadOptions{Concurrency: workers.Or(DefaultDownloadConcurrency), All: *all}
+	bar := newCLIProgress("download", *debug)
+	if bar != nil {
+		opts.Progress = bar.Update
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	service := NewTileService(nil, s3Client, cfg)
+	summary, err := service.DownloadRegion(ctx, region, destDir, opts)
+	if bar != nil {
+		bar.Finish()
+	}
+	if summary != nil {
+		summary.Print()
+	}
+	if err != nil {
+		slog.Error("download failed", "error", err)
+		exit(1)
+	}
+}
+
+// cmdPrune deletes tiles on R2 that no longer exist locally
+func cmdPrune(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Only log the stale tiles that would be deleted")
+	force := fs.Bool("force", false, "Delete even when most remote tiles have no local counterpart")
+	fs.Parse(reorderFlagsFirst(args))
+
+	// Load configuration
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	tilesRoot := cfg.Paths.OutputDir
+	if parsedArgs := fs.Args(); len(parsedArgs) > 0 {
+		tilesRoot = parsedArgs[0]
+	}
+
+	// Initialize S3 client
+	s3Client, err := NewS3Client(cfg.S3)
+	if err != nil {
+		slog.Error("failed to initialize S3 client", "error", err)
+		exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	service := NewTileService(nil, s3Client, cfg)
+	summary, err := service.PruneStaleTiles(ctx, tilesRoot, PruneOptions{DryRun: *dryRun, Force: *force})
+	if summary != nil {
+		summary.Print()
+	}
+	if err != nil {
+		slog.Error("prune failed", "error", err)
+		exit(1)
+	}
+}
+
+// cmdRepairZooms regenerates missing zoom levels of a region's tiles from its GeoJSON
+func cmdRepairZooms(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("repair-zooms", flag.ExitOnError)
+	geoJSONPath := fs.String("geojson", "", "GeoJSON the region was generated from (required)")
+	zoomList := fs.String("zooms", "", "Comma-separated zoom levels to regenerate (default: those verify finds missing)")
+	minZoom := fs.Int("min-zoom", 0, "Minimum expected zoom level when finding missing zooms")
+	maxZoom := fs.Int("max-zoom", 16, "Maximum expected zoom level when finding missing zooms")
+	encoder := fs.String("encoder", EncoderTippecanoe, "Tile encoder: tippecanoe or go")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 || *geoJSONPath == "" {
+		slog.Error("region and -geojson required")
+		slog.Info("Usage: tile-service repair-zooms -geojson <file> [-zooms 7,9] <region>")
+		exit(1)
+	}
+	region := parsedArgs[0]
+
+	zooms, err := parseZoomList(*zoomList)
+	if err != nil {
+		slog.Error("invalid -zooms", "error", err)
+		exit(1)
+	}
+
+	// Load configuration
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+	tilesDir := filepath.Join(cfg.Paths.OutputDir, region)
+
+	if len(zooms) == 0 {
+		report, err := VerifyTileDirectory(tilesDir, *minZoom, *maxZoom)
+		if err != nil {
+			slog.Error("verification failed", "error", err)
+			exit(1)
+		}
+		if report.OK {
+			slog.Info("no missing zoom levels, nothing to repair", "tiles_dir", tilesDir)
+			return
+		}
+		zooms = report.MissingZooms
+	}
+
+	if *encoder != EncoderGo {
+		if err := CheckTippecanoe(cfg.Paths.TippecanoeBin); err != nil {
+			slog.Error("tippecanoe not available (set TIPPECANOE_BIN or use -encoder go)", "error", err)
+			exit(1)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	service := NewTileService(nil, nil, cfg)
+	opts := service.generateTilesOptions(region, &JobOptions{Encoder: *encoder})
+	count, err := RegenerateZooms(ctx, *geoJSONPath, tilesDir, zooms, opts)
+	if err != nil {
+		slog.Error("repair failed", "region", region, "error", err)
+		exit(1)
+	}
+	slog.Info("repair completed", "region", region, "zooms", zooms, "tiles_count", count)
+}
+
 // cmdVerify handles tile verification commands
 func cmdVerify(args []string, configPath *string, debug *bool) {
 	if len(args) == 0 {
 		slog.Error("verify subcommand required: tiles, merge, or upload")
-		os.Exit(1)
+		exit(1)
 	}
 
 	subcommand := args[0]
@@ -712,7 +1381,7 @@ func cmdVerify(args []string, configPath *string, debug *bool) {
 	default:
 		slog.Error("unknown verify subcommand", "subcommand", subcommand)
 		slog.Info("available: tiles, merge, upload")
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -720,26 +1389,40 @@ func cmdVerifyTiles(args []string) {
 	fs := flag.NewFlagSet("verify tiles", flag.ExitOnError)
 	minZoom := fs.Int("min-zoom", 0, "Minimum expected zoom level")
 	maxZoom := fs.Int("max-zoom", 16, "Maximum expected zoom level")
+	minTiles := fs.Int("min-tiles", 0, "Fail zoom levels with fewer tiles than this (0 = only check presence)")
+	minTilesZoom := fs.String("min-tiles-zoom", "", "Per-zoom minimum tile counts overriding -min-tiles, e.g. 12=100,14=400")
+	minTilesFraction := fs.Float64("min-tiles-fraction", 0, "Fail zoom levels with fewer than this share of the zoom's 4^z tiles")
 	fs.Parse(reorderFlagsFirst(args))
 
 	parsedArgs := fs.Args()
 	if len(parsedArgs) == 0 {
 		slog.Error("tiles directory required")
-		slog.Info("Usage: tile-service verify tiles <dir> [--min-zoom N] [--max-zoom N]")
-		os.Exit(1)
+		slog.Info("Usage: tile-service verify tiles <dir> [--min-zoom N] [--max-zoom N] [--min-tiles N]")
+		exit(1)
 	}
 	dir := parsedArgs[0]
 
-	report, err := VerifyTileDirectory(dir, *minZoom, *maxZoom)
+	perZoom, err := ParseZoomTileCounts(*minTilesZoom)
+	if err != nil {
+		slog.Error("invalid -min-tiles-zoom", "error", err)
+		exit(1)
+	}
+	if *minTiles < 0 || *minTilesFraction < 0 || *minTilesFraction > 1 {
+		slog.Error("-min-tiles must be positive and -min-tiles-fraction between 0 and 1", "min_tiles", *minTiles, "min_tiles_fraction", *minTilesFraction)
+		exit(1)
+	}
+	mins := &ZoomTileMinimums{Default: *minTiles, PerZoom: perZoom, Fraction: *minTilesFraction}
+
+	report, err := VerifyTileDirectoryWithMinimums(dir, *minZoom, *maxZoom, mins)
 	if err != nil {
 		slog.Error("verification failed", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	report.Print()
 
 	if !report.OK {
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -751,14 +1434,14 @@ func cmdVerifyMerge(args []string, configPath *string) {
 	if len(parsedArgs) == 0 {
 		slog.Error("region required")
 		slog.Info("Usage: tile-service verify merge <region>")
-		os.Exit(1)
+		exit(1)
 	}
 	region := parsedArgs[0]
 
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	regionDir := filepath.Join(cfg.Paths.OutputDir, region)
@@ -767,55 +1450,145 @@ func cmdVerifyMerge(args []string, configPath *string) {
 	report, err := VerifyMergeIntegrity(regionDir, mergedDir)
 	if err != nil {
 		slog.Error("merge verification failed", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	report.Print()
 
 	if !report.OK {
-		os.Exit(1)
+		exit(1)
 	}
 }
 
 func cmdVerifyUpload(args []string, configPath *string) {
 	fs := flag.NewFlagSet("verify upload", flag.ExitOnError)
 	samplesPerZoom := fs.Int("samples-per-zoom", 5, "Number of tiles to spot-check per zoom level")
+	seed := fs.Int64("seed", 0, "Seed for choosing sample tiles (0 = random; the seed used is printed)")
+	full := fs.Bool("full", false, "Check every local tile against one listing of R2, and report orphaned remote tiles")
 	fs.Parse(reorderFlagsFirst(args))
 
 	parsedArgs := fs.Args()
 	if len(parsedArgs) == 0 {
 		slog.Error("region required")
-		slog.Info("Usage: tile-service verify upload <region> [--samples-per-zoom N]")
-		os.Exit(1)
+		slog.Info("Usage: tile-service verify upload <region> [--samples-per-zoom N] [--seed N] [--full]")
+		exit(1)
 	}
 	region := parsedArgs[0]
 
 	cfg, err := LoadConfig(*configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	s3Client, err := NewS3Client(cfg.S3)
 	if err != nil {
 		slog.Error("failed to initialize S3 client", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	tilesDir := filepath.Join(cfg.Paths.OutputDir, region)
 	ctx := context.Background()
 
-	report, err := VerifyUpload(ctx, s3Client, tilesDir, cfg.S3.BucketPath, *samplesPerZoom)
+	if *full {
+		report, err := VerifyUploadFull(ctx, s3Client, tilesDir, cfg.S3.BucketPath)
+		if err != nil {
+			slog.Error("upload verification failed", "error", err)
+			exit(1)
+		}
+		report.Print()
+		if !report.OK {
+			exit(1)
+		}
+		return
+	}
+
+	// Always sample with a known seed so any run can be repeated
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	report, err := VerifyUpload(ctx, s3Client, tilesDir, cfg.S3.BucketPath, *samplesPerZoom, *seed)
 	if err != nil {
 		slog.Error("upload verification failed", "error", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	report.Print()
 
 	if !report.OK {
-		os.Exit(1)
+		exit(1)
+	}
+}
+
+// cmdReport prints a region's road counts at each pipeline stage
+func cmdReport(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	kmlPath := fs.String("kml", "", "KML file (default: extracted from the region's KMZ in CURVATURE_DATA_DIR)")
+	geoJSONPath := fs.String("geojson", "", "GeoJSON file (default: the intermediate GeoJSON if kept, else converted from the KML)")
+	tilesPath := fs.String("tiles", "", "Tile directory or .mbtiles file (default OUTPUT_DIR/{region})")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(reorderFlagsFirst(args))
+
+	parsedArgs := fs.Args()
+	if len(parsedArgs) == 0 {
+		slog.Error("region required")
+		slog.Info("Usage: tile-service report [-kml file] [-geojson file] [-tiles dir|file.mbtiles] [-json] <region>")
+		exit(1)
+	}
+	region := parsedArgs[0]
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	service := NewTileService(nil, nil, cfg)
+	report, err := service.PipelineReport(context.Background(), region, ReportOptions{
+		KMLPath:     *kmlPath,
+		GeoJSONPath: *geoJSONPath,
+		TilesPath:   *tilesPath,
+	})
+	if err != nil {
+		slog.Error("failed to build pipeline report", "region", region, "error", err)
+		exit(1)
+	}
+
+	if *jsonOut {
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			slog.Error("failed to write report", "error", err)
+			exit(1)
+		}
+		return
+	}
+	report.Print(os.Stdout)
+}
+
+// cmdStats prints the number of road geometries in the database per region
+func cmdStats(args []string, configPath *string, debug *bool) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.Parse(reorderFlagsFirst(args))
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		exit(1)
+	}
+
+	db, err := NewDatabase(cfg.Database)
+	if err != nil {
+		slog.Error("failed to connect to database (required for stats)", "error", err)
+		exit(1)
+	}
+	defer db.Close()
+
+	counts, err := db.GetRoadGeometryCountsByRegion(context.Background())
+	if err != nil {
+		slog.Error("failed to get road geometry counts", "error", err)
+		exit(1)
 	}
+	PrintRoadGeometryCounts(os.Stdout, counts)
 }
 
 func showHelp() {
@@ -826,20 +1599,33 @@ Usage:
 
 Global Options:
   -config string        Path to .env configuration file (default ".env")
-  -debug                Enable debug logging
+  -debug                Enable debug logging (set DB_LOG_SQL=true to also log SQL)
   -help                 Show this help message
+  -cpuprofile string    Write a CPU profile to this file
+  -memprofile string    Write a heap profile to this file when the command finishes
 
 Commands:
   generate              Generate tiles from road geometry data
   upload                Upload pre-generated tiles to R2
   extract               Extract road geometries from existing tiles into database
+  convert               Convert a curvature KML or KMZ file to GeoJSON
   insert-geometries     Insert extracted road geometries from file into database
+  qa-geometries         Report bounding box health of extracted road geometries
+  reconcile             Compare an extraction file against the database
+  export-geojson        Export a region's road geometries from the database as GeoJSON
+  inspect-tile          Dump a single tile's layers and features as JSON/GeoJSON
   merge                 Merge regional tiles and upload to R2
+  download              Download a region's tiles from R2 into a local directory
+  prune                 Delete tiles on R2 that no region has any more
+  repair-zooms          Regenerate only the missing zoom levels of a region's tiles
   verify                Verify tile integrity, merge completeness, or upload status
+  report                Trace a region's road counts from KML through GeoJSON to tiles
+  stats                 Print the number of road geometries in the database per region
   serve                 Start the REST API server
 
 Generate Command:
   Usage: tile-service generate [options] <region> [region2] [region3] ...
+         tile-service generate -from-job <job_file>
 
   Arguments:
     <region>              One or more region names (e.g., washington oregon california)
@@ -854,6 +1640,39 @@ Generate Command:
     -skip-geometry-insertion  Extract to file but don't insert to database
     -merge-all            Merge all regions instead of just overlapping neighbors
     -workers int          Number of parallel workers for multi-region generation (default 1)
+    -extract-workers int  Tiles decoded at once during geometry extraction
+                          (default EXTRACT_WORKERS, or one per CPU)
+    -dedup-global         Key roads by name and rounded start and end points so
+                          overlapping regions share one row (default EXTRACT_DEDUP_GLOBAL)
+    -keep-going           Continue with remaining regions when one fails (default true);
+                          -keep-going=false stops starting regions after the first failure
+    -skip-empty           Don't upload near-empty tiles (see upload options)
+    -skip-empty-bytes int Size threshold for -skip-empty (default 50)
+    -skip-empty-strict    Only skip small tiles that decode to zero features
+    -encoder string       Tile encoder: tippecanoe or go (pure-Go fallback for small datasets/CI,
+                          no feature dropping or simplification) (default "tippecanoe").
+                          The Tippecanoe binary is TIPPECANOE_BIN (default "tippecanoe")
+    -clip string          GeoJSON file with a Polygon/MultiPolygon boundary; road parts
+                          outside it are dropped during conversion (amount reported in the log)
+    -preserve-altitude    Keep KML altitude as a third coordinate ([lng, lat, alt]) in the
+                          GeoJSON; tiles and extracted geometry stay 2D
+    -max-tile-features int
+                          Tippecanoe --maximum-tile-features (default: Tippecanoe's 200000).
+                          Tiles over the limit drop their densest features
+    -max-tile-bytes int   Tippecanoe --maximum-tile-bytes (default: Tippecanoe's 500000).
+                          Tiles over the limit drop their densest features
+    -id-attribute string  Promote this property to the MVT feature ID (Tippecanoe
+                          --use-attribute-for-id). Only non-negative integer values
+                          become IDs (default: no feature IDs)
+    -atomic-swap          Generate into a hidden directory beside the region's tiles and
+                          swap it in only on success; a failed or cancelled run leaves
+                          the existing tiles untouched
+    -stats-only           Generate tiles into a temp directory, print tile count, size and
+                          per-zoom stats, then delete them (no upload, no database)
+    -from-job string      Replay a job definition written next to the tiles of a previous
+                          run (<tiles_dir>/.job-<id>.json); region, options and the
+                          TIPPECANOE_* settings come from the file. Options given
+                          explicitly override the recorded ones
 
 Upload Command:
   Usage: tile-service upload [options] <tiles_directory>
@@ -864,17 +1683,95 @@ Upload Command:
   Options:
     -min-zoom int         Minimum zoom level to upload (-1 = all, default -1)
     -max-zoom int         Maximum zoom level to upload (-1 = all, default -1)
+    -manifest             Also upload manifests/<region>/manifest.json listing every file
+                          with its size and SHA-256 (for auditing and cache-busting)
+    -dedup                Upload byte-identical tiles once and CopyObject the duplicates
+    -skip-empty           Skip tiles smaller than -skip-empty-bytes (reports how many were skipped)
+    -skip-empty-bytes int Size threshold for -skip-empty (default 50)
+    -skip-empty-strict    Only skip small tiles that decode to zero features
+    -sync                 Compare against the manifest of the last upload: upload only
+                          added and changed files, delete files removed locally from R2,
+                          then upload the new manifest. Reports added/updated/deleted
+                          counts. Cannot be combined with zoom filters, -dedup or -skip-empty.
+                          Files another region's manifest lists are never deleted
+    -verify               With -sync, list the tiles prefix on R2 and upload again files
+                          the last manifest lists but R2 is missing (slower; by default
+                          the manifest is trusted)
+    -workers int          Number of files to upload at once (default S3_UPLOAD_CONCURRENCY,
+                          or 8). The first failed file cancels the rest of the upload.
+                          -concurrency is accepted as an alias
+
+  Description:
+    Tiles go to S3_BUCKET_PATH/z/x/y.pbf as application/x-protobuf, cached for an
+    hour, with Content-Encoding: gzip when gzipped (see S3_CONTENT_TYPES and
+    S3_CACHE_CONTROL). A metadata.json in the tiles directory goes to
+    S3_BUCKET_PATH/<region>/metadata.json as application/json, with the
+    S3_METADATA_ACL and S3_METADATA_CACHE_CONTROL settings (also used for manifests).
 
 Extract Command:
-  Usage: tile-service extract <tiles_directory>
+  Usage: tile-service extract <tiles_directory | file.mbtiles>
+         tile-service extract -mbtiles <file>
 
   Arguments:
     <tiles_directory>     Path to the tiles directory (e.g., ~/data/df/tiles/oregon)
+                          or an .mbtiles file (same as -mbtiles)
+
+  Options:
+    -mbtiles string       Read tiles from an .mbtiles file; the region is the
+                          file name without its extension
+    -scheme string        Row convention of the tiles directory: xyz or tms
+                          (default TILE_SCHEME, or xyz). TMS rows are flipped
+                          to XYZ as tiles are read.
+    -json                 Print the extraction summary as JSON
+    -max-invalid-ratio float
+                          Exit 1 when invalid roads exceed this share of all
+                          decoded roads (default 0.05, 0 = off)
+    -workers int          Tiles read and decoded at once (default EXTRACT_WORKERS,
+                          or one per CPU). Tiles are still accounted for in
+                          order, so progress checkpoints resume correctly.
+    -geojson string       Also write the extracted bounding boxes to this file as
+                          a GeoJSON FeatureCollection, one rectangle per road
+                          (roadId, region, curvature), e.g. to check them in QGIS
+    -max-tiles int        Only extract from the first N tiles in z/x/y order, for
+                          debugging the bounds logic without a full run (default
+                          0: all). The summary reports the limit. A limited run
+                          saves no progress, inserts nothing into the database and
+                          writes .extracted-roads-{region}.partial.json instead.
+    -tile string          Only extract from this tile (z/x/y, as in the directory's
+                          paths; XYZ for -mbtiles), for debugging one road
+    -subtree string       Only extract from the tiles of one z/x column (the z/x
+                          directory). Like -max-tiles, a scoped run saves no
+                          progress and only writes the partial extraction file.
+    -metadata-check string
+                          What to do when the tileset's metadata.json (or MBTiles
+                          metadata) declares a non-vector format, a row scheme
+                          other than the one tiles are read as, or a projection
+                          other than Web Mercator: error, warn or off (default
+                          EXTRACT_METADATA_CHECK, or error)
+    -dedup-global         Key roads by a hash of their name and start and end points
+                          (rounded to 3 decimals) instead of road ID and region,
+                          so a road in two overlapping regions is one row. Needs the
+                          unique "roadId" index of docs/SCHEMA_SYNC.md (default
+                          EXTRACT_DEDUP_GLOBAL)
 
   Description:
     Extracts road bounding boxes from vector tiles and stores them in the database.
     This enables the "Find Nearby Roads" feature in the application.
     Can be run on existing tiles without regenerating them.
+    Ends with a summary (tiles processed/failed, roads extracted, invalid
+    skipped, roads inserted) and exits 1 when no roads were extracted or the
+    invalid ratio is too high, so CI catches silent failures.
+
+Convert Command:
+  Usage: tile-service convert <kml-or-kmz-file> <output-geojson>
+
+  Arguments:
+    <kml-or-kmz-file>     Curvature KML file, or a KMZ whose doc.kml is extracted first
+    <output-geojson>      GeoJSON file to write (overwritten if it exists)
+
+  Description:
+    Converts curvature KML to the GeoJSON that generate feeds to Tippecanoe,
+    without generating tiles. Needs no configuration, database or R2 access.
 
 Insert Geometries Command:
   Usage: tile-service insert-geometries <extraction_file_or_region>
@@ -888,6 +1785,67 @@ Insert Geometries Command:
     Batch inserts road geometries from extraction file into database.
     Use this after generating tiles with -skip-geometry-insertion flag.
     Allows you to review extracted data before inserting to database.
+    Progress is saved to .insert-progress-{region}.json after every commit, so
+    rerunning an interrupted insertion skips the roads already inserted.
+
+  Options:
+    -keep-file            Keep the extraction file after insertion (needed for reconcile)
+    -dedup-global         Upsert on "roadId" alone, for a file extracted with
+                          -dedup-global (default EXTRACT_DEDUP_GLOBAL)
+
+QA Geometries Command:
+  Usage: tile-service qa-geometries [options] <extraction_file_or_region>
+
+  Options:
+    -json                 Print the report as JSON
+    -top int              Number of largest bounding boxes to list (default 10)
+    -max-bbox-km float    Diagonal above which a road counts as oversized (default 200)
+
+  Description:
+    One-shot health check of an extraction file before insertion: road count,
+    min/max/avg bounding box size, roads with near-zero area or no curvature,
+    and the largest bounding boxes (usually same-name merge bugs).
+
+Reconcile Command:
+  Usage: tile-service reconcile [options] <region>
+
+  Options:
+    -file string          Extraction file (default .extracted-roads-{region}.json)
+    -sample int           Road IDs to check in each direction (0 = all, default 1000)
+
+  Description:
+    Compares the database road count for the region with the extraction file
+    and reports missing and extra road IDs from a random sample. Duplicate
+    road IDs in the file (collapsed by ON CONFLICT on insert) are reported too.
+    Exits non-zero if the database and file disagree. Files extracted with
+    -dedup-global are refused, since their rows are shared across regions.
+
+Export GeoJSON Command:
+  Usage: tile-service export-geojson [-o file] <region>
+
+  Options:
+    -o string             Output file (default {region}.geojson)
+
+  Description:
+    Streams the region's RoadGeometry rows from the database (in pages of
+    DB_STREAM_PAGE_SIZE rows, default 5000, ordered by roadId) and writes them
+    as a GeoJSON FeatureCollection.
+
+Inspect Tile Command:
+  Usage: tile-service inspect-tile [options] <path.pbf>
+         tile-service inspect-tile -mbtiles <file> [options] <z/x/y>
+
+  Options:
+    -geo string           Tile address z/x/y (default: parsed from .../z/x/y.pbf)
+    -raw                  Print layers and features in tile coordinates
+    -mbtiles string       Read the tile from an .mbtiles file (addressed in XYZ)
+    -scheme string        Row convention of the tile path or address: xyz or tms
+                          (default TILE_SCHEME, or xyz)
+
+  Description:
+    Decodes one vector tile (gzipped or not) and prints its features as a
+    GeoJSON FeatureCollection in lat/lng, with each feature's layer in a
+    "layer" property. Without a tile address, prints the raw layers instead.
 
 Merge Command:
   Usage: tile-service merge [options] [regions...]
@@ -905,6 +1863,9 @@ Merge Command:
     Merges multiple regional tile directories into a single "merged" directory
     using tile-join. This combines tiles from overlapping regions so all roads
     are visible at low zoom levels. The merged tiles are then uploaded to R2.
+    tile-join is looked up next to TIPPECANOE_BIN (or on PATH when that is a
+    plain command name). Without it, a pure-Go merge combines the tiles'
+    layers instead (tile directories only, no MBTiles).
 
     This command is useful for:
     - Re-merging after adding new regional tiles without regenerating
@@ -912,6 +1873,69 @@ Merge Command:
     - Manual control over which regions to include in the merged output
     - Using --for to efficiently merge only neighboring regions
 
+Download Command:
+  Usage: tile-service download [options] <region> <dest_dir>
+
+  Arguments:
+    <region>              Region name (e.g., oregon)
+    <dest_dir>            Directory to write {z}/{x}/{y}.pbf tiles into
+
+  Options:
+    -workers int          Number of files to download at once (default 32;
+                          -concurrency is accepted as an alias)
+    -retries int          Times to retry a file after a transient failure
+                          (default S3_MAX_ATTEMPTS-1, or 3)
+    -all                  Without a region manifest, download every tile under the
+                          shared prefix (other regions' tiles included)
+
+  Description:
+    Lists the objects under S3_BUCKET_PATH on R2 and downloads the region's tiles
+    and metadata.json in parallel. Tiles of every region share that prefix, so only
+    the files in the region's manifest (upload -manifest or -sync) are downloaded;
+    without a manifest the command fails unless -all is given. Connection errors, cut-off
+    downloads, throttling and 5xx responses are retried with exponential backoff.
+
+Prune Command:
+  Usage: tile-service prune [options] [tiles_root]
+
+  Arguments:
+    [tiles_root]          Directory holding one tiles directory per region
+                          (default: OUTPUT_DIR, e.g. ~/data/df/tiles)
+
+  Options:
+    -dry-run              Only log the stale tiles that would be deleted
+    -force                Delete even when more than half of the remote tiles are stale
+
+  Description:
+    Tiles of every region share one prefix on R2, so a tile is kept when any
+    region has it: any region directory under tiles_root, or any region
+    manifest on R2 (upload -manifest or -sync), which covers regions generated
+    on another machine. Every other .pbf tile under S3_BUCKET_PATH is deleted,
+    then the number of objects removed and bytes reclaimed is printed. Other
+    objects, such as manifests, are never deleted. A region directory left by
+    an interrupted generation run stops the prune.
+
+Repair Zooms Command:
+  Usage: tile-service repair-zooms -geojson <file> [options] <region>
+
+  Arguments:
+    <region>              Region whose tiles in OUTPUT_DIR are repaired
+
+  Options:
+    -geojson string       GeoJSON the region was generated from (keep it with
+                          generate -no-cleanup)
+    -zooms string         Comma-separated zoom levels to regenerate (e.g., 7,9).
+                          Default: the zooms verify tiles reports missing
+    -min-zoom int         Minimum expected zoom level when finding missing zooms (default 0)
+    -max-zoom int         Maximum expected zoom level when finding missing zooms (default 16)
+    -encoder string       Tile encoder: tippecanoe or go (default "tippecanoe")
+
+  Description:
+    Generates just the given zoom levels into a scratch directory next to the
+    region's tiles, then swaps each zoom into the tree. Other zoom levels are
+    not touched, and nothing changes if generation fails. Upload the region
+    afterwards to publish the repaired zooms.
+
 Verify Command:
   Usage: tile-service verify <subcommand> [options] [arguments]
 
@@ -921,38 +1945,91 @@ Verify Command:
     upload                Spot-check that tiles exist on R2
 
   Verify Tiles:
-    Usage: tile-service verify tiles <dir> [--min-zoom N] [--max-zoom N]
+    Usage: tile-service verify tiles <dir> [--min-zoom N] [--max-zoom N] [--min-tiles N]
 
     Options:
       -min-zoom int         Minimum expected zoom level (default 0)
       -max-zoom int         Maximum expected zoom level (default 16)
+      -min-tiles int        Also fail zoom levels with fewer tiles than this, to catch
+                            partial generation (default 0: presence only). Capped at
+                            the 4^z tiles a zoom has, so low zooms are not failed
+      -min-tiles-zoom string
+                            Per-zoom minimums overriding -min-tiles, e.g. 12=100,14=400
+      -min-tiles-fraction float
+                            Minimum as a share of the zoom's 4^z tiles (e.g. 0.5 for a
+                            world-wide tile set); the larger minimum applies
 
   Verify Merge:
     Usage: tile-service verify merge <region>
 
   Verify Upload:
-    Usage: tile-service verify upload <region> [--samples-per-zoom N]
+    Usage: tile-service verify upload <region> [--samples-per-zoom N] [--seed N] [--full]
 
     Options:
       -samples-per-zoom int Number of tiles to spot-check per zoom level (default 5)
+      -seed int             Seed for choosing the sample (default: random). The seed
+                            and every sampled key are printed; the same seed and
+                            tiles directory always check the same tiles
+      -full                 Check every local tile instead of a sample, using one
+                            paginated listing of R2 rather than a request per tile.
+                            Also reports orphaned tiles on R2 with no local
+                            counterpart; since regions share the prefix, use the
+                            merged directory ("merged") for a meaningful list
 
   Description:
     Exits 0 if verification passes, 1 if issues are found.
 
+Report Command:
+  Usage: tile-service report [options] <region>
+
+  Options:
+    -kml string           KML file (default: extracted from the region's KMZ in
+                          CURVATURE_DATA_DIR)
+    -geojson string       GeoJSON file (default: the newest intermediate GeoJSON
+                          left by generate -no-cleanup, else the KML converted
+                          again)
+    -tiles string         Tile directory or .mbtiles file (default OUTPUT_DIR/{region})
+    -json                 Print the report as JSON
+
+  Description:
+    Reports a region's data lineage in one place: KML folders, placemarks and
+    coordinate points; GeoJSON features and coordinate points; and tiles,
+    features, coordinate points and unique road IDs of the roads layer per
+    zoom. Divergences the pipeline does not explain are flagged: roads lost
+    in conversion, coordinate points changed by conversion, zoom levels with
+    no tiles, roads missing at the max zoom, and more than 10% of coordinate
+    points lost at the max zoom.
+
+Stats Command:
+  Usage: tile-service stats
+
+  Description:
+    Prints how many road geometries the database holds for each region,
+    sorted by region, and the total across all regions. Regions with none
+    are not listed.
+
 Serve Command:
   Usage: tile-service serve [options]
 
   Options:
     -port int             Port to listen on (default 8080)
+    -pprof-addr string    Expose net/http/pprof on this address (e.g., localhost:6060)
 
   Description:
     Starts the REST API server for tile generation.
 
+    On SIGINT or SIGTERM the server stops accepting requests and jobs and waits
+    up to SHUTDOWN_TIMEOUT_SECONDS (default 300) for the running job to finish.
+    Queued jobs, and a running job that outlasts the timeout or a second signal,
+    are cancelled and marked cancelled in the database.
+
     API Endpoints:
       POST   /api/generate          - Submit a new tile generation job
       GET    /api/jobs              - List all active jobs
+      GET    /api/jobs?status=pending - Page through pending jobs, oldest first
       GET    /api/jobs/{jobId}      - Get status of a specific job
       GET    /api/stream/{jobId}    - Stream real-time job updates (SSE)
+      GET    /api/roads/nearby      - Up to ?limit= roads near ?lat=&lng= within ?radius= meters
       GET    /health                - Health check endpoint
 
 Examples:
@@ -968,6 +2045,24 @@ Examples:
   # Generate tiles with minimal zoom levels for debugging
   ./tile-service generate -max-zoom 7 -skip-upload -no-cleanup maryland
 
+  # Generate without Tippecanoe installed (small regions, CI)
+  ./tile-service generate -encoder go -max-zoom 10 -skip-upload -skip-merge test-region
+
+  # Only tile the parts of roads inside a state outline
+  ./tile-service generate -clip oregon-outline.geojson oregon
+
+  # Estimate how large a region's tiles will be without keeping them
+  ./tile-service generate -stats-only -max-zoom 14 california
+
+  # Thin out dense urban tiles, checking the effect without keeping them
+  ./tile-service generate -stats-only -max-tile-features 50000 -max-tile-bytes 300000 new-jersey
+
+  # Reproduce an earlier run from its recorded job definition
+  ./tile-service generate -from-job ~/data/df/tiles/oregon/.job-cli-20260114T093000-1a2b3c4d.json
+
+  # Regenerate zooms 10-14 while the current tiles stay servable
+  ./tile-service generate -atomic-swap -min-zoom 10 -max-zoom 14 oregon
+
   # Batch generate multiple regions with 4 parallel workers (no upload/merge)
   ./tile-service generate -workers 4 -skip-upload -skip-merge washington oregon california idaho
 
@@ -975,6 +2070,9 @@ Examples:
   ./tile-service generate -workers 4 -skip-upload -skip-merge alabama alaska arizona ...
   ./tile-service merge
 
+  # Stop starting new regions as soon as one fails
+  ./tile-service generate -workers 4 -keep-going=false washington oregon idaho
+
   # Upload pre-generated tiles
   ./tile-service upload ~/data/df/tiles/oregon
 
@@ -984,8 +2082,33 @@ Examples:
   # Upload zoom levels 7-12
   ./tile-service upload -min-zoom 7 -max-zoom 12 ~/data/df/tiles/oregon
 
+  # Re-upload only what changed since the last upload, deleting removed tiles
+  ./tile-service upload -sync ~/data/df/tiles/oregon
+
+  # Upload fewer files at once over a slow link
+  ./tile-service upload -workers 8 ~/data/df/tiles/oregon
+
   # Extract road geometries from existing tiles
   ./tile-service extract ~/data/df/tiles/oregon
+  ./tile-service extract -mbtiles ~/data/df/oregon.mbtiles
+
+  # Also write the bounding boxes as GeoJSON to check them in QGIS
+  ./tile-service extract -geojson oregon-bounds.geojson ~/data/df/tiles/oregon
+
+  # Quick check of the bounds logic on the first 200 tiles
+  ./tile-service extract -max-tiles 200 -geojson sample.geojson ~/data/df/tiles/oregon
+
+  # Extract only from the tile (or z/x column) a problem road is in
+  ./tile-service extract -tile 12/650/1425 -geojson road.geojson ~/data/df/tiles/oregon
+  ./tile-service extract -subtree 12/650 ~/data/df/tiles/oregon
+
+  # Extract overlapping regions into one row per road
+  ./tile-service extract -dedup-global ~/data/df/tiles/oregon
+  ./tile-service extract -dedup-global ~/data/df/tiles/washington
+
+  # Convert curvature data to GeoJSON without generating tiles
+  ./tile-service convert oregon.kml oregon.geojson
+  ./tile-service convert ~/data/df/curvature/us-oregon.c_1000.curves.kmz oregon.geojson
 
   # Generate tiles and extract geometries to file (don't insert yet)
   ./tile-service generate -skip-upload -skip-geometry-insertion florida
@@ -995,6 +2118,14 @@ Examples:
   # or
   ./tile-service insert-geometries .extracted-roads-florida.json
 
+  # Insert, then confirm the database matches the extraction file
+  ./tile-service insert-geometries -keep-file florida
+  ./tile-service reconcile florida
+
+  # Dump a tile as GeoJSON for debugging
+  ./tile-service inspect-tile ~/data/df/tiles/oregon/10/163/357.pbf > tile.geojson
+  ./tile-service inspect-tile -mbtiles ~/data/df/oregon.mbtiles 10/163/357
+
   # Generate tiles without geometry extraction
   ./tile-service generate -extract-geometry=false washington
 
@@ -1016,17 +2147,51 @@ Examples:
   # Start the REST API server on a custom port
   ./tile-service serve -port 3000
 
+  # Pull production tiles down for debugging
+  ./tile-service download oregon /tmp/oregon-r2
+
+  # Download over a flaky connection with fewer, more patient workers
+  ./tile-service download -workers 8 -retries 6 oregon /tmp/oregon-r2
+
+  # See which R2 tiles no region has any more, then delete them
+  ./tile-service prune -dry-run
+  ./tile-service prune ~/data/df/tiles
+
+  # Regenerate only the zoom levels verify finds missing, from a GeoJSON kept
+  # by an earlier generate -no-cleanup
+  ./tile-service repair-zooms -geojson /tmp/arkansas_roads-1234567890.geojson arkansas
+
   # Verify tiles have all expected zoom levels
   ./tile-service verify tiles ~/data/df/tiles/arkansas --min-zoom 0 --max-zoom 16
 
+  # Also flag partially generated zooms (at least 20 tiles, 500 at zoom 14)
+  ./tile-service verify tiles ~/data/df/tiles/arkansas --min-tiles 20 --min-tiles-zoom 14=500
+
   # Verify merge completeness for a region
   ./tile-service verify merge arkansas
 
   # Spot-check uploaded tiles on R2
   ./tile-service verify upload arkansas --samples-per-zoom 10
 
+  # Check every merged tile is on R2 and list remote tiles with no local copy
+  ./tile-service verify upload merged --full
+
+  # Repeat a failed CI upload check with the exact same sample
+  ./tile-service verify upload arkansas --seed 1718035200123456789
+
+  # Trace where a region's roads or coordinate points go missing
+  ./tile-service report arkansas
+  ./tile-service report -geojson /tmp/arkansas_roads-1234567890.geojson -tiles arkansas.mbtiles arkansas
+
+  # Road geometries in the database per region
+  ./tile-service stats
+
   # Debug mode
   ./tile-service -debug generate -max-zoom 8 washington
+
+  # Profile a generation run
+  ./tile-service -cpuprofile cpu.prof -memprofile mem.prof generate -skip-upload oregon
+  go tool pprof cpu.prof
 `
 	fmt.Print(help)
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ManifestFileName is the name used for a region's manifest in R2
+const ManifestFileName = "manifest.json"
+
+// ManifestEntry describes one generated file
+type ManifestEntry struct {
+	Path   string `json:"path"` // Slash-separated path relative to the tiles directory
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BuildFileManifest hashes every file under tilesDir in parallel and returns
+// the entries sorted by path. The incomplete-generation marker and any
+// existing manifest are skipped.
+func BuildFileManifest(tilesDir string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := filepath.Walk(tilesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if info.Name() == IncompleteSentinel || info.Name() == ManifestFileName {
+			return nil
+		}
+		relPath, err := filepath.Rel(tilesDir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ManifestEntry{Path: filepath.ToSlash(relPath), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tiles directory: %w", err)
+	}
+
+	// Hash files in parallel; each worker fills in its own entries by index
+	numWorkers := runtime.NumCPU()
+	workChan := make(chan int)
+	errChan := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range workChan {
+				sum, err := hashFile(filepath.Join(tilesDir, filepath.FromSlash(entries[idx].Path)))
+				if err != nil {
+					select {
+					case errChan <- err:
+					default:
+					}
+					continue
+				}
+				entries[idx].SHA256 = sum
+			}
+		}()
+	}
+
+	for i := range entries {
+		workChan <- i
+	}
+	close(workChan)
+	wg.Wait()
+	close(errChan)
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// WriteFileManifest writes a JSON manifest of every file under tilesDir to out
+func WriteFileManifest(tilesDir string, out string) error {
+	entries, err := BuildFileManifest(tilesDir)
+	if err != nil {
+		return err
+	}
+	if err := writeManifestEntries(entries, out); err != nil {
+		return err
+	}
+
+	slog.Info("file manifest written", "path", out, "files", len(entries))
+	return nil
+}
+
+// writeManifestEntries writes entries to out as an indented JSON array
+func writeManifestEntries(entries []ManifestEntry, out string) error {
+	if entries == nil {
+		entries = []ManifestEntry{}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// manifestKey returns the R2 key of a region's manifest
+func (s *TileService) manifestKey(region string) string {
+	return filepath.ToSlash(filepath.Join(s.config.S3.BucketPath, "manifests", region, ManifestFileName))
+}
+
+// UploadManifest builds a manifest for tilesDir and uploads it to
+// <bucket_path>/manifests/<region>/manifest.json
+func (s *TileService) UploadManifest(ctx context.Context, tilesDir, region string) error {
+	entries, err := BuildFileManifest(tilesDir)
+	if err != nil {
+		return err
+	}
+	return s.uploadManifestEntries(ctx, entries, region)
+}
+
+// uploadManifestEntries uploads entries as the region's manifest, staged in
+// the configured temp directory
+func (s *TileService) uploadManifestEntries(ctx context.Context, entries []ManifestEntry, region string) error {
+	tmp, err := os.CreateTemp(s.config.Paths.TempDir, "manifest-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create manifest file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := writeManifestEntries(entries, tmp.Name()); err != nil {
+		return err
+	}
+
+	key := s.manifestKey(region)
+	if _, err := s.s3.UploadFileWithOptions(ctx, tmp.Name(), key, jsonUploadOptions(s.config.S3)); err != nil {
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+
+	slog.Info("manifest uploaded", "region", region, "s3_key", key, "files", len(entries))
+	return nil
+}
+
+// FetchManifest downloads the region's manifest from R2. A region that has
+// never been uploaded with a manifest returns nil entries and no error.
+func (s *TileService) FetchManifest(ctx context.Context, region string) ([]ManifestEntry, bool, error) {
+	data, err := s.s3.GetObject(ctx, s.manifestKey(region))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var entries []ManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, false, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return entries, true, nil
+}
+
+// FetchAllManifests downloads the manifest of every region uploaded with one,
+// keyed by region
+func (s *TileService) FetchAllManifests(ctx context.Context) (map[string][]ManifestEntry, error) {
+	prefix := path.Dir(path.Dir(s.manifestKey("region"))) + "/"
+	keys, err := s.s3.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list manifests: %w", err)
+	}
+
+	manifests := make(map[string][]ManifestEntry)
+	for _, key := range keys {
+		region, name := path.Split(strings.TrimPrefix(key, prefix))
+		region = strings.TrimSuffix(region, "/")
+		if name != ManifestFileName || region == "" || strings.Contains(region, "/") {
+			continue
+		}
+		entries, found, err := s.FetchManifest(ctx, region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s manifest: %w", region, err)
+		}
+		if found {
+			manifests[region] = entries
+		}
+	}
+	return manifests, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
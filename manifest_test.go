@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileManifest(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"5/10/20.pbf":   "tile-a",
+		"5/10/21.pbf":   "tile-b",
+		"7/30/40.pbf":   "tile-a",
+		"metadata.json": `{"name":"test"}`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The incomplete marker is not a generated file
+	if err := os.WriteFile(filepath.Join(dir, IncompleteSentinel), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteFileManifest(dir, out); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+
+	if len(entries) != len(files) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(files), len(entries), entries)
+	}
+	for i, e := range entries {
+		if i > 0 && entries[i-1].Path >= e.Path {
+			t.Errorf("entries not sorted: %s before %s", entries[i-1].Path, e.Path)
+		}
+		content, ok := files[e.Path]
+		if !ok {
+			t.Errorf("unexpected entry %s", e.Path)
+			continue
+		}
+		if e.Size != int64(len(content)) {
+			t.Errorf("%s: expected size %d, got %d", e.Path, len(content), e.Size)
+		}
+		sum := sha256.Sum256([]byte(content))
+		if e.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: wrong sha256 %s", e.Path, e.SHA256)
+		}
+	}
+}
+
+func TestWriteFileManifest_EmptyDir(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteFileManifest(t.TempDir(), out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("expected empty JSON array, got %s", data)
+	}
+}
+
+func TestWriteFileManifest_MissingDir(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteFileManifest(filepath.Join(t.TempDir(), "missing"), out); err == nil {
+		t.Fatal("expected error for missing tiles directory")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/paulmach/orb/maptile"
+)
+
+// MBTilesSource reads tiles from an MBTiles (SQLite) file. MBTiles stores
+// rows in the TMS scheme, so tile_row is flipped to and from XYZ here.
+type MBTilesSource struct {
+	path string
+	conn *sql.DB
+}
+
+// errNoSQLite is returned when opening MBTiles in a build without cgo
+var errNoSQLite = errors.New("this build has no SQLite support (rebuild with CGO_ENABLED=1)")
+
+// OpenMBTiles opens an MBTiles file read-only
+func OpenMBTiles(path string) (*MBTilesSource, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open mbtiles: %w", err)
+	}
+	if !sqliteAvailable {
+		return nil, fmt.Errorf("failed to open mbtiles: %w", errNoSQLite)
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mbtiles: %w", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open mbtiles: %w", err)
+	}
+
+	return &MBTilesSource{path: path, conn: conn}, nil
+}
+
+// Tiles lists every tile address in XYZ, ordered by zoom, column and row
+func (s *MBTilesSource) Tiles(ctx context.Context) ([]maptile.Tile, error) {
+	rows, err := s.conn.QueryContext(ctx,
+		`SELECT zoom_level, tile_column, tile_row FROM tiles ORDER BY zoom_level, tile_column, tile_row`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tiles: %w", err)
+	}
+	defer rows.Close()
+
+	var tiles []maptile.Tile
+	for rows.Next() {
+		var z, x, row uint32
+		if err := rows.Scan(&z, &x, &row); err != nil {
+			return nil, fmt.Errorf("failed to scan tile: %w", err)
+		}
+		zoom := maptile.Zoom(z)
+		tiles = append(tiles, maptile.New(x, FlipY(row, zoom), zoom))
+	}
+	return tiles, rows.Err()
+}
+
+// ReadTile returns the data of an XYZ-addressed tile
+func (s *MBTilesSource) ReadTile(ctx context.Context, tile maptile.Tile) ([]byte, error) {
+	var data []byte
+	err := s.conn.QueryRowContext(ctx,
+		`SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?`,
+		uint32(tile.Z), tile.X, FlipY(tile.Y, tile.Z),
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("tile %s not found in %s", tileKey(tile), s.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile: %w", err)
+	}
+	return data, nil
+}
+
+// Close closes the underlying SQLite connection
+func (s *MBTilesSource) Close() error {
+	return s.conn.Close()
+}
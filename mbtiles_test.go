@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb/maptile"
+)
+
+// requireSQLite skips tests that need the cgo SQLite driver
+func requireSQLite(t *testing.T) {
+	t.Helper()
+	if !sqliteAvailable {
+		t.Skip("SQLite needs a cgo build")
+	}
+}
+
+// writeFixtureMBTiles writes tiles into a new .mbtiles file, storing each
+// XYZ tile at its TMS tile_row like Tippecanoe does
+func writeFixtureMBTiles(t *testing.T, path string, tiles map[maptile.Tile][]byte) {
+	t.Helper()
+	requireSQLite(t)
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stmts := []string{
+		`CREATE TABLE metadata (name text, value text)`,
+		`CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)`,
+		`CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)`,
+		`INSERT INTO metadata (name, value) VALUES ('format', 'pbf')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for tile, data := range tiles {
+		row := (1 << tile.Z) - 1 - tile.Y
+		if _, err := conn.Exec(`INSERT INTO tiles VALUES (?, ?, ?, ?)`, uint32(tile.Z), tile.X, row, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// gzipBytes gzips data the way Tippecanoe stores MBTiles tile_data
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fixtureMBTiles stores the Seattle fixture tile (gzipped) in an .mbtiles file
+func fixtureMBTiles(t *testing.T) (string, maptile.Tile) {
+	t.Helper()
+	dir := t.TempDir()
+	tilePath, tile, _ := writeFixtureTile(t, dir)
+	data, err := os.ReadFile(tilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "seattle.mbtiles")
+	writeFixtureMBTiles(t, path, map[maptile.Tile][]byte{tile: gzipBytes(t, data)})
+	return path, tile
+}
+
+func TestMBTilesSource_FlipsTMSRows(t *testing.T) {
+	tests := []struct {
+		tile maptile.Tile
+		row  uint32
+	}{
+		{maptile.New(0, 0, 0), 0},
+		{maptile.New(1, 0, 1), 1},
+		{maptile.New(163, 357, 10), 666},
+		{maptile.New(655, 1430, 12), 2665},
+	}
+
+	tiles := make(map[maptile.Tile][]byte)
+	for _, tc := range tests {
+		if got := FlipY(tc.tile.Y, tc.tile.Z); got != tc.row {
+			t.Errorf("FlipY(%d, %d) = %d, want %d", tc.tile.Y, tc.tile.Z, got, tc.row)
+		}
+		tiles[tc.tile] = []byte(tileKey(tc.tile))
+	}
+
+	path := filepath.Join(t.TempDir(), "flip.mbtiles")
+	writeFixtureMBTiles(t, path, tiles)
+
+	src, err := OpenMBTiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	ctx := context.Background()
+
+	listed, err := src.Tiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(tests) {
+		t.Fatalf("listed %d tiles, want %d", len(listed), len(tests))
+	}
+	for _, tile := range listed {
+		if _, ok := tiles[tile]; !ok {
+			t.Errorf("listed tile %s was not written (y not flipped?)", tileKey(tile))
+		}
+	}
+
+	for _, tc := range tests {
+		data, err := src.ReadTile(ctx, tc.tile)
+		if err != nil {
+			t.Errorf("ReadTile(%s): %v", tileKey(tc.tile), err)
+			continue
+		}
+		if string(data) != tileKey(tc.tile) {
+			t.Errorf("ReadTile(%s) = %q", tileKey(tc.tile), data)
+		}
+	}
+
+	// The TMS row is not an XYZ address
+	if _, err := src.ReadTile(ctx, maptile.New(163, 666, 10)); err == nil {
+		t.Error("expected reading the unflipped row to fail")
+	}
+}
+
+func TestOpenMBTiles_Missing(t *testing.T) {
+	if _, err := OpenMBTiles(filepath.Join(t.TempDir(), "missing.mbtiles")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestOpenMBTiles_WithoutSQLite(t *testing.T) {
+	if sqliteAvailable {
+		t.Skip("this build has SQLite")
+	}
+	path := filepath.Join(t.TempDir(), "oregon.mbtiles")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMBTiles(path); !errors.Is(err, errNoSQLite) {
+		t.Errorf("expected errNoSQLite, got %v", err)
+	}
+}
+
+func TestExtractRoadGeometriesFromMBTiles(t *testing.T) {
+	path, _ := fixtureMBTiles(t)
+	t.Chdir(t.TempDir())
+
+	src, err := OpenMBTiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	roads, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(context.Background(), src, "seattle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roads) != 1 {
+		t.Fatalf("expected 1 road, got %d", len(roads))
+	}
+
+	// Reading the tile at the wrong (unflipped) row would land far from Seattle
+	r := roads[0]
+	if r.RoadID != "road-7" {
+		t.Errorf("unexpected road id %q", r.RoadID)
+	}
+	if r.MinLat < 47.59 || r.MaxLat > 47.62 || r.MinLng < -122.35 || r.MaxLng > -122.31 {
+		t.Errorf("bounds not around Seattle: lat %f..%f lng %f..%f", r.MinLat, r.MaxLat, r.MinLng, r.MaxLng)
+	}
+}
+
+func TestExtractRoadGeometriesFromExistingTiles_MBTilesPath(t *testing.T) {
+	path, _ := fixtureMBTiles(t)
+	t.Chdir(t.TempDir())
+
+	// TILE_SCHEME only describes directories; MBTiles rows are always TMS
+	service := NewTileService(nil, nil, &Config{Paths: PathsConfig{TileScheme: SchemeTMS}})
+	summary, err := service.ExtractRoadGeometriesFromExistingTiles(context.Background(), path, "seattle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TilesProcessed != 1 || summary.RoadsExtracted != 1 {
+		t.Errorf("expected the mbtiles tile and road to be read, got %+v", summary)
+	}
+
+	roads, summary, err := NewGeometryExtractor().ExtractRoadGeometriesFromTiles(context.Background(), path, "seattle-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roads) != 1 || roads[0].MinLat < 47.59 || roads[0].MaxLat > 47.62 {
+		t.Errorf("expected the Seattle road from the mbtiles file, got %+v", roads)
+	}
+	if summary.Region != "seattle-2" || summary.TilesTotal != 1 || summary.TilesProcessed != 1 || summary.RoadsExtracted != 1 {
+		t.Errorf("expected the summary to describe the mbtiles run, got %+v", summary)
+	}
+}
+
+func TestReadInspectTile_MBTiles(t *testing.T) {
+	path, tile := fixtureMBTiles(t)
+
+	data, err := readInspectTile(context.Background(), tileKey(tile), path, SchemeXYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := InspectTile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].Name != "roads" || len(layers[0].Features) != 1 {
+		t.Errorf("unexpected layers %+v", layers)
+	}
+
+	if _, err := readInspectTile(context.Background(), "not-a-tile", path, SchemeXYZ); err == nil {
+		t.Error("expected an error for an invalid tile address")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MetadataFileName is the tileset metadata Tippecanoe writes at the root of
+// a tiles directory
+const MetadataFileName = "metadata.json"
+
+// Defaults for the region JSON objects (metadata.json and the manifest).
+// They are fetched by browsers on other origins, so they are public and
+// cached only briefly: a regenerated region shows up within minutes, unlike
+// the .pbf tiles. CORS headers themselves come from the bucket's CORS policy.
+const (
+	DefaultMetadataACL          = string(types.ObjectCannedACLPublicRead)
+	DefaultMetadataCacheControl = "public, max-age=300"
+)
+
+// MetadataKey returns the R2 key of a region's metadata.json:
+// <bucket_path>/<region>/metadata.json
+func MetadataKey(bucketPath, region string) string {
+	return filepath.ToSlash(filepath.Join(bucketPath, region, MetadataFileName))
+}
+
+// jsonUploadOptions returns the upload headers for a region's JSON objects
+func jsonUploadOptions(cfg S3Config) UploadOptions {
+	opts := UploadOptions{
+		ACL:          types.ObjectCannedACL(cfg.MetadataACL),
+		ContentType:  "application/json",
+		CacheControl: cfg.MetadataCacheControl,
+	}
+	if opts.ACL == "" {
+		opts.ACL = types.ObjectCannedACL(DefaultMetadataACL)
+	}
+	if opts.CacheControl == "" {
+		opts.CacheControl = DefaultMetadataCacheControl
+	}
+	return opts
+}
+
+// UploadMetadata uploads the metadata.json in tilesDir to the region's
+// metadata key. A tiles directory without one is not an error; 0 bytes are
+// reported.
+func (s *TileService) UploadMetadata(ctx context.Context, tilesDir, region string) (int64, error) {
+	path := filepath.Join(tilesDir, MetadataFileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		slog.Debug("no tileset metadata to upload", "tiles_dir", tilesDir)
+		return 0, nil
+	}
+
+	key := MetadataKey(s.config.S3.BucketPath, region)
+	size, err := s.s3.UploadFileWithOptions(ctx, path, key, jsonUploadOptions(s.config.S3))
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload metadata: %w", err)
+	}
+
+	slog.Info("metadata uploaded", "region", region, "s3_key", key)
+	return size, nil
+}
+
+// validateCannedACL reports whether acl is one of the S3 canned ACLs
+func validateCannedACL(acl string) error {
+	if acl == "" || slices.Contains(types.ObjectCannedACL("").Values(), types.ObjectCannedACL(acl)) {
+		return nil
+	}
+	return fmt.Errorf("unknown canned ACL %q", acl)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTilesWithMetadata writes a one-tile directory with a metadata.json
+func writeTilesWithMetadata(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	createFakeTile(t, dir, 5, 1, 1)
+	if err := os.WriteFile(filepath.Join(dir, MetadataFileName), []byte(`{"name":"washington"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestUploadToR2_Metadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       S3Config
+		wantACL   string
+		wantCache string
+	}{
+		{"defaults", S3Config{BucketPath: "tiles"}, "public-read", DefaultMetadataCacheControl},
+		{"configured", S3Config{BucketPath: "tiles", MetadataACL: "private", MetadataCacheControl: "no-cache"}, "private", "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTilesWithMetadata(t)
+			fake, client := newFakeS3(t)
+			service := NewTileService(nil, client, &Config{S3: tt.cfg})
+
+			if _, err := service.UploadToR2(context.Background(), dir, "washington"); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := fake.puts["tiles/metadata.json"]; ok {
+				t.Error("expected metadata.json to stay out of the shared tiles prefix")
+			}
+			h, ok := fake.headers["tiles/washington/metadata.json"]
+			if !ok {
+				t.Fatalf("expected metadata at tiles/washington/metadata.json, got %v", fake.puts)
+			}
+			if got := h.Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %q", got)
+			}
+			if got := h.Get("X-Amz-Acl"); got != tt.wantACL {
+				t.Errorf("expected ACL %q, got %q", tt.wantACL, got)
+			}
+			if got := h.Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wantCache, got)
+			}
+
+			// Tiles keep their own public-read headers, whatever the metadata settings
+			tile := fake.headers["tiles/5/1/1.pbf"]
+			if tile == nil || tile.Get("X-Amz-Acl") != "public-read" || tile.Get("Cache-Control") != DefaultObjectHeaders[".pbf"].CacheControl {
+				t.Errorf("unexpected tile upload headers %v", tile)
+			}
+		})
+	}
+}
+
+func TestUploadDirectory_LogsSkippedMetadata(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	dir := writeTilesWithMetadata(t)
+	fake, client := newFakeS3(t)
+	if _, err := client.uploadDirectory(context.Background(), dir, "tiles"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.puts["tiles/metadata.json"]; ok {
+		t.Error("expected metadata.json to be skipped")
+	}
+	if !strings.Contains(logs.String(), "skipping tileset metadata") {
+		t.Errorf("expected the skipped metadata.json to be logged, got:\n%s", logs.String())
+	}
+}
+
+func TestUploadManifest_JSONHeaders(t *testing.T) {
+	dir := writeTilesWithMetadata(t)
+	fake, client := newFakeS3(t)
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+
+	if err := service.UploadManifest(context.Background(), dir, "washington"); err != nil {
+		t.Fatal(err)
+	}
+
+	h, ok := fake.headers["tiles/manifests/washington/manifest.json"]
+	if !ok {
+		t.Fatalf("expected the manifest upload, got %v", fake.puts)
+	}
+	if h.Get("Content-Type") != "application/json" || h.Get("X-Amz-Acl") != "public-read" || h.Get("Cache-Control") != DefaultMetadataCacheControl {
+		t.Errorf("unexpected manifest upload headers %v", h)
+	}
+}
+
+func TestUploadManifest_UsesTempDir(t *testing.T) {
+	dir := writeTilesWithMetadata(t)
+	_, client := newFakeS3(t)
+	tempDir := t.TempDir()
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}, Paths: PathsConfig{TempDir: tempDir}})
+
+	if err := service.UploadManifest(context.Background(), dir, "washington"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Errorf("expected the staged manifest to be removed from TEMP_DIR, got %v (%v)", entries, err)
+	}
+
+	// A TEMP_DIR that does not exist fails rather than falling back to /tmp
+	service.config.Paths.TempDir = filepath.Join(tempDir, "missing")
+	if err := service.UploadManifest(context.Background(), dir, "washington"); err == nil || !strings.Contains(err.Error(), "failed to create manifest file") {
+		t.Errorf("expected the manifest to be staged in TEMP_DIR, got %v", err)
+	}
+}
+
+func TestUploadMetadata_Missing(t *testing.T) {
+	fake, client := newFakeS3(t)
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+
+	size, err := service.UploadMetadata(context.Background(), t.TempDir(), "washington")
+	if err != nil || size != 0 || len(fake.puts) != 0 {
+		t.Errorf("expected nothing uploaded without metadata.json, got %d bytes, %v (%v)", size, fake.puts, err)
+	}
+}
+
+func TestValidateCannedACL(t *testing.T) {
+	tests := []struct {
+		acl     string
+		wantErr bool
+	}{
+		{"", false},
+		{"public-read", false},
+		{"private", false},
+		{"public", true},
+	}
+	for _, tt := range tests {
+		if err := validateCannedACL(tt.acl); (err != nil) != tt.wantErr {
+			t.Errorf("validateCannedACL(%q) error = %v, wantErr %v", tt.acl, err, tt.wantErr)
+		}
+	}
+}
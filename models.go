@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/paulmach/orb"
+)
 
 // TileJob represents a tile generation job
 type TileJob struct {
@@ -81,13 +85,21 @@ type ProcessingResult struct {
 
 // JobOptions represents optional configuration for tile generation
 type JobOptions struct {
-	MaxZoom               int
-	MinZoom               int
-	SkipUpload            bool
-	SkipGeneration        bool // Skip tile generation, only upload existing tiles
-	SkipMerge             bool // Skip merging with other regions (useful for batch processing)
-	NoCleanup             bool
-	ExtractGeometry       bool // Extract road geometries into database for nearby roads feature
-	SkipGeometryInsertion bool // Extract to file but don't insert into database
-	MergeAll              bool // Merge all regions instead of just overlapping neighbors
+	MaxZoom               int              `json:"maxZoom"`
+	MinZoom               int              `json:"minZoom"`
+	SkipUpload            bool             `json:"skipUpload"`
+	SkipGeneration        bool             `json:"skipGeneration"` // Skip tile generation, only upload existing tiles
+	SkipMerge             bool             `json:"skipMerge"`      // Skip merging with other regions (useful for batch processing)
+	NoCleanup             bool             `json:"noCleanup"`
+	ExtractGeometry       bool             `json:"extractGeometry"`           // Extract road geometries into database for nearby roads feature
+	SkipGeometryInsertion bool             `json:"skipGeometryInsertion"`     // Extract to file but don't insert into database
+	MergeAll              bool             `json:"mergeAll"`                  // Merge all regions instead of just overlapping neighbors
+	Encoder               string           `json:"encoder,omitempty"`         // Tile encoder: EncoderTippecanoe (default) or EncoderGo
+	Clip                  orb.MultiPolygon `json:"clip,omitempty"`            // Clip roads to this boundary during conversion (nil = no clipping)
+	PreserveAltitude      bool             `json:"preserveAltitude"`          // Keep KML altitude as a third GeoJSON coordinate
+	Bounds                *orb.Bound       `json:"bounds,omitempty"`          // Region bounds, overriding REGION_BOUNDS (nil = use config)
+	MaxTileFeatures       int              `json:"maxTileFeatures,omitempty"` // Tippecanoe --maximum-tile-features (0 = Tippecanoe default)
+	MaxTileBytes          int              `json:"maxTileBytes,omitempty"`    // Tippecanoe --maximum-tile-bytes (0 = Tippecanoe default)
+	IDAttribute           string           `json:"idAttribute,omitempty"`     // Property promoted to the MVT feature ID ("" = none)
+	AtomicSwap            bool             `json:"atomicSwap,omitempty"`      // Generate beside the old tiles and swap them in on success
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ObjectHeaders are the HTTP headers an uploaded file is served with
+type ObjectHeaders struct {
+	ContentType  string // "" = left to R2
+	CacheControl string // "" = none
+}
+
+// DefaultTileCacheControl is the Cache-Control of uploaded tiles. Tile URLs
+// are not versioned, so a regenerated region overwrites them in place; an
+// hour keeps CDNs and browsers from serving stale tiles for long. Setups that
+// version their tile paths can opt into "immutable" with S3_CACHE_CONTROL.
+const DefaultTileCacheControl = "public, max-age=3600"
+
+// DefaultObjectHeaders are the headers of uploaded files by extension
+var DefaultObjectHeaders = map[string]ObjectHeaders{
+	".pbf": {ContentType: "application/x-protobuf", CacheControl: DefaultTileCacheControl},
+	".mvt": {ContentType: "application/vnd.mapbox-vector-tile", CacheControl: DefaultTileCacheControl},
+}
+
+// parseObjectHeaders returns DefaultObjectHeaders with the overrides in
+// contentTypes and cacheControl applied. Each is a ';'-separated list of
+// .ext=value entries, e.g. ".pbf=application/vnd.mapbox-vector-tile"; an
+// empty value removes the header for that extension.
+func parseObjectHeaders(contentTypes, cacheControl string) (map[string]ObjectHeaders, error) {
+	headers := maps.Clone(DefaultObjectHeaders)
+	set := func(value string, apply func(h *ObjectHeaders, v string)) error {
+		for _, entry := range strings.Split(value, ";") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			ext, v, ok := strings.Cut(entry, "=")
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if !ok || len(ext) < 2 || !strings.HasPrefix(ext, ".") {
+				return fmt.Errorf("%q is not .ext=value", entry)
+			}
+			h := headers[ext]
+			apply(&h, strings.TrimSpace(v))
+			headers[ext] = h
+		}
+		return nil
+	}
+
+	if err := set(contentTypes, func(h *ObjectHeaders, v string) { h.ContentType = v }); err != nil {
+		return nil, err
+	}
+	if err := set(cacheControl, func(h *ObjectHeaders, v string) { h.CacheControl = v }); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// objectHeaders returns the headers for an uploaded file, by its extension
+func (s *S3Client) objectHeaders(filePath string) (ObjectHeaders, bool) {
+	headers := s.Headers
+	if headers == nil {
+		headers = DefaultObjectHeaders
+	}
+	h, ok := headers[strings.ToLower(filepath.Ext(filePath))]
+	return h, ok
+}
+
+// isGzipped reports whether f starts with the gzip magic number, without
+// moving its offset
+func isGzipped(f *os.File) bool {
+	magic := make([]byte, 2)
+	n, _ := f.ReadAt(magic, 0)
+	return bytes.Equal(magic[:n], []byte{0x1f, 0x8b})
+}
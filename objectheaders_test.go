@@ -0,0 +1,149 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// recordingUploader keeps the last upload request
+type recordingUploader struct {
+	input *s3.PutObjectInput
+}
+
+func (u *recordingUploader) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	u.input = input
+	return &manager.UploadOutput{Key: input.Key}, nil
+}
+
+func TestPutObjectInput_Headers(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.pbf")
+	if err := os.WriteFile(plain, []byte{0x1a, 0x02}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	gzipped := filepath.Join(dir, "gzipped.pbf")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte{0x1a, 0x02})
+	zw.Close()
+	f.Close()
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, []byte{0x1f, 0x8b}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const tileCache = "public, max-age=3600"
+	tests := []struct {
+		name         string
+		path         string
+		headers      map[string]ObjectHeaders
+		opts         UploadOptions
+		wantType     string
+		wantEncoding string
+		wantCache    string
+	}{
+		{"tile", plain, nil, UploadOptions{}, "application/x-protobuf", "", tileCache},
+		{"gzipped tile", gzipped, nil, UploadOptions{}, "application/x-protobuf", "gzip", tileCache},
+		{"other extension", other, nil, UploadOptions{}, "", "", ""},
+		{"configured headers", plain, map[string]ObjectHeaders{".pbf": {ContentType: "application/vnd.mapbox-vector-tile", CacheControl: "no-cache"}}, UploadOptions{}, "application/vnd.mapbox-vector-tile", "", "no-cache"},
+		{"options take precedence", plain, nil, UploadOptions{CacheControl: "public, max-age=60"}, "application/x-protobuf", "", "public, max-age=60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploader := &recordingUploader{}
+			client := &S3Client{bucket: "bucket", uploader: uploader, Headers: tt.headers}
+			if _, err := client.UploadFileWithOptions(context.Background(), tt.path, "tiles/5/1/1.pbf", tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			input := uploader.input
+			if got := aws.ToString(input.ContentType); got != tt.wantType {
+				t.Errorf("expected Content-Type %q, got %q", tt.wantType, got)
+			}
+			if got := aws.ToString(input.ContentEncoding); got != tt.wantEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if got := aws.ToString(input.CacheControl); got != tt.wantCache {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wantCache, got)
+			}
+			if input.ACL != types.ObjectCannedACLPublicRead || aws.ToString(input.Key) != "tiles/5/1/1.pbf" {
+				t.Errorf("expected a public-read upload to the tile key, got ACL %q key %q", input.ACL, aws.ToString(input.Key))
+			}
+		})
+	}
+}
+
+func TestUploadDirectory_TileHeaders(t *testing.T) {
+	dir := t.TempDir()
+	writeTileFiles(t, dir, 1)
+
+	uploader := &recordingUploader{}
+	client := &S3Client{bucket: "bucket", uploader: uploader}
+	if _, err := client.UploadDirectory(context.Background(), dir, "tiles"); err != nil {
+		t.Fatal(err)
+	}
+	if uploader.input == nil {
+		t.Fatal("expected a tile upload")
+	}
+	if got := aws.ToString(uploader.input.ContentType); got != "application/x-protobuf" {
+		t.Errorf("expected Content-Type application/x-protobuf, got %q", got)
+	}
+	if got := aws.ToString(uploader.input.CacheControl); got != DefaultTileCacheControl {
+		t.Errorf("expected a long Cache-Control, got %q", got)
+	}
+}
+
+func TestParseObjectHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentTypes string
+		cacheControl string
+		want         map[string]ObjectHeaders
+		wantErr      bool
+	}{
+		{"defaults", "", "", DefaultObjectHeaders, false},
+		{
+			"overrides and new extensions",
+			".pbf=application/vnd.mapbox-vector-tile; .JSON=application/json",
+			".pbf=public, max-age=86400;.mvt=",
+			map[string]ObjectHeaders{
+				".pbf":  {ContentType: "application/vnd.mapbox-vector-tile", CacheControl: "public, max-age=86400"},
+				".mvt":  {ContentType: "application/vnd.mapbox-vector-tile"},
+				".json": {ContentType: "application/json"},
+			},
+			false,
+		},
+		{"missing value", ".pbf", "", nil, true},
+		{"missing dot", "pbf=application/x-protobuf", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseObjectHeaders(tt.contentTypes, tt.cacheControl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	// Overrides must not leak into the defaults
+	if DefaultObjectHeaders[".pbf"].ContentType != "application/x-protobuf" {
+		t.Error("expected DefaultObjectHeaders to be unchanged")
+	}
+}
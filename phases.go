@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultPhaseConcurrency lets geometry extraction and R2 upload overlap
+const DefaultPhaseConcurrency = 2
+
+// pipelinePhase is a named step of the pipeline that can run alongside others
+type pipelinePhase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// runPhases runs phases with at most concurrency of them at a time (1 = one
+// after another). The first failure cancels the phases still running and
+// skips those not yet started. Every started phase is waited for before
+// returning, so cleanup never races a running phase. The returned error joins
+// each failed phase's error, prefixed with its name.
+func runPhases(ctx context.Context, concurrency int, phases []pipelinePhase) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	failed, skipped := false, false
+	sem := make(chan struct{}, concurrency)
+
+	for _, phase := range phases {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			skipped = true
+			break
+		}
+
+		wg.Add(1)
+		go func(phase pipelinePhase) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := phase.run(ctx)
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			// A phase stopped because a sibling failed is not a failure of its own
+			if failed && errors.Is(err, context.Canceled) {
+				return
+			}
+			failed = true
+			errs = append(errs, fmt.Errorf("%s: %w", phase.name, err))
+			cancel()
+		}(phase)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if skipped {
+		// Cancelled from outside before every phase started
+		return ctx.Err()
+	}
+	return nil
+}
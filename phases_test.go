@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPhases_Concurrency(t *testing.T) {
+	tests := []struct {
+		concurrency int
+		wantMax     int32
+	}{
+		{1, 1},
+		{2, 2},
+		{0, 1},
+	}
+
+	for _, tt := range tests {
+		var running, maxRunning, ran int32
+		phase := func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&ran, 1)
+			return nil
+		}
+
+		err := runPhases(context.Background(), tt.concurrency, []pipelinePhase{{"a", phase}, {"b", phase}, {"c", phase}})
+		if err != nil {
+			t.Fatalf("concurrency %d: %v", tt.concurrency, err)
+		}
+		if ran != 3 || maxRunning != tt.wantMax {
+			t.Errorf("concurrency %d: ran %d phases, at most %d at once, want 3 and %d", tt.concurrency, ran, maxRunning, tt.wantMax)
+		}
+	}
+}
+
+func TestRunPhases_EitherFailureFails(t *testing.T) {
+	for _, failing := range []string{"extract", "upload"} {
+		t.Run(failing, func(t *testing.T) {
+			var started sync.WaitGroup
+			started.Add(2)
+			var sawCancel atomic.Bool
+
+			phase := func(name string) pipelinePhase {
+				return pipelinePhase{name, func(ctx context.Context) error {
+					started.Done()
+					started.Wait() // both are running before either finishes
+					if name == failing {
+						return errors.New("boom")
+					}
+					select {
+					case <-ctx.Done():
+						sawCancel.Store(true)
+						return ctx.Err()
+					case <-time.After(5 * time.Second):
+						return nil
+					}
+				}}
+			}
+
+			var lateRan atomic.Bool
+			late := pipelinePhase{"late", func(ctx context.Context) error { lateRan.Store(true); return nil }}
+
+			err := runPhases(context.Background(), 2, []pipelinePhase{phase("extract"), phase("upload"), late})
+			if err == nil || err.Error() != failing+": boom" {
+				t.Fatalf("expected only %q's error, got %v", failing, err)
+			}
+			if !sawCancel.Load() {
+				t.Error("expected the other phase to be cancelled")
+			}
+			if lateRan.Load() {
+				t.Error("expected phases not yet started to be skipped")
+			}
+		})
+	}
+}
+
+func TestRunPhases_ParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	err := runPhases(ctx, 2, []pipelinePhase{{"a", func(ctx context.Context) error { ran = true; return nil }}})
+	if !errors.Is(err, context.Canceled) || ran {
+		t.Errorf("expected a cancelled run with nothing started, got %v (ran %v)", err, ran)
+	}
+}
+
+func TestRunPostGeneration(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	newService := func(t *testing.T, maxInvalid int) (*fakeS3, *TileService) {
+		fake, client := newFakeS3(t)
+		cfg := &Config{
+			S3:      S3Config{BucketPath: "tiles"},
+			Service: ServiceConfig{MaxInvalidRoads: maxInvalid},
+		}
+		return fake, NewTileService(nil, client, cfg)
+	}
+	opts := &JobOptions{ExtractGeometry: true, SkipGeometryInsertion: true}
+
+	t.Run("both run", func(t *testing.T) {
+		fake, svc := newService(t, 0)
+		dir := t.TempDir()
+		writeFixtureTile(t, dir)
+
+		result, err := svc.runPostGeneration(ctx, &TileJob{ID: "1", Region: "phases-ok"}, opts, dir, dir, newJobTracker(nil, "1", slog.Default()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.geometryCount != 1 || result.uploadedBytes == 0 || len(fake.puts) != 1 {
+			t.Errorf("expected one road extracted and one tile uploaded, got %+v and %v", result, fake.puts)
+		}
+	})
+
+	t.Run("extraction failure fails the job", func(t *testing.T) {
+		_, svc := newService(t, 1)
+		dir := t.TempDir()
+		writeEquatorTile(t, dir, 5)
+
+		_, err := svc.runPostGeneration(ctx, &TileJob{ID: "2", Region: "phases-extract"}, opts, dir, dir, newJobTracker(nil, "2", slog.Default()))
+		if err == nil || !strings.Contains(err.Error(), "geometry extraction:") {
+			t.Errorf("expected a geometry extraction failure, got %v", err)
+		}
+	})
+
+	t.Run("upload failure fails the job", func(t *testing.T) {
+		_, svc := newService(t, 0)
+		dir := t.TempDir()
+		writeFixtureTile(t, dir)
+
+		_, err := svc.runPostGeneration(ctx, &TileJob{ID: "3", Region: "phases-upload"}, opts, dir, filepath.Join(dir, "missing-merged"), newJobTracker(nil, "3", slog.Default()))
+		if err == nil || !strings.Contains(err.Error(), "R2 upload:") {
+			t.Errorf("expected an upload failure, got %v", err)
+		}
+	})
+}
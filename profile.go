@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sync"
+)
+
+// stopProfiles finishes the profiles main started (a no-op until then)
+var stopProfiles = func() {}
+
+// exit finishes any profiles and exits with code. Commands exit through it
+// rather than os.Exit, so a failing run still writes its profiles.
+func exit(code int) {
+	stopProfiles()
+	os.Exit(code)
+}
+
+// startProfiling starts a CPU profile (if cpuPath is set) and returns a stop
+// function that finishes the CPU profile and writes a heap profile (if memPath
+// is set). The stop function is safe to call when neither path is set, and
+// more than once.
+func startProfiling(cpuPath, memPath string) (func(), error) {
+	var cpuFile *os.File
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpuFile = f
+		slog.Info("CPU profiling enabled", "path", cpuPath)
+	}
+
+	stop := func() {
+		if cpuFile != nil {
+			runtimepprof.StopCPUProfile()
+			cpuFile.Close()
+			slog.Info("CPU profile written", "path", cpuPath)
+		}
+
+		if memPath != "" {
+			f, err := os.Create(memPath)
+			if err != nil {
+				slog.Error("failed to create memory profile", "error", err)
+				return
+			}
+			defer f.Close()
+
+			// Get up-to-date statistics before writing the heap profile
+			runtime.GC()
+			if err := runtimepprof.WriteHeapProfile(f); err != nil {
+				slog.Error("failed to write memory profile", "error", err)
+				return
+			}
+			slog.Info("memory profile written", "path", memPath)
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(stop) }, nil
+}
+
+// startPprofServer exposes net/http/pprof on its own listener so the profiling
+// endpoints are never mounted on the public API mux.
+func startPprofServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		slog.Info("starting pprof server", "addr", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("pprof server failed", "error", err)
+		}
+	}()
+}
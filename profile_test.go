@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling_WritesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.prof")
+	memPath := filepath.Join(dir, "mem.prof")
+
+	stop, err := startProfiling(cpuPath, memPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Do a little work so the profiles have something to record
+	sum := 0
+	for i := 0; i < 1000000; i++ {
+		sum += i
+	}
+	_ = sum
+
+	stop()
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected profile %s to exist: %v", path, err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("expected profile %s to be non-empty", path)
+		}
+	}
+
+	// exit may stop the profiles again after a command has; that is a no-op
+	os.Remove(memPath)
+	stop()
+	if _, err := os.Stat(memPath); !os.IsNotExist(err) {
+		t.Error("expected a second stop not to write the profiles again")
+	}
+}
+
+func TestStartProfiling_Disabled(t *testing.T) {
+	stop, err := startProfiling("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
+
+func TestStartProfiling_BadPath(t *testing.T) {
+	_, err := startProfiling(filepath.Join(t.TempDir(), "missing", "cpu.prof"), "")
+	if err == nil {
+		t.Fatal("expected error for unwritable CPU profile path")
+	}
+}
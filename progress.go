@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressFunc receives how many of total items a long-running step has done
+type ProgressFunc func(done, total int)
+
+// StepProgressFunc receives the pipeline step a job is in and how far through
+// it the job is, as a percentage
+type StepProgressFunc func(step string, percent int)
+
+// progressBarWidth is the number of cells in the rendered bar
+const progressBarWidth = 30
+
+// progressRedrawInterval limits how often the bar is redrawn
+const progressRedrawInterval = 100 * time.Millisecond
+
+// ProgressBar renders a single-line progress bar on a terminal. It also
+// implements io.Writer so log lines can be printed above the bar instead of
+// breaking it up.
+type ProgressBar struct {
+	mu       sync.Mutex
+	w        io.Writer
+	label    string
+	start    time.Time
+	lastDraw time.Time
+	done     int
+	total    int
+	drawn    bool
+}
+
+// NewProgressBar creates a progress bar that draws to w
+func NewProgressBar(w io.Writer, label string) *ProgressBar {
+	return &ProgressBar{w: w, label: label, start: time.Now()}
+}
+
+// Update records progress and redraws the bar; it matches ProgressFunc
+func (p *ProgressBar) Update(done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done, p.total = done, total
+	if time.Since(p.lastDraw) < progressRedrawInterval && done < total {
+		return
+	}
+	p.draw()
+}
+
+// Write prints log output above the bar and redraws the bar below it
+func (p *ProgressBar) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	n, err := p.w.Write(b)
+	if p.drawn {
+		p.draw()
+	}
+	return n, err
+}
+
+// Finish draws the final state and moves past the bar line
+func (p *ProgressBar) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.drawn {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.w)
+	p.drawn = false
+}
+
+func (p *ProgressBar) draw() {
+	fmt.Fprint(p.w, "\r\033[K"+p.render())
+	p.drawn = true
+	p.lastDraw = time.Now()
+}
+
+// render formats the bar, e.g. "extract [=======>      ]  42% 420/1000 12s"
+func (p *ProgressBar) render() string {
+	frac := 0.0
+	if p.total > 0 {
+		frac = min(1, float64(p.done)/float64(p.total))
+	}
+	filled := int(frac * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	elapsed := time.Since(p.start).Round(time.Second)
+	return fmt.Sprintf("%s [%s] %3.0f%% %d/%d %s", p.label, bar, frac*100, p.done, p.total, elapsed)
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe
+// or a file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// newCLIProgress returns a progress bar for label when stdout is a terminal,
+// routing logs through it. It returns nil when stdout is piped or redirected,
+// leaving the periodic progress logs in place.
+func newCLIProgress(label string, debug bool) *ProgressBar {
+	if !isTerminal(os.Stdout) {
+		return nil
+	}
+	bar := NewProgressBar(os.Stdout, label)
+	slog.SetDefault(newLogger(bar, debug))
+	return bar
+}
+
+// zoomProgressInterval is how often watchZoomProgress looks for new zoom
+// directories
+var zoomProgressInterval = 2 * time.Second
+
+// watchZoomProgress reports progress through zooms for a tool that writes
+// dir's zoom directories in order, like Tippecanoe and tile-join: a zoom
+// counts as done once the directory of the next one appears. It polls dir
+// until the returned stop func is called.
+func watchZoomProgress(dir string, zooms []int, progress ProgressFunc) (stop func()) {
+	if progress == nil || len(zooms) == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(zoomProgressInterval)
+		defer ticker.Stop()
+
+		reported := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			started := 0
+			for _, z := range zooms {
+				if _, err := os.Stat(filepath.Join(dir, strconv.Itoa(z))); err == nil {
+					started++
+				}
+			}
+			if started-1 > reported {
+				reported = started - 1
+				progress(reported, len(zooms))
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// zoomRange returns the zooms from minZoom to maxZoom
+func zoomRange(minZoom, maxZoom int) []int {
+	var zooms []int
+	for z := minZoom; z <= maxZoom; z++ {
+		zooms = append(zooms, z)
+	}
+	return zooms
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressBar_Render(t *testing.T) {
+	var out bytes.Buffer
+	bar := NewProgressBar(&out, "upload")
+
+	bar.Update(5, 10)
+	if got := out.String(); !strings.HasPrefix(got, "\r\033[Kupload [") || !strings.Contains(got, " 50% 5/10") {
+		t.Fatalf("unexpected bar %q", got)
+	}
+
+	// Log lines are printed above the bar, which is then redrawn
+	out.Reset()
+	bar.Write([]byte("level=INFO msg=hello\n"))
+	if got := out.String(); !strings.HasPrefix(got, "\r\033[Klevel=INFO msg=hello\n\r\033[Kupload [") {
+		t.Errorf("expected the log line above a redrawn bar, got %q", got)
+	}
+
+	// Completion is always drawn, even within the redraw interval
+	out.Reset()
+	bar.Update(10, 10)
+	bar.Finish()
+	if got := out.String(); !strings.Contains(got, "100% 10/10") || !strings.HasSuffix(got, "\n") {
+		t.Errorf("expected a final 100%% bar and newline, got %q", got)
+	}
+}
+
+func TestProgressBar_WriteBeforeDrawIsPassthrough(t *testing.T) {
+	var out bytes.Buffer
+	bar := NewProgressBar(&out, "extract")
+	bar.Write([]byte("line\n"))
+	bar.Finish()
+	if out.String() != "line\n" {
+		t.Errorf("expected plain passthrough before the bar is drawn, got %q", out.String())
+	}
+}
+
+func TestNewCLIProgress_NonTTY(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if isTerminal(w) || isTerminal(file) {
+		t.Fatal("pipes and files are not terminals")
+	}
+
+	prevStdout, prevLogger := os.Stdout, slog.Default()
+	defer func() { os.Stdout = prevStdout; slog.SetDefault(prevLogger) }()
+	os.Stdout = w
+
+	if bar := newCLIProgress("extract", false); bar != nil {
+		t.Error("expected no progress bar when stdout is piped")
+	}
+	if slog.Default() != prevLogger {
+		t.Error("expected the logger to be left alone when stdout is piped")
+	}
+}
+
+func TestExtractRoadGeometries_Progress(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	writeFixtureTile(t, dir)
+	writeEquatorTile(t, dir, 1)
+
+	var calls [][2]int
+	e := NewGeometryExtractor()
+	e.Progress = func(done, total int) { calls = append(calls, [2]int{done, total}) }
+	if _, _, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "progress-bar"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) == 0 || calls[len(calls)-1] != [2]int{2, 2} {
+		t.Errorf("expected progress to finish at 2/2, got %v", calls)
+	}
+
+	// Without a callback the output is the plain log stream
+	var logs bytes.Buffer
+	e = NewGeometryExtractor()
+	e.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	if _, _, err := e.ExtractRoadGeometriesFromSource(context.Background(), NewDirTileSource(dir), "progress-logs"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "\r") || !strings.Contains(logs.String(), `msg="extraction complete"`) {
+		t.Errorf("expected unchanged log output, got:\n%s", logs.String())
+	}
+}
+
+func TestUploadDirectory_Progress(t *testing.T) {
+	_, client := newFakeS3(t)
+	dir := writeDedupFixture(t)
+
+	var mu sync.Mutex
+	last, calls := 0, 0
+	client.Progress = func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if total != 6 {
+			t.Errorf("expected a total of 6 files, got %d", total)
+		}
+		last = max(last, done)
+	}
+
+	if _, err := client.uploadDirectory(context.Background(), dir, "tiles"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 6 || last != 6 {
+		t.Errorf("expected one progress call per file up to 6, got %d calls, last %d", calls, last)
+	}
+}
+
+func TestGenerateTiles_GoEncoderProgress(t *testing.T) {
+	var calls [][2]int
+	_, _, _, err := GenerateTilesWithOptions(context.Background(), writeTestGeoJSON(t), "testregion", t.TempDir(),
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 7, Encoder: EncoderGo, Progress: func(done, total int) {
+			calls = append(calls, [2]int{done, total})
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) < 3 || calls[0] != [2]int{1, 3} || calls[1] != [2]int{2, 3} || calls[len(calls)-1] != [2]int{3, 3} {
+		t.Errorf("expected progress to climb one zoom at a time to 3/3, got %v", calls)
+	}
+}
+
+func TestWatchZoomProgress(t *testing.T) {
+	interval := zoomProgressInterval
+	zoomProgressInterval = 5 * time.Millisecond
+	t.Cleanup(func() { zoomProgressInterval = interval })
+
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var calls [][2]int
+	waitFor := func(want [2]int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			n := len(calls)
+			last := [2]int{}
+			if n > 0 {
+				last = calls[n-1]
+			}
+			mu.Unlock()
+			if last == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("expected progress %v, got %v", want, calls)
+	}
+
+	stop := watchZoomProgress(dir, zoomRange(5, 7), func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, [2]int{done, total})
+	})
+
+	// A zoom is done once the next one is started
+	for _, z := range []string{"5", "6"} {
+		if err := os.Mkdir(filepath.Join(dir, z), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor([2]int{1, 3})
+	if err := os.Mkdir(filepath.Join(dir, "7"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitFor([2]int{2, 3})
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 {
+		t.Errorf("expected each zoom reported once, got %v", calls)
+	}
+}
+
+func TestMergeTileDirs_Progress(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeMergeTile(t, a, TileCoord{5, 3, 4}, 1)
+	writeMergeTile(t, a, TileCoord{5, 3, 5}, 2)
+	writeMergeTile(t, b, TileCoord{6, 7, 8}, 3)
+	writeMergeTile(t, b, TileCoord{12, 7, 8}, 4) // Outside the zoom filter
+
+	tests := []struct {
+		name     string
+		tileJoin bool
+		want     [2]int
+	}{
+		{"go merge counts tiles", false, [2]int{3, 3}},
+		{"tile-join counts zooms", true, [2]int{2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.tileJoin {
+				installStubTileJoin(t)
+			} else {
+				hideTileJoin(t)
+			}
+
+			var calls [][2]int
+			opts := &MergeTilesOptions{MinZoom: -1, MaxZoom: 10, Progress: func(done, total int) {
+				calls = append(calls, [2]int{done, total})
+			}}
+			if err := mergeTileDirs(context.Background(), []string{a, b}, t.TempDir(), opts); err != nil {
+				t.Fatal(err)
+			}
+			if len(calls) == 0 || calls[len(calls)-1] != tt.want {
+				t.Errorf("expected progress to finish at %v, got %v", tt.want, calls)
+			}
+		})
+	}
+}
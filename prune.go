@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPruneShare is the largest share of remote tiles a prune deletes without
+// PruneOptions.Force, as a last guard against a keep set that is missing
+// regions
+const maxPruneShare = 0.5
+
+// pruneWorkers is how many deletes run at once
+const pruneWorkers = 16
+
+// PruneOptions controls PruneStaleTiles
+type PruneOptions struct {
+	DryRun bool // Only log what would be deleted
+	Force  bool // Delete even when more than maxPruneShare of remote tiles are stale
+}
+
+// PruneSummary reports what a prune deleted, or would delete in a dry run
+type PruneSummary struct {
+	Regions      int // Local region directories and R2 manifests the kept tiles came from
+	Kept         int // Distinct tiles in any of them
+	Remote       int // Remote tiles examined
+	Stale        int // Remote tiles with no local counterpart
+	Deleted      int
+	DeletedBytes int64
+	DryRun       bool
+}
+
+// Print logs the summary
+func (p *PruneSummary) Print() {
+	if p.DryRun {
+		slog.Info("prune dry run summary", "regions", p.Regions, "kept_tiles", p.Kept, "remote_tiles", p.Remote, "would_delete", p.Stale, "would_reclaim_bytes", p.DeletedBytes)
+		return
+	}
+	slog.Info("prune summary", "regions", p.Regions, "kept_tiles", p.Kept, "remote_tiles", p.Remote, "deleted", p.Deleted, "reclaimed_bytes", p.DeletedBytes)
+}
+
+// PruneStaleTiles deletes the .pbf tiles on R2 that belong to no region.
+// Tiles of every region share one prefix, so the tiles kept are those of
+// every region directory under tilesRoot (the OUTPUT_DIR holding one
+// directory per region) and every tile listed in a region manifest on R2,
+// which covers regions generated elsewhere. Objects that are not z/x/y.pbf
+// tiles (manifests, metadata) are never touched.
+func (s *TileService) PruneStaleTiles(ctx context.Context, tilesRoot string, opts PruneOptions) (*PruneSummary, error) {
+	prefix := s.config.S3.BucketPath + "/"
+	logger := slog.With("tiles_root", tilesRoot, "prefix", prefix, "dry_run", opts.DryRun)
+	logger.Info("pruning stale tiles from R2")
+
+	summary := &PruneSummary{DryRun: opts.DryRun}
+	local, regions, err := s.pruneKeepSet(ctx, tilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(local) == 0 {
+		return nil, fmt.Errorf("no tiles found under %s or in any region manifest, refusing to prune", tilesRoot)
+	}
+	summary.Regions, summary.Kept = regions, len(local)
+
+	objects, err := s.s3.ListObjectInfos(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []ObjectInfo
+	for _, obj := range objects {
+		coord, ok := tileCoordFromKey(strings.TrimPrefix(obj.Key, prefix))
+		if !ok {
+			continue
+		}
+		summary.Remote++
+		if !local[coord] {
+			stale = append(stale, obj)
+			summary.DeletedBytes += obj.Size
+		}
+	}
+	summary.Stale = len(stale)
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Key < stale[j].Key })
+
+	if opts.DryRun {
+		for _, obj := range stale {
+			logger.Info("would delete stale tile", "s3_key", obj.Key, "size_bytes", obj.Size)
+		}
+		return summary, nil
+	}
+
+	if !opts.Force && float64(len(stale)) > maxPruneShare*float64(summary.Remote) {
+		return nil, fmt.Errorf("%d of %d remote tiles belong to none of the %d regions found; is a region missing from %s and R2 manifests? (or use -force)",
+			len(stale), summary.Remote, regions, tilesRoot)
+	}
+
+	deleted, deletedBytes, err := s.deleteObjects(ctx, stale)
+	summary.Deleted, summary.DeletedBytes = deleted, deletedBytes
+	if err != nil {
+		return summary, err
+	}
+
+	logger.Info("stale tiles pruned", "deleted", deleted, "reclaimed_bytes", deletedBytes)
+	return summary, nil
+}
+
+// pruneKeepSet returns the tiles of every region directory under tilesRoot
+// and of every region manifest on R2, and how many regions they came from.
+// A region directory left by an interrupted generation run is refused, as
+// its missing tiles would be deleted.
+func (s *TileService) pruneKeepSet(ctx context.Context, tilesRoot string) (map[TileCoord]bool, int, error) {
+	dirs, err := os.ReadDir(tilesRoot)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read tiles root: %w", err)
+	}
+
+	keep := make(map[TileCoord]bool)
+	regions := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if _, err := strconv.Atoi(dir.Name()); err == nil {
+			return nil, 0, fmt.Errorf("%s holds zoom directories, so it is a single tileset; pass the directory holding every region's tiles (OUTPUT_DIR)", tilesRoot)
+		}
+		regionDir := filepath.Join(tilesRoot, dir.Name())
+		if IsIncompleteTileDir(regionDir) {
+			return nil, 0, fmt.Errorf("%s is from an interrupted generation run, refusing to prune against it", regionDir)
+		}
+		coords, err := GetTileCoords(regionDir)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read local tiles: %w", err)
+		}
+		if len(coords) == 0 {
+			continue
+		}
+		regions++
+		for c := range coords {
+			keep[c] = true
+		}
+	}
+
+	manifests, err := s.FetchAllManifests(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, entries := range manifests {
+		regions++
+		for _, entry := range entries {
+			if c, ok := tileCoordFromKey(entry.Path); ok {
+				keep[c] = true
+			}
+		}
+	}
+	return keep, regions, nil
+}
+
+// deleteObjects deletes objects with a small worker pool, returning how many
+// were deleted and their total size
+func (s *TileService) deleteObjects(ctx context.Context, objects []ObjectInfo) (int, int64, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		deleted int
+		bytes   int64
+		errs    []error
+	)
+	work := make(chan ObjectInfo)
+
+	for i := 0; i < pruneWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range work {
+				err := s.s3.DeleteObject(ctx, obj.Key)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", obj.Key, err))
+				} else {
+					deleted++
+					bytes += obj.Size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- obj
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return deleted, bytes, fmt.Errorf("failed to delete %d stale tiles: %w", len(objects)-deleted, errors.Join(errs...))
+	}
+	return deleted, bytes, nil
+}
+
+// tileCoordFromKey parses a "z/x/y.pbf" key relative to the tiles prefix
+func tileCoordFromKey(key string) (TileCoord, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".pbf") {
+		return TileCoord{}, false
+	}
+	z, errZ := strconv.Atoi(parts[0])
+	x, errX := strconv.Atoi(parts[1])
+	y, errY := strconv.Atoi(strings.TrimSuffix(parts[2], ".pbf"))
+	if errZ != nil || errX != nil || errY != nil {
+		return TileCoord{}, false
+	}
+	return TileCoord{z, x, y}, true
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newPruneService returns a service on a fake S3 seeded with keys, each
+// object's size being its index + 1, and a tiles root whose washington
+// directory holds localTiles
+func newPruneService(t *testing.T, keys []string, localTiles ...TileCoord) (*fakeS3, *TileService, string) {
+	t.Helper()
+	f, client := newFakeS3(t)
+	for i, key := range keys {
+		f.objects[key] = true
+		f.puts[key] = int64(i + 1)
+	}
+
+	tilesRoot := t.TempDir()
+	for _, c := range localTiles {
+		createFakeTile(t, filepath.Join(tilesRoot, "washington"), c.Z, c.X, c.Y)
+	}
+
+	cfg := &Config{S3: S3Config{BucketPath: "tiles"}}
+	return f, NewTileService(nil, client, cfg), tilesRoot
+}
+
+func TestPruneStaleTiles(t *testing.T) {
+	keys := []string{
+		"tiles/5/1/1.pbf",
+		"tiles/5/1/2.pbf",
+		"tiles/5/1/3.pbf",
+		"tiles/6/2/2.pbf",
+		"tiles/manifests/washington/manifest.json",
+	}
+	local := []TileCoord{{5, 1, 1}, {5, 1, 2}, {6, 2, 2}}
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		f, svc, tilesDir := newPruneService(t, keys, local...)
+		summary, err := svc.PruneStaleTiles(context.Background(), tilesDir, PruneOptions{DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Remote != 4 || summary.Stale != 1 || summary.Deleted != 0 || summary.DeletedBytes != 3 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if len(f.deletes) != 0 {
+			t.Errorf("expected no deletes in a dry run, got %v", f.deletes)
+		}
+	})
+
+	t.Run("deletes stale tiles only", func(t *testing.T) {
+		f, svc, tilesDir := newPruneService(t, keys, local...)
+		summary, err := svc.PruneStaleTiles(context.Background(), tilesDir, PruneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Deleted != 1 || summary.DeletedBytes != 3 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if len(f.deletes) != 1 || f.deletes[0] != "tiles/5/1/3.pbf" {
+			t.Errorf("expected only the stale tile to be deleted, got %v", f.deletes)
+		}
+		if !f.objects["tiles/manifests/washington/manifest.json"] {
+			t.Error("expected the manifest to be left alone")
+		}
+	})
+
+	t.Run("refuses to delete most remote tiles", func(t *testing.T) {
+		f, svc, tilesDir := newPruneService(t, keys, TileCoord{5, 1, 1})
+		if _, err := svc.PruneStaleTiles(context.Background(), tilesDir, PruneOptions{}); err == nil {
+			t.Fatal("expected an error when 3 of 4 remote tiles are stale")
+		}
+		if len(f.deletes) != 0 {
+			t.Errorf("expected no deletes, got %v", f.deletes)
+		}
+
+		summary, err := svc.PruneStaleTiles(context.Background(), tilesDir, PruneOptions{Force: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(f.deletes)
+		if summary.Deleted != 3 || len(f.deletes) != 3 || f.deletes[0] != "tiles/5/1/2.pbf" {
+			t.Errorf("expected -force to delete the 3 stale tiles, got %+v / %v", summary, f.deletes)
+		}
+	})
+
+	t.Run("empty local directory", func(t *testing.T) {
+		_, svc, tilesDir := newPruneService(t, keys)
+		if _, err := svc.PruneStaleTiles(context.Background(), tilesDir, PruneOptions{Force: true}); err == nil {
+			t.Error("expected an error for a directory without tiles")
+		}
+	})
+
+	t.Run("keeps other local regions", func(t *testing.T) {
+		f, svc, tilesRoot := newPruneService(t, keys, TileCoord{5, 1, 1}, TileCoord{6, 2, 2})
+		createFakeTile(t, filepath.Join(tilesRoot, "oregon"), 5, 1, 2)
+		summary, err := svc.PruneStaleTiles(context.Background(), tilesRoot, PruneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Regions != 2 || summary.Kept != 3 {
+			t.Errorf("expected 3 tiles kept from 2 regions, got %+v", summary)
+		}
+		if len(f.deletes) != 1 || f.deletes[0] != "tiles/5/1/3.pbf" {
+			t.Errorf("expected only the stale tile to be deleted, got %v", f.deletes)
+		}
+	})
+
+	t.Run("keeps tiles in other regions' manifests", func(t *testing.T) {
+		f, svc, tilesRoot := newPruneService(t, keys, TileCoord{5, 1, 1})
+		f.seedObjects(map[string]string{
+			"tiles/manifests/idaho/manifest.json": `[{"path":"5/1/2.pbf"},{"path":"6/2/2.pbf"},{"path":"metadata.json"}]`,
+		})
+		summary, err := svc.PruneStaleTiles(context.Background(), tilesRoot, PruneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Regions != 2 || summary.Kept != 3 {
+			t.Errorf("expected 3 tiles kept from a directory and a manifest, got %+v", summary)
+		}
+		if len(f.deletes) != 1 || f.deletes[0] != "tiles/5/1/3.pbf" {
+			t.Errorf("expected only the stale tile to be deleted, got %v", f.deletes)
+		}
+	})
+
+	t.Run("refuses a single tileset", func(t *testing.T) {
+		f, svc, tilesRoot := newPruneService(t, keys, local...)
+		_, err := svc.PruneStaleTiles(context.Background(), filepath.Join(tilesRoot, "washington"), PruneOptions{Force: true})
+		if err == nil || !strings.Contains(err.Error(), "single tileset") {
+			t.Errorf("expected a single tileset to be refused, got %v", err)
+		}
+		if len(f.deletes) != 0 {
+			t.Errorf("expected no deletes, got %v", f.deletes)
+		}
+	})
+
+	t.Run("refuses an interrupted region", func(t *testing.T) {
+		f, svc, tilesRoot := newPruneService(t, keys, local...)
+		createFakeTile(t, filepath.Join(tilesRoot, "oregon"), 5, 1, 3)
+		if err := os.WriteFile(filepath.Join(tilesRoot, "oregon", IncompleteSentinel), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.PruneStaleTiles(context.Background(), tilesRoot, PruneOptions{Force: true}); err == nil {
+			t.Error("expected an interrupted region directory to be refused")
+		}
+		if len(f.deletes) != 0 {
+			t.Errorf("expected no deletes, got %v", f.deletes)
+		}
+	})
+}
+
+func TestTileCoordFromKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want TileCoord
+		ok   bool
+	}{
+		{"5/1/2.pbf", TileCoord{5, 1, 2}, true},
+		{"14/2620/5720.pbf", TileCoord{14, 2620, 5720}, true},
+		{"manifests/washington/manifest.json", TileCoord{}, false},
+		{"5/1/2.json", TileCoord{}, false},
+		{"5/1/x.pbf", TileCoord{}, false},
+		{"a/5/1/2.pbf", TileCoord{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := tileCoordFromKey(tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("tileCoordFromKey(%q) = %v, %v; want %v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NearZeroAreaSqMeters is the bounding box area below which a road is
+// reported as degenerate (a point, or a box collapsed by a projection bug)
+const NearZeroAreaSqMeters = 1.0
+
+// QARoad identifies a road in a QA report
+type QARoad struct {
+	RoadID             string  `json:"roadId"`
+	Name               string  `json:"name"`
+	BBoxDiagonalMeters float64 `json:"bboxDiagonalMeters"`
+}
+
+// GeometryQAReport summarizes the health of a set of extracted roads
+type GeometryQAReport struct {
+	Source             string   `json:"source"`
+	Count              int      `json:"count"`
+	MinBBoxMeters      float64  `json:"minBBoxMeters"`
+	MaxBBoxMeters      float64  `json:"maxBBoxMeters"`
+	AvgBBoxMeters      float64  `json:"avgBBoxMeters"`
+	NearZeroArea       int      `json:"nearZeroArea"`
+	MissingCurvature   int      `json:"missingCurvature"`
+	OversizedThreshold float64  `json:"oversizedThresholdMeters"`
+	Oversized          int      `json:"oversized"`
+	Largest            []QARoad `json:"largest"`
+}
+
+// BuildGeometryQAReport computes bounding box statistics for roads and lists
+// the topN largest boxes (none for topN <= 0), which are the most likely
+// extraction bugs
+func BuildGeometryQAReport(source string, roads []RoadGeometry, topN int, maxBBoxMeters float64) *GeometryQAReport {
+	report := &GeometryQAReport{
+		Source:             source,
+		Count:              len(roads),
+		OversizedThreshold: maxBBoxMeters,
+		Largest:            []QARoad{},
+	}
+	if len(roads) == 0 {
+		return report
+	}
+
+	report.MinBBoxMeters = math.Inf(1)
+	var total float64
+	for i := range roads {
+		road := &roads[i]
+		d := road.BBoxDiagonalMeters()
+		total += d
+		report.MinBBoxMeters = math.Min(report.MinBBoxMeters, d)
+		report.MaxBBoxMeters = math.Max(report.MaxBBoxMeters, d)
+
+		if road.BBoxAreaSqMeters() < NearZeroAreaSqMeters {
+			report.NearZeroArea++
+		}
+		if road.Curvature == nil || *road.Curvature == "" {
+			report.MissingCurvature++
+		}
+	}
+	report.AvgBBoxMeters = total / float64(len(roads))
+
+	if maxBBoxMeters > 0 {
+		report.Oversized = len(FindOversizedRoads(roads, maxBBoxMeters))
+	}
+
+	// Largest boxes first, regardless of the oversized threshold
+	largest := FindOversizedRoads(roads, -1)
+	for _, road := range largest[:min(max(topN, 0), len(largest))] {
+		report.Largest = append(report.Largest, QARoad{
+			RoadID:             road.RoadID,
+			Name:               road.Name,
+			BBoxDiagonalMeters: road.BBoxDiagonalMeters(),
+		})
+	}
+
+	return report
+}
+
+// Print logs the QA report
+func (r *GeometryQAReport) Print() {
+	logger := slog.With("source", r.Source)
+
+	logger.Info("geometry QA summary",
+		"roads", r.Count,
+		"min_bbox_m", math.Round(r.MinBBoxMeters),
+		"max_bbox_m", math.Round(r.MaxBBoxMeters),
+		"avg_bbox_m", math.Round(r.AvgBBoxMeters),
+	)
+	if r.NearZeroArea > 0 {
+		logger.Warn("roads with zero or near-zero bounding box area", "count", r.NearZeroArea)
+	}
+	if r.MissingCurvature > 0 {
+		logger.Warn("roads without curvature", "count", r.MissingCurvature)
+	}
+	if r.Oversized > 0 {
+		logger.Warn("roads with oversized bounding boxes", "count", r.Oversized, "threshold_m", r.OversizedThreshold)
+	}
+	for i, road := range r.Largest {
+		logger.Info("largest bounding box",
+			"rank", i+1,
+			"road_id", road.RoadID,
+			"name", road.Name,
+			"bbox_diagonal_m", math.Round(road.BBoxDiagonalMeters),
+		)
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *GeometryQAReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode QA report: %w", err)
+	}
+	return nil
+}
+
+// resolveExtractionFile accepts either an extraction file path or a region
+// name and returns both the file and the region
+func resolveExtractionFile(extractor *GeometryExtractor, fileOrRegion string) (string, string) {
+	if _, err := os.Stat(fileOrRegion); err == nil {
+		// It's a file. Try to extract region from filename:
+		// .extracted-roads-{region}.json or .extracted-roads-{region}.partial.json
+		base := filepath.Base(fileOrRegion)
+		region := strings.TrimPrefix(base, ".extracted-roads-")
+		region = strings.TrimSuffix(region, ".json")
+		region = strings.TrimSuffix(region, ".partial")
+		return fileOrRegion, region
+	}
+	// It's a region name
+	return extractor.getExtractionFile(fileOrRegion), fileOrRegion
+}
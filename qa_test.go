@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeQAFixture(t *testing.T) string {
+	t.Helper()
+	curvature := "1500"
+	roads := []RoadGeometry{
+		// Known-good: a ~10 km curvy road
+		{RoadID: "good-1", Name: "Tail of the Dragon", MinLat: 35.46, MaxLat: 35.52, MinLng: -84.00, MaxLng: -83.92, Curvature: &curvature},
+		{RoadID: "good-2", Name: "Cherohala Skyway", MinLat: 35.30, MaxLat: 35.40, MinLng: -84.20, MaxLng: -84.00, Curvature: &curvature},
+		// Known-bad: same-name merge spanning the state
+		{RoadID: "bad-merge", Name: "Main Street", MinLat: 34.9, MaxLat: 36.6, MinLng: -90.0, MaxLng: -81.6, Curvature: &curvature},
+		// Known-bad: collapsed to a point, no curvature
+		{RoadID: "bad-point", Name: "Nowhere Road", MinLat: 35.0, MaxLat: 35.0, MinLng: -85.0, MaxLng: -85.0},
+	}
+	data, err := json.Marshal(roads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), ".extracted-roads-tennessee.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildGeometryQAReport(t *testing.T) {
+	path := writeQAFixture(t)
+	roads, err := NewGeometryExtractor().loadRoadsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := BuildGeometryQAReport(path, roads, 2, DefaultMaxBBoxMeters)
+
+	if report.Count != 4 {
+		t.Errorf("expected 4 roads, got %d", report.Count)
+	}
+	if report.NearZeroArea != 1 {
+		t.Errorf("expected 1 near-zero area road, got %d", report.NearZeroArea)
+	}
+	if report.MissingCurvature != 1 {
+		t.Errorf("expected 1 road without curvature, got %d", report.MissingCurvature)
+	}
+	if report.Oversized != 1 {
+		t.Errorf("expected 1 oversized road, got %d", report.Oversized)
+	}
+	if report.MinBBoxMeters != 0 {
+		t.Errorf("expected min bbox 0 for the point road, got %f", report.MinBBoxMeters)
+	}
+	if report.MaxBBoxMeters < DefaultMaxBBoxMeters {
+		t.Errorf("expected max bbox above %f, got %f", DefaultMaxBBoxMeters, report.MaxBBoxMeters)
+	}
+	if report.AvgBBoxMeters <= report.MinBBoxMeters || report.AvgBBoxMeters >= report.MaxBBoxMeters {
+		t.Errorf("expected avg between min and max, got %f", report.AvgBBoxMeters)
+	}
+
+	if len(report.Largest) != 2 {
+		t.Fatalf("expected top 2 largest, got %d", len(report.Largest))
+	}
+	if report.Largest[0].RoadID != "bad-merge" || report.Largest[1].RoadID != "good-2" {
+		t.Errorf("expected largest [bad-merge good-2], got [%s %s]", report.Largest[0].RoadID, report.Largest[1].RoadID)
+	}
+}
+
+func TestBuildGeometryQAReport_NegativeTop(t *testing.T) {
+	path := writeQAFixture(t)
+	roads, err := NewGeometryExtractor().loadRoadsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := BuildGeometryQAReport(path, roads, -1, DefaultMaxBBoxMeters)
+	if len(report.Largest) != 0 || report.Count != 4 {
+		t.Errorf("expected no largest boxes for a negative top, got %d of %d roads", len(report.Largest), report.Count)
+	}
+}
+
+func TestBuildGeometryQAReport_Empty(t *testing.T) {
+	report := BuildGeometryQAReport("empty", nil, 10, DefaultMaxBBoxMeters)
+	if report.Count != 0 || report.MinBBoxMeters != 0 || len(report.Largest) != 0 {
+		t.Errorf("expected zero-valued report, got %+v", report)
+	}
+
+	// An empty report must still be valid JSON (no +Inf)
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeometryQAReport_WriteJSON(t *testing.T) {
+	path := writeQAFixture(t)
+	roads, err := NewGeometryExtractor().loadRoadsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := BuildGeometryQAReport(path, roads, 1, DefaultMaxBBoxMeters).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"count", "minBBoxMeters", "maxBBoxMeters", "avgBBoxMeters", "nearZeroArea", "missingCurvature", "largest"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected key %q in JSON report", key)
+		}
+	}
+}
+
+func TestResolveExtractionFile(t *testing.T) {
+	extractor := NewGeometryExtractor()
+	path := writeQAFixture(t)
+
+	file, region := resolveExtractionFile(extractor, path)
+	if file != path || region != "tennessee" {
+		t.Errorf("expected (%s, tennessee), got (%s, %s)", path, file, region)
+	}
+
+	file, region = resolveExtractionFile(extractor, "oregon")
+	if file != extractor.getExtractionFile("oregon") || region != "oregon" {
+		t.Errorf("expected region lookup, got (%s, %s)", file, region)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+)
+
+// roadStore is the subset of Database used to reconcile an extraction file.
+// It matches rows by region, which only regional road keys keep.
+type roadStore interface {
+	GetRoadGeometryCount(ctx context.Context, region string) (int, error)
+	ExistingRoadIDs(ctx context.Context, region string, roadIDs []string) (map[string]bool, error)
+	SampleRoadIDs(ctx context.Context, region string, limit int) ([]string, error)
+}
+
+// ReconcileReport compares an extraction file against the RoadGeometry table
+type ReconcileReport struct {
+	Region      string
+	FileRoads   int      // Entries in the extraction file
+	UniqueRoads int      // Distinct road IDs in the extraction file
+	Duplicates  []string // Road IDs appearing more than once in the file (collapsed by ON CONFLICT)
+	DBCount     int      // Rows in the database for the region
+	SampledFile int      // File road IDs checked against the database
+	Missing     []string // Sampled file road IDs not in the database
+	SampledDB   int      // Database road IDs checked against the file
+	Extra       []string // Sampled database road IDs not in the file
+	OK          bool
+}
+
+// Reconcile checks that the database matches the extraction file for a region.
+// Up to sampleSize IDs are checked in each direction (0 = check every file ID).
+// A file extracted with RoadKeyGlobal is refused: its roads upsert into rows
+// other regions share, which keep only the region that inserted them first,
+// so a per-region count or ID check would report false mismatches.
+func Reconcile(ctx context.Context, store roadStore, region string, roads []RoadGeometry, sampleSize int) (*ReconcileReport, error) {
+	for _, road := range roads {
+		if isGlobalRoadKey(road.RoadID) {
+			return nil, fmt.Errorf("cannot reconcile region %s: its extraction file uses global road keys, whose rows are shared across regions", region)
+		}
+	}
+
+	report := &ReconcileReport{Region: region, FileRoads: len(roads)}
+
+	fileIDs := make(map[string]int, len(roads))
+	for _, road := range roads {
+		fileIDs[road.RoadID]++
+	}
+	report.UniqueRoads = len(fileIDs)
+
+	ids := make([]string, 0, len(fileIDs))
+	for id, n := range fileIDs {
+		ids = append(ids, id)
+		if n > 1 {
+			report.Duplicates = append(report.Duplicates, id)
+		}
+	}
+	sort.Strings(ids)
+	sort.Strings(report.Duplicates)
+
+	count, err := store.GetRoadGeometryCount(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	report.DBCount = count
+
+	// File -> DB: sample file IDs and confirm they were inserted
+	sample := ids
+	if sampleSize > 0 && len(ids) > sampleSize {
+		sample = make([]string, len(ids))
+		copy(sample, ids)
+		rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		sample = sample[:sampleSize]
+		sort.Strings(sample)
+	}
+	report.SampledFile = len(sample)
+
+	if len(sample) > 0 {
+		existing, err := store.ExistingRoadIDs(ctx, region, sample)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range sample {
+			if !existing[id] {
+				report.Missing = append(report.Missing, id)
+			}
+		}
+	}
+
+	// DB -> file: sample DB IDs and confirm they came from this file
+	dbLimit := sampleSize
+	if dbLimit <= 0 {
+		dbLimit = count
+	}
+	if dbLimit > 0 {
+		dbSample, err := store.SampleRoadIDs(ctx, region, dbLimit)
+		if err != nil {
+			return nil, err
+		}
+		report.SampledDB = len(dbSample)
+		for _, id := range dbSample {
+			if fileIDs[id] == 0 {
+				report.Extra = append(report.Extra, id)
+			}
+		}
+		sort.Strings(report.Extra)
+	}
+
+	report.OK = report.DBCount == report.UniqueRoads && len(report.Missing) == 0 && len(report.Extra) == 0
+	return report, nil
+}
+
+// Print logs the reconcile report
+func (r *ReconcileReport) Print() {
+	logger := slog.With("region", r.Region)
+
+	if r.OK {
+		logger.Info("reconcile PASSED", "db_count", r.DBCount, "file_roads", r.UniqueRoads)
+	} else {
+		logger.Error("reconcile FAILED",
+			"db_count", r.DBCount,
+			"file_roads", r.UniqueRoads,
+			"difference", r.UniqueRoads-r.DBCount,
+		)
+	}
+
+	if len(r.Duplicates) > 0 {
+		logger.Warn("duplicate road IDs in extraction file (collapsed on insert)",
+			"count", len(r.Duplicates),
+			"entries", r.FileRoads,
+			"sample", firstN(r.Duplicates, 10),
+		)
+	}
+	logger.Info("sampled file road IDs", "checked", r.SampledFile, "missing", len(r.Missing))
+	if len(r.Missing) > 0 {
+		logger.Error("road IDs missing from database", "sample", firstN(r.Missing, 10))
+	}
+	logger.Info("sampled database road IDs", "checked", r.SampledDB, "extra", len(r.Extra))
+	if len(r.Extra) > 0 {
+		logger.Error("road IDs in database but not in extraction file", "sample", firstN(r.Extra, 10))
+	}
+}
+
+// firstN returns at most n items for logging
+func firstN(items []string, n int) []string {
+	if len(items) <= n {
+		return items
+	}
+	return append(items[:n:n], fmt.Sprintf("... %d more", len(items)-n))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakeRoadStore is an in-memory RoadGeometry table keyed by region
+type fakeRoadStore struct {
+	roads map[string]map[string]bool
+}
+
+func newFakeRoadStore(region string, ids ...string) *fakeRoadStore {
+	s := &fakeRoadStore{roads: map[string]map[string]bool{region: {}}}
+	for _, id := range ids {
+		s.roads[region][id] = true
+	}
+	return s
+}
+
+func (s *fakeRoadStore) GetRoadGeometryCount(ctx context.Context, region string) (int, error) {
+	return len(s.roads[region]), nil
+}
+
+func (s *fakeRoadStore) ExistingRoadIDs(ctx context.Context, region string, roadIDs []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	for _, id := range roadIDs {
+		if s.roads[region][id] {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *fakeRoadStore) SampleRoadIDs(ctx context.Context, region string, limit int) ([]string, error) {
+	var ids []string
+	for id := range s.roads[region] {
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func roadsWithIDs(region string, ids ...string) []RoadGeometry {
+	roads := make([]RoadGeometry, len(ids))
+	for i, id := range ids {
+		roads[i] = RoadGeometry{RoadID: id, Region: region}
+	}
+	return roads
+}
+
+func TestReconcile_Match(t *testing.T) {
+	store := newFakeRoadStore("oregon", "a", "b", "c")
+	report, err := Reconcile(context.Background(), store, "oregon", roadsWithIDs("oregon", "a", "b", "c"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK {
+		t.Errorf("expected matching file and DB to pass, got %+v", report)
+	}
+}
+
+func TestReconcile_MissingAndExtra(t *testing.T) {
+	// DB has a, b, c, z; file has a, b, c plus d and e that never made it in
+	store := newFakeRoadStore("oregon", "a", "b", "c", "z")
+	roads := roadsWithIDs("oregon", "a", "b", "c", "d", "e")
+
+	report, err := Reconcile(context.Background(), store, "oregon", roads, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.OK {
+		t.Fatal("expected reconcile to fail")
+	}
+	if report.DBCount != 4 || report.UniqueRoads != 5 {
+		t.Errorf("expected db_count=4 file_roads=5, got %d and %d", report.DBCount, report.UniqueRoads)
+	}
+	if fmt.Sprint(report.Missing) != "[d e]" {
+		t.Errorf("expected missing [d e], got %v", report.Missing)
+	}
+	if fmt.Sprint(report.Extra) != "[z]" {
+		t.Errorf("expected extra [z], got %v", report.Extra)
+	}
+}
+
+func TestReconcile_DuplicateIDsInFile(t *testing.T) {
+	// Three entries collapse to two rows on insert; counts still match
+	store := newFakeRoadStore("oregon", "a", "b")
+	roads := roadsWithIDs("oregon", "a", "b", "a")
+
+	report, err := Reconcile(context.Background(), store, "oregon", roads, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK {
+		t.Errorf("expected collapsed duplicates to reconcile, got %+v", report)
+	}
+	if report.FileRoads != 3 || report.UniqueRoads != 2 {
+		t.Errorf("expected 3 entries and 2 unique roads, got %d and %d", report.FileRoads, report.UniqueRoads)
+	}
+	if fmt.Sprint(report.Duplicates) != "[a]" {
+		t.Errorf("expected duplicate [a], got %v", report.Duplicates)
+	}
+}
+
+func TestReconcile_Sampling(t *testing.T) {
+	var ids []string
+	for i := 0; i < 100; i++ {
+		ids = append(ids, fmt.Sprintf("road-%03d", i))
+	}
+	store := newFakeRoadStore("oregon", ids...)
+
+	report, err := Reconcile(context.Background(), store, "oregon", roadsWithIDs("oregon", ids...), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SampledFile != 10 || report.SampledDB != 10 {
+		t.Errorf("expected 10 IDs sampled each way, got %d and %d", report.SampledFile, report.SampledDB)
+	}
+	if !report.OK {
+		t.Errorf("expected sampled reconcile to pass, got %+v", report)
+	}
+}
+
+func TestReconcile_RefusesGlobalKeys(t *testing.T) {
+	road := RoadGeometry{Name: "Hwy 1", Region: "oregon", MinLat: 44, MaxLat: 45, MinLng: -124, MaxLng: -123}
+	road.RoadID = road.Key(RoadKeyGlobal)
+	store := newFakeRoadStore("oregon", road.RoadID)
+
+	if _, err := Reconcile(context.Background(), store, "oregon", []RoadGeometry{road}, 0); err == nil {
+		t.Error("expected reconcile to refuse a file with global road keys")
+	}
+}
+
+func TestFirstN(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	if got := fmt.Sprint(firstN(items, 5)); got != "[a b c]" {
+		t.Errorf("got %s", got)
+	}
+	if got := fmt.Sprint(firstN(items, 2)); got != "[a b ... 1 more]" {
+		t.Errorf("got %s", got)
+	}
+	if fmt.Sprint(items) != "[a b c]" {
+		t.Errorf("firstN modified its input: %v", items)
+	}
+}
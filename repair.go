@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RegenerateZooms regenerates only the given zoom levels of the tile tree at
+// tilesDir from geoJSONPath, leaving every other zoom untouched. Consecutive
+// zooms are generated together into a scratch directory beside tilesDir, and
+// each zoom is then swapped into the tree, replacing any partial copy. The
+// returned count is the number of tiles written.
+func RegenerateZooms(ctx context.Context, geoJSONPath, tilesDir string, zooms []int, opts *GenerateTilesOptions) (int, error) {
+	if len(zooms) == 0 {
+		return 0, fmt.Errorf("no zoom levels to regenerate")
+	}
+	if _, err := os.Stat(geoJSONPath); err != nil {
+		return 0, fmt.Errorf("failed to read GeoJSON: %w", err)
+	}
+	if info, err := os.Stat(tilesDir); err != nil || !info.IsDir() {
+		return 0, fmt.Errorf("tiles directory %s does not exist", tilesDir)
+	}
+
+	region := filepath.Base(tilesDir)
+	logger := slog.With("tiles_dir", tilesDir, "zooms", zooms)
+	logger.Info("regenerating zoom levels")
+
+	total := 0
+	for _, span := range zoomSpans(zooms) {
+		count, err := regenerateZoomSpan(ctx, geoJSONPath, tilesDir, region, span[0], span[1], opts)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+
+	logger.Info("zoom levels regenerated", "tiles_count", total)
+	return total, nil
+}
+
+// regenerateZoomSpan generates zooms minZoom..maxZoom into a scratch tree and
+// moves them into tilesDir
+func regenerateZoomSpan(ctx context.Context, geoJSONPath, tilesDir, region string, minZoom, maxZoom int, opts *GenerateTilesOptions) (int, error) {
+	// Beside tilesDir so the final renames stay on one filesystem
+	scratch, err := os.MkdirTemp(filepath.Dir(tilesDir), ".regen-"+region+"-")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	spanOpts := GenerateTilesOptions{}
+	if opts != nil {
+		spanOpts = *opts
+	}
+	spanOpts.MinZoom, spanOpts.MaxZoom = minZoom, maxZoom
+
+	generatedDir, _, _, err := GenerateTilesWithOptions(ctx, geoJSONPath, region, scratch, &spanOpts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate zooms %d-%d: %w", minZoom, maxZoom, err)
+	}
+
+	// Check every zoom before touching the tree, so a bad run changes nothing
+	counts := make(map[int]int)
+	for z := minZoom; z <= maxZoom; z++ {
+		count, err := countTiles(filepath.Join(generatedDir, strconv.Itoa(z)))
+		if err != nil || count == 0 {
+			return 0, fmt.Errorf("regenerating zoom %d produced no tiles", z)
+		}
+		counts[z] = count
+	}
+
+	total := 0
+	for z := minZoom; z <= maxZoom; z++ {
+		if err := swapZoomDir(filepath.Join(generatedDir, strconv.Itoa(z)), filepath.Join(tilesDir, strconv.Itoa(z))); err != nil {
+			return total, err
+		}
+		slog.Info("zoom level replaced", "tiles_dir", tilesDir, "zoom", z, "tiles", counts[z])
+		total += counts[z]
+	}
+	return total, nil
+}
+
+// swapZoomDir replaces the zoom directory dst with src. An existing dst is
+// moved aside first and restored if the swap fails.
+func swapZoomDir(src, dst string) error {
+	old := dst + ".old"
+	os.RemoveAll(old)
+
+	hadOld := false
+	if _, err := os.Stat(dst); err == nil {
+		if err := os.Rename(dst, old); err != nil {
+			return fmt.Errorf("failed to move aside %s: %w", dst, err)
+		}
+		hadOld = true
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if hadOld {
+			os.Rename(old, dst)
+		}
+		return fmt.Errorf("failed to move regenerated zoom into %s: %w", dst, err)
+	}
+
+	if hadOld {
+		os.RemoveAll(old)
+	}
+	return nil
+}
+
+// zoomSpans sorts and de-duplicates zooms and groups consecutive levels into
+// [min, max] spans, so each span takes one generation run
+func zoomSpans(zooms []int) [][2]int {
+	sorted := append([]int(nil), zooms...)
+	sort.Ints(sorted)
+
+	var spans [][2]int
+	for _, z := range sorted {
+		if n := len(spans); n > 0 && z <= spans[n-1][1]+1 {
+			spans[n-1][1] = max(spans[n-1][1], z)
+			continue
+		}
+		spans = append(spans, [2]int{z, z})
+	}
+	return spans
+}
+
+// parseZoomList parses a comma-separated list of zoom levels such as "7,9,10"
+func parseZoomList(value string) ([]int, error) {
+	var zooms []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		z, err := strconv.Atoi(part)
+		if err != nil || z < 0 || z > maxTileZoom {
+			return nil, fmt.Errorf("invalid zoom level %q", part)
+		}
+		zooms = append(zooms, z)
+	}
+	return zooms, nil
+}
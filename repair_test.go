@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestRegenerateZooms_FillsMissingZoom(t *testing.T) {
+	geoJSONPath := writeTestGeoJSON(t)
+	outDir := t.TempDir()
+	opts := &GenerateTilesOptions{MinZoom: 5, MaxZoom: 8, Encoder: EncoderGo}
+	tilesDir, _, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "repairregion", outDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := GetTileCoords(tilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoom5Tile := filepath.Join(tilesDir, "5", "5", "11.pbf")
+	zoom5, err := os.ReadFile(zoom5Tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lose zoom 7
+	if err := os.RemoveAll(filepath.Join(tilesDir, "7")); err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyTileDirectory(tilesDir, 5, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK || fmt.Sprint(report.MissingZooms) != "[7]" {
+		t.Fatalf("expected zoom 7 to be missing, got %+v", report)
+	}
+
+	count, err := RegenerateZooms(context.Background(), geoJSONPath, tilesDir, report.MissingZooms, &GenerateTilesOptions{Encoder: EncoderGo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifyTileDirectory(tilesDir, 5, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK || count != report.ZoomStats[7].TileCount {
+		t.Errorf("expected zoom 7 to be filled (%d tiles written), got %+v", count, report)
+	}
+	repaired, err := GetTileCoords(tilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(repaired) != fmt.Sprint(original) {
+		t.Errorf("expected the original tile set back, got %d tiles for %d", len(repaired), len(original))
+	}
+
+	// Other zooms are left alone and no scratch directories remain
+	if got, err := os.ReadFile(zoom5Tile); err != nil || !bytes.Equal(got, zoom5) {
+		t.Errorf("expected zoom 5 to be untouched (%v)", err)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "repairregion" {
+		t.Errorf("expected only the region directory in %s, found %v", outDir, entries)
+	}
+}
+
+func TestRegenerateZooms_FailureLeavesTreeUnchanged(t *testing.T) {
+	geoJSONPath := writeTestGeoJSON(t)
+	tilesDir := filepath.Join(t.TempDir(), "repairregion")
+	createFakeTile(t, tilesDir, 9, 1, 1) // a partial zoom
+
+	// Bounds away from the road: generation produces nothing
+	far := orb.Bound{Min: orb.Point{10, 10}, Max: orb.Point{11, 11}}
+	_, err := RegenerateZooms(context.Background(), geoJSONPath, tilesDir, []int{9}, &GenerateTilesOptions{Encoder: EncoderGo, Bounds: &far})
+	if err == nil {
+		t.Fatal("expected an error when a zoom produces no tiles")
+	}
+	if _, err := os.Stat(filepath.Join(tilesDir, "9", "1", "1.pbf")); err != nil {
+		t.Errorf("expected the existing zoom to be kept: %v", err)
+	}
+}
+
+func TestZoomSpans(t *testing.T) {
+	tests := []struct {
+		zooms []int
+		want  string
+	}{
+		{[]int{7}, "[[7 7]]"},
+		{[]int{9, 7, 8}, "[[7 9]]"},
+		{[]int{3, 5, 6, 10, 5}, "[[3 3] [5 6] [10 10]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(zoomSpans(tt.zooms)); got != tt.want {
+			t.Errorf("zoomSpans(%v) = %s, want %s", tt.zooms, got, tt.want)
+		}
+	}
+}
+
+func TestParseZoomList(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "[]", false},
+		{"7", "[7]", false},
+		{"7, 9,10", "[7 9 10]", false},
+		{"7,x", "", true},
+		{"-1", "", true},
+		{"33", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseZoomList(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseZoomList(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && fmt.Sprint(got) != tt.want {
+			t.Errorf("parseZoomList(%q) = %v, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mumuon/drivefinder/tile-service/internal/geomcount"
+	"github.com/paulmach/orb/geojson"
+)
+
+// MaxTileCoordinateLoss is the share of GeoJSON coordinate points that may be
+// missing from the max zoom tiles before a pipeline report flags it. Tiles
+// split roads at tile edges, which adds points, so any real loss is
+// simplification or dropped features.
+const MaxTileCoordinateLoss = 0.1
+
+// KMLCounts are the ground truth counts of a region's KML
+type KMLCounts struct {
+	Path        string `json:"path"`
+	Folders     int    `json:"folders"`     // Semantic roads (folders, or top-level placemarks)
+	Placemarks  int    `json:"placemarks"`  // Road segments
+	Coordinates int    `json:"coordinates"` // Points the converter can parse
+}
+
+// GeoJSONCounts are the counts of a region's intermediate GeoJSON
+type GeoJSONCounts struct {
+	Path        string `json:"path"`
+	Features    int    `json:"features"`
+	Coordinates int    `json:"coordinates"`
+}
+
+// ZoomCounts are the counts of a region's roads layer at one zoom level
+type ZoomCounts struct {
+	Zoom        int `json:"zoom"`
+	Tiles       int `json:"tiles"`
+	Features    int `json:"features"`
+	Coordinates int `json:"coordinates"`
+	Roads       int `json:"roads"` // Unique road IDs
+}
+
+// PipelineReport traces a region's roads from KML through GeoJSON to tiles,
+// with a warning wherever the counts diverge more than the pipeline explains
+type PipelineReport struct {
+	Region   string        `json:"region"`
+	KML      KMLCounts     `json:"kml"`
+	GeoJSON  GeoJSONCounts `json:"geojson"`
+	Tiles    []ZoomCounts  `json:"tiles"` // In zoom order
+	Warnings []string      `json:"warnings"`
+}
+
+// ReportOptions points a pipeline report at each stage's output. Empty
+// fields fall back to where generation puts them.
+type ReportOptions struct {
+	KMLPath     string // Default: the region's KMZ in the curvature data directory
+	GeoJSONPath string // Default: the intermediate GeoJSON if kept, else converted from the KML
+	TilesPath   string // Tile directory or .mbtiles file. Default: {output dir}/{region}
+}
+
+// PipelineReport builds a pipeline report for a region, extracting the KMZ
+// and converting it again when no KML or GeoJSON is given. Anything extracted
+// or converted for the report is removed afterwards.
+func (s *TileService) PipelineReport(ctx context.Context, region string, opts ReportOptions) (*PipelineReport, error) {
+	logger := slog.With("region", region)
+
+	kmlPath := opts.KMLPath
+	if kmlPath == "" {
+		extracted, err := ExtractKMZFromDirWithPattern(ctx, region, s.config.Paths.CurvatureData, s.config.Paths.KMZPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract KMZ: %w", err)
+		}
+		defer CleanupTemporaryFiles(ctx, extracted, "", "")
+		kmlPath = extracted
+	}
+
+	geoJSONPath := opts.GeoJSONPath
+	if geoJSONPath == "" {
+		geoJSONPath = latestIntermediateGeoJSON(region)
+		if geoJSONPath != "" {
+			logger.Info("using intermediate GeoJSON kept by -no-cleanup", "path", geoJSONPath)
+		} else {
+			logger.Info("no intermediate GeoJSON, converting the KML again")
+			converted, _, err := ConvertKMLToGeoJSON(ctx, kmlPath, region)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert KML: %w", err)
+			}
+			defer os.Remove(converted)
+			geoJSONPath = converted
+		}
+	}
+
+	tilesPath := opts.TilesPath
+	if tilesPath == "" {
+		tilesPath = filepath.Join(s.config.Paths.OutputDir, region)
+	}
+	tiles, err := OpenTileSource(tilesPath, s.config.Paths.TileScheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tiles: %w", err)
+	}
+	defer tiles.Close()
+
+	return BuildPipelineReport(ctx, region, kmlPath, geoJSONPath, tiles)
+}
+
+// BuildPipelineReport counts the region's roads at every pipeline stage
+func BuildPipelineReport(ctx context.Context, region, kmlPath, geoJSONPath string, tiles TileSource) (*PipelineReport, error) {
+	kml, err := countKML(kmlPath)
+	if err != nil {
+		return nil, err
+	}
+	geo, err := countGeoJSON(geoJSONPath)
+	if err != nil {
+		return nil, err
+	}
+	zooms, err := countTileZooms(ctx, tiles)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PipelineReport{Region: region, KML: kml, GeoJSON: geo, Tiles: zooms}
+	report.Warnings = report.divergences()
+	return report, nil
+}
+
+// countKML counts the folders, placemarks and parseable coordinate points of
+// a KML file
+func countKML(path string) (KMLCounts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KMLCounts{}, fmt.Errorf("failed to read KML file: %w", err)
+	}
+	var doc kmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return KMLCounts{}, fmt.Errorf("failed to parse KML: %w", err)
+	}
+
+	roads := doc.roads()
+	counts := KMLCounts{Path: path, Folders: len(roads)}
+	for _, folder := range roads {
+		counts.Placemarks += len(folder.Placemarks)
+		for _, pm := range folder.Placemarks {
+			counts.Coordinates += len(parseKMLCoordinates(pm.LineString.Coordinates))
+		}
+	}
+	return counts, nil
+}
+
+// countGeoJSON counts the features and coordinate points of a GeoJSON file
+func countGeoJSON(path string) (GeoJSONCounts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GeoJSONCounts{}, fmt.Errorf("failed to read GeoJSON file: %w", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return GeoJSONCounts{}, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	counts := GeoJSONCounts{Path: path, Features: len(fc.Features)}
+	for _, f := range fc.Features {
+		counts.Coordinates += geomcount.Coordinates(f.Geometry)
+	}
+	return counts, nil
+}
+
+// countTileZooms counts the roads layer of every tile, per zoom level
+func countTileZooms(ctx context.Context, src TileSource) ([]ZoomCounts, error) {
+	tiles, err := src.Tiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tiles: %w", err)
+	}
+
+	byZoom := make(map[int]*ZoomCounts)
+	roadIDs := make(map[int]map[string]bool)
+	for _, tile := range tiles {
+		data, err := src.ReadTile(ctx, tile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tile %s: %w", tileKey(tile), err)
+		}
+		layers, err := decodeTile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tile %s: %w", tileKey(tile), err)
+		}
+
+		z := int(tile.Z)
+		zc := byZoom[z]
+		if zc == nil {
+			zc = &ZoomCounts{Zoom: z}
+			byZoom[z] = zc
+			roadIDs[z] = make(map[string]bool)
+		}
+		zc.Tiles++
+		for _, layer := range layers {
+			if layer.Name != "roads" {
+				continue
+			}
+			zc.Features += len(layer.Features)
+			for _, f := range layer.Features {
+				zc.Coordinates += geomcount.Coordinates(f.Geometry)
+				if id, ok := f.Properties["id"].(string); ok && id != "" {
+					roadIDs[z][id] = true
+				}
+			}
+		}
+	}
+
+	zooms := make([]ZoomCounts, 0, len(byZoom))
+	for z, zc := range byZoom {
+		zc.Roads = len(roadIDs[z])
+		zooms = append(zooms, *zc)
+	}
+	sort.Slice(zooms, func(i, j int) bool { return zooms[i].Zoom < zooms[j].Zoom })
+	return zooms, nil
+}
+
+// divergences lists where the counts disagree more than the pipeline
+// explains. Conversion keeps every road with at least one two-point segment
+// and every point of it; tiles should carry every road, and at least
+// 1-MaxTileCoordinateLoss of the points, at the max zoom.
+func (r *PipelineReport) divergences() []string {
+	warnings := []string{}
+
+	if r.GeoJSON.Features < r.KML.Folders {
+		warnings = append(warnings, fmt.Sprintf("KML→GeoJSON: %d of %d KML roads (folders) have no GeoJSON feature",
+			r.KML.Folders-r.GeoJSON.Features, r.KML.Folders))
+	}
+	if r.GeoJSON.Coordinates != r.KML.Coordinates {
+		warnings = append(warnings, fmt.Sprintf("KML→GeoJSON: coordinate points changed from %d to %d (clipping or single-point segments)",
+			r.KML.Coordinates, r.GeoJSON.Coordinates))
+	}
+
+	if len(r.Tiles) == 0 {
+		return append(warnings, "GeoJSON→tiles: no tiles found")
+	}
+	for i := 1; i < len(r.Tiles); i++ {
+		for z := r.Tiles[i-1].Zoom + 1; z < r.Tiles[i].Zoom; z++ {
+			warnings = append(warnings, fmt.Sprintf("GeoJSON→tiles: zoom %d has no tiles", z))
+		}
+	}
+
+	top := r.Tiles[len(r.Tiles)-1]
+	if top.Roads < r.GeoJSON.Features {
+		warnings = append(warnings, fmt.Sprintf("GeoJSON→tiles: %d of %d roads missing at max zoom %d",
+			r.GeoJSON.Features-top.Roads, r.GeoJSON.Features, top.Zoom))
+	}
+	if float64(top.Coordinates) < float64(r.GeoJSON.Coordinates)*(1-MaxTileCoordinateLoss) {
+		warnings = append(warnings, fmt.Sprintf("GeoJSON→tiles: max zoom %d has %d of %d coordinate points (%.1f%% lost)",
+			top.Zoom, top.Coordinates, r.GeoJSON.Coordinates,
+			100*(1-float64(top.Coordinates)/float64(r.GeoJSON.Coordinates))))
+	}
+	return warnings
+}
+
+// Print writes the report as a table of counts per stage followed by its
+// warnings
+func (r *PipelineReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Pipeline report: %s\n\n", r.Region)
+
+	fmt.Fprintf(w, "KML      %s\n", r.KML.Path)
+	fmt.Fprintf(w, "  folders (roads):      %d\n", r.KML.Folders)
+	fmt.Fprintf(w, "  placemarks:           %d\n", r.KML.Placemarks)
+	fmt.Fprintf(w, "  coordinate points:    %d\n\n", r.KML.Coordinates)
+
+	fmt.Fprintf(w, "GeoJSON  %s\n", r.GeoJSON.Path)
+	fmt.Fprintf(w, "  features (roads):     %d\n", r.GeoJSON.Features)
+	fmt.Fprintf(w, "  coordinate points:    %d\n\n", r.GeoJSON.Coordinates)
+
+	fmt.Fprintf(w, "Tiles (roads layer)\n")
+	fmt.Fprintf(w, "  %4s %8s %10s %12s %8s\n", "zoom", "tiles", "features", "coordinates", "roads")
+	for _, z := range r.Tiles {
+		fmt.Fprintf(w, "  %4d %8d %10d %12d %8d\n", z.Zoom, z.Tiles, z.Features, z.Coordinates, z.Roads)
+	}
+	fmt.Fprintln(w)
+
+	if len(r.Warnings) == 0 {
+		fmt.Fprintln(w, "No unexpected divergence")
+		return
+	}
+	fmt.Fprintf(w, "Divergences (%d):\n", len(r.Warnings))
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "  ! %s\n", warning)
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *PipelineReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode pipeline report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportKML has two roads and a folder whose only segment is a single point,
+// which conversion drops
+const reportKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+<Folder><name>Ridge Road</name><Placemark><LineString><coordinates>-122.40,47.55,0 -122.30,47.60,0 -122.20,47.65,0</coordinates></LineString></Placemark></Folder>
+<Folder><name>Valley Road</name>
+<Placemark><LineString><coordinates>-122.10,47.40,0 -122.00,47.45,0</coordinates></LineString></Placemark>
+<Placemark><LineString><coordinates>-122.00,47.45,0 -121.90,47.50,0</coordinates></LineString></Placemark>
+</Folder>
+<Folder><name>Stub</name><Placemark><LineString><coordinates>-121.50,47.00,0</coordinates></LineString></Placemark></Folder>
+</Document></kml>`
+
+// setupReportRegion writes region's KMZ and generates its tiles the way
+// generate does, returning the service configured with both directories
+func setupReportRegion(t *testing.T, region string) *TileService {
+	t.Helper()
+	dataDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeKMZ(t, dataDir, region, reportKML)
+
+	ctx := context.Background()
+	kmlPath, err := ExtractKMZFromDir(ctx, region, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	geoJSONPath, _, err := ConvertKMLToGeoJSON(ctx, kmlPath, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := GenerateTilesWithOptions(ctx, geoJSONPath, region, outputDir,
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 9, Encoder: EncoderGo}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CleanupTemporaryFiles(ctx, kmlPath, geoJSONPath, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTileService(nil, nil, &Config{Paths: PathsConfig{CurvatureData: dataDir, OutputDir: outputDir}})
+}
+
+func TestPipelineReport_EndToEnd(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	svc := setupReportRegion(t, "reportregion")
+
+	report, err := svc.PipelineReport(context.Background(), "reportregion", ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.KML.Folders != 3 || report.KML.Placemarks != 4 || report.KML.Coordinates != 8 {
+		t.Errorf("unexpected KML counts %+v", report.KML)
+	}
+	if report.GeoJSON.Features != 2 || report.GeoJSON.Coordinates != 7 {
+		t.Errorf("unexpected GeoJSON counts %+v", report.GeoJSON)
+	}
+
+	if len(report.Tiles) != 5 {
+		t.Fatalf("expected zooms 5-9, got %+v", report.Tiles)
+	}
+	for i, z := range report.Tiles {
+		if z.Zoom != 5+i || z.Tiles == 0 || z.Features < 2 || z.Roads != 2 || z.Coordinates < report.GeoJSON.Coordinates {
+			t.Errorf("unexpected zoom counts %+v", z)
+		}
+	}
+
+	// Only the stub road lost in conversion is flagged; tiles keep every road and point
+	want := []string{
+		"KML→GeoJSON: 1 of 3 KML roads (folders) have no GeoJSON feature",
+		"KML→GeoJSON: coordinate points changed from 8 to 7 (clipping or single-point segments)",
+	}
+	if strings.Join(report.Warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected warnings %q, got %q", want, report.Warnings)
+	}
+
+	// The extracted KML and the GeoJSON converted for the report are removed
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the temp dir to be empty, found %v", entries)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, line := range []string{"Pipeline report: reportregion", "folders (roads):      3", "Divergences (2):"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in the printed report:\n%s", line, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded PipelineReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.GeoJSON.Features != 2 || len(decoded.Tiles) != 5 {
+		t.Errorf("unexpected JSON report %s (%v)", out.String(), err)
+	}
+}
+
+func TestPipelineReport_UsesGivenFiles(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	svc := setupReportRegion(t, "givenregion")
+
+	// A GeoJSON with only one road stands in for a kept intermediate file.
+	// Of several kept files the newest is used.
+	older := filepath.Join(os.TempDir(), "givenregion_roads-older.geojson")
+	if err := os.WriteFile(older, []byte(`{"type":"FeatureCollection","features":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(older, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	geoJSONPath := filepath.Join(os.TempDir(), "givenregion_roads-kept.geojson")
+	if err := os.WriteFile(geoJSONPath, []byte(`{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"id":"a"},"geometry":{"type":"LineString","coordinates":[[-122.4,47.55],[-122.3,47.6]]}}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(reportKML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.PipelineReport(context.Background(), "givenregion", ReportOptions{KMLPath: kmlPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.KML.Path != kmlPath || report.GeoJSON.Path != geoJSONPath || report.GeoJSON.Features != 1 {
+		t.Errorf("expected the given KML and the kept GeoJSON, got %+v %+v", report.KML, report.GeoJSON)
+	}
+	if _, err := os.Stat(geoJSONPath); err != nil {
+		t.Errorf("expected a kept intermediate GeoJSON to be left in place: %v", err)
+	}
+
+	if _, err := svc.PipelineReport(context.Background(), "givenregion", ReportOptions{KMLPath: kmlPath, TilesPath: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing tiles directory")
+	}
+}
+
+func TestConvertKMLToGeoJSON_UniqueIntermediateFiles(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	kmlPath := filepath.Join(t.TempDir(), "roads.kml")
+	if err := os.WriteFile(kmlPath, []byte(reportKML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two conversions of one region, as a generate and a report running at
+	// once, each get their own file
+	first, _, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, "sameregion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := ConvertKMLToGeoJSON(context.Background(), kmlPath, "sameregion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || filepath.Dir(first) != tmp {
+		t.Fatalf("expected two files in %s, got %s and %s", tmp, first, second)
+	}
+	for _, path := range []string{first, second} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 || info.Mode().Perm() != 0644 {
+			t.Errorf("expected a readable GeoJSON at %s (%v)", path, err)
+		}
+	}
+}
+
+func TestPipelineReportDivergences(t *testing.T) {
+	base := PipelineReport{
+		KML:     KMLCounts{Folders: 10, Coordinates: 1000},
+		GeoJSON: GeoJSONCounts{Features: 10, Coordinates: 1000},
+		Tiles: []ZoomCounts{
+			{Zoom: 5, Roads: 4, Coordinates: 100},
+			{Zoom: 6, Roads: 10, Coordinates: 1100},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *PipelineReport)
+		want   []string
+	}{
+		{"consistent", func(r *PipelineReport) {}, nil},
+		{"small coordinate loss is tolerated", func(r *PipelineReport) { r.Tiles[1].Coordinates = 950 }, nil},
+		{"roads lost in conversion", func(r *PipelineReport) { r.GeoJSON.Features = 8 }, []string{
+			"KML→GeoJSON: 2 of 10 KML roads (folders) have no GeoJSON feature",
+		}},
+		{"coordinates changed in conversion", func(r *PipelineReport) { r.GeoJSON.Coordinates = 990 }, []string{
+			"KML→GeoJSON: coordinate points changed from 1000 to 990 (clipping or single-point segments)",
+		}},
+		{"roads dropped at max zoom", func(r *PipelineReport) { r.Tiles[1].Roads = 7 }, []string{
+			"GeoJSON→tiles: 3 of 10 roads missing at max zoom 6",
+		}},
+		{"coordinates lost at max zoom", func(r *PipelineReport) { r.Tiles[1].Coordinates = 500 }, []string{
+			"GeoJSON→tiles: max zoom 6 has 500 of 1000 coordinate points (50.0% lost)",
+		}},
+		{"zoom gap", func(r *PipelineReport) { r.Tiles[1].Zoom = 8 }, []string{
+			"GeoJSON→tiles: zoom 6 has no tiles",
+			"GeoJSON→tiles: zoom 7 has no tiles",
+		}},
+		{"no tiles", func(r *PipelineReport) { r.Tiles = nil }, []string{"GeoJSON→tiles: no tiles found"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base
+			r.Tiles = append([]ZoomCounts(nil), base.Tiles...)
+			tt.modify(&r)
+			if got := r.divergences(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// RetryPolicy controls how a whole object transfer is retried after a
+// transient failure. The SDK already retries individual requests; this also
+// covers failures it does not, such as a connection dropped while a body
+// streams. Delays grow exponentially and are jittered so that many workers
+// failing at once do not retry in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts per object, including the first
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each one after
+	MaxDelay    time.Duration // Upper bound on the delay between attempts
+}
+
+// DefaultRetryPolicy is used by an S3Client with no Retry policy set
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// delay returns how long to wait before retry n (1-based)
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// jitter returns a random duration in [d/2, d]
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d-d/2+1)
+}
+
+// retryTransient calls fn until it succeeds, fails with an error that is not
+// transient, or has been attempted policy.MaxAttempts times. The wait between
+// attempts is cut short when ctx is cancelled.
+func retryTransient(ctx context.Context, policy RetryPolicy, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !isTransientError(err) {
+			return err
+		}
+
+		delay := jitter(policy.delay(attempt))
+		slog.Warn("retrying after transient S3 error",
+			"operation", op,
+			"attempt", attempt,
+			"max_attempts", policy.MaxAttempts,
+			"delay", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransientError reports whether err is worth retrying: connection
+// failures, truncated bodies, throttling and 5xx responses. Missing objects
+// and cancellation are not.
+func isTransientError(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrObjectNotFound):
+		return false
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// retryPolicy returns the client's retry policy, or DefaultRetryPolicy if
+// none is set
+func (s *S3Client) retryPolicy() RetryPolicy {
+	if s.Retry.MaxAttempts > 0 {
+		return s.Retry
+	}
+	return DefaultRetryPolicy
+}
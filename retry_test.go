@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// httpResponseError builds the error the SDK returns for a response status
+func httpResponseError(status int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      errors.New(http.StatusText(status)),
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"cancelled", fmt.Errorf("download: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"not found", fmt.Errorf("%w: tiles/1/1/1.pbf", ErrObjectNotFound), false},
+		{"body cut off", fmt.Errorf("failed to download: %w", io.ErrUnexpectedEOF), true},
+		{"connection reset", errors.New("read tcp: connection reset by peer"), true},
+		{"dial failure", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"service unavailable", httpResponseError(http.StatusServiceUnavailable), true},
+		{"forbidden", httpResponseError(http.StatusForbidden), false},
+		{"throttled", &smithy.GenericAPIError{Code: "SlowDown"}, true},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, false},
+		{"local failure", errors.New("failed to create file: permission denied"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	permanent := errors.New("access denied")
+
+	tests := []struct {
+		name      string
+		errs      []error // Returned by successive calls, then nil
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds first time", nil, 1, nil},
+		{"succeeds after transient failures", []error{io.ErrUnexpectedEOF, io.ErrUnexpectedEOF}, 3, nil},
+		{"gives up at max attempts", []error{io.ErrUnexpectedEOF, io.ErrUnexpectedEOF, io.ErrUnexpectedEOF, io.ErrUnexpectedEOF}, 3, io.ErrUnexpectedEOF},
+		{"permanent error is not retried", []error{permanent}, 1, permanent},
+		{"permanent error after a transient one", []error{io.ErrUnexpectedEOF, permanent}, 2, permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryTransient(context.Background(), policy, "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryTransient_StopsWaitingOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	calls := 0
+	start := time.Now()
+	err := retryTransient(ctx, policy, "test", func() error {
+		calls++
+		cancel()
+		return io.ErrUnexpectedEOF
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || calls != 1 {
+		t.Errorf("expected the first error after one call, got %v after %d calls", err, calls)
+	}
+	if time.Since(start) > time.Minute {
+		t.Error("expected cancellation to cut the backoff short")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := policy.delay(i + 1); got != w {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := policy.delay(200); got != time.Second {
+		t.Errorf("expected an overflowing delay to be capped, got %v", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 2, 3, time.Second} {
+		for i := 0; i < 100; i++ {
+			if got := jitter(d); got < d/2 || got > d {
+				t.Fatalf("jitter(%v) = %v, want within [%v, %v]", d, got, d/2, d)
+			}
+		}
+	}
+}
+
+// flakyUploader fails each key's first failures uploads with err
+type flakyUploader struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	calls    map[string]int
+	bodies   map[string]string
+}
+
+func (u *flakyUploader) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := *input.Key
+	u.calls[key]++
+	if u.calls[key] <= u.failures {
+		return nil, u.err
+	}
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	u.bodies[key] = string(data)
+	return &manager.UploadOutput{Key: input.Key}, nil
+}
+
+func TestUploadDirectory_RetriesTransientFailures(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"5/1/1.pbf": "tile-a", "5/1/2.pbf": "tile-b", "6/2/3.pbf": "tile-c"}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds after two transient failures", 2, httpResponseError(http.StatusServiceUnavailable), 3, false},
+		{"gives up after max attempts", 3, io.ErrUnexpectedEOF, 3, true},
+		{"permanent error is not retried", 1, httpResponseError(http.StatusForbidden), 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploader := &flakyUploader{failures: tt.failures, err: tt.err, calls: map[string]int{}, bodies: map[string]string{}}
+			client := &S3Client{
+				bucket:   "bucket",
+				uploader: uploader,
+				Retry:    RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			}
+
+			_, err := client.UploadDirectory(context.Background(), dir, "tiles")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				// The first failure cancels the other files, so only the
+				// failed file is sure to have used all its attempts
+				failed := 0
+				for key, calls := range uploader.calls {
+					if calls > tt.wantCalls {
+						t.Errorf("%s: expected at most %d calls, got %d", key, tt.wantCalls, calls)
+					}
+					if calls == tt.wantCalls {
+						failed++
+					}
+				}
+				if failed == 0 {
+					t.Errorf("expected a file to fail after %d calls, got %v", tt.wantCalls, uploader.calls)
+				}
+				return
+			}
+			for rel, content := range files {
+				key := "tiles/" + rel
+				if uploader.calls[key] != tt.wantCalls || uploader.bodies[key] != content {
+					t.Errorf("%s: expected %q after %d calls, got %q after %d", key, content, tt.wantCalls, uploader.bodies[key], uploader.calls[key])
+				}
+			}
+		})
+	}
+}
+
+func TestUploadOne_StopsRetryingOnCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.pbf")
+	if err := os.WriteFile(path, []byte("tile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uploader := &flakyUploader{failures: 10, err: io.ErrUnexpectedEOF, calls: map[string]int{}, bodies: map[string]string{}}
+	client := &S3Client{
+		bucket:   "bucket",
+		uploader: uploader,
+		Retry:    RetryPolicy{MaxAttempts: 10, BaseDelay: time.Hour, MaxDelay: time.Hour},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.uploadOne(ctx, path, "tiles/1.pbf"); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if calls := uploader.calls["tiles/1.pbf"]; calls != 1 {
+		t.Errorf("expected cancellation to stop retries after 1 call, got %d", calls)
+	}
+}
+
+func TestNewS3Client_MaxAttempts(t *testing.T) {
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	for _, attempts := range []int{0, 2} {
+		client, err := NewS3Client(S3Config{Endpoint: "http://localhost", Region: "auto", Bucket: "bucket", MaxAttempts: attempts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DefaultRetryPolicy
+		if attempts > 0 {
+			want.MaxAttempts = attempts
+		}
+		if got := client.retryPolicy(); got != want {
+			t.Errorf("S3_MAX_ATTEMPTS=%d: expected policy %+v, got %+v", attempts, want, got)
+		}
+	}
+}
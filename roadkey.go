@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RoadKeyStrategy is how roads are identified when they are deduplicated
+// and upserted
+type RoadKeyStrategy int
+
+const (
+	// RoadKeyRegional keys roads by road ID and region, so the same road in
+	// two overlapping regions is two rows (the default)
+	RoadKeyRegional RoadKeyStrategy = iota
+	// RoadKeyGlobal keys roads by a hash of their name and rounded start and
+	// end points, so overlapping regions share one row
+	RoadKeyGlobal
+)
+
+// globalKeyDecimals is how many decimals coordinates are rounded to for
+// RoadKeyGlobal (~100 m), absorbing float noise between tilesets
+const globalKeyDecimals = 3
+
+// Key returns the key the road is deduplicated by under strategy.
+//
+// The global key hashes the lowercased name with the road's start and end
+// points, which the converter records for the whole road, so every region's
+// tiles agree on them even where their bounding boxes differ (a box is
+// assembled from the tiles the road was read from, and a region edge clips
+// it). Hashing the rounded box instead would key a road two overlapping
+// regions clip differently twice, which is the duplication this key is for.
+// The points are ordered so either direction keys the same. Roads without
+// both points fall back to the rounded bounding box.
+func (r *RoadGeometry) Key(strategy RoadKeyStrategy) string {
+	if strategy != RoadKeyGlobal {
+		return r.RoadID + "_" + r.Region
+	}
+
+	name := strings.ToLower(strings.TrimSpace(r.Name))
+	h := sha256.New()
+	if r.StartLat != nil && r.StartLng != nil && r.EndLat != nil && r.EndLng != nil {
+		start := keyCoord(*r.StartLat) + "," + keyCoord(*r.StartLng)
+		end := keyCoord(*r.EndLat) + "," + keyCoord(*r.EndLng)
+		if end < start {
+			start, end = end, start
+		}
+		fmt.Fprintf(h, "%s|points|%s|%s", name, start, end)
+	} else {
+		fmt.Fprintf(h, "%s|bbox|%s|%s|%s|%s", name,
+			keyCoord(r.MinLat), keyCoord(r.MaxLat), keyCoord(r.MinLng), keyCoord(r.MaxLng))
+	}
+	return "g_" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// keyCoord formats a coordinate rounded to globalKeyDecimals
+func keyCoord(v float64) string {
+	scale := math.Pow10(globalKeyDecimals)
+	rounded := math.Round(v*scale)/scale + 0 // + 0 turns -0 into 0
+	return strconv.FormatFloat(rounded, 'f', globalKeyDecimals, 64)
+}
+
+// roadIDIndexName is the unique index on "roadId" that RoadKeyGlobal upserts
+// conflict on, created by the migration in docs/SCHEMA_SYNC.md
+const roadIDIndexName = "RoadGeometry_roadId_key"
+
+// isGlobalRoadKey reports whether id has the form of a RoadKeyGlobal key
+func isGlobalRoadKey(id string) bool {
+	if len(id) != 18 || !strings.HasPrefix(id, "g_") {
+		return false
+	}
+	_, err := hex.DecodeString(id[2:])
+	return err == nil
+}
+
+// conflictTarget returns the ON CONFLICT columns of the "RoadGeometry"
+// unique constraint matching the strategy
+func (s RoadKeyStrategy) conflictTarget() string {
+	if s == RoadKeyGlobal {
+		return `"roadId"`
+	}
+	return `"roadId", region`
+}
+
+// dedupRoadsGlobally re-keys roads by RoadKeyGlobal, merging roads with the
+// same key into one whose RoadID is that key. The merged road has the union
+// of their bounding boxes; its other fields come from the road with the
+// lowest regional key, so the result does not depend on map order.
+func dedupRoadsGlobally(roads map[string]*RoadGeometry) map[string]*RoadGeometry {
+	keys := make([]string, 0, len(roads))
+	for key := range roads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	merged := make(map[string]*RoadGeometry, len(roads))
+	for _, key := range keys {
+		road := *roads[key]
+		global := road.Key(RoadKeyGlobal)
+		existing, exists := merged[global]
+		if !exists {
+			road.RoadID = global
+			merged[global] = &road
+			continue
+		}
+		existing.MinLat = math.Min(existing.MinLat, road.MinLat)
+		existing.MaxLat = math.Max(existing.MaxLat, road.MaxLat)
+		existing.MinLng, existing.MaxLng = unionLngRange(existing.MinLng, existing.MaxLng, road.MinLng, road.MaxLng)
+	}
+	return merged
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// ptr returns a pointer to v
+func ptr(v float64) *float64 { return &v }
+
+func TestRoadGeometryKey(t *testing.T) {
+	road := RoadGeometry{
+		RoadID: "oregon_Hwy 1", Name: "Hwy 1", Region: "oregon",
+		MinLat: 44.1234, MaxLat: 44.5678, MinLng: -123.4567, MaxLng: -123.1234,
+		StartLat: ptr(44.1234), StartLng: ptr(-123.4567), EndLat: ptr(44.5678), EndLng: ptr(-123.1234),
+	}
+
+	if got := road.Key(RoadKeyRegional); got != "oregon_Hwy 1_oregon" {
+		t.Errorf("expected the regional key oregon_Hwy 1_oregon, got %q", got)
+	}
+
+	global := road.Key(RoadKeyGlobal)
+	if !strings.HasPrefix(global, "g_") || len(global) != 18 {
+		t.Fatalf("expected g_ and 16 hex digits, got %q", global)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *RoadGeometry)
+		same   bool
+	}{
+		{"other region and road ID", func(r *RoadGeometry) { r.Region, r.RoadID = "washington", "washington_Hwy 1" }, true},
+		{"name case and spaces", func(r *RoadGeometry) { r.Name = "  HWY 1 " }, true},
+		{"box clipped at a region edge", func(r *RoadGeometry) { r.MinLat, r.MaxLng = 44.3, -123.3 }, true},
+		{"points differ below the rounding", func(r *RoadGeometry) { r.StartLat = ptr(44.1232); r.EndLng = ptr(-123.1232) }, true},
+		{"reversed direction", func(r *RoadGeometry) {
+			r.StartLat, r.StartLng, r.EndLat, r.EndLng = r.EndLat, r.EndLng, r.StartLat, r.StartLng
+		}, true},
+		{"other name", func(r *RoadGeometry) { r.Name = "Hwy 2" }, false},
+		{"other end point", func(r *RoadGeometry) { r.EndLat = ptr(44.6) }, false},
+		{"no points, so keyed by box", func(r *RoadGeometry) { r.EndLat = nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := road
+			tt.modify(&other)
+			if got := other.Key(RoadKeyGlobal); (got == global) != tt.same {
+				t.Errorf("expected same key %v, got %q and %q", tt.same, global, got)
+			}
+		})
+	}
+}
+
+func TestRoadGeometryKey_BoxFallback(t *testing.T) {
+	road := RoadGeometry{Name: "Hwy 1", MinLat: 44.1234, MaxLat: 44.5678, MinLng: -123.4567, MaxLng: -123.1234}
+	global := road.Key(RoadKeyGlobal)
+
+	tests := []struct {
+		name   string
+		modify func(r *RoadGeometry)
+		same   bool
+	}{
+		{"box differs below the rounding", func(r *RoadGeometry) { r.MinLat -= 0.0002; r.MaxLng += 0.0001 }, true},
+		{"only one point", func(r *RoadGeometry) { r.StartLat, r.StartLng = ptr(44.1234), ptr(-123.4567) }, true},
+		{"other box", func(r *RoadGeometry) { r.MaxLat += 0.01 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := road
+			tt.modify(&other)
+			if got := other.Key(RoadKeyGlobal); (got == global) != tt.same {
+				t.Errorf("expected same key %v, got %q and %q", tt.same, global, got)
+			}
+		})
+	}
+
+	// -0 rounds to the same key as 0
+	a := RoadGeometry{Name: "Zero", MinLng: -0.0001, MaxLng: 1}
+	b := RoadGeometry{Name: "Zero", MinLng: 0, MaxLng: 1}
+	if a.Key(RoadKeyGlobal) != b.Key(RoadKeyGlobal) {
+		t.Error("expected a box rounding to -0 to key like 0")
+	}
+}
+
+func TestDedupRoadsGlobally(t *testing.T) {
+	curvature := "1200"
+	hwy1 := func(region string, minLat, maxLat float64) *RoadGeometry {
+		return &RoadGeometry{
+			RoadID: region + "_Hwy 1", Name: "Hwy 1", Region: region,
+			MinLat: minLat, MaxLat: maxLat, MinLng: -123.5, MaxLng: -123.1,
+			StartLat: ptr(44.1), StartLng: ptr(-123.5), EndLat: ptr(44.9), EndLng: ptr(-123.1),
+		}
+	}
+	// Each region read Hwy 1 from its own tiles, so their boxes differ
+	oregon, washington := hwy1("oregon", 44.1, 44.6), hwy1("washington", 44.4, 44.9)
+	oregon.Curvature = &curvature
+	roads := map[string]*RoadGeometry{
+		"oregon_Hwy 1_oregon":         oregon,
+		"washington_Hwy 1_washington": washington,
+		"oregon_Hwy 2_oregon":         {RoadID: "oregon_Hwy 2", Name: "Hwy 2", Region: "oregon", MinLat: 45, MaxLat: 45.1, MinLng: -122, MaxLng: -121.9},
+	}
+
+	merged := dedupRoadsGlobally(roads)
+	if len(merged) != 2 {
+		t.Fatalf("expected the two copies of Hwy 1 to merge into 2 roads, got %d", len(merged))
+	}
+	for key, road := range merged {
+		if road.RoadID != key || road.Key(RoadKeyGlobal) != key {
+			t.Errorf("expected road %q to be keyed by its global key, got %q", road.RoadID, key)
+		}
+	}
+
+	got := merged[oregon.Key(RoadKeyGlobal)]
+	if got == nil {
+		t.Fatal("expected Hwy 1 under its global key")
+	}
+	if got.Region != "oregon" || got.Curvature == nil {
+		t.Errorf("expected fields from the lowest regional key (oregon), got region %q curvature %v", got.Region, got.Curvature)
+	}
+	if got.MinLat != 44.1 || got.MaxLat != 44.9 {
+		t.Errorf("expected the union of both boxes, got %v..%v", got.MinLat, got.MaxLat)
+	}
+	if oregon.RoadID != "oregon_Hwy 1" {
+		t.Error("expected the input roads to be left unchanged")
+	}
+}
+
+func TestBatchUpsertRoadGeometries_GlobalKeys(t *testing.T) {
+	db := newRoadsTestDB(t)
+	if _, err := db.conn.Exec(`CREATE UNIQUE INDEX "RoadGeometry_roadId_key" ON "RoadGeometry"("roadId")`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overlapping regions with different boxes for the same road
+	oregon := RoadGeometry{
+		Name: "Hwy 1", Region: "oregon", MinLat: 44.1, MaxLat: 44.6, MinLng: -123.5, MaxLng: -123.1,
+		StartLat: ptr(44.1), StartLng: ptr(-123.5), EndLat: ptr(44.9), EndLng: ptr(-123.1),
+	}
+	washington := oregon
+	washington.Region, washington.MinLat, washington.MaxLat = "washington", 44.4, 44.9
+	for _, road := range []RoadGeometry{oregon, washington} {
+		road.RoadID = road.Key(RoadKeyGlobal)
+		if _, err := db.BatchUpsertRoadGeometries(context.Background(), []RoadGeometry{road}, RoadKeyGlobal, 10, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rows int
+	var region string
+	var minLat, maxLat float64
+	if err := db.conn.QueryRow(`SELECT COUNT(*), MIN(region), MIN("minLat"), MAX("maxLat") FROM "RoadGeometry"`).Scan(&rows, &region, &minLat, &maxLat); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Fatalf("expected the overlapping regions to share one row, got %d", rows)
+	}
+	if region != "oregon" || minLat != 44.1 || maxLat != 44.9 {
+		t.Errorf("expected the first region and the union of the boxes, got region %q box %v..%v", region, minLat, maxLat)
+	}
+}
+
+func TestBatchUpsertRoadGeometries_GlobalKeysNeedRoadIDIndex(t *testing.T) {
+	db := newRoadsTestDB(t)
+
+	road := RoadGeometry{Name: "Hwy 2", Region: "oregon", MinLat: 44, MaxLat: 45, MinLng: -124, MaxLng: -123}
+	road.RoadID = road.Key(RoadKeyGlobal)
+	_, err := db.BatchUpsertRoadGeometries(context.Background(), []RoadGeometry{road}, RoadKeyGlobal, 10, nil)
+	if err == nil || !strings.Contains(err.Error(), roadIDIndexName) {
+		t.Fatalf("expected an error naming the missing index, got %v", err)
+	}
+
+	var rows int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM "RoadGeometry"`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("expected no rows without the index, got %d", rows)
+	}
+}
+
+func TestIsGlobalRoadKey(t *testing.T) {
+	road := RoadGeometry{Name: "Hwy 1", Region: "oregon", MinLat: 44, MaxLat: 45, MinLng: -124, MaxLng: -123}
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{road.Key(RoadKeyGlobal), true},
+		{road.Key(RoadKeyRegional), false},
+		{"g_not-hex-digits!", false},
+		{"g_3f9a0c1d2e4b5a6", false},
+	}
+	for _, tt := range tests {
+		if got := isGlobalRoadKey(tt.id); got != tt.want {
+			t.Errorf("isGlobalRoadKey(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// roadShardCount is how many independently locked shards roadShards splits
+// roads across
+const roadShardCount = 32
+
+// roadShards deduplicates roads decoded from many tiles at once. Roads are
+// spread across shards by a hash of their key, so extraction workers merging
+// different roads rarely wait on each other.
+//
+// A road seen in several tiles gets the union of their bounding boxes, across
+// the antimeridian when that is narrower (unionLngRange). Its other fields
+// come from the earliest tile it was seen in (lowest order), as a single
+// worker merging tiles in order would keep them, so the result does not
+// depend on which worker finished first.
+type roadShards struct {
+	shards [roadShardCount]roadShard
+}
+
+type roadShard struct {
+	mu    sync.Mutex
+	roads map[string]*shardedRoad
+}
+
+// shardedRoad is a merged road and the order of the tile its fields came from
+type shardedRoad struct {
+	road  RoadGeometry
+	order int
+}
+
+func newRoadShards() *roadShards {
+	s := &roadShards{}
+	for i := range s.shards {
+		s.shards[i].roads = make(map[string]*shardedRoad)
+	}
+	return s
+}
+
+// roadKey is the key roads are deduplicated by
+func roadKey(road RoadGeometry) string {
+	return road.Key(RoadKeyRegional)
+}
+
+func (s *roadShards) shard(key string) *roadShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%roadShardCount]
+}
+
+// merge adds a road seen in the tile at order, expanding the bounding box of
+// a road already seen. Returns true if the road is new.
+func (s *roadShards) merge(road RoadGeometry, order int) bool {
+	key := roadKey(road)
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	existing, exists := shard.roads[key]
+	if !exists {
+		shard.roads[key] = &shardedRoad{road: road, order: order}
+		return true
+	}
+
+	minLat := math.Min(existing.road.MinLat, road.MinLat)
+	maxLat := math.Max(existing.road.MaxLat, road.MaxLat)
+	minLng, maxLng := unionLngRange(existing.road.MinLng, existing.road.MaxLng, road.MinLng, road.MaxLng)
+	if order < existing.order {
+		existing.road, existing.order = road, order
+	}
+	existing.road.MinLat, existing.road.MaxLat = minLat, maxLat
+	existing.road.MinLng, existing.road.MaxLng = minLng, maxLng
+	return false
+}
+
+// len returns how many distinct roads have been merged
+func (s *roadShards) len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].roads)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// combined returns every shard's roads in one map, keyed as roadKey
+func (s *roadShards) combined() map[string]*RoadGeometry {
+	roads := make(map[string]*RoadGeometry)
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		for key, merged := range s.shards[i].roads {
+			road := merged.road
+			roads[key] = &road
+		}
+		s.shards[i].mu.Unlock()
+	}
+	return roads
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRoadShards_MatchesSequentialUnion(t *testing.T) {
+	// Roads as decoded from 500 tiles, many sharing a road ID
+	rng := rand.New(rand.NewSource(1))
+	type decoded struct {
+		road  RoadGeometry
+		order int
+	}
+	var all []decoded
+	for tile := 0; tile < 500; tile++ {
+		for n := 0; n < 20; n++ {
+			lat, lng := 40+rng.Float64()*5, -120+rng.Float64()*5
+			all = append(all, decoded{RoadGeometry{
+				RoadID: fmt.Sprintf("road-%d", rng.Intn(300)),
+				Name:   fmt.Sprintf("seen in tile %d", tile),
+				Region: []string{"oregon", "washington"}[rng.Intn(2)],
+				MinLat: lat, MaxLat: lat + rng.Float64(),
+				MinLng: lng, MaxLng: lng + rng.Float64(),
+			}, tile})
+		}
+	}
+
+	// The single-threaded union: tiles in order, the first sighting keeps
+	// its fields and every sighting widens the box
+	want := make(map[string]*RoadGeometry)
+	for _, d := range all {
+		key := fmt.Sprintf("%s_%s", d.road.RoadID, d.road.Region)
+		if existing, ok := want[key]; ok {
+			existing.MinLat = math.Min(existing.MinLat, d.road.MinLat)
+			existing.MaxLat = math.Max(existing.MaxLat, d.road.MaxLat)
+			existing.MinLng = math.Min(existing.MinLng, d.road.MinLng)
+			existing.MaxLng = math.Max(existing.MaxLng, d.road.MaxLng)
+		} else {
+			road := d.road
+			want[key] = &road
+		}
+	}
+
+	for _, workers := range []int{1, 4, 32} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			// Workers take the roads in a scrambled order
+			shuffled := append([]decoded(nil), all...)
+			rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+			shards := newRoadShards()
+			var created atomic.Int64
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := w; i < len(shuffled); i += workers {
+						if shards.merge(shuffled[i].road, shuffled[i].order) {
+							created.Add(1)
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			if got := shards.combined(); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected the sharded result to match the sequential union (%d roads, got %d)", len(want), len(got))
+			}
+			if int(created.Load()) != len(want) || shards.len() != len(want) {
+				t.Errorf("expected %d new roads, merge reported %d and len %d", len(want), created.Load(), shards.len())
+			}
+		})
+	}
+}
+
+func TestRoadShards_MergesAcrossAntimeridian(t *testing.T) {
+	shards := newRoadShards()
+	// One road seen in the last tile column and the first
+	shards.merge(RoadGeometry{RoadID: "r", Region: "fiji", MinLat: -17, MaxLat: -16.9, MinLng: 179.8, MaxLng: 180.03}, 0)
+	shards.merge(RoadGeometry{RoadID: "r", Region: "fiji", MinLat: -17.1, MaxLat: -17, MinLng: -179.95, MaxLng: -179.8}, 1)
+
+	road := shards.combined()["r_fiji"]
+	if road == nil {
+		t.Fatal("expected the road to be merged")
+	}
+	if math.Abs(road.MinLng-179.8) > 1e-9 || math.Abs(road.MaxLng-180.2) > 1e-9 || road.MinLat != -17.1 || road.MaxLat != -16.9 {
+		t.Errorf("expected the narrow box across the antimeridian, got %+v", road)
+	}
+}
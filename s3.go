@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,30 +25,52 @@ import (
 	"github.com/aws/smithy-go"
 )
 
+// DefaultUploadConcurrency is how many files upload at once unless
+// UploadConcurrency says otherwise
+const DefaultUploadConcurrency = 8
+
 // S3Client wraps AWS S3 operations for R2
 type S3Client struct {
 	client     *s3.Client
 	bucket     string
 	bucketPath string
-	uploader   *manager.Uploader
+	uploader   objectUploader
+
+	// Dedup enables content-hash deduplication in UploadDirectory
+	Dedup bool
+
+	// SkipEmptyBelow skips .pbf tiles smaller than this many bytes (0 = upload all)
+	SkipEmptyBelow int64
+	// SkipEmptyStrict only skips small tiles that also decode to zero features
+	SkipEmptyStrict bool
+
+	// Progress receives the files uploaded so far in place of the periodic
+	// progress logs (nil = log)
+	Progress ProgressFunc
+	// BytesProgress receives the bytes uploaded so far out of the total of
+	// a directory upload, alongside Progress and the logs
+	BytesProgress ProgressFunc
+
+	// Retry controls how uploads and downloads of single objects are retried
+	// after transient failures (zero value = DefaultRetryPolicy)
+	Retry RetryPolicy
+
+	// UploadConcurrency is how many files directory uploads send at once
+	// (0 = DefaultUploadConcurrency)
+	UploadConcurrency int
+
+	// Headers are the Content-Type and Cache-Control of uploaded files by
+	// extension, e.g. ".pbf" (nil = DefaultObjectHeaders)
+	Headers map[string]ObjectHeaders
+}
+
+// objectUploader uploads a single object; *manager.Uploader satisfies it
+type objectUploader interface {
+	Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error)
 }
 
 // NewS3Client creates a new S3 client for Cloudflare R2
 func NewS3Client(cfg S3Config) (*S3Client, error) {
-	logger := slog.With("endpoint", cfg.Endpoint, "bucket", cfg.Bucket)
-	logger.Info("initializing S3 client for R2")
-
-	// Create custom resolver for R2 endpoint
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if service == s3.ServiceID {
-			return aws.Endpoint{
-				URL:           cfg.Endpoint,
-				SigningRegion: cfg.Region,
-			}, nil
-		}
-		return aws.Endpoint{}, &smithy.GenericAPIError{Code: "UnknownEndpoint"}
-	})
-
 	// Create custom HTTP client with connection pooling optimized for parallel uploads
 	// MaxIdleConnsPerHost should match or exceed the number of upload workers (100)
 	// to ensure connections are reused instead of constantly opened/closed
@@ -65,6 +89,25 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 		Timeout: 5 * time.Minute, // Overall request timeout
 	}
 
+	return newS3ClientWithHTTPClient(cfg, httpClient)
+}
+
+// newS3ClientWithHTTPClient creates the S3 client on top of the given HTTP client
+func newS3ClientWithHTTPClient(cfg S3Config, httpClient *http.Client) (*S3Client, error) {
+	logger := slog.With("endpoint", cfg.Endpoint, "bucket", cfg.Bucket)
+	logger.Info("initializing S3 client for R2")
+
+	// Create custom resolver for R2 endpoint
+	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		if service == s3.ServiceID {
+			return aws.Endpoint{
+				URL:           cfg.Endpoint,
+				SigningRegion: cfg.Region,
+			}, nil
+		}
+		return aws.Endpoint{}, &smithy.GenericAPIError{Code: "UnknownEndpoint"}
+	})
+
 	// Load AWS config
 	awsCfg, err := config.LoadDefaultConfig(context.Background(),
 		config.WithHTTPClient(httpClient),
@@ -75,6 +118,7 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 		)),
 		config.WithRegion(cfg.Region),
 		config.WithEndpointResolverWithOptions(customResolver),
+		config.WithAPIOptions(s3RequestTracing(cfg.UserAgent)),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
@@ -90,28 +134,106 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 
 	logger.Info("S3 client initialized successfully")
 
-	return &S3Client{
+	client := &S3Client{
 		client:     s3Client,
 		bucket:     cfg.Bucket,
 		bucketPath: cfg.BucketPath,
 		uploader:   uploader,
-	}, nil
+
+		UploadConcurrency: cfg.UploadConcurrency,
+		Headers:           cfg.ObjectHeaders,
+	}
+	if cfg.MaxAttempts > 0 {
+		client.Retry = DefaultRetryPolicy
+		client.Retry.MaxAttempts = cfg.MaxAttempts
+	}
+	return client, nil
 }
 
-// UploadDirectory uploads all files from a directory to S3 using parallel workers
+// UploadDirectory uploads all files from a directory to S3 using parallel workers.
+// When Dedup is enabled, files with identical content are uploaded once and
+// the other keys are created server-side with CopyObject.
 func (s *S3Client) UploadDirectory(ctx context.Context, localDir, s3Prefix string) (int64, error) {
-	logger := slog.With("local_dir", localDir, "s3_prefix", s3Prefix)
-	logger.Info("starting parallel directory upload to R2")
+	stats, err := s.uploadDirectory(ctx, localDir, s3Prefix)
+	if err != nil {
+		return 0, err
+	}
+	return stats.TotalBytes, nil
+}
 
-	// First, collect all files to upload
-	type fileToUpload struct {
-		path     string
-		relPath  string
-		s3Key    string
-		size     int64
+// UploadStats summarizes a directory upload
+type UploadStats struct {
+	Files      int   // Files written to the bucket (uploads + copies)
+	Copies     int   // Files created with CopyObject instead of uploading
+	Skipped    int   // Empty tiles not uploaded (SkipEmptyBelow)
+	TotalBytes int64 // Logical bytes written to the bucket
+	BytesSaved int64 // Bytes not sent because the content was already uploaded
+
+	ByZoom map[int]ZoomUploadStats // Tiles written per zoom level (z/x/y.pbf keys only)
+}
+
+// ZoomUploadStats counts tiles and bytes uploaded for one zoom level
+type ZoomUploadStats struct {
+	Count int
+	Bytes int64
+}
+
+// UploadSummary aggregates one or more directory uploads, broken down by zoom
+type UploadSummary struct {
+	TotalBytes int64
+	Files      int
+	ByZoom     map[int]ZoomUploadStats
+}
+
+// add folds a directory upload's stats into the summary
+func (u *UploadSummary) add(stats *UploadStats) {
+	u.TotalBytes += stats.TotalBytes
+	u.Files += stats.Files
+	for z, zs := range stats.ByZoom {
+		cur := u.ByZoom[z]
+		cur.Count += zs.Count
+		cur.Bytes += zs.Bytes
+		u.ByZoom[z] = cur
 	}
+}
 
-	var files []fileToUpload
+// Print logs the per-zoom breakdown in zoom order
+func (u *UploadSummary) Print() {
+	zooms := make([]int, 0, len(u.ByZoom))
+	for z := range u.ByZoom {
+		zooms = append(zooms, z)
+	}
+	sort.Ints(zooms)
+
+	for _, z := range zooms {
+		slog.Info("zoom level uploaded", "zoom", z, "tiles", u.ByZoom[z].Count, "bytes", u.ByZoom[z].Bytes)
+	}
+	slog.Info("upload summary", "zoom_levels", len(zooms), "files", u.Files, "total_bytes", u.TotalBytes)
+}
+
+// zoomFromKey extracts the zoom level from a .../z/x/y.pbf object key
+func zoomFromKey(s3Key string) (int, bool) {
+	parts := strings.Split(s3Key, "/")
+	if len(parts) < 3 || !strings.HasSuffix(parts[len(parts)-1], ".pbf") {
+		return 0, false
+	}
+	z, err := strconv.Atoi(parts[len(parts)-3])
+	if err != nil {
+		return 0, false
+	}
+	return z, true
+}
+
+// uploadDirectory uploads the files under localDir to s3Prefix with the
+// shared worker pool. The root metadata.json is not among them: it is logged
+// and skipped here, and UploadMetadata uploads it to the region's own key.
+func (s *S3Client) uploadDirectory(ctx context.Context, localDir, s3Prefix string) (*UploadStats, error) {
+	logger := slog.With("local_dir", localDir, "s3_prefix", s3Prefix, "dedup", s.Dedup)
+	logger.Info("starting parallel directory upload to R2")
+
+	// First, collect all files to upload
+	var files []uploadFile
+	skipped := 0
 
 	err := filepath.Walk(localDir, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -122,6 +244,22 @@ func (s *S3Client) UploadDirectory(ctx context.Context, localDir, s3Prefix strin
 			return nil
 		}
 
+		// Job definitions are local records, not tiles
+		if isJobDefinitionFile(info.Name()) {
+			return nil
+		}
+
+		// The tileset metadata goes to a per-region key (UploadMetadata)
+		if filePath == filepath.Join(localDir, MetadataFileName) {
+			logger.Info("skipping tileset metadata, uploaded separately to the region's metadata key", "path", filePath)
+			return nil
+		}
+
+		if s.skipTile(filePath, info.Size()) {
+			skipped++
+			return nil
+		}
+
 		relPath, err := filepath.Rel(localDir, filePath)
 		if err != nil {
 			return err
@@ -129,7 +267,7 @@ func (s *S3Client) UploadDirectory(ctx context.Context, localDir, s3Prefix strin
 
 		s3Key := filepath.Join(s3Prefix, filepath.ToSlash(relPath))
 
-		files = append(files, fileToUpload{
+		files = append(files, uploadFile{
 			path:    filePath,
 			relPath: relPath,
 			s3Key:   s3Key,
@@ -141,82 +279,173 @@ func (s *S3Client) UploadDirectory(ctx context.Context, localDir, s3Prefix strin
 
 	if err != nil {
 		logger.Error("failed to scan directory", "error", err)
-		return 0, fmt.Errorf("failed to scan directory: %w", err)
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
 	}
 
-	logger.Info("found files to upload", "count", len(files))
+	logger.Info("found files to upload", "count", len(files), "skipped_empty", skipped)
+
+	// With dedup, split into first-seen content (uploaded) and duplicates
+	// (copied from the first key once the uploads have finished)
+	uploads := files
+	var copies []uploadFile
+	if s.Dedup {
+		manifest, err := BuildFileManifest(localDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash files for dedup: %w", err)
+		}
+		hashes := make(map[string]string, len(manifest))
+		for _, entry := range manifest {
+			hashes[entry.Path] = entry.SHA256
+		}
+
+		firstKey := make(map[string]string)
+		uploads = nil
+		for _, file := range files {
+			sum, ok := hashes[filepath.ToSlash(file.relPath)]
+			if !ok {
+				uploads = append(uploads, file)
+				continue
+			}
+			if key, seen := firstKey[sum]; seen {
+				file.copyFrom = key
+				copies = append(copies, file)
+				continue
+			}
+			firstKey[sum] = file.s3Key
+			uploads = append(uploads, file)
+		}
+		logger.Info("deduplicated files by content", "unique", len(uploads), "duplicates", len(copies))
+	}
 
 	// Upload files in parallel using worker pool
-	const numWorkers = 100 // Parallel upload workers
-	var totalBytes int64
-	var fileCount int
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+	stats := &UploadStats{Skipped: skipped, ByZoom: make(map[int]ZoomUploadStats)}
+	var allBytes int64
+	for _, file := range files {
+		allBytes += file.size
+	}
+
+	done := func(file uploadFile) {
+		stats.TotalBytes += file.size
+		stats.Files++
+		if file.copyFrom != "" {
+			stats.Copies++
+			stats.BytesSaved += file.size
+		}
+		if z, ok := zoomFromKey(file.s3Key); ok {
+			zs := stats.ByZoom[z]
+			zs.Count++
+			zs.Bytes += file.size
+			stats.ByZoom[z] = zs
+		}
+		if s.Progress != nil {
+			s.Progress(stats.Files, len(files))
+		}
+		if s.BytesProgress != nil {
+			s.BytesProgress(int(stats.TotalBytes), int(allBytes))
+		}
+
+		// Log progress every 1000 files
+		if s.Progress == nil && stats.Files%1000 == 0 {
+			logger.Info("upload progress", "files_uploaded", stats.Files, "bytes_uploaded", stats.TotalBytes)
+		}
+	}
+
+	if err := s.runUploadPool(ctx, uploads, done); err != nil {
+		logger.Error("upload failed", "error", err)
+		return nil, err
+	}
+	if err := s.runUploadPool(ctx, copies, done); err != nil {
+		logger.Error("upload failed", "error", err)
+		return nil, err
+	}
+
+	logger.Info("directory upload completed",
+		"total_files", stats.Files,
+		"total_bytes", stats.TotalBytes,
+		"copies", stats.Copies,
+		"bytes_saved", stats.BytesSaved,
+		"skipped_empty", stats.Skipped,
+	)
+	return stats, nil
+}
+
+// uploadFile is a file for runUploadPool to upload
+type uploadFile struct {
+	path     string
+	relPath  string
+	s3Key    string
+	size     int64
+	copyFrom string // Key of an identical object already uploaded in this run
+}
+
+// runUploadPool uploads batch with uploadWorkers parallel workers, calling
+// done after each file, one call at a time. The first failure cancels the
+// files still queued or in flight.
+func (s *S3Client) runUploadPool(ctx context.Context, batch []uploadFile, done func(uploadFile)) error {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	numWorkers := s.uploadWorkers()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Create channel for work distribution
-	workChan := make(chan fileToUpload, numWorkers*2)
+	workChan := make(chan uploadFile, numWorkers*2)
 	errChan := make(chan error, 1)
 
 	// Start workers
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go func(workerID int) {
+		go func() {
 			defer wg.Done()
 
 			for file := range workChan {
-				// Open file
-				f, err := os.Open(file.path)
-				if err != nil {
-					select {
-					case errChan <- fmt.Errorf("failed to open file %s: %w", file.relPath, err):
-					default:
+				var err error
+				if file.copyFrom != "" {
+					_, err = s.client.CopyObject(ctx, &s3.CopyObjectInput{
+						Bucket:     aws.String(s.bucket),
+						Key:        aws.String(file.s3Key),
+						CopySource: aws.String(s.bucket + "/" + file.copyFrom),
+						ACL:        types.ObjectCannedACLPublicRead,
+					})
+					if err != nil {
+						err = fmt.Errorf("failed to copy file %s: %w", file.relPath, err)
+					}
+				} else {
+					err = s.uploadOne(ctx, file.path, file.s3Key)
+					if err != nil {
+						err = fmt.Errorf("failed to upload file %s: %w", file.relPath, err)
 					}
-					return
 				}
 
-				// Upload file
-				_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
-					Bucket: aws.String(s.bucket),
-					Key:    aws.String(file.s3Key),
-					Body:   f,
-					ACL:    types.ObjectCannedACLPublicRead,
-				})
-				f.Close()
-
 				if err != nil {
 					select {
-					case errChan <- fmt.Errorf("failed to upload file %s: %w", file.relPath, err):
+					case errChan <- err:
 					default:
 					}
+					cancel()
 					return
 				}
 
-				// Update stats
 				mu.Lock()
-				totalBytes += file.size
-				fileCount++
-				currentCount := fileCount
-				currentBytes := totalBytes
+				done(file)
 				mu.Unlock()
-
-				// Log progress every 1000 files
-				if currentCount%1000 == 0 {
-					logger.Info("upload progress", "files_uploaded", currentCount, "bytes_uploaded", currentBytes)
-				}
 			}
-		}(i)
+		}()
 	}
 
 	// Send work to workers
 	go func() {
-		for _, file := range files {
+		defer close(workChan)
+		for _, file := range batch {
 			select {
 			case <-ctx.Done():
 				return
 			case workChan <- file:
 			}
 		}
-		close(workChan)
 	}()
 
 	// Wait for completion
@@ -225,12 +454,87 @@ func (s *S3Client) UploadDirectory(ctx context.Context, localDir, s3Prefix strin
 
 	// Check for errors
 	if err := <-errChan; err != nil {
-		logger.Error("upload failed", "error", err)
-		return 0, err
+		return err
+	}
+	return ctx.Err()
+}
+
+// withBytesProgress returns a copy of the client that reports upload bytes to
+// fn, so one job's progress can be followed on a client shared by several
+func (s *S3Client) withBytesProgress(fn ProgressFunc) *S3Client {
+	c := *s
+	c.BytesProgress = fn
+	return &c
+}
+
+// uploadWorkers is how many files to upload at once
+func (s *S3Client) uploadWorkers() int {
+	if s.UploadConcurrency > 0 {
+		return s.UploadConcurrency
 	}
+	return DefaultUploadConcurrency
+}
 
-	logger.Info("directory upload completed", "total_files", fileCount, "total_bytes", totalBytes)
-	return totalBytes, nil
+// uploadOne uploads a single local file to the given key, retrying
+// transient failures
+func (s *S3Client) uploadOne(ctx context.Context, filePath, s3Key string) error {
+	return retryTransient(ctx, s.retryPolicy(), "upload "+s3Key, func() error {
+		return s.uploadOnce(ctx, filePath, s3Key)
+	})
+}
+
+// uploadOnce makes a single attempt at uploading a local file
+func (s *S3Client) uploadOnce(ctx context.Context, filePath, s3Key string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = s.uploader.Upload(ctx, s.putObjectInput(f, s3Key, UploadOptions{}))
+	return err
+}
+
+// putObjectInput builds the upload of f to s3Key: public-read, with the
+// headers configured for the file's extension (opts take precedence), and
+// Content-Encoding gzip when such a file is gzipped
+func (s *S3Client) putObjectInput(f *os.File, s3Key string, opts UploadOptions) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s3Key),
+		Body:   f,
+		ACL:    types.ObjectCannedACLPublicRead,
+	}
+	if opts.ACL != "" {
+		input.ACL = opts.ACL
+	}
+
+	headers, configured := s.objectHeaders(f.Name())
+	if opts.ContentType != "" {
+		headers.ContentType = opts.ContentType
+	}
+	if opts.CacheControl != "" {
+		headers.CacheControl = opts.CacheControl
+	}
+	if headers.ContentType != "" {
+		input.ContentType = aws.String(headers.ContentType)
+	}
+	if headers.CacheControl != "" {
+		input.CacheControl = aws.String(headers.CacheControl)
+	}
+	if configured && isGzipped(f) {
+		input.ContentEncoding = aws.String("gzip")
+	}
+	return input
+}
+
+// skipTile reports whether a file should be left out of the upload because
+// it is an empty tile under the configured threshold
+func (s *S3Client) skipTile(filePath string, size int64) bool {
+	if s.SkipEmptyBelow <= 0 || filepath.Ext(filePath) != ".pbf" {
+		return false
+	}
+	return isEmptyTile(filePath, size, s.SkipEmptyBelow, s.SkipEmptyStrict)
 }
 
 // UploadTilesWithFilter uploads only tiles from a directory that match the given coordinates
@@ -248,6 +552,7 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 	}
 
 	var files []fileToUpload
+	skipped := 0
 
 	err := filepath.Walk(localDir, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -288,6 +593,11 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 			return nil // Skip tiles not in filter
 		}
 
+		if s.skipTile(filePath, info.Size()) {
+			skipped++
+			return nil
+		}
+
 		s3Key := filepath.Join(s3Prefix, filepath.ToSlash(relPath))
 
 		files = append(files, fileToUpload{
@@ -305,14 +615,18 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 		return 0, fmt.Errorf("failed to scan directory: %w", err)
 	}
 
-	logger.Info("found tiles matching filter", "count", len(files), "filter_count", len(coords))
+	logger.Info("found tiles matching filter", "count", len(files), "filter_count", len(coords), "skipped_empty", skipped)
 
 	// Upload files in parallel using worker pool
-	const numWorkers = 100
+	numWorkers := s.uploadWorkers()
 	var totalBytes int64
 	var fileCount int
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var allBytes int64
+	for _, file := range files {
+		allBytes += file.size
+	}
 
 	workChan := make(chan fileToUpload, numWorkers*2)
 	errChan := make(chan error, 1)
@@ -324,24 +638,7 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 			defer wg.Done()
 
 			for file := range workChan {
-				f, err := os.Open(file.path)
-				if err != nil {
-					select {
-					case errChan <- fmt.Errorf("failed to open file %s: %w", file.relPath, err):
-					default:
-					}
-					return
-				}
-
-				_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
-					Bucket: aws.String(s.bucket),
-					Key:    aws.String(file.s3Key),
-					Body:   f,
-					ACL:    types.ObjectCannedACLPublicRead,
-				})
-				f.Close()
-
-				if err != nil {
+				if err := s.uploadOne(ctx, file.path, file.s3Key); err != nil {
 					select {
 					case errChan <- fmt.Errorf("failed to upload file %s: %w", file.relPath, err):
 					default:
@@ -354,9 +651,15 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 				fileCount++
 				currentCount := fileCount
 				currentBytes := totalBytes
+				if s.Progress != nil {
+					s.Progress(currentCount, len(files))
+				}
+				if s.BytesProgress != nil {
+					s.BytesProgress(int(currentBytes), int(allBytes))
+				}
 				mu.Unlock()
 
-				if currentCount%1000 == 0 {
+				if s.Progress == nil && currentCount%1000 == 0 {
 					logger.Info("upload progress", "files_uploaded", currentCount, "bytes_uploaded", currentBytes)
 				}
 			}
@@ -365,6 +668,7 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 
 	// Send work to workers
 	go func() {
+		defer close(workChan)
 		for _, file := range files {
 			select {
 			case <-ctx.Done():
@@ -372,7 +676,6 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 			case workChan <- file:
 			}
 		}
-		close(workChan)
 	}()
 
 	wg.Wait()
@@ -382,13 +685,69 @@ func (s *S3Client) UploadTilesWithFilter(ctx context.Context, localDir, s3Prefix
 		logger.Error("upload failed", "error", err)
 		return 0, err
 	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 
 	logger.Info("filtered upload completed", "total_files", fileCount, "total_bytes", totalBytes)
 	return totalBytes, nil
 }
 
-// UploadFile uploads a single file to S3
+// UploadFiles uploads the given files of localDir, as slash-separated paths
+// relative to it, to the same paths under s3Prefix using parallel workers.
+// Returns the total bytes uploaded.
+func (s *S3Client) UploadFiles(ctx context.Context, localDir, s3Prefix string, relPaths []string) (int64, error) {
+	logger := slog.With("local_dir", localDir, "s3_prefix", s3Prefix, "count", len(relPaths))
+	logger.Info("uploading changed files to R2")
+
+	files := make([]uploadFile, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		filePath := filepath.Join(localDir, filepath.FromSlash(relPath))
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upload file %s: %w", relPath, err)
+		}
+		files = append(files, uploadFile{
+			path:    filePath,
+			relPath: relPath,
+			s3Key:   s3Prefix + "/" + relPath,
+			size:    info.Size(),
+		})
+	}
+
+	var totalBytes int64
+	fileCount := 0
+	err := s.runUploadPool(ctx, files, func(file uploadFile) {
+		totalBytes += file.size
+		fileCount++
+		if s.Progress != nil {
+			s.Progress(fileCount, len(files))
+		}
+	})
+	if err != nil {
+		logger.Error("upload failed", "error", err)
+		return totalBytes, err
+	}
+
+	logger.Info("changed files uploaded", "total_files", fileCount, "total_bytes", totalBytes)
+	return totalBytes, nil
+}
+
+// UploadOptions sets the headers of a single-file upload
+type UploadOptions struct {
+	ACL          types.ObjectCannedACL // "" = public-read
+	ContentType  string                // "" = by extension (Headers), or left to R2
+	CacheControl string                // "" = by extension (Headers), or none
+}
+
+// UploadFile uploads a single public-read file to S3
 func (s *S3Client) UploadFile(ctx context.Context, filePath, s3Key string) (int64, error) {
+	return s.UploadFileWithOptions(ctx, filePath, s3Key, UploadOptions{})
+}
+
+// UploadFileWithOptions uploads a single file to S3 with the given ACL,
+// Content-Type and Cache-Control
+func (s *S3Client) UploadFileWithOptions(ctx context.Context, filePath, s3Key string, opts UploadOptions) (int64, error) {
 	logger := slog.With("file_path", filePath, "s3_key", s3Key)
 	logger.Debug("uploading file to R2")
 
@@ -406,12 +765,7 @@ func (s *S3Client) UploadFile(ctx context.Context, filePath, s3Key string) (int6
 	defer file.Close()
 
 	// Upload file
-	result, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s3Key),
-		Body:   file,
-		ACL:    types.ObjectCannedACLPublicRead,
-	})
+	result, err := s.uploader.Upload(ctx, s.putObjectInput(file, s3Key, opts))
 
 	if err != nil {
 		logger.Error("upload failed", "error", err)
@@ -441,12 +795,31 @@ func (s *S3Client) DeleteObject(ctx context.Context, s3Key string) error {
 	return nil
 }
 
+// ObjectInfo is a listed object's key and size
+type ObjectInfo struct {
+	Key  string
+	Size int64
+}
+
 // ListObjects lists objects in S3
 func (s *S3Client) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	infos, err := s.ListObjectInfos(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]string, len(infos))
+	for i, info := range infos {
+		objects[i] = info.Key
+	}
+	return objects, nil
+}
+
+// ListObjectInfos lists objects in S3 with their sizes
+func (s *S3Client) ListObjectInfos(ctx context.Context, prefix string) ([]ObjectInfo, error) {
 	logger := slog.With("prefix", prefix)
 	logger.Debug("listing objects from R2")
 
-	var objects []string
+	var objects []ObjectInfo
 
 	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
@@ -460,7 +833,11 @@ func (s *S3Client) ListObjects(ctx context.Context, prefix string) ([]string, er
 		}
 
 		for _, obj := range page.Contents {
-			objects = append(objects, *obj.Key)
+			info := ObjectInfo{Key: *obj.Key}
+			if obj.Size != nil {
+				info.Size = *obj.Size
+			}
+			objects = append(objects, info)
 		}
 	}
 
@@ -496,6 +873,95 @@ func (s *S3Client) HeadObject(ctx context.Context, s3Key string) (int64, bool, e
 	return size, true, nil
 }
 
+// ErrObjectNotFound is returned by GetObject and GetObjectReader for a key
+// that does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// GetObjectReader opens an object for streaming. The caller must close the
+// reader. A missing key returns an error wrapping ErrObjectNotFound.
+func (s *S3Client) GetObjectReader(ctx context.Context, s3Key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if ok := errors.As(err, &noSuchKey); ok {
+			return nil, fmt.Errorf("%s: %w", s3Key, ErrObjectNotFound)
+		}
+		// Also check the error code of generic API errors
+		var apiErr smithy.APIError
+		if ok := errors.As(err, &apiErr); ok && (apiErr.ErrorCode() == "NoSuchKey" || apiErr.ErrorCode() == "NotFound") {
+			return nil, fmt.Errorf("%s: %w", s3Key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", s3Key, err)
+	}
+	return result.Body, nil
+}
+
+// GetObject downloads an object's contents into memory. A missing key
+// returns an error wrapping ErrObjectNotFound.
+func (s *S3Client) GetObject(ctx context.Context, s3Key string) ([]byte, error) {
+	body, err := s.GetObjectReader(ctx, s3Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", s3Key, err)
+	}
+	return data, nil
+}
+
+// DownloadFile downloads an object to filePath, creating its directory. The
+// object is written to a temporary file first so an interrupted download
+// never leaves a partial file at filePath. Transient failures, including a
+// body cut off mid-stream, are retried. Returns the bytes written.
+func (s *S3Client) DownloadFile(ctx context.Context, s3Key, filePath string) (int64, error) {
+	var size int64
+	err := retryTransient(ctx, s.retryPolicy(), "download "+s3Key, func() error {
+		var err error
+		size, err = s.downloadOnce(ctx, s3Key, filePath)
+		return err
+	})
+	return size, err
+}
+
+// downloadOnce makes a single attempt at downloading an object to filePath
+func (s *S3Client) downloadOnce(ctx context.Context, s3Key, filePath string) (int64, error) {
+	body, err := s.GetObjectReader(ctx, s3Key)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".download-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %w", s3Key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return 0, fmt.Errorf("failed to move download into place: %w", err)
+	}
+	return size, nil
+}
+
 // GetPublicURL returns the public URL for an object
 func (s *S3Client) GetPublicURL(s3Key string) string {
 	// For Cloudflare R2, construct the public URL
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the per-operation request ID on every S3 request so
+// failures can be matched against R2 logs
+const RequestIDHeader = "X-Request-Id"
+
+// requestIDKey is the middleware stack key for the per-operation request ID
+type requestIDKey struct{}
+
+// s3RequestTracing returns SDK middleware that prepends userAgent to the
+// User-Agent header and tags each operation with a request ID, which is
+// logged (with R2's own request ID, when available) if the operation fails
+func s3RequestTracing(userAgent string) []func(*middleware.Stack) error {
+	requestID := middleware.InitializeMiddlewareFunc("TileServiceRequestID", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		id := uuid.NewString()
+		ctx = middleware.WithStackValue(ctx, requestIDKey{}, id)
+
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if err != nil {
+			serverID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+			slog.Error("S3 request failed",
+				"operation", awsmiddleware.GetOperationName(ctx),
+				"request_id", id,
+				"server_request_id", serverID,
+				"error", err,
+			)
+		}
+		return out, metadata, err
+	})
+
+	// Headers are set at the end of the finalize step so they are applied to
+	// every retry attempt
+	headers := middleware.FinalizeMiddlewareFunc("TileServiceHeaders", func(
+		ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+	) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if req, ok := in.Request.(*smithyhttp.Request); ok {
+			if userAgent != "" {
+				current := req.Header.Get("User-Agent")
+				if !strings.HasPrefix(current, userAgent) {
+					req.Header.Set("User-Agent", strings.TrimSpace(userAgent+" "+current))
+				}
+			}
+			if id, ok := middleware.GetStackValue(ctx, requestIDKey{}).(string); ok {
+				req.Header.Set(RequestIDHeader, id)
+			}
+		}
+		return next.HandleFinalize(ctx, in)
+	})
+
+	return []func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(requestID, middleware.After)
+		},
+		func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(headers, middleware.After)
+		},
+	}
+}
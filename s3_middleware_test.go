@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// capturingTransport records outgoing requests and answers with a fixed status
+type capturingTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	status   int
+}
+
+func (c *capturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: c.status,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func newCapturingS3Client(t *testing.T, status int, userAgent string) (*capturingTransport, *S3Client) {
+	t.Helper()
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	transport := &capturingTransport{status: status}
+	client, err := newS3ClientWithHTTPClient(S3Config{
+		Endpoint:        "http://r2.test",
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		Region:          "auto",
+		Bucket:          "bucket",
+		UserAgent:       userAgent,
+	}, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport, client
+}
+
+func TestS3RequestTracing_UserAgentAndRequestID(t *testing.T) {
+	transport, client := newCapturingS3Client(t, http.StatusOK, "tile-service-test/1.0")
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.HeadObject(context.Background(), "tiles/5/1/1.pbf"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(transport.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(transport.requests))
+	}
+
+	ids := map[string]bool{}
+	for _, req := range transport.requests {
+		ua := req.Header.Get("User-Agent")
+		if !strings.HasPrefix(ua, "tile-service-test/1.0 ") {
+			t.Errorf("expected User-Agent to start with configured value, got %q", ua)
+		}
+		if !strings.Contains(ua, "aws-sdk-go-v2") {
+			t.Errorf("expected SDK User-Agent to be kept, got %q", ua)
+		}
+		id := req.Header.Get(RequestIDHeader)
+		if id == "" {
+			t.Error("expected request ID header")
+		}
+		ids[id] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected a distinct request ID per operation, got %v", ids)
+	}
+}
+
+func TestS3RequestTracing_NoUserAgentKeepsSDKDefault(t *testing.T) {
+	transport, client := newCapturingS3Client(t, http.StatusOK, "")
+
+	if _, _, err := client.HeadObject(context.Background(), "tiles/5/1/1.pbf"); err != nil {
+		t.Fatal(err)
+	}
+	if ua := transport.requests[0].Header.Get("User-Agent"); !strings.HasPrefix(ua, "aws-sdk-go-v2") {
+		t.Errorf("expected default SDK User-Agent, got %q", ua)
+	}
+}
+
+func TestS3RequestTracing_FailureStillTagged(t *testing.T) {
+	transport, client := newCapturingS3Client(t, http.StatusForbidden, "tile-service-test/1.0")
+
+	if _, _, err := client.HeadObject(context.Background(), "tiles/5/1/1.pbf"); err == nil {
+		t.Fatal("expected error for forbidden request")
+	}
+	if len(transport.requests) == 0 || transport.requests[0].Header.Get(RequestIDHeader) == "" {
+		t.Error("expected failed request to carry a request ID")
+	}
+}
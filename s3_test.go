@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+)
+
+// fakeS3 is a minimal path-style S3 endpoint that records puts, copies and
+// deletes, and answers ListObjectsV2 in pages of listPageSize keys (0 = a
+// single page)
+type fakeS3 struct {
+	mu        sync.Mutex
+	objects   map[string]bool
+	puts      map[string]int64 // key -> payload bytes received
+	copies    map[string]string
+	copyOrder []string
+	deletes   []string
+	headers   map[string]http.Header // key -> headers of the last put
+	bodies    map[string][]byte      // key -> content, served by GET
+	gets      map[string]int         // key -> GET requests received
+	failGets  map[string]int         // key -> GETs left to cut off mid-body
+
+	listPageSize int
+	listRequests int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Client) {
+	t.Helper()
+	// Keep the host's AWS environment out of the client config
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	f := &fakeS3{objects: map[string]bool{}, puts: map[string]int64{}, copies: map[string]string{}, headers: map[string]http.Header{}, bodies: map[string][]byte{}, gets: map[string]int{}, failGets: map[string]int{}}
+	srv := httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(srv.Close)
+
+	client, err := NewS3Client(S3Config{
+		Endpoint:        srv.URL,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		Region:          "auto",
+		Bucket:          "bucket",
+		BucketPath:      "tiles",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeS3) handle(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("continuation-token"))
+		return
+	case r.Method == http.MethodGet:
+		body, ok := f.bodies[key]
+		if !ok || !f.objects[key] {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			return
+		}
+		f.gets[key]++
+		if f.failGets[key] > 0 {
+			// Promise the whole body but drop the connection halfway
+			f.failGets[key]--
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			return
+		}
+		w.Write(body)
+		return
+	case r.Method == http.MethodHead:
+		if !f.objects[key] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		f.deletes = append(f.deletes, key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method != http.MethodPut:
+		http.Error(w, "unsupported", http.StatusNotImplemented)
+		return
+	}
+
+	if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+		srcKey := strings.TrimPrefix(src, "bucket/")
+		if !f.objects[srcKey] {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		f.copies[key] = srcKey
+		f.objects[key] = true
+		w.Write([]byte(`<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	size := int64(len(body))
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+		size, _ = strconv.ParseInt(decoded, 10, 64)
+	}
+	f.puts[key] = size
+	f.headers[key] = r.Header.Clone()
+	f.bodies[key] = body
+	f.objects[key] = true
+	w.Header().Set("ETag", `"etag"`)
+}
+
+// seedObjects stores fixture objects in the fake S3
+func (f *fakeS3) seedObjects(objects map[string]string) {
+	for key, body := range objects {
+		f.objects[key] = true
+		f.bodies[key] = []byte(body)
+		f.puts[key] = int64(len(body))
+	}
+}
+
+// list writes a ListObjectsV2 response for every object under prefix
+func (f *fakeS3) list(w http.ResponseWriter, prefix, token string) {
+	f.listRequests++
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// The continuation token is the index of the page's first key
+	start, _ := strconv.Atoi(token)
+	keys = keys[min(start, len(keys)):]
+	next := ""
+	if f.listPageSize > 0 && len(keys) > f.listPageSize {
+		keys = keys[:f.listPageSize]
+		next = strconv.Itoa(start + f.listPageSize)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>bucket</Name>`)
+	fmt.Fprintf(&b, "<IsTruncated>%t</IsTruncated>", next != "")
+	if next != "" {
+		fmt.Fprintf(&b, "<NextContinuationToken>%s</NextContinuationToken>", next)
+	}
+	fmt.Fprintf(&b, "<KeyCount>%d</KeyCount>", len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, f.puts[key])
+	}
+	b.WriteString("</ListBucketResult>")
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(b.String()))
+}
+
+// writeDedupFixture writes a small tile tree where several tiles share content
+func writeDedupFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"5/1/1.pbf": "empty-tile",
+		"5/1/2.pbf": "empty-tile",
+		"5/2/1.pbf": "empty-tile",
+		"6/1/1.pbf": "road-tile-with-more-bytes",
+		"6/1/2.pbf": "road-tile-with-more-bytes",
+		"6/1/3.pbf": "unique",
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestUploadDirectory_Dedup(t *testing.T) {
+	fake, client := newFakeS3(t)
+	client.Dedup = true
+	dir := writeDedupFixture(t)
+
+	stats, err := client.uploadDirectory(context.Background(), dir, "tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.puts) != 3 {
+		t.Errorf("expected 3 unique uploads, got %d: %v", len(fake.puts), fake.puts)
+	}
+	if len(fake.copies) != 3 {
+		t.Errorf("expected 3 copies, got %d: %v", len(fake.copies), fake.copies)
+	}
+	if len(fake.objects) != 6 {
+		t.Errorf("expected all 6 keys to exist, got %d", len(fake.objects))
+	}
+
+	wantSaved := int64(2*len("empty-tile") + len("road-tile-with-more-bytes"))
+	if stats.BytesSaved != wantSaved {
+		t.Errorf("expected %d bytes saved, got %d", wantSaved, stats.BytesSaved)
+	}
+	if stats.Copies != 3 || stats.Files != 6 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	var sent int64
+	for _, n := range fake.puts {
+		sent += n
+	}
+	if sent != stats.TotalBytes-stats.BytesSaved {
+		t.Errorf("expected %d bytes on the wire, server received %d", stats.TotalBytes-stats.BytesSaved, sent)
+	}
+}
+
+func TestUploadDirectory_NoDedupUploadsEverything(t *testing.T) {
+	fake, client := newFakeS3(t)
+	dir := writeDedupFixture(t)
+
+	total, err := client.UploadDirectory(context.Background(), dir, "tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.puts) != 6 || len(fake.copies) != 0 {
+		t.Errorf("expected 6 uploads and no copies, got %d uploads and %d copies", len(fake.puts), len(fake.copies))
+	}
+	if _, ok := fake.puts["tiles/6/1/3.pbf"]; !ok {
+		t.Errorf("expected key tiles/6/1/3.pbf, got %v", fake.puts)
+	}
+	if total == 0 {
+		t.Error("expected non-zero total bytes")
+	}
+}
+
+// countingUploader records how many uploads run at once, and fails the key
+// in fail with a permanent error
+type countingUploader struct {
+	mu       sync.Mutex
+	running  int
+	peak     int
+	uploaded map[string]bool
+	fail     string
+}
+
+func (u *countingUploader) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	u.mu.Lock()
+	u.running++
+	u.peak = max(u.peak, u.running)
+	u.mu.Unlock()
+	defer func() {
+		u.mu.Lock()
+		u.running--
+		u.mu.Unlock()
+	}()
+
+	if *input.Key == u.fail {
+		return nil, httpResponseError(http.StatusForbidden)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Millisecond):
+	}
+
+	u.mu.Lock()
+	u.uploaded[*input.Key] = true
+	u.mu.Unlock()
+	return &manager.UploadOutput{Key: input.Key}, nil
+}
+
+// writeTileFiles writes n small .pbf files under dir
+func writeTileFiles(t *testing.T, dir string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, "10", strconv.Itoa(i), "1.pbf")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("tile"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadDirectory_Concurrency(t *testing.T) {
+	dir := t.TempDir()
+	writeTileFiles(t, dir, 40)
+
+	tests := []struct {
+		concurrency int
+		wantMax     int
+	}{
+		{1, 1},
+		{4, 4},
+		{0, DefaultUploadConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("concurrency %d", tt.concurrency), func(t *testing.T) {
+			uploader := &countingUploader{uploaded: map[string]bool{}}
+			client := &S3Client{bucket: "bucket", uploader: uploader, UploadConcurrency: tt.concurrency}
+
+			total, err := client.UploadDirectory(context.Background(), dir, "tiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(uploader.uploaded) != 40 || total != 40*4 {
+				t.Errorf("expected 40 files and 160 bytes, got %d files and %d bytes", len(uploader.uploaded), total)
+			}
+			if uploader.peak > tt.wantMax {
+				t.Errorf("expected at most %d uploads at once, got %d", tt.wantMax, uploader.peak)
+			}
+		})
+	}
+}
+
+func TestUploadDirectory_FirstErrorCancels(t *testing.T) {
+	dir := t.TempDir()
+	writeTileFiles(t, dir, 200)
+
+	// Files are queued in walk order, so 10/0/1.pbf is among the first
+	uploader := &countingUploader{uploaded: map[string]bool{}, fail: "tiles/10/0/1.pbf"}
+	client := &S3Client{bucket: "bucket", uploader: uploader, UploadConcurrency: 2}
+
+	_, err := client.UploadDirectory(context.Background(), dir, "tiles")
+	if err == nil || !strings.Contains(err.Error(), filepath.Join("10", "0", "1.pbf")) {
+		t.Fatalf("expected the failing file's error, got %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first error rather than a cancellation, got %v", err)
+	}
+	if len(uploader.uploaded) >= 199 {
+		t.Errorf("expected the failure to stop the remaining uploads, %d of 199 uploaded", len(uploader.uploaded))
+	}
+}
+
+// cancellingUploader cancels the upload's context on its first call and
+// otherwise succeeds, ignoring the cancellation
+type cancellingUploader struct {
+	once   sync.Once
+	cancel context.CancelFunc
+}
+
+func (u *cancellingUploader) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	u.once.Do(u.cancel)
+	return &manager.UploadOutput{Key: input.Key}, nil
+}
+
+func TestUploadTilesWithFilter_Cancel(t *testing.T) {
+	dir := t.TempDir()
+	writeTileFiles(t, dir, 200)
+	coords := make(map[TileCoord]bool)
+	for x := 0; x < 200; x++ {
+		coords[TileCoord{Z: 10, X: x, Y: 1}] = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &S3Client{bucket: "bucket", uploader: &cancellingUploader{cancel: cancel}, UploadConcurrency: 2}
+
+	// The workers must still exit once the queue stops being fed
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.UploadTilesWithFilter(ctx, dir, "tiles", coords)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected a cancellation error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("filtered upload did not return after cancellation")
+	}
+}
+
+// writeEmptyTileFixture writes a tree with an empty tile, a small tile with
+// one feature, and a large tile
+func writeEmptyTileFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	encode := func(fc *geojson.FeatureCollection) []byte {
+		layers := mvt.NewLayers(map[string]*geojson.FeatureCollection{"roads": fc})
+		data, err := EncodeTile(layers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	empty := encode(geojson.NewFeatureCollection())
+
+	small := geojson.NewFeatureCollection()
+	small.Append(geojson.NewFeature(orb.Point{1, 1}))
+
+	large := geojson.NewFeatureCollection()
+	f := geojson.NewFeature(orb.LineString{{0, 0}, {100, 100}, {200, 50}})
+	f.Properties["Name"] = strings.Repeat("Curvy Road ", 10)
+	large.Append(f)
+
+	tiles := map[string][]byte{
+		"5/1/1.pbf": empty,
+		"5/1/2.pbf": encode(small),
+		"5/1/3.pbf": encode(large),
+	}
+	for rel, data := range tiles {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestUploadDirectory_SkipEmpty(t *testing.T) {
+	dir := writeEmptyTileFixture(t)
+
+	tests := []struct {
+		name        string
+		strict      bool
+		wantSkipped int
+		wantKeys    []string
+	}{
+		{"size only", false, 2, []string{"tiles/5/1/3.pbf"}},
+		{"strict decode", true, 1, []string{"tiles/5/1/2.pbf", "tiles/5/1/3.pbf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			client.SkipEmptyBelow = 50
+			client.SkipEmptyStrict = tt.strict
+
+			stats, err := client.uploadDirectory(context.Background(), dir, "tiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Skipped != tt.wantSkipped {
+				t.Errorf("expected %d skipped, got %d", tt.wantSkipped, stats.Skipped)
+			}
+			if len(fake.puts) != len(tt.wantKeys) {
+				t.Errorf("expected uploads %v, got %v", tt.wantKeys, fake.puts)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := fake.puts[key]; !ok {
+					t.Errorf("expected %s to be uploaded", key)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadToR2WithZoomFilter_PerZoomSummary(t *testing.T) {
+	dir := t.TempDir()
+	createFakeTile(t, dir, 5, 1, 1)
+	createFakeTile(t, dir, 6, 1, 1)
+	createFakeTile(t, dir, 6, 1, 2)
+	createFakeTile(t, dir, 7, 2, 1)
+	createFakeTile(t, dir, 7, 2, 2)
+	createFakeTile(t, dir, 7, 3, 1)
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tileSize := int64(len("fake-tile-data"))
+
+	tests := []struct {
+		name             string
+		minZoom, maxZoom int
+		want             map[int]int
+		wantFiles        int
+	}{
+		{"all zooms", -1, -1, map[int]int{5: 1, 6: 2, 7: 3}, 7}, // metadata.json counts as a file but not a tile
+		{"zoom range", 6, 7, map[int]int{6: 2, 7: 3}, 5},
+		{"min only", 7, -1, map[int]int{7: 3}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+
+			summary, err := service.UploadToR2WithZoomFilter(context.Background(), dir, "test", tt.minZoom, tt.maxZoom)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(summary.ByZoom) != len(tt.want) {
+				t.Errorf("expected zooms %v, got %v", tt.want, summary.ByZoom)
+			}
+			for z, count := range tt.want {
+				got := summary.ByZoom[z]
+				if got.Count != count {
+					t.Errorf("zoom %d: expected %d tiles, got %d", z, count, got.Count)
+				}
+				if got.Bytes != int64(count)*tileSize {
+					t.Errorf("zoom %d: expected %d bytes, got %d", z, int64(count)*tileSize, got.Bytes)
+				}
+			}
+			if summary.Files != tt.wantFiles || len(fake.puts) != tt.wantFiles {
+				t.Errorf("expected %d files, summary has %d and server got %d", tt.wantFiles, summary.Files, len(fake.puts))
+			}
+		})
+	}
+}
+
+func TestGetObject(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.seedObjects(map[string]string{"tiles/5/1/1.pbf": "tile-bytes"})
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		key         string
+		want        string
+		wantMissing bool
+	}{
+		{"existing key", "tiles/5/1/1.pbf", "tile-bytes", false},
+		{"missing key", "tiles/5/1/2.pbf", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := client.GetObject(ctx, tt.key)
+			if tt.wantMissing {
+				if !errors.Is(err, ErrObjectNotFound) {
+					t.Fatalf("expected ErrObjectNotFound, got %v", err)
+				}
+			} else if err != nil || string(data) != tt.want {
+				t.Fatalf("expected %q, got %q (%v)", tt.want, data, err)
+			}
+
+			body, err := client.GetObjectReader(ctx, tt.key)
+			if tt.wantMissing {
+				if !errors.Is(err, ErrObjectNotFound) {
+					t.Fatalf("reader: expected ErrObjectNotFound, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			streamed, err := io.ReadAll(body)
+			if err != nil || string(streamed) != tt.want {
+				t.Errorf("reader: expected %q, got %q (%v)", tt.want, streamed, err)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 // TileService orchestrates tile generation
@@ -14,6 +16,31 @@ type TileService struct {
 	db     *Database
 	s3     *S3Client
 	config *Config
+
+	// Progress receives extraction progress in place of the periodic
+	// progress logs (nil = log)
+	Progress ProgressFunc
+
+	// StepProgress receives each pipeline step of ProcessJobWithOptions as it
+	// starts, then its percentage through geometry extraction (tiles) and
+	// R2 upload (bytes) (nil = none)
+	StepProgress StepProgressFunc
+
+	// MaxTiles limits extraction from existing tiles to the first tiles in
+	// z/x/y order (0 = all)
+	MaxTiles int
+
+	// TileScope limits extraction from existing tiles to one tile or z/x
+	// column (nil = all)
+	TileScope *TileScope
+
+	// BoundsGeoJSON also writes roads extracted from existing tiles there as
+	// GeoJSON bounding boxes (empty = off)
+	BoundsGeoJSON string
+
+	// StepChange receives each phase of ProcessJobWithOptions as it is
+	// recorded as the job's currentStep (nil = none)
+	StepChange func(step string)
 }
 
 // NewTileService creates a new tile service
@@ -35,6 +62,12 @@ func (s *TileService) UploadToR2(ctx context.Context, tilesDir, region string) (
 		return 0, fmt.Errorf("failed to upload to R2: %w", err)
 	}
 
+	metadataBytes, err := s.UploadMetadata(ctx, tilesDir, region)
+	if err != nil {
+		return 0, err
+	}
+	totalBytes += metadataBytes
+
 	logger.Info("R2 upload completed", "total_bytes", totalBytes)
 	return totalBytes, nil
 }
@@ -42,6 +75,12 @@ func (s *TileService) UploadToR2(ctx context.Context, tilesDir, region string) (
 // UploadMergedTilesForRegion uploads only tiles from mergedDir that match the region's tile coordinates
 // This is efficient: we get merged content (multi-region roads) but only upload the target region's tiles
 func (s *TileService) UploadMergedTilesForRegion(ctx context.Context, mergedDir, regionDir, region string) (int64, error) {
+	return s.uploadMergedTilesForRegion(ctx, s.s3, mergedDir, regionDir, region)
+}
+
+// uploadMergedTilesForRegion is UploadMergedTilesForRegion with the tiles
+// sent through client
+func (s *TileService) uploadMergedTilesForRegion(ctx context.Context, client *S3Client, mergedDir, regionDir, region string) (int64, error) {
 	logger := slog.With("region", region, "merged_dir", mergedDir, "region_dir", regionDir)
 	logger.Info("starting R2 upload of merged tiles for region only")
 
@@ -54,40 +93,57 @@ func (s *TileService) UploadMergedTilesForRegion(ctx context.Context, mergedDir,
 	logger.Info("uploading merged tiles for region coordinates", "tile_count", len(regionCoords))
 
 	// Upload only the tiles that match the region's coordinates
-	totalBytes, err := s.s3.UploadTilesWithFilter(ctx, mergedDir, s.config.S3.BucketPath, regionCoords)
+	totalBytes, err := client.UploadTilesWithFilter(ctx, mergedDir, s.config.S3.BucketPath, regionCoords)
 	if err != nil {
 		return 0, fmt.Errorf("failed to upload to R2: %w", err)
 	}
 
+	// The merged tree's metadata describes every region; publish the region's own
+	metadataBytes, err := s.UploadMetadata(ctx, regionDir, region)
+	if err != nil {
+		return 0, err
+	}
+	totalBytes += metadataBytes
+
 	logger.Info("R2 upload completed", "total_bytes", totalBytes, "tiles_uploaded", len(regionCoords))
 	return totalBytes, nil
 }
 
 // UploadToR2WithZoomFilter uploads generated tiles to R2 with optional zoom level filtering
 // Filters locally by only walking through the specified zoom level directories
-func (s *TileService) UploadToR2WithZoomFilter(ctx context.Context, tilesDir, region string, minZoom, maxZoom int) (int64, error) {
+func (s *TileService) UploadToR2WithZoomFilter(ctx context.Context, tilesDir, region string, minZoom, maxZoom int) (*UploadSummary, error) {
 	logger := slog.With("region", region, "tiles_dir", tilesDir, "min_zoom", minZoom, "max_zoom", maxZoom)
 	logger.Info("starting R2 upload with zoom filter")
 
+	summary := &UploadSummary{ByZoom: make(map[int]ZoomUploadStats)}
+
 	// If no zoom filtering, just upload everything
 	if minZoom == -1 && maxZoom == -1 {
-		totalBytes, err := s.s3.UploadDirectory(ctx, tilesDir, s.config.S3.BucketPath)
+		stats, err := s.s3.uploadDirectory(ctx, tilesDir, s.config.S3.BucketPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload to R2: %w", err)
+		}
+		summary.add(stats)
+
+		metadataBytes, err := s.UploadMetadata(ctx, tilesDir, region)
 		if err != nil {
-			return 0, fmt.Errorf("failed to upload to R2: %w", err)
+			return nil, err
+		}
+		if metadataBytes > 0 {
+			summary.Files++
+			summary.TotalBytes += metadataBytes
 		}
-		logger.Info("R2 upload completed", "total_bytes", totalBytes)
-		return totalBytes, nil
+		logger.Info("R2 upload completed", "total_bytes", summary.TotalBytes)
+		return summary, nil
 	}
 
 	// Walk the tiles directory and identify zoom level directories to include
 	logger.Info("filtering tiles by zoom level", "min_zoom", minZoom, "max_zoom", maxZoom)
 
-	var totalBytes int64
-
 	// Read the tiles directory to find zoom level folders
 	entries, err := os.ReadDir(tilesDir)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read tiles directory: %w", err)
+		return nil, fmt.Errorf("failed to read tiles directory: %w", err)
 	}
 
 	// Process each zoom level directory that's in our range
@@ -115,27 +171,61 @@ func (s *TileService) UploadToR2WithZoomFilter(ctx context.Context, tilesDir, re
 		zoomDir := filepath.Join(tilesDir, dirName)
 		logger.Info("uploading zoom level", "zoom", zoomLevel)
 
-		bytes, err := s.s3.UploadDirectory(ctx, zoomDir, filepath.Join(s.config.S3.BucketPath, dirName))
+		stats, err := s.s3.uploadDirectory(ctx, zoomDir, filepath.Join(s.config.S3.BucketPath, dirName))
 		if err != nil {
-			return 0, fmt.Errorf("failed to upload zoom level %d: %w", zoomLevel, err)
+			return nil, fmt.Errorf("failed to upload zoom level %d: %w", zoomLevel, err)
 		}
 
-		totalBytes += bytes
+		summary.add(stats)
 	}
 
-	logger.Info("R2 upload completed", "total_bytes", totalBytes)
-	return totalBytes, nil
+	logger.Info("R2 upload completed", "total_bytes", summary.TotalBytes)
+	return summary, nil
+}
+
+// JobResult is what a completed job produced
+type JobResult struct {
+	Region         string `json:"region"`
+	TilesDir       string `json:"tilesDir"`              // The region's tiles directory
+	MergedDir      string `json:"mergedDir,omitempty"`   // Merged tiles directory ("" = merge skipped)
+	GeoJSONPath    string `json:"geojsonPath,omitempty"` // Intermediate GeoJSON, only kept with NoCleanup ("" otherwise)
+	TilesCount     int    `json:"tilesCount"`
+	TotalSizeBytes int64  `json:"totalSizeBytes"`
+	RoadsCount     int    `json:"roadsCount"`     // Roads converted from the KML (0 when generation was skipped)
+	RoadsExtracted int    `json:"roadsExtracted"` // Road geometries extracted, and inserted when there is a database
+	UploadedBytes  int64  `json:"uploadedBytes"`  // Bytes uploaded to R2 (0 when the upload was skipped)
+
+	Report     *TileIntegrityReport `json:"-"`                    // Per-zoom generation report (nil when generation was skipped)
+	Extraction *ExtractionSummary   `json:"extraction,omitempty"` // nil when geometry extraction was skipped
+}
+
+// Print logs the result
+func (r *JobResult) Print() {
+	slog.Info("job result",
+		"region", r.Region,
+		"tiles_dir", r.TilesDir,
+		"tiles_count", r.TilesCount,
+		"total_size_bytes", r.TotalSizeBytes,
+		"roads_count", r.RoadsCount,
+		"roads_extracted", r.RoadsExtracted,
+		"uploaded_bytes", r.UploadedBytes,
+	)
 }
 
-// ProcessJobWithOptions orchestrates the entire tile generation pipeline with custom options
-func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, opts *JobOptions) error {
+// ProcessJobWithOptions orchestrates the entire tile generation pipeline with
+// custom options and returns what it produced
+func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, opts *JobOptions) (*JobResult, error) {
 	logger := slog.With("region", job.Region, "min_zoom", opts.MinZoom, "max_zoom", opts.MaxZoom, "skip_generation", opts.SkipGeneration)
+	tracker := newJobTracker(s.db, job.ID, logger)
+	tracker.onStep = s.StepProgress
+	tracker.onStepChange = s.StepChange
 
 	var tilesDir string
 	var tilesCount int
 	var totalSize int64
 	var roadsCount int
 	var kmlPath, geoJSONPath string
+	var tileReport *TileIntegrityReport
 
 	// Ensure cleanup of temporary files on ANY exit (success or failure)
 	// This prevents temp directory accumulation when processing fails mid-way
@@ -160,10 +250,8 @@ func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, o
 
 		// Check if tiles directory exists
 		if _, err := os.Stat(tilesDir); os.IsNotExist(err) {
-			if s.db != nil {
-				s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("tiles directory does not exist: %s", tilesDir))
-			}
-			return fmt.Errorf("tiles directory does not exist: %s", tilesDir)
+			tracker.fail(ctx, fmt.Sprintf("tiles directory does not exist: %s", tilesDir))
+			return nil, fmt.Errorf("tiles directory does not exist: %s", tilesDir)
 		}
 
 		// Count existing tiles
@@ -180,88 +268,85 @@ func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, o
 
 		logger.Info("using existing tiles", "tiles_dir", tilesDir, "tiles_count", tilesCount, "size_bytes", totalSize)
 
-		if s.db != nil {
-			if err := s.db.UpdateJobStatus(ctx, job.ID, "uploading"); err != nil {
-				logger.Warn("failed to update job status", "error", err)
-			}
-		}
+		tracker.status(ctx, "uploading")
 	} else {
 		// Normal flow: generate tiles
 
 		// Update database status if available
-		if s.db != nil {
-			if err := s.db.UpdateJobStatus(ctx, job.ID, "extracting"); err != nil {
-				logger.Warn("failed to update job status", "error", err)
-			}
-		}
+		tracker.status(ctx, "extracting")
+		tracker.step(ctx, "extracting")
 
 		// Phase 1: Extract KMZ
 		logger.Info("extracting KMZ")
 		var err error
-		kmlPath, err = ExtractKMZFromDir(ctx, job.Region, s.config.Paths.CurvatureData)
+		kmlPath, err = ExtractKMZFromDirWithPattern(ctx, job.Region, s.config.Paths.CurvatureData, s.config.Paths.KMZPattern)
 		if err != nil {
-			if s.db != nil {
-				s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("extraction failed: %v", err))
-			}
-			return fmt.Errorf("failed to extract KMZ: %w", err)
+			tracker.fail(ctx, fmt.Sprintf("extraction failed: %v", err))
+			return nil, fmt.Errorf("failed to extract KMZ: %w", err)
 		}
 		logger.Debug("KMZ extracted", "kml_path", kmlPath)
 
 		// Phase 2: Convert KML to GeoJSON
 		logger.Info("converting KML to GeoJSON")
-		geoJSONPath, roadsCount, err = ConvertKMLToGeoJSON(ctx, kmlPath, job.Region)
+		tracker.step(ctx, "converting")
+		geoJSONPath, roadsCount, err = ConvertKMLToGeoJSONWithOptions(ctx, kmlPath, job.Region, &ConvertOptions{Clip: opts.Clip, PreserveAltitude: opts.PreserveAltitude})
 		if err != nil {
-			if s.db != nil {
-				s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("conversion failed: %v", err))
-			}
-			return fmt.Errorf("failed to convert KML: %w", err)
+			tracker.fail(ctx, fmt.Sprintf("conversion failed: %v", err))
+			return nil, fmt.Errorf("failed to convert KML: %w", err)
 		}
 		logger.Info("KML converted", "geojson_path", geoJSONPath, "roads_count", roadsCount)
 
-		if s.db != nil {
-			if err := s.db.UpdateJobProgress(ctx, job.ID, roadsCount, 0); err != nil {
-				logger.Warn("failed to update progress", "error", err)
+		// Keep a copy for comparing conversions across pipeline versions
+		if keep := s.config.Paths.GeoJSONRetention; keep > 0 {
+			archived, err := archiveGeoJSON(geoJSONPath, s.config.Paths.GeoJSONArchiveDir, job.Region, keep, time.Now())
+			if err != nil {
+				logger.Warn("failed to archive GeoJSON", "error", err)
+			} else {
+				logger.Info("GeoJSON archived", "path", archived, "retention", keep)
 			}
 		}
 
+		tracker.progress(ctx, roadsCount, 0)
+
 		// Phase 3: Generate tiles with Tippecanoe
 		logger.Info("generating tiles with Tippecanoe")
-		if s.db != nil {
-			if err := s.db.UpdateJobStatus(ctx, job.ID, "generating"); err != nil {
-				logger.Warn("failed to update job status", "error", err)
-			}
-		}
+		tracker.status(ctx, "generating")
+		tracker.step(ctx, "generating")
 
 		// Generate tiles with configurable zoom levels
-		genOpts := &GenerateTilesOptions{
-			MinZoom: opts.MinZoom,
-			MaxZoom: opts.MaxZoom,
-		}
+		genOpts := s.generateTilesOptions(job.Region, opts)
+		genOpts.Progress = tracker.stepProgress("generating", nil)
 		tilesDir, tilesCount, totalSize, err = GenerateTilesWithOptions(ctx, geoJSONPath, job.Region, s.config.Paths.OutputDir, genOpts)
 		if err != nil {
-			if s.db != nil {
-				s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("tile generation failed: %v", err))
+			if errors.Is(err, context.Canceled) {
+				// ctx is already done, so record the status on a fresh context
+				logger.Warn("tile generation cancelled")
+				tracker.cancelled()
+				return nil, err
 			}
-			return fmt.Errorf("failed to generate tiles: %w", err)
+			tracker.fail(ctx, fmt.Sprintf("tile generation failed: %v", err))
+			return nil, fmt.Errorf("failed to generate tiles: %w", err)
 		}
 		logger.Info("tiles generated", "tiles_dir", tilesDir, "tiles_count", tilesCount, "size_bytes", totalSize)
 
-		if s.db != nil {
-			if err := s.db.UpdateJobProgress(ctx, job.ID, roadsCount, tilesCount); err != nil {
-				logger.Warn("failed to update progress", "error", err)
-			}
+		// Record the resolved job next to the tiles so the run can be replayed
+		defPath, err := WriteJobDefinition(tilesDir, newJobDefinition(ctx, job, *opts, genOpts, geoJSONPath, tilesDir))
+		if err != nil {
+			logger.Warn("failed to write job definition", "error", err)
+		} else {
+			logger.Info("job definition written", "path", defPath)
 		}
 
+		tracker.progress(ctx, roadsCount, tilesCount)
+
 		// Verify generated tiles have all expected zoom levels (hard fail)
-		tileReport, err := VerifyTileDirectory(tilesDir, opts.MinZoom, opts.MaxZoom)
+		tileReport, err = VerifyTileDirectory(tilesDir, opts.MinZoom, opts.MaxZoom)
 		if err != nil {
 			logger.Warn("tile verification error", "error", err)
 		} else if !tileReport.OK {
 			tileReport.Print()
-			if s.db != nil {
-				s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("generated tiles missing zoom levels: %v", tileReport.MissingZooms))
-			}
-			return fmt.Errorf("generated tiles missing zoom levels: %v", tileReport.MissingZooms)
+			tracker.fail(ctx, fmt.Sprintf("generated tiles missing zoom levels: %v", tileReport.MissingZooms))
+			return nil, fmt.Errorf("generated tiles missing zoom levels: %v", tileReport.MissingZooms)
 		} else {
 			logger.Info("tile integrity check passed", "zoom_levels", len(tileReport.ZoomStats))
 		}
@@ -276,11 +361,8 @@ func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, o
 		logger.Info("skipping merge (--skip-merge flag set)")
 	} else {
 		logger.Info("merging regional tiles", "merge_all", opts.MergeAll)
-		if s.db != nil {
-			if err := s.db.UpdateJobStatus(ctx, job.ID, "merging"); err != nil {
-				logger.Warn("failed to update job status", "error", err)
-			}
-		}
+		tracker.status(ctx, "merging")
+		tracker.step(ctx, "merging")
 
 		var regionDirs []string
 		var err error
@@ -289,26 +371,31 @@ func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, o
 			// Merge all regions
 			regionDirs, err = FindRegionalTileDirs(s.config.Paths.OutputDir)
 			if err != nil {
-				return fmt.Errorf("failed to find regional tile directories: %w", err)
+				return nil, fmt.Errorf("failed to find regional tile directories: %w", err)
 			}
 			logger.Info("merging all regions", "count", len(regionDirs))
 		} else {
 			// Only merge with overlapping neighbors (more efficient)
 			regionDirs, err = FindOverlappingRegions(s.config.Paths.OutputDir, job.Region)
 			if err != nil {
-				return fmt.Errorf("failed to find overlapping regions: %w", err)
+				return nil, fmt.Errorf("failed to find overlapping regions: %w", err)
 			}
 			logger.Info("merging overlapping regions only", "count", len(regionDirs), "dirs", regionDirs)
 		}
 
 		if len(regionDirs) == 0 {
-			return fmt.Errorf("no regional tile directories found in %s", s.config.Paths.OutputDir)
+			return nil, fmt.Errorf("no regional tile directories found in %s", s.config.Paths.OutputDir)
 		}
 
 		mergedDir = filepath.Join(s.config.Paths.OutputDir, "merged")
-		mergeMetadata, err := MergeTiles(ctx, regionDirs, mergedDir)
+		mergeMetadata, err := MergeTilesWithOptions(ctx, regionDirs, mergedDir, &MergeTilesOptions{
+			MinZoom:       -1,
+			MaxZoom:       -1,
+			TippecanoeBin: s.config.Paths.TippecanoeBin,
+			Progress:      tracker.stepProgress("merging", nil),
+		})
 		if err != nil {
-			return fmt.Errorf("failed to merge tiles: %w", err)
+			return nil, fmt.Errorf("failed to merge tiles: %w", err)
 		}
 		logger.Info("tiles merged", "regions", len(regionDirs), "tiles_count", mergeMetadata.TilesCount, "size_bytes", mergeMetadata.TotalSize)
 
@@ -324,178 +411,335 @@ func (s *TileService) ProcessJobWithOptions(ctx context.Context, job *TileJob, o
 		}
 	}
 
-	// Phase 5 & 6: Run geometry extraction and R2 upload in parallel
-	// This significantly speeds up processing by utilizing concurrent I/O operations
+	// Phase 5 & 6: Run geometry extraction and R2 upload in parallel (up to
+	// PHASE_CONCURRENCY at once); either failing cancels the other and fails the job
 	logger.Info("starting parallel operations: geometry extraction and R2 upload")
 
 	// Update job status to show we're in the upload/extraction phase
-	if s.db != nil {
-		statusMsg := "uploading"
-		if opts.ExtractGeometry && !opts.SkipUpload {
-			statusMsg = "uploading/extracting"
-		} else if opts.ExtractGeometry {
-			statusMsg = "extracting"
-		}
-		if err := s.db.UpdateJobStatus(ctx, job.ID, statusMsg); err != nil {
-			logger.Warn("failed to update job status", "error", err)
+	statusMsg := "uploading"
+	if opts.ExtractGeometry && !opts.SkipUpload {
+		statusMsg = "uploading/extracting"
+	} else if opts.ExtractGeometry {
+		statusMsg = "extracting"
+	}
+	tracker.status(ctx, statusMsg)
+	tracker.step(ctx, statusMsg)
+
+	result, err := s.runPostGeneration(ctx, job, opts, tilesDir, mergedDir, tracker)
+	if err != nil {
+		tracker.fail(ctx, fmt.Sprintf("post-generation phase failed: %v", err))
+		return nil, fmt.Errorf("post-generation phase failed: %w", err)
+	}
+
+	// Verify upload spot-check (warn only — don't block pipeline)
+	if !opts.SkipUpload && s.s3 != nil && mergedDir != "" {
+		uploadReport, err := VerifyUpload(ctx, s.s3, mergedDir, s.config.S3.BucketPath, 3, time.Now().UnixNano())
+		if err != nil {
+			logger.Warn("upload verification error", "error", err)
+		} else {
+			uploadReport.Print()
+			if !uploadReport.OK {
+				logger.Warn("upload verification found missing tiles on R2", "count", len(uploadReport.Missing))
+			}
 		}
 	}
 
-	// Use channels to collect results and errors
-	type uploadResult struct {
-		bytes int64
-		err   error
+	logger.Info("parallel operations completed",
+		"uploaded_bytes", result.uploadedBytes,
+		"geometry_count", result.geometryCount)
+
+	if result.extraction != nil {
+		result.extraction.Print()
 	}
-	type geometryResult struct {
-		count int
-		err   error
+
+	// Phase 7: Mark as complete
+	tracker.complete(ctx, roadsCount, tilesCount, totalSize)
+
+	// Note: Cleanup is handled by defer at the top of this function
+
+	logger.Info("job processing complete")
+	jobResult := &JobResult{
+		Region:         job.Region,
+		TilesDir:       tilesDir,
+		MergedDir:      mergedDir,
+		TilesCount:     tilesCount,
+		TotalSizeBytes: totalSize,
+		RoadsCount:     roadsCount,
+		RoadsExtracted: result.geometryCount,
+		UploadedBytes:  result.uploadedBytes,
+		Report:         tileReport,
+		Extraction:     result.extraction,
+	}
+	if opts.NoCleanup {
+		jobResult.GeoJSONPath = geoJSONPath
 	}
+	return jobResult, nil
+}
 
-	uploadChan := make(chan uploadResult, 1)
-	geometryChan := make(chan geometryResult, 1)
+// generateTilesOptions resolves the tile generation options for a region
+func (s *TileService) generateTilesOptions(region string, opts *JobOptions) *GenerateTilesOptions {
+	genOpts := &GenerateTilesOptions{
+		MinZoom:         opts.MinZoom,
+		MaxZoom:         opts.MaxZoom,
+		Encoder:         opts.Encoder,
+		TippecanoeBin:   s.config.Paths.TippecanoeBin,
+		TempDir:         s.config.Paths.TempDir,
+		DiskSpaceFactor: s.diskSpaceFactor(),
+		MaxTileFeatures: opts.MaxTileFeatures,
+		MaxTileBytes:    opts.MaxTileBytes,
+		IDAttribute:     opts.IDAttribute,
+		AtomicSwap:      opts.AtomicSwap,
+		Tippecanoe:      s.config.Tippecanoe,
+		FileMode:        s.config.Paths.TileFileMode,
+		DirMode:         s.config.Paths.TileDirMode,
+	}
+	if opts.Bounds != nil {
+		genOpts.Bounds = opts.Bounds
+	} else if bound, ok := s.config.Service.RegionBounds[region]; ok {
+		genOpts.Bounds = &bound
+	}
+	return genOpts
+}
+
+// diskSpaceFactor returns the configured free-space factor for generation,
+// or 0 when the check is turned off
+func (s *TileService) diskSpaceFactor() float64 {
+	factor := s.config.Service.DiskSpaceFactor
+	if factor == 0 {
+		return DefaultDiskSpaceFactor
+	}
+	return max(factor, 0)
+}
 
-	// Goroutine 1: Extract and insert road geometries (from regional tiles, not merged)
+// postGenerationResult is what the phases after tile generation produced
+type postGenerationResult struct {
+	uploadedBytes int64
+	geometryCount int
+	extraction    *ExtractionSummary // nil when geometry extraction was skipped
+}
+
+// runPostGeneration runs geometry extraction and the R2 upload of merged
+// tiles, overlapping them up to the configured phase concurrency. A failure
+// in either cancels the other and fails the job.
+func (s *TileService) runPostGeneration(ctx context.Context, job *TileJob, opts *JobOptions, tilesDir, mergedDir string, tracker *jobTracker) (*postGenerationResult, error) {
+	logger := slog.With("region", job.Region)
+	result := &postGenerationResult{}
+	var phases []pipelinePhase
+
+	// Extract and insert road geometries (from regional tiles, not merged)
 	if opts.ExtractGeometry {
-		go func() {
+		phases = append(phases, pipelinePhase{name: "geometry extraction", run: func(ctx context.Context) error {
 			logger.Info("starting road geometry extraction (parallel)")
-			extractor := NewGeometryExtractor()
+			extractor := s.newGeometryExtractor()
+			if tracker.onStep != nil {
+				extractor.Progress = tracker.stepProgress("extracting", extractor.Progress)
+			}
 
-			roads, err := extractor.ExtractRoadGeometriesFromTiles(ctx, tilesDir, job.Region)
+			roads, summary, err := extractor.ExtractRoadGeometriesFromTiles(ctx, tilesDir, job.Region)
 			if err != nil {
-				logger.Warn("failed to extract road geometries", "error", err)
-				geometryChan <- geometryResult{0, err}
-				return
+				return fmt.Errorf("failed to extract road geometries: %w", err)
 			}
+			result.extraction = summary
 
 			logger.Info("road geometries extracted", "count", len(roads))
 
 			if opts.SkipGeometryInsertion {
 				logger.Info("skipping database insertion, geometries saved to file",
 					"file", extractor.getExtractionFile(job.Region))
-				geometryChan <- geometryResult{len(roads), nil}
+				result.geometryCount = len(roads)
 			} else if s.db != nil {
 				// Insert into database with large batch size
-				inserted, err := s.db.BatchUpsertRoadGeometries(ctx, roads, 9000)
+				inserted, err := s.insertRoadGeometries(ctx, extractor, roads, job.Region)
 				if err != nil {
-					logger.Warn("failed to insert road geometries", "error", err)
-					geometryChan <- geometryResult{0, err}
-					return
+					return err
 				}
 
 				logger.Info("road geometries inserted into database", "count", inserted)
+				summary.RoadsInserted = inserted
 
 				// Cleanup extraction files after successful insertion
 				if err := extractor.CleanupExtractionFiles(job.Region); err != nil {
 					logger.Warn("failed to cleanup extraction files", "error", err)
 				}
-
-				geometryChan <- geometryResult{inserted, nil}
+				result.geometryCount = inserted
 			} else {
 				logger.Warn("database not available, geometries saved to file only")
-				geometryChan <- geometryResult{len(roads), nil}
+				result.geometryCount = len(roads)
 			}
-		}()
-	} else {
-		// If not extracting geometry, send a success result immediately
-		geometryChan <- geometryResult{0, nil}
+			return nil
+		}})
 	}
 
-	// Goroutine 2: Upload MERGED tiles to R2, but only for the region's tile coordinates
+	// Upload MERGED tiles to R2, but only for the region's tile coordinates
 	// This gives us merged content (multi-region roads) but we only pay for the region's tile count
 	if !opts.SkipUpload {
-		go func() {
+		phases = append(phases, pipelinePhase{name: "R2 upload", run: func(ctx context.Context) error {
 			logger.Info("starting R2 upload of merged tiles for region coordinates", "merged_dir", mergedDir, "region", job.Region)
-			uploadedBytes, err := s.UploadMergedTilesForRegion(ctx, mergedDir, tilesDir, job.Region)
+			client := s.s3
+			if tracker.onStep != nil && client != nil {
+				client = client.withBytesProgress(tracker.stepProgress("uploading", nil))
+			}
+			uploadedBytes, err := s.uploadMergedTilesForRegion(ctx, client, mergedDir, tilesDir, job.Region)
 			if err != nil {
-				logger.Error("R2 upload failed", "error", err)
-				uploadChan <- uploadResult{0, err}
-				return
+				return err
 			}
 			logger.Info("R2 upload completed", "uploaded_bytes", uploadedBytes)
-			uploadChan <- uploadResult{uploadedBytes, nil}
-		}()
+			result.uploadedBytes = uploadedBytes
+			return nil
+		}})
 	} else {
 		logger.Info("skipping R2 upload, tiles saved locally", "tiles_dir", tilesDir)
-		uploadChan <- uploadResult{0, nil}
 	}
 
-	// Wait for both operations to complete
-	uploadRes := <-uploadChan
-	geometryRes := <-geometryChan
-
-	// Check for errors
-	if uploadRes.err != nil {
-		if s.db != nil {
-			s.db.UpdateJobError(ctx, job.ID, fmt.Sprintf("R2 upload failed: %v", uploadRes.err))
-		}
-		return fmt.Errorf("failed to upload to R2: %w", uploadRes.err)
+	if err := runPhases(ctx, s.phaseConcurrency(), phases); err != nil {
+		logger.Error("post-generation phase failed", "error", err)
+		return nil, err
 	}
+	return result, nil
+}
 
-	// Verify upload spot-check (warn only — don't block pipeline)
-	if !opts.SkipUpload && s.s3 != nil && mergedDir != "" {
-		uploadReport, err := VerifyUpload(ctx, s.s3, mergedDir, s.config.S3.BucketPath, 3)
-		if err != nil {
-			logger.Warn("upload verification error", "error", err)
-		} else {
-			uploadReport.Print()
-			if !uploadReport.OK {
-				logger.Warn("upload verification found missing tiles on R2", "count", len(uploadReport.Missing))
-			}
-		}
+// phaseConcurrency is how many post-generation phases may run at once
+func (s *TileService) phaseConcurrency() int {
+	if s.config != nil && s.config.Service.PhaseConcurrency > 0 {
+		return s.config.Service.PhaseConcurrency
 	}
+	return DefaultPhaseConcurrency
+}
 
-	// Geometry extraction errors are non-fatal (we already logged them)
-	if geometryRes.err != nil {
-		logger.Warn("geometry extraction completed with errors", "error", geometryRes.err)
+// ExtractRoadGeometriesFromExistingTiles extracts road geometries from
+// already-generated tiles. A tilesDir ending in .mbtiles is read as an
+// MBTiles file.
+func (s *TileService) ExtractRoadGeometriesFromExistingTiles(ctx context.Context, tilesDir, region string) (*ExtractionSummary, error) {
+	if IsMBTilesPath(tilesDir) {
+		return s.ExtractRoadGeometriesFromMBTiles(ctx, tilesDir, region)
 	}
 
-	logger.Info("parallel operations completed",
-		"uploaded_bytes", uploadRes.bytes,
-		"geometry_count", geometryRes.count)
+	logger := slog.With("region", region, "tiles_dir", tilesDir)
+	logger.Info("extracting road geometries from existing tiles")
 
-	// Phase 7: Mark as complete
-	if s.db != nil {
-		if err := s.db.CompleteJob(ctx, job.ID, roadsCount, tilesCount, totalSize); err != nil {
-			logger.Warn("failed to mark job complete", "error", err)
-		}
+	var scheme TileScheme
+	if s.config != nil {
+		scheme = s.config.Paths.TileScheme
 	}
+	src, err := OpenTileSource(tilesDir, scheme)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
 
-	// Note: Cleanup is handled by defer at the top of this function
-
-	logger.Info("job processing complete")
-	return nil
+	return s.extractRoadGeometries(ctx, src, region, logger)
 }
 
-// ExtractRoadGeometriesFromExistingTiles extracts road geometries from already-generated tiles
-func (s *TileService) ExtractRoadGeometriesFromExistingTiles(ctx context.Context, tilesDir, region string) (int, error) {
-	logger := slog.With("region", region, "tiles_dir", tilesDir)
-	logger.Info("extracting road geometries from existing tiles")
+// ExtractRoadGeometriesFromMBTiles extracts road geometries from an MBTiles file
+func (s *TileService) ExtractRoadGeometriesFromMBTiles(ctx context.Context, mbtilesPath, region string) (*ExtractionSummary, error) {
+	logger := slog.With("region", region, "mbtiles", mbtilesPath)
+	logger.Info("extracting road geometries from mbtiles")
 
-	extractor := NewGeometryExtractor()
+	src, err := OpenMBTiles(mbtilesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return s.extractRoadGeometries(ctx, src, region, logger)
+}
+
+// extractRoadGeometries extracts road geometries from a tile source and
+// inserts them into the database when one is configured
+func (s *TileService) extractRoadGeometries(ctx context.Context, src TileSource, region string, logger *slog.Logger) (*ExtractionSummary, error) {
+	extractor := s.newGeometryExtractor()
 
 	// Extract roads from tiles
-	roads, err := extractor.ExtractRoadGeometriesFromTiles(ctx, tilesDir, region)
+	roads, summary, err := extractor.ExtractRoadGeometriesFromSource(ctx, src, region)
 	if err != nil {
-		return 0, fmt.Errorf("failed to extract road geometries: %w", err)
+		return nil, fmt.Errorf("failed to extract road geometries: %w", err)
 	}
 
 	logger.Info("road geometries extracted", "count", len(roads))
 
+	// Written before insertion so the boxes can be checked even if it fails
+	if s.BoundsGeoJSON != "" {
+		if err := WriteRoadBoundsGeoJSON(s.BoundsGeoJSON, roads); err != nil {
+			return summary, err
+		}
+		logger.Info("road bounding boxes written as GeoJSON", "path", s.BoundsGeoJSON, "count", len(roads))
+	}
+
+	// Roads from only some of the tiles would overwrite complete rows with
+	// partial boxes, so a limited or scoped run only keeps its partial file
+	if extractor.partial() {
+		logger.Warn("partial extraction, road geometries not inserted into database", "file", extractor.getExtractionFile(region))
+		return summary, nil
+	}
+
 	// Insert into database if available
-	if s.db != nil {
-		inserted, err := s.db.BatchUpsertRoadGeometries(ctx, roads, 9000)
+	if s.db != nil && len(roads) > 0 {
+		inserted, err := s.insertRoadGeometries(ctx, extractor, roads, region)
 		if err != nil {
-			return 0, fmt.Errorf("failed to insert road geometries: %w", err)
+			return summary, err
 		}
 		logger.Info("road geometries inserted into database", "count", inserted)
+		summary.RoadsInserted = inserted
 
 		// Cleanup extraction files
 		if err := extractor.CleanupExtractionFiles(region); err != nil {
 			logger.Warn("failed to cleanup extraction files", "error", err)
 		}
+	}
+
+	return summary, nil
+}
 
-		return inserted, nil
+// insertRoadGeometries upserts extracted roads, retrying through a brief
+// database outage. If the database stays unreachable the error says so and
+// points at the extraction file the roads are still saved in.
+func (s *TileService) insertRoadGeometries(ctx context.Context, extractor *GeometryExtractor, roads []RoadGeometry, region string) (int, error) {
+	inserted, err := s.db.upsertRoadGeometriesWithReconnect(ctx, roads, extractor.KeyStrategy, 9000)
+	if err != nil && isDatabaseUnavailable(err) {
+		command := "insert-geometries"
+		if extractor.KeyStrategy == RoadKeyGlobal {
+			command += " -dedup-global"
+		}
+		return 0, fmt.Errorf("database unavailable, road geometries not inserted (saved to %s; load them with %s): %w",
+			extractor.getExtractionFile(region), command, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert road geometries: %w", err)
 	}
+	return inserted, nil
+}
 
-	return len(roads), nil
+// newGeometryExtractor creates an extractor configured from the service config
+func (s *TileService) newGeometryExtractor() *GeometryExtractor {
+	extractor := NewGeometryExtractor()
+	extractor.Progress = s.Progress
+	extractor.MaxTiles = s.MaxTiles
+	extractor.Scope = s.TileScope
+	if s.config == nil {
+		return extractor
+	}
+	extractor.MaxBBoxMeters = float64(max(s.config.Service.MaxRoadBBoxKm, 0)) * 1000
+	if n := s.config.Service.MaxInvalidRoads; n > 0 {
+		extractor.MaxInvalidRoads = n
+	} else if n < 0 {
+		extractor.MaxInvalidRoads = 0
+	}
+	if r := s.config.Service.InvalidWarnRatio; r > 0 {
+		extractor.InvalidWarnRatio = r
+	} else if r < 0 {
+		extractor.InvalidWarnRatio = 0
+	}
+	if d := s.config.Service.LogCoordDecimals; d > 0 {
+		extractor.LogCoordDecimals = d
+	}
+	if n := s.config.Service.ExtractWorkers; n > 0 {
+		extractor.Workers = n
+	}
+	if check := s.config.Service.ExtractMetadataCheck; check != "" {
+		extractor.MetadataCheck = check
+	}
+	if s.config.Service.DedupGlobal {
+		extractor.KeyStrategy = RoadKeyGlobal
+	}
+	return extractor
 }
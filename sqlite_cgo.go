@@ -0,0 +1,8 @@
+//go:build cgo
+
+package main
+
+import _ "github.com/mattn/go-sqlite3"
+
+// sqliteAvailable reports whether this build can read SQLite (MBTiles) files
+const sqliteAvailable = true
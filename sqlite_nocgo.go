@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package main
+
+// sqliteAvailable reports whether this build can read SQLite (MBTiles) files.
+// The SQLite driver needs cgo, so builds without it can't.
+const sqliteAvailable = false
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// statsCacheTTL is how long /api/stats responses are reused
+const statsCacheTTL = 10 * time.Second
+
+// JobStats holds aggregate statistics over tile jobs and road geometries
+type JobStats struct {
+	JobsByStatus          map[string]int `json:"jobsByStatus"`
+	TotalJobs             int            `json:"totalJobs"`
+	TotalRoads            int            `json:"totalRoads"`
+	TotalTiles            int64          `json:"totalTiles"`
+	TotalBytes            int64          `json:"totalBytes"`
+	AvgJobDurationSeconds float64        `json:"avgJobDurationSeconds"`
+}
+
+// StatsResponse is the body of GET /api/stats
+type StatsResponse struct {
+	JobStats
+	ActiveJobs  map[string]int `json:"activeJobs"` // Jobs held in memory by this server, by status
+	GeneratedAt time.Time      `json:"generatedAt"`
+}
+
+// aggregateJobStats computes JobStats over jobs. Average duration covers
+// completed jobs with both start and completion times.
+func aggregateJobStats(jobs []*TileJob) JobStats {
+	stats := JobStats{JobsByStatus: make(map[string]int)}
+
+	var totalDuration time.Duration
+	completed := 0
+	for _, job := range jobs {
+		stats.JobsByStatus[job.Status]++
+		stats.TotalJobs++
+		if job.TilesGenerated != nil {
+			stats.TotalTiles += int64(*job.TilesGenerated)
+		}
+		if job.TotalSizeBytes != nil {
+			stats.TotalBytes += *job.TotalSizeBytes
+		}
+		if job.Status == "completed" && job.StartedAt != nil && job.CompletedAt != nil {
+			totalDuration += job.CompletedAt.Sub(*job.StartedAt)
+			completed++
+		}
+	}
+
+	if completed > 0 {
+		stats.AvgJobDurationSeconds = (totalDuration / time.Duration(completed)).Seconds()
+	}
+	return stats
+}
+
+// jobStatsByStatusQuery counts jobs and sums their output per status
+const jobStatsByStatusQuery = `
+	SELECT status, COUNT(*), COALESCE(SUM("tilesGenerated"), 0), COALESCE(SUM("totalSizeBytes"), 0)
+	FROM "TileJob"
+	GROUP BY status
+`
+
+// avgJobDurationQuery averages the run time of completed jobs in seconds
+const avgJobDurationQuery = `
+	SELECT COALESCE(AVG(EXTRACT(EPOCH FROM ("completedAt" - "startedAt"))), 0)
+	FROM "TileJob"
+	WHERE status = 'completed' AND "startedAt" IS NOT NULL AND "completedAt" IS NOT NULL
+`
+
+// GetJobStats aggregates job and road statistics across all regions
+func (d *Database) GetJobStats(ctx context.Context) (*JobStats, error) {
+	stats := &JobStats{JobsByStatus: make(map[string]int)}
+
+	d.logQuery(ctx, jobStatsByStatusQuery)
+	rows, err := d.conn.QueryContext(ctx, jobStatsByStatusQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		var tiles, bytes int64
+		if err := rows.Scan(&status, &count, &tiles, &bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan job stats: %w", err)
+		}
+		stats.JobsByStatus[status] = count
+		stats.TotalJobs += count
+		stats.TotalTiles += tiles
+		stats.TotalBytes += bytes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job stats: %w", err)
+	}
+
+	d.logQuery(ctx, avgJobDurationQuery)
+	if err := d.conn.QueryRowContext(ctx, avgJobDurationQuery).Scan(&stats.AvgJobDurationSeconds); err != nil {
+		return nil, fmt.Errorf("failed to query average job duration: %w", err)
+	}
+
+	countQuery := `SELECT COUNT(*) FROM "RoadGeometry"`
+	d.logQuery(ctx, countQuery)
+	if err := d.conn.QueryRowContext(ctx, countQuery).Scan(&stats.TotalRoads); err != nil {
+		return nil, fmt.Errorf("failed to count road geometries: %w", err)
+	}
+
+	return stats, nil
+}
+
+// roadGeometryCountsQuery counts road geometries per region
+const roadGeometryCountsQuery = `SELECT region, COUNT(*) FROM "RoadGeometry" GROUP BY region`
+
+// GetRoadGeometryCountsByRegion returns the number of road geometries in
+// every region that has any
+func (d *Database) GetRoadGeometryCountsByRegion(ctx context.Context) (map[string]int, error) {
+	d.logQuery(ctx, roadGeometryCountsQuery)
+	rows, err := d.conn.QueryContext(ctx, roadGeometryCountsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count road geometries by region: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var region string
+		var count int
+		if err := rows.Scan(&region, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan road geometry count: %w", err)
+		}
+		counts[region] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating road geometry counts: %w", err)
+	}
+	return counts, nil
+}
+
+// PrintRoadGeometryCounts writes per-region road geometry counts as a table
+// sorted by region, followed by the total across all regions
+func PrintRoadGeometryCounts(w io.Writer, counts map[string]int) {
+	regions := make([]string, 0, len(counts))
+	width := len("region")
+	total := 0
+	for region, count := range counts {
+		regions = append(regions, region)
+		width = max(width, len(region))
+		total += count
+	}
+	slices.Sort(regions)
+
+	fmt.Fprintf(w, "%-*s %10s\n", width, "region", "roads")
+	for _, region := range regions {
+		fmt.Fprintf(w, "%-*s %10d\n", width, region, counts[region])
+	}
+	fmt.Fprintf(w, "%-*s %10d\n", width, "total", total)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int       { return &v }
+func int64Ptr(v int64) *int64 { return &v }
+
+// seededJobs is a small dataset with known aggregates: 2 completed (60s and
+// 180s), 1 failed, 1 generating
+func seededJobs() []*TileJob {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	end1 := start.Add(60 * time.Second)
+	end2 := start.Add(180 * time.Second)
+	return []*TileJob{
+		{ID: "a", Status: "completed", TilesGenerated: intPtr(100), TotalSizeBytes: int64Ptr(1000), StartedAt: &start, CompletedAt: &end1},
+		{ID: "b", Status: "completed", TilesGenerated: intPtr(50), TotalSizeBytes: int64Ptr(500), StartedAt: &start, CompletedAt: &end2},
+		{ID: "c", Status: "failed", StartedAt: &start},
+		{ID: "d", Status: "generating", TilesGenerated: intPtr(7)},
+	}
+}
+
+func TestAggregateJobStats(t *testing.T) {
+	stats := aggregateJobStats(seededJobs())
+
+	if stats.TotalJobs != 4 {
+		t.Errorf("expected 4 jobs, got %d", stats.TotalJobs)
+	}
+	want := map[string]int{"completed": 2, "failed": 1, "generating": 1}
+	for status, count := range want {
+		if stats.JobsByStatus[status] != count {
+			t.Errorf("expected %d %s jobs, got %d", count, status, stats.JobsByStatus[status])
+		}
+	}
+	if stats.TotalTiles != 157 || stats.TotalBytes != 1500 {
+		t.Errorf("expected 157 tiles and 1500 bytes, got %d and %d", stats.TotalTiles, stats.TotalBytes)
+	}
+	if stats.AvgJobDurationSeconds != 120 {
+		t.Errorf("expected 120s average duration, got %v", stats.AvgJobDurationSeconds)
+	}
+}
+
+func TestHandleStats_InMemory(t *testing.T) {
+	s := newTestAPIServer()
+	for _, job := range seededJobs() {
+		s.activeJobs[job.ID] = &JobStatus{Job: job}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp StatsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.TotalJobs != 4 || resp.TotalTiles != 157 || resp.AvgJobDurationSeconds != 120 {
+		t.Errorf("unexpected aggregates %+v", resp.JobStats)
+	}
+	if resp.ActiveJobs["completed"] != 2 || resp.ActiveJobs["generating"] != 1 {
+		t.Errorf("unexpected active tallies %v", resp.ActiveJobs)
+	}
+}
+
+func TestHandleStats_UsesDatabaseAggregatesAndCaches(t *testing.T) {
+	s := newTestAPIServer(&JobStatus{Job: &TileJob{ID: "live", Status: "uploading"}})
+	calls := 0
+	s.statsFetcher = func(ctx context.Context) (*JobStats, error) {
+		calls++
+		return &JobStats{
+			JobsByStatus: map[string]int{"completed": 40, "uploading": 1},
+			TotalJobs:    41,
+			TotalRoads:   250000,
+			TotalTiles:   9000,
+			TotalBytes:   1 << 30,
+		}, nil
+	}
+
+	get := func() StatsResponse {
+		rec := httptest.NewRecorder()
+		s.handleStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+		var resp StatsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get()
+	if resp.TotalJobs != 41 || resp.TotalRoads != 250000 || resp.JobsByStatus["completed"] != 40 {
+		t.Errorf("expected database aggregates, got %+v", resp.JobStats)
+	}
+	if resp.ActiveJobs["uploading"] != 1 {
+		t.Errorf("expected in-memory tallies alongside, got %v", resp.ActiveJobs)
+	}
+
+	get()
+	if calls != 1 {
+		t.Errorf("expected cached response on second request, fetched %d times", calls)
+	}
+
+	s.statsCache.GeneratedAt = time.Now().Add(-2 * statsCacheTTL)
+	get()
+	if calls != 2 {
+		t.Errorf("expected refetch after cache expiry, fetched %d times", calls)
+	}
+}
+
+func TestJobStatsQueries(t *testing.T) {
+	if !strings.Contains(jobStatsByStatusQuery, "GROUP BY status") {
+		t.Errorf("expected per-status grouping, got %s", jobStatsByStatusQuery)
+	}
+	if !strings.Contains(avgJobDurationQuery, `EXTRACT(EPOCH FROM ("completedAt" - "startedAt"))`) ||
+		!strings.Contains(avgJobDurationQuery, "status = 'completed'") {
+		t.Errorf("unexpected duration query %s", avgJobDurationQuery)
+	}
+}
+
+func TestJobStatsByStatusQuery_SQLite(t *testing.T) {
+	db := newJobsTestDB(t)
+	jobs := seededJobs()
+	for _, job := range jobs {
+		_, err := db.conn.Exec(`INSERT INTO "TileJob" (id, region, status, "tilesGenerated", "totalSizeBytes", "startedAt", "completedAt") VALUES (?, 'oregon', ?, ?, ?, ?, ?)`,
+			job.ID, job.Status, job.TilesGenerated, job.TotalSizeBytes, job.StartedAt, job.CompletedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := db.conn.Query(jobStatsByStatusQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := JobStats{JobsByStatus: make(map[string]int)}
+	for rows.Next() {
+		var status string
+		var count int
+		var tiles, bytes int64
+		if err := rows.Scan(&status, &count, &tiles, &bytes); err != nil {
+			t.Fatal(err)
+		}
+		got.JobsByStatus[status] = count
+		got.TotalJobs += count
+		got.TotalTiles += tiles
+		got.TotalBytes += bytes
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The SQL must agree with the in-memory aggregate, NULL outputs included
+	want := aggregateJobStats(jobs)
+	want.AvgJobDurationSeconds = 0 // avgJobDurationQuery is PostgreSQL only
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("query aggregates %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintRoadGeometryCounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[string]int
+		want   string
+	}{
+		{"empty", map[string]int{}, "region      roads\ntotal           0\n"},
+		{
+			"sorted with total",
+			map[string]int{"washington": 39120, "oregon": 48213, "idaho": 7},
+			"region          roads\n" +
+				"idaho               7\n" +
+				"oregon          48213\n" +
+				"washington      39120\n" +
+				"total           87340\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintRoadGeometryCounts(&buf, tt.counts)
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRoadGeometryCountsQuery(t *testing.T) {
+	if !strings.Contains(roadGeometryCountsQuery, `FROM "RoadGeometry" GROUP BY region`) {
+		t.Errorf("expected per-region grouping, got %s", roadGeometryCountsQuery)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SyncSummary reports what SyncToR2 changed on R2
+type SyncSummary struct {
+	Added         int   // Files with no previous upload
+	Updated       int   // Files whose content changed since the previous upload
+	Deleted       int   // Files removed locally and deleted from R2
+	Unchanged     int   // Files skipped because R2 already has this content
+	UploadedBytes int64 // Bytes uploaded for added and updated files
+	DeletedBytes  int64 // Bytes deleted from R2
+}
+
+// Print logs the summary
+func (s *SyncSummary) Print() {
+	slog.Info("sync summary",
+		"added", s.Added,
+		"updated", s.Updated,
+		"deleted", s.Deleted,
+		"unchanged", s.Unchanged,
+		"uploaded_bytes", s.UploadedBytes,
+		"deleted_bytes", s.DeletedBytes,
+	)
+}
+
+// SyncOptions controls SyncToR2
+type SyncOptions struct {
+	// Verify lists the tiles prefix to find files the previous manifest
+	// lists but R2 is missing, and uploads them again. Without it the
+	// previous manifest is trusted, so a sync costs no listing of the prefix
+	// every region shares.
+	Verify bool
+}
+
+// SyncToR2 uploads only the files of tilesDir that changed since the
+// region's last manifest upload and deletes from R2 the files the manifest
+// lists that no longer exist locally. Tiles share one prefix across regions,
+// so a file another region's manifest lists is left in place. The new
+// manifest is uploaded last, so an interrupted sync is redone by the next
+// one. Without a previous manifest every file is uploaded.
+func (s *TileService) SyncToR2(ctx context.Context, tilesDir, region string, opts SyncOptions) (*SyncSummary, error) {
+	logger := slog.With("region", region, "tiles_dir", tilesDir)
+	logger.Info("starting R2 sync")
+
+	local, err := BuildFileManifest(tilesDir)
+	if err != nil {
+		return nil, err
+	}
+	local = syncableEntries(local)
+
+	previous, found, err := s.FetchManifest(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		logger.Info("no previous manifest, uploading every file")
+	}
+
+	// onR2 reports whether a file of the previous manifest is on R2
+	onR2 := func(key string) bool { return true }
+	if opts.Verify {
+		remote, err := s.s3.ListObjectInfos(ctx, s.config.S3.BucketPath+"/")
+		if err != nil {
+			return nil, err
+		}
+		remoteKeys := make(map[string]bool, len(remote))
+		for _, obj := range remote {
+			remoteKeys[obj.Key] = true
+		}
+		onR2 = func(key string) bool { return remoteKeys[key] }
+	}
+
+	prevEntries := make(map[string]ManifestEntry, len(previous))
+	for _, entry := range syncableEntries(previous) {
+		prevEntries[entry.Path] = entry
+	}
+
+	summary := &SyncSummary{}
+	var changed []string
+	metadataChanged := false
+	localPaths := make(map[string]bool, len(local))
+	for _, entry := range local {
+		localPaths[entry.Path] = true
+		prev, seen := prevEntries[entry.Path]
+		present := seen && onR2(s.syncKey(region, entry.Path))
+
+		switch {
+		case present && prev.SHA256 == entry.SHA256:
+			summary.Unchanged++
+			continue
+		case present:
+			summary.Updated++
+		default:
+			summary.Added++
+		}
+
+		if entry.Path == MetadataFileName {
+			metadataChanged = true
+		} else {
+			changed = append(changed, entry.Path)
+		}
+	}
+
+	var removed []ManifestEntry
+	for p, entry := range prevEntries {
+		if !localPaths[p] {
+			removed = append(removed, entry)
+		}
+	}
+	var stale []ObjectInfo
+	if len(removed) > 0 {
+		shared, err := s.pathsOfOtherRegions(ctx, region)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range removed {
+			key := s.syncKey(region, entry.Path)
+			if entry.Path != MetadataFileName && shared[entry.Path] {
+				logger.Debug("keeping removed file another region lists", "s3_key", key)
+				continue
+			}
+			if onR2(key) {
+				stale = append(stale, ObjectInfo{Key: key, Size: entry.Size})
+			}
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Key < stale[j].Key })
+
+	logger.Info("sync plan", "added", summary.Added, "updated", summary.Updated, "deleted", len(stale), "unchanged", summary.Unchanged)
+
+	uploaded, err := s.s3.UploadFiles(ctx, tilesDir, s.config.S3.BucketPath, changed)
+	summary.UploadedBytes = uploaded
+	if err != nil {
+		return summary, fmt.Errorf("failed to upload changed files: %w", err)
+	}
+	if metadataChanged {
+		size, err := s.UploadMetadata(ctx, tilesDir, region)
+		if err != nil {
+			return summary, err
+		}
+		summary.UploadedBytes += size
+	}
+
+	deleted, deletedBytes, err := s.deleteObjects(ctx, stale)
+	summary.Deleted, summary.DeletedBytes = deleted, deletedBytes
+	if err != nil {
+		return summary, err
+	}
+
+	if err := s.uploadManifestEntries(ctx, local, region); err != nil {
+		return summary, err
+	}
+
+	logger.Info("R2 sync completed", "uploaded_bytes", summary.UploadedBytes)
+	return summary, nil
+}
+
+// pathsOfOtherRegions returns the paths listed in the manifest of every
+// region but region
+func (s *TileService) pathsOfOtherRegions(ctx context.Context, region string) (map[string]bool, error) {
+	manifests, err := s.FetchAllManifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool)
+	for other, entries := range manifests {
+		if other == region {
+			continue
+		}
+		for _, entry := range entries {
+			paths[entry.Path] = true
+		}
+	}
+	return paths, nil
+}
+
+// syncKey returns the R2 key a manifest path is uploaded to
+func (s *TileService) syncKey(region, relPath string) string {
+	if relPath == MetadataFileName {
+		return MetadataKey(s.config.S3.BucketPath, region)
+	}
+	return s.config.S3.BucketPath + "/" + relPath
+}
+
+// syncableEntries drops manifest entries that are never uploaded, such as
+// job definitions
+func syncableEntries(entries []ManifestEntry) []ManifestEntry {
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if isJobDefinitionFile(path.Base(entry.Path)) || strings.HasPrefix(entry.Path, "../") {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
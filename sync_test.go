@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func writeSyncFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// putKeys returns the keys uploaded since headers was last reset, sorted
+func (f *fakeS3) putKeys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.headers))
+	for key := range f.headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSyncToR2(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "washington")
+	writeSyncFile(t, dir, "5/1/1.pbf", "tile-a")
+	writeSyncFile(t, dir, "5/1/2.pbf", "tile-b")
+	writeSyncFile(t, dir, "6/2/2.pbf", "tile-c")
+	writeSyncFile(t, dir, MetadataFileName, `{"name":"washington"}`)
+	writeSyncFile(t, dir, ".job-42.json", `{}`)
+
+	fake, client := newFakeS3(t)
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+	ctx := context.Background()
+
+	// No previous manifest: everything is added
+	summary, err := service.SyncToR2(ctx, dir, "washington", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 4 || summary.Updated != 0 || summary.Deleted != 0 || summary.Unchanged != 0 {
+		t.Errorf("first sync: unexpected summary %+v", summary)
+	}
+	want := []string{
+		"tiles/5/1/1.pbf",
+		"tiles/5/1/2.pbf",
+		"tiles/6/2/2.pbf",
+		"tiles/manifests/washington/manifest.json",
+		"tiles/washington/metadata.json",
+	}
+	if got := fake.putKeys(); !slices.Equal(got, want) {
+		t.Errorf("first sync: expected uploads %v, got %v", want, got)
+	}
+
+	// Change one tile, remove one and add one
+	writeSyncFile(t, dir, "5/1/2.pbf", "tile-b2")
+	if err := os.Remove(filepath.Join(dir, "6", "2", "2.pbf")); err != nil {
+		t.Fatal(err)
+	}
+	writeSyncFile(t, dir, "7/4/4.pbf", "tile-d")
+	fake.headers = map[string]http.Header{}
+
+	summary, err = service.SyncToR2(ctx, dir, "washington", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 1 || summary.Updated != 1 || summary.Deleted != 1 || summary.Unchanged != 2 {
+		t.Errorf("second sync: unexpected summary %+v", summary)
+	}
+	if summary.UploadedBytes != int64(len("tile-b2")+len("tile-d")) || summary.DeletedBytes != int64(len("tile-c")) {
+		t.Errorf("second sync: unexpected byte counts %+v", summary)
+	}
+	want = []string{"tiles/5/1/2.pbf", "tiles/7/4/4.pbf", "tiles/manifests/washington/manifest.json"}
+	if got := fake.putKeys(); !slices.Equal(got, want) {
+		t.Errorf("second sync: expected uploads %v, got %v", want, got)
+	}
+	if !slices.Equal(fake.deletes, []string{"tiles/6/2/2.pbf"}) {
+		t.Errorf("second sync: expected only the removed tile to be deleted, got %v", fake.deletes)
+	}
+	// Without -verify the only listing is of the manifests, to check the
+	// removed tile against other regions
+	if fake.listRequests != 1 {
+		t.Errorf("expected 1 list request, got %d", fake.listRequests)
+	}
+
+	// The stored manifest now describes the changed tree
+	var manifest []ManifestEntry
+	if err := json.Unmarshal(fake.bodies["tiles/manifests/washington/manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 4 || manifest[3].Path != MetadataFileName {
+		t.Errorf("unexpected stored manifest %+v", manifest)
+	}
+}
+
+func TestSyncToR2_PriorManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "oregon")
+	writeSyncFile(t, dir, "5/1/1.pbf", "tile-a")
+	writeSyncFile(t, dir, "5/1/2.pbf", "tile-b")
+	writeSyncFile(t, dir, "5/1/3.pbf", "tile-c")
+
+	entries, err := BuildFileManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The previous upload also had 6/1/1.pbf, whose key another region's
+	// sync already deleted
+	entries = append(entries, ManifestEntry{Path: "6/1/1.pbf", Size: 6, SHA256: "gone"})
+	prior, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, client := newFakeS3(t)
+	manifestKey := "tiles/manifests/oregon/manifest.json"
+	fake.objects[manifestKey] = true
+	fake.bodies[manifestKey] = prior
+	// 5/1/3.pbf is in the manifest but missing from R2
+	for _, key := range []string{"tiles/5/1/1.pbf", "tiles/5/1/2.pbf"} {
+		fake.objects[key] = true
+		fake.puts[key] = 6
+	}
+
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+	summary, err := service.SyncToR2(context.Background(), dir, "oregon", SyncOptions{Verify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Added != 1 || summary.Updated != 0 || summary.Deleted != 0 || summary.Unchanged != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	want := []string{"tiles/5/1/3.pbf", manifestKey}
+	if got := fake.putKeys(); !slices.Equal(got, want) {
+		t.Errorf("expected uploads %v, got %v", want, got)
+	}
+	if len(fake.deletes) != 0 {
+		t.Errorf("expected no deletes for keys already gone, got %v", fake.deletes)
+	}
+}
+
+func TestSyncToR2_KeepsOtherRegionsFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "oregon")
+	writeSyncFile(t, dir, "5/1/1.pbf", "tile-a")
+
+	prior, err := json.Marshal([]ManifestEntry{
+		{Path: "5/1/1.pbf", Size: 6, SHA256: "d0b1a3e9"},
+		{Path: "5/2/2.pbf", Size: 6, SHA256: "border"},
+		{Path: "5/3/3.pbf", Size: 6, SHA256: "inland"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	neighbour, err := json.Marshal([]ManifestEntry{{Path: "5/2/2.pbf", Size: 6, SHA256: "border"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, client := newFakeS3(t)
+	fake.seedObjects(map[string]string{
+		"tiles/manifests/oregon/manifest.json":     string(prior),
+		"tiles/manifests/washington/manifest.json": string(neighbour),
+		"tiles/5/2/2.pbf":                          "border",
+		"tiles/5/3/3.pbf":                          "inland",
+	})
+
+	service := NewTileService(nil, client, &Config{S3: S3Config{BucketPath: "tiles"}})
+	summary, err := service.SyncToR2(context.Background(), dir, "oregon", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Deleted != 1 {
+		t.Errorf("expected 1 delete, got summary %+v", summary)
+	}
+	if !slices.Equal(fake.deletes, []string{"tiles/5/3/3.pbf"}) {
+		t.Errorf("expected only the tile no other region lists to be deleted, got %v", fake.deletes)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+// LatLngToTileCoord converts a lng/lat point to tile-space coordinates
+// (0..extent, y pointing down) within tile. It is the inverse of
+// TileCoordToLatLng; points outside the tile land outside 0..extent.
+// Latitudes are clamped to ±MaxMercatorLatitude.
+func LatLngToTileCoord(tile maptile.Tile, p orb.Point, extent float64) orb.Point {
+	n := math.Pow(2.0, float64(tile.Z))
+
+	lat := math.Max(-MaxMercatorLatitude, math.Min(MaxMercatorLatitude, p.Lat()))
+	latRad := lat * math.Pi / 180.0
+
+	// World coordinates in tiles (forward Mercator projection)
+	worldX := (p.Lon() + 180.0) / 360.0 * n
+	worldY := (1.0 - math.Log(math.Tan(latRad)+1.0/math.Cos(latRad))/math.Pi) / 2.0 * n
+
+	return orb.Point{
+		(worldX - float64(tile.X)) * extent,
+		(worldY - float64(tile.Y)) * extent,
+	}
+}
+
+// ProjectLineString converts every point of ls to tile-space coordinates
+func ProjectLineString(tile maptile.Tile, ls orb.LineString, extent float64) orb.LineString {
+	projected := make(orb.LineString, len(ls))
+	for i, p := range ls {
+		projected[i] = LatLngToTileCoord(tile, p, extent)
+	}
+	return projected
+}
+
+// TileClipBound returns the tile-space bound of a tile with the given
+// extent, grown by buffer on every side
+func TileClipBound(extent, buffer float64) orb.Bound {
+	return orb.Bound{
+		Min: orb.Point{-buffer, -buffer},
+		Max: orb.Point{extent + buffer, extent + buffer},
+	}
+}
+
+// ClipLineString clips ls to bound. A line that leaves and re-enters the
+// bound is split into several parts; parts with fewer than two points are
+// dropped, so a line entirely outside returns nil.
+func ClipLineString(ls orb.LineString, bound orb.Bound) orb.MultiLineString {
+	var parts orb.MultiLineString
+	var current orb.LineString
+
+	flush := func() {
+		if len(current) > 1 {
+			parts = append(parts, current)
+		}
+		current = nil
+	}
+
+	for i := 0; i+1 < len(ls); i++ {
+		a, b, ok := clipSegment(ls[i], ls[i+1], bound)
+		if !ok {
+			flush()
+			continue
+		}
+
+		// The segment entered the bound somewhere new: start a new part
+		if len(current) > 0 && current[len(current)-1] != a {
+			flush()
+		}
+		if len(current) == 0 {
+			current = orb.LineString{a}
+		}
+		current = append(current, b)
+
+		// The segment left the bound: close this part
+		if b != ls[i+1] {
+			flush()
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// clipSegment clips the segment a-b to bound using the Liang-Barsky
+// algorithm. It reports false when no part of the segment is inside.
+func clipSegment(a, b orb.Point, bound orb.Bound) (orb.Point, orb.Point, bool) {
+	dx := b[0] - a[0]
+	dy := b[1] - a[1]
+	t0, t1 := 0.0, 1.0
+
+	edges := [4][2]float64{
+		{-dx, a[0] - bound.Min[0]},
+		{dx, bound.Max[0] - a[0]},
+		{-dy, a[1] - bound.Min[1]},
+		{dy, bound.Max[1] - a[1]},
+	}
+	for _, e := range edges {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return a, b, false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return a, b, false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = orb.Point{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = orb.Point{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
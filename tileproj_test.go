@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestLatLngToTileCoord_KnownPoints(t *testing.T) {
+	const extent = 4096.0
+	world := maptile.New(0, 0, 0)
+
+	tests := []struct {
+		name string
+		tile maptile.Tile
+		in   orb.Point
+		want orb.Point
+	}{
+		{"world center", world, orb.Point{0, 0}, orb.Point{2048, 2048}},
+		{"world NW corner", world, orb.Point{-180, MaxMercatorLatitude}, orb.Point{0, 0}},
+		{"world SE corner", world, orb.Point{180, -MaxMercatorLatitude}, orb.Point{4096, 4096}},
+		{"clamped past the pole", world, orb.Point{-180, 89.9}, orb.Point{0, 0}},
+		{"z1 SE tile origin", maptile.New(1, 1, 1), orb.Point{0, 0}, orb.Point{0, 0}},
+		{"outside tile is negative", maptile.New(1, 1, 1), orb.Point{-90, 0}, orb.Point{-2048, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LatLngToTileCoord(tt.tile, tt.in, extent)
+			if math.Abs(got[0]-tt.want[0]) > 1e-6 || math.Abs(got[1]-tt.want[1]) > 1e-6 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatLngToTileCoord_InverseOfTileCoordToLatLng(t *testing.T) {
+	const extent = 4096.0
+	tile := maptile.At(orb.Point{-122.33, 47.61}, 12)
+
+	for _, xy := range []orb.Point{{0, 0}, {4096, 4096}, {1000, 3000}, {-64, 4160}} {
+		ll := TileCoordToLatLng(tile, xy[0], xy[1], extent)
+		back := LatLngToTileCoord(tile, ll, extent)
+		if math.Abs(back[0]-xy[0]) > 1e-6 || math.Abs(back[1]-xy[1]) > 1e-6 {
+			t.Errorf("round trip of %v gave %v", xy, back)
+		}
+	}
+}
+
+func TestClipLineString(t *testing.T) {
+	bound := TileClipBound(100, 10) // -10..110
+
+	tests := []struct {
+		name string
+		in   orb.LineString
+		want orb.MultiLineString
+	}{
+		{
+			name: "inside is unchanged",
+			in:   orb.LineString{{0, 0}, {50, 50}, {100, 0}},
+			want: orb.MultiLineString{{{0, 0}, {50, 50}, {100, 0}}},
+		},
+		{
+			name: "crosses right edge",
+			in:   orb.LineString{{50, 50}, {150, 50}},
+			want: orb.MultiLineString{{{50, 50}, {110, 50}}},
+		},
+		{
+			name: "crosses left and right edges",
+			in:   orb.LineString{{-50, 20}, {150, 20}},
+			want: orb.MultiLineString{{{-10, 20}, {110, 20}}},
+		},
+		{
+			name: "leaves and re-enters",
+			in:   orb.LineString{{50, 50}, {50, 200}, {80, 200}, {80, 50}},
+			want: orb.MultiLineString{{{50, 50}, {50, 110}}, {{80, 110}, {80, 50}}},
+		},
+		{
+			name: "diagonal through a corner region",
+			in:   orb.LineString{{-30, 50}, {50, -30}},
+			want: orb.MultiLineString{{{-10, 30}, {30, -10}}},
+		},
+		{
+			name: "entirely outside",
+			in:   orb.LineString{{200, 200}, {300, 250}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClipLineString(tt.in, bound)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("part %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProjectAndClip_LineCrossingTileEdge(t *testing.T) {
+	tile := maptile.New(163, 357, 10)
+	bound := tile.Bound()
+	mid := TileCoordToLatLng(tile, 2048, 2048, 4096)
+
+	// From the tile center to well past its east edge
+	line := orb.LineString{mid, {bound.Max.Lon() + (bound.Max.Lon() - bound.Min.Lon()), mid.Lat()}}
+	g := projectAndClip(tile, line)
+
+	ls, ok := g.(orb.LineString)
+	if !ok || len(ls) != 2 {
+		t.Fatalf("expected a two-point LineString, got %v", g)
+	}
+	if ls[1][0] != 4096+goEncoderBuffer {
+		t.Errorf("expected line clipped at the buffered east edge, got %v", ls)
+	}
+	if math.Abs(ls[0][0]-2048) > 1 || math.Abs(ls[0][1]-2048) > 1 {
+		t.Errorf("expected line to start at the tile center, got %v", ls[0])
+	}
+
+	if projectAndClip(tile, orb.Point{0, 0}) != nil {
+		t.Error("expected a point outside the tile to be dropped")
+	}
+}
@@ -4,30 +4,93 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
 )
 
 // GenerateTilesOptions contains options for tile generation
 type GenerateTilesOptions struct {
 	MinZoom int // Minimum zoom level (default 5)
 	MaxZoom int // Maximum zoom level (default 16)
+
+	Encoder       string // EncoderTippecanoe (default) or EncoderGo
+	TippecanoeBin string // Tippecanoe command (default "tippecanoe")
+	TempDir       string // Tippecanoe's scratch space, set as its TMPDIR ("" = inherited)
+
+	DiskSpaceFactor float64 // Require this many times the GeoJSON size free before generating (0 = no check)
+
+	// Per-tile limits passed to Tippecanoe (0 = Tippecanoe's defaults of
+	// 200,000 features and 500K). Tiles over a limit have their densest
+	// features dropped rather than failing the run.
+	MaxTileFeatures int
+	MaxTileBytes    int
+
+	FileMode os.FileMode // Applied to generated tile files (0 = leave Tippecanoe's default)
+	DirMode  os.FileMode // Applied to generated directories (0 = leave as created)
+
+	Bounds *orb.Bound // Remove generated tiles that fall outside this box (nil = keep all)
+
+	// IDAttribute is a property promoted to the MVT feature ID, as Tippecanoe's
+	// --use-attribute-for-id ("" = no feature IDs). Only non-negative integer
+	// values can be IDs; other features get none.
+	IDAttribute string
+
+	// AtomicSwap generates into a hidden sibling directory and swaps it in
+	// only once generation succeeds, so the existing tiles stay whole and
+	// servable until then and are untouched by a failed or cancelled run
+	AtomicSwap bool
+
+	Tippecanoe *TippecanoeConfig // Compression and feature-dropping tradeoffs (nil = DefaultTippecanoeConfig)
+
+	Progress ProgressFunc // Receives the zoom levels done out of the zoom range (nil = none)
 }
 
-// GenerateTiles generates vector tiles from GeoJSON using Tippecanoe
-func GenerateTiles(ctx context.Context, geoJSONPath, region string, outputBaseDir string) (string, int, int64, error) {
-	return GenerateTilesWithOptions(ctx, geoJSONPath, region, outputBaseDir, nil)
+// TippecanoeConfig holds the Tippecanoe tradeoffs that differ between
+// deployments, such as gzipped tiles for CDNs that serve them with
+// Content-Encoding, or no feature dropping at low zooms
+type TippecanoeConfig struct {
+	Compress    bool // Gzip each tile (off = --no-tile-compression)
+	DropDensest bool // Drop the densest features from tiles over a limit (--drop-densest-as-needed)
+	ExtendZooms bool // Add zooms past the maximum while features are still dropped (--extend-zooms-if-still-dropping)
+
+	MaxStringAttributeLength int // Truncate longer string attributes (0 = Tippecanoe's default)
+
+	ExtraArgs []string // Passed to Tippecanoe as-is, before the input file
 }
 
-// GenerateTilesWithOptions generates vector tiles with configurable zoom levels
-func GenerateTilesWithOptions(ctx context.Context, geoJSONPath, region string, outputBaseDir string, opts *GenerateTilesOptions) (string, int, int64, error) {
+// DefaultTippecanoeConfig is what tiles are generated with when no
+// TippecanoeConfig is set: uncompressed, dropping the densest features as
+// needed, and capping strings at 1000 characters
+var DefaultTippecanoeConfig = TippecanoeConfig{
+	DropDensest:              true,
+	ExtendZooms:              true,
+	MaxStringAttributeLength: 1000,
+}
+
+// tippecanoeConfig returns the Tippecanoe settings to generate with
+func (opts *GenerateTilesOptions) tippecanoeConfig() TippecanoeConfig {
+	if opts == nil || opts.Tippecanoe == nil {
+		return DefaultTippecanoeConfig
+	}
+	return *opts.Tippecanoe
+}
+
+// resolve returns the zoom range and encoder to use, applying defaults for
+// unset options
+func (opts *GenerateTilesOptions) resolve() (minZoom, maxZoom int, encoder string) {
 	// Default zoom levels
-	minZoom := 0
-	maxZoom := 16
+	minZoom = 0
+	maxZoom = 16
+	encoder = EncoderTippecanoe
 	if opts != nil {
 		if opts.MinZoom >= 0 {
 			minZoom = opts.MinZoom
@@ -35,50 +98,278 @@ func GenerateTilesWithOptions(ctx context.Context, geoJSONPath, region string, o
 		if opts.MaxZoom > 0 {
 			maxZoom = opts.MaxZoom
 		}
+		if opts.Encoder != "" {
+			encoder = opts.Encoder
+		}
+	}
+	return minZoom, maxZoom, encoder
+}
+
+// validateTileLimits rejects negative per-tile limits
+func (opts *GenerateTilesOptions) validateTileLimits() error {
+	if opts == nil {
+		return nil
+	}
+	if opts.MaxTileFeatures < 0 {
+		return fmt.Errorf("maximum tile features must be positive, got %d", opts.MaxTileFeatures)
+	}
+	if opts.MaxTileBytes < 0 {
+		return fmt.Errorf("maximum tile bytes must be positive, got %d", opts.MaxTileBytes)
 	}
+	return nil
+}
+
+// progress returns the ProgressFunc generation progress goes to, or nil
+func (opts *GenerateTilesOptions) progress() ProgressFunc {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+// tippecanoeBin returns the Tippecanoe command to run
+func (opts *GenerateTilesOptions) tippecanoeBin() string {
+	if opts == nil || opts.TippecanoeBin == "" {
+		return DefaultTippecanoeBin
+	}
+	return opts.TippecanoeBin
+}
 
-	logger := slog.With("region", region, "geojson", geoJSONPath, "min_zoom", minZoom, "max_zoom", maxZoom)
-	logger.Info("generating tiles with Tippecanoe")
+// DefaultTippecanoeBin is the Tippecanoe command used when none is configured
+const DefaultTippecanoeBin = "tippecanoe"
 
-	// Clean output directory before generation.
-	// If generating a partial zoom range, only remove those zoom directories
-	// to preserve tiles outside the range. For full range, wipe everything.
+// CheckTippecanoe verifies that bin resolves to an executable, either on PATH
+// or, when it contains a slash, at that path
+func CheckTippecanoe(bin string) error {
+	if _, err := exec.LookPath(bin); err != nil {
+		return fmt.Errorf("tippecanoe binary %q is not executable: %w", bin, err)
+	}
+	return nil
+}
+
+// GenerateTiles generates vector tiles from GeoJSON using Tippecanoe
+func GenerateTiles(ctx context.Context, geoJSONPath, region string, outputBaseDir string) (string, int, int64, error) {
+	return GenerateTilesWithOptions(ctx, geoJSONPath, region, outputBaseDir, nil)
+}
+
+// GenerateTilesWithOptions generates vector tiles with configurable zoom levels
+func GenerateTilesWithOptions(ctx context.Context, geoJSONPath, region string, outputBaseDir string, opts *GenerateTilesOptions) (string, int, int64, error) {
+	minZoom, maxZoom, encoder := opts.resolve()
+	if encoder != EncoderTippecanoe && encoder != EncoderGo {
+		return "", 0, 0, fmt.Errorf("unknown tile encoder %q (want %q or %q)", encoder, EncoderTippecanoe, EncoderGo)
+	}
+
+	if err := opts.validateTileLimits(); err != nil {
+		return "", 0, 0, err
+	}
+
+	logger := slog.With("region", region, "geojson", geoJSONPath, "min_zoom", minZoom, "max_zoom", maxZoom, "encoder", encoder)
+	logger.Info("generating tiles")
+	if encoder == EncoderGo && (opts.MaxTileFeatures > 0 || opts.MaxTileBytes > 0) {
+		logger.Warn("per-tile limits are ignored by the go encoder, which never drops features")
+	}
+
+	// Fail fast rather than filling the disk partway through generation
+	if opts != nil && opts.DiskSpaceFactor > 0 {
+		dirs := []string{outputBaseDir}
+		if encoder == EncoderTippecanoe {
+			tempDir := opts.TempDir
+			if tempDir == "" {
+				tempDir = os.TempDir()
+			}
+			dirs = append(dirs, tempDir)
+		}
+		if err := checkDiskSpace(geoJSONPath, opts.DiskSpaceFactor, diskFreeSpace, dirs...); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	// Clean output directory before generation, or with AtomicSwap start a
+	// fresh directory beside it and leave the old tiles alone until the swap
 	tilesDir := filepath.Join(outputBaseDir, region)
-	if minZoom == 0 && maxZoom == 16 {
-		if err := removeDirectoryContents(tilesDir); err != nil {
-			return "", 0, 0, fmt.Errorf("failed to clean tiles directory: %w", err)
+	workDir := tilesDir
+	atomicSwap := opts != nil && opts.AtomicSwap
+	if atomicSwap {
+		workDir = swapWorkDir(tilesDir)
+		if err := os.RemoveAll(workDir); err != nil {
+			return "", 0, 0, fmt.Errorf("failed to remove leftover generation directory: %w", err)
+		}
+		// Whichever step fails, the old tiles stay and the new ones are
+		// discarded; after a successful swap there is nothing left to remove
+		defer func() {
+			if err := os.RemoveAll(workDir); err != nil {
+				logger.Error("failed to remove generation directory", "dir", workDir, "error", err)
+			}
+		}()
+	} else if err := cleanTilesForZoomRange(tilesDir, minZoom, maxZoom); err != nil {
+		return "", 0, 0, err
+	}
+
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to create tiles directory: %w", err)
+	}
+
+	// Mark the directory as incomplete until generation finishes, so a hard
+	// kill mid-run leaves evidence that upload can detect
+	sentinelPath := filepath.Join(workDir, IncompleteSentinel)
+	if err := os.WriteFile(sentinelPath, []byte(region), 0644); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to write incomplete marker: %w", err)
+	}
+
+	var err error
+	if encoder == EncoderGo {
+		idAttribute := ""
+		if opts != nil {
+			idAttribute = opts.IDAttribute
 		}
+		err = encodeTilesGo(ctx, geoJSONPath, workDir, minZoom, maxZoom, idAttribute, opts.progress())
 	} else {
-		// Partial range: only remove zoom directories being regenerated
-		for z := minZoom; z <= maxZoom; z++ {
-			zoomDir := filepath.Join(tilesDir, strconv.Itoa(z))
-			if err := os.RemoveAll(zoomDir); err != nil && !os.IsNotExist(err) {
-				return "", 0, 0, fmt.Errorf("failed to clean zoom %d directory: %w", z, err)
+		stop := watchZoomProgress(workDir, zoomRange(minZoom, maxZoom), opts.progress())
+		err = runTippecanoe(ctx, opts, geoJSONPath, region, workDir, logger)
+		stop()
+	}
+	if err != nil && atomicSwap {
+		// The old tiles were never touched; only the new ones are discarded
+		if ctx.Err() != nil {
+			logger.Warn("tile generation cancelled, keeping existing tiles", "tiles_dir", tilesDir)
+			return "", 0, 0, fmt.Errorf("tile generation cancelled: %w", ctx.Err())
+		}
+		return "", 0, 0, err
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// Cancelled mid-run: the tree is partial, so remove what was written
+			logger.Warn("tile generation cancelled, removing partial output", "tiles_dir", tilesDir)
+			if cleanErr := cleanTilesForZoomRange(tilesDir, minZoom, maxZoom); cleanErr != nil {
+				logger.Error("failed to remove partial output", "error", cleanErr)
+			} else {
+				os.Remove(sentinelPath)
 			}
+			return "", 0, 0, fmt.Errorf("tile generation cancelled: %w", ctx.Err())
 		}
+		return "", 0, 0, err
 	}
 
-	if err := os.MkdirAll(tilesDir, 0755); err != nil {
-		return "", 0, 0, fmt.Errorf("failed to create tiles directory: %w", err)
+	// Prune while the directory is still marked incomplete, including any
+	// zooms Tippecanoe added past maxZoom for ExtendZooms
+	if opts != nil && opts.Bounds != nil {
+		pruneMaxZoom := maxZoom
+		if encoder == EncoderTippecanoe && opts.tippecanoeConfig().ExtendZooms {
+			pruneMaxZoom = maxTileZoom
+		}
+		pruned, err := pruneTilesOutsideBounds(workDir, *opts.Bounds, minZoom, pruneMaxZoom)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		if pruned > 0 {
+			logger.Info("removed tiles outside region bounds", "tiles_removed", pruned, "bounds", *opts.Bounds)
+		}
 	}
 
-	// Build Tippecanoe command
-	// NOTE: Must use separate --include flags for each property (not --include=Name)
-	cmd := exec.CommandContext(ctx, "tippecanoe",
+	if err := os.Remove(sentinelPath); err != nil && !os.IsNotExist(err) {
+		return "", 0, 0, fmt.Errorf("failed to remove incomplete marker: %w", err)
+	}
+
+	if opts != nil && (opts.FileMode != 0 || opts.DirMode != 0) {
+		if err := applyTilePermissions(workDir, opts.FileMode, opts.DirMode); err != nil {
+			return "", 0, 0, err
+		}
+		logger.Debug("applied tile permissions", "file_mode", opts.FileMode, "dir_mode", opts.DirMode)
+	}
+
+	if atomicSwap {
+		if err := swapTilesDir(workDir, tilesDir, minZoom, maxZoom, logger); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	// Count generated tiles
+	tilesCount, err := countTiles(tilesDir)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to count tiles: %w", err)
+	}
+
+	// Calculate total size
+	totalSize, err := getDirectorySize(tilesDir)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to calculate directory size: %w", err)
+	}
+
+	logger.Info("tiles generated successfully",
+		"tiles_count", tilesCount,
+		"total_size_bytes", totalSize,
+	)
+	if progress := opts.progress(); progress != nil {
+		progress(maxZoom-minZoom+1, maxZoom-minZoom+1)
+	}
+
+	return tilesDir, tilesCount, totalSize, nil
+}
+
+// runTippecanoe runs Tippecanoe over geoJSONPath, writing tiles into tilesDir
+func runTippecanoe(ctx context.Context, opts *GenerateTilesOptions, geoJSONPath, region, tilesDir string, logger *slog.Logger) error {
+	cmd := tippecanoeCommand(ctx, opts, tippecanoeArgs(geoJSONPath, region, tilesDir, opts))
+
+	logger.Debug("running Tippecanoe", "cmd", cmd.String())
+
+	// Capture output for debugging
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Error("Tippecanoe failed", "error", err, "output", string(output))
+		return fmt.Errorf("Tippecanoe generation failed: %w", err)
+	}
+
+	logger.Debug("Tippecanoe output", "output", string(output))
+	return nil
+}
+
+// tippecanoeCommand builds the Tippecanoe command. Its scratch files go to
+// opts.TempDir rather than wherever the inherited TMPDIR points.
+func tippecanoeCommand(ctx context.Context, opts *GenerateTilesOptions, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, opts.tippecanoeBin(), args...)
+	if opts != nil && opts.TempDir != "" {
+		cmd.Env = append(os.Environ(), "TMPDIR="+opts.TempDir)
+	}
+	return cmd
+}
+
+// tippecanoeArgs builds the Tippecanoe command line for a region
+func tippecanoeArgs(geoJSONPath, region, tilesDir string, opts *GenerateTilesOptions) []string {
+	minZoom, maxZoom, _ := opts.resolve()
+	tc := opts.tippecanoeConfig()
+
+	args := []string{
 		"--force",
 		fmt.Sprintf("--output-to-directory=%s", tilesDir),
 		"--read-parallel",
-		"--temporary-directory=/tmp",
+		// No --temporary-directory: it would override the TMPDIR set by tippecanoeCommand
 		fmt.Sprintf("--minimum-zoom=%d", minZoom),
 		fmt.Sprintf("--maximum-zoom=%d", maxZoom),
-		"--drop-densest-as-needed",
-		"--extend-zooms-if-still-dropping",
+	}
+	// Tiles over the feature or size limit drop their densest features
+	if tc.DropDensest {
+		args = append(args, "--drop-densest-as-needed")
+	}
+	if tc.ExtendZooms {
+		args = append(args, "--extend-zooms-if-still-dropping")
+	}
+	args = append(args,
 		"--layer=roads",
 		fmt.Sprintf("--name=%s Curvy Roads", region),
 		"--attribution=Data © OpenStreetMap contributors",
 		"--preserve-input-order",
-		"--maximum-string-attribute-length=1000",
-		"--no-tile-compression",
+	)
+	if tc.MaxStringAttributeLength > 0 {
+		args = append(args, fmt.Sprintf("--maximum-string-attribute-length=%d", tc.MaxStringAttributeLength))
+	}
+	if !tc.Compress {
+		args = append(args, "--no-tile-compression")
+	}
+	// NOTE: Must use separate --include flags for each property (not --include=Name)
+	args = append(args,
 		"--include", "id",
 		"--include", "Name",
 		"--include", "curvature",
@@ -87,38 +378,150 @@ func GenerateTilesWithOptions(ctx context.Context, geoJSONPath, region string, o
 		"--include", "startLng",
 		"--include", "endLat",
 		"--include", "endLng",
-		geoJSONPath,
 	)
+	if opts != nil && opts.MaxTileFeatures > 0 {
+		args = append(args, fmt.Sprintf("--maximum-tile-features=%d", opts.MaxTileFeatures))
+	}
+	if opts != nil && opts.MaxTileBytes > 0 {
+		args = append(args, fmt.Sprintf("--maximum-tile-bytes=%d", opts.MaxTileBytes))
+	}
+	if opts != nil && opts.IDAttribute != "" {
+		args = append(args, fmt.Sprintf("--use-attribute-for-id=%s", opts.IDAttribute))
+	}
+	args = append(args, tc.ExtraArgs...)
+	return append(args, geoJSONPath)
+}
 
-	logger.Debug("running Tippecanoe", "cmd", cmd.String())
-
-	// Capture output for debugging
-	output, err := cmd.CombinedOutput()
+// applyTilePermissions chmods every file under dir to fileMode and every
+// directory (including dir) to dirMode. A zero mode leaves that kind alone.
+func applyTilePermissions(dir string, fileMode, dirMode os.FileMode) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := fileMode
+		if d.IsDir() {
+			mode = dirMode
+		}
+		if mode == 0 {
+			return nil
+		}
+		return os.Chmod(path, mode)
+	})
 	if err != nil {
-		logger.Error("Tippecanoe failed", "error", err, "output", string(output))
-		return "", 0, 0, fmt.Errorf("Tippecanoe generation failed: %w", err)
+		return fmt.Errorf("failed to apply tile permissions: %w", err)
 	}
+	return nil
+}
 
-	logger.Debug("Tippecanoe output", "output", string(output))
+// IncompleteSentinel is written into a tiles directory while Tippecanoe is
+// running and removed once generation succeeds
+const IncompleteSentinel = ".incomplete"
 
-	// Count generated tiles
-	tilesCount, err := countTiles(tilesDir)
+// IsIncompleteTileDir reports whether a tiles directory was left behind by an
+// interrupted generation run
+func IsIncompleteTileDir(tilesDir string) bool {
+	_, err := os.Stat(filepath.Join(tilesDir, IncompleteSentinel))
+	return err == nil
+}
+
+// cleanTilesForZoomRange removes existing tiles before (re)generation.
+// If generating a partial zoom range, only remove those zoom directories
+// to preserve tiles outside the range. For full range, wipe everything.
+func cleanTilesForZoomRange(tilesDir string, minZoom, maxZoom int) error {
+	if minZoom == 0 && maxZoom == 16 {
+		if err := removeDirectoryContents(tilesDir); err != nil {
+			return fmt.Errorf("failed to clean tiles directory: %w", err)
+		}
+		return nil
+	}
+
+	// Partial range: only remove zoom directories being regenerated
+	for z := minZoom; z <= maxZoom; z++ {
+		zoomDir := filepath.Join(tilesDir, strconv.Itoa(z))
+		if err := os.RemoveAll(zoomDir); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clean zoom %d directory: %w", z, err)
+		}
+	}
+	return nil
+}
+
+// isEmptyTile reports whether a tile is small enough to be treated as empty.
+// In strict mode the tile must also decode to zero features; tiles that fail
+// to decode are never treated as empty.
+func isEmptyTile(path string, size, threshold int64, strict bool) bool {
+	if size >= threshold {
+		return false
+	}
+	if !strict {
+		return true
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to count tiles: %w", err)
+		return false
+	}
+	layers, err := mvt.Unmarshal(data)
+	if err != nil {
+		return false
 	}
+	for _, layer := range layers {
+		if len(layer.Features) > 0 {
+			return false
+		}
+	}
+	return true
+}
 
-	// Calculate total size
-	totalSize, err := getDirectorySize(tilesDir)
+// EncodeTile marshals layers into MVT bytes deterministically: layers are
+// sorted by name and features by ID first, so identical input produces
+// byte-identical tiles. Any code that writes tiles should use this instead of
+// calling mvt.Marshal directly. The layers are sorted in place.
+func EncodeTile(layers mvt.Layers) ([]byte, error) {
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].Name < layers[j].Name
+	})
+	for _, layer := range layers {
+		sort.SliceStable(layer.Features, func(i, j int) bool {
+			return featureIDLess(layer.Features[i].ID, layer.Features[j].ID)
+		})
+	}
+
+	data, err := mvt.Marshal(layers)
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to calculate directory size: %w", err)
+		return nil, fmt.Errorf("failed to encode tile: %w", err)
 	}
+	return data, nil
+}
 
-	logger.Info("tiles generated successfully",
-		"tiles_count", tilesCount,
-		"total_size_bytes", totalSize,
-	)
+// featureIDLess orders feature IDs numerically when both are numbers and by
+// their string form otherwise. Features without an ID sort last.
+func featureIDLess(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a != nil && b == nil
+	}
+	af, aNum := featureIDNumber(a)
+	bf, bNum := featureIDNumber(b)
+	if aNum && bNum {
+		return af < bf
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
 
-	return tilesDir, tilesCount, totalSize, nil
+// featureIDNumber converts the numeric ID types produced by mvt.Unmarshal
+// and geojson decoding to a float64
+func featureIDNumber(id interface{}) (float64, bool) {
+	switch v := id.(type) {
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
 }
 
 // countTiles counts the number of .pbf tile files in a directory
@@ -292,11 +695,15 @@ func removeDirectoryContents(dir string) error {
 
 // MergeTilesOptions contains options for tile merging
 type MergeTilesOptions struct {
-	MinZoom int // Minimum zoom level (-1 for no filter)
-	MaxZoom int // Maximum zoom level (-1 for no filter)
+	MinZoom       int    // Minimum zoom level (-1 for no filter)
+	MaxZoom       int    // Maximum zoom level (-1 for no filter)
+	TippecanoeBin string // Tippecanoe command; tile-join is looked up beside it
+
+	Progress ProgressFunc // Receives the tiles (Go merge) or zoom levels (tile-join) merged so far (nil = none)
 }
 
 // MergeTiles merges multiple regional tile directories into a single output using tile-join
+// (or the pure-Go merge when tile-join is not installed).
 // This combines tiles from overlapping regions so all roads are visible at low zoom levels
 func MergeTiles(ctx context.Context, inputDirs []string, outputDir string) (*TileMetadata, error) {
 	return MergeTilesWithOptions(ctx, inputDirs, outputDir, nil)
@@ -310,7 +717,7 @@ func MergeTilesWithOptions(ctx context.Context, inputDirs []string, outputDir st
 	if opts != nil && (opts.MinZoom >= 0 || opts.MaxZoom >= 0) {
 		logger = logger.With("min_zoom", opts.MinZoom, "max_zoom", opts.MaxZoom)
 	}
-	logger.Info("merging regional tiles with tile-join")
+	logger.Info("merging regional tiles")
 
 	if len(inputDirs) == 0 {
 		return nil, fmt.Errorf("no input directories provided for merge")
@@ -328,46 +735,12 @@ func MergeTilesWithOptions(ctx context.Context, inputDirs []string, outputDir st
 		return nil, fmt.Errorf("failed to create temp merge directory: %w", err)
 	}
 
-	// Build tile-join command
-	// tile-join merges multiple tile sources into one, combining features from overlapping tiles
-	// --no-tile-size-limit is needed because merged tiles can exceed the 500KB default limit
-	args := []string{
-		"--force",
-		"--no-tile-compression",
-		"--no-tile-size-limit",
-		fmt.Sprintf("--output-to-directory=%s", tmpDir),
-	}
-
-	// Add zoom filtering if specified
-	if opts != nil {
-		if opts.MinZoom >= 0 {
-			args = append(args, fmt.Sprintf("--minimum-zoom=%d", opts.MinZoom))
-		}
-		if opts.MaxZoom >= 0 {
-			args = append(args, fmt.Sprintf("--maximum-zoom=%d", opts.MaxZoom))
-		}
-	}
-
-	args = append(args, inputDirs...)
-
-	cmd := exec.CommandContext(ctx, "tile-join", args...)
-
-	// Set TIPPECANOE_MAX_THREADS to use all available CPUs for faster merging
-	// Inherit current environment and add/override the thread setting
-	cmd.Env = append(os.Environ(), "TIPPECANOE_MAX_THREADS=16")
-
-	logger.Debug("running tile-join", "cmd", cmd.String(), "threads", 16)
-
-	// Capture output for debugging
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		logger.Error("tile-join failed, preserving existing merged data", "error", err, "output", string(output))
+	if err := mergeTileDirs(ctx, inputDirs, tmpDir, opts); err != nil {
+		logger.Error("merge failed, preserving existing merged data", "error", err)
 		os.RemoveAll(tmpDir)
-		return nil, fmt.Errorf("tile-join merge failed: %w", err)
+		return nil, err
 	}
 
-	logger.Debug("tile-join output", "output", string(output))
-
 	// Verify the temp dir has tiles before swapping
 	tmpTileCount, err := countTiles(tmpDir)
 	if err != nil {
@@ -376,7 +749,7 @@ func MergeTilesWithOptions(ctx context.Context, inputDirs []string, outputDir st
 	}
 	if tmpTileCount == 0 {
 		os.RemoveAll(tmpDir)
-		return nil, fmt.Errorf("tile-join produced no tiles, preserving existing merged data")
+		return nil, fmt.Errorf("merge produced no tiles, preserving existing merged data")
 	}
 
 	logger.Info("merge produced tiles, performing atomic swap", "tile_count", tmpTileCount)
@@ -414,6 +787,145 @@ func MergeTilesWithOptions(ctx context.Context, inputDirs []string, outputDir st
 	return metadata, nil
 }
 
+// TileJoinBin returns the tile-join command installed alongside the
+// Tippecanoe command tippecanoeBin: in the same directory when tippecanoeBin
+// is a path, otherwise on PATH
+func TileJoinBin(tippecanoeBin string) string {
+	if strings.ContainsRune(tippecanoeBin, '/') || strings.ContainsRune(tippecanoeBin, filepath.Separator) {
+		dir := filepath.Dir(tippecanoeBin)
+		if dir == "." {
+			// filepath.Join would drop the "./" and turn the path into a
+			// PATH lookup
+			return "." + string(filepath.Separator) + "tile-join"
+		}
+		return filepath.Join(dir, "tile-join")
+	}
+	return "tile-join"
+}
+
+// progress returns the ProgressFunc merge progress goes to, or nil
+func (opts *MergeTilesOptions) progress() ProgressFunc {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+// tileJoinBin returns the tile-join command to run
+func (opts *MergeTilesOptions) tileJoinBin() string {
+	if opts == nil || opts.TippecanoeBin == "" {
+		return TileJoinBin(DefaultTippecanoeBin)
+	}
+	return TileJoinBin(opts.TippecanoeBin)
+}
+
+// CheckTileJoin verifies that bin resolves to an executable, like
+// CheckTippecanoe
+func CheckTileJoin(bin string) error {
+	if _, err := exec.LookPath(bin); err != nil {
+		return fmt.Errorf("tile-join binary %q is not executable: %w", bin, err)
+	}
+	return nil
+}
+
+// MergeWithTileJoin merges the tile directories in inputs into out with
+// tile-join when it is available, falling back to the pure-Go merge otherwise
+func MergeWithTileJoin(ctx context.Context, inputs []string, out string) error {
+	return mergeTileDirs(ctx, inputs, out, nil)
+}
+
+// mergeTileDirs merges inputDirs into outputDir with tile-join when it is
+// available, falling back to the pure-Go merge otherwise
+func mergeTileDirs(ctx context.Context, inputDirs []string, outputDir string, opts *MergeTilesOptions) error {
+	bin := opts.tileJoinBin()
+	if err := CheckTileJoin(bin); err != nil {
+		slog.Warn("tile-join not available, falling back to the Go merge", "error", err)
+		return mergeTilesGo(ctx, inputDirs, outputDir, opts)
+	}
+	return runTileJoin(ctx, bin, inputDirs, outputDir, opts)
+}
+
+// runTileJoin runs tile-join (bin) over inputDirs, writing tiles into outputDir
+func runTileJoin(ctx context.Context, bin string, inputDirs []string, outputDir string, opts *MergeTilesOptions) error {
+	// Build tile-join command
+	// tile-join merges multiple tile sources into one, combining features from overlapping tiles
+	// --no-tile-size-limit is needed because merged tiles can exceed the 500KB default limit
+	args := []string{
+		"--force",
+		"--no-tile-compression",
+		"--no-tile-size-limit",
+		fmt.Sprintf("--output-to-directory=%s", outputDir),
+	}
+
+	// Add zoom filtering if specified
+	if opts != nil {
+		if opts.MinZoom >= 0 {
+			args = append(args, fmt.Sprintf("--minimum-zoom=%d", opts.MinZoom))
+		}
+		if opts.MaxZoom >= 0 {
+			args = append(args, fmt.Sprintf("--maximum-zoom=%d", opts.MaxZoom))
+		}
+	}
+
+	args = append(args, inputDirs...)
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+
+	// Set TIPPECANOE_MAX_THREADS to use all available CPUs for faster merging
+	// Inherit current environment and add/override the thread setting
+	cmd.Env = append(os.Environ(), "TIPPECANOE_MAX_THREADS=16")
+
+	slog.Debug("running tile-join", "cmd", cmd.String(), "threads", 16)
+
+	// tile-join writes zooms in order, so progress is the input zooms done
+	zooms := inputZooms(inputDirs, opts)
+	stop := watchZoomProgress(outputDir, zooms, opts.progress())
+
+	// Capture output for debugging
+	output, err := cmd.CombinedOutput()
+	stop()
+	if err != nil {
+		slog.Error("tile-join failed", "error", err, "output", string(output))
+		return fmt.Errorf("tile-join merge failed: %w", err)
+	}
+
+	slog.Debug("tile-join output", "output", string(output))
+	if progress := opts.progress(); progress != nil && len(zooms) > 0 {
+		progress(len(zooms), len(zooms))
+	}
+	return nil
+}
+
+// inputZooms returns the zoom levels, in order, that the tile directories in
+// inputDirs have within opts' zoom filter. Inputs that are not directories,
+// such as MBTiles files, add none.
+func inputZooms(inputDirs []string, opts *MergeTilesOptions) []int {
+	seen := make(map[int]bool)
+	for _, dir := range inputDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			z, err := strconv.Atoi(entry.Name())
+			if err != nil || !entry.IsDir() {
+				continue
+			}
+			if opts != nil && ((opts.MinZoom >= 0 && z < opts.MinZoom) || (opts.MaxZoom >= 0 && z > opts.MaxZoom)) {
+				continue
+			}
+			seen[z] = true
+		}
+	}
+
+	zooms := make([]int, 0, len(seen))
+	for z := range seen {
+		zooms = append(zooms, z)
+	}
+	sort.Ints(zooms)
+	return zooms
+}
+
 // TileCoord represents a tile coordinate (zoom/x/y)
 type TileCoord struct {
 	Z, X, Y int
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
 )
 
 // createFakeTile creates a .pbf file at z/x/y.pbf within baseDir with some content
@@ -468,3 +479,538 @@ func TestGetTileMetadata(t *testing.T) {
 		t.Errorf("expected 28 bytes total, got %d", meta.TotalSize)
 	}
 }
+
+// --- GenerateTilesWithOptions cancellation tests ---
+
+// installStubTippecanoe puts a fake tippecanoe on PATH that writes one tile
+// and then blocks, simulating a long-running generation
+func installStubTippecanoe(t *testing.T) {
+	t.Helper()
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+mkdir -p "$out/5/1"
+echo partial > "$out/5/1/1.pbf"
+exec sleep 30
+`
+	if err := os.WriteFile(filepath.Join(binDir, "tippecanoe"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// writeStubTippecanoe writes an executable at a custom path that records its
+// arguments and writes a single tile into the output directory
+func writeStubTippecanoe(t *testing.T) (bin, argsFile string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "opt", "tippecanoe-1.0", "bin")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	argsFile = filepath.Join(dir, "args.txt")
+	script := `#!/bin/sh
+echo "$@" > "` + argsFile + `"
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+mkdir -p "$out/5/1"
+echo tile > "$out/5/1/1.pbf"
+`
+	bin = filepath.Join(dir, "tippecanoe-stub")
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin, argsFile
+}
+
+func TestGenerateTiles_CustomTippecanoeBin(t *testing.T) {
+	bin, argsFile := writeStubTippecanoe(t)
+	outDir := t.TempDir()
+
+	tilesDir, count, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "stubregion", outDir, &GenerateTilesOptions{MinZoom: 5, MaxZoom: 6, TippecanoeBin: bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || tilesDir != filepath.Join(outDir, "stubregion") {
+		t.Errorf("expected the stub's single tile in %s, got %d in %s", filepath.Join(outDir, "stubregion"), count, tilesDir)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("expected the stub to be invoked: %v", err)
+	}
+	if !bytes.Contains(args, []byte("--output-to-directory="+tilesDir)) {
+		t.Errorf("unexpected stub arguments %q", args)
+	}
+}
+
+func TestTippecanoeCommand_TempDir(t *testing.T) {
+	scratch := t.TempDir()
+	t.Setenv("TMPDIR", "/small-partition")
+	svc := NewTileService(nil, nil, &Config{Paths: PathsConfig{TempDir: scratch, TippecanoeBin: "/opt/bin/tippecanoe"}})
+
+	cmd := tippecanoeCommand(context.Background(), svc.generateTilesOptions("test", &JobOptions{}), nil)
+	if cmd.Path != "/opt/bin/tippecanoe" {
+		t.Errorf("expected the configured binary, got %s", cmd.Path)
+	}
+	// The last TMPDIR wins when the command starts
+	var tmpdir string
+	for _, kv := range cmd.Env {
+		if v, ok := strings.CutPrefix(kv, "TMPDIR="); ok {
+			tmpdir = v
+		}
+	}
+	if tmpdir != scratch {
+		t.Errorf("expected TMPDIR=%s in the command environment, got %q", scratch, tmpdir)
+	}
+
+	// An explicit --temporary-directory would take precedence over TMPDIR
+	for _, arg := range tippecanoeArgs("in.geojson", "test", "out", &GenerateTilesOptions{MinZoom: 5, MaxZoom: 10}) {
+		if strings.HasPrefix(arg, "--temporary-directory") || strings.HasPrefix(arg, "-t") {
+			t.Errorf("unexpected temp dir argument %q overriding TMPDIR", arg)
+		}
+	}
+
+	// Without a configured temp dir the environment is inherited untouched
+	if cmd := tippecanoeCommand(context.Background(), nil, nil); cmd.Env != nil {
+		t.Errorf("expected an inherited environment, got %v", cmd.Env)
+	}
+}
+
+func TestCheckTippecanoe(t *testing.T) {
+	bin, _ := writeStubTippecanoe(t)
+	dir := t.TempDir()
+	notExec := filepath.Join(dir, "tippecanoe")
+	if err := os.WriteFile(notExec, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", filepath.Dir(bin))
+
+	tests := []struct {
+		bin     string
+		wantErr bool
+	}{
+		{bin, false},
+		{"tippecanoe-stub", false}, // a bare name is looked up on PATH
+		{filepath.Join(dir, "missing"), true},
+		{notExec, true},
+		{dir, true},
+		{"tippecanoe", true},
+	}
+	for _, tt := range tests {
+		err := CheckTippecanoe(tt.bin)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckTippecanoe(%q) error = %v, wantErr %v", tt.bin, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGenerateTiles_CancelRemovesPartialOutput(t *testing.T) {
+	installStubTippecanoe(t)
+	outDir := t.TempDir()
+	tilesDir := filepath.Join(outDir, "testregion")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, _, err := GenerateTilesWithOptions(ctx, "input.geojson", "testregion", outDir, nil)
+		errCh <- err
+	}()
+
+	// Wait for the stub to write its partial tile before cancelling
+	partialTile := filepath.Join(tilesDir, "5", "1", "1.pbf")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(partialTile); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stub tippecanoe never wrote a tile")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	err := <-errCh
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	count, err := countTiles(tilesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected partial tiles to be removed, found %d", count)
+	}
+	if IsIncompleteTileDir(tilesDir) {
+		t.Error("expected incomplete marker to be removed after cleanup")
+	}
+}
+
+func TestGenerateTiles_CancelPartialRangeKeepsOtherZooms(t *testing.T) {
+	installStubTippecanoe(t)
+	outDir := t.TempDir()
+	tilesDir := filepath.Join(outDir, "testregion")
+
+	// Existing tiles outside the regenerated range must survive
+	createFakeTile(t, tilesDir, 10, 1, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, _, _, err := GenerateTilesWithOptions(ctx, "input.geojson", "testregion", outDir, &GenerateTilesOptions{MinZoom: 5, MaxZoom: 6})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tilesDir, "5")); !os.IsNotExist(err) {
+		t.Error("expected partial zoom 5 to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(tilesDir, "10", "1", "1.pbf")); err != nil {
+		t.Errorf("expected zoom 10 tile to be preserved: %v", err)
+	}
+}
+
+func TestIsIncompleteTileDir(t *testing.T) {
+	dir := t.TempDir()
+	if IsIncompleteTileDir(dir) {
+		t.Fatal("expected clean directory not to be incomplete")
+	}
+	if err := os.WriteFile(filepath.Join(dir, IncompleteSentinel), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsIncompleteTileDir(dir) {
+		t.Error("expected directory with marker to be incomplete")
+	}
+}
+
+func TestIsEmptyTile_UndecodableNeverEmptyInStrictMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.pbf")
+	if err := os.WriteFile(path, []byte{0xff, 0xff}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !isEmptyTile(path, 2, 50, false) {
+		t.Error("expected small tile to be empty by size")
+	}
+	if isEmptyTile(path, 2, 50, true) {
+		t.Error("expected undecodable tile not to be treated as empty in strict mode")
+	}
+	if isEmptyTile(path, 2, 2, false) {
+		t.Error("expected tile at the threshold not to be empty")
+	}
+}
+
+func TestEncodeTile_Deterministic(t *testing.T) {
+	roads := geojson.NewFeatureCollection()
+	for _, id := range []uint64{3, 1, 2} {
+		f := geojson.NewFeature(orb.LineString{{0, 0}, {float64(id) * 10, 100}})
+		f.ID = id
+		f.Properties["Name"] = fmt.Sprintf("Road %d", id)
+		f.Properties["curvature"] = float64(id * 100)
+		roads.Append(f)
+	}
+	water := geojson.NewFeatureCollection()
+	water.Append(geojson.NewFeature(orb.Point{5, 5}))
+
+	original, err := mvt.Marshal(mvt.NewLayers(map[string]*geojson.FeatureCollection{
+		"roads": roads,
+		"water": water,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode the same tile twice and scramble the second copy's ordering
+	first, err := mvt.Unmarshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := mvt.Unmarshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second[0], second[1] = second[1], second[0]
+	for _, layer := range second {
+		for i, j := 0, len(layer.Features)-1; i < j; i, j = i+1, j-1 {
+			layer.Features[i], layer.Features[j] = layer.Features[j], layer.Features[i]
+		}
+	}
+
+	a, err := EncodeTile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncodeTile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatal("expected identical tiles to encode to identical bytes")
+	}
+
+	decoded, err := mvt.Unmarshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0].Name != "roads" || decoded[1].Name != "water" {
+		t.Errorf("expected layers sorted by name, got %s, %s", decoded[0].Name, decoded[1].Name)
+	}
+	for i, f := range decoded[0].Features {
+		if id, _ := featureIDNumber(f.ID); id != float64(i+1) {
+			t.Errorf("feature %d: expected ID %d, got %v", i, i+1, f.ID)
+		}
+	}
+}
+
+func TestFeatureIDLess(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{uint64(2), uint64(10), true},
+		{uint64(10), uint64(2), false},
+		{float64(1), uint64(2), true},
+		{"a", "b", true},
+		{uint64(1), nil, true},
+		{nil, uint64(1), false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		if got := featureIDLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("featureIDLess(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// installFinishingTippecanoe puts a fake tippecanoe on PATH that writes a
+// few owner-only tiles and exits successfully
+func installFinishingTippecanoe(t *testing.T) {
+	t.Helper()
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+umask 077
+mkdir -p "$out/5/1" "$out/6/2"
+echo tile > "$out/5/1/1.pbf"
+echo tile > "$out/6/2/3.pbf"
+echo '{}' > "$out/metadata.json"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "tippecanoe"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGenerateTiles_AppliesPermissions(t *testing.T) {
+	installFinishingTippecanoe(t)
+	outDir := t.TempDir()
+
+	tilesDir, count, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "testregion", outDir,
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 6, FileMode: 0640, DirMode: 0750})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 tiles, got %d", count)
+	}
+
+	err = filepath.WalkDir(tilesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		want := os.FileMode(0640)
+		if d.IsDir() {
+			want = 0750
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s: expected mode %o, got %o", path, want, info.Mode().Perm())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyTilePermissions_ZeroModeLeavesAlone(t *testing.T) {
+	dir := t.TempDir()
+	createFakeTile(t, dir, 5, 1, 1)
+	tile := filepath.Join(dir, "5", "1", "1.pbf")
+	if err := os.Chmod(tile, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyTilePermissions(dir, 0, 0711); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode untouched, got %o", info.Mode().Perm())
+	}
+	info, err = os.Stat(filepath.Join(dir, "5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0711 {
+		t.Errorf("expected dir mode 0711, got %o", info.Mode().Perm())
+	}
+}
+
+func TestTippecanoeArgs_TileLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *GenerateTilesOptions
+		want []string
+	}{
+		{"defaults", nil, nil},
+		{"features", &GenerateTilesOptions{MaxTileFeatures: 50000}, []string{"--maximum-tile-features=50000"}},
+		{"bytes", &GenerateTilesOptions{MaxTileBytes: 300000}, []string{"--maximum-tile-bytes=300000"}},
+		{"both", &GenerateTilesOptions{MaxTileFeatures: 1000, MaxTileBytes: 2000}, []string{"--maximum-tile-features=1000", "--maximum-tile-bytes=2000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tippecanoeArgs("in.geojson", "test", "out", tt.opts)
+
+			var limits []string
+			dropDensest := false
+			for _, arg := range args {
+				if strings.HasPrefix(arg, "--maximum-tile-") {
+					limits = append(limits, arg)
+				}
+				dropDensest = dropDensest || arg == "--drop-densest-as-needed"
+			}
+			if fmt.Sprint(limits) != fmt.Sprint(tt.want) {
+				t.Errorf("expected limits %v, got %v", tt.want, limits)
+			}
+			// The limits only thin tiles out because features may be dropped
+			if !dropDensest {
+				t.Error("expected --drop-densest-as-needed alongside the limits")
+			}
+			if args[len(args)-1] != "in.geojson" {
+				t.Errorf("expected the GeoJSON input last, got %v", args)
+			}
+		})
+	}
+}
+
+func TestTippecanoeArgs_IDAttribute(t *testing.T) {
+	for _, attr := range []string{"", "osm_id"} {
+		args := tippecanoeArgs("in.geojson", "test", "out", &GenerateTilesOptions{IDAttribute: attr})
+		var got []string
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--use-attribute-for-id") {
+				got = append(got, arg)
+			}
+		}
+		want := []string{}
+		if attr != "" {
+			want = append(want, "--use-attribute-for-id="+attr)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("IDAttribute %q: expected %v, got %v", attr, want, got)
+		}
+	}
+}
+
+func TestGenerateTiles_RejectsNegativeTileLimits(t *testing.T) {
+	for _, opts := range []*GenerateTilesOptions{{MaxTileFeatures: -1}, {MaxTileBytes: -5}} {
+		outDir := t.TempDir()
+		_, _, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "limits", outDir, opts)
+		if err == nil || !strings.Contains(err.Error(), "must be positive") {
+			t.Errorf("expected a validation error for %+v, got %v", opts, err)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "limits")); !os.IsNotExist(err) {
+			t.Error("expected nothing to be written for invalid limits")
+		}
+	}
+}
+
+func TestTippecanoeArgs_TippecanoeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *TippecanoeConfig
+		want    []string // Must be present
+		notWant []string // Must be absent
+	}{
+		{
+			name:    "defaults",
+			config:  nil,
+			want:    []string{"--drop-densest-as-needed", "--extend-zooms-if-still-dropping", "--maximum-string-attribute-length=1000", "--no-tile-compression"},
+			notWant: nil,
+		},
+		{
+			name:    "compressed",
+			config:  &TippecanoeConfig{Compress: true, DropDensest: true, ExtendZooms: true, MaxStringAttributeLength: 1000},
+			want:    []string{"--drop-densest-as-needed", "--maximum-string-attribute-length=1000"},
+			notWant: []string{"--no-tile-compression"},
+		},
+		{
+			name:    "no feature dropping",
+			config:  &TippecanoeConfig{MaxStringAttributeLength: 1000},
+			want:    []string{"--no-tile-compression"},
+			notWant: []string{"--drop-densest-as-needed", "--extend-zooms-if-still-dropping"},
+		},
+		{
+			name:    "tippecanoe string length",
+			config:  &TippecanoeConfig{DropDensest: true},
+			want:    []string{"--drop-densest-as-needed"},
+			notWant: []string{"--maximum-string-attribute-length=1000", "--extend-zooms-if-still-dropping"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tippecanoeArgs("in.geojson", "test", "out", &GenerateTilesOptions{Tippecanoe: tt.config})
+			for _, arg := range tt.want {
+				if !slices.Contains(args, arg) {
+					t.Errorf("expected %s in %v", arg, args)
+				}
+			}
+			for _, arg := range tt.notWant {
+				if slices.Contains(args, arg) {
+					t.Errorf("expected no %s in %v", arg, args)
+				}
+			}
+			if args[len(args)-1] != "in.geojson" {
+				t.Errorf("expected the GeoJSON input last, got %v", args)
+			}
+		})
+	}
+}
+
+func TestTippecanoeArgs_ExtraArgs(t *testing.T) {
+	config := DefaultTippecanoeConfig
+	config.ExtraArgs = []string{"--coalesce-densest-as-needed", "--simplification=4"}
+	args := tippecanoeArgs("in.geojson", "test", "out", &GenerateTilesOptions{Tippecanoe: &config})
+
+	tail := args[len(args)-3:]
+	want := []string{"--coalesce-densest-as-needed", "--simplification=4", "in.geojson"}
+	if !slices.Equal(tail, want) {
+		t.Errorf("expected the extra arguments just before the input, got %v", args)
+	}
+	// The default arguments are unchanged
+	if got, def := args[:len(args)-3], tippecanoeArgs("in.geojson", "test", "out", nil); !slices.Equal(got, def[:len(def)-1]) {
+		t.Errorf("expected the default arguments before the extras, got %v", got)
+	}
+}
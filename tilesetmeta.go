@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataCheck is what extraction does when a tileset's metadata declares
+// tiles it cannot read correctly
+type MetadataCheck string
+
+const (
+	// MetadataCheckError fails extraction on a mismatch
+	MetadataCheckError MetadataCheck = "error"
+	// MetadataCheckWarn logs a mismatch and extracts anyway
+	MetadataCheckWarn MetadataCheck = "warn"
+	// MetadataCheckOff skips the check
+	MetadataCheckOff MetadataCheck = "off"
+)
+
+// ParseMetadataCheck parses "error", "warn" or "off" (case-insensitive). An
+// empty string means error.
+func ParseMetadataCheck(value string) (MetadataCheck, error) {
+	switch MetadataCheck(strings.ToLower(value)) {
+	case "", MetadataCheckError:
+		return MetadataCheckError, nil
+	case MetadataCheckWarn:
+		return MetadataCheckWarn, nil
+	case MetadataCheckOff:
+		return MetadataCheckOff, nil
+	}
+	return "", fmt.Errorf("unknown metadata check %q (want error, warn or off)", value)
+}
+
+// TilesetMetadata is what a tileset declares about its tiles, from a
+// directory's metadata.json (Tippecanoe metadata or TileJSON) or an MBTiles
+// metadata table. Fields the tileset does not declare are empty.
+type TilesetMetadata struct {
+	Source string // Where the metadata was read from
+	Format string // Tile format, "pbf" for vector tiles
+	Scheme string // Row convention, "xyz" or "tms"
+	CRS    string // Coordinate reference system, e.g. "EPSG:3857"
+
+	// ReadAs is the row convention the tile source reads tiles in
+	ReadAs TileScheme
+}
+
+// tilesetMetadataSource is a TileSource that can report its tileset metadata
+type tilesetMetadataSource interface {
+	// TilesetMetadata returns the tileset's metadata, or nil if it has none
+	TilesetMetadata(ctx context.Context) (*TilesetMetadata, error)
+}
+
+// webMercatorCRS are the names Web Mercator is declared under
+var webMercatorCRS = map[string]bool{
+	"EPSG:3857":   true,
+	"EPSG:900913": true,
+	"EPSG:3785":   true,
+	"EPSG:102100": true,
+	"OSGEO:41001": true,
+}
+
+// isWebMercator reports whether crs names Web Mercator, as a code
+// ("EPSG:3857") or an OGC URI (".../def/crs/EPSG/0/3857")
+func isWebMercator(crs string) bool {
+	crs = strings.ToUpper(strings.TrimSpace(crs))
+	if webMercatorCRS[crs] {
+		return true
+	}
+	if i := strings.Index(crs, "/CRS/"); i >= 0 {
+		parts := strings.Split(crs[i+len("/CRS/"):], "/")
+		if len(parts) == 3 {
+			return webMercatorCRS[parts[0]+":"+parts[2]]
+		}
+	}
+	return false
+}
+
+// Mismatches lists how the tileset differs from what extraction assumes:
+// Mapbox vector tiles in Web Mercator, with rows in the scheme they are read
+// as. Undeclared fields are assumed to match.
+func (m *TilesetMetadata) Mismatches() []string {
+	var problems []string
+	if format := strings.ToLower(m.Format); format != "" && format != "pbf" && format != "mvt" {
+		problems = append(problems, fmt.Sprintf("format is %q, not vector tiles (pbf)", m.Format))
+	}
+	if m.Scheme != "" {
+		scheme, err := ParseTileScheme(m.Scheme)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if m.ReadAs != "" && scheme != m.ReadAs {
+			problems = append(problems, fmt.Sprintf("scheme is %s but tiles are read as %s", scheme, m.ReadAs))
+		}
+	}
+	if m.CRS != "" && !isWebMercator(m.CRS) {
+		problems = append(problems, fmt.Sprintf("projection is %s, not Web Mercator (EPSG:3857)", m.CRS))
+	}
+	return problems
+}
+
+// tilesetMetadataJSON holds the metadata.json fields the check reads. crs is
+// written by GDAL; srs and projection by some other tools.
+type tilesetMetadataJSON struct {
+	Format     string `json:"format"`
+	Scheme     string `json:"scheme"`
+	CRS        string `json:"crs"`
+	SRS        string `json:"srs"`
+	Projection string `json:"projection"`
+}
+
+// crs returns the first coordinate reference system the file declares
+func (j tilesetMetadataJSON) crs() string {
+	for _, v := range []string{j.CRS, j.SRS, j.Projection} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// TilesetMetadata reads the directory's metadata.json, if it has one
+func (s *DirTileSource) TilesetMetadata(ctx context.Context) (*TilesetMetadata, error) {
+	path := filepath.Join(s.dir, MetadataFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tileset metadata: %w", err)
+	}
+
+	var raw tilesetMetadataJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse tileset metadata %s: %w", path, err)
+	}
+	return &TilesetMetadata{
+		Source: path,
+		Format: raw.Format,
+		Scheme: raw.Scheme,
+		CRS:    raw.crs(),
+		ReadAs: s.Scheme,
+	}, nil
+}
+
+// TilesetMetadata reads the MBTiles metadata table. Rows are always read as
+// TMS, so a file declaring xyz rows is reported as a mismatch.
+func (s *MBTilesSource) TilesetMetadata(ctx context.Context) (*TilesetMetadata, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT name, value FROM metadata`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mbtiles metadata: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan mbtiles metadata: %w", err)
+		}
+		values[strings.ToLower(name)] = value.String
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mbtiles metadata: %w", err)
+	}
+
+	raw := tilesetMetadataJSON{
+		Format:     values["format"],
+		Scheme:     values["scheme"],
+		CRS:        values["crs"],
+		SRS:        values["srs"],
+		Projection: values["projection"],
+	}
+	return &TilesetMetadata{
+		Source: s.path,
+		Format: raw.Format,
+		Scheme: raw.Scheme,
+		CRS:    raw.crs(),
+		ReadAs: SchemeTMS,
+	}, nil
+}
+
+// checkTilesetMetadata compares the source's metadata with what extraction
+// assumes. Depending on mode a mismatch is an error or a warning; sources
+// without metadata, or whose metadata cannot be read, are extracted as is.
+func checkTilesetMetadata(ctx context.Context, src TileSource, mode MetadataCheck, logger *slog.Logger) error {
+	if mode == MetadataCheckOff {
+		return nil
+	}
+	ms, ok := src.(tilesetMetadataSource)
+	if !ok {
+		return nil
+	}
+	metadata, err := ms.TilesetMetadata(ctx)
+	if err != nil {
+		logger.Warn("cannot check tileset metadata", "error", err)
+		return nil
+	}
+	if metadata == nil {
+		return nil
+	}
+
+	problems := metadata.Mismatches()
+	if len(problems) == 0 {
+		return nil
+	}
+	if mode == MetadataCheckWarn {
+		logger.Warn("tileset metadata does not match what extraction assumes, roads may be wrong",
+			"metadata", metadata.Source,
+			"problems", problems,
+		)
+		return nil
+	}
+	return fmt.Errorf("tileset metadata %s does not match what extraction assumes (%s); fix -scheme, or set EXTRACT_METADATA_CHECK=warn to extract anyway",
+		metadata.Source, strings.Join(problems, "; "))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMetadataCheck(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    MetadataCheck
+		wantErr bool
+	}{
+		{"", MetadataCheckError, false},
+		{"error", MetadataCheckError, false},
+		{"WARN", MetadataCheckWarn, false},
+		{"off", MetadataCheckOff, false},
+		{"ignore", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMetadataCheck(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMetadataCheck(%q) = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTilesetMetadata_Mismatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata TilesetMetadata
+		want     []string // Substrings of each expected problem
+	}{
+		{"nothing declared", TilesetMetadata{ReadAs: SchemeXYZ}, nil},
+		{"tippecanoe", TilesetMetadata{Format: "pbf", ReadAs: SchemeXYZ}, nil},
+		{"matching tms", TilesetMetadata{Format: "pbf", Scheme: "tms", ReadAs: SchemeTMS}, nil},
+		{"web mercator code", TilesetMetadata{CRS: "EPSG:3857", ReadAs: SchemeXYZ}, nil},
+		{"web mercator uri", TilesetMetadata{CRS: "http://www.opengis.net/def/crs/EPSG/0/3857", ReadAs: SchemeXYZ}, nil},
+		{"legacy web mercator", TilesetMetadata{CRS: "epsg:900913", ReadAs: SchemeXYZ}, nil},
+		{"tms read as xyz", TilesetMetadata{Scheme: "tms", ReadAs: SchemeXYZ}, []string{"scheme is tms but tiles are read as xyz"}},
+		{"xyz mbtiles", TilesetMetadata{Scheme: "xyz", ReadAs: SchemeTMS}, []string{"scheme is xyz but tiles are read as tms"}},
+		{"unknown scheme", TilesetMetadata{Scheme: "wmts", ReadAs: SchemeXYZ}, []string{"unknown tile scheme"}},
+		{"raster", TilesetMetadata{Format: "png", ReadAs: SchemeXYZ}, []string{`format is "png"`}},
+		{"geographic", TilesetMetadata{CRS: "EPSG:4326", ReadAs: SchemeXYZ}, []string{"projection is EPSG:4326"}},
+		{
+			"several",
+			TilesetMetadata{Format: "jpg", Scheme: "tms", CRS: "http://www.opengis.net/def/crs/EPSG/0/4326", ReadAs: SchemeXYZ},
+			[]string{"format", "scheme", "projection"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.metadata.Mismatches()
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d problems, got %v", len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("expected problem %d to mention %q, got %q", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+// writeTilesetMetadata writes a metadata.json into a tiles directory
+func writeTilesetMetadata(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, MetadataFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRoadGeometriesFromSource_MetadataCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string // metadata.json content ("" = none)
+		scheme   TileScheme
+		check    MetadataCheck
+		wantErr  bool
+	}{
+		{"no metadata", "", SchemeXYZ, MetadataCheckError, false},
+		{"tippecanoe metadata", `{"name":"seattle","format":"pbf"}`, SchemeXYZ, MetadataCheckError, false},
+		{"unexpected scheme", `{"format":"pbf","scheme":"tms"}`, SchemeXYZ, MetadataCheckError, true},
+		{"unexpected scheme read as declared", `{"format":"pbf","scheme":"tms"}`, SchemeTMS, MetadataCheckError, false},
+		{"unexpected scheme warns", `{"format":"pbf","scheme":"tms"}`, SchemeXYZ, MetadataCheckWarn, false},
+		{"unexpected scheme unchecked", `{"format":"pbf","scheme":"tms"}`, SchemeXYZ, MetadataCheckOff, false},
+		{"unexpected projection", `{"format":"pbf","crs":"EPSG:4326"}`, SchemeXYZ, MetadataCheckError, true},
+		{"unreadable metadata", `{not json`, SchemeXYZ, MetadataCheckError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			dir := t.TempDir()
+			writeFixtureTile(t, dir)
+			if tt.metadata != "" {
+				writeTilesetMetadata(t, dir, tt.metadata)
+			}
+			src := NewDirTileSource(dir)
+			src.Scheme = tt.scheme
+
+			e := NewGeometryExtractor()
+			e.MetadataCheck = tt.check
+			_, summary, err := e.ExtractRoadGeometriesFromSource(context.Background(), src, "seattle")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), MetadataFileName) {
+					t.Errorf("expected the error to name the metadata file, got %v", err)
+				}
+				return
+			}
+			if summary.TilesProcessed != 1 {
+				t.Errorf("expected the tile to be extracted, got %+v", summary)
+			}
+		})
+	}
+}
+
+func TestExtractRoadGeometriesFromMBTiles_MetadataCheck(t *testing.T) {
+	path, _ := fixtureMBTiles(t)
+	t.Chdir(t.TempDir())
+
+	extract := func() error {
+		src, err := OpenMBTiles(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+		_, _, err = NewGeometryExtractor().ExtractRoadGeometriesFromSource(context.Background(), src, "seattle")
+		return err
+	}
+
+	if err := extract(); err != nil {
+		t.Fatalf("expected a standard MBTiles file to pass, got %v", err)
+	}
+
+	// Rows declared as xyz would be read flipped
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`INSERT INTO metadata (name, value) VALUES ('scheme', 'xyz')`); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if err := extract(); err == nil || !strings.Contains(err.Error(), "scheme is xyz but tiles are read as tms") {
+		t.Errorf("expected a scheme mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paulmach/orb/maptile"
+)
+
+// TileSource is a readable tileset, either a z/x/y.pbf directory or an
+// MBTiles file. Tiles are always addressed in XYZ.
+type TileSource interface {
+	// Tiles lists the address of every tile in the source in a stable order
+	Tiles(ctx context.Context) ([]maptile.Tile, error)
+	// ReadTile returns the raw (possibly gzipped) tile data
+	ReadTile(ctx context.Context, tile maptile.Tile) ([]byte, error)
+	Close() error
+}
+
+// TileScheme is the y-axis convention of a tileset's addresses
+type TileScheme string
+
+const (
+	// SchemeXYZ numbers rows from the north (slippy map / Tippecanoe directories)
+	SchemeXYZ TileScheme = "xyz"
+	// SchemeTMS numbers rows from the south (MBTiles, some tile servers)
+	SchemeTMS TileScheme = "tms"
+)
+
+// ParseTileScheme parses "xyz" or "tms" (case-insensitive). An empty string
+// means XYZ.
+func ParseTileScheme(value string) (TileScheme, error) {
+	switch TileScheme(strings.ToLower(value)) {
+	case "", SchemeXYZ:
+		return SchemeXYZ, nil
+	case SchemeTMS:
+		return SchemeTMS, nil
+	}
+	return "", fmt.Errorf("unknown tile scheme %q (want xyz or tms)", value)
+}
+
+// FlipY converts a row between XYZ and TMS at zoom z. The flip is its own
+// inverse, so it converts in either direction.
+func FlipY(y uint32, z maptile.Zoom) uint32 {
+	return (uint32(1) << z) - 1 - y
+}
+
+// ToXYZ normalizes a tile addressed in this scheme to XYZ
+func (s TileScheme) ToXYZ(tile maptile.Tile) maptile.Tile {
+	if s == SchemeTMS {
+		tile.Y = FlipY(tile.Y, tile.Z)
+	}
+	return tile
+}
+
+// FromXYZ converts an XYZ tile to this scheme's addressing
+func (s TileScheme) FromXYZ(tile maptile.Tile) maptile.Tile {
+	return s.ToXYZ(tile)
+}
+
+// tileKey formats a tile address as "z/x/y"
+func tileKey(tile maptile.Tile) string {
+	return fmt.Sprintf("%d/%d/%d", tile.Z, tile.X, tile.Y)
+}
+
+// IsMBTilesPath reports whether path names an MBTiles file rather than a
+// tiles directory
+func IsMBTilesPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mbtiles")
+}
+
+// OpenTileSource opens path as an MBTiles file when it ends in .mbtiles and
+// as a z/x/y.pbf directory in the given scheme otherwise. MBTiles rows are
+// always TMS, so scheme only applies to directories.
+func OpenTileSource(path string, scheme TileScheme) (TileSource, error) {
+	if IsMBTilesPath(path) {
+		return OpenMBTiles(path)
+	}
+	src := NewDirTileSource(path)
+	if scheme != "" {
+		src.Scheme = scheme
+	}
+	return src, nil
+}
+
+// DirTileSource reads tiles from a z/x/y.pbf directory tree. Paths in a TMS
+// tree are flipped to XYZ as they are listed.
+type DirTileSource struct {
+	Scheme TileScheme
+
+	dir   string
+	paths map[maptile.Tile]string
+}
+
+// NewDirTileSource creates a tile source over a tiles directory
+func NewDirTileSource(dir string) *DirTileSource {
+	return &DirTileSource{Scheme: SchemeXYZ, dir: dir}
+}
+
+// Tiles walks the directory for .pbf files, skipping paths that are not z/x/y
+func (s *DirTileSource) Tiles(ctx context.Context) ([]maptile.Tile, error) {
+	files, err := (&GeometryExtractor{}).findPBFFiles(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find PBF files: %w", err)
+	}
+
+	s.paths = make(map[maptile.Tile]string, len(files))
+	tiles := make([]maptile.Tile, 0, len(files))
+	for _, file := range files {
+		tile, err := ParseTilePath(file)
+		if err != nil {
+			continue
+		}
+		tile = s.Scheme.ToXYZ(tile)
+		s.paths[tile] = file
+		tiles = append(tiles, tile)
+	}
+	return tiles, nil
+}
+
+// ReadTile reads a tile file
+func (s *DirTileSource) ReadTile(ctx context.Context, tile maptile.Tile) ([]byte, error) {
+	path, ok := s.paths[tile]
+	if !ok {
+		path = fmt.Sprintf("%s/%s.pbf", s.dir, tileKey(s.Scheme.FromXYZ(tile)))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile: %w", err)
+	}
+	return data, nil
+}
+
+// Close is a no-op for directories
+func (s *DirTileSource) Close() error {
+	return nil
+}
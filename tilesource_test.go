@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestParseTileScheme(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TileScheme
+		wantErr bool
+	}{
+		{"", SchemeXYZ, false},
+		{"xyz", SchemeXYZ, false},
+		{"TMS", SchemeTMS, false},
+		{"google", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTileScheme(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTileScheme(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTileScheme(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTileScheme_ToXYZ(t *testing.T) {
+	tile := maptile.New(163, 357, 10)
+
+	if got := SchemeXYZ.ToXYZ(tile); got != tile {
+		t.Errorf("xyz should be unchanged, got %v", got)
+	}
+	tms := SchemeTMS.FromXYZ(tile)
+	if tms.Y != 666 || tms.X != tile.X || tms.Z != tile.Z {
+		t.Errorf("FromXYZ(%v) = %v, want y 666", tile, tms)
+	}
+	if got := SchemeTMS.ToXYZ(tms); got != tile {
+		t.Errorf("round trip = %v, want %v", got, tile)
+	}
+}
+
+func TestDirTileSource_TMSMatchesXYZ(t *testing.T) {
+	xyzDir := t.TempDir()
+	xyzPath, tile, _ := writeFixtureTile(t, xyzDir)
+	data, err := os.ReadFile(xyzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same tile, stored under its TMS row
+	tmsDir := t.TempDir()
+	tmsPath := filepath.Join(tmsDir, tileRelPath(SchemeTMS.FromXYZ(tile)))
+	if err := os.MkdirAll(filepath.Dir(tmsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmsPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	xyzRoads, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(ctx, NewDirTileSource(xyzDir), "xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmsSrc := NewDirTileSource(tmsDir)
+	tmsSrc.Scheme = SchemeTMS
+	tmsRoads, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(ctx, tmsSrc, "tms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(xyzRoads) != 1 || len(tmsRoads) != 1 {
+		t.Fatalf("expected one road from each source, got %d and %d", len(xyzRoads), len(tmsRoads))
+	}
+	x, m := xyzRoads[0], tmsRoads[0]
+	if x.MinLat != m.MinLat || x.MaxLat != m.MaxLat || x.MinLng != m.MinLng || x.MaxLng != m.MaxLng {
+		t.Errorf("TMS bounds %+v differ from XYZ bounds %+v", m, x)
+	}
+
+	// Read as XYZ, the TMS tree lands on the wrong side of the equator
+	wrong, _, err := NewGeometryExtractor().ExtractRoadGeometriesFromSource(ctx, NewDirTileSource(tmsDir), "tms-as-xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wrong) != 1 || wrong[0].MinLat > 0 {
+		t.Errorf("expected unflipped TMS tile to decode south of the equator, got %+v", wrong)
+	}
+}
+
+func TestOpenTileSource(t *testing.T) {
+	mbtilesPath, tile := fixtureMBTiles(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		path       string
+		scheme     TileScheme
+		wantMB     bool
+		wantScheme TileScheme
+	}{
+		{"mbtiles", mbtilesPath, SchemeTMS, true, ""},
+		{"upper-case extension", filepath.Join(filepath.Dir(mbtilesPath), "SEATTLE.MBTILES"), "", true, ""},
+		{"xyz directory", dir, "", false, SchemeXYZ},
+		{"tms directory", dir, SchemeTMS, false, SchemeTMS},
+	}
+	if err := os.Link(mbtilesPath, tests[1].path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := OpenTileSource(tt.path, tt.scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+
+			switch s := src.(type) {
+			case *MBTilesSource:
+				if !tt.wantMB {
+					t.Fatal("expected a directory source")
+				}
+				// MBTiles rows are flipped regardless of the scheme argument
+				tiles, err := s.Tiles(context.Background())
+				if err != nil || len(tiles) != 1 || tiles[0] != tile {
+					t.Errorf("expected [%v], got %v (%v)", tile, tiles, err)
+				}
+			case *DirTileSource:
+				if tt.wantMB || s.Scheme != tt.wantScheme {
+					t.Errorf("unexpected directory source with scheme %q", s.Scheme)
+				}
+			}
+		})
+	}
+
+	if _, err := OpenTileSource(filepath.Join(dir, "missing.mbtiles"), ""); err == nil {
+		t.Error("expected an error for a missing mbtiles file")
+	}
+}
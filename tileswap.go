@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// renameDir renames a directory; a variable so tests can simulate renames
+// across devices
+var renameDir = os.Rename
+
+// swapWorkDir returns the hidden sibling directory an atomic-swap run
+// generates a region's tiles into. Hidden directories are never taken for
+// regions, so a leftover one is not merged or uploaded.
+func swapWorkDir(tilesDir string) string {
+	return filepath.Join(filepath.Dir(tilesDir), "."+filepath.Base(tilesDir)+".generating")
+}
+
+// swapTilesDir replaces tilesDir with newDir once generation has succeeded.
+// What an in-place run would have kept — for a partial zoom range, the other
+// zoom levels and files the new tree lacks — is moved into newDir first.
+// The swap is two renames: tilesDir is briefly missing between them, so
+// readers see the old tree, no tree, or the new one, but never a partial
+// one. When tilesDir cannot be renamed (for instance it is a
+// mount point, EXDEV/EBUSY) the new tiles are copied in place instead, which
+// is not atomic.
+func swapTilesDir(newDir, tilesDir string, minZoom, maxZoom int, logger *slog.Logger) error {
+	if _, err := os.Stat(tilesDir); os.IsNotExist(err) {
+		return moveTilesDir(newDir, tilesDir, minZoom, maxZoom, logger)
+	}
+
+	backup := filepath.Join(filepath.Dir(tilesDir), "."+filepath.Base(tilesDir)+".old")
+	if err := os.RemoveAll(backup); err != nil {
+		return fmt.Errorf("failed to remove old backup directory: %w", err)
+	}
+	if err := renameDir(tilesDir, backup); err != nil {
+		if !isCrossDeviceError(err) {
+			return fmt.Errorf("failed to move old tiles aside: %w", err)
+		}
+		return copyTilesInPlace(newDir, tilesDir, minZoom, maxZoom, err, logger)
+	}
+
+	// restore puts the old tree back after a failed swap
+	var carried []string
+	restore := func(cause error) error {
+		for _, name := range carried {
+			os.Rename(filepath.Join(newDir, name), filepath.Join(backup, name))
+		}
+		if err := renameDir(backup, tilesDir); err != nil {
+			logger.Error("failed to restore old tiles", "backup", backup, "error", err)
+		}
+		return cause
+	}
+
+	if !isFullZoomRange(minZoom, maxZoom) {
+		entries, err := os.ReadDir(backup)
+		if err != nil {
+			return restore(fmt.Errorf("failed to read old tiles: %w", err))
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if z, err := strconv.Atoi(name); err == nil && entry.IsDir() && z >= minZoom && z <= maxZoom {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(newDir, name)); err == nil {
+				continue // Regenerated, e.g. metadata.json
+			}
+			if err := os.Rename(filepath.Join(backup, name), filepath.Join(newDir, name)); err != nil {
+				return restore(fmt.Errorf("failed to keep %s from the old tiles: %w", name, err))
+			}
+			carried = append(carried, name)
+		}
+	}
+
+	if err := renameDir(newDir, tilesDir); err != nil {
+		return restore(fmt.Errorf("failed to move new tiles into place: %w", err))
+	}
+	if err := os.RemoveAll(backup); err != nil {
+		logger.Warn("failed to remove old tiles", "backup", backup, "error", err)
+	}
+	logger.Debug("swapped in new tiles", "tiles_dir", tilesDir, "kept_from_old", len(carried))
+	return nil
+}
+
+// moveTilesDir moves newDir to a tilesDir that does not exist yet
+func moveTilesDir(newDir, tilesDir string, minZoom, maxZoom int, logger *slog.Logger) error {
+	err := renameDir(newDir, tilesDir)
+	if err == nil {
+		return nil
+	}
+	if !isCrossDeviceError(err) {
+		return fmt.Errorf("failed to move new tiles into place: %w", err)
+	}
+	if err := os.MkdirAll(tilesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create tiles directory: %w", err)
+	}
+	return copyTilesInPlace(newDir, tilesDir, minZoom, maxZoom, err, logger)
+}
+
+// copyTilesInPlace is the fallback when renames are not possible: it cleans
+// the regenerated zoom range out of tilesDir and copies newDir over it
+func copyTilesInPlace(newDir, tilesDir string, minZoom, maxZoom int, renameErr error, logger *slog.Logger) error {
+	logger.Warn("cannot rename tiles directory, copying new tiles in place (not atomic)",
+		"tiles_dir", tilesDir, "error", renameErr)
+
+	if err := cleanTilesForZoomRange(tilesDir, minZoom, maxZoom); err != nil {
+		return err
+	}
+	if err := copyTilesToParent(newDir, tilesDir, logger); err != nil {
+		return fmt.Errorf("failed to copy new tiles into place: %w", err)
+	}
+	if err := os.RemoveAll(newDir); err != nil {
+		logger.Warn("failed to remove generation directory", "dir", newDir, "error", err)
+	}
+	return nil
+}
+
+// isFullZoomRange reports whether a run regenerates every zoom level, in
+// which case nothing of the old tree is kept (see cleanTilesForZoomRange)
+func isFullZoomRange(minZoom, maxZoom int) bool {
+	return minZoom == 0 && maxZoom == 16
+}
+
+// isCrossDeviceError reports whether a rename failed because source and
+// destination are on different filesystems, or the source is a mount point
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EBUSY)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+func TestGenerateTiles_AtomicSwapCancelKeepsOldTiles(t *testing.T) {
+	installStubTippecanoe(t)
+	outDir := t.TempDir()
+	tilesDir := filepath.Join(outDir, "testregion")
+	createFakeTile(t, tilesDir, 5, 0, 0)
+	createFakeTile(t, tilesDir, 10, 1, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, _, _, err := GenerateTilesWithOptions(ctx, "input.geojson", "testregion", outDir, &GenerateTilesOptions{MinZoom: 5, MaxZoom: 6, AtomicSwap: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The old tree is as it was, including the zoom being regenerated
+	for _, rel := range []string{"5/0/0.pbf", "10/1/1.pbf"} {
+		if _, err := os.Stat(filepath.Join(tilesDir, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("expected old tile %s to be kept: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tilesDir, "5", "1", "1.pbf")); !os.IsNotExist(err) {
+		t.Error("expected the partial tile not to reach the tiles directory")
+	}
+	if IsIncompleteTileDir(tilesDir) {
+		t.Error("expected the tiles directory not to be marked incomplete")
+	}
+	if _, err := os.Stat(swapWorkDir(tilesDir)); !os.IsNotExist(err) {
+		t.Error("expected the generation directory to be removed")
+	}
+}
+
+func TestGenerateTiles_AtomicSwapPruneFailureKeepsOldTiles(t *testing.T) {
+	// A Tippecanoe stand-in whose out-of-bounds tile cannot be pruned: 00.pbf
+	// lists as 6/0/0 but removing 6/0/0.pbf fails
+	bin := filepath.Join(t.TempDir(), "tippecanoe")
+	script := `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+		--output-to-directory=*) out="${arg#--output-to-directory=}" ;;
+	esac
+done
+mkdir -p "$out/6/0"
+echo tile > "$out/6/0/00.pbf"
+`
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	tilesDir := filepath.Join(outDir, "washington")
+	createFakeTile(t, tilesDir, 6, 10, 22)
+	washington := orb.Bound{Min: orb.Point{-124.9, 45.5}, Max: orb.Point{-116.9, 49.1}}
+
+	_, _, _, err := GenerateTilesWithOptions(context.Background(), "input.geojson", "washington", outDir,
+		&GenerateTilesOptions{MinZoom: 5, MaxZoom: 6, TippecanoeBin: bin, Bounds: &washington, AtomicSwap: true})
+	if err == nil {
+		t.Fatal("expected pruning to fail")
+	}
+	if _, err := os.Stat(filepath.Join(tilesDir, "6", "10", "22.pbf")); err != nil {
+		t.Errorf("expected the old tile to be kept: %v", err)
+	}
+	if _, err := os.Stat(swapWorkDir(tilesDir)); !os.IsNotExist(err) {
+		t.Error("expected the generation directory to be removed")
+	}
+}
+
+func TestGenerateTiles_AtomicSwap(t *testing.T) {
+	geoJSONPath := writeTestGeoJSON(t)
+
+	tests := []struct {
+		name             string
+		minZoom, maxZoom int
+		crossDevice      bool
+		wantKept         bool // Zoom 12 and other files survive from the old tree
+	}{
+		{"partial range keeps other zooms", 5, 8, false, true},
+		{"full range replaces everything", 0, 16, false, false},
+		{"cross-device falls back to copying", 5, 8, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.crossDevice {
+				renameDir = func(oldpath, newpath string) error {
+					return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+				}
+				t.Cleanup(func() { renameDir = os.Rename })
+			}
+			outDir := t.TempDir()
+			tilesDir := filepath.Join(outDir, "testregion")
+			createFakeTile(t, tilesDir, 5, 0, 0) // Not covered by the new tiles
+			createFakeTile(t, tilesDir, 12, 1, 1)
+			if err := os.WriteFile(filepath.Join(tilesDir, ".job-1.json"), []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			gotDir, count, _, err := GenerateTilesWithOptions(context.Background(), geoJSONPath, "testregion", outDir,
+				&GenerateTilesOptions{MinZoom: tt.minZoom, MaxZoom: tt.maxZoom, Encoder: EncoderGo, AtomicSwap: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotDir != tilesDir {
+				t.Errorf("expected tiles in %s, got %s", tilesDir, gotDir)
+			}
+
+			if _, err := os.Stat(filepath.Join(tilesDir, "5", "0", "0.pbf")); !os.IsNotExist(err) {
+				t.Error("expected the old zoom 5 tile to be replaced")
+			}
+			tiles, err := GetTileCoords(tilesDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tiles[TileCoord{Z: 8, X: 41, Y: 89}] {
+				t.Errorf("expected the new zoom 8 tile, got %v", tiles)
+			}
+			if want := tiles[TileCoord{Z: 12, X: 1, Y: 1}]; want != tt.wantKept {
+				t.Errorf("expected zoom 12 tile kept = %v, got %v", tt.wantKept, want)
+			}
+			if _, err := os.Stat(filepath.Join(tilesDir, ".job-1.json")); (err == nil) != tt.wantKept {
+				t.Errorf("expected job file kept = %v, got %v", tt.wantKept, err)
+			}
+			if want := len(tiles); count != want {
+				t.Errorf("expected the count to cover the swapped-in tree (%d), got %d", want, count)
+			}
+			if IsIncompleteTileDir(tilesDir) {
+				t.Error("expected incomplete marker to be removed")
+			}
+
+			// Nothing is left beside the tiles directory
+			entries, err := os.ReadDir(outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the tiles directory in %s, got %v", outDir, entries)
+			}
+		})
+	}
+}
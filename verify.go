@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -28,6 +30,63 @@ type TileIntegrityReport struct {
 	OK           bool
 	MissingZooms []int
 	ZoomStats    map[int]*ZoomStats
+
+	UnderpopulatedZooms []int       // Present zooms with fewer tiles than MinTiles
+	MinTiles            map[int]int // Minimum tile count applied to each zoom (none = no minimum)
+}
+
+// ZoomTileMinimums sets how many tiles each zoom level must have for a tile
+// directory to pass verification, catching partially generated zooms. A
+// zoom's minimum is the larger of its absolute and fractional minimums,
+// capped at the 4^z tiles that exist at that zoom.
+type ZoomTileMinimums struct {
+	Default  int         // Minimum for zooms without a PerZoom entry (0 = none)
+	PerZoom  map[int]int // Minimums for individual zooms
+	Fraction float64     // Minimum as a share of the zoom's 4^z tiles (0 = none)
+}
+
+// forZoom returns the minimum tile count for zoom z (0 = no minimum)
+func (m *ZoomTileMinimums) forZoom(z int) int {
+	if m == nil {
+		return 0
+	}
+	minTiles := m.Default
+	if n, ok := m.PerZoom[z]; ok {
+		minTiles = n
+	}
+	// Beyond zoom 30, 4^z overflows and every minimum is below it anyway
+	if z > 30 {
+		return minTiles
+	}
+	total := 1 << (2 * z)
+	if m.Fraction > 0 {
+		minTiles = max(minTiles, int(math.Ceil(m.Fraction*float64(total))))
+	}
+	return min(minTiles, total)
+}
+
+// ParseZoomTileCounts parses per-zoom tile counts such as "12=100,14=400"
+func ParseZoomTileCounts(value string) (map[int]int, error) {
+	counts := make(map[int]int)
+	if strings.TrimSpace(value) == "" {
+		return counts, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		zoomStr, countStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q (want zoom=count)", entry)
+		}
+		z, err := strconv.Atoi(strings.TrimSpace(zoomStr))
+		if err != nil || z < 0 {
+			return nil, fmt.Errorf("invalid zoom in %q", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(countStr))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid tile count in %q", entry)
+		}
+		counts[z] = n
+	}
+	return counts, nil
 }
 
 // Print logs the report details
@@ -37,7 +96,7 @@ func (r *TileIntegrityReport) Print() {
 	if r.OK {
 		logger.Info("tile integrity check PASSED", "zoom_levels", len(r.ZoomStats))
 	} else {
-		logger.Error("tile integrity check FAILED", "missing_zooms", r.MissingZooms)
+		logger.Error("tile integrity check FAILED", "missing_zooms", r.MissingZooms, "underpopulated_zooms", r.UnderpopulatedZooms)
 	}
 
 	for z := r.MinZoom; z <= r.MaxZoom; z++ {
@@ -49,6 +108,9 @@ func (r *TileIntegrityReport) Print() {
 				"x_range", fmt.Sprintf("%d-%d", stats.MinX, stats.MaxX),
 				"y_range", fmt.Sprintf("%d-%d", stats.MinY, stats.MaxY),
 			)
+			if minTiles := r.MinTiles[z]; stats.TileCount < minTiles {
+				slog.Warn("zoom level UNDERPOPULATED", "zoom", z, "tiles", stats.TileCount, "min_tiles", minTiles)
+			}
 		} else {
 			slog.Warn("zoom level MISSING", "zoom", z)
 		}
@@ -103,12 +165,18 @@ type UploadVerifyReport struct {
 	Checked        int
 	Missing        []string // s3 keys that were missing
 	SamplesPerZoom int
+	Seed           int64    // Seed the samples were drawn with
+	Sampled        []string // s3 keys sampled, by zoom then draw order
 }
 
 // Print logs the upload verification report
 func (r *UploadVerifyReport) Print() {
-	logger := slog.With("tiles_dir", r.TilesDir, "s3_prefix", r.S3Prefix, "checked", r.Checked)
+	logger := slog.With("tiles_dir", r.TilesDir, "s3_prefix", r.S3Prefix, "checked", r.Checked, "seed", r.Seed)
 
+	// The keys and seed are enough to repeat the exact check elsewhere
+	for _, key := range r.Sampled {
+		slog.Info("sampled tile", "key", key)
+	}
 	if r.OK {
 		logger.Info("upload verification PASSED")
 	} else {
@@ -116,17 +184,106 @@ func (r *UploadVerifyReport) Print() {
 		for _, key := range r.Missing {
 			slog.Error("missing from R2", "key", key)
 		}
+		logger.Info("rerun with the same sample", "flag", fmt.Sprintf("-seed %d", r.Seed))
 	}
 }
 
+// UploadFullVerifyReport compares every local tile with a listing of R2
+type UploadFullVerifyReport struct {
+	TilesDir string
+	S3Prefix string
+	OK       bool     // No local tile is missing from R2
+	Local    int      // Tiles in TilesDir
+	Remote   int      // Tiles listed under S3Prefix
+	Missing  []string // Keys of local tiles not on R2
+	Orphaned []string // Keys of tiles on R2 with no local counterpart
+}
+
+// maxLoggedOrphans is how many orphaned keys UploadFullVerifyReport.Print
+// lists; a shared prefix can hold every other region's tiles
+const maxLoggedOrphans = 20
+
+// Print logs the full upload verification report
+func (r *UploadFullVerifyReport) Print() {
+	logger := slog.With("tiles_dir", r.TilesDir, "s3_prefix", r.S3Prefix, "local_tiles", r.Local, "remote_tiles", r.Remote)
+
+	for _, key := range r.Missing {
+		slog.Error("missing from R2", "key", key)
+	}
+	if len(r.Orphaned) > 0 {
+		slog.Warn("orphaned on R2",
+			"count", len(r.Orphaned),
+			"first_keys", r.Orphaned[:min(len(r.Orphaned), maxLoggedOrphans)],
+		)
+	}
+	if r.OK {
+		logger.Info("full upload verification PASSED", "orphaned", len(r.Orphaned))
+	} else {
+		logger.Error("full upload verification FAILED", "missing", len(r.Missing), "orphaned", len(r.Orphaned))
+	}
+}
+
+// VerifyUploadFull checks every tile in tilesDir against a single paginated
+// listing of s3Prefix, rather than a HeadObject per tile. Remote tiles
+// without a local counterpart are reported as orphaned but do not fail the
+// check: tiles of every region share the prefix, so only a comparison with
+// the merged directory makes them meaningful.
+func VerifyUploadFull(ctx context.Context, s3Client *S3Client, tilesDir, s3Prefix string) (*UploadFullVerifyReport, error) {
+	report := &UploadFullVerifyReport{TilesDir: tilesDir, S3Prefix: s3Prefix}
+
+	local, err := GetTileCoords(tilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local tiles: %w", err)
+	}
+	report.Local = len(local)
+
+	prefix := s3Prefix + "/"
+	keys, err := s3Client.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := make(map[TileCoord]bool, len(keys))
+	for _, key := range keys {
+		// Manifests and metadata are not tiles
+		coord, ok := tileCoordFromKey(strings.TrimPrefix(key, prefix))
+		if !ok {
+			continue
+		}
+		remote[coord] = true
+		if !local[coord] {
+			report.Orphaned = append(report.Orphaned, key)
+		}
+	}
+	report.Remote = len(remote)
+
+	for coord := range local {
+		if !remote[coord] {
+			report.Missing = append(report.Missing, fmt.Sprintf("%s%d/%d/%d.pbf", prefix, coord.Z, coord.X, coord.Y))
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Orphaned)
+
+	report.OK = len(report.Missing) == 0
+	return report, nil
+}
+
 // VerifyTileDirectory checks that a tile directory has tiles at every zoom level
 // from minZoom to maxZoom, and collects per-zoom statistics.
 func VerifyTileDirectory(dir string, minZoom, maxZoom int) (*TileIntegrityReport, error) {
+	return VerifyTileDirectoryWithMinimums(dir, minZoom, maxZoom, nil)
+}
+
+// VerifyTileDirectoryWithMinimums is VerifyTileDirectory that also fails when
+// a zoom level has fewer tiles than mins requires (nil = presence only)
+func VerifyTileDirectoryWithMinimums(dir string, minZoom, maxZoom int, mins *ZoomTileMinimums) (*TileIntegrityReport, error) {
 	report := &TileIntegrityReport{
 		Dir:       dir,
 		MinZoom:   minZoom,
 		MaxZoom:   maxZoom,
 		ZoomStats: make(map[int]*ZoomStats),
+		MinTiles:  make(map[int]int),
 	}
 
 	// Walk the directory and collect stats
@@ -196,14 +353,22 @@ func VerifyTileDirectory(dir string, minZoom, maxZoom int) (*TileIntegrityReport
 		return nil, fmt.Errorf("failed to walk tile directory: %w", err)
 	}
 
-	// Check which zoom levels are missing
+	// Check which zoom levels are missing or have too few tiles
 	for z := minZoom; z <= maxZoom; z++ {
-		if _, ok := report.ZoomStats[z]; !ok {
+		stats, ok := report.ZoomStats[z]
+		if !ok {
 			report.MissingZooms = append(report.MissingZooms, z)
+			continue
+		}
+		if minTiles := mins.forZoom(z); minTiles > 0 {
+			report.MinTiles[z] = minTiles
+			if stats.TileCount < minTiles {
+				report.UnderpopulatedZooms = append(report.UnderpopulatedZooms, z)
+			}
 		}
 	}
 
-	report.OK = len(report.MissingZooms) == 0
+	report.OK = len(report.MissingZooms) == 0 && len(report.UnderpopulatedZooms) == 0
 	return report, nil
 }
 
@@ -264,11 +429,13 @@ func VerifyMergeIntegrity(regionDir, mergedDir string) (*MergeIntegrityReport, e
 }
 
 // VerifyUpload spot-checks that tiles exist on R2 by sampling N tiles per zoom level.
-func VerifyUpload(ctx context.Context, s3Client *S3Client, tilesDir, s3Prefix string, samplesPerZoom int) (*UploadVerifyReport, error) {
+// The same seed and tiles directory always sample the same tiles.
+func VerifyUpload(ctx context.Context, s3Client *S3Client, tilesDir, s3Prefix string, samplesPerZoom int, seed int64) (*UploadVerifyReport, error) {
 	report := &UploadVerifyReport{
 		TilesDir:       tilesDir,
 		S3Prefix:       s3Prefix,
 		SamplesPerZoom: samplesPerZoom,
+		Seed:           seed,
 	}
 
 	// Collect tiles per zoom level
@@ -305,13 +472,21 @@ func VerifyUpload(ctx context.Context, s3Client *S3Client, tilesDir, s3Prefix st
 		return nil, fmt.Errorf("failed to walk tiles directory: %w", err)
 	}
 
-	// Sample and check tiles per zoom level
-	for z, tiles := range tilesByZoom {
+	// Sample and check tiles per zoom level. Walk order is lexical and zooms
+	// are visited in order, so the draws depend only on seed and directory.
+	zooms := make([]int, 0, len(tilesByZoom))
+	for z := range tilesByZoom {
+		zooms = append(zooms, z)
+	}
+	sort.Ints(zooms)
+	rng := rand.New(rand.NewSource(seed))
+
+	for _, z := range zooms {
 		// Select samples
-		samples := tiles
+		samples := tilesByZoom[z]
 		if len(samples) > samplesPerZoom {
 			// Shuffle and take first N
-			rand.Shuffle(len(samples), func(i, j int) {
+			rng.Shuffle(len(samples), func(i, j int) {
 				samples[i], samples[j] = samples[j], samples[i]
 			})
 			samples = samples[:samplesPerZoom]
@@ -319,6 +494,7 @@ func VerifyUpload(ctx context.Context, s3Client *S3Client, tilesDir, s3Prefix st
 
 		for _, rel := range samples {
 			s3Key := filepath.Join(s3Prefix, filepath.ToSlash(rel))
+			report.Sampled = append(report.Sampled, s3Key)
 
 			_, exists, err := s3Client.HeadObject(ctx, s3Key)
 			if err != nil {
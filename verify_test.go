@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -213,3 +218,210 @@ func TestVerifyMergeIntegrity_SmallerMergedTile(t *testing.T) {
 		t.Errorf("expected 1 warning, got %d", len(report.Warnings))
 	}
 }
+
+func TestVerifyUpload_SeededSample(t *testing.T) {
+	dir := t.TempDir()
+	for x := 0; x < 20; x++ {
+		createFakeTile(t, dir, 10, x, 7)
+		createFakeTile(t, dir, 11, x, 3)
+	}
+	createFakeTile(t, dir, 5, 1, 1) // Fewer tiles than samples: all are checked
+	fake, client := newFakeS3(t)
+
+	run := func(seed int64) *UploadVerifyReport {
+		t.Helper()
+		report, err := VerifyUpload(context.Background(), client, dir, "tiles", 4, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	first := run(42)
+	if first.Seed != 42 || len(first.Sampled) != 9 || first.Checked != 9 {
+		t.Fatalf("expected 9 keys sampled and checked with seed 42, got %d/%d (seed %d)", len(first.Sampled), first.Checked, first.Seed)
+	}
+	if first.Sampled[0] != "tiles/5/1/1.pbf" {
+		t.Errorf("expected zooms to be sampled in order, got %v", first.Sampled)
+	}
+	// Nothing was uploaded, so every sampled key is missing
+	if first.OK || !reflect.DeepEqual(first.Missing, first.Sampled) {
+		t.Errorf("expected every sampled key reported missing, got %v", first.Missing)
+	}
+
+	// The same seed checks the same tiles, run after run
+	for i := 0; i < 5; i++ {
+		if got := run(42).Sampled; !reflect.DeepEqual(got, first.Sampled) {
+			t.Fatalf("run %d: expected sample %v, got %v", i, first.Sampled, got)
+		}
+	}
+
+	// A different seed draws a different sample
+	differs := false
+	for seed := int64(1); seed <= 5 && !differs; seed++ {
+		differs = !reflect.DeepEqual(run(seed).Sampled, first.Sampled)
+	}
+	if !differs {
+		t.Error("expected other seeds to sample other tiles")
+	}
+
+	// Uploaded tiles pass
+	for _, key := range first.Sampled {
+		fake.objects[key] = true
+	}
+	if report := run(42); !report.OK || len(report.Missing) != 0 {
+		t.Errorf("expected the sampled tiles to be found, missing %v", report.Missing)
+	}
+}
+
+func TestVerifyUploadFull(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []TileCoord{{5, 1, 1}, {5, 1, 2}, {6, 2, 3}, {7, 4, 6}} {
+		createFakeTile(t, dir, c.Z, c.X, c.Y)
+	}
+
+	tests := []struct {
+		name         string
+		remote       []string
+		wantMissing  []string
+		wantOrphaned []string
+	}{
+		{
+			name:   "all uploaded",
+			remote: []string{"tiles/5/1/1.pbf", "tiles/5/1/2.pbf", "tiles/6/2/3.pbf", "tiles/7/4/6.pbf", "tiles/oregon/metadata.json"},
+		},
+		{
+			name:         "missing and orphaned",
+			remote:       []string{"tiles/5/1/1.pbf", "tiles/6/2/3.pbf", "tiles/8/9/9.pbf", "tiles/5/0/0.pbf", "tiles/manifests/oregon/manifest.json", "other/7/4/6.pbf"},
+			wantMissing:  []string{"tiles/5/1/2.pbf", "tiles/7/4/6.pbf"},
+			wantOrphaned: []string{"tiles/5/0/0.pbf", "tiles/8/9/9.pbf"},
+		},
+		{
+			name:        "nothing uploaded",
+			wantMissing: []string{"tiles/5/1/1.pbf", "tiles/5/1/2.pbf", "tiles/6/2/3.pbf", "tiles/7/4/6.pbf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			fake.listPageSize = 2
+			for _, key := range tt.remote {
+				fake.objects[key] = true
+			}
+
+			report, err := VerifyUploadFull(context.Background(), client, dir, "tiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.OK != (len(tt.wantMissing) == 0) || report.Local != 4 {
+				t.Errorf("expected OK=%v with 4 local tiles, got %+v", len(tt.wantMissing) == 0, report)
+			}
+			if fmt.Sprint(report.Missing) != fmt.Sprint(tt.wantMissing) {
+				t.Errorf("expected missing %v, got %v", tt.wantMissing, report.Missing)
+			}
+			if fmt.Sprint(report.Orphaned) != fmt.Sprint(tt.wantOrphaned) {
+				t.Errorf("expected orphaned %v, got %v", tt.wantOrphaned, report.Orphaned)
+			}
+			// One paginated listing (two keys a page), however many tiles there are
+			listed := 0
+			for _, key := range tt.remote {
+				if strings.HasPrefix(key, "tiles/") {
+					listed++
+				}
+			}
+			if want := max(1, (listed+1)/2); fake.listRequests != want {
+				t.Errorf("expected %d list requests, got %d", want, fake.listRequests)
+			}
+		})
+	}
+}
+
+func TestVerifyTileDirectory_MinimumTiles(t *testing.T) {
+	dir := t.TempDir()
+	// Zoom 0 has its single tile, zoom 6 is full, zoom 7 stopped after one tile
+	createFakeTile(t, dir, 0, 0, 0)
+	for x := 0; x < 8; x++ {
+		createFakeTile(t, dir, 6, x, 0)
+	}
+	createFakeTile(t, dir, 7, 0, 0)
+
+	tests := []struct {
+		name             string
+		minZoom, maxZoom int
+		mins             *ZoomTileMinimums
+		wantUnder        []int
+	}{
+		{"presence only", 6, 7, nil, nil},
+		{"absolute minimum", 6, 7, &ZoomTileMinimums{Default: 4}, []int{7}},
+		// Zoom 0 only has one tile to give
+		{"capped at the zoom's tiles", 0, 0, &ZoomTileMinimums{Default: 8}, nil},
+		{"per-zoom override", 6, 7, &ZoomTileMinimums{Default: 4, PerZoom: map[int]int{6: 9, 7: 1}}, []int{6}},
+		{"fraction of the zoom's tiles", 6, 7, &ZoomTileMinimums{Fraction: 0.5}, []int{6, 7}},
+		{"fraction below the absolute minimum", 6, 7, &ZoomTileMinimums{Default: 2, Fraction: 0.0001}, []int{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := VerifyTileDirectoryWithMinimums(dir, tt.minZoom, tt.maxZoom, tt.mins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.MissingZooms) != 0 {
+				t.Errorf("expected no missing zooms, got %v", report.MissingZooms)
+			}
+			if !reflect.DeepEqual(report.UnderpopulatedZooms, tt.wantUnder) {
+				t.Errorf("expected underpopulated zooms %v, got %v", tt.wantUnder, report.UnderpopulatedZooms)
+			}
+			if report.OK != (len(tt.wantUnder) == 0) {
+				t.Errorf("expected OK=%v, got %v", len(tt.wantUnder) == 0, report.OK)
+			}
+		})
+	}
+}
+
+func TestParseZoomTileCounts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[int]int
+		wantErr bool
+	}{
+		{"", map[int]int{}, false},
+		{"12=100", map[int]int{12: 100}, false},
+		{"12=100, 14 = 400", map[int]int{12: 100, 14: 400}, false},
+		{"12", nil, true},
+		{"x=1", nil, true},
+		{"12=-1", nil, true},
+		{"-1=5", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseZoomTileCounts(tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("ParseZoomTileCounts(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestUploadFullVerifyReport_PrintLimitsOrphans(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	report := &UploadFullVerifyReport{OK: true}
+	for i := 0; i < maxLoggedOrphans+5; i++ {
+		report.Orphaned = append(report.Orphaned, fmt.Sprintf("tiles/9/%d/0.pbf", i))
+	}
+	report.Print()
+
+	out := logs.String()
+	if n := strings.Count(out, "orphaned on R2"); n != 1 {
+		t.Errorf("expected one orphan line, got %d: %s", n, out)
+	}
+	if !strings.Contains(out, fmt.Sprintf("count=%d", maxLoggedOrphans+5)) {
+		t.Errorf("expected the orphan count, got %s", out)
+	}
+	if !strings.Contains(out, report.Orphaned[maxLoggedOrphans-1]) || strings.Contains(out, report.Orphaned[maxLoggedOrphans]) {
+		t.Errorf("expected only the first %d keys, got %s", maxLoggedOrphans, out)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// workersFlag is a command's -workers flag: how many files or tiles it works
+// on at once. Zero means the flag was not given, so the command's configured
+// default applies; a given count must be at least 1.
+type workersFlag int
+
+func (w *workersFlag) String() string {
+	return strconv.Itoa(int(*w))
+}
+
+func (w *workersFlag) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid worker count %q", value)
+	}
+	if n < 1 {
+		return fmt.Errorf("worker count must be at least 1, got %d", n)
+	}
+	*w = workersFlag(n)
+	return nil
+}
+
+// Or returns the count given on the command line, or def if there was none
+func (w workersFlag) Or(def int) int {
+	if w > 0 {
+		return int(w)
+	}
+	return def
+}
+
+// addWorkersFlag registers -workers on fs, plus each alias (e.g. the older
+// -concurrency) setting the same count
+func addWorkersFlag(fs *flag.FlagSet, usage string, aliases ...string) *workersFlag {
+	w := new(workersFlag)
+	fs.Var(w, "workers", usage)
+	for _, alias := range aliases {
+		fs.Var(w, alias, "Same as -workers")
+	}
+	return w
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestWorkersFlag(t *testing.T) {
+	const configDefault = 6
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{"not given uses the config default", nil, configDefault, false},
+		{"overrides the config default", []string{"-workers", "3"}, 3, false},
+		{"one worker", []string{"-workers=1"}, 1, false},
+		{"alias overrides the config default", []string{"-concurrency", "12"}, 12, false},
+		{"zero is rejected", []string{"-workers", "0"}, 0, true},
+		{"negative is rejected", []string{"-workers", "-2"}, 0, true},
+		{"not a number", []string{"-workers", "many"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			workers := addWorkersFlag(fs, "Files at once", "concurrency")
+
+			err := fs.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := workers.Or(configDefault); got != tt.want {
+				t.Errorf("expected %d workers, got %d", tt.want, got)
+			}
+		})
+	}
+}